@@ -0,0 +1,158 @@
+// Package corerd provides helpers for the registration interface of the CoRE Resource Directory (RFC 9176).
+package corerd
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/message/linkformat"
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+	"github.com/plgd-dev/go-coap/v3/mux"
+)
+
+const (
+	// DefaultRegistrationPath is the path of the registration interface announced by rt="core.rd".
+	DefaultRegistrationPath = "/rd"
+	// DefaultLifetime is used when the registration doesn't contain the lt parameter.
+	DefaultLifetime = 90000 * time.Second
+	// MaxEndpointNameLength is the maximal length of the ep and d parameters.
+	MaxEndpointNameLength = 63
+)
+
+// Registration parameters of RFC 9176 section 5.3.
+const (
+	ParamEndpoint     = "ep"
+	ParamSector       = "d"
+	ParamLifetime     = "lt"
+	ParamBase         = "base"
+	ParamEndpointType = "et"
+	// ParamContext is the name of the base parameter used by the drafts of the resource directory.
+	ParamContext = "con"
+)
+
+var (
+	ErrMissingEndpoint          = errors.New("missing endpoint name")
+	ErrInvalidParam             = errors.New("invalid registration parameter")
+	ErrUnsupportedContentFormat = errors.New("unsupported content format")
+)
+
+// Registration is the registration request of an endpoint.
+type Registration struct {
+	// Endpoint is the endpoint name (ep).
+	Endpoint string
+	// Sector is the sector of the endpoint (d).
+	Sector string
+	// Lifetime of the registration (lt). DefaultLifetime when it is not set.
+	Lifetime time.Duration
+	// Base URI of the links (base or con). Empty means the source address of the request.
+	Base string
+	// EndpointType is the type of the endpoint (et).
+	EndpointType string
+	// Params contains the other parameters of the query, e.g. the endpoint attributes.
+	Params message.QueryParams
+	// Links are the links posted by the endpoint.
+	Links linkformat.Links
+}
+
+func checkName(key, value string) error {
+	if value == "" || len(value) > MaxEndpointNameLength {
+		return fmt.Errorf("%w: %v must have 1-%v characters", ErrInvalidParam, key, MaxEndpointNameLength)
+	}
+	return nil
+}
+
+func parseLifetime(value string) (time.Duration, error) {
+	// the lifetime is 1-4294967295 seconds (RFC 9176 section 5.3)
+	lt, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%w: lt(%v): %w", ErrInvalidParam, value, err)
+	}
+	if lt == 0 {
+		return 0, fmt.Errorf("%w: lt(%v) must be at least 1", ErrInvalidParam, value)
+	}
+	return time.Duration(lt) * time.Second, nil
+}
+
+// ParseRegistrationParams parses the query parameters of the registration request.
+func ParseRegistrationParams(params message.QueryParams) (Registration, error) {
+	reg := Registration{
+		Lifetime: DefaultLifetime,
+	}
+	for _, p := range params {
+		var err error
+		switch p.Key {
+		case ParamEndpoint:
+			reg.Endpoint = p.Value
+			err = checkName(p.Key, p.Value)
+		case ParamSector:
+			reg.Sector = p.Value
+			err = checkName(p.Key, p.Value)
+		case ParamLifetime:
+			reg.Lifetime, err = parseLifetime(p.Value)
+		case ParamBase, ParamContext:
+			reg.Base = p.Value
+		case ParamEndpointType:
+			reg.EndpointType = p.Value
+		default:
+			reg.Params = append(reg.Params, p)
+		}
+		if err != nil {
+			return Registration{}, err
+		}
+	}
+	if reg.Endpoint == "" {
+		return Registration{}, ErrMissingEndpoint
+	}
+	return reg, nil
+}
+
+// ParseRegistration parses the registration request: POST with the registration parameters
+// in the Uri-Query options and the links of the endpoint in the application/link-format payload.
+func ParseRegistration(r *pool.Message) (Registration, error) {
+	if r.Code() != codes.POST {
+		return Registration{}, fmt.Errorf("invalid code(%v) of registration: expected POST", r.Code())
+	}
+	params, err := r.QueryParams()
+	if err != nil {
+		return Registration{}, fmt.Errorf("cannot get query parameters: %w", err)
+	}
+	reg, err := ParseRegistrationParams(params)
+	if err != nil {
+		return Registration{}, err
+	}
+	if r.Body() == nil {
+		return reg, nil
+	}
+	if cf, errC := r.ContentFormat(); errC == nil && cf != message.AppLinkFormat {
+		return Registration{}, fmt.Errorf("%w: %v", ErrUnsupportedContentFormat, cf)
+	}
+	payload, err := r.ReadBody()
+	if err != nil {
+		return Registration{}, fmt.Errorf("cannot read links: %w", err)
+	}
+	reg.Links, err = linkformat.Parse(payload)
+	if err != nil {
+		return Registration{}, err
+	}
+	return reg, nil
+}
+
+// RegistrationLocation returns the path of the registration resource created under the registration path, e.g. /rd/4521.
+func RegistrationLocation(registrationPath, id string) string {
+	return path.Join("/", registrationPath, id)
+}
+
+// SetRegistrationResponse sets 2.01 Created response with the location of the registration resource in the Location-Path options.
+func SetRegistrationResponse(w mux.ResponseWriter, location string) error {
+	buf := make([]byte, len(location))
+	opts, _, err := message.Options{}.SetLocationPath(buf, location)
+	if err != nil {
+		return fmt.Errorf("cannot set location(%v): %w", location, err)
+	}
+	return w.SetResponse(codes.Created, message.TextPlain, nil, opts...)
+}
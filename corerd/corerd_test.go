@@ -0,0 +1,119 @@
+package corerd_test
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/plgd-dev/go-coap/v3/corerd"
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/mux"
+	coapNet "github.com/plgd-dev/go-coap/v3/net"
+	"github.com/plgd-dev/go-coap/v3/options"
+	"github.com/plgd-dev/go-coap/v3/udp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRegistrationParams(t *testing.T) {
+	reg, err := corerd.ParseRegistrationParams(message.QueryParams{
+		{Key: "ep", Value: "node1"},
+		{Key: "lt", Value: "3600"},
+		{Key: "con", Value: "coap://[2001:db8::1]"},
+		{Key: "x", Value: "y"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, corerd.Registration{
+		Endpoint: "node1",
+		Lifetime: time.Hour,
+		Base:     "coap://[2001:db8::1]",
+		Params:   message.QueryParams{{Key: "x", Value: "y"}},
+	}, reg)
+
+	reg, err = corerd.ParseRegistrationParams(message.QueryParams{{Key: "ep", Value: "node1"}})
+	require.NoError(t, err)
+	require.Equal(t, corerd.DefaultLifetime, reg.Lifetime)
+
+	_, err = corerd.ParseRegistrationParams(message.QueryParams{{Key: "lt", Value: "3600"}})
+	require.ErrorIs(t, err, corerd.ErrMissingEndpoint)
+	reg, err = corerd.ParseRegistrationParams(message.QueryParams{{Key: "ep", Value: "node1"}, {Key: "lt", Value: "10"}})
+	require.NoError(t, err)
+	require.Equal(t, 10*time.Second, reg.Lifetime)
+	reg, err = corerd.ParseRegistrationParams(message.QueryParams{{Key: "ep", Value: "node1"}, {Key: "lt", Value: "4294967295"}})
+	require.NoError(t, err)
+	require.Equal(t, 4294967295*time.Second, reg.Lifetime)
+	_, err = corerd.ParseRegistrationParams(message.QueryParams{{Key: "ep", Value: "node1"}, {Key: "lt", Value: "0"}})
+	require.ErrorIs(t, err, corerd.ErrInvalidParam)
+	_, err = corerd.ParseRegistrationParams(message.QueryParams{{Key: "ep", Value: "node1"}, {Key: "lt", Value: "4294967296"}})
+	require.ErrorIs(t, err, corerd.ErrInvalidParam)
+	_, err = corerd.ParseRegistrationParams(message.QueryParams{{Key: "ep", Value: "node1"}, {Key: "lt", Value: "x"}})
+	require.ErrorIs(t, err, corerd.ErrInvalidParam)
+}
+
+func TestRegistration(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	registrations := make(chan corerd.Registration, 1)
+	m := mux.NewRouter()
+	err = m.Handle(corerd.DefaultRegistrationPath, mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		reg, errP := corerd.ParseRegistration(r.Message)
+		if errP != nil {
+			errS := w.SetResponse(codes.BadRequest, message.TextPlain, bytes.NewReader([]byte(errP.Error())))
+			assert.NoError(t, errS)
+			return
+		}
+		registrations <- reg
+		errS := corerd.SetRegistrationResponse(w, corerd.RegistrationLocation(corerd.DefaultRegistrationPath, "4521"))
+		assert.NoError(t, errS)
+	}))
+	require.NoError(t, err)
+
+	s := udp.NewServer(options.WithMux(m))
+	defer s.Stop()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := udp.Dial(l.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*8)
+	defer cancel()
+	resp, err := cc.Post(ctx, corerd.DefaultRegistrationPath, message.AppLinkFormat,
+		bytes.NewReader([]byte(`</sensors/temp>;rt="temperature-c";if="sensor";ct=41`)),
+		message.QueryParams{{Key: "ep", Value: "node1"}, {Key: "lt", Value: "600"}}.Options()...)
+	require.NoError(t, err)
+	require.Equal(t, codes.Created, resp.Code())
+	location, err := resp.Options().LocationPath()
+	require.NoError(t, err)
+	require.Equal(t, "/rd/4521", location)
+
+	reg := <-registrations
+	require.Equal(t, "node1", reg.Endpoint)
+	require.Equal(t, time.Minute*10, reg.Lifetime)
+	require.Len(t, reg.Links, 1)
+	require.Equal(t, "/sensors/temp", reg.Links[0].Target)
+
+	resp, err = cc.Post(ctx, corerd.DefaultRegistrationPath, message.AppJSON, bytes.NewReader([]byte(`{}`)),
+		message.QueryParams{{Key: "ep", Value: "node1"}}.Options()...)
+	require.NoError(t, err)
+	require.Equal(t, codes.BadRequest, resp.Code())
+}
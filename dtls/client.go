@@ -1,6 +1,7 @@
 package dtls
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -33,6 +34,10 @@ var DefaultConfig = func() udpClient.Config {
 }()
 
 // Dial creates a client connection to the given target.
+//
+// 0-RTT early data is not supported: the underlying pion/dtls implements DTLS 1.2 only, so the first request
+// is always sent after the handshake. To save the round trip of the full handshake for reconnections,
+// set dtlsCfg.SessionStore to resume the previous session by the abbreviated handshake.
 func Dial(target string, dtlsCfg *dtls.Config, opts ...udp.Option) (*udpClient.Conn, error) {
 	cfg := DefaultConfig
 	for _, o := range opts {
@@ -48,6 +53,15 @@ func Dial(target string, dtlsCfg *dtls.Config, opts ...udp.Option) (*udpClient.C
 	if err != nil {
 		return nil, err
 	}
+	if cfg.HandshakeTimeout > 0 {
+		ctx, cancel := context.WithTimeout(cfg.Ctx, cfg.HandshakeTimeout)
+		err = conn.HandshakeContext(ctx)
+		cancel()
+		if err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("dtls handshake: %w", err)
+		}
+	}
 	opts = append(opts, options.WithCloseSocket())
 	return Client(conn, opts...), nil
 }
@@ -93,6 +107,11 @@ func Client(conn *dtls.Conn, opts ...udp.Option) *udpClient.Conn {
 				func(token message.Token) (*pool.Message, bool) {
 					return v.GetObservationRequest(token)
 				},
+				blockwise.WithBufferAllocator(cfg.BlockwiseBufferAllocator),
+				blockwise.WithMaxRequestBodySize(cfg.BlockwiseMaxRequestBodySize),
+				blockwise.WithMaxConcurrentTransfers(cfg.BlockwiseMaxConcurrentTransfers),
+				blockwise.WithPerBlockTimeout(cfg.BlockwisePerBlockTimeout),
+				blockwise.WithMetrics(cfg.Metrics),
 			)
 		}
 	}
@@ -105,6 +124,10 @@ func Client(conn *dtls.Conn, opts ...udp.Option) *udpClient.Conn {
 		cfg.MTU,
 		cfg.CloseSocket,
 	)
+	session.SetWireTap(cfg.WireTap)
+	session.SetMetrics(cfg.Metrics)
+	session.SetTokenLength(cfg.TokenLength)
+	session.SetSendQueue(cfg.NewSendQueue())
 	cc := udpClient.NewConnWithOpts(session,
 		&cfg,
 		udpClient.WithBlockWise(createBlockWise),
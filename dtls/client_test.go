@@ -110,7 +110,7 @@ func TestConnGet(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		errS := s.Serve(l)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := dtls.Dial(l.Addr().String(), dtlsCfg)
@@ -203,7 +203,7 @@ func TestConnGetSeparateMessage(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		errS := s.Serve(l)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := dtls.Dial(l.Addr().String(), dtlsCfg, options.WithHandlerFunc(func(_ *responsewriter.ResponseWriter[*client.Conn], r *pool.Message) {
@@ -227,6 +227,136 @@ func TestConnGetSeparateMessage(t *testing.T) {
 	assert.Equal(t, codes.Content, resp.Code())
 }
 
+func TestConnConnectionState(t *testing.T) {
+	dtlsCfg := &piondtls.Config{
+		PSK: func([]byte) ([]byte, error) {
+			return []byte{0xAB, 0xC1, 0x23}, nil
+		},
+		PSKIdentityHint: []byte("Pion DTLS Server"),
+		CipherSuites:    []piondtls.CipherSuiteID{piondtls.TLS_PSK_WITH_AES_128_CCM_8},
+	}
+	l, err := coapNet.NewDTLSListener("udp", "", dtlsCfg)
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	var serverConn atomic.Pointer[client.Conn]
+	s := dtls.NewServer(options.WithOnNewConn(func(cc *client.Conn) {
+		serverConn.Store(cc)
+	}))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := dtls.Dial(l.Addr().String(), dtlsCfg)
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	err = cc.Ping(ctx)
+	require.NoError(t, err)
+
+	state := dtls.ConnectionState(cc)
+	require.Equal(t, cc.RemoteAddr(), state.RemoteAddr)
+	require.NotNil(t, state.DTLS)
+	require.Equal(t, piondtls.TLS_PSK_WITH_AES_128_CCM_8, state.DTLS.CipherSuiteID)
+	require.Equal(t, dtlsCfg.PSKIdentityHint, state.DTLS.IdentityHint)
+
+	// the server finishes the handshake before it receives the ping
+	require.NotNil(t, serverConn.Load())
+	srvState := dtls.ConnectionState(serverConn.Load())
+	require.NotNil(t, srvState.DTLS)
+	require.Equal(t, piondtls.TLS_PSK_WITH_AES_128_CCM_8, srvState.DTLS.CipherSuiteID)
+}
+
+func TestConnPSKIdentity(t *testing.T) {
+	serverCfg := &piondtls.Config{
+		PSK: func([]byte) ([]byte, error) {
+			return []byte{0xAB, 0xC1, 0x23}, nil
+		},
+		PSKIdentityHint: []byte("Pion DTLS Server"),
+		CipherSuites:    []piondtls.CipherSuiteID{piondtls.TLS_PSK_WITH_AES_128_CCM_8},
+	}
+	clientCfg := &piondtls.Config{
+		PSK: func([]byte) ([]byte, error) {
+			return []byte{0xAB, 0xC1, 0x23}, nil
+		},
+		PSKIdentityHint: []byte("device-1"),
+		CipherSuites:    []piondtls.CipherSuiteID{piondtls.TLS_PSK_WITH_AES_128_CCM_8},
+	}
+	l, err := coapNet.NewDTLSListener("udp", "", serverCfg)
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	m := mux.NewRouter()
+	err = m.Handle("/identity", mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		identity, ok := dtls.PSKIdentity(r.Context())
+		assert.True(t, ok)
+		errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader(identity))
+		require.NoError(t, errS)
+	}))
+	require.NoError(t, err)
+
+	var serverConn atomic.Pointer[client.Conn]
+	s := dtls.NewServer(options.WithMux(m), options.WithOnNewConn(func(cc *client.Conn) {
+		serverConn.Store(cc)
+	}))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := dtls.Dial(l.Addr().String(), clientCfg)
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	resp, err := cc.Get(ctx, "/identity")
+	require.NoError(t, err)
+	require.Equal(t, codes.Content, resp.Code())
+	body, err := resp.ReadBody()
+	require.NoError(t, err)
+	require.Equal(t, clientCfg.PSKIdentityHint, body)
+
+	require.Equal(t, serverCfg.PSKIdentityHint, dtls.ConnectionState(cc).PSKIdentity)
+	identity, ok := dtls.PSKIdentity(cc.Context())
+	require.True(t, ok)
+	require.Equal(t, serverCfg.PSKIdentityHint, identity)
+	require.NotNil(t, serverConn.Load())
+	require.Equal(t, clientCfg.PSKIdentityHint, dtls.ConnectionState(serverConn.Load()).PSKIdentity)
+
+	_, ok = dtls.PSKIdentity(context.Background())
+	require.False(t, ok)
+}
+
 func TestConnPost(t *testing.T) {
 	type args struct {
 		path          string
@@ -329,7 +459,7 @@ func TestConnPost(t *testing.T) {
 			go func() {
 				defer wg.Done()
 				errS := s.Serve(l)
-				assert.NoError(t, errS)
+				assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 			}()
 
 			cc, err := dtls.Dial(l.Addr().String(), dtlsCfg)
@@ -464,7 +594,7 @@ func TestConnPut(t *testing.T) {
 			go func() {
 				defer wg.Done()
 				errS := s.Serve(l)
-				assert.NoError(t, errS)
+				assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 			}()
 
 			cc, err := dtls.Dial(l.Addr().String(), dtlsCfg)
@@ -576,7 +706,7 @@ func TestConnDelete(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		errS := s.Serve(l)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := dtls.Dial(l.Addr().String(), dtlsCfg)
@@ -636,7 +766,7 @@ func TestConnPing(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		errS := s.Serve(l)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := dtls.Dial(l.Addr().String(), dtlsCfg)
@@ -653,6 +783,28 @@ func TestConnPing(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestDialHandshakeTimeout(t *testing.T) {
+	dtlsCfg := &piondtls.Config{
+		PSK: func([]byte) ([]byte, error) {
+			return []byte{0xAB, 0xC1, 0x23}, nil
+		},
+		PSKIdentityHint: []byte("Pion DTLS Server"),
+		CipherSuites:    []piondtls.CipherSuiteID{piondtls.TLS_PSK_WITH_AES_128_CCM_8},
+	}
+	// peer which never answers the handshake
+	l, err := coapNet.NewListenUDP("udp", "127.0.0.1:")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+
+	start := time.Now()
+	_, err = dtls.Dial(l.LocalAddr().String(), dtlsCfg, options.WithHandshakeTimeout(time.Millisecond*300))
+	require.Error(t, err)
+	require.Less(t, time.Since(start), time.Second*3)
+}
+
 func TestClientInactiveMonitor(t *testing.T) {
 	var inactivityDetected atomic.Bool
 
@@ -691,7 +843,7 @@ func TestClientInactiveMonitor(t *testing.T) {
 	go func() {
 		defer serverWg.Done()
 		errS := sd.Serve(ld)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 	defer func() {
 		sd.Stop()
@@ -763,7 +915,7 @@ func TestClientKeepAliveMonitor(t *testing.T) {
 	go func() {
 		defer serverWg.Done()
 		errS := sd.Serve(ld)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 	defer func() {
 		sd.Stop()
@@ -0,0 +1,42 @@
+package dtls
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/pion/dtls/v3"
+	"github.com/pion/dtls/v3/pkg/crypto/elliptic"
+)
+
+// RestrictHandshake returns a copy of dtlsCfg which allows only the given cipher suites and elliptic curves
+// during the handshake. Empty cipherSuites or curves keep the value from dtlsCfg (nil means pion/dtls defaults).
+// The returned config can be passed to Dial or to net.NewDTLSListener for a server.
+//
+// The DTLS version cannot be restricted, because pion/dtls implements only DTLS 1.2 and both
+// sides of the connection always negotiate it.
+func RestrictHandshake(dtlsCfg *dtls.Config, cipherSuites []dtls.CipherSuiteID, curves []elliptic.Curve) (*dtls.Config, error) {
+	if dtlsCfg == nil {
+		return nil, errors.New("invalid dtls config")
+	}
+	for _, id := range cipherSuites {
+		// CipherSuiteName returns hex value of id for unsupported cipher suites
+		if strings.HasPrefix(dtls.CipherSuiteName(id), "0x") {
+			return nil, fmt.Errorf("unsupported cipher suite 0x%04X", uint16(id))
+		}
+	}
+	supportedCurves := elliptic.Curves()
+	for _, c := range curves {
+		if !supportedCurves[c] {
+			return nil, fmt.Errorf("unsupported elliptic curve %v", c)
+		}
+	}
+	cfg := *dtlsCfg
+	if len(cipherSuites) > 0 {
+		cfg.CipherSuites = append([]dtls.CipherSuiteID(nil), cipherSuites...)
+	}
+	if len(curves) > 0 {
+		cfg.EllipticCurves = append([]elliptic.Curve(nil), curves...)
+	}
+	return &cfg, nil
+}
@@ -0,0 +1,30 @@
+package dtls_test
+
+import (
+	"testing"
+
+	piondtls "github.com/pion/dtls/v3"
+	"github.com/pion/dtls/v3/pkg/crypto/elliptic"
+	"github.com/plgd-dev/go-coap/v3/dtls"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestrictHandshake(t *testing.T) {
+	base := &piondtls.Config{
+		PSKIdentityHint: []byte("hint"),
+	}
+	cfg, err := dtls.RestrictHandshake(base, []piondtls.CipherSuiteID{piondtls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}, []elliptic.Curve{elliptic.P256})
+	require.NoError(t, err)
+	require.Equal(t, []piondtls.CipherSuiteID{piondtls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}, cfg.CipherSuites)
+	require.Equal(t, []elliptic.Curve{elliptic.P256}, cfg.EllipticCurves)
+	require.Equal(t, base.PSKIdentityHint, cfg.PSKIdentityHint)
+	require.Empty(t, base.CipherSuites)
+	require.Empty(t, base.EllipticCurves)
+
+	_, err = dtls.RestrictHandshake(base, []piondtls.CipherSuiteID{0xffff}, nil)
+	require.Error(t, err)
+	_, err = dtls.RestrictHandshake(base, nil, []elliptic.Curve{0xffff})
+	require.Error(t, err)
+	_, err = dtls.RestrictHandshake(nil, nil, nil)
+	require.Error(t, err)
+}
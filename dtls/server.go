@@ -1,7 +1,24 @@
 package dtls
 
-import "github.com/plgd-dev/go-coap/v3/dtls/server"
+import (
+	"context"
+
+	"github.com/plgd-dev/go-coap/v3/dtls/server"
+	"github.com/plgd-dev/go-coap/v3/udp/client"
+)
 
 func NewServer(opt ...server.Option) *server.Server {
 	return server.New(opt...)
 }
+
+// PSKIdentity returns the PSK identity presented by the peer of the connection of the request context,
+// e.g. PSKIdentity(r.Context()) in the handler. See server.PSKIdentity.
+func PSKIdentity(ctx context.Context) ([]byte, bool) {
+	return server.PSKIdentity(ctx)
+}
+
+// ConnectionState returns the parameters of the DTLS connection, including the state negotiated by the handshake.
+// See server.GetConnectionState.
+func ConnectionState(cc *client.Conn) server.ConnectionState {
+	return server.GetConnectionState(cc)
+}
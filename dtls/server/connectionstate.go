@@ -0,0 +1,36 @@
+package server
+
+import (
+	"github.com/pion/dtls/v3"
+	"github.com/plgd-dev/go-coap/v3/udp/client"
+)
+
+// ConnectionState contains the parameters of the DTLS connection, e.g. for audit logs.
+type ConnectionState struct {
+	client.ConnectionState
+	// DTLS contains the state negotiated by the handshake (cipher suite, peer certificates, PSK identity hint, ...).
+	// It is nil when the handshake has not been finished yet.
+	DTLS *dtls.State
+	// PSKIdentity is the PSK identity presented by the peer: the identity of the client on the server
+	// and the identity hint of the server on the client. It is nil when the PSK cipher suite was not negotiated.
+	PSKIdentity []byte
+}
+
+// GetConnectionState returns the parameters of the connection served or dialed over DTLS.
+// For the connection which is not over DTLS the DTLS state is nil.
+func GetConnectionState(cc *client.Conn) ConnectionState {
+	state := ConnectionState{
+		ConnectionState: cc.ConnectionState(),
+	}
+	c, ok := cc.NetConn().(*dtls.Conn)
+	if !ok {
+		return state
+	}
+	if dtlsState, ok := c.ConnectionState(); ok {
+		state.DTLS = &dtlsState
+		if len(dtlsState.IdentityHint) > 0 {
+			state.PSKIdentity = dtlsState.IdentityHint
+		}
+	}
+	return state
+}
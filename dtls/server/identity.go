@@ -0,0 +1,30 @@
+package server
+
+import (
+	"context"
+
+	"github.com/pion/dtls/v3"
+	coapNet "github.com/plgd-dev/go-coap/v3/net"
+)
+
+type pskIdentityKey struct{}
+
+// PSKIdentity returns the PSK identity presented by the peer in the DTLS handshake, e.g. to authorize the request
+// by the device key in the handler via PSKIdentity(r.Context()). On the server it is the identity of the client,
+// on the client it is the identity hint of the server. It returns false when the ctx doesn't belong to the DTLS connection,
+// the handshake has not been finished yet or the PSK cipher suite was not negotiated.
+func PSKIdentity(ctx context.Context) ([]byte, bool) {
+	connection, ok := ctx.Value(pskIdentityKey{}).(*coapNet.Conn)
+	if !ok {
+		return nil, false
+	}
+	c, ok := connection.NetConn().(*dtls.Conn)
+	if !ok {
+		return nil, false
+	}
+	state, ok := c.ConnectionState()
+	if !ok || len(state.IdentityHint) == 0 {
+		return nil, false
+	}
+	return state.IdentityHint, true
+}
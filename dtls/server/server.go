@@ -8,13 +8,16 @@ import (
 	"sync"
 	"time"
 
+	"github.com/pion/dtls/v3"
 	"github.com/plgd-dev/go-coap/v3/message"
 	"github.com/plgd-dev/go-coap/v3/message/pool"
 	coapNet "github.com/plgd-dev/go-coap/v3/net"
 	"github.com/plgd-dev/go-coap/v3/net/blockwise"
 	"github.com/plgd-dev/go-coap/v3/net/monitor/inactivity"
 	"github.com/plgd-dev/go-coap/v3/pkg/connections"
+	"github.com/plgd-dev/go-coap/v3/pkg/runner/scheduler"
 	udpClient "github.com/plgd-dev/go-coap/v3/udp/client"
+	"go.uber.org/atomic"
 )
 
 // Listener defined used by coap
@@ -25,11 +28,17 @@ type Listener interface {
 
 type Server struct {
 	ctx    context.Context
-	cancel context.CancelFunc
+	cancel context.CancelCauseFunc
 	cfg    *Config
 
 	listenMutex sync.Mutex
 	listen      Listener
+
+	numConnections atomic.Uint32
+	connections    *connections.Connections
+
+	notificationSchedulerOnce sync.Once
+	notificationScheduler     *scheduler.Scheduler
 }
 
 // A Option sets options such as credentials, codec and keepalive parameters, etc.
@@ -43,7 +52,7 @@ func New(opt ...Option) *Server {
 		o.DTLSServerApply(&cfg)
 	}
 
-	ctx, cancel := context.WithCancel(cfg.Ctx)
+	ctx, cancel := context.WithCancelCause(cfg.Ctx)
 	if cfg.Errors == nil {
 		cfg.Errors = func(error) {
 			// default no-op
@@ -79,9 +88,10 @@ func New(opt ...Option) *Server {
 	}
 
 	return &Server{
-		ctx:    ctx,
-		cancel: cancel,
-		cfg:    &cfg,
+		ctx:         ctx,
+		cancel:      cancel,
+		cfg:         &cfg,
+		connections: connections.New(),
 	}
 }
 
@@ -103,28 +113,54 @@ func (s *Server) popListener() Listener {
 	return l
 }
 
-func (s *Server) checkAcceptError(err error) bool {
+// checkAcceptError returns false with the error returned by Serve when the accepting of connections must be stopped.
+func (s *Server) checkAcceptError(err error) (bool, error) {
 	if err == nil {
-		return true
+		return true, nil
 	}
 	switch {
 	case errors.Is(err, coapNet.ErrListenerIsClosed):
+		if s.ctx.Err() != nil {
+			return false, coapNet.ErrServerClosed
+		}
 		s.Stop()
-		return false
+		return false, err
 	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
 		select {
 		case <-s.ctx.Done():
 		default:
 			s.cfg.Errors(fmt.Errorf("cannot accept connection: %w", err))
-			return true
+			return true, nil
 		}
-		return false
+		return false, coapNet.ErrServerClosed
 	default:
-		return true
+		return true, nil
 	}
 }
 
+// handshake performs the DTLS handshake of the accepted connection within cfg.HandshakeTimeout. Without the timeout
+// the handshake is performed by the first read of the connection.
+func (s *Server) handshake(rw net.Conn) error {
+	c, ok := rw.(*dtls.Conn)
+	if !ok || s.cfg.HandshakeTimeout <= 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(s.ctx, s.cfg.HandshakeTimeout)
+	defer cancel()
+	if err := c.HandshakeContext(ctx); err != nil {
+		return fmt.Errorf("dtls handshake: %w", err)
+	}
+	return nil
+}
+
 func (s *Server) serveConnection(connections *connections.Connections, rw net.Conn) {
+	if err := s.handshake(rw); err != nil {
+		s.cfg.Errors(fmt.Errorf("%v: %w", rw.RemoteAddr(), err))
+		if errC := rw.Close(); errC != nil {
+			s.cfg.Errors(fmt.Errorf("cannot close connection: %w", errC))
+		}
+		return
+	}
 	inactivityMonitor := s.cfg.CreateInactivityMonitor()
 	requestMonitor := s.cfg.RequestMonitor
 	dtlsConn := coapNet.NewConn(rw)
@@ -132,6 +168,11 @@ func (s *Server) serveConnection(connections *connections.Connections, rw net.Co
 	if s.cfg.OnNewConn != nil {
 		s.cfg.OnNewConn(cc)
 	}
+	if s.cfg.OnCloseConn != nil {
+		cc.AddOnClose(func() {
+			s.cfg.OnCloseConn(cc, cc.CloseReason())
+		})
+	}
 	connections.Store(cc)
 	defer connections.Delete(cc)
 
@@ -140,6 +181,9 @@ func (s *Server) serveConnection(connections *connections.Connections, rw net.Co
 	}
 }
 
+// Serve accepts the connections on the listener l until Stop is called. Serve always returns a non-nil error:
+// coapNet.ErrServerClosed after Stop or the cancellation of the context of the server, otherwise the error
+// which stopped the serving, e.g. when the listener was closed without Stop.
 func (s *Server) Serve(l Listener) error {
 	if s.cfg.BlockwiseSZX > blockwise.SZX1024 {
 		return errors.New("invalid blockwiseSZX")
@@ -155,7 +199,7 @@ func (s *Server) Serve(l Listener) error {
 	var wg sync.WaitGroup
 	defer wg.Wait()
 
-	connections := connections.New()
+	connections := s.connections
 	s.cfg.PeriodicRunner(func(now time.Time) bool {
 		connections.CheckExpirations(now)
 		return s.ctx.Err() == nil
@@ -164,23 +208,89 @@ func (s *Server) Serve(l Listener) error {
 
 	for {
 		rw, err := l.AcceptWithContext(s.ctx)
-		if ok := s.checkAcceptError(err); !ok {
-			return nil
+		if ok, errA := s.checkAcceptError(err); !ok {
+			return errA
 		}
 		if err != nil || rw == nil {
 			continue
 		}
+		if !s.acquireConnection() {
+			s.refuseConnection(rw, coapNet.ErrMaxConnectionsExceeded)
+			continue
+		}
 		wg.Add(1)
-		go func() {
+		started := s.cfg.Goroutines.TryGo(func() {
 			defer wg.Done()
+			defer s.releaseConnection()
 			s.serveConnection(connections, rw)
+		})
+		if !started {
+			wg.Done()
+			s.releaseConnection()
+			s.refuseConnection(rw, coapNet.ErrMaxGoroutinesExceeded)
+		}
+	}
+}
+
+// MigrateObservers moves the observers of all connections to the server at the altAddr, see client.Conn.MigrateObservers.
+// The connections with the observations are closed, the errors of the connections are joined.
+func (s *Server) MigrateObservers(ctx context.Context, altAddr string) error {
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	var errs []error
+	for _, c := range s.connections.List() {
+		cc, ok := c.(*udpClient.Conn)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := cc.MigrateObservers(ctx, altAddr); err != nil {
+				mutex.Lock()
+				errs = append(errs, fmt.Errorf("%v: %w", cc.RemoteAddr(), err))
+				mutex.Unlock()
+			}
 		}()
 	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// acquireConnection reserves the slot for the new connection when the number of connections is limited.
+func (s *Server) acquireConnection() bool {
+	if s.cfg.MaxConnections == 0 {
+		return true
+	}
+	if s.numConnections.Inc() > s.cfg.MaxConnections {
+		s.numConnections.Dec()
+		return false
+	}
+	return true
+}
+
+func (s *Server) releaseConnection() {
+	if s.cfg.MaxConnections > 0 {
+		s.numConnections.Dec()
+	}
+}
+
+func (s *Server) refuseConnection(rw net.Conn, reason error) {
+	s.cfg.Errors(fmt.Errorf("%v: %w", rw.RemoteAddr(), reason))
+	if err := rw.Close(); err != nil {
+		s.cfg.Errors(fmt.Errorf("cannot close refused connection: %w", err))
+	}
+}
+
+// Goroutines returns the number of the goroutines of the connections counted by options.WithMaxGoroutines,
+// 0 when the option is not set.
+func (s *Server) Goroutines() int64 {
+	return s.cfg.Goroutines.Running()
 }
 
 // Stop stops server without wait of ends Serve function.
 func (s *Server) Stop() {
-	s.cancel()
+	s.cancel(coapNet.ErrServerShutdown)
 	l := s.popListener()
 	if l == nil {
 		return
@@ -190,6 +300,19 @@ func (s *Server) Stop() {
 	}
 }
 
+// NotificationScheduler returns the scheduler of the timed notifications served by the goroutines
+// set by options.WithNotificationWorkers. It is created by the first call and closed when the server is stopped.
+func (s *Server) NotificationScheduler() *scheduler.Scheduler {
+	s.notificationSchedulerOnce.Do(func() {
+		s.notificationScheduler = scheduler.New(s.cfg.NotificationWorkers)
+		go func() {
+			<-s.ctx.Done()
+			s.notificationScheduler.Close()
+		}()
+	})
+	return s.notificationScheduler
+}
+
 func (s *Server) createConn(connection *coapNet.Conn, inactivityMonitor udpClient.InactivityMonitor, requestMonitor udpClient.RequestMonitorFunc) *udpClient.Conn {
 	createBlockWise := func(*udpClient.Conn) *blockwise.BlockWise[*udpClient.Conn] {
 		return nil
@@ -204,6 +327,11 @@ func (s *Server) createConn(connection *coapNet.Conn, inactivityMonitor udpClien
 				func(token message.Token) (*pool.Message, bool) {
 					return v.GetObservationRequest(token)
 				},
+				blockwise.WithBufferAllocator(s.cfg.BlockwiseBufferAllocator),
+				blockwise.WithMaxRequestBodySize(s.cfg.BlockwiseMaxRequestBodySize),
+				blockwise.WithMaxConcurrentTransfers(s.cfg.BlockwiseMaxConcurrentTransfers),
+				blockwise.WithPerBlockTimeout(s.cfg.BlockwisePerBlockTimeout),
+				blockwise.WithMetrics(s.cfg.Metrics),
 			)
 		}
 	}
@@ -214,10 +342,20 @@ func (s *Server) createConn(connection *coapNet.Conn, inactivityMonitor udpClien
 		s.cfg.MTU,
 		true,
 	)
+	session.SetWireTap(s.cfg.WireTap)
+	session.SetMetrics(s.cfg.Metrics)
+	session.SetTokenLength(s.cfg.TokenLength)
+	session.SetSendQueue(s.cfg.NewSendQueue())
 	cfg := udpClient.DefaultConfig
 	cfg.TransmissionNStart = s.cfg.TransmissionNStart
 	cfg.TransmissionAcknowledgeTimeout = s.cfg.TransmissionAcknowledgeTimeout
 	cfg.TransmissionMaxRetransmit = s.cfg.TransmissionMaxRetransmit
+	cfg.TransmissionAckRandomFactor = s.cfg.TransmissionAckRandomFactor
+	cfg.TransmissionExchangeLifetime = s.cfg.TransmissionExchangeLifetime
+	cfg.ConfirmableResponseToNonConfirmable = s.cfg.ConfirmableResponseToNonConfirmable
+	cfg.SeparateResponseThreshold = s.cfg.SeparateResponseThreshold
+	cfg.UnexpectedMessageHandler = s.cfg.UnexpectedMessageHandler
+	cfg.DeduplicateNonConfirmable = s.cfg.DeduplicateNonConfirmable
 	cfg.Handler = s.cfg.Handler
 	cfg.BlockwiseSZX = s.cfg.BlockwiseSZX
 	cfg.Errors = s.cfg.Errors
@@ -225,7 +363,17 @@ func (s *Server) createConn(connection *coapNet.Conn, inactivityMonitor udpClien
 	cfg.GetToken = s.cfg.GetToken
 	cfg.MessagePool = s.cfg.MessagePool
 	cfg.ReceivedMessageQueueSize = s.cfg.ReceivedMessageQueueSize
+	cfg.OnParseError = s.cfg.OnParseError
+	cfg.SerializedHandlers = s.cfg.SerializedHandlers
+	cfg.MaxObservations = s.cfg.MaxObservations
+	cfg.TrackObservations = s.cfg.TrackObservations
+	cfg.StrictParsing = s.cfg.StrictParsing
+	cfg.Metrics = s.cfg.Metrics
+	cfg.Goroutines = s.cfg.Goroutines
+	cfg.TokenLength = s.cfg.TokenLength
+	cfg.OnTransportError = s.cfg.OnTransportError
 	cfg.ProcessReceivedMessage = s.cfg.ProcessReceivedMessage
+	cfg.HandshakeTimeout = s.cfg.HandshakeTimeout
 
 	cc := udpClient.NewConnWithOpts(
 		session,
@@ -8,8 +8,11 @@ import (
 	"sync"
 	"sync/atomic"
 
+	"github.com/plgd-dev/go-coap/v3/message"
 	"github.com/plgd-dev/go-coap/v3/message/pool"
 	coapNet "github.com/plgd-dev/go-coap/v3/net"
+	"github.com/plgd-dev/go-coap/v3/net/metrics"
+	"github.com/plgd-dev/go-coap/v3/options/config"
 	"github.com/plgd-dev/go-coap/v3/udp/client"
 	"github.com/plgd-dev/go-coap/v3/udp/coder"
 )
@@ -21,7 +24,7 @@ type Session struct {
 
 	ctx atomic.Pointer[context.Context]
 
-	cancel     context.CancelFunc
+	cancel     context.CancelCauseFunc
 	connection *coapNet.Conn
 
 	done chan struct{}
@@ -33,6 +36,12 @@ type Session struct {
 	mtu uint16
 
 	closeSocket bool
+
+	wireTap     config.WireTapFunc
+	sendQueue   *coapNet.SendQueue
+	metrics     metrics.Collector
+	tokenLength message.TokenLength
+	encoder     *coder.Coder
 }
 
 func NewSession(
@@ -42,7 +51,9 @@ func NewSession(
 	mtu uint16,
 	closeSocket bool,
 ) *Session {
-	ctx, cancel := context.WithCancel(ctx)
+	ctx, cancel := context.WithCancelCause(ctx)
+	// the identity is read from the connection, because the handshake can be finished after the session is created
+	ctx = context.WithValue(ctx, pskIdentityKey{}, connection)
 	s := &Session{
 		cancel:         cancel,
 		connection:     connection,
@@ -50,6 +61,9 @@ func NewSession(
 		closeSocket:    closeSocket,
 		mtu:            mtu,
 		done:           make(chan struct{}),
+		tokenLength:    message.DefaultTokenLength,
+		encoder:        coder.DefaultCoder,
+		metrics:        metrics.NilCollector{},
 	}
 	s.ctx.Store(&ctx)
 	return s
@@ -82,7 +96,7 @@ func (s *Session) shutdown() {
 }
 
 func (s *Session) Close() error {
-	s.cancel()
+	s.cancel(nil)
 	if s.closeSocket {
 		return s.connection.Close()
 	}
@@ -99,16 +113,60 @@ func (s *Session) SetContextValue(key interface{}, val interface{}) {
 	s.ctx.Store(&ctx)
 }
 
+// SetWireTap sets the function which is called with every decrypted datagram sent or received by the session.
+func (s *Session) SetWireTap(wireTap config.WireTapFunc) {
+	s.wireTap = wireTap
+}
+
+// SetMetrics sets the collector of the messages sent by the session, nil disables the collection.
+func (s *Session) SetMetrics(collector metrics.Collector) {
+	s.metrics = metrics.OrNil(collector)
+}
+
+// SetTokenLength sets the lengths of the tokens of the messages written by the session, the other messages are rejected.
+func (s *Session) SetTokenLength(tokenLength message.TokenLength) {
+	s.tokenLength = tokenLength
+	s.encoder = coder.DefaultCoder.WithMaxTokenSize(tokenLength.Max)
+}
+
+// SetSendQueue bounds the messages written by the session at once, nil removes the bound.
+func (s *Session) SetSendQueue(sendQueue *coapNet.SendQueue) {
+	s.sendQueue = sendQueue
+}
+
+// Flush blocks until the messages queued by the send queue are written to the connection or the ctx is done.
+// Without the send queue the messages are written synchronously, so there is nothing to wait for.
+func (s *Session) Flush(ctx context.Context) error {
+	if s.sendQueue == nil {
+		return nil
+	}
+	return s.sendQueue.Flush(ctx)
+}
+
 func (s *Session) WriteMessage(req *pool.Message) error {
-	data, err := req.MarshalWithEncoder(coder.DefaultCoder)
+	if err := s.tokenLength.Validate(req.Code(), req.Token()); err != nil {
+		return err
+	}
+	data, err := req.MarshalWithEncoder(s.encoder)
 	if err != nil {
 		return fmt.Errorf("cannot marshal: %w", err)
 	}
-	err = s.connection.WriteWithContext(req.Context(), data)
-	if err != nil {
-		return fmt.Errorf("cannot write to connection: %w", err)
+	// the code is read before the write, the request can be released as soon as the response arrives
+	code := req.Code()
+	write := func() error {
+		if s.wireTap != nil {
+			s.wireTap(config.DirectionSent, data, s.RemoteAddr())
+		}
+		if errW := s.connection.WriteWithContext(req.Context(), data); errW != nil {
+			return fmt.Errorf("cannot write to connection: %w", errW)
+		}
+		s.metrics.MessageSent(code)
+		return nil
 	}
-	return err
+	if s.sendQueue == nil {
+		return write()
+	}
+	return s.sendQueue.Write(req.Context(), write)
 }
 
 // WriteMulticastMessage sends multicast to the remote multicast address.
@@ -132,6 +190,10 @@ func (s *Session) LocalAddr() net.Addr {
 // Run reads and processes requests from a connection, until the connection is closed.
 func (s *Session) Run(cc *client.Conn) (err error) {
 	defer func() {
+		if err != nil {
+			// the error is the cause of the closed connection
+			s.cancel(err)
+		}
 		err1 := s.Close()
 		if err == nil {
 			err = err1
@@ -146,8 +208,11 @@ func (s *Session) Run(cc *client.Conn) (err error) {
 			return fmt.Errorf("cannot read from connection: %w", err)
 		}
 		readBuf = readBuf[:readLen]
+		if s.wireTap != nil {
+			s.wireTap(config.DirectionReceived, readBuf, s.RemoteAddr())
+		}
 		err = cc.Process(nil, readBuf)
-		if err != nil {
+		if err != nil && !errors.Is(err, coapNet.ErrMalformedDatagram) {
 			return err
 		}
 	}
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	piondtls "github.com/pion/dtls/v3"
+	dtlsnet "github.com/pion/dtls/v3/pkg/net"
 	"github.com/plgd-dev/go-coap/v3/dtls"
 	"github.com/plgd-dev/go-coap/v3/examples/dtls/pki"
 	"github.com/plgd-dev/go-coap/v3/message"
@@ -68,7 +69,7 @@ func TestServerCleanUpConns(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		errS := sd.Serve(ld)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := dtls.Dial(ld.Addr().String(), dtlsCfg)
@@ -182,7 +183,7 @@ func TestServerSetContextValueWithPKI(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		errS := sd.Serve(ld)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := dtls.Dial(ld.Addr().String(), clientCgf)
@@ -243,7 +244,7 @@ func TestServerInactiveMonitor(t *testing.T) {
 	go func() {
 		defer serverWg.Done()
 		errS := sd.Serve(ld)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := dtls.Dial(ld.Addr().String(), clientCgf)
@@ -315,7 +316,7 @@ func TestServerKeepAliveMonitor(t *testing.T) {
 	go func() {
 		defer serverWg.Done()
 		errS := sd.Serve(ld)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := piondtls.Dial("udp4", &net.UDPAddr{IP: []byte{127, 0, 0, 1}, Port: ld.Addr().(*net.UDPAddr).Port}, clientCgf)
@@ -337,3 +338,80 @@ func TestServerKeepAliveMonitor(t *testing.T) {
 	require.NoError(t, err)
 	require.True(t, inactivityDetected.Load())
 }
+
+// firstWritePacketConn drops all writes after the first one, e.g. to send only the first ClientHello.
+type firstWritePacketConn struct {
+	net.PacketConn
+	writes atomic.Int32
+}
+
+func (c *firstWritePacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	if c.writes.Inc() > 1 {
+		return len(p), nil
+	}
+	return c.PacketConn.WriteTo(p, addr)
+}
+
+func TestServerHandshakeTimeout(t *testing.T) {
+	dtlsCfg := &piondtls.Config{
+		PSK: func([]byte) ([]byte, error) {
+			return []byte{0xAB, 0xC1, 0x23}, nil
+		},
+		PSKIdentityHint: []byte("Pion DTLS Server"),
+		CipherSuites:    []piondtls.CipherSuiteID{piondtls.TLS_PSK_WITH_AES_128_CCM_8},
+	}
+	ld, err := coapNet.NewDTLSListener("udp4", "", dtlsCfg)
+	require.NoError(t, err)
+	defer func() {
+		errC := ld.Close()
+		require.NoError(t, errC)
+	}()
+
+	handshakeErr := make(chan error, 1)
+	var newConn atomic.Bool
+	sd := dtls.NewServer(
+		options.WithHandshakeTimeout(time.Millisecond*300),
+		options.WithOnNewConn(func(*client.Conn) {
+			newConn.Store(true)
+		}),
+		options.WithErrors(func(err error) {
+			select {
+			case handshakeErr <- err:
+			default:
+			}
+		}),
+	)
+	var wg sync.WaitGroup
+	defer func() {
+		sd.Stop()
+		wg.Wait()
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := sd.Serve(ld)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	// the peer which sends the ClientHello and never continues the handshake
+	c, err := net.Dial("udp4", ld.Addr().String())
+	require.NoError(t, err)
+	conn, err := piondtls.Client(&firstWritePacketConn{PacketConn: dtlsnet.PacketConnFromConn(c)}, c.RemoteAddr(), dtlsCfg)
+	require.NoError(t, err)
+	defer func() {
+		_ = conn.Close()
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	go func() {
+		_ = conn.HandshakeContext(ctx)
+	}()
+
+	select {
+	case err = <-handshakeErr:
+		require.Contains(t, err.Error(), "dtls handshake")
+	case <-time.After(time.Second * 3):
+		require.Fail(t, "the handshake was not timed out")
+	}
+	require.False(t, newConn.Load())
+}
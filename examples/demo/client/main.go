@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net"
@@ -39,16 +40,17 @@ func main() {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		s.Serve(l)
+		if errS := s.Serve(l); !errors.Is(errS, coapnet.ErrServerClosed) {
+			log.Printf("server failed: %v", errS)
+		}
 	}()
 
 	// 创建发现请求上下文
 	ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
 	defer cancel()
 
-	// 用于存储发现的第一个设备地址
-	var firstDevice string
-	var deviceFound = make(chan bool)
+	// 用于存储发现的第一个设备的连接
+	deviceFound := make(chan *client.Conn, 1)
 
 	// 创建发现请求
 	token, err := message.GetToken()
@@ -68,8 +70,12 @@ func main() {
 
 	// 发送发现请求
 	log.Println("Discovering devices...")
-	err = s.DiscoveryRequest(req, "224.0.1.187:5683", func(cc *client.Conn, resp *pool.Message) {
+	err = s.DiscoveryRequestWithErrors(req, "224.0.1.187:5683", func(cc *client.Conn, _ *pool.Message, err error) {
 		addr := cc.RemoteAddr().String()
+		if err != nil {
+			log.Printf("Invalid response from device %v: %v", addr, err)
+			return
+		}
 		host, _, err := net.SplitHostPort(addr)
 		if err != nil {
 			log.Printf("Error parsing address %v: %v", addr, err)
@@ -83,9 +89,9 @@ func main() {
 		}
 
 		log.Printf("Discovered device at: %v\n", addr)
-		if firstDevice == "" {
-			firstDevice = addr
-			deviceFound <- true
+		select {
+		case deviceFound <- cc:
+		default:
 		}
 	})
 	if err != nil {
@@ -93,33 +99,19 @@ func main() {
 	}
 
 	// 等待发现至少一个设备
+	var co *client.Conn
 	select {
-	case <-deviceFound:
-		log.Printf("Selected device: %v\n", firstDevice)
-	case <-time.After(discoveryTimeout):
+	case co = <-deviceFound:
+		log.Printf("Selected device: %v\n", co.RemoteAddr())
+	default:
 		log.Fatal("Timeout: No non-local devices found")
 	}
+	firstDevice := co.RemoteAddr().String()
 
-	// 修改端口为5688用于observer连接
-	host, _, err := net.SplitHostPort(firstDevice)
-	if err != nil {
-		log.Fatal("Error parsing address:", err)
-	}
-	observerAddr := fmt.Sprintf("%s:5688", host)
-
-	// 连接到选定的设备
+	// 通过发现时设备响应的地址和端口建立观察，不需要假设设备的其他监听端口
 	ctx, cancel = context.WithTimeout(context.Background(), connectionTimeout)
 	defer cancel()
 
-	co, err := udp.Dial(observerAddr,
-		options.WithNetwork("udp4"),
-		options.WithTransmission(1, time.Second*5, 2),
-	)
-	if err != nil {
-		log.Fatal("Error dialing:", err)
-	}
-	defer co.Close()
-
 	// 设置观察处理函数
 	log.Println("Starting observation...")
 	fmt.Println("Press Ctrl+C to exit") // 提前打印退出提示
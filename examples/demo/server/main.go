@@ -7,7 +7,6 @@ import (
 	gonet "net"
 	"time"
 
-	coap "github.com/plgd-dev/go-coap/v3"
 	"github.com/plgd-dev/go-coap/v3/message"
 	"github.com/plgd-dev/go-coap/v3/message/codes"
 	"github.com/plgd-dev/go-coap/v3/mux"
@@ -64,9 +63,8 @@ func handleMcast(w mux.ResponseWriter, r *mux.Message) {
 
 func handleObserve(w mux.ResponseWriter, r *mux.Message) {
 	log.Printf("Got message path=%v: %+v from %v", getPath(r.Options()), r, w.Conn().RemoteAddr())
-	obs, err := r.Options().Observe()
 	switch {
-	case r.Code() == codes.GET && err == nil && obs == 0:
+	case r.Code() == codes.GET && r.ObserveAction() == message.ObserveRegister:
 		go periodicTransmitter(w.Conn(), r.Token())
 	case r.Code() == codes.GET:
 		err := sendResponse(w.Conn(), r.Token(), time.Now(), -1)
@@ -87,14 +85,8 @@ func main() {
 	// 设置多播地址和端口
 	multicastAddr := "224.0.1.187:5683"
 
-	// 创建UDP监听器
-	l, err := net.NewListenUDP("udp4", multicastAddr)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	// 获取网络接口
-	ifaces, err := gonet.Interfaces()
+	// 创建UDP监听器，发现和观察共用同一个端口
+	l, err := net.NewListenUDP("udp4", ":5683")
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -106,12 +98,8 @@ func main() {
 	}
 
 	// 加入多播组
-	for i := range ifaces {
-		iface := ifaces[i]
-		err := l.JoinGroup(&iface, a)
-		if err != nil {
-			log.Printf("cannot JoinGroup(%v, %v): %v", iface, a, err)
-		}
+	if _, err := l.JoinGroupAllInterfaces(a); err != nil {
+		log.Printf("cannot JoinGroup(%v): %v", a, err)
 	}
 
 	// 设置多播回环
@@ -126,13 +114,7 @@ func main() {
 	s := udp.NewServer(options.WithMux(m))
 	defer s.Stop()
 
-	// 启动普通的CoAP服务器（用于Observer）
-	go func() {
-		log.Printf("Starting CoAP server on :5688")
-		log.Fatal(coap.ListenAndServe("udp4", ":5688", m))
-	}()
-
-	// 启动多播服务器
-	log.Printf("Starting multicast server on %v", multicastAddr)
+	// 启动服务器，同一个socket处理多播发现和单播观察请求
+	log.Printf("Starting CoAP server on :5683 joined to %v", multicastAddr)
 	log.Fatal(s.Serve(l))
 }
@@ -35,24 +35,14 @@ func main() {
 		return
 	}
 
-	ifaces, err := gonet.Interfaces()
-	if err != nil {
-		log.Println(err)
-		return
-	}
-
 	a, err := gonet.ResolveUDPAddr("udp", multicastAddr)
 	if err != nil {
 		log.Println(err)
 		return
 	}
 
-	for i := range ifaces {
-		iface := ifaces[i]
-		err := l.JoinGroup(&iface, a)
-		if err != nil {
-			log.Printf("cannot JoinGroup(%v, %v): %v", iface, a, err)
-		}
+	if _, err := l.JoinGroupAllInterfaces(a); err != nil {
+		log.Printf("cannot JoinGroup(%v): %v", a, err)
 	}
 	err = l.SetMulticastLoopback(true)
 	if err != nil {
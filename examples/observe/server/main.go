@@ -10,6 +10,7 @@ import (
 	"github.com/plgd-dev/go-coap/v3/message"
 	"github.com/plgd-dev/go-coap/v3/message/codes"
 	"github.com/plgd-dev/go-coap/v3/mux"
+	"github.com/plgd-dev/go-coap/v3/pkg/runner/scheduler"
 )
 
 func getPath(opts message.Options) string {
@@ -21,44 +22,36 @@ func getPath(opts message.Options) string {
 	return path
 }
 
-func sendResponse(cc mux.Conn, token []byte, subded time.Time, obs int64) error {
-	m := cc.AcquireMessage(cc.Context())
-	defer cc.ReleaseMessage(m)
-	m.SetCode(codes.Content)
-	m.SetToken(token)
-	m.SetBody(bytes.NewReader([]byte(fmt.Sprintf("Been running for %v", time.Since(subded)))))
-	m.SetContentFormat(message.TextPlain)
-	if obs >= 0 {
-		m.SetObserve(uint32(obs))
-	}
-	return cc.WriteMessage(m)
-}
-
-func periodicTransmitter(cc mux.Conn, token []byte) {
+func scheduleTransmitter(s *scheduler.Scheduler, cc mux.Conn, n *mux.Notifier) {
 	subded := time.Now()
-
-	for obs := int64(2); ; obs++ {
-		err := sendResponse(cc, token, subded, obs)
+	s.Schedule(cc.Context(), time.Second, func(time.Time) bool {
+		err := n.Notify(codes.Content, message.TextPlain, bytes.NewReader([]byte(fmt.Sprintf("Been running for %v", time.Since(subded)))))
 		if err != nil {
 			log.Printf("Error on transmitter, stopping: %v", err)
-			return
+			return false
 		}
-		time.Sleep(time.Second)
-	}
+		return true
+	})
 }
 
 func main() {
+	// all observations are served by a bounded pool of goroutines
+	s := scheduler.New(scheduler.DefaultWorkers)
 	log.Fatal(coap.ListenAndServe("udp", ":5688",
 		mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
 			log.Printf("Got message path=%v: %+v from %v", getPath(r.Options()), r, w.Conn().RemoteAddr())
-			obs, err := r.Options().Observe()
 			switch {
-			case r.Code() == codes.GET && err == nil && obs == 0:
-				go periodicTransmitter(w.Conn(), r.Token())
+			case r.Code() == codes.GET && r.ObserveAction() == message.ObserveRegister:
+				n := mux.NewNotifier(w, r, mux.WithNotifyRetry(2, 100*time.Millisecond))
+				if errS := n.SetResponse(w, codes.Content, message.TextPlain, bytes.NewReader([]byte("Been running for 0s"))); errS != nil {
+					log.Printf("Error on transmitter: %v", errS)
+					return
+				}
+				scheduleTransmitter(s, w.Conn(), n)
 			case r.Code() == codes.GET:
-				err := sendResponse(w.Conn(), r.Token(), time.Now(), -1)
-				if err != nil {
-					log.Printf("Error on transmitter: %v", err)
+				errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("Been running for 0s")))
+				if errS != nil {
+					log.Printf("Error on transmitter: %v", errS)
 				}
 			}
 		})))
@@ -0,0 +1,52 @@
+package message
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// BodyDecoderFunc decodes the payload into v, e.g. json.Unmarshal. The data must not be retained.
+type BodyDecoderFunc = func(data []byte, v any) error
+
+var bodyDecoders = map[MediaType]BodyDecoderFunc{
+	TextPlain:        decodeRawBody,
+	AppOctets:        decodeRawBody,
+	AppJSON:          json.Unmarshal,
+	AppSenmlJSON:     json.Unmarshal,
+	AppSensmlJSON:    json.Unmarshal,
+	AppSenmlEtchJSON: json.Unmarshal,
+}
+
+// decodeRawBody copies the payload to *[]byte or *string.
+func decodeRawBody(data []byte, v any) error {
+	switch d := v.(type) {
+	case *[]byte:
+		*d = append((*d)[:0], data...)
+	case *string:
+		*d = string(data)
+	default:
+		return fmt.Errorf("cannot decode payload to %T", v)
+	}
+	return nil
+}
+
+// RegisterBodyDecoder sets the decoder of the payload of the content format used by DecodeBody, e.g. for CBOR:
+//
+//	message.RegisterBodyDecoder(message.AppCBOR, cbor.Unmarshal)
+//	message.RegisterBodyDecoder(message.AppSenmlCbor, cbor.Unmarshal)
+//
+// The registration must be done before the decoding, e.g. from init, because the decoders are not guarded.
+func RegisterBodyDecoder(contentFormat MediaType, decoder BodyDecoderFunc) {
+	bodyDecoders[contentFormat] = decoder
+}
+
+// DecodeBody decodes the payload of the content format into v by the registered decoder. The text/plain
+// and application/octet-stream payloads are decoded to *[]byte or *string, the application/json payload and the JSON
+// variants of SenML (application/senml+json, application/sensml+json, application/senml-etch+json) by json.Unmarshal.
+func DecodeBody(contentFormat MediaType, data []byte, v any) error {
+	decoder, ok := bodyDecoders[contentFormat]
+	if !ok {
+		return fmt.Errorf("%w: %v", ErrUnsupportedContentFormat, contentFormat)
+	}
+	return decoder(data, v)
+}
@@ -14,4 +14,8 @@ var (
 	ErrInvalidEncoding              = errors.New("invalid encoding")
 	ErrOptionNotFound               = errors.New("option not found")
 	ErrOptionDuplicate              = errors.New("duplicated option")
+	ErrContentFormatWithoutPayload  = errors.New("content format without payload")
+	ErrOptionDefined                = errors.New("option is already defined")
+	ErrUnsupportedContentFormat     = errors.New("unsupported content format")
+	ErrOptionsNotCanonical          = errors.New("options are not in canonical form")
 )
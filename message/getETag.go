@@ -7,6 +7,9 @@ import (
 	"io"
 )
 
+// GetETagFunc calculates ETag from payload. The result must be 1-8 bytes long.
+type GetETagFunc = func(r io.ReadSeeker) ([]byte, error)
+
 // GetETag calculates ETag from payload via CRC64
 func GetETag(r io.ReadSeeker) ([]byte, error) {
 	if r == nil {
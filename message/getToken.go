@@ -3,7 +3,10 @@ package message
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"fmt"
 	"hash/crc64"
+
+	"github.com/plgd-dev/go-coap/v3/message/codes"
 )
 
 type Token []byte
@@ -27,3 +30,27 @@ func GetToken() (Token, error) {
 
 	return b, nil
 }
+
+// TokenLength is the range of the lengths of the tokens of the sent and the received requests and responses.
+type TokenLength struct {
+	// Min is the min length of the token, e.g. to reject the requests with short tokens which are easy to guess.
+	Min int
+	// Max is the max length of the token, up to MaxEncodableTokenSize.
+	Max int
+}
+
+// DefaultTokenLength allows the token lengths of RFC 7252 (0-8 bytes).
+var DefaultTokenLength = TokenLength{Min: 0, Max: MaxTokenSize}
+
+// Validate returns ErrInvalidTokenLen when the length of the token of the message with the code is out of the range.
+// The min length is not required from the empty messages and the signals, which don't need the token.
+func (l TokenLength) Validate(code codes.Code, token Token) error {
+	minLen := l.Min
+	if code == codes.Empty || code >= codes.CSM {
+		minLen = 0
+	}
+	if len(token) < minLen || len(token) > l.Max {
+		return fmt.Errorf("%w(%v): expected %v-%v bytes", ErrInvalidTokenLen, len(token), minLen, l.Max)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package message
 import (
 	"testing"
 
+	"github.com/plgd-dev/go-coap/v3/message/codes"
 	"github.com/stretchr/testify/require"
 )
 
@@ -12,3 +13,31 @@ func TestGetToken(t *testing.T) {
 	require.NotEmpty(t, token)
 	require.NotEqual(t, 0, token.Hash())
 }
+
+func TestTokenLengthValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		tokenLength TokenLength
+		code        codes.Code
+		token       Token
+		wantErr     bool
+	}{
+		{name: "default", tokenLength: DefaultTokenLength, code: codes.GET, token: make(Token, 8)},
+		{name: "default-empty", tokenLength: DefaultTokenLength, code: codes.GET},
+		{name: "default-long", tokenLength: DefaultTokenLength, code: codes.GET, token: make(Token, 9), wantErr: true},
+		{name: "long", tokenLength: TokenLength{Max: MaxEncodableTokenSize}, code: codes.Content, token: make(Token, 15)},
+		{name: "short", tokenLength: TokenLength{Min: 4, Max: MaxTokenSize}, code: codes.GET, token: make(Token, 2), wantErr: true},
+		{name: "short-empty-message", tokenLength: TokenLength{Min: 4, Max: MaxTokenSize}, code: codes.Empty},
+		{name: "short-signal", tokenLength: TokenLength{Min: 4, Max: MaxTokenSize}, code: codes.Pong},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.tokenLength.Validate(tt.code, tt.token)
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidTokenLen)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
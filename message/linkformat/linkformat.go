@@ -0,0 +1,242 @@
+// Package linkformat implements parsing and encoding of the CoRE Link Format (RFC 6690).
+package linkformat
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrInvalidLinkFormat = errors.New("invalid link format")
+
+// Param is a link parameter, e.g. rt="temperature". The value of a quoted-string is stored without quotes.
+type Param struct {
+	Key   string
+	Value string
+	// HasValue is false for parameters without '=', e.g. obs.
+	HasValue bool
+}
+
+func (p Param) String() string {
+	if !p.HasValue {
+		return p.Key
+	}
+	// numbers (ct, sz) are encoded as cardinal, the other values (rt, if, anchor, ...) as quoted-string
+	if isCardinal(p.Value) {
+		return p.Key + "=" + p.Value
+	}
+	return p.Key + `="` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(p.Value) + `"`
+}
+
+// Link is a link-value of the link format: target URI with parameters.
+type Link struct {
+	Target string
+	Params []Param
+}
+
+// Get returns the value of the first parameter with the key.
+func (l Link) Get(key string) (string, bool) {
+	for _, p := range l.Params {
+		if p.Key == key {
+			return p.Value, true
+		}
+	}
+	return "", false
+}
+
+// Values returns the space separated values of all parameters with the key, e.g. rt="a b" rt=c returns [a b c].
+func (l Link) Values(key string) []string {
+	var values []string
+	for _, p := range l.Params {
+		if p.Key == key {
+			values = append(values, strings.Fields(p.Value)...)
+		}
+	}
+	return values
+}
+
+// Has returns true when the link contains the parameter.
+func (l Link) Has(key string) bool {
+	_, ok := l.Get(key)
+	return ok
+}
+
+func (l Link) String() string {
+	var b strings.Builder
+	b.WriteString("<" + l.Target + ">")
+	for _, p := range l.Params {
+		b.WriteString(";" + p.String())
+	}
+	return b.String()
+}
+
+// Links is a list of links, the payload of application/link-format.
+type Links []Link
+
+func (links Links) String() string {
+	s := make([]string, 0, len(links))
+	for _, l := range links {
+		s = append(s, l.String())
+	}
+	return strings.Join(s, ",")
+}
+
+// MarshalText encodes links to the link format.
+func (links Links) MarshalText() ([]byte, error) {
+	return []byte(links.String()), nil
+}
+
+// UnmarshalText parses links from the link format.
+func (links *Links) UnmarshalText(data []byte) error {
+	l, err := Parse(data)
+	if err != nil {
+		return err
+	}
+	*links = l
+	return nil
+}
+
+func isCardinal(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+type parser struct {
+	data []byte
+	pos  int
+}
+
+func (p *parser) skipSpaces() {
+	for p.pos < len(p.data) && isSpace(p.data[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *parser) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("%w: %v at offset %v", ErrInvalidLinkFormat, fmt.Sprintf(format, a...), p.pos)
+}
+
+func (p *parser) parseTarget() (string, error) {
+	if p.pos >= len(p.data) || p.data[p.pos] != '<' {
+		return "", p.errorf("expected '<'")
+	}
+	end := bytes.IndexByte(p.data[p.pos:], '>')
+	if end < 0 {
+		return "", p.errorf("missing '>'")
+	}
+	target := string(p.data[p.pos+1 : p.pos+end])
+	p.pos += end + 1
+	return target, nil
+}
+
+func (p *parser) parseQuoted() (string, error) {
+	// skip opening quote
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		switch c {
+		case '\\':
+			if p.pos+1 >= len(p.data) {
+				return "", p.errorf("unterminated escape")
+			}
+			b.WriteByte(p.data[p.pos+1])
+			p.pos += 2
+			continue
+		case '"':
+			p.pos++
+			return b.String(), nil
+		}
+		b.WriteByte(c)
+		p.pos++
+	}
+	return "", p.errorf("unterminated quoted-string")
+}
+
+func (p *parser) parseParam() (Param, error) {
+	start := p.pos
+	for p.pos < len(p.data) && p.data[p.pos] != '=' && p.data[p.pos] != ';' && p.data[p.pos] != ',' && !isSpace(p.data[p.pos]) {
+		p.pos++
+	}
+	key := string(p.data[start:p.pos])
+	if key == "" {
+		return Param{}, p.errorf("empty parameter name")
+	}
+	p.skipSpaces()
+	if p.pos >= len(p.data) || p.data[p.pos] != '=' {
+		return Param{Key: key}, nil
+	}
+	p.pos++
+	p.skipSpaces()
+	if p.pos < len(p.data) && p.data[p.pos] == '"' {
+		value, err := p.parseQuoted()
+		if err != nil {
+			return Param{}, err
+		}
+		return Param{Key: key, Value: value, HasValue: true}, nil
+	}
+	start = p.pos
+	for p.pos < len(p.data) && p.data[p.pos] != ';' && p.data[p.pos] != ',' && !isSpace(p.data[p.pos]) {
+		p.pos++
+	}
+	return Param{Key: key, Value: string(p.data[start:p.pos]), HasValue: true}, nil
+}
+
+func (p *parser) parseLink() (Link, error) {
+	target, err := p.parseTarget()
+	if err != nil {
+		return Link{}, err
+	}
+	link := Link{Target: target}
+	for {
+		p.skipSpaces()
+		if p.pos >= len(p.data) || p.data[p.pos] != ';' {
+			return link, nil
+		}
+		p.pos++
+		p.skipSpaces()
+		param, err := p.parseParam()
+		if err != nil {
+			return Link{}, err
+		}
+		link.Params = append(link.Params, param)
+	}
+}
+
+// Parse parses the payload of application/link-format.
+func Parse(data []byte) (Links, error) {
+	p := parser{data: data}
+	var links Links
+	p.skipSpaces()
+	if p.pos >= len(p.data) {
+		return links, nil
+	}
+	for {
+		p.skipSpaces()
+		link, err := p.parseLink()
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+		p.skipSpaces()
+		if p.pos >= len(p.data) {
+			return links, nil
+		}
+		if p.data[p.pos] != ',' {
+			return nil, p.errorf("expected ','")
+		}
+		p.pos++
+	}
+}
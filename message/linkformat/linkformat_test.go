@@ -0,0 +1,51 @@
+package linkformat_test
+
+import (
+	"testing"
+
+	"github.com/plgd-dev/go-coap/v3/message/linkformat"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	links, err := linkformat.Parse([]byte(`</sensors/temp>;rt="temperature-c";if="sensor";ct=0;obs,
+  </sensors/light>;rt="light-lux core.s";title="a \"b\", c;d"`))
+	require.NoError(t, err)
+	require.Equal(t, linkformat.Links{
+		{
+			Target: "/sensors/temp",
+			Params: []linkformat.Param{
+				{Key: "rt", Value: "temperature-c", HasValue: true},
+				{Key: "if", Value: "sensor", HasValue: true},
+				{Key: "ct", Value: "0", HasValue: true},
+				{Key: "obs"},
+			},
+		},
+		{
+			Target: "/sensors/light",
+			Params: []linkformat.Param{
+				{Key: "rt", Value: "light-lux core.s", HasValue: true},
+				{Key: "title", Value: `a "b", c;d`, HasValue: true},
+			},
+		},
+	}, links)
+	require.True(t, links[0].Has("obs"))
+	require.Equal(t, []string{"light-lux", "core.s"}, links[1].Values("rt"))
+	ct, ok := links[0].Get("ct")
+	require.True(t, ok)
+	require.Equal(t, "0", ct)
+
+	parsed, err := linkformat.Parse([]byte(links.String()))
+	require.NoError(t, err)
+	require.Equal(t, links, parsed)
+	require.Equal(t, `</sensors/temp>;rt="temperature-c";if="sensor";ct=0;obs`, links[0].String())
+
+	links, err = linkformat.Parse(nil)
+	require.NoError(t, err)
+	require.Empty(t, links)
+
+	for _, invalid := range []string{"/a", "</a", "</a>;", `</a>;rt="x`, "</a> </b>"} {
+		_, err = linkformat.Parse([]byte(invalid))
+		require.ErrorIs(t, err, linkformat.ErrInvalidLinkFormat, invalid)
+	}
+}
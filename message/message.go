@@ -9,6 +9,10 @@ import (
 // MaxTokenSize maximum of token size that can be used in message
 const MaxTokenSize = 8
 
+// MaxEncodableTokenSize is the max token size which fits into the 4 bits of the token length in the header. The tokens
+// longer than MaxTokenSize violate RFC 7252, they are sent and received only when they are allowed by TokenLength.
+const MaxEncodableTokenSize = 15
+
 type Message struct {
 	Token   Token
 	Options Options
@@ -18,6 +22,9 @@ type Message struct {
 	// For DTLS and UDP messages
 	MessageID int32 // uint16 is valid, all other values are invalid, -1 is used for unset
 	Type      Type  // uint8 is valid, all other values are invalid, -1 is used for unset
+	// Version overrides the version of the DTLS and UDP messages, nil means the version 1 defined by RFC 7252.
+	// Other versions are not understood by the peers, so it is intended only for the conformance testing.
+	Version *uint8
 }
 
 func (r *Message) String() string {
@@ -0,0 +1,38 @@
+package message
+
+import "strconv"
+
+// ObserveAction is the meaning of the Observe option of the message (RFC 7641 section 2).
+type ObserveAction int
+
+const (
+	// ObserveNone is the message without the Observe option or the request with the value other than 0 and 1.
+	ObserveNone ObserveAction = iota
+	// ObserveRegister is the request with Observe 0 which registers the observation.
+	ObserveRegister
+	// ObserveDeregister is the request with Observe 1 which cancels the observation.
+	ObserveDeregister
+	// ObserveNotification is the response with the Observe option, the value is the sequence number of the notification.
+	ObserveNotification
+)
+
+// The values of the Observe option in the request (RFC 7641 section 2).
+const (
+	ObserveRegisterValue   uint32 = 0
+	ObserveDeregisterValue uint32 = 1
+)
+
+var observeActionToString = map[ObserveAction]string{
+	ObserveNone:         "None",
+	ObserveRegister:     "Register",
+	ObserveDeregister:   "Deregister",
+	ObserveNotification: "Notification",
+}
+
+func (a ObserveAction) String() string {
+	val, ok := observeActionToString[a]
+	if ok {
+		return val
+	}
+	return "ObserveAction(" + strconv.FormatInt(int64(a), 10) + ")"
+}
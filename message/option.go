@@ -3,6 +3,7 @@ package message
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"strconv"
 
 	"github.com/plgd-dev/go-coap/v3/pkg/math"
@@ -76,6 +77,8 @@ const (
 	ProxyScheme   OptionID = 39
 	Size1         OptionID = 60
 	NoResponse    OptionID = 258
+	// RequestTag distinguishes the concurrent blockwise operations of the client (RFC 9175 section 3).
+	RequestTag OptionID = 292
 )
 
 var optionIDToString = map[OptionID]string{
@@ -99,6 +102,7 @@ var optionIDToString = map[OptionID]string{
 	ProxyScheme:   "ProxyScheme",
 	Size1:         "Size1",
 	NoResponse:    "NoResponse",
+	RequestTag:    "RequestTag",
 }
 
 func (o OptionID) String() string {
@@ -118,6 +122,37 @@ func ToOptionID(v string) (OptionID, error) {
 	return 0, errors.New("not found")
 }
 
+// IsCritical returns true if the option is critical. https://datatracker.ietf.org/doc/html/rfc7252#section-5.4.6
+func (o OptionID) IsCritical() bool {
+	return o&0x01 != 0
+}
+
+// IsUnsafe returns true if the option is unsafe to forward by a proxy which doesn't understand it. https://datatracker.ietf.org/doc/html/rfc7252#section-5.4.6
+func (o OptionID) IsUnsafe() bool {
+	return o&0x02 != 0
+}
+
+// IsNoCacheKey returns true if the option is not a part of the cache key. https://datatracker.ietf.org/doc/html/rfc7252#section-5.4.6
+func (o OptionID) IsNoCacheKey() bool {
+	return o&0x1e == 0x1c
+}
+
+// KeepOnProxyForward returns false for options which are consumed by a forward-proxy and must not be forwarded to the next hop.
+//
+// Proxy-Uri and Proxy-Scheme are hop-by-hop: the proxy uses them to resolve the request target and it must replace them
+// by Uri-Host, Uri-Port, Uri-Path and Uri-Query options of the forwarded request. All other options are end-to-end
+// and they are kept. https://datatracker.ietf.org/doc/html/rfc7252#section-5.7.2
+//
+// Unsafe options (see OptionID.IsUnsafe) which are not understood by the proxy must not be forwarded either,
+// on such a request the proxy should respond with 5.02 (Bad Gateway).
+func KeepOnProxyForward(id OptionID) bool {
+	switch id {
+	case ProxyURI, ProxyScheme:
+		return false
+	}
+	return true
+}
+
 // Option value format (RFC7252 section 3.2)
 type ValueFormat uint8
 
@@ -133,22 +168,24 @@ type OptionDef struct {
 	MinLen      uint32
 	MaxLen      uint32
 	ValueFormat ValueFormat
+	// Repeatable allows multiple occurrences of the option in the message.
+	Repeatable bool
 }
 
 var CoapOptionDefs = map[OptionID]OptionDef{
-	IfMatch:       {ValueFormat: ValueOpaque, MinLen: 0, MaxLen: 8},
+	IfMatch:       {ValueFormat: ValueOpaque, MinLen: 0, MaxLen: 8, Repeatable: true},
 	URIHost:       {ValueFormat: ValueString, MinLen: 1, MaxLen: 255},
-	ETag:          {ValueFormat: ValueOpaque, MinLen: 1, MaxLen: 8},
+	ETag:          {ValueFormat: ValueOpaque, MinLen: 1, MaxLen: 8, Repeatable: true},
 	IfNoneMatch:   {ValueFormat: ValueEmpty, MinLen: 0, MaxLen: 0},
 	Observe:       {ValueFormat: ValueUint, MinLen: 0, MaxLen: 3},
 	URIPort:       {ValueFormat: ValueUint, MinLen: 0, MaxLen: 2},
-	LocationPath:  {ValueFormat: ValueString, MinLen: 0, MaxLen: 255},
-	URIPath:       {ValueFormat: ValueString, MinLen: 0, MaxLen: 255},
+	LocationPath:  {ValueFormat: ValueString, MinLen: 0, MaxLen: 255, Repeatable: true},
+	URIPath:       {ValueFormat: ValueString, MinLen: 0, MaxLen: 255, Repeatable: true},
 	ContentFormat: {ValueFormat: ValueUint, MinLen: 0, MaxLen: 2},
 	MaxAge:        {ValueFormat: ValueUint, MinLen: 0, MaxLen: 4},
-	URIQuery:      {ValueFormat: ValueString, MinLen: 0, MaxLen: 255},
+	URIQuery:      {ValueFormat: ValueString, MinLen: 0, MaxLen: 255, Repeatable: true},
 	Accept:        {ValueFormat: ValueUint, MinLen: 0, MaxLen: 2},
-	LocationQuery: {ValueFormat: ValueString, MinLen: 0, MaxLen: 255},
+	LocationQuery: {ValueFormat: ValueString, MinLen: 0, MaxLen: 255, Repeatable: true},
 	Block2:        {ValueFormat: ValueUint, MinLen: 0, MaxLen: 3},
 	Block1:        {ValueFormat: ValueUint, MinLen: 0, MaxLen: 3},
 	Size2:         {ValueFormat: ValueUint, MinLen: 0, MaxLen: 4},
@@ -156,6 +193,23 @@ var CoapOptionDefs = map[OptionID]OptionDef{
 	ProxyScheme:   {ValueFormat: ValueString, MinLen: 1, MaxLen: 255},
 	Size1:         {ValueFormat: ValueUint, MinLen: 0, MaxLen: 4},
 	NoResponse:    {ValueFormat: ValueUint, MinLen: 0, MaxLen: 1},
+	RequestTag:    {ValueFormat: ValueOpaque, MinLen: 0, MaxLen: 8, Repeatable: true},
+}
+
+// RegisterOption registers the definition of the custom option, e.g. the proprietary option from the experimental range
+// (65000-65535). The registered options are decoded according to the definition and they are validated when
+// the message is encoded or decoded by the strict coders (udp/coder.StrictCoder, tcp/coder.StrictCoder).
+// The standard options can't be redefined. It must be called before the messages are encoded or decoded,
+// e.g. from init, because CoapOptionDefs is not guarded.
+func RegisterOption(id OptionID, def OptionDef) error {
+	if _, ok := CoapOptionDefs[id]; ok {
+		return fmt.Errorf("%w: %v", ErrOptionDefined, id)
+	}
+	if def.MinLen > def.MaxLen {
+		return fmt.Errorf("invalid definition of option(%v): min length %v is greater than max length %v", id, def.MinLen, def.MaxLen)
+	}
+	CoapOptionDefs[id] = def
+	return nil
 }
 
 // MediaType specifies the content format of a message.
@@ -176,14 +230,21 @@ var (
 	AppJSONMergePatch MediaType = 52    // application/merge-patch+json (RFC7396)
 	AppCBOR           MediaType = 60    // application/cbor (RFC 7049)
 	AppCWT            MediaType = 61    // application/cwt
+	AppCBORSeq        MediaType = 63    // application/cbor-seq (RFC 8742)
 	AppCoseEncrypt    MediaType = 96    // application/cose; cose-type="cose-encrypt" (RFC 8152)
 	AppCoseMac        MediaType = 97    // application/cose; cose-type="cose-mac" (RFC 8152)
 	AppCoseSign       MediaType = 98    // application/cose; cose-type="cose-sign" (RFC 8152)
 	AppCoseKey        MediaType = 101   // application/cose-key (RFC 8152)
 	AppCoseKeySet     MediaType = 102   // application/cose-key-set (RFC 8152)
-	AppSenmlJSON      MediaType = 110   // application/senml+json
-	AppSenmlCbor      MediaType = 112   // application/senml+cbor
+	AppSenmlJSON      MediaType = 110   // application/senml+json (RFC 8428)
+	AppSensmlJSON     MediaType = 111   // application/sensml+json (RFC 8428)
+	AppSenmlCbor      MediaType = 112   // application/senml+cbor (RFC 8428)
+	AppSensmlCbor     MediaType = 113   // application/sensml+cbor (RFC 8428)
+	AppSenmlExi       MediaType = 114   // application/senml-exi (RFC 8428)
+	AppSensmlExi      MediaType = 115   // application/sensml-exi (RFC 8428)
 	AppCoapGroup      MediaType = 256   // coap-group+json (RFC 7390)
+	AppSenmlXML       MediaType = 310   // application/senml+xml (RFC 8428)
+	AppSensmlXML      MediaType = 311   // application/sensml+xml (RFC 8428)
 	AppSenmlEtchJSON  MediaType = 320   // application/senml-etch+json
 	AppSenmlEtchCbor  MediaType = 322   // application/senml-etch+cbor
 	AppOcfCbor        MediaType = 10000 // application/vnd.ocf+cbor
@@ -206,14 +267,21 @@ var mediaTypeToString = map[MediaType]string{
 	AppJSONMergePatch: "application/merge-patch+json",
 	AppCBOR:           "application/cbor",
 	AppCWT:            "application/cwt",
+	AppCBORSeq:        "application/cbor-seq",
 	AppCoseEncrypt:    "application/cose; cose-type=\"cose-encrypt\"",
 	AppCoseMac:        "application/cose; cose-type=\"cose-mac\"",
 	AppCoseSign:       "application/cose; cose-type=\"cose-sign\"",
 	AppCoseKey:        "application/cose-key",
 	AppCoseKeySet:     "application/cose-key-set",
 	AppSenmlJSON:      "application/senml+json",
+	AppSensmlJSON:     "application/sensml+json",
 	AppSenmlCbor:      "application/senml+cbor",
+	AppSensmlCbor:     "application/sensml+cbor",
+	AppSenmlExi:       "application/senml-exi",
+	AppSensmlExi:      "application/sensml-exi",
 	AppCoapGroup:      "coap-group+json",
+	AppSenmlXML:       "application/senml+xml",
+	AppSensmlXML:      "application/sensml+xml",
 	AppSenmlEtchJSON:  "application/senml-etch+json",
 	AppSenmlEtchCbor:  "application/senml-etch+cbor",
 	AppOcfCbor:        "application/vnd.ocf+cbor",
@@ -16,6 +16,31 @@ func TestMediaTypeString(t *testing.T) {
 	}
 }
 
+func TestMediaTypeSenML(t *testing.T) {
+	for _, mt := range []MediaType{AppCBOR, AppCBORSeq, AppSenmlJSON, AppSensmlJSON, AppSenmlCbor, AppSensmlCbor, AppSenmlExi, AppSensmlExi, AppSenmlXML, AppSensmlXML} {
+		v, err := MediaTypeFromNumber(uint16(mt))
+		require.NoError(t, err)
+		require.Equal(t, mt, v)
+		v, err = ToMediaType(mt.String())
+		require.NoError(t, err)
+		require.Equal(t, mt, v)
+	}
+	require.Equal(t, MediaType(60), AppCBOR)
+	require.Equal(t, MediaType(110), AppSenmlJSON)
+	require.Equal(t, MediaType(112), AppSenmlCbor)
+
+	var records []struct {
+		Name  string  `json:"n"`
+		Value float64 `json:"v"`
+	}
+	err := DecodeBody(AppSenmlJSON, []byte(`[{"n":"urn:dev:ow:10e2073a01080063","v":23.1}]`), &records)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	require.Equal(t, 23.1, records[0].Value)
+	err = DecodeBody(AppSenmlCbor, []byte{0x80}, &records)
+	require.ErrorIs(t, err, ErrUnsupportedContentFormat)
+}
+
 func TestOptionIDString(t *testing.T) {
 	for i := 0; i < 12000; i++ {
 		func(oid int, s string) {
@@ -25,3 +50,14 @@ func TestOptionIDString(t *testing.T) {
 		}(i, OptionID(i).String())
 	}
 }
+
+func TestOptionIDClass(t *testing.T) {
+	require.True(t, URIPath.IsCritical())
+	require.True(t, URIPath.IsUnsafe())
+	require.False(t, URIPath.IsNoCacheKey())
+	require.False(t, ETag.IsCritical())
+	require.False(t, ETag.IsUnsafe())
+	require.True(t, Size1.IsNoCacheKey())
+	require.True(t, Size2.IsNoCacheKey())
+	require.False(t, ContentFormat.IsCritical())
+}
@@ -541,6 +541,44 @@ func (options Options) Marshal(buf []byte) (int, error) {
 	return length, nil
 }
 
+// Validate checks the length of the values and the occurrences of the options defined by optionDefs,
+// the other options are not validated. The options must be sorted by ID.
+func (options Options) Validate(optionDefs map[OptionID]OptionDef) error {
+	for i, o := range options {
+		def, ok := optionDefs[o.ID]
+		if !ok {
+			continue
+		}
+		if len(o.Value) < int(def.MinLen) || len(o.Value) > int(def.MaxLen) {
+			return fmt.Errorf("%w: option(%v) has length %v, expected %v-%v", ErrInvalidValueLength, o.ID, len(o.Value), def.MinLen, def.MaxLen)
+		}
+		if !def.Repeatable && i > 0 && options[i-1].ID == o.ID {
+			return fmt.Errorf("%w: option(%v) is not repeatable", ErrOptionDuplicate, o.ID)
+		}
+	}
+	return nil
+}
+
+// ValidateCanonical checks that the options are in the canonical form: sorted by ID and the uint values of the options
+// defined by optionDefs are encoded by the minimal number of bytes (RFC 7252 section 3.2).
+// The options unmarshaled from the wire are always sorted, because the option numbers are delta-encoded,
+// so the order is checked only for the options built by the application.
+func (options Options) ValidateCanonical(optionDefs map[OptionID]OptionDef) error {
+	for i, o := range options {
+		if i > 0 && options[i-1].ID > o.ID {
+			return fmt.Errorf("%w: option(%v) follows option(%v)", ErrOptionsNotCanonical, o.ID, options[i-1].ID)
+		}
+		def, ok := optionDefs[o.ID]
+		if !ok || def.ValueFormat != ValueUint {
+			continue
+		}
+		if len(o.Value) > 0 && o.Value[0] == 0 {
+			return fmt.Errorf("%w: option(%v) has uint value with leading zero", ErrOptionsNotCanonical, o.ID)
+		}
+	}
+	return nil
+}
+
 // Unmarshal unmarshals data bytes to options and returns the number of consumed bytes.
 func (options *Options) Unmarshal(data []byte, optionDefs map[OptionID]OptionDef) (int, error) {
 	prev := 0
@@ -627,6 +665,38 @@ func (options Options) ResetOptionsTo(buf []byte, in Options) (Options, int, err
 	return opts, used, nil
 }
 
+// Filter returns a copy of options which contains only options for which keep returns true.
+//
+// Values of the returned options are copied, so the result doesn't share any memory with options.
+// For forwarding a request by a proxy use KeepOnProxyForward as keep function.
+func (options Options) Filter(keep func(id OptionID) bool) Options {
+	kept := make(Options, 0, len(options))
+	size := 0
+	for _, o := range options {
+		if keep(o.ID) {
+			kept = append(kept, o)
+			size += len(o.Value)
+		}
+	}
+	buf := make([]byte, size)
+	for i, o := range kept {
+		n := copy(buf, o.Value)
+		kept[i].Value = buf[:n:n]
+		buf = buf[n:]
+	}
+	return kept
+}
+
+// Map returns the values of the options by the option ID, e.g. to log the whole option set. The values of the repeated
+// options are in the order of the options. The values are copied, so the map can be used after the message is released.
+func (options Options) Map() map[OptionID][][]byte {
+	m := make(map[OptionID][][]byte, len(options))
+	for _, o := range options {
+		m[o.ID] = append(m[o.ID], append(make([]byte, 0, len(o.Value)), o.Value...))
+	}
+	return m
+}
+
 // Clone create duplicates of options.
 func (options Options) Clone() (Options, error) {
 	opts := make(Options, 0, len(options))
@@ -0,0 +1,80 @@
+package message
+
+import (
+	"bytes"
+	"sort"
+)
+
+// OptionChange describes the option which is present in both option sets with different values.
+type OptionChange struct {
+	ID OptionID
+	// From are the values of the option in the first set, in the order of the options.
+	From [][]byte
+	// To are the values of the option in the second set, in the order of the options.
+	To [][]byte
+}
+
+// OptionsDiff is the difference between two option sets returned by DiffOptions.
+type OptionsDiff struct {
+	// Added are the options whose ID is present only in the second set.
+	Added Options
+	// Removed are the options whose ID is present only in the first set.
+	Removed Options
+	// Changed are the options present in both sets with different values, sorted by the option ID.
+	Changed []OptionChange
+}
+
+// IsEmpty returns true when the option sets are equal.
+func (d OptionsDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+func equalValues(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// DiffOptions compares the options a and b by the option ID, e.g. to verify that a proxy forwards the options.
+// The repeated options are compared with their order, so the option with the values reordered is reported as changed.
+// The values in the diff are copied, so the diff can be used after the messages are released.
+func DiffOptions(a, b Options) OptionsDiff {
+	ma := a.Map()
+	mb := b.Map()
+	ids := make([]OptionID, 0, len(ma)+len(mb))
+	for id := range ma {
+		ids = append(ids, id)
+	}
+	for id := range mb {
+		if _, ok := ma[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	var diff OptionsDiff
+	for _, id := range ids {
+		va, inA := ma[id]
+		vb, inB := mb[id]
+		switch {
+		case !inA:
+			for _, v := range vb {
+				diff.Added = append(diff.Added, Option{ID: id, Value: v})
+			}
+		case !inB:
+			for _, v := range va {
+				diff.Removed = append(diff.Removed, Option{ID: id, Value: v})
+			}
+		case !equalValues(va, vb):
+			diff.Changed = append(diff.Changed, OptionChange{ID: id, From: va, To: vb})
+		}
+	}
+	return diff
+}
@@ -357,3 +357,146 @@ func FuzzUnmarshalData(f *testing.F) {
 		_, _ = uoptions.Unmarshal(input_data, CoapOptionDefs)
 	})
 }
+
+func TestFilterOptions(t *testing.T) {
+	opts := Options{
+		{ID: URIHost, Value: []byte("example.com")},
+		{ID: URIPath, Value: []byte("a")},
+		{ID: ContentFormat, Value: []byte{50}},
+		{ID: ProxyURI, Value: []byte("coap://example.com/a")},
+		{ID: ProxyScheme, Value: []byte("coap")},
+	}
+	filtered := opts.Filter(KeepOnProxyForward)
+	require.Equal(t, opts[:3], filtered)
+	// the values are copied
+	filtered[1].Value[0] = 'b'
+	require.Equal(t, []byte("a"), opts[1].Value)
+
+	filtered = opts.Filter(func(OptionID) bool { return false })
+	require.Empty(t, filtered)
+}
+
+func TestOptionsValidate(t *testing.T) {
+	const customOption OptionID = 65001
+	err := RegisterOption(customOption, OptionDef{ValueFormat: ValueOpaque, MinLen: 2, MaxLen: 4})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		delete(CoapOptionDefs, customOption)
+	})
+	err = RegisterOption(customOption, OptionDef{ValueFormat: ValueOpaque, MaxLen: 8})
+	require.ErrorIs(t, err, ErrOptionDefined)
+	err = RegisterOption(ContentFormat, OptionDef{ValueFormat: ValueOpaque, MaxLen: 8})
+	require.ErrorIs(t, err, ErrOptionDefined)
+	err = RegisterOption(customOption+1, OptionDef{ValueFormat: ValueOpaque, MinLen: 2, MaxLen: 1})
+	require.Error(t, err)
+
+	tests := []struct {
+		name    string
+		options Options
+		wantErr error
+	}{
+		{
+			name:    "valid",
+			options: Options{{ID: URIPath, Value: []byte("a")}, {ID: URIPath, Value: []byte("b")}, {ID: customOption, Value: []byte{1, 2}}},
+		},
+		{
+			name:    "unknown option",
+			options: Options{{ID: customOption + 1, Value: make([]byte, 300)}},
+		},
+		{
+			name:    "too long custom option",
+			options: Options{{ID: customOption, Value: []byte{1, 2, 3, 4, 5}}},
+			wantErr: ErrInvalidValueLength,
+		},
+		{
+			name:    "too short custom option",
+			options: Options{{ID: customOption, Value: []byte{1}}},
+			wantErr: ErrInvalidValueLength,
+		},
+		{
+			name:    "empty etag",
+			options: Options{{ID: ETag, Value: []byte{}}},
+			wantErr: ErrInvalidValueLength,
+		},
+		{
+			name:    "repeated content format",
+			options: Options{{ID: ContentFormat, Value: []byte{0}}, {ID: ContentFormat, Value: []byte{50}}},
+			wantErr: ErrOptionDuplicate,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.options.Validate(CoapOptionDefs)
+			if tt.wantErr == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestOptionsMap(t *testing.T) {
+	value := []byte("a")
+	options := Options{
+		{ID: URIPath, Value: value},
+		{ID: URIPath, Value: []byte("b")},
+		{ID: ContentFormat, Value: []byte{}},
+	}
+	m := options.Map()
+	require.Equal(t, map[OptionID][][]byte{
+		URIPath:       {[]byte("a"), []byte("b")},
+		ContentFormat: {{}},
+	}, m)
+	// the values are copied
+	value[0] = 'c'
+	require.Equal(t, []byte("a"), m[URIPath][0])
+	require.Empty(t, Options{}.Map())
+}
+
+func TestDiffOptions(t *testing.T) {
+	a := Options{
+		{ID: URIPath, Value: []byte("a")},
+		{ID: URIPath, Value: []byte("b")},
+		{ID: ContentFormat, Value: []byte{0}},
+		{ID: Block2, Value: []byte{0x06}},
+	}
+	b := Options{
+		{ID: URIPath, Value: []byte("a")},
+		{ID: URIPath, Value: []byte("b")},
+		{ID: ContentFormat, Value: []byte{50}},
+		{ID: Size2, Value: []byte{0x10}},
+		{ID: URIQuery, Value: []byte("x=1")},
+	}
+	diff := DiffOptions(a, b)
+	require.False(t, diff.IsEmpty())
+	require.Equal(t, Options{
+		{ID: URIQuery, Value: []byte("x=1")},
+		{ID: Size2, Value: []byte{0x10}},
+	}, diff.Added)
+	require.Equal(t, Options{{ID: Block2, Value: []byte{0x06}}}, diff.Removed)
+	require.Equal(t, []OptionChange{{ID: ContentFormat, From: [][]byte{{0}}, To: [][]byte{{50}}}}, diff.Changed)
+
+	require.True(t, DiffOptions(a, a).IsEmpty())
+	require.True(t, DiffOptions(nil, Options{}).IsEmpty())
+	// the order of the repeated options matters
+	diff = DiffOptions(a[:2], Options{a[1], a[0]})
+	require.Len(t, diff.Changed, 1)
+	require.Equal(t, URIPath, diff.Changed[0].ID)
+}
+
+func TestOptionsValidateCanonical(t *testing.T) {
+	require.NoError(t, Options{
+		{ID: Observe, Value: []byte{}},
+		{ID: URIPath, Value: []byte("a")},
+		{ID: ContentFormat, Value: []byte{50}},
+	}.ValidateCanonical(CoapOptionDefs))
+	// uint value with leading zero
+	err := Options{{ID: ContentFormat, Value: []byte{0, 50}}}.ValidateCanonical(CoapOptionDefs)
+	require.ErrorIs(t, err, ErrOptionsNotCanonical)
+	// opaque values are not checked
+	require.NoError(t, Options{{ID: ETag, Value: []byte{0, 1}}}.ValidateCanonical(CoapOptionDefs))
+	// out of order
+	err = Options{{ID: URIPath, Value: []byte("a")}, {ID: Observe, Value: []byte{}}}.ValidateCanonical(CoapOptionDefs)
+	require.ErrorIs(t, err, ErrOptionsNotCanonical)
+}
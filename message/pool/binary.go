@@ -0,0 +1,31 @@
+package pool
+
+import (
+	"context"
+	"errors"
+)
+
+// MarshalBinaryWithEncoder encodes the message to the wire format of the encoder: udp/coder.DefaultCoder for
+// the CoAP over UDP and DTLS (RFC 7252 section 3), tcp/coder.DefaultCoder for the CoAP over TCP and TLS (RFC 8323 section 3.2).
+//
+// The returned slice is a copy, so it can be stored after the message is released.
+func (r *Message) MarshalBinaryWithEncoder(encoder Encoder) ([]byte, error) {
+	data, err := r.MarshalWithEncoder(encoder)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// ParseMessage creates the message from data in the wire format of the decoder: udp/coder.DefaultCoder for UDP and DTLS,
+// tcp/coder.DefaultCoder for TCP and TLS. The data must contain exactly one message and it can be reused after the call.
+func ParseMessage(ctx context.Context, data []byte, decoder Decoder) (*Message, error) {
+	if decoder == nil {
+		return nil, errors.New("invalid decoder")
+	}
+	msg := NewMessage(ctx)
+	if _, err := msg.UnmarshalWithDecoder(decoder, data); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
@@ -0,0 +1,71 @@
+package pool_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+	tcpCoder "github.com/plgd-dev/go-coap/v3/tcp/coder"
+	udpCoder "github.com/plgd-dev/go-coap/v3/udp/coder"
+	"github.com/stretchr/testify/require"
+)
+
+func newBinaryTestMessage(t *testing.T) *pool.Message {
+	msg := pool.NewMessage(context.Background())
+	err := msg.SetupPost("/a/b", message.Token{1, 2, 3}, message.TextPlain, bytes.NewReader([]byte("hello")))
+	require.NoError(t, err)
+	msg.SetType(message.Confirmable)
+	msg.SetMessageID(42)
+	return msg
+}
+
+func TestMessageMarshalBinaryWithEncoder(t *testing.T) {
+	msg := newBinaryTestMessage(t)
+	data, err := msg.MarshalBinaryWithEncoder(udpCoder.DefaultCoder)
+	require.NoError(t, err)
+	// data are not shared with the message buffers
+	msg.Reset()
+	msg.SetCode(codes.GET)
+	msg.SetMessageID(1)
+	msg.SetType(message.NonConfirmable)
+	_, err = msg.MarshalBinaryWithEncoder(udpCoder.DefaultCoder)
+	require.NoError(t, err)
+
+	parsed, err := pool.ParseMessage(context.Background(), data, udpCoder.DefaultCoder)
+	require.NoError(t, err)
+	require.Equal(t, codes.POST, parsed.Code())
+	require.Equal(t, message.Confirmable, parsed.Type())
+	require.Equal(t, int32(42), parsed.MessageID())
+	require.Equal(t, message.Token{1, 2, 3}, parsed.Token())
+	path, err := parsed.Path()
+	require.NoError(t, err)
+	require.Equal(t, "/a/b", path)
+	body, err := parsed.ReadBody()
+	require.NoError(t, err)
+	require.Equal(t, []byte("hello"), body)
+
+	_, err = pool.ParseMessage(context.Background(), data[:3], udpCoder.DefaultCoder)
+	require.Error(t, err)
+	_, err = pool.ParseMessage(context.Background(), data, nil)
+	require.Error(t, err)
+}
+
+func TestParseMessageTCP(t *testing.T) {
+	msg := newBinaryTestMessage(t)
+	data, err := msg.MarshalBinaryWithEncoder(tcpCoder.DefaultCoder)
+	require.NoError(t, err)
+
+	parsed, err := pool.ParseMessage(context.Background(), data, tcpCoder.DefaultCoder)
+	require.NoError(t, err)
+	require.Equal(t, codes.POST, parsed.Code())
+	require.Equal(t, message.Token{1, 2, 3}, parsed.Token())
+	body, err := parsed.ReadBody()
+	require.NoError(t, err)
+	require.Equal(t, []byte("hello"), body)
+
+	_, err = pool.ParseMessage(context.Background(), data[:2], tcpCoder.DefaultCoder)
+	require.Error(t, err)
+}
@@ -19,6 +19,12 @@ type Encoder interface {
 	Encode(m message.Message, buf []byte) (int, error)
 }
 
+// HeaderEncoder encodes the message without the payload, so the payload can be written after the encoded header
+// directly from the body (e.g. the length-prefixed frame of CoAP over TCP).
+type HeaderEncoder interface {
+	EncodeHeader(m message.Message, payloadLen int, buf []byte) (int, error)
+}
+
 type Decoder interface {
 	Decode(buf []byte, m *message.Message) (int, error)
 }
@@ -34,13 +40,23 @@ type Message struct {
 	origValueBuffer []byte
 	body            io.ReadSeeker
 	sequence        uint64
+	// acquiredFrom is the pool which counts the message as acquired until it is released
+	acquiredFrom *Pool
+	// shared is the encoding set by SetSharedEncoding, sharedBody is the body it set
+	shared     *SharedEncoding
+	sharedBody *bytes.Reader
 
 	// local vars
 	bufferUnmarshal []byte
 	bufferMarshal   []byte
+	bufferBorrow    []byte
 }
 
-const valueBufferSize = 256
+const (
+	valueBufferSize = 256
+	// maxBorrowBufferSize is the maximum size of the buffer used by BodyBytesBorrow which is kept for the next reuse of the message.
+	maxBorrowBufferSize = 64 * 1024
+)
 
 func NewMessage(ctx context.Context) *Message {
 	valueBuffer := make([]byte, valueBufferSize)
@@ -127,6 +143,14 @@ func (r *Message) Type() message.Type {
 	return r.msg.Type
 }
 
+// SetVersionForTesting overrides the version of the UDP and DTLS messages, which is otherwise 1. It is intended
+// for the conformance testing of the peers, because the messages with another version are silently ignored by them.
+// Only the values 0 to 3 are valid, the version 0 is also encoded.
+func (r *Message) SetVersionForTesting(version uint8) {
+	r.msg.Version = &version
+	r.isModified = true
+}
+
 // Reset clear message for next reuse
 func (r *Message) Reset() {
 	r.msg.Token = nil
@@ -134,17 +158,23 @@ func (r *Message) Reset() {
 	r.msg.Options = r.msg.Options[:0]
 	r.msg.MessageID = -1
 	r.msg.Type = message.Unset
+	r.msg.Version = nil
 	r.msg.Payload = nil
 	r.valueBuffer = r.origValueBuffer
 	r.body = nil
 	r.isModified = false
 	r.controlMessage = nil
+	r.shared = nil
+	r.sharedBody = nil
 	if cap(r.bufferMarshal) > 1024 {
 		r.bufferMarshal = make([]byte, 256)
 	}
 	if cap(r.bufferUnmarshal) > 1024 {
 		r.bufferUnmarshal = make([]byte, 256)
 	}
+	if cap(r.bufferBorrow) > maxBorrowBufferSize {
+		r.bufferBorrow = nil
+	}
 	r.isModified = false
 }
 
@@ -280,6 +310,26 @@ func (r *Message) AddQuery(query string) {
 	r.AddOptionString(message.URIQuery, query)
 }
 
+// QueryParams returns URIQuery options parsed to the ordered key/value pairs.
+func (r *Message) QueryParams() (message.QueryParams, error) {
+	return r.msg.Options.QueryParams()
+}
+
+// SetQueryParams replaces all URIQuery options by params.
+func (r *Message) SetQueryParams(params message.QueryParams) {
+	opts, used, err := r.msg.Options.SetQueryParams(r.valueBuffer, params)
+	if errors.Is(err, message.ErrTooSmall) {
+		r.valueBuffer = append(r.valueBuffer, make([]byte, used)...)
+		opts, used, err = r.msg.Options.SetQueryParams(r.valueBuffer, params)
+	}
+	if err != nil {
+		panic(fmt.Errorf("cannot set query params: %w", err))
+	}
+	r.msg.Options = opts
+	r.valueBuffer = r.valueBuffer[used:]
+	r.isModified = true
+}
+
 func (r *Message) GetOptionUint32(id message.OptionID) (uint32, error) {
 	return r.msg.Options.GetUint32(id)
 }
@@ -381,10 +431,33 @@ func (r *Message) HasOption(id message.OptionID) bool {
 	return r.msg.Options.HasOption(id)
 }
 
+// SetContentFormat sets the Content-Format option which indicates the format of the payload (RFC 7252 section 5.10.3),
+// so it must be used only for the messages with the body. The body can be set before or after the content format,
+// so the rule is checked by ValidateContentFormat when the request is sent.
 func (r *Message) SetContentFormat(contentFormat message.MediaType) {
 	r.SetOptionUint32(message.ContentFormat, uint32(contentFormat))
 }
 
+// ValidateContentFormat returns message.ErrContentFormatWithoutPayload when the Content-Format option is set,
+// but the message has no body, e.g. GET request with the Content-Format instead of the Accept option.
+// The empty body is valid, because the empty payload can be the representation in the format,
+// e.g. an empty text/plain.
+func (r *Message) ValidateContentFormat() error {
+	if !r.HasOption(message.ContentFormat) || r.Body() != nil {
+		return nil
+	}
+	cf, _ := r.ContentFormat()
+	return fmt.Errorf("%w: %v", message.ErrContentFormatWithoutPayload, cf)
+}
+
+// UpsertContentFormat sets content format option only when it is not set.
+func (r *Message) UpsertContentFormat(contentFormat message.MediaType) {
+	if r.HasOption(message.ContentFormat) {
+		return
+	}
+	r.SetContentFormat(contentFormat)
+}
+
 func (r *Message) SetObserve(observe uint32) {
 	r.SetOptionUint32(message.Observe, observe)
 }
@@ -393,6 +466,26 @@ func (r *Message) Observe() (uint32, error) {
 	return r.GetOptionUint32(message.Observe)
 }
 
+// ObserveAction returns the meaning of the Observe option: the request registers (0) or deregisters (1) the observation
+// and the response with the option is the notification. ObserveNone is returned when the option is not set.
+func (r *Message) ObserveAction() message.ObserveAction {
+	obs, err := r.Observe()
+	if err != nil {
+		return message.ObserveNone
+	}
+	switch code := r.Code(); {
+	case code >= codes.Created:
+		return message.ObserveNotification
+	case code == codes.Empty:
+		return message.ObserveNone
+	case obs == message.ObserveRegisterValue:
+		return message.ObserveRegister
+	case obs == message.ObserveDeregisterValue:
+		return message.ObserveDeregister
+	}
+	return message.ObserveNone
+}
+
 // SetAccept sets accept option.
 func (r *Message) SetAccept(contentFormat message.MediaType) {
 	r.SetOptionUint32(message.Accept, uint32(contentFormat))
@@ -432,6 +525,14 @@ func (r *Message) SetBody(s io.ReadSeeker) {
 	r.isModified = true
 }
 
+// SetBodyReaderAt sets the body of size bytes read from r by ReadAt, e.g. from os.File. The blockwise transfer reads
+// each block by ReadAt at its offset, so the blocks are read without the buffering of the whole body
+// and without the shared read position. The blockwise transfer doesn't compute the ETag of such body, so set it
+// by SetETag when the blocks can be fetched by the separate requests, e.g. from the modification time of the file.
+func (r *Message) SetBodyReaderAt(ra io.ReaderAt, size int64) {
+	r.SetBody(io.NewSectionReader(ra, 0, size))
+}
+
 func (r *Message) Body() io.ReadSeeker {
 	return r.body
 }
@@ -464,11 +565,14 @@ func (r *Message) String() string {
 	return r.msg.String()
 }
 
-func (r *Message) ReadBody() ([]byte, error) {
+func (r *Message) readBody(payload []byte) ([]byte, error) {
 	if r.Body() == nil {
 		return nil, nil
 	}
 	size, err := r.BodySize()
+	if errors.Is(err, message.ErrStreamSizeUnknown) {
+		return r.readStreamBody(payload)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -479,10 +583,10 @@ func (r *Message) ReadBody() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	payload := make([]byte, 1024)
-	if int64(len(payload)) < size {
+	if int64(cap(payload)) < size {
 		payload = make([]byte, size)
 	}
+	payload = payload[:cap(payload)]
 	n, err := io.ReadFull(r.Body(), payload)
 	if (errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)) && int64(n) == size {
 		err = nil
@@ -493,6 +597,54 @@ func (r *Message) ReadBody() ([]byte, error) {
 	return payload[:n], nil
 }
 
+// readStreamBody reads the body of unknown size, e.g. message.StreamBody, up to its end.
+func (r *Message) readStreamBody(payload []byte) ([]byte, error) {
+	if _, err := r.Body().Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	buf := bytes.NewBuffer(payload[:0])
+	if _, err := buf.ReadFrom(r.Body()); err != nil {
+		return nil, err
+	}
+	if buf.Len() == 0 {
+		return nil, nil
+	}
+	return buf.Bytes(), nil
+}
+
+func (r *Message) ReadBody() ([]byte, error) {
+	return r.readBody(make([]byte, 1024))
+}
+
+// DecodeBody decodes the body into v by the decoder of the content format of the message, see message.RegisterBodyDecoder.
+func (r *Message) DecodeBody(v any) error {
+	contentFormat, err := r.ContentFormat()
+	if err != nil {
+		return fmt.Errorf("cannot get content format: %w", err)
+	}
+	data, err := r.BodyBytesBorrow()
+	if err != nil {
+		return fmt.Errorf("cannot read body: %w", err)
+	}
+	return message.DecodeBody(contentFormat, data, v)
+}
+
+// BodyBytesBorrow returns the body of the message in a buffer owned by the message, so it doesn't allocate
+// for each received message (e.g. observe notification).
+//
+// The returned slice is valid only until the message is released to the pool or BodyBytesBorrow is called again,
+// so it must not be retained after the handler or the observe callback returns. Use ReadBody to get a copy.
+func (r *Message) BodyBytesBorrow() ([]byte, error) {
+	payload, err := r.readBody(r.bufferBorrow)
+	if err != nil {
+		return nil, err
+	}
+	if cap(payload) > cap(r.bufferBorrow) {
+		r.bufferBorrow = payload[:0]
+	}
+	return payload, nil
+}
+
 func (r *Message) toMessage() (message.Message, error) {
 	payload, err := r.ReadBody()
 	if err != nil {
@@ -503,7 +655,21 @@ func (r *Message) toMessage() (message.Message, error) {
 	return m, nil
 }
 
+// WireSize returns the size of the message marshaled by the encoder (e.g. udp/coder.DefaultCoder), including the header,
+// the options and the payload, without marshaling the message. It allows to decide whether the message fits the MTU
+// or it needs to be sent by the blockwise transfer.
+func (r *Message) WireSize(encoder Encoder) (int, error) {
+	msg, err := r.toMessage()
+	if err != nil {
+		return -1, err
+	}
+	return encoder.Size(msg)
+}
+
 func (r *Message) MarshalWithEncoder(encoder Encoder) ([]byte, error) {
+	if tail, tailEncoder, ok := r.sharedTail(encoder); ok {
+		return r.marshalWithTail(tailEncoder, tail)
+	}
 	msg, err := r.toMessage()
 	if err != nil {
 		return nil, err
@@ -523,6 +689,42 @@ func (r *Message) MarshalWithEncoder(encoder Encoder) ([]byte, error) {
 	return r.bufferMarshal, nil
 }
 
+func (r *Message) marshalWithTail(encoder TailEncoder, tail []byte) ([]byte, error) {
+	size, err := encoder.EncodeWithTail(r.msg, tail, nil)
+	if !errors.Is(err, message.ErrTooSmall) {
+		return nil, err
+	}
+	if len(r.bufferMarshal) < size {
+		r.bufferMarshal = append(r.bufferMarshal, make([]byte, size-len(r.bufferMarshal))...)
+	}
+	n, err := encoder.EncodeWithTail(r.msg, tail, r.bufferMarshal)
+	if err != nil {
+		return nil, err
+	}
+	r.bufferMarshal = r.bufferMarshal[:n]
+	return r.bufferMarshal, nil
+}
+
+// MarshalHeaderWithEncoder marshals the message without the body, the body of bodySize bytes must be written
+// after the returned data. The returned data are valid until the next marshal of the message.
+func (r *Message) MarshalHeaderWithEncoder(encoder HeaderEncoder, bodySize int) ([]byte, error) {
+	msg := r.msg
+	msg.Payload = nil
+	size, err := encoder.EncodeHeader(msg, bodySize, nil)
+	if err != nil && !errors.Is(err, message.ErrTooSmall) {
+		return nil, err
+	}
+	if len(r.bufferMarshal) < size {
+		r.bufferMarshal = append(r.bufferMarshal, make([]byte, size-len(r.bufferMarshal))...)
+	}
+	n, err := encoder.EncodeHeader(msg, bodySize, r.bufferMarshal)
+	if err != nil {
+		return nil, err
+	}
+	r.bufferMarshal = r.bufferMarshal[:n]
+	return r.bufferMarshal, nil
+}
+
 func (r *Message) decode(decoder Decoder) (int, error) {
 	var n int
 	var err error
@@ -9,9 +9,11 @@ import (
 	"testing"
 
 	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
 	"github.com/plgd-dev/go-coap/v3/message/pool"
 	"github.com/plgd-dev/go-coap/v3/tcp/coder"
 	"github.com/plgd-dev/go-coap/v3/test/net"
+	udp "github.com/plgd-dev/go-coap/v3/udp/coder"
 	"github.com/stretchr/testify/require"
 )
 
@@ -391,3 +393,130 @@ func TestUnmarshalMessageWithMultipleOptions(t *testing.T) {
 		})
 	}
 }
+
+func TestMessageBodyBytesBorrow(t *testing.T) {
+	msg := pool.NewMessage(context.Background())
+	body, err := msg.BodyBytesBorrow()
+	require.NoError(t, err)
+	require.Empty(t, body)
+
+	msg.SetBody(bytes.NewReader([]byte("first")))
+	body, err = msg.BodyBytesBorrow()
+	require.NoError(t, err)
+	require.Equal(t, []byte("first"), body)
+
+	msg.SetBody(bytes.NewReader([]byte("2nd")))
+	body2, err := msg.BodyBytesBorrow()
+	require.NoError(t, err)
+	require.Equal(t, []byte("2nd"), body2)
+	// the buffer is reused
+	require.Equal(t, &body[0], &body2[0])
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_, _ = msg.BodyBytesBorrow()
+	})
+	require.Zero(t, allocs)
+}
+
+func TestMessageValidateContentFormat(t *testing.T) {
+	msg := pool.NewMessage(context.Background())
+	require.NoError(t, msg.ValidateContentFormat())
+
+	msg.SetContentFormat(message.TextPlain)
+	err := msg.ValidateContentFormat()
+	require.ErrorIs(t, err, message.ErrContentFormatWithoutPayload)
+
+	// the empty payload is the representation in the content format
+	msg.SetBody(bytes.NewReader(nil))
+	require.NoError(t, msg.ValidateContentFormat())
+
+	msg.SetBody(bytes.NewReader([]byte("payload")))
+	require.NoError(t, msg.ValidateContentFormat())
+
+	msg.SetBody(nil)
+	msg.Remove(message.ContentFormat)
+	require.NoError(t, msg.ValidateContentFormat())
+}
+
+func TestMessageWireSize(t *testing.T) {
+	msg := pool.NewMessage(context.Background())
+	msg.SetCode(codes.POST)
+	msg.SetToken(message.Token("token"))
+	err := msg.SetPath("/a/b")
+	require.NoError(t, err)
+	msg.SetContentFormat(message.TextPlain)
+	msg.SetBody(bytes.NewReader([]byte("payload")))
+
+	size, err := msg.WireSize(coder.DefaultCoder)
+	require.NoError(t, err)
+	data, err := msg.MarshalWithEncoder(coder.DefaultCoder)
+	require.NoError(t, err)
+	require.Len(t, data, size)
+}
+
+func TestMessageObserveAction(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    codes.Code
+		observe int64 // -1 for the message without the option
+		want    message.ObserveAction
+	}{
+		{name: "without observe", code: codes.GET, observe: -1, want: message.ObserveNone},
+		{name: "register", code: codes.GET, observe: 0, want: message.ObserveRegister},
+		{name: "deregister", code: codes.POST, observe: 1, want: message.ObserveDeregister},
+		{name: "unknown request value", code: codes.GET, observe: 2, want: message.ObserveNone},
+		{name: "notification", code: codes.Content, observe: 1, want: message.ObserveNotification},
+		{name: "response without observe", code: codes.Content, observe: -1, want: message.ObserveNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := pool.NewMessage(context.Background())
+			m.SetCode(tt.code)
+			if tt.observe >= 0 {
+				m.SetObserve(uint32(tt.observe))
+			}
+			require.Equal(t, tt.want, m.ObserveAction())
+			require.Equal(t, tt.want.String(), m.ObserveAction().String())
+		})
+	}
+}
+
+func TestMessageSharedEncoding(t *testing.T) {
+	opts := message.Options{}
+	buf := make([]byte, 64)
+	opts, _, err := opts.SetContentFormat(buf, message.AppJSON)
+	require.NoError(t, err)
+	shared, err := pool.NewSharedEncoding(codes.Content, opts, []byte(`{"temp":21}`))
+	require.NoError(t, err)
+
+	encoders := map[string]pool.Encoder{"udp": udp.DefaultCoder, "tcp": coder.DefaultCoder}
+	for name, encoder := range encoders {
+		t.Run(name, func(t *testing.T) {
+			for i, token := range []message.Token{{0x1}, {0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7, 0x8}} {
+				m := pool.NewMessage(context.Background())
+				m.SetSharedEncoding(shared)
+				m.SetToken(token)
+				m.SetMessageID(int32(i))
+				m.SetType(message.NonConfirmable)
+				data, err := m.MarshalWithEncoder(encoder)
+				require.NoError(t, err)
+
+				expected := pool.NewMessage(context.Background())
+				err = m.Clone(expected)
+				require.NoError(t, err)
+				expectedData, err := expected.MarshalWithEncoder(encoder)
+				require.NoError(t, err)
+				require.Equal(t, expectedData, data)
+
+				// the modified message is not encoded by the shared encoding
+				m.SetObserve(2)
+				data, err = m.MarshalWithEncoder(encoder)
+				require.NoError(t, err)
+				expected.SetObserve(2)
+				expectedData, err = expected.MarshalWithEncoder(encoder)
+				require.NoError(t, err)
+				require.Equal(t, expectedData, data)
+			}
+		})
+	}
+}
@@ -2,12 +2,47 @@ package pool
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
 	"sync"
 
 	"go.uber.org/atomic"
+	"golang.org/x/sync/semaphore"
 )
 
+// ErrPoolExhausted is returned by TryAcquireMessage when the message cannot be acquired by the ExhaustedPolicy of the pool.
+var ErrPoolExhausted = errors.New("message pool is exhausted")
+
+// ExhaustedPolicy defines what happens when the message is acquired while maxNumMessages messages of the pool
+// are acquired and not released yet.
+type ExhaustedPolicy uint8
+
+const (
+	// ExhaustedAllocate allocates the message beyond the pool, so maxNumMessages limits only the number
+	// of the released messages kept by the pool for the reuse. It is the default policy.
+	ExhaustedAllocate ExhaustedPolicy = iota
+	// ExhaustedBlock blocks the acquisition until a message is released to the pool or the context
+	// of the acquisition is done.
+	ExhaustedBlock
+	// ExhaustedError fails the acquisition by TryAcquireMessage with ErrPoolExhausted.
+	ExhaustedError
+)
+
+var exhaustedPolicyToString = map[ExhaustedPolicy]string{
+	ExhaustedAllocate: "Allocate",
+	ExhaustedBlock:    "Block",
+	ExhaustedError:    "Error",
+}
+
+func (p ExhaustedPolicy) String() string {
+	str, ok := exhaustedPolicyToString[p]
+	if !ok {
+		return "ExhaustedPolicy(" + strconv.FormatInt(int64(p), 10) + ")"
+	}
+	return str
+}
+
 type Pool struct {
 	// This field needs to be the first in the struct to ensure proper word alignment on 32-bit platforms.
 	// See: https://golang.org/pkg/sync/atomic/#pkg-note-BUG
@@ -15,21 +50,49 @@ type Pool struct {
 	messagePool           sync.Pool
 	maxNumMessages        uint32
 	maxMessageBufferSize  uint16
+	exhaustedPolicy       ExhaustedPolicy
+	// acquired bounds the number of the acquired messages, nil for ExhaustedAllocate
+	acquired *semaphore.Weighted
+	// numExhausted counts the acquisitions which were not satisfied by the exhausted policy
+	numExhausted atomic.Uint64
 }
 
-func New(maxNumMessages uint32, maxMessageBufferSize uint16) *Pool {
-	return &Pool{
+type Option = func(p *Pool)
+
+// WithExhaustedPolicy sets the policy applied when maxNumMessages messages of the pool are acquired,
+// by default the messages are allocated beyond the pool (ExhaustedAllocate). With ExhaustedBlock and ExhaustedError
+// the pool counts the acquired messages, so all of them must be released by ReleaseMessage, otherwise the pool
+// is exhausted forever. New panics when maxNumMessages is 0 for ExhaustedBlock or ExhaustedError, because no message
+// could be acquired.
+func WithExhaustedPolicy(policy ExhaustedPolicy) Option {
+	return func(p *Pool) {
+		p.exhaustedPolicy = policy
+	}
+}
+
+func New(maxNumMessages uint32, maxMessageBufferSize uint16, opts ...Option) *Pool {
+	p := &Pool{
 		maxNumMessages:       maxNumMessages,
 		maxMessageBufferSize: maxMessageBufferSize,
 	}
+	for _, o := range opts {
+		o(p)
+	}
+	if p.exhaustedPolicy != ExhaustedAllocate {
+		if maxNumMessages == 0 {
+			panic(fmt.Errorf("invalid maxNumMessages(0) for exhausted policy %v", p.exhaustedPolicy))
+		}
+		p.acquired = semaphore.NewWeighted(int64(maxNumMessages))
+	}
+	return p
 }
 
-// AcquireMessage returns an empty Message instance from Message pool.
-//
-// The returned Message instance may be passed to ReleaseMessage when it is
-// no longer needed. This allows Message recycling, reduces GC pressure
-// and usually improves performance.
-func (p *Pool) AcquireMessage(ctx context.Context) *Message {
+// ExhaustedPolicy returns the policy applied when maxNumMessages messages of the pool are acquired.
+func (p *Pool) ExhaustedPolicy() ExhaustedPolicy {
+	return p.exhaustedPolicy
+}
+
+func (p *Pool) getMessage(ctx context.Context) *Message {
 	v := p.messagePool.Get()
 	if v == nil {
 		return NewMessage(ctx)
@@ -43,11 +106,74 @@ func (p *Pool) AcquireMessage(ctx context.Context) *Message {
 	return r
 }
 
+// AcquireMessage returns an empty Message instance from Message pool.
+//
+// The returned Message instance may be passed to ReleaseMessage when it is
+// no longer needed. This allows Message recycling, reduces GC pressure
+// and usually improves performance.
+//
+// When the pool is exhausted, the ExhaustedBlock policy blocks until a message is released or the ctx is done.
+// AcquireMessage cannot fail, so the message which cannot be acquired by the policy is allocated beyond the pool
+// and counted by NumExhausted, use TryAcquireMessage to get ErrPoolExhausted instead. The requests created
+// by the clients are acquired by TryAcquireMessage and the received messages by AcquireMessageNoWait, so they
+// fail when the pool is exhausted.
+func (p *Pool) AcquireMessage(ctx context.Context) *Message {
+	r, err := p.TryAcquireMessage(ctx)
+	if err != nil {
+		return NewMessage(ctx)
+	}
+	return r
+}
+
+// TryAcquireMessage acquires the message as AcquireMessage, but it returns ErrPoolExhausted when the message
+// cannot be acquired by the policy: immediately for ExhaustedError or when the ctx is done for ExhaustedBlock.
+func (p *Pool) TryAcquireMessage(ctx context.Context) (*Message, error) {
+	return p.tryAcquireMessage(ctx, p.exhaustedPolicy == ExhaustedBlock)
+}
+
+// AcquireMessageNoWait acquires the message as TryAcquireMessage, but it never blocks, so it returns
+// ErrPoolExhausted immediately also for ExhaustedBlock. The connections acquire the received messages by it,
+// so the reading of the connection is not blocked by the handlers which hold the messages of the pool.
+func (p *Pool) AcquireMessageNoWait(ctx context.Context) (*Message, error) {
+	return p.tryAcquireMessage(ctx, false)
+}
+
+func (p *Pool) tryAcquireMessage(ctx context.Context, wait bool) (*Message, error) {
+	switch {
+	case p.exhaustedPolicy == ExhaustedAllocate:
+		return p.getMessage(ctx), nil
+	case wait:
+		if err := p.acquired.Acquire(ctx, 1); err != nil {
+			p.numExhausted.Inc()
+			return nil, fmt.Errorf("%w: %w", ErrPoolExhausted, err)
+		}
+	default:
+		if !p.acquired.TryAcquire(1) {
+			p.numExhausted.Inc()
+			return nil, ErrPoolExhausted
+		}
+	}
+	r := p.getMessage(ctx)
+	r.acquiredFrom = p
+	return r, nil
+}
+
+// NumExhausted returns the number of the messages which could not be acquired by the ExhaustedPolicy of the pool,
+// including the messages allocated beyond the pool by AcquireMessage.
+func (p *Pool) NumExhausted() uint64 {
+	return p.numExhausted.Load()
+}
+
 // ReleaseMessage returns req acquired via AcquireMessage to Message pool.
 //
 // It is forbidden accessing req and/or its' members after returning
 // it to Message pool.
 func (p *Pool) ReleaseMessage(req *Message) {
+	if owner := req.acquiredFrom; owner != nil {
+		// the message is counted by the pool which applies the exhausted policy
+		req.acquiredFrom = nil
+		owner.acquired.Release(1)
+	}
 	for {
 		v := p.currentMessagesInPool.Load()
 		if v >= int64(p.maxNumMessages) {
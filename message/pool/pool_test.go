@@ -0,0 +1,99 @@
+package pool_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolExhaustedPolicy(t *testing.T) {
+	p := pool.New(1, 1024)
+	require.Equal(t, pool.ExhaustedAllocate, p.ExhaustedPolicy())
+	m1, err := p.TryAcquireMessage(context.Background())
+	require.NoError(t, err)
+	m2, err := p.TryAcquireMessage(context.Background())
+	require.NoError(t, err)
+	p.ReleaseMessage(m1)
+	p.ReleaseMessage(m2)
+
+	p = pool.New(1, 1024, pool.WithExhaustedPolicy(pool.ExhaustedError))
+	require.Equal(t, "Error", p.ExhaustedPolicy().String())
+	m1, err = p.TryAcquireMessage(context.Background())
+	require.NoError(t, err)
+	_, err = p.TryAcquireMessage(context.Background())
+	require.ErrorIs(t, err, pool.ErrPoolExhausted)
+	// AcquireMessage allocates the message beyond the pool, which is not counted
+	m2 = p.AcquireMessage(context.Background())
+	require.NotNil(t, m2)
+	p.ReleaseMessage(m2)
+	_, err = p.TryAcquireMessage(context.Background())
+	require.ErrorIs(t, err, pool.ErrPoolExhausted)
+	p.ReleaseMessage(m1)
+	// the double release doesn't free more messages
+	p.ReleaseMessage(m1)
+	m1, err = p.TryAcquireMessage(context.Background())
+	require.NoError(t, err)
+	_, err = p.TryAcquireMessage(context.Background())
+	require.ErrorIs(t, err, pool.ErrPoolExhausted)
+
+	p = pool.New(1, 1024, pool.WithExhaustedPolicy(pool.ExhaustedBlock))
+	m1, err = p.TryAcquireMessage(context.Background())
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	_, err = p.TryAcquireMessage(ctx)
+	require.ErrorIs(t, err, pool.ErrPoolExhausted)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	acquired := make(chan *pool.Message)
+	go func() {
+		acquired <- p.AcquireMessage(context.Background())
+	}()
+	select {
+	case <-acquired:
+		require.Fail(t, "message was acquired from exhausted pool")
+	case <-time.After(time.Millisecond * 50):
+	}
+	p.ReleaseMessage(m1)
+	select {
+	case m := <-acquired:
+		p.ReleaseMessage(m)
+	case <-time.After(time.Second):
+		require.Fail(t, "message was not acquired after release")
+	}
+}
+
+func TestPoolExhaustedPolicyZeroMessages(t *testing.T) {
+	require.Panics(t, func() {
+		pool.New(0, 1024, pool.WithExhaustedPolicy(pool.ExhaustedBlock))
+	})
+	require.Panics(t, func() {
+		pool.New(0, 1024, pool.WithExhaustedPolicy(pool.ExhaustedError))
+	})
+	require.NotNil(t, pool.New(0, 1024))
+}
+
+func TestPoolAcquireMessageNoWait(t *testing.T) {
+	p := pool.New(1, 1024, pool.WithExhaustedPolicy(pool.ExhaustedBlock))
+	m, err := p.AcquireMessageNoWait(context.Background())
+	require.NoError(t, err)
+	// the exhausted pool fails immediately instead of blocking
+	_, err = p.AcquireMessageNoWait(context.Background())
+	require.ErrorIs(t, err, pool.ErrPoolExhausted)
+	require.Equal(t, uint64(1), p.NumExhausted())
+	p.ReleaseMessage(m)
+	m, err = p.AcquireMessageNoWait(context.Background())
+	require.NoError(t, err)
+	p.ReleaseMessage(m)
+
+	p = pool.New(1, 1024, pool.WithExhaustedPolicy(pool.ExhaustedError))
+	m, err = p.TryAcquireMessage(context.Background())
+	require.NoError(t, err)
+	// the message allocated beyond the pool is counted
+	p.ReleaseMessage(p.AcquireMessage(context.Background()))
+	require.Equal(t, uint64(1), p.NumExhausted())
+	p.ReleaseMessage(m)
+}
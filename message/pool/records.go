@@ -0,0 +1,139 @@
+package pool
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// ErrRecordTruncated is returned by RecordReader.Next when the body ends in the middle of the record.
+var ErrRecordTruncated = errors.New("record is truncated")
+
+// RecordFraming splits the body into the records. ReadRecord reads the next record from r and it returns io.EOF
+// when the body ends at the record boundary.
+type RecordFraming interface {
+	ReadRecord(r *bufio.Reader) ([]byte, error)
+}
+
+// The RecordFramingFunc type is an adapter to allow the use of ordinary functions as RecordFraming.
+type RecordFramingFunc func(r *bufio.Reader) ([]byte, error)
+
+// ReadRecord calls f(r).
+func (f RecordFramingFunc) ReadRecord(r *bufio.Reader) ([]byte, error) {
+	return f(r)
+}
+
+// readRecordData reads the record of the length announced by the prefix. The buffer grows with the read data,
+// so the invalid length doesn't allocate more than the body contains.
+func readRecordData(r io.Reader, length uint64) ([]byte, error) {
+	if length > math.MaxInt64 {
+		return nil, fmt.Errorf("%w: invalid length %v", ErrRecordTruncated, length)
+	}
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, r, int64(length))
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: %v of %v bytes", ErrRecordTruncated, n, length)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// LengthPrefixFraming creates the framing where each record is prefixed by its length encoded as the big-endian
+// unsigned integer of size bytes. The size must be 1, 2, 4 or 8.
+func LengthPrefixFraming(size int) RecordFraming {
+	return RecordFramingFunc(func(r *bufio.Reader) ([]byte, error) {
+		if size != 1 && size != 2 && size != 4 && size != 8 {
+			return nil, fmt.Errorf("invalid size(%v) of length prefix", size)
+		}
+		var prefix [8]byte
+		n, err := io.ReadFull(r, prefix[:size])
+		switch {
+		case errors.Is(err, io.EOF):
+			return nil, io.EOF
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return nil, fmt.Errorf("%w: %v of %v bytes of length", ErrRecordTruncated, n, size)
+		case err != nil:
+			return nil, err
+		}
+		var length uint64
+		for _, b := range prefix[:size] {
+			length = length<<8 | uint64(b)
+		}
+		return readRecordData(r, length)
+	})
+}
+
+// UvarintFraming is the framing where each record is prefixed by its length encoded as the unsigned varint
+// of encoding/binary (the protobuf delimited format).
+var UvarintFraming RecordFraming = RecordFramingFunc(func(r *bufio.Reader) ([]byte, error) {
+	length, err := binary.ReadUvarint(r)
+	switch {
+	case errors.Is(err, io.EOF):
+		return nil, io.EOF
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return nil, fmt.Errorf("%w: incomplete length", ErrRecordTruncated)
+	case err != nil:
+		return nil, err
+	}
+	return readRecordData(r, length)
+})
+
+// DelimiterFraming creates the framing where the records are separated by the delim, e.g. '\n' for the newline
+// delimited JSON. The delimiter after the last record is optional and the delimiter is not part of the record.
+func DelimiterFraming(delim byte) RecordFraming {
+	return RecordFramingFunc(func(r *bufio.Reader) ([]byte, error) {
+		record, err := r.ReadBytes(delim)
+		if errors.Is(err, io.EOF) {
+			if len(record) == 0 {
+				return nil, io.EOF
+			}
+			return record, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return record[:len(record)-1], nil
+	})
+}
+
+// RecordReader reads the successive records of the body, see Message.RecordReader.
+type RecordReader struct {
+	r       *bufio.Reader
+	framing RecordFraming
+	err     error
+}
+
+// Next returns the next record. It returns io.EOF when there are no more records; after the error it returns
+// the same error. The records returned by the framings of this package are copies, so they can be retained.
+func (rr *RecordReader) Next() ([]byte, error) {
+	if rr.err != nil {
+		return nil, rr.err
+	}
+	record, err := rr.framing.ReadRecord(rr.r)
+	if err != nil {
+		rr.err = err
+		return nil, err
+	}
+	return record, nil
+}
+
+// RecordReader returns the reader of the records packed into the body by the framing, e.g. the length-prefixed
+// records. It reads the body from its start, so the body must not be read by others until the records are read.
+func (r *Message) RecordReader(framing RecordFraming) (*RecordReader, error) {
+	if r.Body() == nil {
+		return &RecordReader{framing: framing, err: io.EOF}, nil
+	}
+	if _, err := r.Body().Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("cannot seek to start of body: %w", err)
+	}
+	return &RecordReader{
+		r:       bufio.NewReader(r.Body()),
+		framing: framing,
+	}, nil
+}
@@ -0,0 +1,102 @@
+package pool_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+	"github.com/stretchr/testify/require"
+)
+
+func readRecords(t *testing.T, body []byte, framing pool.RecordFraming) ([][]byte, error) {
+	msg := pool.NewMessage(context.Background())
+	if body != nil {
+		msg.SetBody(bytes.NewReader(body))
+	}
+	rr, err := msg.RecordReader(framing)
+	require.NoError(t, err)
+	var records [][]byte
+	for {
+		record, err := rr.Next()
+		if err != nil {
+			return records, err
+		}
+		records = append(records, record)
+	}
+}
+
+func TestMessageRecordReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    []byte
+		framing pool.RecordFraming
+		want    [][]byte
+		wantErr error
+	}{
+		{
+			name:    "empty body",
+			framing: pool.UvarintFraming,
+			wantErr: io.EOF,
+		},
+		{
+			name:    "uint16 prefix",
+			body:    []byte{0, 2, 'a', 'b', 0, 0, 0, 1, 'c'},
+			framing: pool.LengthPrefixFraming(2),
+			want:    [][]byte{[]byte("ab"), {}, []byte("c")},
+			wantErr: io.EOF,
+		},
+		{
+			name:    "uvarint prefix",
+			body:    binary.AppendUvarint(append(binary.AppendUvarint(nil, 3), "abc"...), 1),
+			framing: pool.UvarintFraming,
+			want:    [][]byte{[]byte("abc")},
+			wantErr: pool.ErrRecordTruncated,
+		},
+		{
+			name:    "truncated prefix",
+			body:    []byte{1, 'a', 0},
+			framing: pool.LengthPrefixFraming(2),
+			wantErr: pool.ErrRecordTruncated,
+		},
+		{
+			name:    "huge length",
+			body:    []byte{0xff, 0xff, 0xff, 0xff, 'a'},
+			framing: pool.LengthPrefixFraming(4),
+			wantErr: pool.ErrRecordTruncated,
+		},
+		{
+			name:    "delimiter",
+			body:    []byte("{\"a\":1}\n{\"a\":2}"),
+			framing: pool.DelimiterFraming('\n'),
+			want:    [][]byte{[]byte(`{"a":1}`), []byte(`{"a":2}`)},
+			wantErr: io.EOF,
+		},
+		{
+			name: "custom framing",
+			body: []byte("abcde"),
+			framing: pool.RecordFramingFunc(func(r *bufio.Reader) ([]byte, error) {
+				b, err := r.ReadByte()
+				if err != nil {
+					return nil, err
+				}
+				return []byte{b}, nil
+			}),
+			want:    [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d"), []byte("e")},
+			wantErr: io.EOF,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := readRecords(t, tt.body, tt.framing)
+			require.ErrorIs(t, err, tt.wantErr)
+			require.Equal(t, len(tt.want), len(records))
+			for i := range tt.want {
+				require.Equal(t, string(tt.want[i]), string(records[i]))
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package pool
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+)
+
+// TailEncoder encodes the message in two parts: the tail with the options and the payload, and the header with the token
+// and the message ID, so the tail can be encoded once and shared by the messages which differ only by the header.
+type TailEncoder interface {
+	// EncodeTail encodes the options, the payload marker and the payload of the message. When buf is too small, it returns
+	// the size of the tail and message.ErrTooSmall.
+	EncodeTail(m message.Message, buf []byte) (int, error)
+	// EncodeWithTail encodes the header of the message followed by the tail returned by EncodeTail for the same code.
+	// When buf is too small, it returns the size of the message and message.ErrTooSmall.
+	EncodeWithTail(m message.Message, tail []byte, buf []byte) (int, error)
+}
+
+// SharedEncoding is the code, the options and the payload shared by many messages, e.g. the notification published
+// to thousands of observers. The options and the payload are encoded once for each encoder and the encoded tail
+// is reused by all messages set by SetSharedEncoding, which differ only by the token, the message ID and the type.
+type SharedEncoding struct {
+	code    codes.Code
+	options message.Options
+	payload []byte
+
+	mutex sync.Mutex
+	tails map[TailEncoder][]byte // guarded by mutex
+}
+
+// NewSharedEncoding creates the shared encoding of the message with the code, the options and the payload.
+// The options are copied, the payload must not be modified while the encoding is used.
+func NewSharedEncoding(code codes.Code, options message.Options, payload []byte) (*SharedEncoding, error) {
+	opts, err := options.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return &SharedEncoding{
+		code:    code,
+		options: opts,
+		payload: payload,
+		tails:   make(map[TailEncoder][]byte),
+	}, nil
+}
+
+func (e *SharedEncoding) tail(encoder TailEncoder) ([]byte, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if tail, ok := e.tails[encoder]; ok {
+		return tail, nil
+	}
+	m := message.Message{
+		Code:    e.code,
+		Options: e.options,
+		Payload: e.payload,
+	}
+	size, err := encoder.EncodeTail(m, nil)
+	if !errors.Is(err, message.ErrTooSmall) {
+		return nil, err
+	}
+	tail := make([]byte, size)
+	if _, err = encoder.EncodeTail(m, tail); err != nil {
+		return nil, err
+	}
+	e.tails[encoder] = tail
+	return tail, nil
+}
+
+// SetSharedEncoding sets the code, the options and the body of the message from e. The message is marshaled
+// with the tail encoded by e until the code, the options or the body of the message are modified.
+func (r *Message) SetSharedEncoding(e *SharedEncoding) {
+	r.SetCode(e.code)
+	r.ResetOptionsTo(e.options)
+	var body *bytes.Reader
+	if len(e.payload) > 0 {
+		body = bytes.NewReader(e.payload)
+		r.SetBody(body)
+	} else {
+		r.SetBody(nil)
+	}
+	r.shared = e
+	r.sharedBody = body
+}
+
+// sharedTail returns the tail of the shared encoding when the message wasn't modified since SetSharedEncoding.
+func (r *Message) sharedTail(encoder Encoder) ([]byte, TailEncoder, bool) {
+	if r.shared == nil {
+		return nil, nil, false
+	}
+	tailEncoder, ok := encoder.(TailEncoder)
+	if !ok || r.msg.Code != r.shared.code || !r.hasSharedBody() || !equalOptions(r.msg.Options, r.shared.options) {
+		return nil, nil, false
+	}
+	tail, err := r.shared.tail(tailEncoder)
+	if err != nil {
+		// the message is encoded as a whole, so the error is reported by the encoder
+		return nil, nil, false
+	}
+	return tail, tailEncoder, true
+}
+
+func (r *Message) hasSharedBody() bool {
+	if r.sharedBody == nil {
+		return r.body == nil
+	}
+	b, ok := r.body.(*bytes.Reader)
+	return ok && b == r.sharedBody
+}
+
+func equalOptions(a, b message.Options) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].ID != b[i].ID || !bytes.Equal(a[i].Value, b[i].Value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,130 @@
+package message
+
+import (
+	"sort"
+	"strings"
+)
+
+// QueryParam is a parameter of the Uri-Query option in the form key=value.
+type QueryParam struct {
+	Key   string
+	Value string
+	// HasValue is true for parameters with '=', so the empty value is written as "key=". The parameter
+	// with a non-empty value is always written with '='.
+	HasValue bool
+}
+
+func (p QueryParam) String() string {
+	if p.Value == "" && !p.HasValue {
+		return p.Key
+	}
+	return p.Key + "=" + p.Value
+}
+
+// QueryParams is an ordered list of Uri-Query parameters which can contain duplicated keys.
+type QueryParams []QueryParam
+
+// ParseQueryParam splits Uri-Query option value to the key and the value by the first '='.
+func ParseQueryParam(query string) QueryParam {
+	key, value, ok := strings.Cut(query, "=")
+	return QueryParam{Key: key, Value: value, HasValue: ok}
+}
+
+// QueryParamsFromMap converts map to QueryParams. Keys are sorted, values of the same key keep their order.
+func QueryParamsFromMap(m map[string][]string) QueryParams {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	params := make(QueryParams, 0, len(m))
+	for _, k := range keys {
+		for _, v := range m[k] {
+			params = append(params, QueryParam{Key: k, Value: v, HasValue: v != ""})
+		}
+	}
+	return params
+}
+
+// Get returns the first value of the key or empty string when the key doesn't exist.
+func (params QueryParams) Get(key string) string {
+	for _, p := range params {
+		if p.Key == key {
+			return p.Value
+		}
+	}
+	return ""
+}
+
+// Values returns all values of the key in the order of the Uri-Query options.
+func (params QueryParams) Values(key string) []string {
+	var values []string
+	for _, p := range params {
+		if p.Key == key {
+			values = append(values, p.Value)
+		}
+	}
+	return values
+}
+
+// Has returns true when the key exists.
+func (params QueryParams) Has(key string) bool {
+	for _, p := range params {
+		if p.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Add appends the key/value pair, the key with the empty value is appended as the parameter without '='.
+func (params QueryParams) Add(key, value string) QueryParams {
+	return append(params, QueryParam{Key: key, Value: value, HasValue: value != ""})
+}
+
+// Options converts params to Uri-Query options, e.g. for Get/Post/Put/Delete of the connection.
+func (params QueryParams) Options() []Option {
+	opts := make([]Option, 0, len(params))
+	for _, p := range params {
+		opts = append(opts, Option{ID: URIQuery, Value: []byte(p.String())})
+	}
+	return opts
+}
+
+// QueryParams parses URIQuery options to the ordered key/value pairs.
+func (options Options) QueryParams() (QueryParams, error) {
+	queries, err := options.Queries()
+	if err != nil {
+		return nil, err
+	}
+	params := make(QueryParams, 0, len(queries))
+	for _, q := range queries {
+		params = append(params, ParseQueryParam(q))
+	}
+	return params, nil
+}
+
+// SetQueryParams replaces all URIQuery options by params and copies values to buffer.
+//
+// Returns modified options, number of used buf bytes and error if occurs.
+func (options Options) SetQueryParams(buf []byte, params QueryParams) (Options, int, error) {
+	needed := 0
+	for _, p := range params {
+		needed += len(p.String())
+	}
+	if len(buf) < needed {
+		return options, needed, ErrTooSmall
+	}
+	options = options.Remove(URIQuery)
+	used := 0
+	for _, p := range params {
+		var n int
+		var err error
+		options, n, err = options.AddString(buf[used:], URIQuery, p.String())
+		if err != nil {
+			return options, -1, err
+		}
+		used += n
+	}
+	return options, used, nil
+}
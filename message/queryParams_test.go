@@ -0,0 +1,38 @@
+package message
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryParams(t *testing.T) {
+	params := QueryParams{}.Add("tag", "a").Add("rt", "oic.r").Add("tag", "b").Add("flag", "")
+	buf := make([]byte, 4)
+	opts := Options{{ID: URIPath, Value: []byte("a")}, {ID: URIQuery, Value: []byte("old")}}
+	opts, n, err := opts.SetQueryParams(buf, params)
+	require.ErrorIs(t, err, ErrTooSmall)
+	buf = make([]byte, n)
+	opts, _, err = opts.SetQueryParams(buf, params)
+	require.NoError(t, err)
+
+	queries, err := opts.Queries()
+	require.NoError(t, err)
+	require.Equal(t, []string{"tag=a", "rt=oic.r", "tag=b", "flag"}, queries)
+
+	got, err := opts.QueryParams()
+	require.NoError(t, err)
+	require.Equal(t, params, got)
+	require.Equal(t, []string{"a", "b"}, got.Values("tag"))
+	require.Equal(t, "oic.r", got.Get("rt"))
+	require.True(t, got.Has("flag"))
+	require.False(t, got.Has("unknown"))
+
+	require.Equal(t, ParseQueryParam("a=b=c"), QueryParam{Key: "a", Value: "b=c", HasValue: true})
+	// '=' of the empty value is kept
+	require.Equal(t, "a=", ParseQueryParam("a=").String())
+	require.Equal(t, "a", ParseQueryParam("a").String())
+	require.Equal(t, QueryParams{{Key: "a", Value: "1", HasValue: true}, {Key: "a", Value: "2", HasValue: true}, {Key: "b", Value: "3", HasValue: true}},
+		QueryParamsFromMap(map[string][]string{"b": {"3"}, "a": {"1", "2"}}))
+	require.Equal(t, []Option{{ID: URIQuery, Value: []byte("tag=a")}}, QueryParams{{Key: "tag", Value: "a"}}.Options())
+}
@@ -0,0 +1,503 @@
+package senml
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// The labels of the CBOR representation (RFC 8428 section 6).
+const (
+	labelBaseVersion = -1
+	labelBaseName    = -2
+	labelBaseTime    = -3
+	labelBaseUnit    = -4
+	labelBaseValue   = -5
+	labelBaseSum     = -6
+	labelName        = 0
+	labelUnit        = 1
+	labelValue       = 2
+	labelStringValue = 3
+	labelBoolValue   = 4
+	labelSum         = 5
+	labelTime        = 6
+	labelUpdateTime  = 7
+	labelDataValue   = 8
+)
+
+// The major types of CBOR (RFC 8949 section 3.1).
+const (
+	majorUint   = 0
+	majorNegInt = 1
+	majorBytes  = 2
+	majorText   = 3
+	majorArray  = 4
+	majorMap    = 5
+	majorTag    = 6
+	majorSimple = 7
+)
+
+const (
+	infoIndefinite = 31
+	breakCode      = 0xff
+	simpleFalse    = 20
+	simpleTrue     = 21
+	// maxNestingDepth limits the nesting of the skipped values of the unknown labels.
+	maxNestingDepth = 16
+)
+
+var errTruncated = errors.New("truncated CBOR data")
+
+type cborEncoder struct {
+	buf []byte
+}
+
+func (e *cborEncoder) head(major byte, arg uint64) {
+	switch {
+	case arg < 24:
+		e.buf = append(e.buf, major<<5|byte(arg))
+	case arg <= math.MaxUint8:
+		e.buf = append(e.buf, major<<5|24, byte(arg))
+	case arg <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, major<<5|25), uint16(arg))
+	case arg <= math.MaxUint32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, major<<5|26), uint32(arg))
+	default:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, major<<5|27), arg)
+	}
+}
+
+func (e *cborEncoder) int(v int64) {
+	if v >= 0 {
+		e.head(majorUint, uint64(v))
+		return
+	}
+	e.head(majorNegInt, uint64(-1-v))
+}
+
+// number encodes the integral values as the integers and the others as the double-precision floats.
+func (e *cborEncoder) number(v float64) {
+	if v == math.Trunc(v) && v >= -(1<<63) && v < 1<<63 {
+		e.int(int64(v))
+		return
+	}
+	e.buf = binary.BigEndian.AppendUint64(append(e.buf, majorSimple<<5|27), math.Float64bits(v))
+}
+
+func (e *cborEncoder) text(s string) {
+	e.head(majorText, uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *cborEncoder) bytes(b []byte) {
+	e.head(majorBytes, uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+func (e *cborEncoder) bool(v bool) {
+	if v {
+		e.buf = append(e.buf, majorSimple<<5|simpleTrue)
+		return
+	}
+	e.buf = append(e.buf, majorSimple<<5|simpleFalse)
+}
+
+func (e *cborEncoder) record(r Record) {
+	var fields []func()
+	add := func(label int64, encode func()) {
+		fields = append(fields, func() {
+			e.int(label)
+			encode()
+		})
+	}
+	if r.BaseVersion != 0 {
+		add(labelBaseVersion, func() { e.int(int64(r.BaseVersion)) })
+	}
+	if r.BaseName != "" {
+		add(labelBaseName, func() { e.text(r.BaseName) })
+	}
+	if r.BaseTime != 0 {
+		add(labelBaseTime, func() { e.number(r.BaseTime) })
+	}
+	if r.BaseUnit != "" {
+		add(labelBaseUnit, func() { e.text(r.BaseUnit) })
+	}
+	if r.BaseValue != 0 {
+		add(labelBaseValue, func() { e.number(r.BaseValue) })
+	}
+	if r.BaseSum != 0 {
+		add(labelBaseSum, func() { e.number(r.BaseSum) })
+	}
+	if r.Name != "" {
+		add(labelName, func() { e.text(r.Name) })
+	}
+	if r.Unit != "" {
+		add(labelUnit, func() { e.text(r.Unit) })
+	}
+	if r.Value != nil {
+		add(labelValue, func() { e.number(*r.Value) })
+	}
+	if r.StringValue != nil {
+		add(labelStringValue, func() { e.text(*r.StringValue) })
+	}
+	if r.BoolValue != nil {
+		add(labelBoolValue, func() { e.bool(*r.BoolValue) })
+	}
+	if r.Sum != nil {
+		add(labelSum, func() { e.number(*r.Sum) })
+	}
+	if r.Time != 0 {
+		add(labelTime, func() { e.number(r.Time) })
+	}
+	if r.UpdateTime != 0 {
+		add(labelUpdateTime, func() { e.number(r.UpdateTime) })
+	}
+	if r.DataValue != nil {
+		add(labelDataValue, func() { e.bytes(r.DataValue) })
+	}
+	e.head(majorMap, uint64(len(fields)))
+	for _, f := range fields {
+		f()
+	}
+}
+
+// EncodeCBOR encodes the pack to the CBOR representation (application/senml+cbor).
+func EncodeCBOR(p Pack) []byte {
+	var e cborEncoder
+	e.head(majorArray, uint64(len(p)))
+	for _, r := range p {
+		e.record(r)
+	}
+	return e.buf
+}
+
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+// head reads the initial byte and the argument of the data item. The argument of the indefinite length is 0.
+func (d *cborDecoder) head() (major byte, info byte, arg uint64, err error) {
+	if d.pos >= len(d.data) {
+		return 0, 0, 0, errTruncated
+	}
+	major, info = d.data[d.pos]>>5, d.data[d.pos]&0x1f
+	d.pos++
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info <= 27:
+		n := 1 << (info - 24)
+		if len(d.data)-d.pos < n {
+			return 0, 0, 0, errTruncated
+		}
+		for _, c := range d.data[d.pos : d.pos+n] {
+			arg = arg<<8 | uint64(c)
+		}
+		d.pos += n
+	case info == infoIndefinite && major != majorUint && major != majorNegInt && major != majorTag:
+	default:
+		return 0, 0, 0, fmt.Errorf("invalid additional information %v of major type %v", info, major)
+	}
+	return major, info, arg, nil
+}
+
+// isBreak consumes the break code which ends the item of the indefinite length.
+func (d *cborDecoder) isBreak() bool {
+	if d.pos < len(d.data) && d.data[d.pos] == breakCode {
+		d.pos++
+		return true
+	}
+	return false
+}
+
+// length checks that the argument doesn't exceed the remaining data, each item occupies at least one byte.
+func (d *cborDecoder) length(arg uint64) (int, error) {
+	if arg > uint64(len(d.data)-d.pos) {
+		return 0, errTruncated
+	}
+	return int(arg), nil
+}
+
+// container reads the head of the array or the map, n is the number of the items or the pairs.
+func (d *cborDecoder) container(expected byte) (n int, indefinite bool, err error) {
+	major, info, arg, err := d.head()
+	if err != nil {
+		return 0, false, err
+	}
+	if major != expected {
+		return 0, false, fmt.Errorf("unexpected major type %v, expected %v", major, expected)
+	}
+	if info == infoIndefinite {
+		return 0, true, nil
+	}
+	n, err = d.length(arg)
+	return n, false, err
+}
+
+// next reports whether the container has the i-th item.
+func (d *cborDecoder) next(i, n int, indefinite bool) bool {
+	if indefinite {
+		return !d.isBreak()
+	}
+	return i < n
+}
+
+func (d *cborDecoder) int() (int64, error) {
+	major, _, arg, err := d.head()
+	if err != nil {
+		return 0, err
+	}
+	if (major != majorUint && major != majorNegInt) || arg > math.MaxInt64 {
+		return 0, fmt.Errorf("invalid integer of major type %v", major)
+	}
+	if major == majorNegInt {
+		return -1 - int64(arg), nil
+	}
+	return int64(arg), nil
+}
+
+func halfToFloat64(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var v float64
+	switch exp {
+	case 0:
+		v = math.Ldexp(mant, -24)
+	case 0x1f:
+		v = math.Inf(1)
+		if mant != 0 {
+			v = math.NaN()
+		}
+	default:
+		v = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		v = -v
+	}
+	return v
+}
+
+func (d *cborDecoder) number() (float64, error) {
+	major, info, arg, err := d.head()
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case major == majorUint:
+		return float64(arg), nil
+	case major == majorNegInt:
+		return -1 - float64(arg), nil
+	case major == majorSimple && info == 25:
+		return halfToFloat64(uint16(arg)), nil
+	case major == majorSimple && info == 26:
+		return float64(math.Float32frombits(uint32(arg))), nil
+	case major == majorSimple && info == 27:
+		return math.Float64frombits(arg), nil
+	}
+	return 0, fmt.Errorf("invalid number of major type %v", major)
+}
+
+// string reads the byte string or the text string, the string of the indefinite length is concatenated from its chunks.
+func (d *cborDecoder) string(expected byte) ([]byte, error) {
+	major, info, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	if major != expected {
+		return nil, fmt.Errorf("unexpected major type %v, expected %v", major, expected)
+	}
+	if info != infoIndefinite {
+		n, err := d.length(arg)
+		if err != nil {
+			return nil, err
+		}
+		s := make([]byte, n)
+		copy(s, d.data[d.pos:d.pos+n])
+		d.pos += n
+		return s, nil
+	}
+	s := []byte{}
+	for !d.isBreak() {
+		major, info, arg, err = d.head()
+		if err != nil {
+			return nil, err
+		}
+		if major != expected || info == infoIndefinite {
+			return nil, fmt.Errorf("invalid chunk of string of major type %v", expected)
+		}
+		n, err := d.length(arg)
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, d.data[d.pos:d.pos+n]...)
+		d.pos += n
+	}
+	return s, nil
+}
+
+func (d *cborDecoder) text() (string, error) {
+	s, err := d.string(majorText)
+	return string(s), err
+}
+
+func (d *cborDecoder) bool() (bool, error) {
+	major, info, _, err := d.head()
+	if err != nil {
+		return false, err
+	}
+	if major != majorSimple || (info != simpleFalse && info != simpleTrue) {
+		return false, fmt.Errorf("invalid boolean of major type %v", major)
+	}
+	return info == simpleTrue, nil
+}
+
+// skip skips the data item of the unknown label.
+func (d *cborDecoder) skip(depth int) error {
+	if depth > maxNestingDepth {
+		return errors.New("nesting is too deep")
+	}
+	start := d.pos
+	major, info, arg, err := d.head()
+	if err != nil {
+		return err
+	}
+	switch major {
+	case majorBytes, majorText:
+		d.pos = start
+		_, err = d.string(major)
+		return err
+	case majorArray, majorMap:
+		n := 0
+		if info != infoIndefinite {
+			if n, err = d.length(arg); err != nil {
+				return err
+			}
+		}
+		items := 1
+		if major == majorMap {
+			items = 2
+		}
+		for i := 0; d.next(i, n, info == infoIndefinite); i++ {
+			for j := 0; j < items; j++ {
+				if err = d.skip(depth + 1); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	case majorTag:
+		return d.skip(depth + 1)
+	case majorSimple:
+		if info == infoIndefinite {
+			return errors.New("unexpected break")
+		}
+	}
+	return nil
+}
+
+func (d *cborDecoder) field(r *Record, label int64) error {
+	var err error
+	switch label {
+	case labelBaseVersion:
+		var v int64
+		v, err = d.int()
+		if err == nil && (v < math.MinInt32 || v > math.MaxInt32) {
+			err = fmt.Errorf("invalid version %v", v)
+		}
+		r.BaseVersion = int(v)
+	case labelBaseName:
+		r.BaseName, err = d.text()
+	case labelBaseTime:
+		r.BaseTime, err = d.number()
+	case labelBaseUnit:
+		r.BaseUnit, err = d.text()
+	case labelBaseValue:
+		r.BaseValue, err = d.number()
+	case labelBaseSum:
+		r.BaseSum, err = d.number()
+	case labelName:
+		r.Name, err = d.text()
+	case labelUnit:
+		r.Unit, err = d.text()
+	case labelValue:
+		r.Value = new(float64)
+		*r.Value, err = d.number()
+	case labelStringValue:
+		r.StringValue = new(string)
+		*r.StringValue, err = d.text()
+	case labelBoolValue:
+		r.BoolValue = new(bool)
+		*r.BoolValue, err = d.bool()
+	case labelSum:
+		r.Sum = new(float64)
+		*r.Sum, err = d.number()
+	case labelTime:
+		r.Time, err = d.number()
+	case labelUpdateTime:
+		r.UpdateTime, err = d.number()
+	case labelDataValue:
+		r.DataValue, err = d.string(majorBytes)
+	default:
+		err = d.skip(0)
+	}
+	return err
+}
+
+func (d *cborDecoder) record() (Record, error) {
+	var r Record
+	n, indefinite, err := d.container(majorMap)
+	if err != nil {
+		return r, err
+	}
+	for i := 0; d.next(i, n, indefinite); i++ {
+		if d.pos >= len(d.data) {
+			return r, errTruncated
+		}
+		if d.data[d.pos]>>5 == majorText {
+			// the extension label (RFC 8428 section 6)
+			key, errK := d.text()
+			if errK != nil {
+				return r, errK
+			}
+			if strings.HasSuffix(key, "_") {
+				return r, fmt.Errorf("unsupported label %q which must be understood", key)
+			}
+			if err = d.skip(0); err != nil {
+				return r, err
+			}
+			continue
+		}
+		label, errL := d.int()
+		if errL != nil {
+			return r, errL
+		}
+		if err = d.field(&r, label); err != nil {
+			return r, fmt.Errorf("label %v: %w", label, err)
+		}
+	}
+	return r, nil
+}
+
+// DecodeCBOR decodes the pack of the CBOR representation (application/senml+cbor).
+func DecodeCBOR(data []byte) (Pack, error) {
+	d := cborDecoder{data: data}
+	n, indefinite, err := d.container(majorArray)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPack, err)
+	}
+	p := make(Pack, 0, n)
+	for i := 0; d.next(i, n, indefinite); i++ {
+		r, err := d.record()
+		if err != nil {
+			return nil, fmt.Errorf("%w: record %v: %w", ErrInvalidPack, i, err)
+		}
+		p = append(p, r)
+	}
+	if d.pos != len(data) {
+		return nil, fmt.Errorf("%w: %v bytes after the pack", ErrInvalidPack, len(data)-d.pos)
+	}
+	return p, nil
+}
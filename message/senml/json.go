@@ -0,0 +1,140 @@
+package senml
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// jsonRecord is the JSON representation of the record (RFC 8428 section 5), the data value is encoded
+// by base64url without padding.
+type jsonRecord struct {
+	BaseName    string   `json:"bn,omitempty"`
+	BaseTime    float64  `json:"bt,omitempty"`
+	BaseUnit    string   `json:"bu,omitempty"`
+	BaseValue   float64  `json:"bv,omitempty"`
+	BaseSum     float64  `json:"bs,omitempty"`
+	BaseVersion int      `json:"bver,omitempty"`
+	Name        string   `json:"n,omitempty"`
+	Unit        string   `json:"u,omitempty"`
+	Value       *float64 `json:"v,omitempty"`
+	StringValue *string  `json:"vs,omitempty"`
+	BoolValue   *bool    `json:"vb,omitempty"`
+	DataValue   *string  `json:"vd,omitempty"`
+	Sum         *float64 `json:"s,omitempty"`
+	Time        float64  `json:"t,omitempty"`
+	UpdateTime  float64  `json:"ut,omitempty"`
+}
+
+func (r Record) MarshalJSON() ([]byte, error) {
+	jr := jsonRecord{
+		BaseName:    r.BaseName,
+		BaseTime:    r.BaseTime,
+		BaseUnit:    r.BaseUnit,
+		BaseValue:   r.BaseValue,
+		BaseSum:     r.BaseSum,
+		BaseVersion: r.BaseVersion,
+		Name:        r.Name,
+		Unit:        r.Unit,
+		Value:       r.Value,
+		StringValue: r.StringValue,
+		BoolValue:   r.BoolValue,
+		Sum:         r.Sum,
+		Time:        r.Time,
+		UpdateTime:  r.UpdateTime,
+	}
+	if r.DataValue != nil {
+		vd := base64.RawURLEncoding.EncodeToString(r.DataValue)
+		jr.DataValue = &vd
+	}
+	return json.Marshal(jr)
+}
+
+func unmarshalJSONField(r *Record, key string, raw json.RawMessage) error {
+	switch key {
+	case "bn":
+		return json.Unmarshal(raw, &r.BaseName)
+	case "bt":
+		return json.Unmarshal(raw, &r.BaseTime)
+	case "bu":
+		return json.Unmarshal(raw, &r.BaseUnit)
+	case "bv":
+		return json.Unmarshal(raw, &r.BaseValue)
+	case "bs":
+		return json.Unmarshal(raw, &r.BaseSum)
+	case "bver":
+		return json.Unmarshal(raw, &r.BaseVersion)
+	case "n":
+		return json.Unmarshal(raw, &r.Name)
+	case "u":
+		return json.Unmarshal(raw, &r.Unit)
+	case "v":
+		r.Value = new(float64)
+		return json.Unmarshal(raw, r.Value)
+	case "vs":
+		r.StringValue = new(string)
+		return json.Unmarshal(raw, r.StringValue)
+	case "vb":
+		r.BoolValue = new(bool)
+		return json.Unmarshal(raw, r.BoolValue)
+	case "vd":
+		var vd string
+		if err := json.Unmarshal(raw, &vd); err != nil {
+			return err
+		}
+		data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(vd, "="))
+		if err != nil {
+			return err
+		}
+		r.DataValue = data
+		return nil
+	case "s":
+		r.Sum = new(float64)
+		return json.Unmarshal(raw, r.Sum)
+	case "t":
+		return json.Unmarshal(raw, &r.Time)
+	case "ut":
+		return json.Unmarshal(raw, &r.UpdateTime)
+	}
+	// the unknown fields are ignored, except the fields which must be understood (RFC 8428 section 4.4)
+	if strings.HasSuffix(key, "_") {
+		return errors.New("unsupported field which must be understood")
+	}
+	return nil
+}
+
+func (r *Record) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidPack, err)
+	}
+	*r = Record{}
+	for key, raw := range fields {
+		if err := unmarshalJSONField(r, key, raw); err != nil {
+			return fmt.Errorf("%w: field %q: %w", ErrInvalidPack, key, err)
+		}
+	}
+	return nil
+}
+
+// DecodeJSON decodes the pack of the JSON representation (application/senml+json).
+func DecodeJSON(data []byte) (Pack, error) {
+	var p Pack
+	if err := json.Unmarshal(data, &p); err != nil {
+		if errors.Is(err, ErrInvalidPack) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPack, err)
+	}
+	return p, nil
+}
+
+// EncodeJSON encodes the pack to the JSON representation (application/senml+json).
+func EncodeJSON(p Pack) ([]byte, error) {
+	if p == nil {
+		p = Pack{}
+	}
+	return json.Marshal(p)
+}
@@ -0,0 +1,194 @@
+// Package senml implements parsing and encoding of the Sensor Measurement Lists (RFC 8428) in the JSON
+// and the CBOR representation.
+//
+// Importing the package registers the decoders of application/senml+cbor and application/sensml+cbor
+// for pool.Message.DecodeBody, so the body of both SenML representations can be decoded into *Pack.
+package senml
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+)
+
+var ErrInvalidPack = errors.New("invalid SenML pack")
+
+// DefaultBaseVersion is the version of the SenML (RFC 8428), which is assumed when the records don't contain bver.
+const DefaultBaseVersion = 10
+
+// relativeTimeThreshold is the time (2**28 seconds) under which the resolved time is relative to the current time
+// (RFC 8428 section 4.5.3).
+const relativeTimeThreshold = 1 << 28
+
+// Record is the SenML record. The base fields apply to the record and to the following records of the pack
+// until they are changed, see Pack.Resolve. The times are in seconds since the Unix epoch or relative
+// to the current time when they are less than 2**28. Only one of Value, StringValue, BoolValue and DataValue
+// can be set.
+type Record struct {
+	BaseName    string
+	BaseTime    float64
+	BaseUnit    string
+	BaseValue   float64
+	BaseSum     float64
+	BaseVersion int
+
+	Name        string
+	Unit        string
+	Time        float64
+	UpdateTime  float64
+	Value       *float64
+	StringValue *string
+	BoolValue   *bool
+	DataValue   []byte
+	Sum         *float64
+}
+
+// Pack is the SenML pack: the list of the records.
+type Pack []Record
+
+func isNameStart(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// validateName checks the characters of the resolved name (RFC 8428 section 4.5.1).
+func validateName(name string) error {
+	if name == "" {
+		return errors.New("empty name")
+	}
+	if !isNameStart(name[0]) {
+		return fmt.Errorf("name %q doesn't start with letter or digit", name)
+	}
+	for i := 1; i < len(name); i++ {
+		c := name[i]
+		if !isNameStart(c) && c != '-' && c != ':' && c != '.' && c != '/' && c != '_' {
+			return fmt.Errorf("invalid character %q of name %q", c, name)
+		}
+	}
+	return nil
+}
+
+func (r Record) numValues() int {
+	var n int
+	if r.Value != nil {
+		n++
+	}
+	if r.StringValue != nil {
+		n++
+	}
+	if r.BoolValue != nil {
+		n++
+	}
+	if r.DataValue != nil {
+		n++
+	}
+	return n
+}
+
+// applyBase updates the base fields of base by the base fields set in the record r.
+func applyBase(base *Record, r Record) {
+	if r.BaseName != "" {
+		base.BaseName = r.BaseName
+	}
+	if r.BaseTime != 0 {
+		base.BaseTime = r.BaseTime
+	}
+	if r.BaseUnit != "" {
+		base.BaseUnit = r.BaseUnit
+	}
+	if r.BaseValue != 0 {
+		base.BaseValue = r.BaseValue
+	}
+	if r.BaseSum != 0 {
+		base.BaseSum = r.BaseSum
+	}
+	if r.BaseVersion != 0 {
+		base.BaseVersion = r.BaseVersion
+	}
+}
+
+// Resolve returns the resolved records (RFC 8428 section 4.6): the base fields are applied to the records,
+// so each record contains its full name, unit, value, sum and the absolute time. The times relative to the current
+// time are resolved against now. The BaseVersion is kept only when it differs from DefaultBaseVersion.
+// It returns ErrInvalidPack when the resolved name is not valid or the record contains more than one value.
+func (p Pack) Resolve(now time.Time) (Pack, error) {
+	nowSec := float64(now.UnixNano()) / float64(time.Second)
+	resolved := make(Pack, 0, len(p))
+	var base Record
+	for i, r := range p {
+		applyBase(&base, r)
+		res := Record{
+			Name:        base.BaseName + r.Name,
+			Unit:        r.Unit,
+			Time:        base.BaseTime + r.Time,
+			UpdateTime:  r.UpdateTime,
+			StringValue: r.StringValue,
+			BoolValue:   r.BoolValue,
+			DataValue:   r.DataValue,
+		}
+		if base.BaseVersion != DefaultBaseVersion {
+			res.BaseVersion = base.BaseVersion
+		}
+		if res.Unit == "" {
+			res.Unit = base.BaseUnit
+		}
+		if res.Time < relativeTimeThreshold {
+			res.Time += nowSec
+		}
+		if r.Value != nil {
+			v := base.BaseValue + *r.Value
+			res.Value = &v
+		}
+		if r.Sum != nil {
+			s := base.BaseSum + *r.Sum
+			res.Sum = &s
+		}
+		if err := validateName(res.Name); err != nil {
+			return nil, fmt.Errorf("%w: record %v: %w", ErrInvalidPack, i, err)
+		}
+		if res.numValues() > 1 {
+			return nil, fmt.Errorf("%w: record %v: multiple values", ErrInvalidPack, i)
+		}
+		resolved = append(resolved, res)
+	}
+	return resolved, nil
+}
+
+// Decode decodes the pack of the SenML content format: message.AppSenmlJSON, message.AppSensmlJSON,
+// message.AppSenmlCbor or message.AppSensmlCbor.
+func Decode(contentFormat message.MediaType, data []byte) (Pack, error) {
+	switch contentFormat {
+	case message.AppSenmlJSON, message.AppSensmlJSON:
+		return DecodeJSON(data)
+	case message.AppSenmlCbor, message.AppSensmlCbor:
+		return DecodeCBOR(data)
+	}
+	return nil, fmt.Errorf("%w: %v", message.ErrUnsupportedContentFormat, contentFormat)
+}
+
+// Encode encodes the pack to the SenML content format, see Decode.
+func Encode(contentFormat message.MediaType, p Pack) ([]byte, error) {
+	switch contentFormat {
+	case message.AppSenmlJSON, message.AppSensmlJSON:
+		return EncodeJSON(p)
+	case message.AppSenmlCbor, message.AppSensmlCbor:
+		return EncodeCBOR(p), nil
+	}
+	return nil, fmt.Errorf("%w: %v", message.ErrUnsupportedContentFormat, contentFormat)
+}
+
+func decodeCBORBody(data []byte, v any) error {
+	p, ok := v.(*Pack)
+	if !ok {
+		return fmt.Errorf("cannot decode SenML payload to %T", v)
+	}
+	var err error
+	*p, err = DecodeCBOR(data)
+	return err
+}
+
+func init() {
+	message.RegisterBodyDecoder(message.AppSenmlCbor, decodeCBORBody)
+	message.RegisterBodyDecoder(message.AppSensmlCbor, decodeCBORBody)
+}
@@ -0,0 +1,127 @@
+package senml_test
+
+import (
+	"encoding/hex"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/senml"
+	"github.com/stretchr/testify/require"
+)
+
+func float(v float64) *float64 {
+	return &v
+}
+
+func TestPackResolve(t *testing.T) {
+	// RFC 8428 section 5.1.3
+	p, err := senml.DecodeJSON([]byte(`[
+		{"bn":"urn:dev:ow:10e2073a0108006:","bt":1.276020076001e+09,"bu":"A","bver":5,"n":"voltage","u":"V","v":120.1},
+		{"n":"current","t":-5,"v":1.2},
+		{"n":"current","t":-4,"v":1.3,"x":"ignored"}
+	]`))
+	require.NoError(t, err)
+	require.Len(t, p, 3)
+	require.Equal(t, "urn:dev:ow:10e2073a0108006:", p[0].BaseName)
+	require.Equal(t, 5, p[0].BaseVersion)
+
+	resolved, err := p.Resolve(time.Now())
+	require.NoError(t, err)
+	require.Len(t, resolved, 3)
+	require.Equal(t, "urn:dev:ow:10e2073a0108006:voltage", resolved[0].Name)
+	require.Equal(t, "V", resolved[0].Unit)
+	require.Equal(t, 5, resolved[0].BaseVersion)
+	require.Empty(t, resolved[0].BaseName)
+	require.Equal(t, "urn:dev:ow:10e2073a0108006:current", resolved[1].Name)
+	require.Equal(t, "A", resolved[1].Unit)
+	require.InDelta(t, 1276020071.001, resolved[1].Time, 1e-6)
+	require.InDelta(t, 1.3, *resolved[2].Value, 1e-9)
+
+	// the relative time is resolved against now
+	now := time.Unix(1700000000, 0)
+	resolved, err = senml.Pack{{BaseName: "dev/", BaseValue: 20, Name: "temp", Time: -10, Value: float(1.5)}}.Resolve(now)
+	require.NoError(t, err)
+	require.Equal(t, "dev/temp", resolved[0].Name)
+	require.Equal(t, float64(1699999990), resolved[0].Time)
+	require.Equal(t, 21.5, *resolved[0].Value)
+
+	_, err = senml.Pack{{Name: "/temp", Value: float(1)}}.Resolve(now)
+	require.ErrorIs(t, err, senml.ErrInvalidPack)
+	vs := "on"
+	_, err = senml.Pack{{Name: "temp", Value: float(1), StringValue: &vs}}.Resolve(now)
+	require.ErrorIs(t, err, senml.ErrInvalidPack)
+
+	_, err = senml.DecodeJSON([]byte(`[{"n":"temp","v":1,"new_":1}]`))
+	require.ErrorIs(t, err, senml.ErrInvalidPack)
+	_, err = senml.DecodeJSON([]byte(`[{"n":"temp","v":"1"}]`))
+	require.ErrorIs(t, err, senml.ErrInvalidPack)
+}
+
+func TestEncodeDecode(t *testing.T) {
+	vs := "closed"
+	vb := true
+	p := senml.Pack{
+		{BaseName: "urn:dev:ow:10e2073a01080063:", BaseTime: 1.320067464e+09, BaseUnit: "%RH", Name: "humidity", Value: float(20.5)},
+		{Name: "door", StringValue: &vs, Time: 60},
+		{Name: "alarm", BoolValue: &vb, UpdateTime: 30},
+		{Name: "frame", DataValue: []byte{0xfb, 0xff, 0x00}},
+		{Name: "energy", Unit: "J", Sum: float(-1e+20), Value: float(math.Inf(1))},
+	}
+	for _, contentFormat := range []message.MediaType{message.AppSenmlJSON, message.AppSenmlCbor, message.AppSensmlCbor} {
+		if contentFormat == message.AppSenmlJSON {
+			// JSON cannot represent the infinity
+			p[4].Value = float(-3)
+		}
+		data, err := senml.Encode(contentFormat, p)
+		require.NoError(t, err)
+		decoded, err := senml.Decode(contentFormat, data)
+		require.NoError(t, err)
+		require.Equal(t, p, decoded, contentFormat)
+	}
+
+	data, err := senml.EncodeJSON(p[3:4])
+	require.NoError(t, err)
+	require.JSONEq(t, `[{"n":"frame","vd":"-_8A"}]`, string(data))
+
+	_, err = senml.Encode(message.AppJSON, p)
+	require.ErrorIs(t, err, message.ErrUnsupportedContentFormat)
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	// [{0: "temp", 2: 22.5 (half-precision), "ext": [1, {}], 99: h'00'}]
+	data, err := hex.DecodeString("81a4006474656d7002f94da0636578748201a0186341" + "00")
+	require.NoError(t, err)
+	p, err := senml.DecodeCBOR(data)
+	require.NoError(t, err)
+	require.Equal(t, senml.Pack{{Name: "temp", Value: float(22.5)}}, p)
+
+	// indefinite lengths: [_ {_ 0: (_ "te", "mp"), 6: -5}]
+	data, err = hex.DecodeString("9fbf007f627465626d70ff0624ffff")
+	require.NoError(t, err)
+	p, err = senml.DecodeCBOR(data)
+	require.NoError(t, err)
+	require.Equal(t, senml.Pack{{Name: "temp", Time: -5}}, p)
+
+	var decoded senml.Pack
+	err = message.DecodeBody(message.AppSenmlCbor, senml.EncodeCBOR(p), &decoded)
+	require.NoError(t, err)
+	require.Equal(t, p, decoded)
+
+	for _, invalid := range []string{
+		"",
+		"a0",             // map instead of array
+		"81a1006474",     // truncated text
+		"81a16378785f01", // must-understand label "xx_"
+		"81a10064",       // name is not text
+		"8100",           // record is not map
+		"80ff",           // trailing data
+		"9f",             // missing break
+	} {
+		data, err = hex.DecodeString(invalid)
+		require.NoError(t, err)
+		_, err = senml.DecodeCBOR(data)
+		require.ErrorIs(t, err, senml.ErrInvalidPack, invalid)
+	}
+}
@@ -0,0 +1,124 @@
+package message
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	// ErrStreamSeekBehindWindow is returned by StreamBody.Seek when the requested offset was already discarded.
+	ErrStreamSeekBehindWindow = errors.New("cannot seek behind the buffered window of the stream")
+	// ErrStreamSizeUnknown is returned by StreamBody.Seek relative to the end, because the size of the stream is not known.
+	ErrStreamSizeUnknown = errors.New("size of the stream is unknown")
+)
+
+// StreamBody adapts io.Reader of unknown size to io.ReadSeeker, so it can be set as the body of the response,
+// e.g. w.SetResponse(codes.Content, message.AppOctets, message.NewStreamBody(r)).
+//
+// The blockwise transfer reads only the requested blocks from the source, so the body is not loaded into memory at once.
+// The Size2 option is omitted because the size is not known and the end of the body is signaled by the block
+// with the M (more) bit set to 0. The last block is shorter than the block size, except when the source ends exactly
+// at the block boundary, then the last block is full. Seeking forward discards the data before the offset of the previous
+// forward seek, so the last sent block can be read again, e.g. for the repeated Block2 request, but seeking back behind
+// it fails with ErrStreamSeekBehindWindow. Seeking relative to the end fails with ErrStreamSizeUnknown.
+//
+// Without the blockwise transfer the whole source is read when the message is encoded.
+type StreamBody struct {
+	r    io.Reader
+	buf  []byte // buffered data of the source, starting at the offset base
+	base int64
+	mark int64 // offset of the last forward seek, the data from it are kept
+	pos  int64
+	err  error // the error returned by the source, io.EOF at the end
+}
+
+// NewStreamBody creates the body which reads the data from r on demand.
+func NewStreamBody(r io.Reader) *StreamBody {
+	return &StreamBody{r: r}
+}
+
+// fill reads the source until the data up to the offset end are buffered or the source returns an error.
+func (s *StreamBody) fill(end int64) {
+	for s.err == nil && s.base+int64(len(s.buf)) < end {
+		chunk := end - s.base - int64(len(s.buf))
+		if chunk < 512 {
+			chunk = 512
+		}
+		l := len(s.buf)
+		if int64(cap(s.buf)-l) < chunk {
+			buf := make([]byte, l, int64(l)+chunk)
+			copy(buf, s.buf)
+			s.buf = buf
+		}
+		n, err := s.r.Read(s.buf[l:cap(s.buf)])
+		s.buf = s.buf[:l+n]
+		s.err = err
+	}
+}
+
+// Read reads the data from the current position.
+func (s *StreamBody) Read(p []byte) (int, error) {
+	if s.pos < s.base {
+		return 0, ErrStreamSeekBehindWindow
+	}
+	s.fill(s.pos + int64(len(p)))
+	off := s.pos - s.base
+	if off >= int64(len(s.buf)) {
+		if s.err == nil {
+			return 0, nil
+		}
+		return 0, s.err
+	}
+	n := copy(p, s.buf[off:])
+	s.pos += int64(n)
+	return n, nil
+}
+
+// Seek sets the position. The forward seek discards the data before the offset of the previous forward seek,
+// so the block read after the previous seek stays buffered.
+func (s *StreamBody) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = s.pos + offset
+	case io.SeekEnd:
+		return 0, ErrStreamSizeUnknown
+	default:
+		return 0, fmt.Errorf("invalid whence %v", whence)
+	}
+	if pos < s.base {
+		return 0, fmt.Errorf("%w: %v < %v", ErrStreamSeekBehindWindow, pos, s.base)
+	}
+	if pos > s.mark {
+		s.discard(s.mark)
+		s.mark = pos
+	}
+	s.pos = pos
+	return pos, nil
+}
+
+// discard drops the buffered data before the offset.
+func (s *StreamBody) discard(offset int64) {
+	n := offset - s.base
+	if n > int64(len(s.buf)) {
+		n = int64(len(s.buf))
+	}
+	s.buf = s.buf[n:]
+	s.base += n
+}
+
+// HasDataAt reports whether the source has the data at the offset. It blocks until the data is read
+// or the source ends. The data is buffered, so the position is not changed.
+func (s *StreamBody) HasDataAt(offset int64) (bool, error) {
+	s.fill(offset + 1)
+	if offset < s.base+int64(len(s.buf)) {
+		return true, nil
+	}
+	if errors.Is(s.err, io.EOF) {
+		return false, nil
+	}
+	return false, s.err
+}
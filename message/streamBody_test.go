@@ -0,0 +1,56 @@
+package message
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamBody(t *testing.T) {
+	data := []byte("0123456789abcdef")
+	s := NewStreamBody(io.MultiReader(bytes.NewReader(data)))
+
+	ok, err := s.HasDataAt(15)
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = s.HasDataAt(16)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(s, buf)
+	require.NoError(t, err)
+	require.Equal(t, []byte("0123"), buf)
+
+	// seeking back within the window is allowed
+	off, err := s.Seek(2, io.SeekStart)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), off)
+
+	// seeking forward discards the data before the offset of the previous forward seek
+	_, err = s.Seek(8, io.SeekStart)
+	require.NoError(t, err)
+	_, err = s.Seek(4, io.SeekStart)
+	require.NoError(t, err)
+	_, err = s.Seek(12, io.SeekStart)
+	require.NoError(t, err)
+	_, err = s.Seek(4, io.SeekStart)
+	require.ErrorIs(t, err, ErrStreamSeekBehindWindow)
+
+	// the previous block can be read again
+	_, err = s.Seek(8, io.SeekStart)
+	require.NoError(t, err)
+	_, err = io.ReadFull(s, buf)
+	require.NoError(t, err)
+	require.Equal(t, []byte("89ab"), buf)
+
+	_, err = s.Seek(0, io.SeekEnd)
+	require.ErrorIs(t, err, ErrStreamSizeUnknown)
+	_, err = s.Seek(8, io.SeekStart)
+	require.NoError(t, err)
+	rest, err := io.ReadAll(s)
+	require.NoError(t, err)
+	require.Equal(t, []byte("89abcdef"), rest)
+}
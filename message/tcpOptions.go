@@ -69,7 +69,7 @@ var TCPSignalPingPongOptionDefs = map[OptionID]OptionDef{
 }
 
 var TCPSignalReleaseOptionDefs = map[OptionID]OptionDef{
-	TCPAlternativeAddress: {ValueFormat: ValueString, MinLen: 1, MaxLen: 255},
+	TCPAlternativeAddress: {ValueFormat: ValueString, MinLen: 1, MaxLen: 255, Repeatable: true},
 	TCPHoldOff:            {ValueFormat: ValueUint, MinLen: 0, MaxLen: 3},
 }
 
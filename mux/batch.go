@@ -0,0 +1,249 @@
+package mux
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/message/linkformat"
+	"github.com/plgd-dev/go-coap/v3/message/senml"
+)
+
+// BatchInterface is the value of the if link attribute of the batch collection (CoRE Interfaces, if=core.b).
+const BatchInterface = "core.b"
+
+var (
+	// ErrBatchResourceNotFound is returned by Batch.Write when the pack contains the record of the unknown sub-resource.
+	ErrBatchResourceNotFound = errors.New("batch sub-resource not found")
+	// ErrBatchResourceReadOnly is returned by Batch.Write when the pack contains the record of the sub-resource without the write function.
+	ErrBatchResourceReadOnly = errors.New("batch sub-resource is read-only")
+)
+
+// BatchReadFunc returns the record with the current value of the sub-resource. The name of the record is set by the batch.
+type BatchReadFunc func(r *Message) (senml.Record, error)
+
+// BatchWriteFunc applies the resolved record to the sub-resource, the name of the record is relative to the batch.
+type BatchWriteFunc func(r *Message, rec senml.Record) error
+
+type batchResource struct {
+	read  BatchReadFunc
+	write BatchWriteFunc
+}
+
+// Batch is the collection of the sub-resources read and written at once by the SenML pack, see Router.HandleBatch.
+// The records are named by the names of the sub-resources relative to the base name of the batch, e.g. the base name
+// "urn:dev:ow:10e2073a01080063/" and the sub-resource "temperature". Batch is safe for the concurrent use.
+type Batch struct {
+	baseName string
+
+	mutex     sync.RWMutex
+	names     []string                 // guarded by mutex, in the order of the registration
+	resources map[string]batchResource // guarded by mutex
+}
+
+// NewBatch creates the batch without sub-resources, the base name is set to the first record of the read pack
+// and it is stripped from the names of the written records.
+func NewBatch(baseName string) *Batch {
+	return &Batch{
+		baseName:  baseName,
+		resources: make(map[string]batchResource),
+	}
+}
+
+// Add adds or replaces the sub-resource of the batch. The write can be nil for the read-only sub-resource.
+func (b *Batch) Add(name string, read BatchReadFunc, write BatchWriteFunc) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if _, ok := b.resources[name]; !ok {
+		b.names = append(b.names, name)
+	}
+	b.resources[name] = batchResource{read: read, write: write}
+}
+
+// Remove removes the sub-resource of the batch.
+func (b *Batch) Remove(name string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if _, ok := b.resources[name]; !ok {
+		return
+	}
+	delete(b.resources, name)
+	for i, n := range b.names {
+		if n == name {
+			b.names = append(b.names[:i], b.names[i+1:]...)
+			break
+		}
+	}
+}
+
+// Read aggregates the records of the sub-resources to the pack in the order of the registration. The sub-resources
+// without the read function are omitted.
+func (b *Batch) Read(r *Message) (senml.Pack, error) {
+	b.mutex.RLock()
+	names := append([]string(nil), b.names...)
+	resources := make([]batchResource, 0, len(names))
+	for _, n := range names {
+		resources = append(resources, b.resources[n])
+	}
+	b.mutex.RUnlock()
+
+	p := make(senml.Pack, 0, len(names))
+	for i, res := range resources {
+		if res.read == nil {
+			continue
+		}
+		rec, err := res.read(r)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read batch sub-resource %v: %w", names[i], err)
+		}
+		rec.Name = names[i]
+		p = append(p, rec)
+	}
+	if len(p) > 0 {
+		p[0].BaseName = b.baseName
+	}
+	return p, nil
+}
+
+// Write resolves the pack and dispatches its records to the write functions of the sub-resources. The records
+// are checked before the first write, so nothing is written when the pack contains the record of the unknown
+// or the read-only sub-resource. The errors of the write functions are joined.
+func (b *Batch) Write(r *Message, p senml.Pack) error {
+	resolved, err := p.Resolve(time.Now())
+	if err != nil {
+		return err
+	}
+	b.mutex.RLock()
+	writes := make([]BatchWriteFunc, 0, len(resolved))
+	for i, rec := range resolved {
+		name := strings.TrimPrefix(rec.Name, b.baseName)
+		res, ok := b.resources[name]
+		if !ok {
+			b.mutex.RUnlock()
+			return fmt.Errorf("%w: %v", ErrBatchResourceNotFound, name)
+		}
+		if res.write == nil {
+			b.mutex.RUnlock()
+			return fmt.Errorf("%w: %v", ErrBatchResourceReadOnly, name)
+		}
+		resolved[i].Name = name
+		writes = append(writes, res.write)
+	}
+	b.mutex.RUnlock()
+
+	var errs []error
+	for i, write := range writes {
+		if err := write(r, resolved[i]); err != nil {
+			errs = append(errs, fmt.Errorf("cannot write batch sub-resource %v: %w", resolved[i].Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func isSenMLFormat(contentFormat message.MediaType) bool {
+	switch contentFormat {
+	case message.AppSenmlJSON, message.AppSensmlJSON, message.AppSenmlCbor, message.AppSensmlCbor:
+		return true
+	}
+	return false
+}
+
+// batchHandler serves the batch: GET reads the pack, PUT and POST write the pack.
+type batchHandler struct {
+	batch  *Batch
+	errors ErrorFunc
+}
+
+func (h *batchHandler) setResponse(w ResponseWriter, code codes.Code, contentFormat message.MediaType, payload []byte) {
+	var body io.ReadSeeker
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	if err := w.SetResponse(code, contentFormat, body); err != nil {
+		h.errors(fmt.Errorf("batch handler: cannot set response: %w", err))
+	}
+}
+
+func (h *batchHandler) serveRead(w ResponseWriter, r *Message) {
+	contentFormat := message.AppSenmlJSON
+	if accept, err := r.Accept(); err == nil {
+		if !isSenMLFormat(accept) {
+			h.setResponse(w, codes.NotAcceptable, message.TextPlain, nil)
+			return
+		}
+		contentFormat = accept
+	}
+	p, err := h.batch.Read(r)
+	if err != nil {
+		h.errors(fmt.Errorf("batch handler: %w", err))
+		h.setResponse(w, codes.InternalServerError, message.TextPlain, nil)
+		return
+	}
+	payload, err := senml.Encode(contentFormat, p)
+	if err != nil {
+		h.errors(fmt.Errorf("batch handler: cannot encode pack: %w", err))
+		h.setResponse(w, codes.InternalServerError, message.TextPlain, nil)
+		return
+	}
+	h.setResponse(w, codes.Content, contentFormat, payload)
+}
+
+func (h *batchHandler) serveWrite(w ResponseWriter, r *Message) {
+	contentFormat, err := r.ContentFormat()
+	if err != nil || !isSenMLFormat(contentFormat) {
+		h.setResponse(w, codes.UnsupportedMediaType, message.TextPlain, nil)
+		return
+	}
+	data, err := r.ReadBody()
+	if err != nil {
+		h.setResponse(w, codes.BadRequest, message.TextPlain, nil)
+		return
+	}
+	p, err := senml.Decode(contentFormat, data)
+	if err != nil {
+		h.setResponse(w, codes.BadRequest, message.TextPlain, []byte(err.Error()))
+		return
+	}
+	err = h.batch.Write(r, p)
+	switch {
+	case err == nil:
+		h.setResponse(w, codes.Changed, message.TextPlain, nil)
+	case errors.Is(err, ErrBatchResourceNotFound):
+		h.setResponse(w, codes.NotFound, message.TextPlain, []byte(err.Error()))
+	case errors.Is(err, ErrBatchResourceReadOnly):
+		h.setResponse(w, codes.MethodNotAllowed, message.TextPlain, []byte(err.Error()))
+	case errors.Is(err, senml.ErrInvalidPack):
+		h.setResponse(w, codes.BadRequest, message.TextPlain, []byte(err.Error()))
+	default:
+		h.errors(fmt.Errorf("batch handler: %w", err))
+		h.setResponse(w, codes.InternalServerError, message.TextPlain, nil)
+	}
+}
+
+func (h *batchHandler) ServeCOAP(w ResponseWriter, r *Message) {
+	switch r.Code() {
+	case codes.GET:
+		h.serveRead(w, r)
+	case codes.PUT, codes.POST:
+		h.serveWrite(w, r)
+	default:
+		h.setResponse(w, codes.MethodNotAllowed, message.TextPlain, nil)
+	}
+}
+
+// HandleBatch adds the handler of the batch collection to the Router for pattern (CoRE Interfaces, if=core.b).
+// GET returns the SenML pack of the sub-resources in the format requested by the Accept option, application/senml+json
+// by default. PUT and POST apply the SenML pack of the body by Batch.Write and return 2.04 (Changed), 4.04 (Not Found)
+// for the unknown sub-resource, 4.05 (Method Not Allowed) for the read-only sub-resource, 4.00 (Bad Request) for the pack
+// which cannot be decoded or resolved or 5.00 (Internal Server Error) when a write function fails.
+// The route is announced by /.well-known/core with if=core.b and the attributes attrs.
+func (r *Router) HandleBatch(pattern string, b *Batch, attrs ...linkformat.Param) error {
+	attrs = append([]linkformat.Param{{Key: "if", Value: BatchInterface, HasValue: true}}, attrs...)
+	return r.handle(pattern, &batchHandler{batch: b, errors: r.errors}, attrs)
+}
@@ -0,0 +1,133 @@
+package mux_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+	"github.com/plgd-dev/go-coap/v3/message/senml"
+	"github.com/plgd-dev/go-coap/v3/mux"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingResponseWriter struct {
+	mux.ResponseWriter
+	code          codes.Code
+	contentFormat message.MediaType
+	body          []byte
+}
+
+func (w *recordingResponseWriter) SetResponse(code codes.Code, contentFormat message.MediaType, d io.ReadSeeker, _ ...message.Option) error {
+	w.code = code
+	w.contentFormat = contentFormat
+	w.body = nil
+	if d != nil {
+		body, err := io.ReadAll(d)
+		if err != nil {
+			return err
+		}
+		w.body = body
+	}
+	return nil
+}
+
+func TestBatch(t *testing.T) {
+	temperature := 21.5
+	on := false
+	b := mux.NewBatch("urn:dev:ow:10e2073a01080063/")
+	b.Add("temperature", func(*mux.Message) (senml.Record, error) {
+		v := temperature
+		return senml.Record{Unit: "Cel", Value: &v}, nil
+	}, nil)
+	b.Add("switch", func(*mux.Message) (senml.Record, error) {
+		v := on
+		return senml.Record{BoolValue: &v}, nil
+	}, func(_ *mux.Message, rec senml.Record) error {
+		if rec.BoolValue == nil {
+			return errors.New("boolean value expected")
+		}
+		on = *rec.BoolValue
+		return nil
+	})
+
+	router := mux.NewRouter()
+	require.NoError(t, router.HandleBatch("/sensors", b))
+	route := router.GetRoute("/sensors")
+	require.NotNil(t, route)
+	require.Equal(t, mux.BatchInterface, route.Attributes()[0].Value)
+
+	p := pool.New(0, 0)
+	serve := func(code codes.Code, setup func(m *pool.Message)) *recordingResponseWriter {
+		m := p.AcquireMessage(context.Background())
+		defer p.ReleaseMessage(m)
+		m.SetCode(code)
+		m.MustSetPath("/sensors")
+		if setup != nil {
+			setup(m)
+		}
+		w := &recordingResponseWriter{}
+		router.ServeCOAP(w, &mux.Message{Message: m, RouteParams: new(mux.RouteParams)})
+		return w
+	}
+
+	w := serve(codes.GET, func(m *pool.Message) {
+		m.SetAccept(message.AppSenmlCbor)
+	})
+	require.Equal(t, codes.Content, w.code)
+	require.Equal(t, message.AppSenmlCbor, w.contentFormat)
+	pack, err := senml.DecodeCBOR(w.body)
+	require.NoError(t, err)
+	require.Len(t, pack, 2)
+	require.Equal(t, "urn:dev:ow:10e2073a01080063/", pack[0].BaseName)
+	require.Equal(t, "temperature", pack[0].Name)
+	require.Equal(t, temperature, *pack[0].Value)
+	require.Equal(t, "switch", pack[1].Name)
+	require.False(t, *pack[1].BoolValue)
+
+	write := func(payload string) *recordingResponseWriter {
+		return serve(codes.PUT, func(m *pool.Message) {
+			m.SetContentFormat(message.AppSenmlJSON)
+			m.SetBody(bytes.NewReader([]byte(payload)))
+		})
+	}
+	w = write(`[{"bn":"urn:dev:ow:10e2073a01080063/","n":"switch","vb":true}]`)
+	require.Equal(t, codes.Changed, w.code)
+	require.True(t, on)
+
+	w = write(`[{"n":"switch","vb":false},{"n":"missing","v":1}]`)
+	require.Equal(t, codes.NotFound, w.code)
+	require.True(t, on, "nothing is written when the pack contains the unknown sub-resource")
+
+	w = write(`[{"n":"temperature","v":30}]`)
+	require.Equal(t, codes.MethodNotAllowed, w.code)
+
+	w = write(`[{"n":"switch","v":1}]`)
+	require.Equal(t, codes.InternalServerError, w.code)
+	require.Empty(t, w.body)
+
+	w = write(`[{"n":"switch","v":1,"vb":true}]`)
+	require.Equal(t, codes.BadRequest, w.code)
+
+	w = write(`[{"n":`)
+	require.Equal(t, codes.BadRequest, w.code)
+
+	w = serve(codes.GET, func(m *pool.Message) {
+		m.SetAccept(message.TextPlain)
+	})
+	require.Equal(t, codes.NotAcceptable, w.code)
+
+	b.Remove("temperature")
+	w = serve(codes.GET, nil)
+	require.Equal(t, codes.Content, w.code)
+	require.Equal(t, message.AppSenmlJSON, w.contentFormat)
+	pack, err = senml.DecodeJSON(w.body)
+	require.NoError(t, err)
+	require.Len(t, pack, 1)
+	require.Equal(t, "switch", pack[0].Name)
+	require.True(t, *pack[0].BoolValue)
+}
@@ -14,6 +14,14 @@ type Observation = interface {
 	Canceled() bool
 }
 
+// NextObservation is implemented by the observations returned by Observe. Use the type assertion
+// to pull the notifications of the observation created with nil observe function.
+type NextObservation interface {
+	Observation
+	// Next waits for the next notification of the observation.
+	Next(ctx context.Context) (*pool.Message, error)
+}
+
 type Conn interface {
 	// create message from pool
 	AcquireMessage(ctx context.Context) *pool.Message
@@ -0,0 +1,83 @@
+package mux
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+)
+
+// EnableCriticalOptionCheck enables the rejection of the requests carrying an unrecognized critical (odd-numbered)
+// option by 4.02 (Bad Option) before they are dispatched (RFC 7252 section 5.4.1). The critical options defined
+// in message.CoapOptionDefs (the standard options and the options registered by message.RegisterOption) are copied
+// to the router at the moment of the call, the other understood options are registered by HandleCriticalOption.
+// The check is disabled by default.
+func (r *Router) EnableCriticalOptionCheck() {
+	r.m.Lock()
+	defer r.m.Unlock()
+	for id := range message.CoapOptionDefs {
+		if id.IsCritical() {
+			r.criticalOptions[id] = struct{}{}
+		}
+	}
+	r.checkCriticalOptions = true
+}
+
+// HandleCriticalOption registers the critical (odd-numbered) options which are understood by the handlers of the Router,
+// so the requests carrying them are not rejected by EnableCriticalOptionCheck.
+func (r *Router) HandleCriticalOption(ids ...message.OptionID) error {
+	for _, id := range ids {
+		if !id.IsCritical() {
+			return fmt.Errorf("option %v is not critical", id)
+		}
+	}
+	r.m.Lock()
+	defer r.m.Unlock()
+	for _, id := range ids {
+		r.criticalOptions[id] = struct{}{}
+	}
+	return nil
+}
+
+// HandleCriticalOptionRemove removes the critical option registered by HandleCriticalOption or copied
+// by EnableCriticalOptionCheck.
+func (r *Router) HandleCriticalOptionRemove(id message.OptionID) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+	if _, ok := r.criticalOptions[id]; ok {
+		delete(r.criticalOptions, id)
+		return nil
+	}
+	return errors.New("critical option is not registered in")
+}
+
+func isRequestCode(code codes.Code) bool {
+	return code >= codes.GET && code>>5 == 0
+}
+
+// unrecognizedCriticalOption returns the first critical option of the request which is not understood. Must be called with the acquired lock.
+func (r *Router) unrecognizedCriticalOption(req *Message) (message.OptionID, bool) {
+	if !r.checkCriticalOptions || !isRequestCode(req.Code()) {
+		return 0, false
+	}
+	for _, o := range req.Options() {
+		if !o.ID.IsCritical() {
+			continue
+		}
+		if _, ok := r.criticalOptions[o.ID]; ok {
+			continue
+		}
+		return o.ID, true
+	}
+	return 0, false
+}
+
+func (r *Router) rejectBadOption(w ResponseWriter, id message.OptionID) {
+	// the diagnostic payload identifies the rejected option (RFC 7252 section 5.5.2)
+	diagnostic := fmt.Sprintf("unrecognized critical option %v", id)
+	if err := w.SetResponse(codes.BadOption, message.TextPlain, bytes.NewReader([]byte(diagnostic))); err != nil {
+		r.errors(fmt.Errorf("router handler: cannot set response: %w", err))
+	}
+}
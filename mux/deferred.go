@@ -0,0 +1,122 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/message/noresponse"
+)
+
+var (
+	// ErrResponseSent is returned by ResponseContext.Respond when the response was already sent.
+	ErrResponseSent = errors.New("response was already sent")
+	// ErrResponseExpired is returned by ResponseContext.Respond after the expiration of the response context.
+	ErrResponseExpired = errors.New("response context expired")
+)
+
+// ResponseContext sends the response to the request after its handler returned, e.g. when the response depends
+// on the callback of the backend which arrives seconds later (the separate response of RFC 7252 section 5.2.2).
+// It is created by DeferResponse and it is safe for the concurrent use.
+type ResponseContext struct {
+	cc              Conn
+	token           message.Token
+	typ             message.Type
+	noResponseValue *uint32
+	expires         time.Time
+
+	mutex sync.Mutex
+	sent  bool // guarded by mutex
+}
+
+// DeferResponse captures the connection and the token of request r, so the handler can return without the response
+// and the response is sent later by Respond from any goroutine. The Confirmable request is acknowledged by the empty ACK
+// when the handler returns without the response (UDP and DTLS), so the handler must not set the response to w.
+// The response can be sent until expiration elapses, zero means until the connection is closed.
+func DeferResponse(w ResponseWriter, r *Message, expiration time.Duration) *ResponseContext {
+	rc := &ResponseContext{
+		cc:    w.Conn(),
+		token: append(message.Token(nil), r.Token()...),
+		typ:   message.Unset,
+	}
+	// the separate response to the Confirmable request is Confirmable and to the Non-confirmable request is Non-confirmable
+	if typ := r.Type(); typ == message.Confirmable || typ == message.NonConfirmable {
+		rc.typ = typ
+	}
+	if v, err := r.Options().GetUint32(message.NoResponse); err == nil {
+		rc.noResponseValue = &v
+	}
+	if expiration > 0 {
+		rc.expires = time.Now().Add(expiration)
+	}
+	return rc
+}
+
+// Conn returns the connection of the request.
+func (rc *ResponseContext) Conn() Conn {
+	return rc.cc
+}
+
+// Token returns the token of the request, which is set to the response.
+func (rc *ResponseContext) Token() message.Token {
+	return rc.token
+}
+
+// Expires returns the time after which the response cannot be sent, zero means until the connection is closed.
+func (rc *ResponseContext) Expires() time.Time {
+	return rc.expires
+}
+
+// Respond sends the response to the request, the response is sent at most once. The body d is sent by the blockwise
+// transfer when it doesn't fit into one message. The Confirmable response waits for the acknowledgement of the client
+// until the expiration of the response context.
+//
+// It returns ErrResponseSent when the response was already sent, ErrResponseExpired after the expiration
+// and noresponse.ErrMessageNotInterested when the client suppressed the response by the No-Response option.
+func (rc *ResponseContext) Respond(code codes.Code, contentFormat message.MediaType, d io.ReadSeeker, opts ...message.Option) error {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	if rc.sent {
+		return ErrResponseSent
+	}
+	if !rc.expires.IsZero() && !time.Now().Before(rc.expires) {
+		return ErrResponseExpired
+	}
+	if rc.noResponseValue != nil {
+		if err := noresponse.IsNoResponseCode(code, *rc.noResponseValue); err != nil {
+			rc.sent = true
+			return err
+		}
+	}
+	ctx, cancel := rc.context()
+	defer cancel()
+	m := rc.cc.AcquireMessage(ctx)
+	defer rc.cc.ReleaseMessage(m)
+	m.SetCode(code)
+	m.SetToken(rc.token)
+	m.ResetOptionsTo(opts)
+	if d != nil {
+		m.SetContentFormat(contentFormat)
+		m.SetBody(d)
+	}
+	if rc.typ != message.Unset {
+		m.SetType(rc.typ)
+	}
+	rc.sent = true
+	if err := rc.cc.WriteMessage(m); err != nil {
+		return fmt.Errorf("cannot send deferred response: %w", err)
+	}
+	return nil
+}
+
+func (rc *ResponseContext) context() (context.Context, context.CancelFunc) {
+	if rc.expires.IsZero() {
+		return context.WithCancel(rc.cc.Context())
+	}
+	return context.WithDeadline(rc.cc.Context(), rc.expires)
+}
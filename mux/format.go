@@ -0,0 +1,102 @@
+package mux
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"golang.org/x/exp/maps"
+)
+
+// formatHandler dispatches the request by the Accept option to the handler of the content format.
+// It is immutable, the registration replaces the route with the updated copy.
+type formatHandler struct {
+	formats  []message.MediaType // in the order of the registration
+	handlers map[message.MediaType]Handler
+	fallback Handler
+	errors   ErrorFunc
+}
+
+func (f *formatHandler) clone() *formatHandler {
+	return &formatHandler{
+		formats:  append([]message.MediaType(nil), f.formats...),
+		handlers: maps.Clone(f.handlers),
+		fallback: f.fallback,
+		errors:   f.errors,
+	}
+}
+
+func (f *formatHandler) ServeCOAP(w ResponseWriter, r *Message) {
+	accept, err := r.Accept()
+	if err != nil {
+		// without the Accept option the first registered format is served
+		if len(f.formats) > 0 {
+			f.handlers[f.formats[0]].ServeCOAP(w, r)
+			return
+		}
+		f.fallback.ServeCOAP(w, r)
+		return
+	}
+	if h, ok := f.handlers[accept]; ok {
+		h.ServeCOAP(w, r)
+		return
+	}
+	if f.fallback != nil {
+		f.fallback.ServeCOAP(w, r)
+		return
+	}
+	if err := w.SetResponse(codes.NotAcceptable, message.TextPlain, nil); err != nil {
+		f.errors(fmt.Errorf("format handler: cannot set response: %w", err))
+	}
+}
+
+// updateFormatHandler replaces the handler of the pattern by the copy of its format handler modified by update.
+func (r *Router) updateFormatHandler(pattern string, update func(f *formatHandler)) error {
+	pattern = FilterPath(pattern)
+	routeRegex, err := newRouteRegexp(pattern)
+	if err != nil {
+		return err
+	}
+	r.m.Lock()
+	defer r.m.Unlock()
+	route, ok := r.z[pattern]
+	var f *formatHandler
+	if fh, isFormat := route.h.(*formatHandler); ok && isFormat {
+		f = fh.clone()
+	} else {
+		// the handler registered by Handle is replaced
+		f = &formatHandler{handlers: make(map[message.MediaType]Handler), errors: r.errors}
+	}
+	update(f)
+	r.z[pattern] = Route{h: f, pattern: pattern, regexMatcher: routeRegex, attributes: route.attributes}
+	return nil
+}
+
+// HandleFormat adds a handler to the Router for pattern which serves the representation in the content format
+// requested by the Accept option (RFC 7252 section 5.10.4). Multiple formats can be registered for the same pattern,
+// the request without the Accept option is served by the first registered format. When the Accept doesn't match
+// any registered format, the handler set by HandleFormatDefault is called, otherwise 4.06 (Not Acceptable) is returned.
+func (r *Router) HandleFormat(pattern string, contentFormat message.MediaType, handler Handler) error {
+	if handler == nil {
+		return errors.New("nil handler")
+	}
+	return r.updateFormatHandler(pattern, func(f *formatHandler) {
+		if _, ok := f.handlers[contentFormat]; !ok {
+			f.formats = append(f.formats, contentFormat)
+		}
+		f.handlers[contentFormat] = handler
+	})
+}
+
+// HandleFormatDefault sets the fallback handler for pattern, which is called instead of returning 4.06 (Not Acceptable)
+// when the Accept option doesn't match any format registered by HandleFormat, e.g. to transcode the representation
+// on the fly. The handler gets the original request, so it can read the requested format via Accept.
+func (r *Router) HandleFormatDefault(pattern string, handler Handler) error {
+	if handler == nil {
+		return errors.New("nil handler")
+	}
+	return r.updateFormatHandler(pattern, func(f *formatHandler) {
+		f.fallback = handler
+	})
+}
@@ -0,0 +1,64 @@
+package mux
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+)
+
+// normalizeHost lowercases the host, because the host names are case-insensitive (RFC 3986 section 3.2.2).
+func normalizeHost(host string) string {
+	return strings.ToLower(host)
+}
+
+// HandleHost dispatches the requests with the Uri-Host option matching host to handler (e.g. a sub-router),
+// so multiple logical hosts can be served by one listener. The host can contain the port, e.g. "sensor.local:5683",
+// then it matches only the requests with the same Uri-Port; the host without the port matches any port.
+// The host names are compared case-insensitively. The requests without the Uri-Host option or with a host
+// which is not registered are routed by the patterns of the Router, which serves as the default host.
+// The middlewares of the Router are applied also to the requests dispatched to the handler.
+func (r *Router) HandleHost(host string, handler Handler) error {
+	if handler == nil {
+		return errors.New("nil handler")
+	}
+	if host == "" {
+		return errors.New("empty host")
+	}
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.hosts[normalizeHost(host)] = handler
+	return nil
+}
+
+// HandleHostRemove removes the handler of host registered by HandleHost.
+func (r *Router) HandleHostRemove(host string) error {
+	host = normalizeHost(host)
+	r.m.Lock()
+	defer r.m.Unlock()
+	if _, ok := r.hosts[host]; ok {
+		delete(r.hosts, host)
+		return nil
+	}
+	return errors.New("host is not registered in")
+}
+
+// matchHost returns the handler registered for the Uri-Host and Uri-Port of the request. Must be called with the acquired lock.
+func (r *Router) matchHost(req *Message) Handler {
+	if len(r.hosts) == 0 {
+		return nil
+	}
+	host, err := req.Options().GetString(message.URIHost)
+	if err != nil {
+		return nil
+	}
+	host = normalizeHost(host)
+	if port, errP := req.Options().GetUint32(message.URIPort); errP == nil {
+		if h, ok := r.hosts[net.JoinHostPort(host, strconv.FormatUint(uint64(port), 10))]; ok {
+			return h
+		}
+	}
+	return r.hosts[host]
+}
@@ -10,7 +10,17 @@ type RouteParams struct {
 }
 
 // Message contains message with sequence number.
+//
+// The context of the message (Context/SetContext) is derived from the context of the connection, the middlewares
+// can replace it by the context with the request-scoped values, see ContextMiddleware.
 type Message struct {
 	*pool.Message
 	RouteParams *RouteParams
 }
+
+// Interface returns the index of the network interface on which the request was received (IP_PKTINFO),
+// e.g. to scope the response of the multicast server. It returns 0 when the index is not known, e.g. for TCP or
+// when the platform doesn't support the control messages.
+func (r *Message) Interface() int {
+	return r.ControlMessage().GetIfIndex()
+}
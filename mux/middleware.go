@@ -1,5 +1,7 @@
 package mux
 
+import "context"
+
 // MiddlewareFunc is a function which receives an Handler and returns another Handler.
 // Typically, the returned handler is a closure which does something with the ResponseWriter and Message passed
 // to it, and then calls the handler passed as parameter to the MiddlewareFunc.
@@ -14,3 +16,16 @@ func (mw MiddlewareFunc) Middleware(handler Handler) Handler {
 func (r *Router) Use(mwf ...MiddlewareFunc) {
 	r.middlewares = append(r.middlewares, mwf...)
 }
+
+// ContextMiddleware returns the middleware which replaces the context of the request by the context returned by
+// newContext, e.g. to add the trace id or the authenticated principal. The ctx passed to newContext is r.Context(),
+// which is the context of the connection unless it was replaced by the preceding middleware. The handlers and
+// middlewares which follow observe the new context via r.Context() and they should use it for the outgoing requests.
+func ContextMiddleware(newContext func(ctx context.Context, r *Message) context.Context) MiddlewareFunc {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(w ResponseWriter, r *Message) {
+			r.SetContext(newContext(r.Context(), r))
+			next.ServeCOAP(w, r)
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package mux
 
 import (
+	"fmt"
 	"io"
 
 	"github.com/plgd-dev/go-coap/v3/message"
@@ -22,6 +23,22 @@ func ToHandler[C Conn](m Handler) func(w *responsewriter.ResponseWriter[C], r *p
 	}
 }
 
+// SetResponseWithETag sets the response with the ETag option calculated from the body d. When w implements
+// ETagResponseWriter its ETag function is used, otherwise the ETag is calculated via message.GetETag.
+func SetResponseWithETag(w ResponseWriter, code codes.Code, contentFormat message.MediaType, d io.ReadSeeker, opts ...message.Option) error {
+	if ew, ok := w.(ETagResponseWriter); ok {
+		return ew.SetResponseWithETag(code, contentFormat, d, opts...)
+	}
+	etag, err := message.GetETag(d)
+	if err != nil {
+		return fmt.Errorf("cannot calculate etag: %w", err)
+	}
+	if err = w.SetResponse(code, contentFormat, d, opts...); err != nil {
+		return err
+	}
+	return w.Message().SetETag(etag)
+}
+
 type muxResponseWriter[C Conn] struct {
 	w *responsewriter.ResponseWriter[C]
 }
@@ -31,6 +48,16 @@ func (w *muxResponseWriter[C]) SetResponse(code codes.Code, contentFormat messag
 	return w.w.SetResponse(code, contentFormat, d, opts...)
 }
 
+// SetResponseWithETag works as SetResponse, but it also sets the ETag option calculated from the body d.
+func (w *muxResponseWriter[C]) SetResponseWithETag(code codes.Code, contentFormat message.MediaType, d io.ReadSeeker, opts ...message.Option) error {
+	return w.w.SetResponseWithETag(code, contentFormat, d, opts...)
+}
+
+// SetGetETag sets the function used by SetResponseWithETag to calculate the ETag.
+func (w *muxResponseWriter[C]) SetGetETag(getETag message.GetETagFunc) {
+	w.w.SetGetETag(getETag)
+}
+
 // Conn peer connection.
 func (w *muxResponseWriter[C]) Conn() Conn {
 	return w.w.Conn()
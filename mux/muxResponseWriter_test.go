@@ -0,0 +1,30 @@
+package mux_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+	"github.com/plgd-dev/go-coap/v3/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetResponseWithETag(t *testing.T) {
+	p := pool.New(0, 0)
+	// the writer doesn't implement mux.ETagResponseWriter, so the ETag is calculated via message.GetETag
+	w := &notifierResponseWriter{msg: p.AcquireMessage(context.Background())}
+	_, ok := interface{}(w).(mux.ETagResponseWriter)
+	require.False(t, ok)
+	body := []byte("hello world")
+	err := mux.SetResponseWithETag(w, codes.Content, message.TextPlain, bytes.NewReader(body))
+	require.NoError(t, err)
+	require.Equal(t, codes.Content, w.msg.Code())
+	etag, err := w.msg.ETag()
+	require.NoError(t, err)
+	expected, err := message.GetETag(bytes.NewReader(body))
+	require.NoError(t, err)
+	require.Equal(t, expected, etag)
+}
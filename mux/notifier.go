@@ -0,0 +1,127 @@
+package mux
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/message/noresponse"
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+	"github.com/plgd-dev/go-coap/v3/net/observation"
+)
+
+type notifierOptions struct {
+	maxRetries int
+	backoff    time.Duration
+}
+
+// NotifierOption configures the notifier created by NewNotifier.
+type NotifierOption func(*notifierOptions)
+
+// WithNotifyRetry retries the notification which cannot be sent (e.g. the send buffer is full) up to maxRetries times.
+// The first retry waits for backoff and each next retry waits twice as long as the previous one. The retries are stopped
+// when the connection is closed. By default the notification is not retried.
+func WithNotifyRetry(maxRetries int, backoff time.Duration) NotifierOption {
+	return func(o *notifierOptions) {
+		o.maxRetries = maxRetries
+		o.backoff = backoff
+	}
+}
+
+// Notifier sends notifications of one observation. The Observe option of the registration
+// response and of each notification is set from the sequence managed by the notifier.
+// The notifications of the classes suppressed by the No-Response option of the registration (RFC 7967) are not sent.
+type Notifier struct {
+	cc         Conn
+	token      message.Token
+	sequence   *observation.Sequence
+	opts       notifierOptions
+	noResponse *uint32
+}
+
+// NewNotifier creates notifier for the observation registered by request r.
+func NewNotifier(w ResponseWriter, r *Message, opts ...NotifierOption) *Notifier {
+	n := &Notifier{
+		cc:         w.Conn(),
+		token:      r.Token(),
+		sequence:   &observation.Sequence{},
+		noResponse: noResponseValue(r),
+	}
+	for _, o := range opts {
+		o(&n.opts)
+	}
+	return n
+}
+
+// SetResponse sets the registration response with the next sequence number to w.
+func (n *Notifier) SetResponse(w ResponseWriter, code codes.Code, contentFormat message.MediaType, d io.ReadSeeker, opts ...message.Option) error {
+	if err := w.SetResponse(code, contentFormat, d, opts...); err != nil {
+		return err
+	}
+	w.Message().SetObserve(n.sequence.Next())
+	return nil
+}
+
+// Notify sends the notification with the next sequence number to the observer. When the sending fails,
+// the notification is retried according to WithNotifyRetry and the last error is returned. The notification
+// suppressed by the No-Response option of the registration is not sent and noresponse.ErrMessageNotInterested is returned.
+func (n *Notifier) Notify(code codes.Code, contentFormat message.MediaType, d io.ReadSeeker, opts ...message.Option) error {
+	if err := suppressedNotification(n.noResponse, code); err != nil {
+		return err
+	}
+	m := n.cc.AcquireMessage(n.cc.Context())
+	defer n.cc.ReleaseMessage(m)
+	m.SetCode(code)
+	m.SetToken(n.token)
+	m.ResetOptionsTo(opts)
+	if d != nil {
+		m.SetContentFormat(contentFormat)
+		m.SetBody(d)
+	}
+	m.SetObserve(n.sequence.Next())
+	return writeWithRetry(n.cc, m, n.opts)
+}
+
+// noResponseValue returns the value of the No-Response option of the request r, nil when it is not set.
+func noResponseValue(r *Message) *uint32 {
+	v, err := r.Options().GetUint32(message.NoResponse)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+// suppressedNotification returns noresponse.ErrMessageNotInterested when the class of the code is suppressed
+// by the No-Response value of the registration.
+func suppressedNotification(noResponse *uint32, code codes.Code) error {
+	if noResponse == nil {
+		return nil
+	}
+	return noresponse.IsNoResponseCode(code, *noResponse)
+}
+
+// writeWithRetry writes the notification and retries it according to WithNotifyRetry. The body is rewound before each
+// retry, because the failed write could read it.
+func writeWithRetry(cc Conn, m *pool.Message, opts notifierOptions) error {
+	err := cc.WriteMessage(m)
+	backoff := opts.backoff
+	for i := 0; err != nil && i < opts.maxRetries; i++ {
+		t := time.NewTimer(backoff)
+		select {
+		case <-t.C:
+		case <-cc.Context().Done():
+			t.Stop()
+			return err
+		}
+		backoff *= 2
+		if body := m.Body(); body != nil {
+			if _, errS := body.Seek(0, io.SeekStart); errS != nil {
+				return fmt.Errorf("cannot rewind the body of the notification: %w", errS)
+			}
+		}
+		err = cc.WriteMessage(m)
+	}
+	return err
+}
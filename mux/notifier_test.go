@@ -0,0 +1,145 @@
+package mux_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/message/noresponse"
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+	"github.com/plgd-dev/go-coap/v3/mux"
+	"github.com/stretchr/testify/require"
+)
+
+var errWriteMessage = errors.New("buffer is full")
+
+type flakyConn struct {
+	mux.Conn
+	pool     *pool.Pool
+	failures int
+	payloads []string
+}
+
+func (c *flakyConn) Context() context.Context {
+	return context.Background()
+}
+
+func (c *flakyConn) AcquireMessage(ctx context.Context) *pool.Message {
+	return c.pool.AcquireMessage(ctx)
+}
+
+func (c *flakyConn) ReleaseMessage(m *pool.Message) {
+	c.pool.ReleaseMessage(m)
+}
+
+func (c *flakyConn) WriteMessage(m *pool.Message) error {
+	if c.failures > 0 {
+		c.failures--
+		// the failed write consumes the body
+		if m.Body() != nil {
+			_, _ = io.ReadAll(m.Body())
+		}
+		return errWriteMessage
+	}
+	if m.Body() == nil {
+		c.payloads = append(c.payloads, "")
+		return nil
+	}
+	// the body is read from the current offset as by the streaming writer
+	body, err := io.ReadAll(m.Body())
+	if err != nil {
+		return err
+	}
+	c.payloads = append(c.payloads, string(body))
+	return nil
+}
+
+type connResponseWriter struct {
+	mux.ResponseWriter
+	cc mux.Conn
+}
+
+func (w *connResponseWriter) Conn() mux.Conn {
+	return w.cc
+}
+
+func TestNotifierRetry(t *testing.T) {
+	p := pool.New(0, 0)
+	cc := &flakyConn{pool: p}
+	w := &connResponseWriter{cc: cc}
+	r := &mux.Message{Message: p.AcquireMessage(context.Background())}
+	r.SetToken(message.Token("token"))
+
+	n := mux.NewNotifier(w, r)
+	cc.failures = 1
+	err := n.Notify(codes.Content, message.TextPlain, bytes.NewReader([]byte("1")))
+	require.ErrorIs(t, err, errWriteMessage)
+
+	n = mux.NewNotifier(w, r, mux.WithNotifyRetry(2, time.Millisecond))
+	cc.failures = 2
+	err = n.Notify(codes.Content, message.TextPlain, bytes.NewReader([]byte("2")))
+	require.NoError(t, err)
+	require.Equal(t, []string{"2"}, cc.payloads)
+
+	cc.failures = 3
+	err = n.Notify(codes.Content, message.TextPlain, bytes.NewReader([]byte("3")))
+	require.ErrorIs(t, err, errWriteMessage)
+	require.Equal(t, []string{"2"}, cc.payloads)
+}
+
+func (c *flakyConn) AddOnClose(func()) {
+	// the connection is never closed
+}
+
+type notifierResponseWriter struct {
+	mux.ResponseWriter
+	cc  mux.Conn
+	msg *pool.Message
+}
+
+func (w *notifierResponseWriter) SetResponse(code codes.Code, _ message.MediaType, _ io.ReadSeeker, _ ...message.Option) error {
+	w.msg.SetCode(code)
+	return nil
+}
+
+func (w *notifierResponseWriter) Message() *pool.Message {
+	return w.msg
+}
+
+func (w *notifierResponseWriter) Conn() mux.Conn {
+	return w.cc
+}
+
+func TestNotifierNoResponse(t *testing.T) {
+	p := pool.New(0, 0)
+	cc := &flakyConn{pool: p}
+	w := &notifierResponseWriter{cc: cc, msg: p.AcquireMessage(context.Background())}
+	r := &mux.Message{Message: p.AcquireMessage(context.Background())}
+	r.SetToken(message.Token("token"))
+	r.SetObserve(0)
+	// the observer is not interested in the 4.xx and 5.xx notifications
+	r.SetOptionUint32(message.NoResponse, 8|16)
+
+	n := mux.NewNotifier(w, r)
+	err := n.Notify(codes.Content, message.TextPlain, bytes.NewReader([]byte("1")))
+	require.NoError(t, err)
+	err = n.Notify(codes.NotFound, message.TextPlain, bytes.NewReader([]byte("2")))
+	require.ErrorIs(t, err, noresponse.ErrMessageNotInterested)
+	err = n.Notify(codes.InternalServerError, message.TextPlain, bytes.NewReader([]byte("3")))
+	require.ErrorIs(t, err, noresponse.ErrMessageNotInterested)
+	require.Equal(t, []string{"1"}, cc.payloads)
+
+	publisher := mux.NewPublisher()
+	err = publisher.SetResponse(w, r, codes.Content, message.TextPlain, bytes.NewReader([]byte("0")))
+	require.NoError(t, err)
+	err = publisher.Publish(codes.ServiceUnavailable, message.TextPlain, []byte("4"))
+	require.NoError(t, err)
+	err = publisher.Publish(codes.Content, message.TextPlain, []byte("5"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"1", "5"}, cc.payloads)
+}
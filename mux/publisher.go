@@ -0,0 +1,126 @@
+package mux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+	"github.com/plgd-dev/go-coap/v3/net/observation"
+)
+
+// Publisher sends the same notifications to many observers of the resource, e.g. the telemetry broadcast to thousands
+// of subscribers. The options and the payload of the published notification are encoded once and the encoding is shared
+// by the notifications of all observers, which differ only by the token and the message ID (see pool.SharedEncoding).
+// The observers share one sequence of the Observe option. The observers which suppressed the class of the notification
+// by the No-Response option of the registration (RFC 7967) are skipped. Publisher is safe for concurrent use.
+type Publisher struct {
+	sequence observation.Sequence
+	opts     notifierOptions
+
+	mutex     sync.Mutex
+	observers map[publisherObserver]*uint32 // guarded by mutex, the No-Response value of the registration
+}
+
+type publisherObserver struct {
+	cc    Conn
+	token string
+}
+
+// NewPublisher creates publisher without observers, the notifications are retried according to WithNotifyRetry.
+func NewPublisher(opts ...NotifierOption) *Publisher {
+	p := &Publisher{
+		observers: make(map[publisherObserver]*uint32),
+	}
+	for _, o := range opts {
+		o(&p.opts)
+	}
+	return p
+}
+
+// SetResponse sets the registration response with the next sequence number to w and adds the observer of request r,
+// the observer is removed when its connection is closed. The handler should call Remove when the request deregisters
+// the observation (message.ObserveDeregister).
+func (p *Publisher) SetResponse(w ResponseWriter, r *Message, code codes.Code, contentFormat message.MediaType, d io.ReadSeeker, opts ...message.Option) error {
+	if err := w.SetResponse(code, contentFormat, d, opts...); err != nil {
+		return err
+	}
+	w.Message().SetObserve(p.sequence.Next())
+	cc := w.Conn()
+	o := publisherObserver{cc: cc, token: string(r.Token())}
+	p.mutex.Lock()
+	_, registered := p.observers[o]
+	p.observers[o] = noResponseValue(r)
+	p.mutex.Unlock()
+	if !registered {
+		cc.AddOnClose(func() {
+			p.remove(o)
+		})
+	}
+	return nil
+}
+
+// Remove removes the observer of the connection with the token.
+func (p *Publisher) Remove(cc Conn, token message.Token) {
+	p.remove(publisherObserver{cc: cc, token: string(token)})
+}
+
+func (p *Publisher) remove(o publisherObserver) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.observers, o)
+}
+
+// Len returns the number of the observers.
+func (p *Publisher) Len() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return len(p.observers)
+}
+
+// Publish sends the non-confirmable notification with the next sequence number to all observers, except the ones
+// which suppressed its class by the No-Response option. The notification
+// which doesn't fit into one message is sent by the blockwise transfer and it is marshaled for each observer.
+// The errors of the observers which cannot be notified are joined.
+func (p *Publisher) Publish(code codes.Code, contentFormat message.MediaType, payload []byte, opts ...message.Option) error {
+	m := pool.NewMessage(context.Background())
+	m.ResetOptionsTo(opts)
+	if payload != nil {
+		m.SetContentFormat(contentFormat)
+	}
+	m.SetObserve(p.sequence.Next())
+	shared, err := pool.NewSharedEncoding(code, m.Options(), payload)
+	if err != nil {
+		return fmt.Errorf("cannot encode notification: %w", err)
+	}
+
+	p.mutex.Lock()
+	observers := make([]publisherObserver, 0, len(p.observers))
+	for o, noResponse := range p.observers {
+		if suppressedNotification(noResponse, code) == nil {
+			observers = append(observers, o)
+		}
+	}
+	p.mutex.Unlock()
+
+	var errs []error
+	for _, o := range observers {
+		if err := p.notify(o, shared); err != nil {
+			errs = append(errs, fmt.Errorf("cannot notify observer %v: %w", o.cc.RemoteAddr(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Publisher) notify(o publisherObserver, shared *pool.SharedEncoding) error {
+	m := o.cc.AcquireMessage(o.cc.Context())
+	defer o.cc.ReleaseMessage(m)
+	m.SetSharedEncoding(shared)
+	m.SetToken(message.Token(o.token))
+	m.SetType(message.NonConfirmable)
+	return writeWithRetry(o.cc, m, p.opts)
+}
@@ -94,6 +94,10 @@ func newRouteRegexp(path string) (*routeRegexp, error) {
 	if errCompile != nil {
 		return nil, errCompile
 	}
+	foldReg, errCompile := regexp.Compile("(?i)" + pattern.String())
+	if errCompile != nil {
+		return nil, errCompile
+	}
 
 	// Check for capturing groups which used to work in older versions
 	if reg.NumSubexp() != len(idxs)/2 {
@@ -105,6 +109,7 @@ func newRouteRegexp(path string) (*routeRegexp, error) {
 	return &routeRegexp{
 		template: template,
 		regexp:   reg,
+		fold:     foldReg,
 		reverse:  reverse.String(),
 		varsN:    varsN,
 		varsR:    varsR,
@@ -119,6 +124,8 @@ type routeRegexp struct {
 
 	// Expanded regexp.
 	regexp *regexp.Regexp
+	// Expanded regexp which matches case-insensitively.
+	fold *regexp.Regexp
 	// Reverse template.
 	reverse string
 	// Variable names.
@@ -157,8 +164,16 @@ func braceIndices(s string) ([]int, error) {
 	return idxs, nil
 }
 
-func (route *routeRegexp) extractRouteParams(path string, routeParams *RouteParams) {
-	matches := route.regexp.FindStringSubmatchIndex(path)
+// matcher returns the regexp used to match the paths.
+func (route *routeRegexp) matcher(caseInsensitive bool) *regexp.Regexp {
+	if caseInsensitive {
+		return route.fold
+	}
+	return route.regexp
+}
+
+func (route *routeRegexp) extractRouteParams(path string, caseInsensitive bool, routeParams *RouteParams) {
+	matches := route.matcher(caseInsensitive).FindStringSubmatchIndex(path)
 	if len(matches) > 0 {
 		extractVars(path, matches, route.varsN, routeParams.Vars)
 	}
@@ -0,0 +1,77 @@
+package mux
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/message/linkformat"
+)
+
+// RootPath is the path of the root resource. The request without the Uri-Path option and the request of "/" are
+// matched by it.
+const RootPath = "/"
+
+// HandleRoot adds a handler to the Router for the root resource, e.g. RootIndex, RootWellKnownCore or RootNotFound.
+// Without the root handler, the request of the root resource is served by the default handler.
+func (r *Router) HandleRoot(handler Handler) error {
+	return r.Handle(RootPath, handler)
+}
+
+// RootWellKnownCore returns the handler of the root resource which serves the same document as /.well-known/core,
+// because CoAP has no redirection, see EnableWellKnownCore for the options.
+func (r *Router) RootWellKnownCore(opts ...WellKnownCoreOption) Handler {
+	return r.wellKnownCoreHandler(opts)
+}
+
+// RootIndex returns the handler of the root resource which serves the application/link-format document of the top-level
+// resources: the routes with one path segment and the first segments of the longer routes, e.g. </sensors> for
+// /sensors/temperature. The segments of the longer routes are listed without the link attributes. The options filter
+// the routes as for EnableWellKnownCore and the query filtering of RFC 6690 section 4.1 is supported.
+func (r *Router) RootIndex(opts ...WellKnownCoreOption) Handler {
+	var o wellKnownCoreOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return r.linksHandler("root index", func() linkformat.Links {
+		return topLevelLinks(r.wellKnownCoreLinks(o))
+	})
+}
+
+// RootNotFound returns the handler of the root resource which responds 4.04 (Not Found), even when the default handler
+// serves the other paths.
+func (r *Router) RootNotFound() Handler {
+	return HandlerFunc(func(w ResponseWriter, _ *Message) {
+		if err := w.SetResponse(codes.NotFound, message.TextPlain, nil); err != nil {
+			r.errors(fmt.Errorf("root handler: cannot set response: %w", err))
+		}
+	})
+}
+
+func topLevelLinks(links linkformat.Links) linkformat.Links {
+	topLevel := make(map[string]linkformat.Link, len(links))
+	for _, link := range links {
+		if link.Target == RootPath || !strings.HasPrefix(link.Target, "/") {
+			continue
+		}
+		segment, _, nested := strings.Cut(link.Target[1:], "/")
+		target := "/" + segment
+		if _, ok := topLevel[target]; ok && nested {
+			continue
+		}
+		if nested {
+			link = linkformat.Link{Target: target}
+		}
+		topLevel[target] = link
+	}
+	res := make(linkformat.Links, 0, len(topLevel))
+	for _, link := range topLevel {
+		res = append(res, link)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Target < res[j].Target
+	})
+	return res
+}
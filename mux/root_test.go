@@ -0,0 +1,66 @@
+package mux_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/message/linkformat"
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+	"github.com/plgd-dev/go-coap/v3/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouterRoot(t *testing.T) {
+	router := mux.NewRouter()
+	h := mux.HandlerFunc(func(mux.ResponseWriter, *mux.Message) {})
+	require.NoError(t, router.HandleWithAttributes("/sensors", h, linkformat.Param{Key: "rt", Value: "collection", HasValue: true}))
+	require.NoError(t, router.HandleWithAttributes("/sensors/temperature", h, linkformat.Param{Key: "rt", Value: "temperature", HasValue: true}))
+	require.NoError(t, router.Handle("/actuators/light", h))
+	require.NoError(t, router.EnableWellKnownCore())
+
+	p := pool.New(0, 0)
+	serve := func(path string, queries ...string) *recordingResponseWriter {
+		m := p.AcquireMessage(context.Background())
+		defer p.ReleaseMessage(m)
+		m.SetCode(codes.GET)
+		if path != "" {
+			m.MustSetPath(path)
+		}
+		for _, q := range queries {
+			m.AddQuery(q)
+		}
+		w := &recordingResponseWriter{}
+		router.ServeCOAP(w, &mux.Message{Message: m, RouteParams: new(mux.RouteParams)})
+		return w
+	}
+
+	w := serve("")
+	require.Equal(t, codes.NotFound, w.code)
+
+	require.NoError(t, router.HandleRoot(router.RootIndex()))
+	for _, path := range []string{"", "/"} {
+		w = serve(path)
+		require.Equal(t, codes.Content, w.code)
+		require.Equal(t, message.AppLinkFormat, w.contentFormat)
+		require.Equal(t, `</actuators>,</sensors>;rt="collection"`, string(w.body))
+	}
+	w = serve("", "rt=collection")
+	require.Equal(t, `</sensors>;rt="collection"`, string(w.body))
+
+	require.NoError(t, router.HandleRoot(router.RootWellKnownCore()))
+	w = serve("")
+	require.Equal(t, codes.Content, w.code)
+	wellKnownCore := serve(mux.WellKnownCorePath)
+	require.Equal(t, string(wellKnownCore.body), string(w.body))
+
+	router.DefaultHandleFunc(func(w mux.ResponseWriter, _ *mux.Message) {
+		require.NoError(t, w.SetResponse(codes.Content, message.TextPlain, nil))
+	})
+	require.NoError(t, router.HandleRoot(router.RootNotFound()))
+	w = serve("/")
+	require.Equal(t, codes.NotFound, w.code)
+	w = serve("/unknown")
+	require.Equal(t, codes.Content, w.code)
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/plgd-dev/go-coap/v3/message"
 	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/message/linkformat"
 	"github.com/plgd-dev/go-coap/v3/message/pool"
 	"golang.org/x/exp/maps" // TODO: replace with standard maps package as soon as Go dependency hits 1.21
 )
@@ -19,6 +20,15 @@ type ResponseWriter = interface {
 	Message() *pool.Message
 }
 
+// ETagResponseWriter is implemented by the ResponseWriter which calculates the ETag of the response body,
+// use SetResponseWithETag to support any ResponseWriter.
+type ETagResponseWriter interface {
+	// SetResponseWithETag works as SetResponse, but it also sets the ETag option calculated from the body.
+	SetResponseWithETag(code codes.Code, contentFormat message.MediaType, d io.ReadSeeker, opts ...message.Option) error
+	// SetGetETag sets the function used by SetResponseWithETag to calculate the ETag.
+	SetGetETag(getETag message.GetETagFunc)
+}
+
 type Handler interface {
 	ServeCOAP(w ResponseWriter, r *Message)
 }
@@ -40,20 +50,27 @@ func (f HandlerFunc) ServeCOAP(w ResponseWriter, r *Message) {
 // path name of each incoming request against a list of
 // registered patterns add calls the handler for the pattern
 // with same name.
-// Router is also safe for concurrent access from multiple goroutines.
+// Router is also safe for concurrent access from multiple goroutines, so the routes can be added, replaced
+// and removed while the server is serving the requests.
 type Router struct {
 	middlewares []MiddlewareFunc
 	errors      ErrorFunc
 
-	m              *sync.RWMutex
-	defaultHandler Handler          // guarded by m
-	z              map[string]Route // guarded by m
+	m                    *sync.RWMutex
+	defaultHandler       Handler                       // guarded by m
+	pathRewriter         func(path string) string      // guarded by m
+	caseInsensitive      bool                          // guarded by m
+	z                    map[string]Route              // guarded by m
+	hosts                map[string]Handler            // guarded by m
+	criticalOptions      map[message.OptionID]struct{} // guarded by m
+	checkCriticalOptions bool                          // guarded by m
 }
 
 type Route struct {
 	h            Handler
 	pattern      string
 	regexMatcher *routeRegexp
+	attributes   []linkformat.Param
 }
 
 func (route *Route) GetRouteRegexp() (string, error) {
@@ -71,8 +88,11 @@ func NewRouter() *Router {
 			fmt.Println(err)
 		},
 
-		m: new(sync.RWMutex),
-		z: make(map[string]Route),
+		m:     new(sync.RWMutex),
+		z:     make(map[string]Route),
+		hosts: make(map[string]Handler),
+
+		criticalOptions: make(map[message.OptionID]struct{}),
 	}
 	router.defaultHandler = HandlerFunc(func(w ResponseWriter, _ *Message) {
 		if err := w.SetResponse(codes.NotFound, message.TextPlain, nil); err != nil {
@@ -89,9 +109,28 @@ func (r *Router) SetErrorHandler(h func(error)) {
 	r.errors = h
 }
 
+// SetPathRewriter sets the function which rewrites the path of the request before it is matched against the registered
+// patterns, e.g. to serve /v1/... by the handlers of the current resource tree. The rewriter gets the decoded path
+// joined from the Uri-Path options (e.g. "/v1/light"), the options of the request are not modified. Nil removes the rewriter.
+func (r *Router) SetPathRewriter(rewriter func(path string) string) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.pathRewriter = rewriter
+}
+
+// SetCaseInsensitive enables the case-insensitive matching of the paths, so the request to /Sensor is served
+// by the handler registered at /sensor, e.g. for the clients which don't preserve the case of the path.
+// It deviates from RFC 7252, which compares the Uri-Path options byte-by-byte, so it is disabled by default.
+// The route variables are extracted from the path of the request as it was sent.
+func (r *Router) SetCaseInsensitive(caseInsensitive bool) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	r.caseInsensitive = caseInsensitive
+}
+
 // Does path match pattern?
-func pathMatch(pattern Route, path string) bool {
-	return pattern.regexMatcher.regexp.MatchString(path)
+func pathMatch(pattern Route, path string, caseInsensitive bool) bool {
+	return pattern.regexMatcher.matcher(caseInsensitive).MatchString(path)
 }
 
 // FilterPath checks the unfiltered input path or pattern against a blacklist and transforms them into valid paths
@@ -108,9 +147,10 @@ func FilterPath(unfiltered string) string {
 func (r *Router) Match(path string, routeParams *RouteParams) (matchedRoute *Route, matchedPattern string) {
 	path = FilterPath(path)
 	r.m.RLock()
+	caseInsensitive := r.caseInsensitive
 	n := 0
 	for pattern, route := range r.z {
-		if !pathMatch(route, path) {
+		if !pathMatch(route, path, caseInsensitive) {
 			continue
 		}
 		if matchedRoute == nil || len(pattern) > n {
@@ -131,13 +171,21 @@ func (r *Router) Match(path string, routeParams *RouteParams) (matchedRoute *Rou
 		routeParams.Vars = make(map[string]string)
 	}
 	routeParams.PathTemplate = matchedPattern
-	matchedRoute.regexMatcher.extractRouteParams(path, routeParams)
+	matchedRoute.regexMatcher.extractRouteParams(path, caseInsensitive, routeParams)
 
 	return
 }
 
-// Handle adds a handler to the Router for pattern.
+// Handle adds a handler to the Router for pattern. It is safe to call Handle after the server has started, e.g. to enable
+// the resource by a feature flag. When the pattern is already registered, its route is replaced atomically:
+// the requests matched before the replacement are served by the previous handler and the following requests by the new one,
+// no request observes the pattern as unregistered. The replaced route loses its link attributes, use HandleWithAttributes
+// to keep them.
 func (r *Router) Handle(pattern string, handler Handler) error {
+	return r.handle(pattern, handler, nil)
+}
+
+func (r *Router) handle(pattern string, handler Handler, attrs []linkformat.Param) error {
 	pattern = FilterPath(pattern)
 
 	if handler == nil {
@@ -150,7 +198,7 @@ func (r *Router) Handle(pattern string, handler Handler) error {
 	}
 
 	r.m.Lock()
-	r.z[pattern] = Route{h: handler, pattern: pattern, regexMatcher: routeRegex}
+	r.z[pattern] = Route{h: handler, pattern: pattern, regexMatcher: routeRegex, attributes: attrs}
 	r.m.Unlock()
 	return nil
 }
@@ -176,7 +224,8 @@ func (r *Router) DefaultHandleFunc(handler func(w ResponseWriter, r *Message)) {
 	r.DefaultHandle(HandlerFunc(handler))
 }
 
-// HandleRemove deregistrars the handler specific for pattern from the Router.
+// HandleRemove deregistrars the handler specific for pattern from the Router. As Handle, it can be called while the server
+// is serving the requests, the requests matched before the removal are still served by the removed handler.
 func (r *Router) HandleRemove(pattern string) error {
 	pattern = FilterPath(pattern)
 	r.m.Lock()
@@ -214,12 +263,25 @@ func (r *Router) ServeCOAP(w ResponseWriter, req *Message) {
 	path, err := req.Options().Path()
 	r.m.RLock()
 	defaultHandler := r.defaultHandler
+	pathRewriter := r.pathRewriter
+	h := r.matchHost(req)
+	badOption, unrecognized := r.unrecognizedCriticalOption(req)
 	r.m.RUnlock()
+	if unrecognized {
+		r.rejectBadOption(w, badOption)
+		return
+	}
+	if h != nil {
+		r.serveWithMiddlewares(h, w, req)
+		return
+	}
 	if err != nil && !errors.Is(err, message.ErrOptionNotFound) {
 		defaultHandler.ServeCOAP(w, req)
 		return
 	}
-	var h Handler
+	if pathRewriter != nil {
+		path = pathRewriter(FilterPath(path))
+	}
 	matchedMuxEntry, _ := r.Match(path, req.RouteParams)
 	if matchedMuxEntry == nil {
 		h = defaultHandler
@@ -229,7 +291,10 @@ func (r *Router) ServeCOAP(w ResponseWriter, req *Message) {
 	if h == nil {
 		return
 	}
+	r.serveWithMiddlewares(h, w, req)
+}
 
+func (r *Router) serveWithMiddlewares(h Handler, w ResponseWriter, req *Message) {
 	for i := len(r.middlewares) - 1; i >= 0; i-- {
 		h = r.middlewares[i].Middleware(h)
 	}
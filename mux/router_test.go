@@ -1,9 +1,12 @@
 package mux_test
 
 import (
+	"sync"
 	"testing"
 
+	"github.com/plgd-dev/go-coap/v3/message/linkformat"
 	"github.com/plgd-dev/go-coap/v3/mux"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/exp/maps" // TODO: replace with standard maps package as soon as Go dependency hits 1.21
 )
@@ -156,3 +159,52 @@ func testRoute(t *testing.T, router *mux.Router, test routeTest) {
 		}
 	}
 }
+
+func TestMuxCaseInsensitive(t *testing.T) {
+	r := mux.NewRouter()
+	r.HandleFunc("/sensor/{name}", func(mux.ResponseWriter, *mux.Message) {})
+
+	routeParams := new(mux.RouteParams)
+	route, _ := r.Match("/Sensor/Temp", routeParams)
+	require.Nil(t, route)
+
+	r.SetCaseInsensitive(true)
+	route, pattern := r.Match("/Sensor/Temp", routeParams)
+	require.NotNil(t, route)
+	require.Equal(t, "/sensor/{name}", pattern)
+	require.Equal(t, map[string]string{"name": "Temp"}, routeParams.Vars)
+	routePathRegexp, err := route.GetRouteRegexp()
+	require.NoError(t, err)
+	require.Equal(t, `^/sensor/(?P<v0>[^/]+)$`, routePathRegexp)
+
+	r.SetCaseInsensitive(false)
+	route, _ = r.Match("/SENSOR/temp", new(mux.RouteParams))
+	require.Nil(t, route)
+}
+
+func TestMuxReplaceHandlerConcurrently(t *testing.T) {
+	r := mux.NewRouter()
+	handler := mux.HandlerFunc(func(mux.ResponseWriter, *mux.Message) {})
+	err := r.HandleWithAttributes("/a", handler, linkformat.Param{Key: "rt", Value: "a", HasValue: true})
+	require.NoError(t, err)
+
+	const replacements = 100
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= replacements; i++ {
+			errH := r.Handle("/a", handler)
+			assert.NoError(t, errH)
+		}
+	}()
+	for i := 0; i < replacements; i++ {
+		// the pattern is never observed as unregistered during the replacement
+		route, pattern := r.Match("/a", new(mux.RouteParams))
+		require.NotNil(t, route)
+		require.Equal(t, "/a", pattern)
+	}
+	wg.Wait()
+	require.Len(t, r.GetRoutes(), 1)
+	require.Empty(t, r.GetRoute("/a").Attributes())
+}
@@ -0,0 +1,173 @@
+package mux
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/message/linkformat"
+)
+
+// WellKnownCorePath is the path of the resource discovery (RFC 6690).
+const WellKnownCorePath = "/.well-known/core"
+
+type wellKnownCoreOptions struct {
+	includeTemplates bool
+	filter           func(pattern string, attrs []linkformat.Param) bool
+}
+
+// WellKnownCoreOption configures the handler created by EnableWellKnownCore.
+type WellKnownCoreOption func(*wellKnownCoreOptions)
+
+// WithWellKnownCoreTemplates lists also the routes with variables, e.g. </light/{id}>. By default they are omitted,
+// because the template doesn't identify a resource.
+func WithWellKnownCoreTemplates() WellKnownCoreOption {
+	return func(o *wellKnownCoreOptions) {
+		o.includeTemplates = true
+	}
+}
+
+// WithWellKnownCoreFilter lists only the routes for which the filter returns true, e.g. to hide the internal resources.
+func WithWellKnownCoreFilter(filter func(pattern string, attrs []linkformat.Param) bool) WellKnownCoreOption {
+	return func(o *wellKnownCoreOptions) {
+		o.filter = filter
+	}
+}
+
+// HandleWithAttributes adds a handler to the Router for pattern with the link attributes (e.g. rt, if, ct)
+// announced by the /.well-known/core handler enabled by EnableWellKnownCore.
+func (r *Router) HandleWithAttributes(pattern string, handler Handler, attrs ...linkformat.Param) error {
+	return r.handle(pattern, handler, attrs)
+}
+
+// Attributes returns the link attributes of the route set by HandleWithAttributes.
+func (route *Route) Attributes() []linkformat.Param {
+	return route.attributes
+}
+
+// EnableWellKnownCore registers the handler of /.well-known/core which serves the application/link-format document
+// synthesized from the registered routes. The document reflects the routes at the moment of the request, so the routes
+// registered or removed later are announced correctly. The query filtering of RFC 6690 section 4.1 (e.g. ?rt=temperature)
+// is supported.
+func (r *Router) EnableWellKnownCore(opts ...WellKnownCoreOption) error {
+	return r.Handle(WellKnownCorePath, r.wellKnownCoreHandler(opts))
+}
+
+func (r *Router) wellKnownCoreHandler(opts []WellKnownCoreOption) Handler {
+	var o wellKnownCoreOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return r.linksHandler("well-known core", func() linkformat.Links {
+		return r.wellKnownCoreLinks(o)
+	})
+}
+
+// linksHandler serves the application/link-format document of the links filtered by the query (RFC 6690 section 4.1).
+func (r *Router) linksHandler(name string, getLinks func() linkformat.Links) Handler {
+	return HandlerFunc(func(w ResponseWriter, req *Message) {
+		if req.Code() != codes.GET {
+			if err := w.SetResponse(codes.MethodNotAllowed, message.TextPlain, nil); err != nil {
+				r.errors(fmt.Errorf("%v handler: cannot set response: %w", name, err))
+			}
+			return
+		}
+		queries, err := req.Queries()
+		if err != nil && !errors.Is(err, message.ErrOptionNotFound) {
+			if errS := w.SetResponse(codes.BadOption, message.TextPlain, nil); errS != nil {
+				r.errors(fmt.Errorf("%v handler: cannot set response: %w", name, errS))
+			}
+			return
+		}
+		links := filterLinks(getLinks(), queries)
+		if err := w.SetResponse(codes.Content, message.AppLinkFormat, bytes.NewReader([]byte(links.String()))); err != nil {
+			r.errors(fmt.Errorf("%v handler: cannot set response: %w", name, err))
+		}
+	})
+}
+
+func (r *Router) wellKnownCoreLinks(o wellKnownCoreOptions) linkformat.Links {
+	routes := r.GetRoutes()
+	links := make(linkformat.Links, 0, len(routes))
+	for pattern, route := range routes {
+		if pattern == WellKnownCorePath {
+			continue
+		}
+		if !o.includeTemplates && strings.Contains(pattern, "{") {
+			continue
+		}
+		if o.filter != nil && !o.filter(pattern, route.attributes) {
+			continue
+		}
+		links = append(links, linkformat.Link{Target: pattern, Params: route.attributes})
+	}
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].Target < links[j].Target
+	})
+	return links
+}
+
+// matchValue compares the value by the query value, the trailing '*' matches any suffix.
+func matchValue(value, query string) bool {
+	if strings.HasSuffix(query, "*") {
+		return strings.HasPrefix(value, strings.TrimSuffix(query, "*"))
+	}
+	return value == query
+}
+
+// isListParam reports whether the value of the attribute is a space-separated list of tokens (RFC 6690 section 3).
+func isListParam(key string) bool {
+	switch key {
+	case "rt", "if", "rel":
+		return true
+	}
+	return false
+}
+
+func matchQuery(link linkformat.Link, query string) bool {
+	key, value, ok := strings.Cut(query, "=")
+	if !ok {
+		return link.Has(key)
+	}
+	if key == "href" {
+		return matchValue(link.Target, value)
+	}
+	for _, v := range link.Values(key) {
+		if !isListParam(key) {
+			if matchValue(v, value) {
+				return true
+			}
+			continue
+		}
+		for _, token := range strings.Fields(v) {
+			if matchValue(token, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func filterLinks(links linkformat.Links, queries []string) linkformat.Links {
+	if len(queries) == 0 {
+		return links
+	}
+	filtered := links[:0]
+	for _, link := range links {
+		match := true
+		for _, q := range queries {
+			if !matchQuery(link, q) {
+				match = false
+				break
+			}
+		}
+		if match {
+			filtered = append(filtered, link)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,79 @@
+package blockwise
+
+import "github.com/dsnet/golib/memfile"
+
+// initialBufferSize is the capacity of the buffer allocated for the first received block.
+const initialBufferSize = 1024
+
+// BufferAllocator allocates the buffers used for the reassembly of the received blockwise payloads,
+// e.g. from a memory-mapped region or an arena instead of the heap.
+type BufferAllocator interface {
+	// Acquire returns a buffer with the capacity of at least size bytes. The length of the buffer is ignored.
+	Acquire(size int) []byte
+	// Release returns the buffer obtained by Acquire back to the allocator. The buffer is not used after the call.
+	// The buffer of the reassembled message is released when the handler returns, so the handler must not retain the body.
+	//
+	// Buffers of reassembled messages which are hijacked by the handler (e.g. responses returned by Do)
+	// are not released, because the blockwise doesn't own them anymore.
+	Release(buf []byte)
+}
+
+// growPayload ensures that the payload of the reassembled message can hold size bytes without a reallocation by memfile.
+// Must be called with the acquired messageGuard.
+func (b *BlockWise[C]) growPayload(mg *messageGuard, payloadFile *memfile.File, size int64) *memfile.File {
+	data := payloadFile.Bytes()
+	if b.bufferAllocator == nil || int64(cap(data)) >= size {
+		return payloadFile
+	}
+	newSize := 2 * cap(data)
+	if newSize < initialBufferSize {
+		newSize = initialBufferSize
+	}
+	if int64(newSize) < size {
+		newSize = int(size)
+	}
+	buf := b.bufferAllocator.Acquire(newSize)[:len(data)]
+	copy(buf, data)
+	if mg.buffer != nil {
+		b.bufferAllocator.Release(mg.buffer)
+	}
+	mg.buffer = buf
+	payloadFile = memfile.New(buf)
+	mg.SetBody(payloadFile)
+	return payloadFile
+}
+
+// releaseBuffer returns the reassembly buffer back to the allocator. Must be called with the acquired messageGuard.
+func (b *BlockWise[C]) releaseBuffer(mg *messageGuard) {
+	if mg.buffer == nil {
+		return
+	}
+	buf := mg.buffer
+	mg.buffer = nil
+	mg.SetBody(memfile.New(nil))
+	b.bufferAllocator.Release(buf)
+}
+
+// releaseIdleBuffer releases the buffer of the expired message. When the message is processed at the moment,
+// the buffer is released by releaseGuard at the end of the processing.
+func (b *BlockWise[C]) releaseIdleBuffer(mg *messageGuard) {
+	if mg == nil {
+		return
+	}
+	mg.expired.Store(true)
+	if !mg.TryAcquire(1) {
+		return
+	}
+	if !mg.IsHijacked() {
+		b.releaseBuffer(mg)
+	}
+	mg.Release(1)
+}
+
+// releaseGuard ends the processing of the message and releases the buffer when the message expired meanwhile.
+func (b *BlockWise[C]) releaseGuard(mg *messageGuard) {
+	mg.Release(1)
+	if mg.expired.Load() {
+		b.releaseIdleBuffer(mg)
+	}
+}
@@ -5,16 +5,21 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"io"
+	"sync"
 	"time"
 
 	"github.com/dsnet/golib/memfile"
 	"github.com/plgd-dev/go-coap/v3/message"
 	"github.com/plgd-dev/go-coap/v3/message/codes"
 	"github.com/plgd-dev/go-coap/v3/message/pool"
+	"github.com/plgd-dev/go-coap/v3/net/metrics"
 	"github.com/plgd-dev/go-coap/v3/net/responsewriter"
 	"github.com/plgd-dev/go-coap/v3/pkg/cache"
 	"github.com/plgd-dev/go-coap/v3/pkg/math"
+	coapSync "github.com/plgd-dev/go-coap/v3/pkg/sync"
+	"go.uber.org/atomic"
 	"golang.org/x/sync/semaphore"
 )
 
@@ -46,6 +51,9 @@ const (
 	szxMask = 0x7
 )
 
+// ErrBlockTimeout is returned by BlockWise.Do when the block exchange exceeds the timeout set by WithPerBlockTimeout.
+var ErrBlockTimeout = fmt.Errorf("block exchange timed out: %w", context.DeadlineExceeded)
+
 // SZX enum representation for the size of the block: https://tools.ietf.org/html/rfc7959#section-2.2
 type SZX uint8
 
@@ -138,14 +146,34 @@ type BlockWise[C Client] struct {
 	cc                        C
 	receivingMessagesCache    *cache.Cache[uint64, *messageGuard]
 	sendingMessagesCache      *cache.Cache[uint64, *pool.Message]
+	sentBlock1                *coapSync.Map[uint64, uint32]
 	errors                    func(error)
 	getSentRequestFromOutside func(token message.Token) (*pool.Message, bool)
 	expiration                time.Duration
+	bufferAllocator           BufferAllocator
+	maxRequestBodySize        uint32
+	maxTransfers              uint32
+	perBlockTimeout           time.Duration
+	blockTimers               *coapSync.Map[uint64, *time.Timer]
+	metrics                   metrics.Collector
+	metricsMutex              sync.Mutex
+	reportedTransfers         int  // guarded by metricsMutex
+	released                  bool // guarded by metricsMutex
 }
 
 type messageGuard struct {
 	*pool.Message
 	*semaphore.Weighted
+	// buffer acquired from the BufferAllocator for the reassembled payload
+	buffer []byte
+	// Request-Tag of the first received block
+	requestTag    []byte
+	hasRequestTag bool
+	// upload is set for the reassembly of the request body received by Block1
+	upload bool
+	// expired is set when the message expired from the cache during its processing, so the buffer is released
+	// by the processing.
+	expired atomic.Bool
 }
 
 func newRequestGuard(request *pool.Message) *messageGuard {
@@ -162,17 +190,29 @@ func New[C Client](
 	expiration time.Duration,
 	errors func(error),
 	getSentRequestFromOutside func(token message.Token) (*pool.Message, bool),
+	opts ...Option,
 ) *BlockWise[C] {
 	if getSentRequestFromOutside == nil {
 		getSentRequestFromOutside = func(message.Token) (*pool.Message, bool) { return nil, false }
 	}
+	var cfg options
+	for _, o := range opts {
+		o(&cfg)
+	}
 	return &BlockWise[C]{
 		cc:                        cc,
 		receivingMessagesCache:    cache.NewCache[uint64, *messageGuard](),
 		sendingMessagesCache:      cache.NewCache[uint64, *pool.Message](),
+		sentBlock1:                coapSync.NewMap[uint64, uint32](),
 		errors:                    errors,
 		getSentRequestFromOutside: getSentRequestFromOutside,
 		expiration:                expiration,
+		bufferAllocator:           cfg.bufferAllocator,
+		maxRequestBodySize:        cfg.maxRequestBodySize,
+		maxTransfers:              cfg.maxTransfers,
+		perBlockTimeout:           cfg.perBlockTimeout,
+		blockTimers:               coapSync.NewMap[uint64, *time.Timer](),
+		metrics:                   metrics.OrNil(cfg.metrics),
 	}
 }
 
@@ -187,6 +227,32 @@ func bufferSize(szx SZX, maxMessageSize uint32) int64 {
 func (b *BlockWise[C]) CheckExpirations(now time.Time) {
 	b.receivingMessagesCache.CheckExpirations(now)
 	b.sendingMessagesCache.CheckExpirations(now)
+	b.sentBlock1.Range(func(token uint64, _ uint32) bool {
+		if b.sendingMessagesCache.Load(token) == nil {
+			b.sentBlock1.Delete(token)
+		}
+		return true
+	})
+	b.reportTransfers(b.receivingMessagesCache.Length()+b.sendingMessagesCache.Length(), false)
+}
+
+// reportTransfers reports the change of the number of the transfers in progress to the metrics.
+func (b *BlockWise[C]) reportTransfers(n int, release bool) {
+	b.metricsMutex.Lock()
+	defer b.metricsMutex.Unlock()
+	if b.released {
+		return
+	}
+	b.released = release
+	if delta := n - b.reportedTransfers; delta != 0 {
+		b.reportedTransfers = n
+		b.metrics.AddBlockwiseTransfers(delta)
+	}
+}
+
+// Release reports the transfers in progress as finished to the metrics, it is called when the connection is closed.
+func (b *BlockWise[C]) Release() {
+	b.reportTransfers(0, true)
 }
 
 func (b *BlockWise[C]) cloneMessage(r *pool.Message) *pool.Message {
@@ -202,8 +268,56 @@ func payloadSizeError(err error) error {
 	return fmt.Errorf("cannot get size of payload: %w", err)
 }
 
+type singleBlockKey struct{}
+
+// WithSingleBlock marks the context of the GET or FETCH request with the Block2 option, whose response is returned as the
+// single block requested by the option instead of the reassembled body, e.g. to resume the download from the block N.
+func WithSingleBlock(ctx context.Context) context.Context {
+	return context.WithValue(ctx, singleBlockKey{}, true)
+}
+
+func isSingleBlock(r *pool.Message) bool {
+	v, _ := r.Context().Value(singleBlockKey{}).(bool)
+	return v
+}
+
 // Do sends an coap message and returns an coap response via blockwise transfer.
 func (b *BlockWise[C]) Do(r *pool.Message, maxSzx SZX, maxMessageSize uint32, do func(req *pool.Message) (*pool.Message, error)) (*pool.Message, error) {
+	if b.perBlockTimeout <= 0 || len(r.Token()) == 0 {
+		return b.do(r, maxSzx, maxMessageSize, do)
+	}
+	reqCtx := r.Context()
+	ctx, cancel := context.WithCancelCause(reqCtx)
+	timer := time.AfterFunc(b.perBlockTimeout, func() {
+		cancel(ErrBlockTimeout)
+	})
+	if _, loaded := b.blockTimers.LoadOrStore(r.Token().Hash(), timer); loaded {
+		timer.Stop()
+		cancel(nil)
+		return nil, errors.New("invalid token")
+	}
+	r.SetContext(ctx)
+	defer func() {
+		b.blockTimers.Delete(r.Token().Hash())
+		timer.Stop()
+		cancel(nil)
+		r.SetContext(reqCtx)
+	}()
+	resp, err := b.do(r, maxSzx, maxMessageSize, do)
+	if err != nil && errors.Is(context.Cause(ctx), ErrBlockTimeout) {
+		return nil, fmt.Errorf("%w: %w", ErrBlockTimeout, err)
+	}
+	return resp, err
+}
+
+// resetBlockTimeout restarts the timeout of the block exchange of the transfer started by Do, when the block of its response is received.
+func (b *BlockWise[C]) resetBlockTimeout(token uint64) {
+	if timer, ok := b.blockTimers.Load(token); ok {
+		timer.Reset(b.perBlockTimeout)
+	}
+}
+
+func (b *BlockWise[C]) do(r *pool.Message, maxSzx SZX, maxMessageSize uint32, do func(req *pool.Message) (*pool.Message, error)) (*pool.Message, error) {
 	if maxSzx > SZXBERT {
 		return nil, errors.New("invalid szx")
 	}
@@ -219,7 +333,10 @@ func (b *BlockWise[C]) Do(r *pool.Message, maxSzx SZX, maxMessageSize uint32, do
 	if loaded {
 		return nil, errors.New("invalid token")
 	}
-	defer b.sendingMessagesCache.Delete(r.Token().Hash())
+	defer func() {
+		b.sendingMessagesCache.Delete(r.Token().Hash())
+		b.sentBlock1.Delete(r.Token().Hash())
+	}()
 	if r.Body() == nil {
 		return do(r)
 	}
@@ -249,6 +366,7 @@ func (b *BlockWise[C]) Do(r *pool.Message, maxSzx SZX, maxMessageSize uint32, do
 		return nil, fmt.Errorf("cannot encode block option(%v, %v, %v) to bw request: %w", maxSzx, 0, true, err)
 	}
 	req.SetOptionUint32(message.Block1, block)
+	b.sentBlock1.Store(r.Token().Hash(), block)
 	newBufLen := bufferSize(maxSzx, maxMessageSize)
 	buf := make([]byte, newBufLen)
 	newOff, err := r.Body().Seek(0, io.SeekStart)
@@ -321,6 +439,99 @@ func (b *BlockWise[C]) sendEntityIncomplete(w *responsewriter.ResponseWriter[C],
 	w.SetMessage(sendMessage)
 }
 
+func (b *BlockWise[C]) sendRequestEntityTooLarge(w *responsewriter.ResponseWriter[C], token message.Token) {
+	sendMessage := b.cc.AcquireMessage(w.Message().Context())
+	sendMessage.SetCode(codes.RequestEntityTooLarge)
+	sendMessage.SetToken(token)
+	sendMessage.SetOptionUint32(message.Size1, b.maxRequestBodySize)
+	w.SetMessage(sendMessage)
+}
+
+func (b *BlockWise[C]) sendServiceUnavailable(w *responsewriter.ResponseWriter[C], token message.Token) {
+	sendMessage := b.cc.AcquireMessage(w.Message().Context())
+	sendMessage.SetCode(codes.ServiceUnavailable)
+	sendMessage.SetToken(token)
+	w.SetMessage(sendMessage)
+}
+
+// acceptsTransfer checks the number of the request bodies in the reassembly against the max concurrent transfers.
+func (b *BlockWise[C]) acceptsTransfer() bool {
+	if b.maxTransfers == 0 {
+		return true
+	}
+	var n uint32
+	b.receivingMessagesCache.Range(func(_ uint64, e *cache.Element[*messageGuard]) bool {
+		if mg := e.Data(); mg != nil && mg.upload {
+			n++
+		}
+		return n < b.maxTransfers
+	})
+	return n < b.maxTransfers
+}
+
+// acceptsRequestBody checks the announced size (Size1) and the size of the received blocks against the max request body size.
+func (b *BlockWise[C]) acceptsRequestBody(r *pool.Message, szx SZX, num int64) bool {
+	if b.maxRequestBodySize == 0 {
+		return true
+	}
+	if size1, err := r.GetOptionUint32(message.Size1); err == nil && size1 > b.maxRequestBodySize {
+		return false
+	}
+	bodySize, err := r.BodySize()
+	if err != nil {
+		// error is reported by processing of the payload
+		return true
+	}
+	return num*szx.Size()+bodySize <= int64(b.maxRequestBodySize)
+}
+
+// isUploadProbe returns true for POST/PUT with Block1(NUM=0,M=1) and without payload, by which the client asks
+// whether the server accepts the body of the size announced by Size1 before the body is sent (RFC 7959 section 4).
+func isUploadProbe(r *pool.Message) bool {
+	if r.Code() != codes.POST && r.Code() != codes.PUT {
+		return false
+	}
+	block, err := r.GetOptionUint32(message.Block1)
+	if err != nil {
+		return false
+	}
+	_, num, more, err := DecodeBlockOption(block)
+	if err != nil || num != 0 || !more {
+		return false
+	}
+	bodySize, err := r.BodySize()
+	return err == nil && bodySize == 0
+}
+
+func (b *BlockWise[C]) isSendingUploadProbe(token uint64) bool {
+	v := b.sendingMessagesCache.Load(token)
+	if v == nil {
+		return false
+	}
+	return isUploadProbe(v.Data())
+}
+
+// dropReceivingMessage removes the incomplete reassembled message from the cache.
+func (b *BlockWise[C]) dropReceivingMessage(token uint64) {
+	e, ok := b.receivingMessagesCache.LoadAndDelete(token)
+	if !ok || e.Data() == nil {
+		return
+	}
+	b.releaseIdleBuffer(e.Data())
+}
+
+func getRequestTag(r *pool.Message) ([]byte, bool) {
+	tag, err := r.GetOptionBytes(message.RequestTag)
+	return tag, err == nil
+}
+
+// isSameOperation returns false when the block has the different Request-Tag than the first block,
+// so it belongs to the another blockwise operation of the client (RFC 9175 section 3.3).
+func (g *messageGuard) isSameOperation(r *pool.Message) bool {
+	tag, ok := getRequestTag(r)
+	return ok == g.hasRequestTag && bytes.Equal(tag, g.requestTag)
+}
+
 func wantsToBeReceived(r *pool.Message) bool {
 	hasBlock1 := r.HasOption(message.Block1)
 	hasBlock2 := r.HasOption(message.Block2)
@@ -346,6 +557,17 @@ func (b *BlockWise[C]) getSendingMessageCode(token uint64) (codes.Code, bool) {
 	return v.Data().Code(), true
 }
 
+// dropCanceledSendingMessage removes the message from the sending cache when its context is done.
+func (b *BlockWise[C]) dropCanceledSendingMessage(token uint64) bool {
+	v := b.sendingMessagesCache.Load(token)
+	if v == nil || v.Data().Context().Err() == nil {
+		return false
+	}
+	b.sendingMessagesCache.Delete(token)
+	b.sentBlock1.Delete(token)
+	return true
+}
+
 // Handle middleware which constructs COAP request from blockwise transfer and send COAP response via blockwise.
 func (b *BlockWise[C]) Handle(w *responsewriter.ResponseWriter[C], r *pool.Message, maxSZX SZX, maxMessageSize uint32, next func(w *responsewriter.ResponseWriter[C], r *pool.Message)) {
 	if maxSZX > SZXBERT {
@@ -362,9 +584,15 @@ func (b *BlockWise[C]) Handle(w *responsewriter.ResponseWriter[C], r *pool.Messa
 		return
 	}
 	tokenStr := token.Hash()
+	b.resetBlockTimeout(tokenStr)
 
 	sendingMessageCode, sendingMessageExist := b.getSendingMessageCode(tokenStr)
-	if !sendingMessageExist || wantsToBeReceived(r) {
+	if sendingMessageExist && b.dropCanceledSendingMessage(tokenStr) {
+		// the transfer was canceled by the sender, so the remaining blocks are not sent and the receiver expires its partial state
+		sendingMessageExist = false
+	}
+	// the response to the upload probe is returned to the client, because there is no body to continue with
+	if !sendingMessageExist || wantsToBeReceived(r) || b.isSendingUploadProbe(tokenStr) {
 		err := b.handleReceivedMessage(w, r, maxSZX, maxMessageSize, next)
 		if err != nil {
 			b.sendEntityIncomplete(w, token)
@@ -375,6 +603,7 @@ func (b *BlockWise[C]) Handle(w *responsewriter.ResponseWriter[C], r *pool.Messa
 	more, err := b.continueSendingMessage(w, r, maxSZX, maxMessageSize, sendingMessageCode)
 	if err != nil {
 		b.sendingMessagesCache.Delete(tokenStr)
+		b.sentBlock1.Delete(tokenStr)
 		b.errors(fmt.Errorf("continueSendingMessage(%v): %w", r, err))
 		return
 	}
@@ -436,36 +665,31 @@ func (b *BlockWise[C]) createSendingMessage(sendingMessage *pool.Message, maxSZX
 	sendMessage.ResetOptionsTo(sendingMessage.Options())
 	sendMessage.SetToken(token)
 	sendMessage.SetType(sendingMessage.Type())
-	payloadSize, err := sendingMessage.BodySize()
-	if err != nil {
-		b.cc.ReleaseMessage(sendMessage)
-		return nil, false, payloadSizeError(err)
+	stream, isStream := sendingMessage.Body().(*message.StreamBody)
+	payloadSize := int64(-1)
+	if !isStream {
+		payloadSize, err = sendingMessage.BodySize()
+		if err != nil {
+			b.cc.ReleaseMessage(sendMessage)
+			return nil, false, payloadSizeError(err)
+		}
 	}
 	szx = getSzx(szx, maxSZX)
-	newBufLen := bufferSize(szx, maxMessageSize)
 	off := num * szx.Size()
 	if blockType == message.Block1 {
 		// For block1, we need to skip the already sent bytes.
-		off += newBufLen
-	}
-	offSeek, err := sendingMessage.Body().Seek(off, io.SeekStart)
-	if err != nil {
-		b.cc.ReleaseMessage(sendMessage)
-		return nil, false, fmt.Errorf("cannot seek in response: %w", err)
-	}
-	if off != offSeek {
-		b.cc.ReleaseMessage(sendMessage)
-		return nil, false, fmt.Errorf("cannot seek to requested offset(%v != %v)", off, offSeek)
+		szx, off = b.nextBlock1(token.Hash(), szx, num, maxMessageSize)
 	}
+	newBufLen := bufferSize(szx, maxMessageSize)
 	buf := make([]byte, 1024)
 	if int64(len(buf)) < newBufLen {
 		buf = make([]byte, newBufLen)
 	}
 	buf = buf[:newBufLen]
 
-	readed, err := io.ReadFull(sendingMessage.Body(), buf)
+	offSeek, readed, err := readBlock(sendingMessage.Body(), isStream, off, buf)
 	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
-		if offSeek+int64(readed) == payloadSize {
+		if isStream || offSeek+int64(readed) == payloadSize {
 			err = nil
 		}
 	}
@@ -474,19 +698,25 @@ func (b *BlockWise[C]) createSendingMessage(sendingMessage *pool.Message, maxSZX
 		return nil, false, fmt.Errorf("cannot read response: %w", err)
 	}
 
-	payloadSizeUint32, err := math.SafeCastTo[uint32](payloadSize)
-	if err != nil {
-		b.cc.ReleaseMessage(sendMessage)
-		return nil, false, fmt.Errorf("cannot set payload size: %w", err)
+	if isStream {
+		// the size of the stream is unknown, so the size option is omitted and the end is signaled only by the more bit
+		more, err = stream.HasDataAt(offSeek + int64(readed))
+		if err != nil {
+			b.cc.ReleaseMessage(sendMessage)
+			return nil, false, fmt.Errorf("cannot read response: %w", err)
+		}
+	} else {
+		payloadSizeUint32, errC := math.SafeCastTo[uint32](payloadSize)
+		if errC != nil {
+			b.cc.ReleaseMessage(sendMessage)
+			return nil, false, fmt.Errorf("cannot set payload size: %w", errC)
+		}
+		sendMessage.SetOptionUint32(sizeType, payloadSizeUint32)
+		more = offSeek+int64(readed) != payloadSize
 	}
-	sendMessage.SetOptionUint32(sizeType, payloadSizeUint32)
 
 	buf = buf[:readed]
 	sendMessage.SetBody(bytes.NewReader(buf))
-	more = true
-	if offSeek+int64(readed) == payloadSize {
-		more = false
-	}
 	num = (offSeek) / szx.Size()
 	block, err = EncodeBlockOption(szx, num, more)
 	if err != nil {
@@ -494,9 +724,48 @@ func (b *BlockWise[C]) createSendingMessage(sendingMessage *pool.Message, maxSZX
 		return nil, false, fmt.Errorf("cannot encode block option(%v,%v,%v): %w", szx, num, more, err)
 	}
 	sendMessage.SetOptionUint32(blockType, block)
+	if blockType == message.Block1 {
+		b.sentBlock1.Store(token.Hash(), block)
+	}
 	return sendMessage, more, nil
 }
 
+// readBlock reads the block at the offset off into buf. The body which implements io.ReaderAt (e.g. set by
+// pool.Message.SetBodyReaderAt) is read by ReadAt, so the read doesn't depend on the position of the body.
+func readBlock(body io.ReadSeeker, isStream bool, off int64, buf []byte) (int64, int, error) {
+	if ra, ok := body.(io.ReaderAt); ok && !isStream {
+		n, err := ra.ReadAt(buf, off)
+		return off, n, err
+	}
+	offSeek, err := body.Seek(off, io.SeekStart)
+	if err != nil {
+		return 0, 0, fmt.Errorf("cannot seek in response: %w", err)
+	}
+	if off != offSeek {
+		return 0, 0, fmt.Errorf("cannot seek to requested offset(%v != %v)", off, offSeek)
+	}
+	n, err := io.ReadFull(body, buf)
+	return offSeek, n, err
+}
+
+// nextBlock1 returns the size and the offset of the next Block1 block after the server acknowledged
+// block num with size szx. The server can reply with a smaller SZX than was sent (RFC 7959 section 2.3),
+// then the acknowledged num is still in units of the sent block size, so the offset is computed
+// from the last sent block and the transfer continues with the smaller size.
+func (b *BlockWise[C]) nextBlock1(token uint64, szx SZX, num int64, maxMessageSize uint32) (SZX, int64) {
+	sent, ok := b.sentBlock1.Load(token)
+	if !ok {
+		return szx, num*szx.Size() + bufferSize(szx, maxMessageSize)
+	}
+	sentSzx, sentNum, _, err := DecodeBlockOption(sent)
+	if err != nil {
+		return szx, num*szx.Size() + bufferSize(szx, maxMessageSize)
+	}
+	// the block size can be only decreased during the transfer
+	szx = getSzx(szx, sentSzx)
+	return szx, sentNum*sentSzx.Size() + bufferSize(sentSzx, maxMessageSize)
+}
+
 func (b *BlockWise[C]) continueSendingMessage(w *responsewriter.ResponseWriter[C], r *pool.Message, maxSZX SZX, maxMessageSize uint32, sendingMessageCode codes.Code /* msg *pool.Message*/) (bool, error) {
 	blockType := message.Block2
 	switch sendingMessageCode {
@@ -536,13 +805,27 @@ func isObserveResponse(msg *pool.Message) bool {
 }
 
 func (b *BlockWise[C]) startSendingMessage(w *responsewriter.ResponseWriter[C], maxSZX SZX, maxMessageSize uint32, block uint32) error {
-	payloadSize, err := w.Message().BodySize()
-	if err != nil {
-		return payloadSizeError(err)
-	}
-
-	if payloadSize < maxSZX.Size() {
-		return nil
+	if stream, ok := w.Message().Body().(*message.StreamBody); ok {
+		// the stream shorter than the block is sent in the single message
+		hasBlock, err := stream.HasDataAt(maxSZX.Size() - 1)
+		if err != nil {
+			return payloadSizeError(err)
+		}
+		if !hasBlock {
+			return nil
+		}
+	} else {
+		payloadSize, err := w.Message().BodySize()
+		if err != nil {
+			return payloadSizeError(err)
+		}
+		if payloadSize < maxSZX.Size() {
+			return nil
+		}
+		// the ETag is computed once for the representation before it is cached, so all its blocks have the same ETag
+		if err = setBodyETag(w.Message()); err != nil {
+			return fmt.Errorf("handleSendingMessage: %w", err)
+		}
 	}
 	sendingMessage, _, err := b.createSendingMessage(w.Message(), maxSZX, maxMessageSize, block)
 	if err != nil {
@@ -566,6 +849,28 @@ func (b *BlockWise[C]) startSendingMessage(w *responsewriter.ResponseWriter[C],
 	return nil
 }
 
+// setBodyETag sets the ETag computed from the body to the response sent by the blocks, unless the handler has set it.
+// The blocks of the representation can be fetched by the separate requests, e.g. the notification and the GET of its
+// next blocks (RFC 7959 section 3.4), so the receiver detects by the ETag that the blocks belong to the different
+// representations. The body set by pool.Message.SetBodyReaderAt is not hashed, because it would be read whole
+// for each requested block, so its handler sets the ETag.
+func setBodyETag(r *pool.Message) error {
+	if r.Code() < codes.Created || r.HasOption(message.ETag) {
+		return nil
+	}
+	if _, ok := r.Body().(*io.SectionReader); ok {
+		return nil
+	}
+	h := fnv.New64a()
+	if _, err := r.Body().Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("cannot seek to start of body: %w", err)
+	}
+	if _, err := io.Copy(h, r.Body()); err != nil {
+		return fmt.Errorf("cannot compute ETag of body: %w", err)
+	}
+	return r.SetETag(h.Sum(nil))
+}
+
 func (b *BlockWise[C]) getSentRequest(token message.Token) *pool.Message {
 	data, ok := b.sendingMessagesCache.LoadWithFunc(token.Hash(), func(value *cache.Element[*pool.Message]) *cache.Element[*pool.Message] {
 		if value == nil {
@@ -632,18 +937,14 @@ func (b *BlockWise[C]) getPayloadFromCachedReceivedMessage(r, cachedReceivedMess
 	}
 	rETAG, errETAG := r.GetOptionBytes(message.ETag)
 	cachedReceivedMessageETAG, errCachedReceivedMessageETAG := cachedReceivedMessage.GetOptionBytes(message.ETag)
-	switch {
-	case errETAG == nil && errCachedReceivedMessageETAG != nil:
-		if len(cachedReceivedMessageETAG) > 0 { // make sure there is an etag there
-			return nil, 0, fmt.Errorf("received message doesn't contains ETAG but cached received message contains it(%v)", cachedReceivedMessageETAG)
-		}
-	case errETAG != nil && errCachedReceivedMessageETAG == nil:
-		if len(rETAG) > 0 { // make sure there is an etag there
-			return nil, 0, fmt.Errorf("received message contains ETAG(%v) but cached received message doesn't", rETAG)
-		}
-	case !bytes.Equal(rETAG, cachedReceivedMessageETAG):
-		// ETAG was changed - drop data and set new ETAG
-		cachedReceivedMessage.SetOptionBytes(message.ETag, rETAG)
+	if (errETAG == nil) != (errCachedReceivedMessageETAG == nil) || !bytes.Equal(rETAG, cachedReceivedMessageETAG) {
+		// ETAG was changed, e.g. the resource was changed between the notification and the GET of its next block,
+		// so the blocks belong to the different representations - drop data, set new ETAG and start over from the first block
+		if errETAG == nil {
+			cachedReceivedMessage.SetOptionBytes(message.ETag, rETAG)
+		} else {
+			cachedReceivedMessage.Remove(message.ETag)
+		}
 		if err := payloadFile.Truncate(0); err != nil {
 			return nil, 0, fmt.Errorf("cannot truncate cached request: %w", err)
 		}
@@ -680,7 +981,7 @@ func copyToPayloadFromOffset(r *pool.Message, payloadFile *memfile.File, offset
 	return payloadSize, nil
 }
 
-func (b *BlockWise[C]) getCachedReceivedMessage(mg *messageGuard, r *pool.Message, tokenStr uint64, validUntil time.Time) (*pool.Message, func(), error) {
+func (b *BlockWise[C]) getCachedReceivedMessage(mg *messageGuard, r *pool.Message, tokenStr uint64, validUntil time.Time, upload bool) (*messageGuard, func(), error) {
 	cannotLockError := func(err error) error {
 		return fmt.Errorf("processReceivedMessage: cannot lock message: %w", err)
 	}
@@ -689,12 +990,12 @@ func (b *BlockWise[C]) getCachedReceivedMessage(mg *messageGuard, r *pool.Messag
 		if errA != nil {
 			return nil, nil, cannotLockError(errA)
 		}
-		return mg.Message, func() { mg.Release(1) }, nil
+		return mg, func() { b.releaseGuard(mg) }, nil
 	}
 	closeFnList := []func(){}
 	appendToClose := func(m *messageGuard) {
 		closeFnList = append(closeFnList, func() {
-			m.Release(1)
+			b.releaseGuard(m)
 		})
 	}
 	closeFn := func() {
@@ -706,19 +1007,29 @@ func (b *BlockWise[C]) getCachedReceivedMessage(mg *messageGuard, r *pool.Messag
 	msg.ResetOptionsTo(r.Options())
 	msg.SetToken(r.Token())
 	msg.SetSequence(r.Sequence())
-	msg.SetBody(memfile.New(make([]byte, 0, 1024)))
+	if b.bufferAllocator == nil {
+		msg.SetBody(memfile.New(make([]byte, 0, initialBufferSize)))
+	} else {
+		msg.SetBody(memfile.New(nil))
+	}
 	msg.SetCode(r.Code())
 	mg = newRequestGuard(msg)
+	mg.upload = upload
+	if tag, ok := getRequestTag(r); ok {
+		mg.requestTag = append([]byte{}, tag...)
+		mg.hasRequestTag = true
+	}
 	errA := mg.Acquire(mg.Context(), 1)
 	if errA != nil {
 		return nil, nil, cannotLockError(errA)
 	}
 	appendToClose(mg)
-	element, loaded := b.receivingMessagesCache.LoadOrStore(tokenStr, cache.NewElement(mg, validUntil, func(d *messageGuard) {
+	element, loaded := b.loadOrStoreReceivingMessage(tokenStr, cache.NewElement(mg, validUntil, func(d *messageGuard) {
 		if d == nil {
 			return
 		}
 		b.sendingMessagesCache.Delete(tokenStr)
+		b.releaseIdleBuffer(d)
 	}))
 	// request was already stored in cache, silently
 	if loaded {
@@ -735,7 +1046,43 @@ func (b *BlockWise[C]) getCachedReceivedMessage(mg *messageGuard, r *pool.Messag
 		appendToClose(mg)
 	}
 
-	return mg.Message, closeFn, nil
+	return mg, closeFn, nil
+}
+
+// loadOrStoreReceivingMessage works as LoadOrStore of receivingMessagesCache and releases the buffer of the replaced
+// expired message. The onExpire of the replaced element is not called, because it deletes the entry of
+// sendingMessagesCache for the token which is stored again.
+func (b *BlockWise[C]) loadOrStoreReceivingMessage(token uint64, e *cache.Element[*messageGuard]) (*cache.Element[*messageGuard], bool) {
+	now := time.Now()
+	var actual, expired *cache.Element[*messageGuard]
+	b.receivingMessagesCache.ReplaceWithFunc(token, func(oldValue *cache.Element[*messageGuard], oldLoaded bool) (*cache.Element[*messageGuard], bool) {
+		if oldLoaded {
+			if !oldValue.IsExpired(now) {
+				actual = oldValue
+				return oldValue, false
+			}
+			expired = oldValue
+		}
+		actual = e
+		return e, false
+	})
+	if expired != nil && expired.Data() != nil {
+		b.releaseIdleBuffer(expired.Data())
+	}
+	return actual, actual != e
+}
+
+func (b *BlockWise[C]) acceptUploadProbe(w *responsewriter.ResponseWriter[C], token message.Token, szx SZX) error {
+	respBlock, err := EncodeBlockOption(szx, 0, true)
+	if err != nil {
+		return fmt.Errorf("cannot encode block option(%v,%v,%v): %w", szx, 0, true, err)
+	}
+	sendMessage := b.cc.AcquireMessage(w.Message().Context())
+	sendMessage.SetCode(codes.Continue)
+	sendMessage.SetToken(token)
+	sendMessage.SetOptionUint32(message.Block1, respBlock)
+	w.SetMessage(sendMessage)
+	return nil
 }
 
 //nolint:gocyclo,gocognit
@@ -761,6 +1108,17 @@ func (b *BlockWise[C]) processReceivedMessage(w *responsewriter.ResponseWriter[C
 	if err != nil {
 		return fmt.Errorf("cannot decode block option: %w", err)
 	}
+	if blockType == message.Block1 {
+		if !b.acceptsRequestBody(r, szx, num) {
+			b.dropReceivingMessage(token.Hash())
+			b.sendRequestEntityTooLarge(w, token)
+			return nil
+		}
+		if isUploadProbe(r) && b.receivingMessagesCache.Load(token.Hash()) == nil {
+			// the transfer of the body will be started by the next request, so the reassembly is not created
+			return b.acceptUploadProbe(w, token, getSzx(szx, maxSzx))
+		}
+	}
 	sentRequest := b.getSentRequest(token)
 	if sentRequest != nil {
 		defer b.cc.ReleaseMessage(sentRequest)
@@ -769,6 +1127,11 @@ func (b *BlockWise[C]) processReceivedMessage(w *responsewriter.ResponseWriter[C
 	if blockType == message.Block2 && sentRequest == nil {
 		return errors.New("cannot request body without paired request")
 	}
+	if blockType == message.Block2 && isSingleBlock(sentRequest) {
+		// the block is not reassembled, it is returned to the caller of the request
+		next(w, r)
+		return nil
+	}
 	if isObserveResponse(r) {
 		token, validUntil, err = b.handleObserveResponse(sentRequest)
 		if err != nil {
@@ -781,6 +1144,10 @@ func (b *BlockWise[C]) processReceivedMessage(w *responsewriter.ResponseWriter[C
 	if e := b.receivingMessagesCache.Load(tokenStr); e != nil {
 		cachedReceivedMessageGuard = e.Data()
 	}
+	if cachedReceivedMessageGuard != nil && blockType == message.Block1 && !cachedReceivedMessageGuard.isSameOperation(r) {
+		b.dropReceivingMessage(tokenStr)
+		cachedReceivedMessageGuard = nil
+	}
 	if cachedReceivedMessageGuard == nil {
 		szx = getSzx(szx, maxSzx)
 		// if there is no more then just forward req to next handler
@@ -788,16 +1155,22 @@ func (b *BlockWise[C]) processReceivedMessage(w *responsewriter.ResponseWriter[C
 			next(w, r)
 			return nil
 		}
+		if blockType == message.Block1 && !b.acceptsTransfer() {
+			b.sendServiceUnavailable(w, token)
+			return nil
+		}
 	}
-	cachedReceivedMessage, closeCachedReceivedMessage, err := b.getCachedReceivedMessage(cachedReceivedMessageGuard, r, tokenStr, validUntil)
+	cachedReceivedMessageGuard, closeCachedReceivedMessage, err := b.getCachedReceivedMessage(cachedReceivedMessageGuard, r, tokenStr, validUntil, blockType == message.Block1)
 	if err != nil {
 		return err
 	}
 	defer closeCachedReceivedMessage()
+	cachedReceivedMessage := cachedReceivedMessageGuard.Message
 
 	defer func(err *error) {
 		if *err != nil {
 			b.receivingMessagesCache.Delete(tokenStr)
+			b.releaseBuffer(cachedReceivedMessageGuard)
 		}
 	}(&err)
 	payloadFile, payloadSize, err := b.getPayloadFromCachedReceivedMessage(r, cachedReceivedMessage)
@@ -806,6 +1179,9 @@ func (b *BlockWise[C]) processReceivedMessage(w *responsewriter.ResponseWriter[C
 	}
 	off := num * szx.Size()
 	if off == payloadSize { //nolint:nestif
+		if bodySize, errB := r.BodySize(); errB == nil {
+			payloadFile = b.growPayload(cachedReceivedMessageGuard, payloadFile, off+bodySize)
+		}
 		payloadSize, err = copyToPayloadFromOffset(r, payloadFile, off)
 		if err != nil {
 			return fmt.Errorf("cannot copy data to payload: %w", err)
@@ -814,6 +1190,7 @@ func (b *BlockWise[C]) processReceivedMessage(w *responsewriter.ResponseWriter[C
 			b.receivingMessagesCache.Delete(tokenStr)
 			cachedReceivedMessage.Remove(blockType)
 			cachedReceivedMessage.Remove(sizeType)
+			cachedReceivedMessage.Remove(message.RequestTag)
 			cachedReceivedMessage.SetType(r.Type())
 			if !bytes.Equal(cachedReceivedMessage.Token(), token) {
 				b.sendingMessagesCache.Delete(tokenStr)
@@ -823,6 +1200,9 @@ func (b *BlockWise[C]) processReceivedMessage(w *responsewriter.ResponseWriter[C
 				return fmt.Errorf("cannot seek to start of cachedReceivedMessage request: %w", errS)
 			}
 			next(w, cachedReceivedMessage)
+			if !cachedReceivedMessage.IsHijacked() {
+				b.releaseBuffer(cachedReceivedMessageGuard)
+			}
 			return nil
 		}
 	}
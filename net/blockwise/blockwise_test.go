@@ -3,6 +3,7 @@ package blockwise
 import (
 	"bytes"
 	"context"
+	"hash/fnv"
 	"io"
 	"testing"
 	"time"
@@ -12,6 +13,7 @@ import (
 	"github.com/plgd-dev/go-coap/v3/message/codes"
 	"github.com/plgd-dev/go-coap/v3/message/pool"
 	"github.com/plgd-dev/go-coap/v3/net/responsewriter"
+	"github.com/plgd-dev/go-coap/v3/pkg/cache"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -96,6 +98,13 @@ func makeDo[C Client](t *testing.T, sender, receiver *BlockWise[C], senderMaxSZX
 	}
 }
 
+// bodyETag returns the ETag option set by the blockwise transfer to the response sent by the blocks.
+func bodyETag(payload []byte) message.Options {
+	h := fnv.New64a()
+	_, _ = h.Write(payload)
+	return message.Options{{ID: message.ETag, Value: h.Sum(nil)}}
+}
+
 func TestBlockWiseDo(t *testing.T) {
 	sender := New(newTestClient(), time.Second*3600, func(err error) { t.Log(err) }, nil)
 	receiver := New(newTestClient(), time.Second*3600, func(err error) { t.Log(err) }, nil)
@@ -145,6 +154,7 @@ func TestBlockWiseDo(t *testing.T) {
 				ctx:     context.Background(),
 				token:   []byte{2},
 				code:    codes.Changed,
+				options: bodyETag(make([]byte, 17)),
 				payload: memfile.New(make([]byte, 17)),
 			},
 		},
@@ -182,6 +192,7 @@ func TestBlockWiseDo(t *testing.T) {
 				ctx:     context.Background(),
 				token:   []byte{2},
 				code:    codes.Changed,
+				options: bodyETag(make([]byte, 17)),
 				payload: memfile.New(make([]byte, 17)),
 			},
 		},
@@ -219,6 +230,7 @@ func TestBlockWiseDo(t *testing.T) {
 				ctx:     context.Background(),
 				token:   []byte{'B', 'E', 'R', 'T'},
 				code:    codes.Changed,
+				options: bodyETag(make([]byte, 22222)),
 				payload: memfile.New(make([]byte, 22222)),
 			},
 		},
@@ -257,6 +269,7 @@ func TestBlockWiseDo(t *testing.T) {
 				ctx:     context.Background(),
 				token:   []byte{2},
 				code:    codes.Created,
+				options: bodyETag(make([]byte, 17)),
 				payload: memfile.New(make([]byte, 17)),
 			},
 		},
@@ -292,6 +305,7 @@ func TestBlockWiseDo(t *testing.T) {
 				ctx:     context.Background(),
 				token:   []byte{2},
 				code:    codes.Content,
+				options: bodyETag(make([]byte, 399)),
 				payload: memfile.New(make([]byte, 399)),
 			},
 		},
@@ -615,3 +629,113 @@ func TestBlockWiseWriteTestMessage(t *testing.T) {
 		})
 	}
 }
+
+func TestBlockWiseDoCanceled(t *testing.T) {
+	sender := New(newTestClient(), time.Second*3600, func(err error) { t.Log(err) }, nil)
+	receiver := New(newTestClient(), time.Second*3600, func(err error) { t.Log(err) }, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	token := message.Token([]byte{1})
+	req := toPoolMessage(&testmessage{
+		ctx:     ctx,
+		token:   token,
+		options: message.Options{message.Option{ID: message.URIPath, Value: []byte("abc")}},
+		code:    codes.POST,
+		payload: bytes.NewReader(make([]byte, 1024)),
+	})
+	next := func(*responsewriter.ResponseWriter[*testClient], *pool.Message) {
+		require.Fail(t, "unexpected call of the handler")
+	}
+	_, err := sender.Do(req, SZX16, uint32(SZX16.Size()), func(r *pool.Message) (*pool.Message, error) {
+		receiverResp := responsewriter.New(receiver.cc.AcquireMessage(ctx), receiver.cc)
+		receiver.Handle(receiverResp, r, SZX16, uint32(SZX16.Size()), next)
+		require.Equal(t, codes.Continue, receiverResp.Message().Code())
+		senderResp := responsewriter.New(sender.cc.AcquireMessage(ctx), sender.cc)
+		sender.Handle(senderResp, receiverResp.Message(), SZX16, uint32(SZX16.Size()), next)
+		require.True(t, senderResp.Message().HasOption(message.Block1))
+
+		// the user cancels the transfer in the middle
+		cancel()
+		senderResp = responsewriter.New(sender.cc.AcquireMessage(ctx), sender.cc)
+		sender.Handle(senderResp, receiverResp.Message(), SZX16, uint32(SZX16.Size()), func(*responsewriter.ResponseWriter[*testClient], *pool.Message) {
+			// the late response is processed as the response without the pending request
+		})
+		require.False(t, senderResp.Message().HasOption(message.Block1))
+		require.Nil(t, sender.sendingMessagesCache.Load(token.Hash()))
+		return nil, r.Context().Err()
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	require.Nil(t, sender.sendingMessagesCache.Load(token.Hash()))
+	_, ok := sender.sentBlock1.Load(token.Hash())
+	require.False(t, ok)
+
+	// the receiver drops the partial state after the expiration
+	require.NotNil(t, receiver.receivingMessagesCache.Load(token.Hash()))
+	receiver.CheckExpirations(time.Now().Add(time.Second * 3601))
+	require.Nil(t, receiver.receivingMessagesCache.Load(token.Hash()))
+}
+
+type countingAllocator struct {
+	outstanding int
+}
+
+func (a *countingAllocator) Acquire(size int) []byte {
+	a.outstanding++
+	return make([]byte, 0, size)
+}
+
+func (a *countingAllocator) Release([]byte) {
+	a.outstanding--
+}
+
+func TestBlockWiseReleaseBufferOnExpiration(t *testing.T) {
+	allocator := &countingAllocator{}
+	receiver := New(newTestClient(), time.Second*3600, func(err error) { t.Log(err) }, nil, WithBufferAllocator(allocator))
+	token := message.Token([]byte{1})
+	handleFirstBlock := func() {
+		block, err := EncodeBlockOption(SZX16, 0, true)
+		require.NoError(t, err)
+		req := toPoolMessage(&testmessage{
+			ctx:     context.Background(),
+			token:   token,
+			options: message.Options{message.Option{ID: message.URIPath, Value: []byte("abc")}},
+			code:    codes.POST,
+			payload: bytes.NewReader(make([]byte, SZX16.Size())),
+		})
+		req.SetOptionUint32(message.Block1, block)
+		w := responsewriter.New(receiver.cc.AcquireMessage(context.Background()), receiver.cc)
+		receiver.Handle(w, req, SZX16, uint32(SZX16.Size()), func(*responsewriter.ResponseWriter[*testClient], *pool.Message) {
+			require.Fail(t, "unexpected call of the handler")
+		})
+		require.Equal(t, codes.Continue, w.Message().Code())
+	}
+
+	// released by the expiration of the idle transfer
+	handleFirstBlock()
+	require.Equal(t, 1, allocator.outstanding)
+	receiver.CheckExpirations(time.Now().Add(time.Second * 3601))
+	require.Equal(t, 0, allocator.outstanding)
+
+	// released by the replacement of the expired transfer
+	handleFirstBlock()
+	e := receiver.receivingMessagesCache.Load(token.Hash())
+	require.NotNil(t, e)
+	e.ValidUntil.Store(time.Now().Add(-time.Second))
+	sending := receiver.cc.AcquireMessage(context.Background())
+	receiver.sendingMessagesCache.Store(token.Hash(), cache.NewElement(sending, time.Now().Add(time.Minute), nil))
+	handleFirstBlock()
+	require.Equal(t, 1, allocator.outstanding)
+	// the entry of the token stored again is kept
+	require.NotNil(t, receiver.sendingMessagesCache.Load(token.Hash()))
+	receiver.sendingMessagesCache.Delete(token.Hash())
+
+	// released at the end of the processing when the transfer expires meanwhile
+	e = receiver.receivingMessagesCache.Load(token.Hash())
+	require.NotNil(t, e)
+	mg := e.Data()
+	require.NoError(t, mg.Acquire(context.Background(), 1))
+	receiver.CheckExpirations(time.Now().Add(time.Second * 3601))
+	require.Equal(t, 1, allocator.outstanding)
+	receiver.releaseGuard(mg)
+	require.Equal(t, 0, allocator.outstanding)
+}
@@ -0,0 +1,59 @@
+package blockwise
+
+import (
+	"time"
+
+	"github.com/plgd-dev/go-coap/v3/net/metrics"
+)
+
+type options struct {
+	bufferAllocator    BufferAllocator
+	maxRequestBodySize uint32
+	maxTransfers       uint32
+	perBlockTimeout    time.Duration
+	metrics            metrics.Collector
+}
+
+// Option configures the blockwise.
+type Option func(o *options)
+
+// WithBufferAllocator sets the allocator of the reassembly buffers. When it is nil, the buffers are allocated from the heap.
+func WithBufferAllocator(allocator BufferAllocator) Option {
+	return func(o *options) {
+		o.bufferAllocator = allocator
+	}
+}
+
+// WithMaxRequestBodySize limits the size of the body received by Block1. The request is rejected by 4.13 (Request Entity Too Large)
+// with the limit in the Size1 option as soon as the Size1 of the request or the received blocks exceed it (RFC 7959 section 2.9.3).
+// Zero means no limit.
+func WithMaxRequestBodySize(size uint32) Option {
+	return func(o *options) {
+		o.maxRequestBodySize = size
+	}
+}
+
+// WithMaxConcurrentTransfers limits the number of the request bodies received by Block1 at the same time, which bounds
+// the memory of the reassembly buffers of the connection. The first block of the transfer beyond the limit is rejected
+// by 5.03 (Service Unavailable) until one of the transfers is finished or expires. Zero means no limit.
+func WithMaxConcurrentTransfers(n uint32) Option {
+	return func(o *options) {
+		o.maxTransfers = n
+	}
+}
+
+// WithPerBlockTimeout limits the time of each block exchange of the transfer started by BlockWise.Do: when no block
+// of the response is received in the timeout since the previous one was sent, the transfer fails by ErrBlockTimeout,
+// even if the context of the request is not done yet. Zero means no limit, only the context of the request applies.
+func WithPerBlockTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.perBlockTimeout = d
+	}
+}
+
+// WithMetrics sets the collector of the number of the transfers in progress, which is updated by CheckExpirations.
+func WithMetrics(collector metrics.Collector) Option {
+	return func(o *options) {
+		o.metrics = collector
+	}
+}
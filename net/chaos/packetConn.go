@@ -0,0 +1,222 @@
+// Package chaos provides the datagram transport which injects the packet loss, duplication, reordering and latency,
+// to test the resilience of the applications to the flaky links, e.g. the retransmissions and the retries.
+package chaos
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+type options struct {
+	loss      float64
+	duplicate float64
+	reorder   float64
+	latency   time.Duration
+	jitter    time.Duration
+	seed      int64
+	hasSeed   bool
+}
+
+// Option configures the faults injected by PacketConn.
+type Option func(o *options)
+
+// WithLoss drops the written datagram with the probability p in the range [0, 1].
+func WithLoss(p float64) Option {
+	return func(o *options) {
+		o.loss = p
+	}
+}
+
+// WithDuplication sends the written datagram twice with the probability p in the range [0, 1].
+func WithDuplication(p float64) Option {
+	return func(o *options) {
+		o.duplicate = p
+	}
+}
+
+// WithReordering holds the written datagram back with the probability p in the range [0, 1], the held datagram
+// is sent right after the next written one. At most one datagram is held, so the last datagram of the burst waits
+// for the next write, e.g. for the retransmission.
+func WithReordering(p float64) Option {
+	return func(o *options) {
+		o.reorder = p
+	}
+}
+
+// WithLatency delays each written datagram by the latency plus the random jitter in the range [0, jitter).
+// The jitter can reorder the datagrams written in the interval shorter than the jitter.
+func WithLatency(latency, jitter time.Duration) Option {
+	return func(o *options) {
+		o.latency = latency
+		o.jitter = jitter
+	}
+}
+
+// WithSeed sets the seed of the random faults, so the same sequence of writes gets the same faults, e.g. in CI.
+// By default, the seed is random.
+func WithSeed(seed int64) Option {
+	return func(o *options) {
+		o.seed = seed
+		o.hasSeed = true
+	}
+}
+
+// Stats are the numbers of the datagrams affected by PacketConn.
+type Stats struct {
+	Written    uint32
+	Dropped    uint32
+	Duplicated uint32
+	Reordered  uint32
+}
+
+type datagram struct {
+	data []byte
+	addr net.Addr
+}
+
+// PacketConn decorates the datagram transport and injects the faults to the written datagrams. The received datagrams
+// are not affected, so wrap the transports of both peers to affect both directions. PacketConn is safe for the concurrent use.
+type PacketConn struct {
+	net.PacketConn
+	opts options
+
+	mutex sync.Mutex
+	rand  *rand.Rand // guarded by mutex
+	held  *datagram  // guarded by mutex
+
+	closed     atomic.Bool
+	written    atomic.Uint32
+	dropped    atomic.Uint32
+	duplicated atomic.Uint32
+	reordered  atomic.Uint32
+}
+
+// NewPacketConn wraps the transport c. Without the options no faults are injected.
+func NewPacketConn(c net.PacketConn, opts ...Option) *PacketConn {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if !o.hasSeed {
+		o.seed = time.Now().UnixNano()
+	}
+	return &PacketConn{
+		PacketConn: c,
+		opts:       o,
+		rand:       rand.New(rand.NewSource(o.seed)), //nolint:gosec
+	}
+}
+
+// faults decides the faults of one datagram.
+func (c *PacketConn) faults(d *datagram) (send []*datagram, delay time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	drop := c.rand.Float64() < c.opts.loss
+	duplicate := c.rand.Float64() < c.opts.duplicate
+	reorder := c.rand.Float64() < c.opts.reorder
+	delay = c.opts.latency
+	if c.opts.jitter > 0 {
+		delay += time.Duration(c.rand.Int63n(int64(c.opts.jitter)))
+	}
+	if drop {
+		c.dropped.Inc()
+		return nil, 0
+	}
+	if reorder && c.held == nil {
+		c.reordered.Inc()
+		c.held = d
+		return nil, 0
+	}
+	send = append(send, d)
+	if duplicate {
+		c.duplicated.Inc()
+		send = append(send, d)
+	}
+	if c.held != nil {
+		send = append(send, c.held)
+		c.held = nil
+	}
+	return send, delay
+}
+
+func (c *PacketConn) write(send []*datagram) error {
+	for _, d := range send {
+		if _, err := c.PacketConn.WriteTo(d.data, d.addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteTo writes the datagram with the injected faults. The dropped and the held datagrams are reported as written.
+// The error of the delayed write is not returned, as the error of the datagram lost by the network.
+func (c *PacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	if c.closed.Load() {
+		return 0, net.ErrClosed
+	}
+	c.written.Inc()
+	send, delay := c.faults(&datagram{data: append([]byte(nil), b...), addr: addr})
+	if delay <= 0 {
+		return len(b), c.write(send)
+	}
+	time.AfterFunc(delay, func() {
+		if !c.closed.Load() {
+			_ = c.write(send)
+		}
+	})
+	return len(b), nil
+}
+
+// Close drops the held and the delayed datagrams and closes the transport.
+func (c *PacketConn) Close() error {
+	c.closed.Store(true)
+	c.mutex.Lock()
+	c.held = nil
+	c.mutex.Unlock()
+	return c.PacketConn.Close()
+}
+
+// Stats returns the numbers of the datagrams affected since the creation.
+func (c *PacketConn) Stats() Stats {
+	return Stats{
+		Written:    c.written.Load(),
+		Dropped:    c.dropped.Load(),
+		Duplicated: c.duplicated.Load(),
+		Reordered:  c.reordered.Load(),
+	}
+}
+
+// Dialer creates the transports with the injected faults for udp.Dial, see options.WithPacketDialer.
+// Each dialed transport is the UDP socket bound to the random port wrapped by NewPacketConn with the options.
+type Dialer struct {
+	Options []Option
+
+	mutex sync.Mutex
+	conns []*PacketConn // guarded by mutex
+}
+
+// DialPacket implements udp/client.PacketDialer.
+func (d *Dialer) DialPacket(ctx context.Context, network, _ string) (net.PacketConn, error) {
+	var lc net.ListenConfig
+	c, err := lc.ListenPacket(ctx, network, "")
+	if err != nil {
+		return nil, err
+	}
+	pc := NewPacketConn(c, d.Options...)
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.conns = append(d.conns, pc)
+	return pc, nil
+}
+
+// Conns returns the transports created by DialPacket, e.g. to check their Stats.
+func (d *Dialer) Conns() []*PacketConn {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return append([]*PacketConn(nil), d.conns...)
+}
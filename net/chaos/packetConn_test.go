@@ -0,0 +1,149 @@
+package chaos_test
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/mux"
+	coapNet "github.com/plgd-dev/go-coap/v3/net"
+	"github.com/plgd-dev/go-coap/v3/net/chaos"
+	"github.com/plgd-dev/go-coap/v3/options"
+	"github.com/plgd-dev/go-coap/v3/pkg/runner/periodic"
+	"github.com/plgd-dev/go-coap/v3/udp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPeers(t *testing.T, opts ...chaos.Option) (*chaos.PacketConn, net.PacketConn) {
+	sender, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	receiver, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	c := chaos.NewPacketConn(sender, opts...)
+	t.Cleanup(func() {
+		_ = c.Close()
+		_ = receiver.Close()
+	})
+	return c, receiver
+}
+
+func receive(t *testing.T, receiver net.PacketConn, timeout time.Duration) []string {
+	var received []string
+	buf := make([]byte, 64)
+	for {
+		require.NoError(t, receiver.SetReadDeadline(time.Now().Add(timeout)))
+		n, _, err := receiver.ReadFrom(buf)
+		if err != nil {
+			return received
+		}
+		received = append(received, string(buf[:n]))
+	}
+}
+
+func send(t *testing.T, c *chaos.PacketConn, receiver net.PacketConn, datagrams ...string) {
+	for _, d := range datagrams {
+		n, err := c.WriteTo([]byte(d), receiver.LocalAddr())
+		require.NoError(t, err)
+		require.Equal(t, len(d), n)
+	}
+}
+
+func TestPacketConn(t *testing.T) {
+	c, receiver := newPeers(t)
+	send(t, c, receiver, "a", "b")
+	require.Equal(t, []string{"a", "b"}, receive(t, receiver, time.Millisecond*100))
+
+	c, receiver = newPeers(t, chaos.WithLoss(1))
+	send(t, c, receiver, "a", "b")
+	require.Empty(t, receive(t, receiver, time.Millisecond*100))
+	require.Equal(t, chaos.Stats{Written: 2, Dropped: 2}, c.Stats())
+
+	c, receiver = newPeers(t, chaos.WithDuplication(1))
+	send(t, c, receiver, "a")
+	require.Equal(t, []string{"a", "a"}, receive(t, receiver, time.Millisecond*100))
+
+	c, receiver = newPeers(t, chaos.WithReordering(1))
+	send(t, c, receiver, "a", "b", "c")
+	// "c" is held until the next write
+	require.Equal(t, []string{"b", "a"}, receive(t, receiver, time.Millisecond*100))
+	require.Equal(t, chaos.Stats{Written: 3, Reordered: 2}, c.Stats())
+
+	c, receiver = newPeers(t, chaos.WithLatency(time.Millisecond*200, 0))
+	start := time.Now()
+	send(t, c, receiver, "a")
+	require.Equal(t, []string{"a"}, receive(t, receiver, time.Millisecond*500))
+	require.GreaterOrEqual(t, time.Since(start), time.Millisecond*200)
+}
+
+func TestPacketConnSeed(t *testing.T) {
+	datagrams := []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}
+	var results [][]string
+	for i := 0; i < 2; i++ {
+		c, receiver := newPeers(t, chaos.WithLoss(0.5), chaos.WithSeed(42))
+		send(t, c, receiver, datagrams...)
+		results = append(results, receive(t, receiver, time.Millisecond*100))
+	}
+	// the same seed drops the same datagrams
+	require.Equal(t, results[0], results[1])
+	require.Less(t, len(results[0]), len(datagrams))
+}
+
+func TestDialerRetransmission(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp4", "127.0.0.1:")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	m := mux.NewRouter()
+	err = m.Handle("/a", mux.HandlerFunc(func(w mux.ResponseWriter, _ *mux.Message) {
+		errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("hello")))
+		assert.NoError(t, errS)
+	}))
+	require.NoError(t, err)
+
+	s := udp.NewServer(options.WithMux(m))
+	defer s.Stop()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	dialer := &chaos.Dialer{Options: []chaos.Option{
+		chaos.WithLoss(0.3),
+		chaos.WithDuplication(0.2),
+		chaos.WithLatency(time.Millisecond, time.Millisecond*5),
+		chaos.WithSeed(1),
+	}}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	cc, err := udp.Dial(l.LocalAddr().String(), options.WithPacketDialer(dialer), options.WithNetwork("udp4"),
+		options.WithTransmission(1, time.Millisecond*50, 10),
+		options.WithPeriodicRunner(periodic.New(ctx.Done(), time.Millisecond*10)))
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	for i := 0; i < 10; i++ {
+		resp, errG := cc.Get(ctx, "/a")
+		require.NoError(t, errG)
+		require.Equal(t, codes.Content, resp.Code())
+	}
+	// the lost requests were retransmitted
+	require.Len(t, dialer.Conns(), 1)
+	require.Greater(t, dialer.Conns()[0].Stats().Dropped, uint32(0))
+}
@@ -2,11 +2,14 @@ package client
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 
 	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
 	"github.com/plgd-dev/go-coap/v3/message/pool"
+	"github.com/plgd-dev/go-coap/v3/net/blockwise"
 	limitparallelrequests "github.com/plgd-dev/go-coap/v3/net/client/limitParallelRequests"
 	"github.com/plgd-dev/go-coap/v3/net/observation"
 )
@@ -49,7 +52,10 @@ func (c *Client[C]) GetToken() (message.Token, error) {
 //
 // Use ctx to set timeout.
 func (c *Client[C]) NewGetRequest(ctx context.Context, path string, opts ...message.Option) (*pool.Message, error) {
-	req := c.cc.AcquireMessage(ctx)
+	req, err := c.acquireMessage(ctx)
+	if err != nil {
+		return nil, err
+	}
 	token, err := c.GetToken()
 	if err != nil {
 		c.cc.ReleaseMessage(req)
@@ -78,6 +84,53 @@ func (c *Client[C]) Get(ctx context.Context, path string, opts ...message.Option
 	return c.Do(req)
 }
 
+// GetBlock issues a GET of the single block num of size szx of the resource on path (RFC 7959 section 2.4), e.g. to resume
+// the interrupted download from the last received block. It returns the data of the block and whether more blocks follow,
+// the blocks are not reassembled. The response which isn't 2.05 (Content) is returned as the error. The server which
+// responds by the smaller blocks returns the first smaller block at the requested offset.
+//
+// Use ctx to set timeout.
+func (c *Client[C]) GetBlock(ctx context.Context, path string, num int64, szx blockwise.SZX, opts ...message.Option) ([]byte, bool, error) {
+	block, err := blockwise.EncodeBlockOption(szx, num, false)
+	if err != nil {
+		return nil, false, fmt.Errorf("cannot encode block option: %w", err)
+	}
+	req, err := c.NewGetRequest(blockwise.WithSingleBlock(ctx), path, opts...)
+	if err != nil {
+		return nil, false, fmt.Errorf("cannot create get request: %w", err)
+	}
+	defer c.cc.ReleaseMessage(req)
+	req.SetOptionUint32(message.Block2, block)
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer c.cc.ReleaseMessage(resp)
+	if resp.Code() != codes.Content {
+		return nil, false, fmt.Errorf("unexpected response code(%v) to the block request", resp.Code())
+	}
+	data, err := resp.ReadBody()
+	if err != nil {
+		return nil, false, fmt.Errorf("cannot read block: %w", err)
+	}
+	respBlock, err := resp.GetOptionUint32(message.Block2)
+	if errors.Is(err, message.ErrOptionNotFound) && num == 0 {
+		// the whole resource fits into the response
+		return data, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("cannot get Block2 option: %w", err)
+	}
+	respSzx, respNum, more, err := blockwise.DecodeBlockOption(respBlock)
+	if err != nil {
+		return nil, false, fmt.Errorf("cannot decode Block2 option: %w", err)
+	}
+	if respNum*respSzx.Size() != num*szx.Size() {
+		return nil, false, fmt.Errorf("unexpected block(%v, szx=%v) in the response to the block(%v, szx=%v)", respNum, respSzx, num, szx)
+	}
+	return data, more, nil
+}
+
 type Observation = interface {
 	Cancel(ctx context.Context, opts ...message.Option) error
 	Canceled() bool
@@ -96,6 +149,11 @@ func (c *Client[C]) NewObserveRequest(ctx context.Context, path string, opts ...
 }
 
 // Observe subscribes for every change of resource on path.
+//
+// Options in opts are sent with the registration, e.g. Uri-Query options with conditional
+// attributes (pmin, pmax, ...) for the server side filtering of notifications.
+//
+// When observeFunc is nil, the notifications are pulled by Observation.Next.
 func (c *Client[C]) Observe(ctx context.Context, path string, observeFunc func(req *pool.Message), opts ...message.Option) (Observation, error) {
 	req, err := c.NewObserveRequest(ctx, path, opts...)
 	if err != nil {
@@ -105,6 +163,83 @@ func (c *Client[C]) Observe(ctx context.Context, path string, observeFunc func(r
 	return c.DoObserve(req, observeFunc)
 }
 
+// NewObserveRequestWithMethod creates observe request with the method GET, POST or PUT, e.g. to observe
+// the progress of the action started by POST. Observing other methods than GET is not covered by RFC 7641,
+// so the server must support it.
+//
+// Use ctx to set timeout.
+//
+// If payload is nil then content format is not used.
+func (c *Client[C]) NewObserveRequestWithMethod(ctx context.Context, method codes.Code, path string, contentFormat message.MediaType, payload io.ReadSeeker, opts ...message.Option) (*pool.Message, error) {
+	switch method {
+	case codes.GET, codes.POST, codes.PUT:
+	default:
+		return nil, fmt.Errorf("cannot observe method %v", method)
+	}
+	req, err := c.acquireMessage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	token, err := c.GetToken()
+	if err != nil {
+		c.cc.ReleaseMessage(req)
+		return nil, err
+	}
+	err = req.SetupPost(path, token, contentFormat, payload, opts...)
+	if err != nil {
+		c.cc.ReleaseMessage(req)
+		return nil, err
+	}
+	req.SetCode(method)
+	req.SetObserve(0)
+	return req, nil
+}
+
+// ObserveWithMethod subscribes for every change of the result of the request with the method on path,
+// see NewObserveRequestWithMethod. The observation is handled as the observation created by Observe, but its response
+// is accepted with any success code (e.g. 2.04 Changed for POST) and it is deregistered by the same method.
+func (c *Client[C]) ObserveWithMethod(ctx context.Context, method codes.Code, path string, contentFormat message.MediaType, payload io.ReadSeeker, observeFunc func(req *pool.Message), opts ...message.Option) (Observation, error) {
+	req, err := c.NewObserveRequestWithMethod(ctx, method, path, contentFormat, payload, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer c.cc.ReleaseMessage(req)
+	return c.DoObserve(req, observeFunc)
+}
+
+// ObserveInto subscribes for every change of resource on path. Each notification is decoded into dest by the decoder
+// of its content format (see message.RegisterBodyDecoder) and then onUpdate is called. The dest is overwritten
+// by the next notification, so it must be accessed only from onUpdate. When the notification cannot be decoded,
+// onError is called instead of onUpdate.
+func (c *Client[C]) ObserveInto(ctx context.Context, path string, dest any, onUpdate func(), onError func(err error), opts ...message.Option) (Observation, error) {
+	return c.Observe(ctx, path, func(n *pool.Message) {
+		if err := n.DecodeBody(dest); err != nil {
+			if onError != nil {
+				onError(fmt.Errorf("cannot decode notification: %w", err))
+			}
+			return
+		}
+		onUpdate()
+	}, opts...)
+}
+
+// Observations returns all active observations created by the connection.
+func (c *Client[C]) Observations() []Observation {
+	observations := c.observationHandler.Observations()
+	res := make([]Observation, 0, len(observations))
+	for _, o := range observations {
+		res = append(res, o)
+	}
+	return res
+}
+
+// CancelObservations deregisters all observations created by the connection, e.g. before the connection is closed.
+//
+// Use ctx to set timeout.
+func (c *Client[C]) CancelObservations(ctx context.Context) error {
+	return c.observationHandler.CancelObservations(ctx)
+}
+
 func (c *Client[C]) GetObservationRequest(token message.Token) (*pool.Message, bool) {
 	return c.observationHandler.GetObservationRequest(token)
 }
@@ -118,7 +253,10 @@ func (c *Client[C]) GetObservationRequest(token message.Token) (*pool.Message, b
 //
 // If payload is nil then content format is not used.
 func (c *Client[C]) NewPostRequest(ctx context.Context, path string, contentFormat message.MediaType, payload io.ReadSeeker, opts ...message.Option) (*pool.Message, error) {
-	req := c.cc.AcquireMessage(ctx)
+	req, err := c.acquireMessage(ctx)
+	if err != nil {
+		return nil, err
+	}
 	token, err := c.GetToken()
 	if err != nil {
 		c.cc.ReleaseMessage(req)
@@ -149,13 +287,32 @@ func (c *Client[C]) Post(ctx context.Context, path string, contentFormat message
 	return c.Do(req)
 }
 
+// PostWithoutContentFormat issues a POST with the payload to the specified path without the content format,
+// so the request gets the default content format of the connection (see options.WithDefaultContentFormat) or none.
+//
+// Use ctx to set timeout.
+func (c *Client[C]) PostWithoutContentFormat(ctx context.Context, path string, payload io.ReadSeeker, opts ...message.Option) (*pool.Message, error) {
+	req, err := c.NewPostRequest(ctx, path, 0, nil, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create post request: %w", err)
+	}
+	defer c.cc.ReleaseMessage(req)
+	if payload != nil {
+		req.SetBody(payload)
+	}
+	return c.Do(req)
+}
+
 // NewPutRequest creates put request.
 //
 // Use ctx to set timeout.
 //
 // If payload is nil then content format is not used.
 func (c *Client[C]) NewPutRequest(ctx context.Context, path string, contentFormat message.MediaType, payload io.ReadSeeker, opts ...message.Option) (*pool.Message, error) {
-	req := c.cc.AcquireMessage(ctx)
+	req, err := c.acquireMessage(ctx)
+	if err != nil {
+		return nil, err
+	}
 	token, err := c.GetToken()
 	if err != nil {
 		c.cc.ReleaseMessage(req)
@@ -186,11 +343,30 @@ func (c *Client[C]) Put(ctx context.Context, path string, contentFormat message.
 	return c.Do(req)
 }
 
+// PutWithoutContentFormat issues a PUT with the payload to the specified path without the content format,
+// so the request gets the default content format of the connection (see options.WithDefaultContentFormat) or none.
+//
+// Use ctx to set timeout.
+func (c *Client[C]) PutWithoutContentFormat(ctx context.Context, path string, payload io.ReadSeeker, opts ...message.Option) (*pool.Message, error) {
+	req, err := c.NewPutRequest(ctx, path, 0, nil, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create put request: %w", err)
+	}
+	defer c.cc.ReleaseMessage(req)
+	if payload != nil {
+		req.SetBody(payload)
+	}
+	return c.Do(req)
+}
+
 // NewDeleteRequest creates delete request.
 //
 // Use ctx to set timeout.
 func (c *Client[C]) NewDeleteRequest(ctx context.Context, path string, opts ...message.Option) (*pool.Message, error) {
-	req := c.cc.AcquireMessage(ctx)
+	req, err := c.acquireMessage(ctx)
+	if err != nil {
+		return nil, err
+	}
 	token, err := c.GetToken()
 	if err != nil {
 		c.cc.ReleaseMessage(req)
@@ -216,6 +392,76 @@ func (c *Client[C]) Delete(ctx context.Context, path string, opts ...message.Opt
 	return c.Do(req)
 }
 
+// tryAcquireMessageConn is implemented by the connections whose message pool can fail the acquisition
+// by its pool.ExhaustedPolicy.
+type tryAcquireMessageConn interface {
+	TryAcquireMessage(ctx context.Context) (*pool.Message, error)
+}
+
+// acquireMessage acquires the message of the request, so the request fails when the message pool is exhausted
+// instead of allocating the message beyond the pool.
+func (c *Client[C]) acquireMessage(ctx context.Context) (*pool.Message, error) {
+	if tc, ok := c.cc.(tryAcquireMessageConn); ok {
+		return tc.TryAcquireMessage(ctx)
+	}
+	return c.cc.AcquireMessage(ctx), nil
+}
+
+// blockwiseSZXConn is implemented by the connections which provide the maximal block size of the blockwise transfers.
+type blockwiseSZXConn interface {
+	BlockwiseSZX() blockwise.SZX
+}
+
+// ErrRequestEntityTooLarge is returned by ProbeUpload when the server rejected the upload by 4.13 (Request Entity Too Large).
+var ErrRequestEntityTooLarge = errors.New("request entity too large")
+
+// ProbeUpload asks the server whether it accepts the body of size bytes sent by POST or PUT to the path,
+// before the body is sent. The request contains Size1 and Block1(NUM=0,M=1) with the block size configured
+// for the connection and without payload (RFC 7959 section 4), the server which accepts the upload responds by 2.31 (Continue).
+//
+// It returns ErrRequestEntityTooLarge when the server rejected the upload, the error contains the max size announced by the server.
+// The same rejection is returned as the 4.13 response by Post/Put after the first block when the upload is not probed.
+//
+// Use ctx to set timeout.
+func (c *Client[C]) ProbeUpload(ctx context.Context, code codes.Code, path string, size uint32, opts ...message.Option) error {
+	if code != codes.POST && code != codes.PUT {
+		return fmt.Errorf("invalid code(%v) of upload: expected POST or PUT", code)
+	}
+	req, err := c.NewPostRequest(ctx, path, message.TextPlain, nil, opts...)
+	if err != nil {
+		return fmt.Errorf("cannot create probe request: %w", err)
+	}
+	defer c.cc.ReleaseMessage(req)
+	req.SetCode(code)
+	szx := blockwise.SZX1024
+	if bc, ok := c.cc.(blockwiseSZXConn); ok {
+		szx = bc.BlockwiseSZX()
+	}
+	block, err := blockwise.EncodeBlockOption(szx, 0, true)
+	if err != nil {
+		return fmt.Errorf("cannot encode block option: %w", err)
+	}
+	req.SetOptionUint32(message.Block1, block)
+	req.SetOptionUint32(message.Size1, size)
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer c.cc.ReleaseMessage(resp)
+	switch resp.Code() {
+	case codes.RequestEntityTooLarge:
+		maxSize, errS := resp.GetOptionUint32(message.Size1)
+		if errS != nil {
+			return fmt.Errorf("%w: %v bytes", ErrRequestEntityTooLarge, size)
+		}
+		return fmt.Errorf("%w: %v bytes, server accepts at most %v bytes", ErrRequestEntityTooLarge, size, maxSize)
+	case codes.Continue:
+		return nil
+	default:
+		return fmt.Errorf("unexpected response code(%v) to the upload probe", resp.Code())
+	}
+}
+
 // Ping issues a PING to the client and waits for PONG response.
 //
 // Use ctx to set timeout.
@@ -237,5 +483,18 @@ func (c *Client[C]) Ping(ctx context.Context) error {
 		return nil
 	case <-ctx.Done():
 		return ctx.Err()
+	case <-c.cc.Context().Done():
+		return fmt.Errorf("connection was closed: %w", context.Cause(c.cc.Context()))
+	}
+}
+
+// IsAlive checks the liveness of the connection before it is reused. For UDP/DTLS it sends an empty
+// confirmable message and waits for the reset, for TCP it sends a Ping signal and waits for the Pong.
+//
+// Use ctx to set timeout.
+func (c *Client[C]) IsAlive(ctx context.Context) bool {
+	if c.cc.Context().Err() != nil {
+		return false
 	}
+	return c.Ping(ctx) == nil
 }
@@ -4,6 +4,7 @@ import (
 	"sync"
 
 	"github.com/plgd-dev/go-coap/v3/message/pool"
+	"github.com/plgd-dev/go-coap/v3/pkg/goroutine"
 	"go.uber.org/atomic"
 )
 
@@ -13,21 +14,35 @@ type ReceivedMessageReaderClient interface {
 }
 
 type ReceivedMessageReader[C ReceivedMessageReaderClient] struct {
-	queue chan *pool.Message
-	cc    C
+	queue      chan *pool.Message
+	cc         C
+	goroutines *goroutine.Limiter
 
 	private struct {
 		mutex           sync.Mutex
 		loopDone        chan struct{}
 		readingMessages *atomic.Bool
 	}
+
+	turn struct {
+		mutex sync.Mutex
+		// last is closed when the function of the last call of ProcessInOrder returns
+		last chan struct{}
+	}
 }
 
 // NewReceivedMessageReader creates a new ReceivedMessageReader[C] instance.
 func NewReceivedMessageReader[C ReceivedMessageReaderClient](cc C, queueSize int) *ReceivedMessageReader[C] {
+	return NewReceivedMessageReaderWithLimiter(cc, queueSize, nil)
+}
+
+// NewReceivedMessageReaderWithLimiter creates a new ReceivedMessageReader[C] instance whose loops are counted by goroutines.
+// The loops are started regardless of the limit, because the received messages must be processed.
+func NewReceivedMessageReaderWithLimiter[C ReceivedMessageReaderClient](cc C, queueSize int, goroutines *goroutine.Limiter) *ReceivedMessageReader[C] {
 	r := ReceivedMessageReader[C]{
-		queue: make(chan *pool.Message, queueSize),
-		cc:    cc,
+		queue:      make(chan *pool.Message, queueSize),
+		cc:         cc,
+		goroutines: goroutines,
 		private: struct {
 			mutex           sync.Mutex
 			loopDone        chan struct{}
@@ -38,7 +53,10 @@ func NewReceivedMessageReader[C ReceivedMessageReaderClient](cc C, queueSize int
 		},
 	}
 
-	go r.loop(r.private.loopDone, r.private.readingMessages)
+	loopDone, readingMessages := r.private.loopDone, r.private.readingMessages
+	r.goroutines.Go(func() {
+		r.loop(loopDone, readingMessages)
+	})
 	return &r
 }
 
@@ -92,5 +110,32 @@ func (r *ReceivedMessageReader[C]) TryToReplaceLoop() {
 	readingMessages := atomic.NewBool(true)
 	r.private.loopDone = loopDone
 	r.private.readingMessages = readingMessages
-	go r.loop(loopDone, readingMessages)
+	r.goroutines.Go(func() {
+		r.loop(loopDone, readingMessages)
+	})
+}
+
+// ProcessInOrder calls f when the functions of the previous calls have returned, so the functions are called one at a time
+// in the order of the calls. While f waits for its turn, the loop is replaced, so the messages received meanwhile
+// (e.g. the responses awaited by the previous function) are still processed. When the client is closed, f is not called.
+func (r *ReceivedMessageReader[C]) ProcessInOrder(f func()) {
+	r.turn.mutex.Lock()
+	prev := r.turn.last
+	done := make(chan struct{})
+	r.turn.last = done
+	r.turn.mutex.Unlock()
+	defer close(done)
+	if prev != nil {
+		select {
+		case <-prev:
+		default:
+			r.TryToReplaceLoop()
+			select {
+			case <-prev:
+			case <-r.cc.Done():
+				return
+			}
+		}
+	}
+	f()
 }
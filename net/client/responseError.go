@@ -0,0 +1,104 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+)
+
+// ResponseError is the response of the client error (4.xx) or the server error (5.xx) class returned as the error,
+// see CheckResponse. Use errors.As to get it from the error returned by GetOrError and the other *OrError methods.
+type ResponseError struct {
+	// Response is the raw response, it can be released to the pool by the caller as the response returned by Do.
+	Response   *pool.Message
+	diagnostic string
+}
+
+// Code returns the code of the response, e.g. codes.NotFound.
+func (e *ResponseError) Code() codes.Code {
+	return e.Response.Code()
+}
+
+// Diagnostic returns the diagnostic payload of the response (RFC 7252 section 5.5.2), the human-readable
+// description of the error. It is empty when the response has no payload.
+func (e *ResponseError) Diagnostic() string {
+	return e.diagnostic
+}
+
+func (e *ResponseError) Error() string {
+	code := e.Code()
+	class, detail := uint8(code>>5), uint8(code&0x1f)
+	if e.diagnostic == "" {
+		return fmt.Sprintf("response %v.%02v (%v)", class, detail, code)
+	}
+	return fmt.Sprintf("response %v.%02v (%v): %v", class, detail, code, e.diagnostic)
+}
+
+// IsErrorCode reports whether the code belongs to the client error (4.xx) or the server error (5.xx) class.
+func IsErrorCode(code codes.Code) bool {
+	class := code >> 5
+	return class == 4 || class == 5
+}
+
+// CheckResponse returns *ResponseError when the code of the resp belongs to the client error (4.xx)
+// or the server error (5.xx) class, otherwise it returns nil.
+func CheckResponse(resp *pool.Message) error {
+	if !IsErrorCode(resp.Code()) {
+		return nil
+	}
+	e := &ResponseError{Response: resp}
+	if resp.Body() != nil {
+		diagnostic, err := resp.ReadBody()
+		if err != nil {
+			return fmt.Errorf("%w: cannot read diagnostic payload: %w", e, err)
+		}
+		e.diagnostic = string(diagnostic)
+	}
+	return e
+}
+
+func responseOrError(resp *pool.Message, err error) (*pool.Message, error) {
+	if err != nil {
+		return nil, err
+	}
+	if err = CheckResponse(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// GetOrError issues a GET to the specified path as Get, but the response of the client error (4.xx)
+// or the server error (5.xx) class is returned as *ResponseError.
+//
+// Use ctx to set timeout.
+func (c *Client[C]) GetOrError(ctx context.Context, path string, opts ...message.Option) (*pool.Message, error) {
+	return responseOrError(c.Get(ctx, path, opts...))
+}
+
+// PostOrError issues a POST to the specified path as Post, but the response of the client error (4.xx)
+// or the server error (5.xx) class is returned as *ResponseError.
+//
+// Use ctx to set timeout.
+func (c *Client[C]) PostOrError(ctx context.Context, path string, contentFormat message.MediaType, payload io.ReadSeeker, opts ...message.Option) (*pool.Message, error) {
+	return responseOrError(c.Post(ctx, path, contentFormat, payload, opts...))
+}
+
+// PutOrError issues a PUT to the specified path as Put, but the response of the client error (4.xx)
+// or the server error (5.xx) class is returned as *ResponseError.
+//
+// Use ctx to set timeout.
+func (c *Client[C]) PutOrError(ctx context.Context, path string, contentFormat message.MediaType, payload io.ReadSeeker, opts ...message.Option) (*pool.Message, error) {
+	return responseOrError(c.Put(ctx, path, contentFormat, payload, opts...))
+}
+
+// DeleteOrError deletes the resource identified by the request path as Delete, but the response of the client error (4.xx)
+// or the server error (5.xx) class is returned as *ResponseError.
+//
+// Use ctx to set timeout.
+func (c *Client[C]) DeleteOrError(ctx context.Context, path string, opts ...message.Option) (*pool.Message, error) {
+	return responseOrError(c.Delete(ctx, path, opts...))
+}
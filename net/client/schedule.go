@@ -0,0 +1,124 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+)
+
+// OverlapPolicy defines how SchedulePeriodic handles the ticks which elapse while the previous request
+// is still waiting for the response.
+type OverlapPolicy int
+
+const (
+	// OverlapSkip drops the ticks which elapse while the request is in progress, the next request is sent
+	// at the next tick after the response is received.
+	OverlapSkip OverlapPolicy = iota
+	// OverlapQueue sends the next request immediately after the response is received when a tick elapsed meanwhile.
+	// At most one tick is queued, so the requests never run in parallel.
+	OverlapQueue
+)
+
+func (p OverlapPolicy) String() string {
+	switch p {
+	case OverlapSkip:
+		return "skip"
+	case OverlapQueue:
+		return "queue"
+	}
+	return fmt.Sprintf("OverlapPolicy(%d)", int(p))
+}
+
+// SchedulePeriodic sends the copy of req every interval and calls handler with the response or the error of each request,
+// e.g. to poll a resource. The requests get a new token and message ID and they never run in parallel, the ticks which
+// elapse while the request is in progress are handled by the policy. The req is copied, so it can be released by the caller
+// after the call. The response is released after the handler returns, so the handler must not retain it.
+// Each request waits for the response at most the timeout, the non-positive timeout bounds the request by the interval.
+//
+// The schedule stops when the returned cancel function is called or when the connection is closed. The request in progress
+// is canceled and its error is not passed to the handler. The cancel function doesn't wait for the handler in progress,
+// so it can be called from the handler.
+func (c *Client[C]) SchedulePeriodic(interval, timeout time.Duration, policy OverlapPolicy, req *pool.Message, handler func(resp *pool.Message, err error)) (func(), error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid interval(%v)", interval)
+	}
+	if timeout <= 0 {
+		timeout = interval
+	}
+	if handler == nil {
+		return nil, errors.New("nil handler")
+	}
+	if policy != OverlapSkip && policy != OverlapQueue {
+		return nil, fmt.Errorf("invalid overlap policy(%v)", policy)
+	}
+	ctx, cancel := context.WithCancel(c.cc.Context())
+	template, err := c.acquireMessage(ctx)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("cannot acquire request: %w", err)
+	}
+	if err := req.Clone(template); err != nil {
+		cancel()
+		c.cc.ReleaseMessage(template)
+		return nil, fmt.Errorf("cannot copy request: %w", err)
+	}
+	go func() {
+		defer c.cc.ReleaseMessage(template)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			c.doScheduled(ctx, timeout, template, handler)
+			if policy == OverlapSkip {
+				// the ticker buffers the tick which elapsed during the request
+				select {
+				case <-ticker.C:
+				default:
+				}
+			}
+		}
+	}()
+	return cancel, nil
+}
+
+func (c *Client[C]) doScheduled(ctx context.Context, timeout time.Duration, template *pool.Message, handler func(resp *pool.Message, err error)) {
+	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := c.acquireMessage(reqCtx)
+	if err != nil {
+		handler(nil, fmt.Errorf("cannot acquire scheduled request: %w", err))
+		return
+	}
+	defer c.cc.ReleaseMessage(req)
+	if err := template.Clone(req); err != nil {
+		handler(nil, fmt.Errorf("cannot copy scheduled request: %w", err))
+		return
+	}
+	token, err := c.GetToken()
+	if err != nil {
+		handler(nil, fmt.Errorf("cannot get token: %w", err))
+		return
+	}
+	req.SetToken(token)
+	req.SetMessageID(-1)
+	resp, err := c.Do(req)
+	if ctx.Err() != nil {
+		if err == nil {
+			c.cc.ReleaseMessage(resp)
+		}
+		return
+	}
+	if err != nil {
+		handler(nil, err)
+		return
+	}
+	defer c.cc.ReleaseMessage(resp)
+	handler(resp, nil)
+}
@@ -0,0 +1,70 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ErrServerShutdown is the cause of the connections which were closed by the stop of the server.
+// It wraps context.Canceled, so it is handled as the cancellation.
+var ErrServerShutdown = fmt.Errorf("server was shut down: %w", context.Canceled)
+
+// CloseReason is the category of the reason why the connection was closed.
+type CloseReason uint8
+
+const (
+	// CloseReasonNone means that the connection is not closed.
+	CloseReasonNone CloseReason = iota
+	// CloseReasonClosed means that the connection was closed by the application via Close.
+	CloseReasonClosed
+	// CloseReasonIdleTimeout means that the connection was closed by the inactivity monitor or by the keepalive,
+	// their callbacks report it by CloseWithReason of the connection, e.g. by inactivity.CloseConn.
+	CloseReasonIdleTimeout
+	// CloseReasonPeerReset means that the peer closed or reset the connection, e.g. TCP FIN/RST or ICMP port unreachable.
+	CloseReasonPeerReset
+	// CloseReasonTransportError means that reading from the connection failed.
+	CloseReasonTransportError
+	// CloseReasonServerShutdown means that the connection was closed by the stop of the server.
+	CloseReasonServerShutdown
+)
+
+var closeReasonToString = map[CloseReason]string{
+	CloseReasonNone:           "None",
+	CloseReasonClosed:         "Closed",
+	CloseReasonIdleTimeout:    "IdleTimeout",
+	CloseReasonPeerReset:      "PeerReset",
+	CloseReasonTransportError: "TransportError",
+	CloseReasonServerShutdown: "ServerShutdown",
+}
+
+func (r CloseReason) String() string {
+	str, ok := closeReasonToString[r]
+	if !ok {
+		return "CloseReason(" + strconv.FormatInt(int64(r), 10) + ")"
+	}
+	return str
+}
+
+// IsGraceful returns true when the connection was closed by the application or by the server shutdown.
+func (r CloseReason) IsGraceful() bool {
+	return r == CloseReasonClosed || r == CloseReasonServerShutdown
+}
+
+// CloseReasonFromCause classifies the cause of the canceled context of the connection.
+func CloseReasonFromCause(cause error) CloseReason {
+	switch {
+	case cause == nil:
+		return CloseReasonNone
+	case errors.Is(cause, ErrServerShutdown):
+		return CloseReasonServerShutdown
+	case errors.Is(cause, context.Canceled):
+		return CloseReasonClosed
+	case errors.Is(cause, io.EOF), errors.Is(cause, ErrConnectionRefused), IsConnectionBrokenError(cause):
+		return CloseReasonPeerReset
+	default:
+		return CloseReasonTransportError
+	}
+}
@@ -0,0 +1,32 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloseReasonFromCause(t *testing.T) {
+	tests := []struct {
+		name  string
+		cause error
+		want  CloseReason
+	}{
+		{name: "open", cause: nil, want: CloseReasonNone},
+		{name: "closed", cause: context.Canceled, want: CloseReasonClosed},
+		{name: "shutdown", cause: fmt.Errorf("stop: %w", ErrServerShutdown), want: CloseReasonServerShutdown},
+		{name: "eof", cause: io.EOF, want: CloseReasonPeerReset},
+		{name: "transport", cause: errors.New("read failed"), want: CloseReasonTransportError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, CloseReasonFromCause(tt.cause))
+		})
+	}
+	require.Equal(t, "IdleTimeout", CloseReasonIdleTimeout.String())
+	require.Equal(t, "CloseReason(255)", CloseReason(255).String())
+}
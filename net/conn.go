@@ -3,6 +3,7 @@ package net
 import (
 	"context"
 	"fmt"
+	"io"
 	"net"
 	"sync"
 
@@ -77,9 +78,59 @@ func (c *Conn) WriteWithContext(ctx context.Context, data []byte) error {
 	if err := c.handshake(ctx); err != nil {
 		return err
 	}
-	written := 0
 	c.lock.Lock()
 	defer c.lock.Unlock()
+	return c.writeLocked(ctx, data)
+}
+
+// streamChunkSize is the size of the chunks copied from the reader by WriteStreamWithContext.
+const streamChunkSize = 32 * 1024
+
+// WriteStreamWithContext writes the header followed by exactly size bytes read from r, without buffering of the whole data.
+// The data are not interleaved with the other writes to the connection. When it fails after a part of the data was written,
+// the connection is closed, because the stream is corrupted.
+func (c *Conn) WriteStreamWithContext(ctx context.Context, header []byte, r io.Reader, size int64) error {
+	if err := c.handshake(ctx); err != nil {
+		return err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.writeLocked(ctx, header); err != nil {
+		return err
+	}
+	if err := c.writeStreamLocked(ctx, r, size); err != nil {
+		if errC := c.Close(); errC != nil {
+			return fmt.Errorf("%w: cannot close connection: %w", err, errC)
+		}
+		return err
+	}
+	return nil
+}
+
+func (c *Conn) writeStreamLocked(ctx context.Context, r io.Reader, size int64) error {
+	chunkSize := int64(streamChunkSize)
+	if size < chunkSize {
+		chunkSize = size
+	}
+	buf := make([]byte, chunkSize)
+	for size > 0 {
+		chunk := buf
+		if size < int64(len(chunk)) {
+			chunk = chunk[:size]
+		}
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return fmt.Errorf("cannot read data to write: %w", err)
+		}
+		if err := c.writeLocked(ctx, chunk); err != nil {
+			return err
+		}
+		size -= int64(len(chunk))
+	}
+	return nil
+}
+
+func (c *Conn) writeLocked(ctx context.Context, data []byte) error {
+	written := 0
 	for written < len(data) {
 		select {
 		case <-ctx.Done():
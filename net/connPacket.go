@@ -0,0 +1,86 @@
+package net
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// ErrMulticastNotSupported is returned by the multicast operations of the connection created by NewUDPConnOverPacketConn.
+var ErrMulticastNotSupported = errors.New("multicast is not supported by the connection")
+
+// packetConnGeneric adapts the custom datagram transport, which doesn't support the control messages and multicast.
+type packetConnGeneric struct {
+	packetConn net.PacketConn
+}
+
+func (p *packetConnGeneric) SupportsControlMessage() bool {
+	return false
+}
+
+func (p *packetConnGeneric) IsIPv6() bool {
+	addr, ok := p.packetConn.LocalAddr().(*net.UDPAddr)
+	return ok && IsIPv6(addr.IP)
+}
+
+func (p *packetConnGeneric) SetWriteDeadline(t time.Time) error {
+	return p.packetConn.SetWriteDeadline(t)
+}
+
+func (p *packetConnGeneric) WriteTo(b []byte, _ *ControlMessage, dst net.Addr) (n int, err error) {
+	return p.packetConn.WriteTo(b, dst)
+}
+
+func (p *packetConnGeneric) ReadFrom(b []byte) (n int, cm *ControlMessage, src net.Addr, err error) {
+	n, src, err = p.packetConn.ReadFrom(b)
+	return n, nil, src, err
+}
+
+func (p *packetConnGeneric) SetMulticastInterface(*net.Interface) error {
+	return ErrMulticastNotSupported
+}
+
+func (p *packetConnGeneric) SetMulticastHopLimit(int) error {
+	return ErrMulticastNotSupported
+}
+
+func (p *packetConnGeneric) SetMulticastLoopback(bool) error {
+	return ErrMulticastNotSupported
+}
+
+func (p *packetConnGeneric) JoinGroup(*net.Interface, net.Addr) error {
+	return ErrMulticastNotSupported
+}
+
+func (p *packetConnGeneric) LeaveGroup(*net.Interface, net.Addr) error {
+	return ErrMulticastNotSupported
+}
+
+func (p *packetConnGeneric) WriteBatch(datagrams []UDPDatagram) (int, error) {
+	for i, d := range datagrams {
+		n, err := p.packetConn.WriteTo(d.Data, d.RemoteAddr)
+		if err != nil {
+			return i, err
+		}
+		if n != len(d.Data) {
+			return i, ErrWriteInterrupted
+		}
+	}
+	return len(datagrams), nil
+}
+
+// NewUDPConnOverPacketConn creates connection over the custom datagram transport, e.g. a userspace VPN or tunnel,
+// instead of the UDP socket. The transport must report the source addresses of the received datagrams as *net.UDPAddr.
+// The control messages are not supported and the multicast operations return ErrMulticastNotSupported.
+func NewUDPConnOverPacketConn(network string, c net.PacketConn, opts ...UDPOption) *UDPConn {
+	cfg := DefaultUDPConnConfig
+	for _, o := range opts {
+		o.ApplyUDP(&cfg)
+	}
+	return &UDPConn{
+		network:    network,
+		custom:     c,
+		packetConn: &packetConnGeneric{packetConn: c},
+		errors:     cfg.Errors,
+	}
+}
@@ -20,6 +20,7 @@ type UDPConn struct {
 	packetConn packetConn
 	network    string
 	connection *net.UDPConn
+	custom     net.PacketConn // set instead of connection by NewUDPConnOverPacketConn
 	errors     func(err error)
 	closed     atomic.Bool
 }
@@ -68,6 +69,40 @@ type packetConn interface {
 	ReadFrom(b []byte) (n int, cm *ControlMessage, src net.Addr, err error)
 	SupportsControlMessage() bool
 	IsIPv6() bool
+	WriteBatch(datagrams []UDPDatagram) (int, error)
+}
+
+// UDPDatagram is a datagram written by UDPConn.WriteBatch.
+type UDPDatagram struct {
+	Data           []byte
+	RemoteAddr     *net.UDPAddr
+	ControlMessage *ControlMessage
+}
+
+// writeBatch writes datagrams via write, which sends multiple messages by one syscall where it is supported.
+func writeBatch(datagrams []UDPDatagram, marshalControlMessage func(cm *ControlMessage) []byte, write func(ms []ipv4.Message, flags int) (int, error)) (int, error) {
+	ms := make([]ipv4.Message, len(datagrams))
+	for i, d := range datagrams {
+		ms[i].Buffers = [][]byte{d.Data}
+		ms[i].Addr = d.RemoteAddr
+		if d.ControlMessage != nil {
+			ms[i].OOB = marshalControlMessage(d.ControlMessage)
+		}
+	}
+	written := 0
+	for written < len(ms) {
+		n, err := write(ms[written:], 0)
+		if err != nil {
+			return written, err
+		}
+		for i := written; i < written+n; i++ {
+			if ms[i].N != len(datagrams[i].Data) {
+				return i, ErrWriteInterrupted
+			}
+		}
+		written += n
+	}
+	return written, nil
 }
 
 type packetConnIPv4 struct {
@@ -109,6 +144,15 @@ func (p *packetConnIPv4) WriteTo(b []byte, cm *ControlMessage, dst net.Addr) (n
 	return p.packetConn.WriteTo(b, c, dst)
 }
 
+func (p *packetConnIPv4) WriteBatch(datagrams []UDPDatagram) (int, error) {
+	return writeBatch(datagrams, func(cm *ControlMessage) []byte {
+		return (&ipv4.ControlMessage{
+			Src:     cm.Src,
+			IfIndex: cm.IfIndex,
+		}).Marshal()
+	}, p.packetConn.WriteBatch)
+}
+
 func (p *packetConnIPv4) ReadFrom(b []byte) (int, *ControlMessage, net.Addr, error) {
 	n, cm, src, err := p.packetConn.ReadFrom(b)
 	if err != nil {
@@ -196,6 +240,15 @@ func (p *packetConnIPv6) WriteTo(b []byte, cm *ControlMessage, dst net.Addr) (n
 	return p.packetConn.WriteTo(b, c, dst)
 }
 
+func (p *packetConnIPv6) WriteBatch(datagrams []UDPDatagram) (int, error) {
+	return writeBatch(datagrams, func(cm *ControlMessage) []byte {
+		return (&ipv6.ControlMessage{
+			Src:     cm.Src,
+			IfIndex: cm.IfIndex,
+		}).Marshal()
+	}, p.packetConn.WriteBatch)
+}
+
 func (p *packetConnIPv6) SetMulticastHopLimit(hoplim int) error {
 	return p.packetConn.SetMulticastHopLimit(hoplim)
 }
@@ -230,6 +283,8 @@ type UDPConnConfig struct {
 	Errors func(err error)
 }
 
+// NewListenUDP creates the listener for network "udp4", "udp6" or "udp". For the dual-stack listener
+// which receives IPv4 and IPv6 datagrams by a single socket, use NewListenUDPDualStack.
 func NewListenUDP(network, addr string, opts ...UDPOption) (*UDPConn, error) {
 	listenAddress, err := net.ResolveUDPAddr(network, addr)
 	if err != nil {
@@ -242,6 +297,36 @@ func NewListenUDP(network, addr string, opts ...UDPOption) (*UDPConn, error) {
 	return NewUDPConn(network, conn, opts...), nil
 }
 
+// NewListenUDPDualStack creates the listener which receives IPv4 and IPv6 datagrams by a single IPv6 socket
+// (IPV6_V6ONLY disabled). The IPv4 peers are reported by IPv4 addresses and the responses are sent to them
+// as IPv4 datagrams. The socket is bound to the unspecified address, so addr must contain only the port, e.g. ":5683".
+//
+// The multicast groups are joined for IPv6 only, so the IPv4 multicast (e.g. 224.0.1.187)
+// requires a separate NewListenUDP("udp4", ...) listener.
+func NewListenUDPDualStack(addr string, opts ...UDPOption) (*UDPConn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if host != "" && host != "::" {
+		return nil, fmt.Errorf("invalid address(%v): dual-stack listener must be bound to the unspecified address", addr)
+	}
+	listenAddress, err := net.ResolveUDPAddr("udp", net.JoinHostPort("::", port))
+	if err != nil {
+		return nil, err
+	}
+	// for the unspecified IPv6 address, the "udp" network creates the socket with disabled IPV6_V6ONLY
+	conn, err := net.ListenUDP("udp", listenAddress)
+	if err != nil {
+		return nil, err
+	}
+	if laddr, ok := conn.LocalAddr().(*net.UDPAddr); !ok || !IsIPv6(laddr.IP) {
+		_ = conn.Close()
+		return nil, errors.New("dual-stack listener is not supported by the system")
+	}
+	return NewUDPConn("udp", conn, opts...), nil
+}
+
 func newPacketConn(c *net.UDPConn) (packetConn, error) {
 	laddr := c.LocalAddr()
 	if laddr == nil {
@@ -285,11 +370,17 @@ func NewUDPConn(network string, c *net.UDPConn, opts ...UDPOption) *UDPConn {
 
 // LocalAddr returns the local network address. The Addr returned is shared by all invocations of LocalAddr, so do not modify it.
 func (c *UDPConn) LocalAddr() net.Addr {
+	if c.custom != nil {
+		return c.custom.LocalAddr()
+	}
 	return c.connection.LocalAddr()
 }
 
 // RemoteAddr returns the remote network address. The Addr returned is shared by all invocations of RemoteAddr, so do not modify it.
 func (c *UDPConn) RemoteAddr() net.Addr {
+	if c.custom != nil {
+		return nil
+	}
 	return c.connection.RemoteAddr()
 }
 
@@ -303,6 +394,9 @@ func (c *UDPConn) Close() error {
 	if !c.closed.CompareAndSwap(false, true) {
 		return nil
 	}
+	if c.custom != nil {
+		return c.custom.Close()
+	}
 	return c.connection.Close()
 }
 
@@ -334,6 +428,9 @@ func (c *UDPConn) writeToAddr(iface *net.Interface, src *net.IP, multicastHopLim
 	if c.closed.Load() {
 		return ErrConnectionIsClosed
 	}
+	if c.custom != nil {
+		return ErrMulticastNotSupported
+	}
 	p, err := newPacketConnWithAddr(raddr, c.connection)
 	if err != nil {
 		return err
@@ -511,6 +608,9 @@ func (c *UDPConn) writeMulticast(ctx context.Context, raddr *net.UDPAddr, buffer
 }
 
 func (c *UDPConn) writeTo(raddr *net.UDPAddr, cm *ControlMessage, buffer []byte) (int, error) {
+	if c.custom != nil {
+		return c.packetConn.WriteTo(buffer, cm, raddr)
+	}
 	if !supportsOverrideRemoteAddr(c.connection) {
 		// If the remote address is set, we can use it as the destination address
 		// because the connection is already established.
@@ -526,6 +626,43 @@ func (c *UDPConn) writeTo(raddr *net.UDPAddr, cm *ControlMessage, buffer []byte)
 	return c.packetConn.WriteTo(buffer, cm, raddr)
 }
 
+// WriteBatch writes datagrams to their remote addresses. On Linux the datagrams are sent by the sendmmsg
+// syscall, on other platforms and for the connected socket they are written one by one.
+// It returns the number of written datagrams.
+func (c *UDPConn) WriteBatch(ctx context.Context, datagrams []UDPDatagram) (int, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	default:
+	}
+	if c.closed.Load() {
+		return 0, ErrConnectionIsClosed
+	}
+	batch := c.custom != nil || supportsOverrideRemoteAddr(c.connection)
+	for _, d := range datagrams {
+		if d.RemoteAddr == nil {
+			return 0, errors.New("cannot write batch: invalid raddr")
+		}
+		if c.custom == nil && !IsIPv6(d.RemoteAddr.IP) && c.packetConn.IsIPv6() {
+			// IPv4 packets need to be written via IPv4 packet connection, see writeTo
+			batch = false
+		}
+	}
+	if batch {
+		return c.packetConn.WriteBatch(datagrams)
+	}
+	for i, d := range datagrams {
+		n, err := c.writeTo(d.RemoteAddr, d.ControlMessage, d.Data)
+		if err != nil {
+			return i, err
+		}
+		if n != len(d.Data) {
+			return i, ErrWriteInterrupted
+		}
+	}
+	return len(datagrams), nil
+}
+
 type UDPWriteCfg struct {
 	Ctx            context.Context
 	RemoteAddr     *net.UDPAddr
@@ -772,6 +909,11 @@ func (c *UDPConn) LeaveGroup(ifi *net.Interface, group net.Addr) error {
 }
 
 // NetConn returns the underlying connection that is wrapped by c. The Conn returned is shared by all invocations of NetConn, so do not modify it.
+// For the connection created by NewUDPConnOverPacketConn it returns the transport when it implements net.Conn, otherwise nil.
 func (c *UDPConn) NetConn() net.Conn {
+	if c.custom != nil {
+		conn, _ := c.custom.(net.Conn)
+		return conn
+	}
 	return c.connection
 }
@@ -547,3 +547,125 @@ func TestPacketConnReadFrom(t *testing.T) {
 		})
 	}
 }
+
+func TestUDPConnWriteBatch(t *testing.T) {
+	for _, network := range []string{udp4Network, udpNetwork} {
+		t.Run(network, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+			defer cancel()
+
+			addr := "127.0.0.1:"
+			if network == udpNetwork {
+				addr = ""
+			}
+			receiver, err := NewListenUDP(network, addr)
+			require.NoError(t, err)
+			defer func() {
+				errC := receiver.Close()
+				require.NoError(t, errC)
+			}()
+			sender, err := NewListenUDP(network, addr)
+			require.NoError(t, err)
+			defer func() {
+				errC := sender.Close()
+				require.NoError(t, errC)
+			}()
+
+			raddr, err := net.ResolveUDPAddr(udp4Network, "127.0.0.1:"+strconv.Itoa(receiver.LocalAddr().(*net.UDPAddr).Port))
+			require.NoError(t, err)
+			datagrams := []UDPDatagram{
+				{Data: []byte("first"), RemoteAddr: raddr},
+				{Data: []byte("second"), RemoteAddr: raddr},
+				{Data: []byte("third"), RemoteAddr: raddr},
+			}
+			n, err := sender.WriteBatch(ctx, datagrams)
+			require.NoError(t, err)
+			require.Equal(t, len(datagrams), n)
+
+			for _, d := range datagrams {
+				buf := make([]byte, 64)
+				n, _, err := receiver.ReadWithContext(ctx, buf)
+				require.NoError(t, err)
+				require.Equal(t, d.Data, buf[:n])
+			}
+
+			ctxCanceled, ctxCancel := context.WithCancel(context.Background())
+			ctxCancel()
+			_, err = sender.WriteBatch(ctxCanceled, datagrams)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestUDPConnOverPacketConn(t *testing.T) {
+	a, err := net.ListenPacket(udp4Network, "127.0.0.1:0")
+	require.NoError(t, err)
+	b, err := NewListenUDP(udp4Network, "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() {
+		errC := b.Close()
+		require.NoError(t, errC)
+	}()
+	// hide the *net.UDPConn behind net.PacketConn
+	c := NewUDPConnOverPacketConn(udp4Network, struct{ net.PacketConn }{a})
+	defer func() {
+		errC := c.Close()
+		require.NoError(t, errC)
+	}()
+	require.Nil(t, c.RemoteAddr())
+	require.Nil(t, c.NetConn())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	baddr, ok := b.LocalAddr().(*net.UDPAddr)
+	require.True(t, ok)
+	err = c.WriteWithContext(ctx, baddr, []byte("ping"))
+	require.NoError(t, err)
+	buf := make([]byte, 16)
+	n, raddr, err := b.ReadWithContext(ctx, buf)
+	require.NoError(t, err)
+	require.Equal(t, "ping", string(buf[:n]))
+
+	err = b.WriteWithContext(ctx, raddr, []byte("pong"))
+	require.NoError(t, err)
+	n, _, err = c.ReadWithContext(ctx, buf)
+	require.NoError(t, err)
+	require.Equal(t, "pong", string(buf[:n]))
+
+	err = c.JoinGroup(nil, &net.UDPAddr{IP: net.IPv4(224, 0, 1, 187)})
+	require.ErrorIs(t, err, ErrMulticastNotSupported)
+	err = c.WriteMulticast(ctx, &net.UDPAddr{IP: net.IPv4(224, 0, 1, 187), Port: 5683}, []byte("ping"))
+	require.ErrorIs(t, err, ErrMulticastNotSupported)
+}
+
+func TestUDPConnJoinGroupAllInterfaces(t *testing.T) {
+	require.Equal(t, "224.0.1.187:5683", AllCoAPNodesIPv4(DefaultCoAPPort).String())
+	require.Equal(t, "[ff02::fd]:5683", AllCoAPNodesIPv6(IPv6MulticastScopeLinkLocal, DefaultCoAPPort).String())
+	require.Equal(t, "[ff05::fd]:5684", AllCoAPNodesIPv6(IPv6MulticastScopeSiteLocal, 5684).String())
+
+	l, err := NewListenUDP(udp4Network, "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+
+	joined, err := l.JoinGroupAllInterfaces(AllCoAPNodesIPv4(DefaultCoAPPort))
+	require.NoError(t, err)
+	for _, iface := range joined {
+		require.NotZero(t, iface.Flags&net.FlagMulticast, iface.Name)
+		require.NotZero(t, iface.Flags&net.FlagUp, iface.Name)
+		require.Zero(t, iface.Flags&net.FlagLoopback, iface.Name)
+		err = l.LeaveGroup(&iface, AllCoAPNodesIPv4(DefaultCoAPPort))
+		require.NoError(t, err)
+	}
+
+	// the IPv6 group cannot be joined by the IPv4 socket
+	joined, err = l.JoinGroupAllInterfaces(AllCoAPNodesIPv6(IPv6MulticastScopeLinkLocal, DefaultCoAPPort), WithJoinDownInterfaces())
+	require.Empty(t, joined)
+	if err != nil {
+		var joinErr *JoinGroupError
+		require.ErrorAs(t, err, &joinErr)
+		require.NotEmpty(t, joinErr.Interface.Name)
+	}
+}
@@ -1,7 +1,9 @@
 package net
 
 import (
+	"bytes"
 	"context"
+	"io"
 	"net"
 	"testing"
 
@@ -85,3 +87,38 @@ func TestConnWriteWithContext(t *testing.T) {
 		})
 	}
 }
+
+func TestConnWriteStreamWithContext(t *testing.T) {
+	data := make([]byte, streamChunkSize*3+100)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	header := []byte{0x1, 0x2, 0x3}
+
+	client, server := net.Pipe()
+	c := NewConn(client)
+	defer func() {
+		errC := server.Close()
+		require.NoError(t, errC)
+	}()
+	received := make(chan []byte, 1)
+	go func() {
+		b := make([]byte, len(header)+len(data))
+		_, errR := io.ReadFull(server, b)
+		assert.NoError(t, errR)
+		received <- b
+	}()
+
+	err := c.WriteStreamWithContext(context.Background(), header, bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	require.Equal(t, append(append([]byte{}, header...), data...), <-received)
+
+	// the short reader corrupts the stream, so the connection is closed
+	go func() {
+		_, _ = io.Copy(io.Discard, server)
+	}()
+	err = c.WriteStreamWithContext(context.Background(), header, bytes.NewReader(data[:10]), int64(len(data)))
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	err = c.WriteWithContext(context.Background(), header)
+	require.ErrorIs(t, err, ErrConnectionIsClosed)
+}
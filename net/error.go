@@ -5,12 +5,25 @@ import (
 	"errors"
 	"io"
 	"net"
+	"syscall"
 )
 
 var (
 	ErrListenerIsClosed   = io.EOF
 	ErrConnectionIsClosed = io.EOF
 	ErrWriteInterrupted   = errors.New("only part data was written to socket")
+	// ErrConnectionRefused is reported by a connected UDP socket when the peer replies with the ICMP port unreachable.
+	ErrConnectionRefused = syscall.ECONNREFUSED
+	// ErrServerClosed is returned by Serve of the server after the call of Stop or the cancellation of its context.
+	ErrServerClosed = errors.New("server closed")
+	// ErrMaxConnectionsExceeded is reported by the server which refused the new connection, because it serves the maximum number of connections.
+	ErrMaxConnectionsExceeded = errors.New("max connections exceeded")
+	// ErrMaxGoroutinesExceeded is reported by the server which refused the new connection, because the goroutines
+	// reached the limit set by options.WithMaxGoroutines.
+	ErrMaxGoroutinesExceeded = errors.New("max goroutines exceeded")
+	// ErrMalformedDatagram is returned by the processing of the datagram which cannot be decoded, the datagram
+	// is dropped and the connection stays open.
+	ErrMalformedDatagram = errors.New("malformed datagram")
 )
 
 func IsCancelOrCloseError(err error) bool {
@@ -0,0 +1,62 @@
+// Package metrics defines the Collector of the events of the servers and the connections, e.g. to export them
+// as the Prometheus metrics (see the net/metrics/prometheus module) without the dependency of the core module on the metrics library.
+package metrics
+
+import (
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+)
+
+// Collector receives the events of the connections. The methods are called concurrently by the goroutines
+// of the connections, so they must be safe for the concurrent use and they must not block.
+type Collector interface {
+	// MessageReceived is called for every received message which was parsed, e.g. the requests by their code.
+	MessageReceived(code codes.Code)
+	// MessageSent is called for every message written to the connection, including the retransmissions.
+	MessageSent(code codes.Code)
+	// Retransmitted is called when the confirmable message is retransmitted (UDP and DTLS).
+	Retransmitted()
+	// AddConnections changes the number of the open connections by delta.
+	AddConnections(delta int)
+	// AddObservations changes the number of the observations registered by the peers by delta.
+	AddObservations(delta int)
+	// AddBlockwiseTransfers changes the number of the blockwise transfers in progress by delta. The transfers
+	// are counted when the connection checks the expirations, so the value is updated periodically.
+	AddBlockwiseTransfers(delta int)
+}
+
+// DropCollector is the optional interface of the Collector which counts the received messages dropped
+// by the connections, e.g. when the message pool is exhausted, or by the UDP server when it refused
+// the connection of the source of the datagram.
+type DropCollector interface {
+	MessageDropped()
+}
+
+// MessageDropped reports the dropped received message to c when it implements DropCollector.
+func MessageDropped(c Collector) {
+	if dc, ok := c.(DropCollector); ok {
+		dc.MessageDropped()
+	}
+}
+
+// NilCollector ignores all events, it is used when the collector is not set.
+type NilCollector struct{}
+
+func (NilCollector) MessageReceived(codes.Code) {}
+
+func (NilCollector) MessageSent(codes.Code) {}
+
+func (NilCollector) Retransmitted() {}
+
+func (NilCollector) AddConnections(int) {}
+
+func (NilCollector) AddObservations(int) {}
+
+func (NilCollector) AddBlockwiseTransfers(int) {}
+
+// OrNil returns the collector c or NilCollector when c is nil.
+func OrNil(c Collector) Collector {
+	if c == nil {
+		return NilCollector{}
+	}
+	return c
+}
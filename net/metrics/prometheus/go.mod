@@ -0,0 +1,29 @@
+module github.com/plgd-dev/go-coap/v3/net/metrics/prometheus
+
+go 1.20
+
+require (
+	github.com/plgd-dev/go-coap/v3 v3.0.0-20261014112227-640d663d2009
+	github.com/prometheus/client_golang v1.19.1
+	github.com/stretchr/testify v1.9.0
+)
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/kr/text v0.2.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.48.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
+	golang.org/x/exp v0.0.0-20240823005443-9b4947da3948 // indirect
+	golang.org/x/sys v0.24.0 // indirect
+	google.golang.org/protobuf v1.33.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+// The core module is pinned to the pseudo-version of the commit which contains net/metrics with
+// the DropCollector, until the next release of the core module is tagged. The replace builds the module
+// against the tree of the repository, so the changes of both modules are tested together.
+replace github.com/plgd-dev/go-coap/v3 => ../../..
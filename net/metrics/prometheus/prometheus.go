@@ -0,0 +1,123 @@
+// Package prometheus provides the collector of the Prometheus metrics of the servers and the connections,
+// which is set by options.WithMetrics and registered to the Prometheus registry:
+//
+//	c := prometheus.NewCollector("coap")
+//	registry.MustRegister(c)
+//	s := udp.NewServer(options.WithMetrics(c))
+//
+// It is the separate module, so the core module doesn't depend on the Prometheus client.
+package prometheus
+
+import (
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/net/metrics"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector implements metrics.Collector by the Prometheus metrics and prometheus.Collector, so it can be registered.
+type Collector struct {
+	messagesReceived   *prom.CounterVec
+	messagesSent       *prom.CounterVec
+	messagesDropped    prom.Counter
+	retransmissions    prom.Counter
+	connections        prom.Gauge
+	observations       prom.Gauge
+	blockwiseTransfers prom.Gauge
+}
+
+var (
+	_ metrics.Collector     = (*Collector)(nil)
+	_ metrics.DropCollector = (*Collector)(nil)
+	_ prom.Collector        = (*Collector)(nil)
+)
+
+// NewCollector creates the collector of the metrics with the namespace, e.g. "coap" for coap_messages_received_total.
+func NewCollector(namespace string) *Collector {
+	return &Collector{
+		messagesReceived: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_received_total",
+			Help:      "Number of the received messages by the code.",
+		}, []string{"code"}),
+		messagesSent: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_sent_total",
+			Help:      "Number of the sent messages by the code, including the retransmissions.",
+		}, []string{"code"}),
+		messagesDropped: prom.NewCounter(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_dropped_total",
+			Help:      "Number of the received messages dropped by the connections, e.g. when the message pool is exhausted.",
+		}),
+		retransmissions: prom.NewCounter(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "retransmissions_total",
+			Help:      "Number of the retransmissions of the confirmable messages.",
+		}),
+		connections: prom.NewGauge(prom.GaugeOpts{
+			Namespace: namespace,
+			Name:      "connections",
+			Help:      "Number of the open connections.",
+		}),
+		observations: prom.NewGauge(prom.GaugeOpts{
+			Namespace: namespace,
+			Name:      "observations",
+			Help:      "Number of the observations registered by the peers.",
+		}),
+		blockwiseTransfers: prom.NewGauge(prom.GaugeOpts{
+			Namespace: namespace,
+			Name:      "blockwise_transfers",
+			Help:      "Number of the blockwise transfers in progress.",
+		}),
+	}
+}
+
+func (c *Collector) MessageReceived(code codes.Code) {
+	c.messagesReceived.WithLabelValues(code.String()).Inc()
+}
+
+func (c *Collector) MessageSent(code codes.Code) {
+	c.messagesSent.WithLabelValues(code.String()).Inc()
+}
+
+func (c *Collector) MessageDropped() {
+	c.messagesDropped.Inc()
+}
+
+func (c *Collector) Retransmitted() {
+	c.retransmissions.Inc()
+}
+
+func (c *Collector) AddConnections(delta int) {
+	c.connections.Add(float64(delta))
+}
+
+func (c *Collector) AddObservations(delta int) {
+	c.observations.Add(float64(delta))
+}
+
+func (c *Collector) AddBlockwiseTransfers(delta int) {
+	c.blockwiseTransfers.Add(float64(delta))
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prom.Desc) {
+	c.messagesReceived.Describe(ch)
+	c.messagesSent.Describe(ch)
+	c.messagesDropped.Describe(ch)
+	c.retransmissions.Describe(ch)
+	c.connections.Describe(ch)
+	c.observations.Describe(ch)
+	c.blockwiseTransfers.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prom.Metric) {
+	c.messagesReceived.Collect(ch)
+	c.messagesSent.Collect(ch)
+	c.messagesDropped.Collect(ch)
+	c.retransmissions.Collect(ch)
+	c.connections.Collect(ch)
+	c.observations.Collect(ch)
+	c.blockwiseTransfers.Collect(ch)
+}
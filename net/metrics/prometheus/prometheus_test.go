@@ -0,0 +1,54 @@
+package prometheus_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/net/metrics/prometheus"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector(t *testing.T) {
+	c := prometheus.NewCollector("coap")
+	registry := prom.NewPedanticRegistry()
+	require.NoError(t, registry.Register(c))
+
+	c.MessageReceived(codes.GET)
+	c.MessageReceived(codes.GET)
+	c.MessageSent(codes.Content)
+	c.Retransmitted()
+	c.MessageDropped()
+	c.AddConnections(2)
+	c.AddConnections(-1)
+	c.AddObservations(3)
+	c.AddBlockwiseTransfers(1)
+	c.AddBlockwiseTransfers(-1)
+
+	expected := `
+# HELP coap_blockwise_transfers Number of the blockwise transfers in progress.
+# TYPE coap_blockwise_transfers gauge
+coap_blockwise_transfers 0
+# HELP coap_connections Number of the open connections.
+# TYPE coap_connections gauge
+coap_connections 1
+# HELP coap_messages_dropped_total Number of the received messages dropped by the connections, e.g. when the message pool is exhausted.
+# TYPE coap_messages_dropped_total counter
+coap_messages_dropped_total 1
+# HELP coap_messages_received_total Number of the received messages by the code.
+# TYPE coap_messages_received_total counter
+coap_messages_received_total{code="GET"} 2
+# HELP coap_messages_sent_total Number of the sent messages by the code, including the retransmissions.
+# TYPE coap_messages_sent_total counter
+coap_messages_sent_total{code="Content"} 1
+# HELP coap_observations Number of the observations registered by the peers.
+# TYPE coap_observations gauge
+coap_observations 3
+# HELP coap_retransmissions_total Number of the retransmissions of the confirmable messages.
+# TYPE coap_retransmissions_total counter
+coap_retransmissions_total 1
+`
+	require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected)))
+}
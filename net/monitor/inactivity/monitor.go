@@ -4,6 +4,8 @@ import (
 	"context"
 	"sync/atomic"
 	"time"
+
+	coapNet "github.com/plgd-dev/go-coap/v3/net"
 )
 
 type OnInactiveFunc[C Conn] func(cc C)
@@ -30,8 +32,19 @@ func (m *Monitor[C]) LastActivity() time.Time {
 	return time.Time{}
 }
 
+// reasonCloser is implemented by the connections which report the reason of the close.
+type reasonCloser interface {
+	CloseWithReason(reason coapNet.CloseReason) error
+}
+
+// CloseConn closes the inactive connection with coapNet.CloseReasonIdleTimeout, it is the default callback
+// of the inactivity monitors of the servers.
 func CloseConn(cc Conn) {
 	// call cc.Close() directly to check and handle error if necessary
+	if rc, ok := cc.(reasonCloser); ok {
+		_ = rc.CloseWithReason(coapNet.CloseReasonIdleTimeout)
+		return
+	}
 	_ = cc.Close()
 }
 
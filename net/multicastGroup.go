@@ -0,0 +1,106 @@
+package net
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// DefaultCoAPPort is the default port of CoAP over UDP (RFC 7252 section 6.1).
+const DefaultCoAPPort = 5683
+
+// IPv6MulticastScope is the scope of the IPv6 multicast address (RFC 7346).
+type IPv6MulticastScope uint8
+
+const (
+	IPv6MulticastScopeLinkLocal  IPv6MulticastScope = 0x2
+	IPv6MulticastScopeRealmLocal IPv6MulticastScope = 0x3
+	IPv6MulticastScopeAdminLocal IPv6MulticastScope = 0x4
+	IPv6MulticastScopeSiteLocal  IPv6MulticastScope = 0x5
+)
+
+// AllCoAPNodesIPv4 returns the IPv4 group of All CoAP Nodes 224.0.1.187 with the port (RFC 7252 section 12.8).
+func AllCoAPNodesIPv4(port int) *net.UDPAddr {
+	return &net.UDPAddr{IP: net.IPv4(224, 0, 1, 187), Port: port}
+}
+
+// AllCoAPNodesIPv6 returns the IPv6 group of All CoAP Nodes ff0X::fd of the scope with the port (RFC 7252 section 12.8),
+// e.g. ff02::fd for IPv6MulticastScopeLinkLocal.
+func AllCoAPNodesIPv6(scope IPv6MulticastScope, port int) *net.UDPAddr {
+	ip := make(net.IP, net.IPv6len)
+	ip[0] = 0xff
+	ip[1] = byte(scope & 0xf)
+	ip[15] = 0xfd
+	return &net.UDPAddr{IP: ip, Port: port}
+}
+
+// JoinGroupError is the error of joining the group on the interface, see UDPConn.JoinGroupAllInterfaces.
+type JoinGroupError struct {
+	Interface net.Interface
+	Err       error
+}
+
+func (e *JoinGroupError) Error() string {
+	return fmt.Sprintf("cannot join group on interface %v: %v", e.Interface.Name, e.Err)
+}
+
+func (e *JoinGroupError) Unwrap() error {
+	return e.Err
+}
+
+type joinGroupOptions struct {
+	loopback bool
+	down     bool
+}
+
+// JoinGroupOption configures UDPConn.JoinGroupAllInterfaces.
+type JoinGroupOption func(*joinGroupOptions)
+
+// WithJoinLoopbackInterfaces joins the group also on the loopback interfaces which support the multicast.
+func WithJoinLoopbackInterfaces() JoinGroupOption {
+	return func(o *joinGroupOptions) {
+		o.loopback = true
+	}
+}
+
+// WithJoinDownInterfaces joins the group also on the interfaces which are down.
+func WithJoinDownInterfaces() JoinGroupOption {
+	return func(o *joinGroupOptions) {
+		o.down = true
+	}
+}
+
+// JoinGroupAllInterfaces joins the group on every interface which supports the multicast and which is up,
+// the loopback interfaces are skipped unless WithJoinLoopbackInterfaces is set. It returns the interfaces
+// on which the group was joined and the joined errors of the other interfaces, each of them is *JoinGroupError.
+// The group is joined when at least one interface is returned, even if the error is not nil.
+func (c *UDPConn) JoinGroupAllInterfaces(group net.Addr, opts ...JoinGroupOption) ([]net.Interface, error) {
+	var o joinGroupOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get interfaces to join group: %w", err)
+	}
+	var joined []net.Interface
+	var errs []error
+	for i := range ifaces {
+		iface := ifaces[i]
+		if iface.Flags&net.FlagMulticast == 0 {
+			continue
+		}
+		if iface.Flags&net.FlagUp == 0 && !o.down {
+			continue
+		}
+		if iface.Flags&net.FlagLoopback != 0 && !o.loopback {
+			continue
+		}
+		if err := c.JoinGroup(&iface, group); err != nil {
+			errs = append(errs, &JoinGroupError{Interface: iface, Err: err})
+			continue
+		}
+		joined = append(joined, iface)
+	}
+	return joined, errors.Join(errs...)
+}
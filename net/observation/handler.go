@@ -18,6 +18,13 @@ import (
 
 type DoFunc = func(req *pool.Message) (*pool.Message, error)
 
+var (
+	// ErrObservationCanceled is returned by Next when the observation was canceled.
+	ErrObservationCanceled = errors.New("observation was canceled")
+	// ErrObserveFuncIsSet is returned by Next when the notifications are delivered to the observe function.
+	ErrObserveFuncIsSet = errors.New("notifications are delivered to the observe function")
+)
+
 type Client interface {
 	Context() context.Context
 	WriteMessage(req *pool.Message) error
@@ -92,7 +99,7 @@ func (h *Handler[C]) NewObservation(req *pool.Message, observeFunc func(req *poo
 		err = fmt.Errorf("connection was closed: %w", h.cc.Context().Err())
 		return nil, err
 	case resp := <-respObservationChan:
-		if resp.code != codes.Content && resp.code != codes.Valid {
+		if !isObserveResponseCode(o.req.Code, resp.code) {
 			err = fmt.Errorf("unexpected return code(%v)", resp.code)
 			return nil, err
 		}
@@ -121,6 +128,28 @@ func (h *Handler[C]) GetObservationRequest(token message.Token) (*pool.Message,
 	return msg, true
 }
 
+// Observations returns all observations registered by the connection.
+func (h *Handler[C]) Observations() []*Observation[C] {
+	observations := make([]*Observation[C], 0, h.observations.Length())
+	h.observations.Range(func(_ uint64, o *Observation[C]) bool {
+		observations = append(observations, o)
+		return true
+	})
+	return observations
+}
+
+// CancelObservations sends deregistration for each observation registered by the connection.
+// All observations are removed, even if the deregistration of some of them fails.
+func (h *Handler[C]) CancelObservations(ctx context.Context) error {
+	var errs []error
+	for _, o := range h.Observations() {
+		if err := o.Cancel(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("cannot cancel observation(%v): %w", o.req.Token, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func (h *Handler[C]) pullOutObservation(key uint64) (*Observation[C], bool) {
 	return h.observations.LoadAndDelete(key)
 }
@@ -143,6 +172,8 @@ type respObservationMessage struct {
 type Observation[C Client] struct {
 	req                 message.Message
 	observeFunc         func(req *pool.Message)
+	notifications       chan *pool.Message // buffered notification for Next, nil when observeFunc is set
+	done                chan struct{}
 	respObservationChan chan respObservationMessage
 	waitForResponse     atomic.Bool
 	observationHandler  *Handler[C]
@@ -161,11 +192,17 @@ func (o *Observation[C]) Canceled() bool {
 }
 
 func newObservation[C Client](req message.Message, observationHandler *Handler[C], observeFunc func(req *pool.Message), respObservationChan chan respObservationMessage) *Observation[C] {
+	var notifications chan *pool.Message
+	if observeFunc == nil {
+		notifications = make(chan *pool.Message, 1)
+	}
 	return &Observation[C]{
 		req:                 req,
 		waitForResponse:     *atomic.NewBool(true),
 		respObservationChan: respObservationChan,
 		observeFunc:         observeFunc,
+		notifications:       notifications,
+		done:                make(chan struct{}),
 		observationHandler:  observationHandler,
 	}
 }
@@ -181,8 +218,86 @@ func (o *Observation[C]) handle(r *pool.Message) {
 		}
 		o.respObservationChan = nil
 	}
-	if o.wantBeNotified(r) {
+	if !o.wantBeNotified(r) {
+		return
+	}
+	if o.notifications == nil {
 		o.observeFunc(r)
+		return
+	}
+	o.bufferNotification(r)
+}
+
+// bufferNotification stores the notification for Next. The older notification which was not pulled yet is replaced,
+// because only the latest one describes the current state of the resource.
+func (o *Observation[C]) bufferNotification(r *pool.Message) {
+	r.Hijack()
+	for {
+		select {
+		case o.notifications <- r:
+			if o.isClosed() {
+				// the observation was closed meanwhile, so nobody pulls the notification
+				o.releaseNotifications()
+			}
+			return
+		default:
+		}
+		select {
+		case old := <-o.notifications:
+			o.client().ReleaseMessage(old)
+		default:
+		}
+	}
+}
+
+// Next waits for the next notification of the observation created without the observe function (nil observeFunc),
+// the first one is the response to the registration. The observation buffers only the latest notification:
+// when notifications arrive faster than they are pulled, the older unread notification is dropped.
+//
+// Caller is responsible to release the notification.
+func (o *Observation[C]) Next(ctx context.Context) (*pool.Message, error) {
+	if o.notifications == nil {
+		return nil, ErrObserveFuncIsSet
+	}
+	select {
+	case r := <-o.notifications:
+		return r, nil
+	default:
+	}
+	select {
+	case r := <-o.notifications:
+		return r, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-o.done:
+		o.releaseNotifications()
+		return nil, ErrObservationCanceled
+	case <-o.client().Context().Done():
+		o.releaseNotifications()
+		return nil, fmt.Errorf("connection was closed: %w", o.client().Context().Err())
+	}
+}
+
+func (o *Observation[C]) isClosed() bool {
+	select {
+	case <-o.done:
+		return true
+	case <-o.client().Context().Done():
+		return true
+	default:
+		return false
+	}
+}
+
+// releaseNotifications releases the buffered notification which will not be pulled by Next.
+func (o *Observation[C]) releaseNotifications() {
+	for {
+		select {
+		case r := <-o.notifications:
+			o.client().ReleaseMessage(r)
+		default:
+			return
+		}
 	}
 }
 
@@ -190,6 +305,10 @@ func (o *Observation[C]) cleanUp() bool {
 	// we can ignore err during cleanUp, if err != nil then some other
 	// part of code already removed the handler for the token
 	_, ok := o.observationHandler.pullOutObservation(o.req.Token.Hash())
+	if ok {
+		close(o.done)
+		o.releaseNotifications()
+	}
 	return ok
 }
 
@@ -208,6 +327,9 @@ func (o *Observation[C]) etag() []byte {
 }
 
 // Cancel remove observation from server. For recreate observation use Observe.
+//
+// The deregistration request contains the path and the Uri-Query options of the registration (RFC7641 3.6),
+// unless Uri-Query options are set via opts.
 func (o *Observation[C]) Cancel(ctx context.Context, opts ...message.Option) error {
 	if !o.cleanUp() {
 		// observation was already cleanup
@@ -217,13 +339,21 @@ func (o *Observation[C]) Cancel(ctx context.Context, opts ...message.Option) err
 	req := o.client().AcquireMessage(ctx)
 	defer o.client().ReleaseMessage(req)
 	req.ResetOptionsTo(opts)
-	req.SetCode(codes.GET)
+	// the observation of the other method than GET is deregistered by the same method, but without the payload
+	req.SetCode(o.req.Code)
 	req.SetObserve(1)
 	if path, err := o.req.Options.Path(); err == nil {
 		if err := req.SetPath(path); err != nil {
 			return fmt.Errorf("cannot set path(%v): %w", path, err)
 		}
 	}
+	if !req.HasOption(message.URIQuery) {
+		if queries, err := o.req.Options.Queries(); err == nil {
+			for _, q := range queries {
+				req.AddQuery(q)
+			}
+		}
+	}
 	req.SetToken(o.req.Token)
 	etag := o.etag()
 	if len(etag) > 0 {
@@ -234,12 +364,21 @@ func (o *Observation[C]) Cancel(ctx context.Context, opts ...message.Option) err
 		return err
 	}
 	defer o.client().ReleaseMessage(resp)
-	if resp.Code() != codes.Content && resp.Code() != codes.Valid {
+	if !isObserveResponseCode(o.req.Code, resp.Code()) {
 		return fmt.Errorf("unexpected return code(%v)", resp.Code())
 	}
 	return nil
 }
 
+// isObserveResponseCode returns true when the code is the successful response to the observe request with the method.
+// The GET is answered by 2.05 Content or 2.03 Valid (RFC 7641), the other methods by any success code.
+func isObserveResponseCode(method, code codes.Code) bool {
+	if method == codes.GET {
+		return code == codes.Content || code == codes.Valid
+	}
+	return code >= codes.Created && code <= codes.Content
+}
+
 func (o *Observation[C]) wantBeNotified(r *pool.Message) bool {
 	obsSequence, err := r.Observe()
 	if err != nil {
@@ -2,6 +2,8 @@ package observation
 
 import (
 	"time"
+
+	"go.uber.org/atomic"
 )
 
 // ObservationSequenceTimeout defines how long is sequence number is valid. https://tools.ietf.org/html/rfc7641#section-3.4
@@ -20,3 +22,26 @@ func ValidSequenceNumber(oldValue, newValue uint32, lastEventOccurs time.Time, n
 	}
 	return false
 }
+
+// MaxSequenceNumber is the maximal value of the Observe option in notifications. https://tools.ietf.org/html/rfc7641#section-4.4
+const MaxSequenceNumber = 1<<24 - 1
+
+// Sequence generates monotonic sequence numbers for notifications of one observation.
+// The numbers are truncated to 24 bits, so they wrap around as expected by ValidSequenceNumber.
+//
+// The zero value is ready to use and the first returned number is 1.
+type Sequence struct {
+	value atomic.Uint32
+}
+
+// NewSequence creates a sequence which continues after the last used number.
+func NewSequence(last uint32) *Sequence {
+	s := &Sequence{}
+	s.value.Store(last & MaxSequenceNumber)
+	return s
+}
+
+// Next returns the next sequence number.
+func (s *Sequence) Next() uint32 {
+	return s.value.Inc() & MaxSequenceNumber
+}
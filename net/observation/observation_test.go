@@ -105,3 +105,15 @@ func TestValidSequenceNumber(t *testing.T) {
 		})
 	}
 }
+
+func TestSequence(t *testing.T) {
+	var s Sequence
+	assert.Equal(t, uint32(1), s.Next())
+	assert.Equal(t, uint32(2), s.Next())
+
+	s2 := NewSequence(MaxSequenceNumber - 1)
+	assert.Equal(t, uint32(MaxSequenceNumber), s2.Next())
+	assert.Equal(t, uint32(0), s2.Next())
+	assert.True(t, ValidSequenceNumber(MaxSequenceNumber, 0, time.Now(), time.Now()))
+	assert.Equal(t, uint32(1), s2.Next())
+}
@@ -0,0 +1,175 @@
+package observation
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+	"github.com/plgd-dev/go-coap/v3/net/metrics"
+	"github.com/plgd-dev/go-coap/v3/net/responsewriter"
+)
+
+// ErrNotTracked is returned when the observations registered by the peer are needed, but they are not tracked,
+// see options.WithObserverMigration.
+var ErrNotTracked = errors.New("observations registered by the peer are not tracked")
+
+// Registrations tracks the observations registered by the peer of one connection, see RegistrationsHandler.
+type Registrations struct {
+	max    uint32
+	mutex  sync.Mutex
+	tokens map[uint64]message.Token // guarded by mutex
+	// the message ID of the last notification of the observation and back, guarded by mutex
+	notifications map[uint64]int32
+	mids          map[int32]uint64
+	metrics       metrics.Collector
+}
+
+func (r *Registrations) canRegister(key uint64) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.tokens[key]; ok {
+		// re-registration of the same observation
+		return true
+	}
+	return r.max == 0 || len(r.tokens) < int(r.max)
+}
+
+func (r *Registrations) add(key uint64, token message.Token) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.tokens[key]; !ok {
+		r.tokens[key] = append(message.Token(nil), token...)
+		r.metrics.AddObservations(1)
+	}
+}
+
+func (r *Registrations) remove(key uint64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.removeLocked(key)
+}
+
+func (r *Registrations) removeLocked(key uint64) {
+	if _, ok := r.tokens[key]; ok {
+		delete(r.tokens, key)
+		r.metrics.AddObservations(-1)
+	}
+	if mid, ok := r.notifications[key]; ok {
+		delete(r.notifications, key)
+		delete(r.mids, mid)
+	}
+}
+
+// Notified records the message ID of the notification sent for the observation with the token, so the observation
+// can be removed by RemoveByMessageID. The notifications of the observations which are not registered are ignored.
+func (r *Registrations) Notified(token message.Token, mid int32) {
+	key := token.Hash()
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, ok := r.tokens[key]; !ok {
+		return
+	}
+	if prev, ok := r.notifications[key]; ok {
+		delete(r.mids, prev)
+	}
+	r.notifications[key] = mid
+	r.mids[mid] = key
+}
+
+// RemoveByMessageID removes the observation whose last notification has the message ID, e.g. when the peer rejected
+// the notification by the Reset message or the Confirmable notification was not acknowledged (RFC 7641 section 3.6,
+// section 4.5).
+func (r *Registrations) RemoveByMessageID(mid int32) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if key, ok := r.mids[mid]; ok {
+		r.removeLocked(key)
+	}
+}
+
+// Tokens returns the tokens of the registered observations.
+func (r *Registrations) Tokens() []message.Token {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	tokens := make([]message.Token, 0, len(r.tokens))
+	for _, t := range r.tokens {
+		tokens = append(tokens, t)
+	}
+	return tokens
+}
+
+// Remove removes the observation with the token, e.g. when the peer was told to register it elsewhere.
+func (r *Registrations) Remove(token message.Token) {
+	r.remove(token.Hash())
+}
+
+// Release removes all observations, it is called when the connection is closed.
+func (r *Registrations) Release() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if len(r.tokens) > 0 {
+		r.metrics.AddObservations(-len(r.tokens))
+		r.tokens = make(map[uint64]message.Token)
+	}
+	r.notifications = make(map[uint64]int32)
+	r.mids = make(map[int32]uint64)
+}
+
+// LimitRegistrations wraps the handler of one connection, so the registrations of the observations over
+// maxObservations are rejected by 5.03 (Service Unavailable) without calling the handler. The observation is counted
+// when the handler accepts it by the success response with the Observe option, and it is released by the deregistration
+// (Observe: 1) or by the close of the connection. Zero maxObservations means no limit.
+func LimitRegistrations[C responsewriter.Client](maxObservations uint32, handler func(w *responsewriter.ResponseWriter[C], r *pool.Message), errors func(error)) func(w *responsewriter.ResponseWriter[C], r *pool.Message) {
+	h, _ := TrackRegistrations(maxObservations, nil, handler, errors)
+	return h
+}
+
+// TrackRegistrations works as LimitRegistrations and it also reports the number of the registered observations
+// to the collector. The returned release function reports the remaining observations as deregistered,
+// it must be called when the connection is closed.
+func TrackRegistrations[C responsewriter.Client](maxObservations uint32, collector metrics.Collector, handler func(w *responsewriter.ResponseWriter[C], r *pool.Message), errors func(error)) (func(w *responsewriter.ResponseWriter[C], r *pool.Message), func()) {
+	h, regs := RegistrationsHandler(maxObservations, collector, handler, errors)
+	return h, regs.Release
+}
+
+// RegistrationsHandler works as TrackRegistrations, but it returns the registrations, so the observations registered
+// by the peer can be listed, e.g. to migrate the observers to another server. Registrations.Release must be called
+// when the connection is closed.
+func RegistrationsHandler[C responsewriter.Client](maxObservations uint32, collector metrics.Collector, handler func(w *responsewriter.ResponseWriter[C], r *pool.Message), errors func(error)) (func(w *responsewriter.ResponseWriter[C], r *pool.Message), *Registrations) {
+	regs := &Registrations{
+		max:           maxObservations,
+		tokens:        make(map[uint64]message.Token),
+		notifications: make(map[uint64]int32),
+		mids:          make(map[int32]uint64),
+		metrics:       metrics.OrNil(collector),
+	}
+	return func(w *responsewriter.ResponseWriter[C], r *pool.Message) {
+		action := r.ObserveAction()
+		if action == message.ObserveNone {
+			handler(w, r)
+			return
+		}
+		key := r.Token().Hash()
+		if action != message.ObserveRegister {
+			regs.remove(key)
+			handler(w, r)
+			return
+		}
+		if !regs.canRegister(key) {
+			if errS := w.SetResponse(codes.ServiceUnavailable, message.TextPlain, nil); errS != nil {
+				errors(fmt.Errorf("cannot reject observation over the limit: %w", errS))
+			}
+			return
+		}
+		handler(w, r)
+		resp := w.Message()
+		if resp.HasOption(message.Observe) && resp.Code() >= codes.Created && resp.Code() < codes.BadRequest {
+			regs.add(key, r.Token())
+		} else {
+			regs.remove(key)
+		}
+	}, regs
+}
@@ -1,6 +1,9 @@
 package net
 
-import "net"
+import (
+	"net"
+	"time"
+)
 
 // A UDPOption sets options such as errors parameters, etc.
 type UDPOption interface {
@@ -44,6 +47,11 @@ type MulticastOptions struct {
 	Source         *net.IP
 	HopLimit       int
 	InterfaceError InterfaceError
+	// DedicatedListener is used by the discovery to receive the responses on the temporary socket.
+	DedicatedListener bool
+	// RepeatCount and RepeatInterval are used by the discovery to send the request multiple times.
+	RepeatCount    int
+	RepeatInterval time.Duration
 }
 
 func (m *MulticastOptions) Apply(o MulticastOption) {
@@ -120,3 +128,33 @@ func (m MulticastInterfaceErrorOpt) applyMC(o *MulticastOptions) {
 func WithMulticastInterfaceError(interfaceError InterfaceError) MulticastOption {
 	return &MulticastInterfaceErrorOpt{interfaceError: interfaceError}
 }
+
+type MulticastDedicatedListenerOpt struct{}
+
+func (m MulticastDedicatedListenerOpt) applyMC(o *MulticastOptions) {
+	o.DedicatedListener = true
+}
+
+// WithMulticastDedicatedListener sends the discovery request from the temporary socket which is created for the request
+// and closed when the discovery ends, so the responses don't interleave with the other traffic of the server.
+// The option is ignored by WriteMulticast.
+func WithMulticastDedicatedListener() MulticastOption {
+	return &MulticastDedicatedListenerOpt{}
+}
+
+type MulticastRepeatOpt struct {
+	count    int
+	interval time.Duration
+}
+
+func (m MulticastRepeatOpt) applyMC(o *MulticastOptions) {
+	o.RepeatCount = m.count
+	o.RepeatInterval = m.interval
+}
+
+// WithMulticastRepeat sends the discovery request count times in total with the interval between the sends, so the devices
+// which dropped the request are discovered too. The responses of the same responder (by the address) are passed
+// to the receiver only once. The option is ignored by WriteMulticast.
+func WithMulticastRepeat(count int, interval time.Duration) MulticastOption {
+	return &MulticastRepeatOpt{count: count, interval: interval}
+}
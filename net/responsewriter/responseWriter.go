@@ -1,6 +1,7 @@
 package responsewriter
 
 import (
+	"fmt"
 	"io"
 
 	"github.com/plgd-dev/go-coap/v3/message"
@@ -18,6 +19,7 @@ type ResponseWriter[C Client] struct {
 	noResponseValue *uint32
 	response        *pool.Message
 	cc              C
+	getETag         message.GetETagFunc
 }
 
 func New[C Client](response *pool.Message, cc C, requestOptions ...message.Option) *ResponseWriter[C] {
@@ -38,6 +40,7 @@ func New[C Client](response *pool.Message, cc C, requestOptions ...message.Optio
 }
 
 // SetResponse simplifies the setup of the response for the request. ETags must be set via options. For advanced setup, use Message().
+// The body of unknown size, e.g. streamed from a backend, can be set via message.NewStreamBody.
 func (r *ResponseWriter[C]) SetResponse(code codes.Code, contentFormat message.MediaType, d io.ReadSeeker, opts ...message.Option) error {
 	if r.noResponseValue != nil {
 		err := noresponse.IsNoResponseCode(code, *r.noResponseValue)
@@ -55,6 +58,31 @@ func (r *ResponseWriter[C]) SetResponse(code codes.Code, contentFormat message.M
 	return nil
 }
 
+// SetResponseWithETag works as SetResponse, but it also sets the ETag option calculated from the body d.
+// By default, the ETag is calculated via message.GetETag, use SetGetETag to change it.
+func (r *ResponseWriter[C]) SetResponseWithETag(code codes.Code, contentFormat message.MediaType, d io.ReadSeeker, opts ...message.Option) error {
+	getETag := r.getETag
+	if getETag == nil {
+		getETag = message.GetETag
+	}
+	etag, err := getETag(d)
+	if err != nil {
+		return fmt.Errorf("cannot calculate etag: %w", err)
+	}
+	if !message.VerifyOptLen(message.ETag, len(etag)) {
+		return fmt.Errorf("invalid etag length(%v): %w", len(etag), message.ErrInvalidValueLength)
+	}
+	if err = r.SetResponse(code, contentFormat, d, opts...); err != nil {
+		return err
+	}
+	return r.response.SetETag(etag)
+}
+
+// SetGetETag sets the function used by SetResponseWithETag to calculate the ETag.
+func (r *ResponseWriter[C]) SetGetETag(getETag message.GetETagFunc) {
+	r.getETag = getETag
+}
+
 // SetMessage replaces the response message. The original message was released to the message pool, so don't use it any more. Ensure that Token, MessageID(udp), and Type(udp) messages are paired correctly.
 func (r *ResponseWriter[C]) SetMessage(m *pool.Message) {
 	r.cc.ReleaseMessage(r.response)
@@ -0,0 +1,49 @@
+package responsewriter_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+	"github.com/plgd-dev/go-coap/v3/net/responsewriter"
+	"github.com/stretchr/testify/require"
+)
+
+type nilClient struct{}
+
+func (nilClient) ReleaseMessage(*pool.Message) {}
+
+func TestResponseWriterSetResponseWithETag(t *testing.T) {
+	body := []byte("hello")
+	w := responsewriter.New(pool.NewMessage(context.Background()), nilClient{})
+	err := w.SetResponseWithETag(codes.Content, message.TextPlain, bytes.NewReader(body))
+	require.NoError(t, err)
+	etag, err := w.Message().ETag()
+	require.NoError(t, err)
+	want, err := message.GetETag(bytes.NewReader(body))
+	require.NoError(t, err)
+	require.Equal(t, want, etag)
+	require.Equal(t, codes.Content, w.Message().Code())
+	got, err := w.Message().ReadBody()
+	require.NoError(t, err)
+	require.Equal(t, body, got)
+
+	w.SetGetETag(func(io.ReadSeeker) ([]byte, error) {
+		return []byte{1, 2}, nil
+	})
+	err = w.SetResponseWithETag(codes.Content, message.TextPlain, bytes.NewReader(body))
+	require.NoError(t, err)
+	etag, err = w.Message().ETag()
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2}, etag)
+
+	w.SetGetETag(func(io.ReadSeeker) ([]byte, error) {
+		return make([]byte, 9), nil
+	})
+	err = w.SetResponseWithETag(codes.Content, message.TextPlain, bytes.NewReader(body))
+	require.Error(t, err)
+}
@@ -0,0 +1,199 @@
+package net
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+)
+
+// ErrSendQueueFull is returned by the write when the send queue is full and the policy is SendQueueOverflowError.
+var ErrSendQueueFull = errors.New("send queue is full")
+
+// SendQueueOverflowPolicy defines what happens with the message written to the full send queue.
+type SendQueueOverflowPolicy uint8
+
+const (
+	// SendQueueOverflowBlock blocks the writer until there is room for the message in the queue or its context is done.
+	SendQueueOverflowBlock SendQueueOverflowPolicy = iota
+	// SendQueueOverflowDropOldest drops the oldest message waiting in the queue, so the newest message is queued.
+	SendQueueOverflowDropOldest
+	// SendQueueOverflowDropNewest drops the written message.
+	SendQueueOverflowDropNewest
+	// SendQueueOverflowError rejects the written message by ErrSendQueueFull.
+	SendQueueOverflowError
+)
+
+var sendQueueOverflowPolicyToString = map[SendQueueOverflowPolicy]string{
+	SendQueueOverflowBlock:      "Block",
+	SendQueueOverflowDropOldest: "DropOldest",
+	SendQueueOverflowDropNewest: "DropNewest",
+	SendQueueOverflowError:      "Error",
+}
+
+func (p SendQueueOverflowPolicy) String() string {
+	str, ok := sendQueueOverflowPolicyToString[p]
+	if !ok {
+		return "SendQueueOverflowPolicy(" + strconv.FormatInt(int64(p), 10) + ")"
+	}
+	return str
+}
+
+// SendQueue bounds the number of messages written to the connection at once: the message being written
+// and the messages waiting for it. The writes are performed in the FIFO order. The dropped message is not written
+// and its writer gets nil, as if the message was lost by the network.
+//
+// Multiple goroutines may invoke methods on a SendQueue simultaneously.
+type SendQueue struct {
+	size   int
+	policy SendQueueOverflowPolicy
+
+	mutex   sync.Mutex
+	busy    bool          // guarded by mutex
+	waiting []chan bool   // guarded by mutex, true passes the turn to write, false drops the message
+	idle    chan struct{} // guarded by mutex, closed when the queue drains, nil when nobody waits for it
+	room    chan struct{} // guarded by mutex, closed when a message leaves the queue, nil when nobody waits for it
+}
+
+// NewSendQueue creates the send queue for size messages with the overflow policy.
+func NewSendQueue(size int, policy SendQueueOverflowPolicy) *SendQueue {
+	return &SendQueue{
+		size:   size,
+		policy: policy,
+	}
+}
+
+// Len returns the number of the messages in the queue, including the message being written.
+func (q *SendQueue) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if !q.busy {
+		return 0
+	}
+	return 1 + len(q.waiting)
+}
+
+// Write calls write when the previous writes are finished. When the queue is full, the overflow policy is applied.
+func (q *SendQueue) Write(ctx context.Context, write func() error) error {
+	q.mutex.Lock()
+	for q.policy == SendQueueOverflowBlock && q.isFull() {
+		if q.room == nil {
+			q.room = make(chan struct{})
+		}
+		room := q.room
+		q.mutex.Unlock()
+		select {
+		case <-room:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		q.mutex.Lock()
+	}
+	if !q.busy {
+		q.busy = true
+		q.mutex.Unlock()
+		defer q.release()
+		return write()
+	}
+	if q.isFull() {
+		switch q.policy {
+		case SendQueueOverflowError:
+			q.mutex.Unlock()
+			return ErrSendQueueFull
+		case SendQueueOverflowDropNewest:
+			q.mutex.Unlock()
+			return nil
+		case SendQueueOverflowDropOldest:
+			if len(q.waiting) == 0 {
+				// the message being written cannot be dropped
+				q.mutex.Unlock()
+				return nil
+			}
+			q.waiting[0] <- false
+			q.waiting = q.waiting[1:]
+		}
+	}
+	turn := make(chan bool, 1)
+	q.waiting = append(q.waiting, turn)
+	q.mutex.Unlock()
+
+	select {
+	case ok := <-turn:
+		if !ok {
+			return nil
+		}
+		defer q.release()
+		return write()
+	case <-ctx.Done():
+		if !q.remove(turn) && <-turn {
+			// the turn was passed meanwhile, so it is passed to the next message
+			q.release()
+		}
+		return ctx.Err()
+	}
+}
+
+// Flush blocks until all the messages in the queue, including the messages written meanwhile, are written or dropped.
+// It returns the error of the ctx when the ctx is done before the queue drains.
+func (q *SendQueue) Flush(ctx context.Context) error {
+	q.mutex.Lock()
+	if !q.busy {
+		q.mutex.Unlock()
+		return nil
+	}
+	if q.idle == nil {
+		q.idle = make(chan struct{})
+	}
+	idle := q.idle
+	q.mutex.Unlock()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isFull reports whether the queue has no room for the next message. The mutex must be held.
+func (q *SendQueue) isFull() bool {
+	return q.busy && 1+len(q.waiting) >= q.size
+}
+
+// notifyRoom wakes up the writers blocked by the full queue. The mutex must be held.
+func (q *SendQueue) notifyRoom() {
+	if q.room != nil {
+		close(q.room)
+		q.room = nil
+	}
+}
+
+// remove removes the waiting message from the queue, it returns false when it was already removed.
+func (q *SendQueue) remove(turn chan bool) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for i, w := range q.waiting {
+		if w == turn {
+			q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+			q.notifyRoom()
+			return true
+		}
+	}
+	return false
+}
+
+// release passes the turn to the oldest waiting message.
+func (q *SendQueue) release() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.notifyRoom()
+	if len(q.waiting) == 0 {
+		q.busy = false
+		if q.idle != nil {
+			close(q.idle)
+			q.idle = nil
+		}
+		return
+	}
+	q.waiting[0] <- true
+	q.waiting = q.waiting[1:]
+}
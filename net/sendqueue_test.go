@@ -0,0 +1,176 @@
+package net
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fillSendQueue starts the write which blocks until the returned function is called.
+func fillSendQueue(q *SendQueue) (unblock func(), done <-chan error) {
+	started := make(chan struct{})
+	block := make(chan struct{})
+	errC := make(chan error, 1)
+	go func() {
+		errC <- q.Write(context.Background(), func() error {
+			close(started)
+			<-block
+			return nil
+		})
+	}()
+	<-started
+	return func() { close(block) }, errC
+}
+
+func waitSendQueueLen(t *testing.T, q *SendQueue, n int) {
+	require.Eventually(t, func() bool {
+		return q.Len() == n
+	}, time.Second, time.Millisecond)
+}
+
+func TestSendQueueOverflowError(t *testing.T) {
+	q := NewSendQueue(1, SendQueueOverflowError)
+	unblock, done := fillSendQueue(q)
+	err := q.Write(context.Background(), func() error {
+		require.Fail(t, "message must not be written")
+		return nil
+	})
+	require.ErrorIs(t, err, ErrSendQueueFull)
+	unblock()
+	require.NoError(t, <-done)
+	require.Equal(t, 0, q.Len())
+}
+
+func TestSendQueueOverflowDropNewest(t *testing.T) {
+	q := NewSendQueue(2, SendQueueOverflowDropNewest)
+	unblock, done := fillSendQueue(q)
+	var written []int
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err := q.Write(context.Background(), func() error {
+			written = append(written, 1)
+			return nil
+		})
+		require.NoError(t, err)
+	}()
+	waitSendQueueLen(t, q, 2)
+	// the queue is full, so the message is dropped
+	err := q.Write(context.Background(), func() error {
+		written = append(written, 2)
+		return nil
+	})
+	require.NoError(t, err)
+	unblock()
+	wg.Wait()
+	require.NoError(t, <-done)
+	require.Equal(t, []int{1}, written)
+}
+
+func TestSendQueueOverflowDropOldest(t *testing.T) {
+	q := NewSendQueue(2, SendQueueOverflowDropOldest)
+	unblock, done := fillSendQueue(q)
+	var mutex sync.Mutex
+	var written []int
+	write := func(i int) func() error {
+		return func() error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			written = append(written, i)
+			return nil
+		}
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	dropped := make(chan struct{})
+	go func() {
+		defer close(dropped)
+		require.NoError(t, q.Write(context.Background(), write(1)))
+	}()
+	waitSendQueueLen(t, q, 2)
+	go func() {
+		defer wg.Done()
+		require.NoError(t, q.Write(context.Background(), write(2)))
+	}()
+	// the message 1 is dropped, its writer returns when the message 2 is queued instead of it
+	<-dropped
+	require.Equal(t, 2, q.Len())
+	unblock()
+	wg.Wait()
+	require.NoError(t, <-done)
+	require.Equal(t, []int{2}, written)
+}
+
+func TestSendQueueOverflowBlock(t *testing.T) {
+	q := NewSendQueue(1, SendQueueOverflowBlock)
+	unblock, done := fillSendQueue(q)
+
+	written := make(chan struct{})
+	go func() {
+		errW := q.Write(context.Background(), func() error {
+			close(written)
+			return nil
+		})
+		require.NoError(t, errW)
+	}()
+	// the writer waits for the room in the queue without exceeding its size
+	require.Eventually(t, func() bool {
+		q.mutex.Lock()
+		defer q.mutex.Unlock()
+		return q.room != nil
+	}, time.Second, time.Millisecond)
+	require.Equal(t, 1, q.Len())
+
+	// the writer waits until its context is done
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	err := q.Write(ctx, func() error {
+		require.Fail(t, "message must not be written")
+		return nil
+	})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	unblock()
+	require.NoError(t, <-done)
+	<-written
+	waitSendQueueLen(t, q, 0)
+}
+
+func TestSendQueueFlush(t *testing.T) {
+	q := NewSendQueue(2, SendQueueOverflowBlock)
+	// the empty queue is flushed immediately
+	require.NoError(t, q.Flush(context.Background()))
+
+	unblock, done := fillSendQueue(q)
+	written := make(chan struct{})
+	go func() {
+		errW := q.Write(context.Background(), func() error {
+			close(written)
+			return nil
+		})
+		require.NoError(t, errW)
+	}()
+	waitSendQueueLen(t, q, 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	require.ErrorIs(t, q.Flush(ctx), context.DeadlineExceeded)
+
+	flushed := make(chan error, 1)
+	go func() {
+		flushed <- q.Flush(context.Background())
+	}()
+	unblock()
+	require.NoError(t, <-flushed)
+	// the queued message is written before the flush returns
+	select {
+	case <-written:
+	default:
+		require.Fail(t, "queued message must be written")
+	}
+	require.NoError(t, <-done)
+	require.Equal(t, 0, q.Len())
+}
@@ -7,13 +7,17 @@ import (
 	"time"
 
 	dtlsServer "github.com/plgd-dev/go-coap/v3/dtls/server"
+	"github.com/plgd-dev/go-coap/v3/message"
 	"github.com/plgd-dev/go-coap/v3/message/pool"
 	"github.com/plgd-dev/go-coap/v3/mux"
+	coapNet "github.com/plgd-dev/go-coap/v3/net"
 	"github.com/plgd-dev/go-coap/v3/net/blockwise"
 	"github.com/plgd-dev/go-coap/v3/net/client"
+	"github.com/plgd-dev/go-coap/v3/net/metrics"
 	"github.com/plgd-dev/go-coap/v3/net/monitor/inactivity"
 	"github.com/plgd-dev/go-coap/v3/net/responsewriter"
 	"github.com/plgd-dev/go-coap/v3/options/config"
+	"github.com/plgd-dev/go-coap/v3/pkg/goroutine"
 	"github.com/plgd-dev/go-coap/v3/pkg/runner/periodic"
 	tcpClient "github.com/plgd-dev/go-coap/v3/tcp/client"
 	tcpServer "github.com/plgd-dev/go-coap/v3/tcp/server"
@@ -276,6 +280,75 @@ func WithProcessReceivedMessageFunc[C responsewriter.Client](processReceivedMess
 	return ProcessReceivedMessageOpt[C]{ProcessReceivedMessageFunc: processReceivedMessageFunc}
 }
 
+// OnTransportErrorOpt transport error option.
+type OnTransportErrorOpt[C responsewriter.Client] struct {
+	onTransportError config.TransportErrorFunc[C]
+}
+
+func panicForInvalidTransportErrorFunc(t, exp any) {
+	panic(fmt.Errorf("invalid TransportErrorFunc type %T, expected %T", t, exp))
+}
+
+func (o OnTransportErrorOpt[C]) TCPServerApply(cfg *tcpServer.Config) {
+	switch v := any(o.onTransportError).(type) {
+	case config.TransportErrorFunc[*tcpClient.Conn]:
+		cfg.OnTransportError = v
+	default:
+		var t config.TransportErrorFunc[*tcpClient.Conn]
+		panicForInvalidTransportErrorFunc(v, t)
+	}
+}
+
+func (o OnTransportErrorOpt[C]) TCPClientApply(cfg *tcpClient.Config) {
+	switch v := any(o.onTransportError).(type) {
+	case config.TransportErrorFunc[*tcpClient.Conn]:
+		cfg.OnTransportError = v
+	default:
+		var t config.TransportErrorFunc[*tcpClient.Conn]
+		panicForInvalidTransportErrorFunc(v, t)
+	}
+}
+
+func (o OnTransportErrorOpt[C]) UDPServerApply(cfg *udpServer.Config) {
+	switch v := any(o.onTransportError).(type) {
+	case config.TransportErrorFunc[*udpClient.Conn]:
+		cfg.OnTransportError = v
+	default:
+		var t config.TransportErrorFunc[*udpClient.Conn]
+		panicForInvalidTransportErrorFunc(v, t)
+	}
+}
+
+func (o OnTransportErrorOpt[C]) DTLSServerApply(cfg *dtlsServer.Config) {
+	switch v := any(o.onTransportError).(type) {
+	case config.TransportErrorFunc[*udpClient.Conn]:
+		cfg.OnTransportError = v
+	default:
+		var t config.TransportErrorFunc[*udpClient.Conn]
+		panicForInvalidTransportErrorFunc(v, t)
+	}
+}
+
+func (o OnTransportErrorOpt[C]) UDPClientApply(cfg *udpClient.Config) {
+	switch v := any(o.onTransportError).(type) {
+	case config.TransportErrorFunc[*udpClient.Conn]:
+		cfg.OnTransportError = v
+	default:
+		var t config.TransportErrorFunc[*udpClient.Conn]
+		panicForInvalidTransportErrorFunc(v, t)
+	}
+}
+
+// WithOnTransportError calls onTransportError with the errors of reading from and writing to the connections, e.g.
+// to distinguish the flaky link from the dead one. The fatal errors closed the connection: the read error, the peer
+// which reset the connection, the malformed TCP frame and the failed write of the response. The recoverable
+// errors keep the connection open: the failed retransmission of the Confirmable message, which is retransmitted again
+// after the next timeout, the failed write of the empty ACK and the malformed datagram, which is dropped (UDP and DTLS). The connection closed by Close,
+// by the inactivity monitor or by the stop of the server is not reported. The function must not block.
+func WithOnTransportError[C responsewriter.Client](onTransportError config.TransportErrorFunc[C]) OnTransportErrorOpt[C] {
+	return OnTransportErrorOpt[C]{onTransportError: onTransportError}
+}
+
 type (
 	UDPOnInactive = func(cc *udpClient.Conn)
 	TCPOnInactive = func(cc *tcpClient.Conn)
@@ -543,6 +616,175 @@ func WithBlockwise(enable bool, szx blockwise.SZX, transferTimeout time.Duration
 	}
 }
 
+// MaxBlockSZXOpt network option.
+type MaxBlockSZXOpt struct {
+	szx blockwise.SZX
+}
+
+func (o MaxBlockSZXOpt) UDPServerApply(cfg *udpServer.Config) {
+	cfg.BlockwiseSZX = o.szx
+}
+
+func (o MaxBlockSZXOpt) DTLSServerApply(cfg *dtlsServer.Config) {
+	cfg.BlockwiseSZX = o.szx
+}
+
+func (o MaxBlockSZXOpt) UDPClientApply(cfg *udpClient.Config) {
+	cfg.BlockwiseSZX = o.szx
+}
+
+func (o MaxBlockSZXOpt) TCPServerApply(cfg *tcpServer.Config) {
+	cfg.BlockwiseSZX = o.szx
+}
+
+func (o MaxBlockSZXOpt) TCPClientApply(cfg *tcpClient.Config) {
+	cfg.BlockwiseSZX = o.szx
+}
+
+// WithMaxBlockSZX caps the block size of blockwise transfers without changing other blockwise settings.
+// A server replies with the smaller block size when the peer requests a bigger one and
+// the peer continues the transfer with it (RFC 7959 section 2.3).
+// It sets the same value as the szx of WithBlockwise, so when both options are used the one applied later wins.
+func WithMaxBlockSZX(szx blockwise.SZX) MaxBlockSZXOpt {
+	return MaxBlockSZXOpt{
+		szx: szx,
+	}
+}
+
+// BlockwiseBufferAllocatorOpt blockwise buffer allocator option.
+type BlockwiseBufferAllocatorOpt struct {
+	allocator blockwise.BufferAllocator
+}
+
+func (o BlockwiseBufferAllocatorOpt) UDPServerApply(cfg *udpServer.Config) {
+	cfg.BlockwiseBufferAllocator = o.allocator
+}
+
+func (o BlockwiseBufferAllocatorOpt) DTLSServerApply(cfg *dtlsServer.Config) {
+	cfg.BlockwiseBufferAllocator = o.allocator
+}
+
+func (o BlockwiseBufferAllocatorOpt) UDPClientApply(cfg *udpClient.Config) {
+	cfg.BlockwiseBufferAllocator = o.allocator
+}
+
+func (o BlockwiseBufferAllocatorOpt) TCPServerApply(cfg *tcpServer.Config) {
+	cfg.BlockwiseBufferAllocator = o.allocator
+}
+
+func (o BlockwiseBufferAllocatorOpt) TCPClientApply(cfg *tcpClient.Config) {
+	cfg.BlockwiseBufferAllocator = o.allocator
+}
+
+// WithBlockwiseBufferAllocator sets the allocator of the buffers used for the reassembly of the received blockwise payloads.
+// When it is nil, the buffers are allocated from the heap.
+func WithBlockwiseBufferAllocator(allocator blockwise.BufferAllocator) BlockwiseBufferAllocatorOpt {
+	return BlockwiseBufferAllocatorOpt{
+		allocator: allocator,
+	}
+}
+
+// BlockwiseMaxRequestBodySizeOpt blockwise max request body size option.
+type BlockwiseMaxRequestBodySizeOpt struct {
+	size uint32
+}
+
+func (o BlockwiseMaxRequestBodySizeOpt) UDPServerApply(cfg *udpServer.Config) {
+	cfg.BlockwiseMaxRequestBodySize = o.size
+}
+
+func (o BlockwiseMaxRequestBodySizeOpt) DTLSServerApply(cfg *dtlsServer.Config) {
+	cfg.BlockwiseMaxRequestBodySize = o.size
+}
+
+func (o BlockwiseMaxRequestBodySizeOpt) UDPClientApply(cfg *udpClient.Config) {
+	cfg.BlockwiseMaxRequestBodySize = o.size
+}
+
+func (o BlockwiseMaxRequestBodySizeOpt) TCPServerApply(cfg *tcpServer.Config) {
+	cfg.BlockwiseMaxRequestBodySize = o.size
+}
+
+func (o BlockwiseMaxRequestBodySizeOpt) TCPClientApply(cfg *tcpClient.Config) {
+	cfg.BlockwiseMaxRequestBodySize = o.size
+}
+
+// WithBlockwiseMaxRequestBodySize limits the size of the body received by the blockwise transfer (Block1).
+// Requests which announce a bigger body by Size1 or which exceed the limit are rejected by 4.13 (Request Entity Too Large)
+// before the rest of the body is transferred. Zero means no limit.
+func WithBlockwiseMaxRequestBodySize(size uint32) BlockwiseMaxRequestBodySizeOpt {
+	return BlockwiseMaxRequestBodySizeOpt{
+		size: size,
+	}
+}
+
+// MaxConcurrentBlockwiseOpt max concurrent blockwise transfers option.
+type MaxConcurrentBlockwiseOpt struct {
+	n uint32
+}
+
+func (o MaxConcurrentBlockwiseOpt) UDPServerApply(cfg *udpServer.Config) {
+	cfg.BlockwiseMaxConcurrentTransfers = o.n
+}
+
+func (o MaxConcurrentBlockwiseOpt) DTLSServerApply(cfg *dtlsServer.Config) {
+	cfg.BlockwiseMaxConcurrentTransfers = o.n
+}
+
+func (o MaxConcurrentBlockwiseOpt) UDPClientApply(cfg *udpClient.Config) {
+	cfg.BlockwiseMaxConcurrentTransfers = o.n
+}
+
+func (o MaxConcurrentBlockwiseOpt) TCPServerApply(cfg *tcpServer.Config) {
+	cfg.BlockwiseMaxConcurrentTransfers = o.n
+}
+
+func (o MaxConcurrentBlockwiseOpt) TCPClientApply(cfg *tcpClient.Config) {
+	cfg.BlockwiseMaxConcurrentTransfers = o.n
+}
+
+// WithMaxConcurrentBlockwise limits the number of the request bodies received by the blockwise transfer (Block1)
+// at the same time per connection, e.g. to bound the memory used by the reassembly of many half-finished uploads.
+// The new transfer beyond the limit is rejected by 5.03 (Service Unavailable). Zero means no limit.
+func WithMaxConcurrentBlockwise(n uint32) MaxConcurrentBlockwiseOpt {
+	return MaxConcurrentBlockwiseOpt{
+		n: n,
+	}
+}
+
+// PerBlockTimeoutOpt per block timeout option.
+type PerBlockTimeoutOpt struct {
+	timeout time.Duration
+}
+
+func (o PerBlockTimeoutOpt) UDPServerApply(cfg *udpServer.Config) {
+	cfg.BlockwisePerBlockTimeout = o.timeout
+}
+
+func (o PerBlockTimeoutOpt) DTLSServerApply(cfg *dtlsServer.Config) {
+	cfg.BlockwisePerBlockTimeout = o.timeout
+}
+
+func (o PerBlockTimeoutOpt) UDPClientApply(cfg *udpClient.Config) {
+	cfg.BlockwisePerBlockTimeout = o.timeout
+}
+
+func (o PerBlockTimeoutOpt) TCPServerApply(cfg *tcpServer.Config) {
+	cfg.BlockwisePerBlockTimeout = o.timeout
+}
+
+func (o PerBlockTimeoutOpt) TCPClientApply(cfg *tcpClient.Config) {
+	cfg.BlockwisePerBlockTimeout = o.timeout
+}
+
+// WithPerBlockTimeout limits the round trip of each block of the blockwise transfer of the request, so the stalled
+// block fails the request by blockwise.ErrBlockTimeout before the deadline of its context. Zero means no limit.
+func WithPerBlockTimeout(d time.Duration) PerBlockTimeoutOpt {
+	return PerBlockTimeoutOpt{
+		timeout: d,
+	}
+}
+
 type OnNewConnFunc interface {
 	tcpServer.OnNewConnFunc | udpServer.OnNewConnFunc
 }
@@ -593,6 +835,122 @@ func WithOnNewConn[F OnNewConnFunc](onNewConn F) OnNewConnOpt[F] {
 	}
 }
 
+// OnCloseConnFunc is the constraint of the callbacks for closed connections.
+type OnCloseConnFunc interface {
+	tcpServer.OnCloseConnFunc | udpServer.OnCloseConnFunc
+}
+
+// OnCloseConnOpt network option.
+type OnCloseConnOpt[F OnCloseConnFunc] struct {
+	f F
+}
+
+func panicForInvalidOnCloseConnFunc(t, exp any) {
+	panic(fmt.Errorf("invalid OnCloseConnFunc type %T, expected %T", t, exp))
+}
+
+func (o OnCloseConnOpt[F]) UDPServerApply(cfg *udpServer.Config) {
+	switch v := any(o.f).(type) {
+	case udpServer.OnCloseConnFunc:
+		cfg.OnCloseConn = v
+	default:
+		var exp udpServer.OnCloseConnFunc
+		panicForInvalidOnCloseConnFunc(v, exp)
+	}
+}
+
+func (o OnCloseConnOpt[F]) DTLSServerApply(cfg *dtlsServer.Config) {
+	switch v := any(o.f).(type) {
+	case udpServer.OnCloseConnFunc:
+		cfg.OnCloseConn = v
+	default:
+		var exp udpServer.OnCloseConnFunc
+		panicForInvalidOnCloseConnFunc(v, exp)
+	}
+}
+
+func (o OnCloseConnOpt[F]) TCPServerApply(cfg *tcpServer.Config) {
+	switch v := any(o.f).(type) {
+	case tcpServer.OnCloseConnFunc:
+		cfg.OnCloseConn = v
+	default:
+		var exp tcpServer.OnCloseConnFunc
+		panicForInvalidOnCloseConnFunc(v, exp)
+	}
+}
+
+// WithOnCloseConn server's notify about closed client connection with the reason of the close,
+// e.g. idle timeout, peer reset, transport error or server shutdown. The idle timeout is reported when the callback
+// of the inactivity monitor closes the connection by CloseWithReason(coapNet.CloseReasonIdleTimeout), as the default one does.
+func WithOnCloseConn[F OnCloseConnFunc](onCloseConn F) OnCloseConnOpt[F] {
+	return OnCloseConnOpt[F]{
+		f: onCloseConn,
+	}
+}
+
+// MaxConnectionsOpt network option.
+type MaxConnectionsOpt struct {
+	maxConnections uint32
+}
+
+func (o MaxConnectionsOpt) UDPServerApply(cfg *udpServer.Config) {
+	cfg.MaxConnections = o.maxConnections
+}
+
+func (o MaxConnectionsOpt) DTLSServerApply(cfg *dtlsServer.Config) {
+	cfg.MaxConnections = o.maxConnections
+}
+
+func (o MaxConnectionsOpt) TCPServerApply(cfg *tcpServer.Config) {
+	cfg.MaxConnections = o.maxConnections
+}
+
+// WithMaxConnections limits the number of the connections served by the server, 0 means unlimited.
+// When the limit is reached, the accepted TCP/DTLS connections are closed immediately and the datagrams
+// from the new UDP sources are dropped without a response. The refused connections are reported via WithErrors.
+// The dropped datagrams are counted by WithMetrics and reported via WithErrors at most once per second.
+func WithMaxConnections(n uint32) MaxConnectionsOpt {
+	return MaxConnectionsOpt{
+		maxConnections: n,
+	}
+}
+
+// MaxGoroutinesOpt network option.
+type MaxGoroutinesOpt struct {
+	goroutines *goroutine.Limiter
+}
+
+func (o MaxGoroutinesOpt) UDPServerApply(cfg *udpServer.Config) {
+	cfg.Goroutines = o.goroutines
+}
+
+func (o MaxGoroutinesOpt) DTLSServerApply(cfg *dtlsServer.Config) {
+	cfg.Goroutines = o.goroutines
+}
+
+func (o MaxGoroutinesOpt) TCPServerApply(cfg *tcpServer.Config) {
+	cfg.Goroutines = o.goroutines
+}
+
+// WithMaxGoroutines limits the goroutines of the connections served by the server, 0 only counts them. The server
+// counts these goroutines of each connection:
+//   - the goroutine which serves the TCP/DTLS connection,
+//   - the goroutines which process the received messages and call the handlers (one per connection, another one
+//     while the handler waits for a response or for its turn, see WithSerializedHandlers),
+//   - the goroutines which send the Confirmable separate responses of UDP/DTLS until they are acknowledged.
+//
+// While the limit is reached the new connections are refused as by WithMaxConnections and the number is reported
+// by the Goroutines method of the server. The goroutines of the accepted connections are never refused, so the number
+// can exceed the limit by the goroutines which process their messages. The callbacks of the timers, e.g. the empty ACK
+// of WithSeparateResponseThreshold and the blockwise per-block timeout, the periodic runner, the notification workers,
+// the goroutines of SchedulePeriodic and MigrateObservers and the goroutines started by the handlers are not counted.
+// The option can be passed to several servers to limit their goroutines together.
+func WithMaxGoroutines(n int64) MaxGoroutinesOpt {
+	return MaxGoroutinesOpt{
+		goroutines: goroutine.NewLimiter(n),
+	}
+}
+
 // WithRequestMonitor
 type WithRequestMonitorFunc interface {
 	tcpClient.RequestMonitorFunc | udpClient.RequestMonitorFunc
@@ -838,3 +1196,390 @@ func (o ReceivedMessageQueueSizeOpt) UDPClientApply(cfg *udpClient.Config) {
 func WithReceivedMessageQueueSize(receivedMessageQueueSize int) ReceivedMessageQueueSizeOpt {
 	return ReceivedMessageQueueSizeOpt{receivedMessageQueueSize: receivedMessageQueueSize}
 }
+
+// DefaultContentFormatOpt default content format option.
+type DefaultContentFormatOpt struct {
+	contentFormat message.MediaType
+}
+
+func (o DefaultContentFormatOpt) TCPClientApply(cfg *tcpClient.Config) {
+	cfg.DefaultContentFormat = &o.contentFormat
+}
+
+func (o DefaultContentFormatOpt) UDPClientApply(cfg *udpClient.Config) {
+	cfg.DefaultContentFormat = &o.contentFormat
+}
+
+// WithDefaultContentFormat sets the content format to requests with a body sent by the client
+// which don't have the content format option, e.g. sent by PostWithoutContentFormat or PutWithoutContentFormat.
+func WithDefaultContentFormat(contentFormat message.MediaType) DefaultContentFormatOpt {
+	return DefaultContentFormatOpt{
+		contentFormat: contentFormat,
+	}
+}
+
+// ClientOptionOpt client option option.
+type ClientOptionOpt struct {
+	id    message.OptionID
+	value []byte
+}
+
+func (o ClientOptionOpt) TCPClientApply(cfg *tcpClient.Config) {
+	cfg.ClientOptions = append(cfg.ClientOptions, message.Option{ID: o.id, Value: o.value})
+}
+
+func (o ClientOptionOpt) UDPClientApply(cfg *udpClient.Config) {
+	cfg.ClientOptions = append(cfg.ClientOptions, message.Option{ID: o.id, Value: o.value})
+}
+
+// WithClientOption adds the option to all requests sent by the client which don't contain the option,
+// e.g. a proprietary option identifying the client software as "myapp/1.2". It can be used multiple times
+// to add several options.
+func WithClientOption(id message.OptionID, value []byte) ClientOptionOpt {
+	return ClientOptionOpt{
+		id:    id,
+		value: value,
+	}
+}
+
+// SerializedHandlersOpt serialized handlers option.
+type SerializedHandlersOpt struct{}
+
+func (o SerializedHandlersOpt) TCPServerApply(cfg *tcpServer.Config) {
+	cfg.SerializedHandlers = true
+}
+
+func (o SerializedHandlersOpt) TCPClientApply(cfg *tcpClient.Config) {
+	cfg.SerializedHandlers = true
+}
+
+func (o SerializedHandlersOpt) UDPServerApply(cfg *udpServer.Config) {
+	cfg.SerializedHandlers = true
+}
+
+func (o SerializedHandlersOpt) DTLSServerApply(cfg *dtlsServer.Config) {
+	cfg.SerializedHandlers = true
+}
+
+func (o SerializedHandlersOpt) UDPClientApply(cfg *udpClient.Config) {
+	cfg.SerializedHandlers = true
+}
+
+// WithSerializedHandlers calls the handler for the requests of one connection one at a time in the receive order,
+// also when the handler sends a request over the same connection and waits for the response.
+// The requests of different connections are still handled concurrently. The order is not guaranteed when
+// the messages are dispatched to the handler by a custom WithProcessReceivedMessageFunc concurrently.
+func WithSerializedHandlers() SerializedHandlersOpt {
+	return SerializedHandlersOpt{}
+}
+
+// MaxObservationsPerConnOpt max observations per connection option.
+type MaxObservationsPerConnOpt struct {
+	maxObservations uint32
+}
+
+func (o MaxObservationsPerConnOpt) TCPServerApply(cfg *tcpServer.Config) {
+	cfg.MaxObservations = o.maxObservations
+}
+
+func (o MaxObservationsPerConnOpt) TCPClientApply(cfg *tcpClient.Config) {
+	cfg.MaxObservations = o.maxObservations
+}
+
+func (o MaxObservationsPerConnOpt) UDPServerApply(cfg *udpServer.Config) {
+	cfg.MaxObservations = o.maxObservations
+}
+
+func (o MaxObservationsPerConnOpt) DTLSServerApply(cfg *dtlsServer.Config) {
+	cfg.MaxObservations = o.maxObservations
+}
+
+func (o MaxObservationsPerConnOpt) UDPClientApply(cfg *udpClient.Config) {
+	cfg.MaxObservations = o.maxObservations
+}
+
+// WithMaxObservationsPerConn limits the number of the observations registered by the peer of one connection. The registrations
+// over the limit are rejected by 5.03 (Service Unavailable). An observation is released by its deregistration,
+// by the close of the connection or, over UDP, when its notification is reset or not acknowledged. 0 means no limit (default).
+func WithMaxObservationsPerConn(maxObservations uint32) MaxObservationsPerConnOpt {
+	return MaxObservationsPerConnOpt{maxObservations: maxObservations}
+}
+
+// ObserverMigrationOpt observer migration option.
+type ObserverMigrationOpt struct{}
+
+func (o ObserverMigrationOpt) TCPServerApply(cfg *tcpServer.Config) {
+	cfg.TrackObservations = true
+}
+
+func (o ObserverMigrationOpt) TCPClientApply(cfg *tcpClient.Config) {
+	cfg.TrackObservations = true
+}
+
+func (o ObserverMigrationOpt) UDPServerApply(cfg *udpServer.Config) {
+	cfg.TrackObservations = true
+}
+
+func (o ObserverMigrationOpt) DTLSServerApply(cfg *dtlsServer.Config) {
+	cfg.TrackObservations = true
+}
+
+func (o ObserverMigrationOpt) UDPClientApply(cfg *udpClient.Config) {
+	cfg.TrackObservations = true
+}
+
+// WithObserverMigration tracks the observations registered by the peers, so the observers can be moved to another
+// server by MigrateObservers. The observations are also tracked by WithMaxObservationsPerConn and WithMetrics.
+func WithObserverMigration() ObserverMigrationOpt {
+	return ObserverMigrationOpt{}
+}
+
+// NotificationWorkersOpt notification workers option.
+type NotificationWorkersOpt struct {
+	workers int
+}
+
+func (o NotificationWorkersOpt) TCPServerApply(cfg *tcpServer.Config) {
+	cfg.NotificationWorkers = o.workers
+}
+
+func (o NotificationWorkersOpt) UDPServerApply(cfg *udpServer.Config) {
+	cfg.NotificationWorkers = o.workers
+}
+
+func (o NotificationWorkersOpt) DTLSServerApply(cfg *dtlsServer.Config) {
+	cfg.NotificationWorkers = o.workers
+}
+
+// WithNotificationWorkers sets the number of goroutines of the server's NotificationScheduler which serve
+// the timed notifications of all observations. 0 means scheduler.DefaultWorkers (default).
+func WithNotificationWorkers(workers int) NotificationWorkersOpt {
+	return NotificationWorkersOpt{workers: workers}
+}
+
+// SendQueueOpt send queue option.
+type SendQueueOpt struct {
+	size   int
+	policy coapNet.SendQueueOverflowPolicy
+}
+
+func (o SendQueueOpt) TCPServerApply(cfg *tcpServer.Config) {
+	cfg.SendQueueSize = o.size
+	cfg.SendQueueOverflowPolicy = o.policy
+}
+
+func (o SendQueueOpt) TCPClientApply(cfg *tcpClient.Config) {
+	cfg.SendQueueSize = o.size
+	cfg.SendQueueOverflowPolicy = o.policy
+}
+
+func (o SendQueueOpt) UDPServerApply(cfg *udpServer.Config) {
+	cfg.SendQueueSize = o.size
+	cfg.SendQueueOverflowPolicy = o.policy
+}
+
+func (o SendQueueOpt) DTLSServerApply(cfg *dtlsServer.Config) {
+	cfg.SendQueueSize = o.size
+	cfg.SendQueueOverflowPolicy = o.policy
+}
+
+func (o SendQueueOpt) UDPClientApply(cfg *udpClient.Config) {
+	cfg.SendQueueSize = o.size
+	cfg.SendQueueOverflowPolicy = o.policy
+}
+
+// WithSendQueueSize bounds the number of messages written to each connection at once, the message being written
+// and the messages waiting for it, e.g. the notifications produced faster than the slow link can drain.
+// The policy defines what happens with the message written to the full queue, see coapNet.SendQueueOverflowPolicy.
+// The dropped messages are not reported to the writer, as if they were lost by the network. Zero size means no bound.
+func WithSendQueueSize(size int, policy coapNet.SendQueueOverflowPolicy) SendQueueOpt {
+	return SendQueueOpt{
+		size:   size,
+		policy: policy,
+	}
+}
+
+// StrictParsingOpt strict parsing option.
+type StrictParsingOpt struct{}
+
+func (o StrictParsingOpt) TCPServerApply(cfg *tcpServer.Config) {
+	cfg.StrictParsing = true
+}
+
+func (o StrictParsingOpt) TCPClientApply(cfg *tcpClient.Config) {
+	cfg.StrictParsing = true
+}
+
+func (o StrictParsingOpt) UDPServerApply(cfg *udpServer.Config) {
+	cfg.StrictParsing = true
+}
+
+func (o StrictParsingOpt) DTLSServerApply(cfg *dtlsServer.Config) {
+	cfg.StrictParsing = true
+}
+
+func (o StrictParsingOpt) UDPClientApply(cfg *udpClient.Config) {
+	cfg.StrictParsing = true
+}
+
+// WithStrictParsing rejects the received messages whose options are not in the canonical form (RFC 7252 section 3.2),
+// e.g. the uint value encoded with the leading zero byte. The rejected message is handled as the message which cannot
+// be parsed, so it is reported via WithOnParseError. The option numbers are delta-encoded on the wire, so the received
+// options are always in ascending order.
+func WithStrictParsing() StrictParsingOpt {
+	return StrictParsingOpt{}
+}
+
+// TokenLengthOpt token length option.
+type TokenLengthOpt struct {
+	tokenLength message.TokenLength
+}
+
+func (o TokenLengthOpt) TCPServerApply(cfg *tcpServer.Config) {
+	cfg.TokenLength = o.tokenLength
+}
+
+func (o TokenLengthOpt) TCPClientApply(cfg *tcpClient.Config) {
+	cfg.TokenLength = o.tokenLength
+}
+
+func (o TokenLengthOpt) UDPServerApply(cfg *udpServer.Config) {
+	cfg.TokenLength = o.tokenLength
+}
+
+func (o TokenLengthOpt) DTLSServerApply(cfg *dtlsServer.Config) {
+	cfg.TokenLength = o.tokenLength
+}
+
+func (o TokenLengthOpt) UDPClientApply(cfg *udpClient.Config) {
+	cfg.TokenLength = o.tokenLength
+}
+
+func newTokenLengthOpt(minLen, maxLen, maxTokenSize int) TokenLengthOpt {
+	if minLen < 0 || minLen > maxLen || maxLen > maxTokenSize {
+		panic(fmt.Errorf("invalid token length range(%v-%v), expected 0 <= min <= max <= %v", minLen, maxLen, maxTokenSize))
+	}
+	return TokenLengthOpt{
+		tokenLength: message.TokenLength{
+			Min: minLen,
+			Max: maxLen,
+		},
+	}
+}
+
+// WithTokenLength sets the range of the lengths of the tokens of the requests and the responses sent and received
+// by the connections, by default 0-8 bytes of RFC 7252. The message with the token out of the range is not written
+// and the error wrapping message.ErrInvalidTokenLen is returned, the received one is handled as the message which cannot
+// be parsed (see WithOnParseError). The min length, e.g. to reject the short tokens which are easy to guess, isn't
+// required from the empty messages and the signals. The tokens generated by WithGetToken must be in the range.
+// It panics unless 0 <= minLen <= maxLen <= message.MaxTokenSize.
+func WithTokenLength(minLen, maxLen int) TokenLengthOpt {
+	return newTokenLengthOpt(minLen, maxLen, message.MaxTokenSize)
+}
+
+// WithReservedTokenLength sets the range of the lengths of the tokens as WithTokenLength, but the max length up to
+// message.MaxEncodableTokenSize (15 bytes) allows the tokens reserved by RFC 7252, e.g. for the conformance tests
+// of the peers. It panics unless 0 <= minLen <= maxLen <= message.MaxEncodableTokenSize.
+func WithReservedTokenLength(minLen, maxLen int) TokenLengthOpt {
+	return newTokenLengthOpt(minLen, maxLen, message.MaxEncodableTokenSize)
+}
+
+// WireTapOpt wire tap option.
+type WireTapOpt struct {
+	wireTap config.WireTapFunc
+}
+
+func (o WireTapOpt) TCPServerApply(cfg *tcpServer.Config) {
+	cfg.WireTap = o.wireTap
+}
+
+func (o WireTapOpt) TCPClientApply(cfg *tcpClient.Config) {
+	cfg.WireTap = o.wireTap
+}
+
+func (o WireTapOpt) UDPServerApply(cfg *udpServer.Config) {
+	cfg.WireTap = o.wireTap
+}
+
+func (o WireTapOpt) DTLSServerApply(cfg *dtlsServer.Config) {
+	cfg.WireTap = o.wireTap
+}
+
+func (o WireTapOpt) UDPClientApply(cfg *udpClient.Config) {
+	cfg.WireTap = o.wireTap
+}
+
+// MetricsOpt metrics option.
+type MetricsOpt struct {
+	collector metrics.Collector
+}
+
+func (o MetricsOpt) TCPServerApply(cfg *tcpServer.Config) {
+	cfg.Metrics = o.collector
+}
+
+func (o MetricsOpt) TCPClientApply(cfg *tcpClient.Config) {
+	cfg.Metrics = o.collector
+}
+
+func (o MetricsOpt) UDPServerApply(cfg *udpServer.Config) {
+	cfg.Metrics = o.collector
+}
+
+func (o MetricsOpt) DTLSServerApply(cfg *dtlsServer.Config) {
+	cfg.Metrics = o.collector
+}
+
+func (o MetricsOpt) UDPClientApply(cfg *udpClient.Config) {
+	cfg.Metrics = o.collector
+}
+
+// WithMetrics sets the collector of the metrics of the connections: the messages sent and received by the code,
+// the retransmissions, the open connections, the observations registered by the peers and the blockwise transfers
+// in progress. The collector of the Prometheus metrics is provided by the github.com/plgd-dev/go-coap/v3/net/metrics/prometheus module.
+func WithMetrics(collector metrics.Collector) MetricsOpt {
+	return MetricsOpt{collector: collector}
+}
+
+// OnParseErrorOpt parse error option.
+type OnParseErrorOpt struct {
+	onParseError config.ParseErrorFunc
+}
+
+func (o OnParseErrorOpt) TCPServerApply(cfg *tcpServer.Config) {
+	cfg.OnParseError = o.onParseError
+}
+
+func (o OnParseErrorOpt) TCPClientApply(cfg *tcpClient.Config) {
+	cfg.OnParseError = o.onParseError
+}
+
+func (o OnParseErrorOpt) UDPServerApply(cfg *udpServer.Config) {
+	cfg.OnParseError = o.onParseError
+}
+
+func (o OnParseErrorOpt) DTLSServerApply(cfg *dtlsServer.Config) {
+	cfg.OnParseError = o.onParseError
+}
+
+func (o OnParseErrorOpt) UDPClientApply(cfg *udpClient.Config) {
+	cfg.OnParseError = o.onParseError
+}
+
+// WithOnParseError calls onParseError with raw bytes of every datagram (UDP), decrypted datagram (DTLS) or frame (TCP)
+// which cannot be parsed, e.g. because of the truncated header or the malformed option, together with the parse error.
+// The malformed datagram closes the connection and the malformed frame closes the TCP connection as before.
+// It is intended for debugging, the data must not be modified or retained.
+func WithOnParseError(onParseError config.ParseErrorFunc) OnParseErrorOpt {
+	return OnParseErrorOpt{
+		onParseError: onParseError,
+	}
+}
+
+// WithWireTap calls wireTap with raw bytes of every datagram (UDP), decrypted datagram (DTLS) or frame (TCP)
+// sent to or received from the peer. It is intended for debugging, the data must not be modified or retained.
+func WithWireTap(wireTap config.WireTapFunc) WireTapOpt {
+	return WireTapOpt{
+		wireTap: wireTap,
+	}
+}
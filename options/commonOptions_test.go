@@ -261,6 +261,7 @@ func TestCommonUDPServerApply(t *testing.T) {
 		options.WithLimitClientParallelRequest(42),
 		options.WithLimitClientEndpointParallelRequest(43),
 		options.WithReceivedMessageQueueSize(10),
+		options.WithNotificationWorkers(4),
 	}
 
 	for _, o := range opts {
@@ -298,6 +299,8 @@ func TestCommonUDPServerApply(t *testing.T) {
 	require.Equal(t, int64(43), cfg.LimitClientEndpointParallelRequests)
 	// WithReceivedMessageQueueSize
 	require.Equal(t, 10, cfg.ReceivedMessageQueueSize)
+	// WithNotificationWorkers
+	require.Equal(t, 4, cfg.NotificationWorkers)
 
 	m := mux.NewRouter()
 	keepAlive := func(*udpClient.Conn) {
@@ -512,3 +515,51 @@ func TestCommonUDPClientApply(t *testing.T) {
 	// WithKeepAlive
 	require.NotNil(t, cfg.CreateInactivityMonitor)
 }
+
+func TestMaxBlockSZXWithBlockwise(t *testing.T) {
+	// the option applied later wins
+	cfg := udpClient.Config{}
+	opts := []udp.Option{
+		options.WithBlockwise(true, blockwise.SZX1024, time.Second),
+		options.WithMaxBlockSZX(blockwise.SZX32),
+	}
+	for _, o := range opts {
+		o.UDPClientApply(&cfg)
+	}
+	require.True(t, cfg.BlockwiseEnable)
+	require.Equal(t, blockwise.SZX32, cfg.BlockwiseSZX)
+	require.Equal(t, time.Second, cfg.BlockwiseTransferTimeout)
+
+	cfg = udpClient.Config{}
+	opts = []udp.Option{
+		options.WithMaxBlockSZX(blockwise.SZX32),
+		options.WithBlockwise(true, blockwise.SZX1024, time.Second),
+	}
+	for _, o := range opts {
+		o.UDPClientApply(&cfg)
+	}
+	require.Equal(t, blockwise.SZX1024, cfg.BlockwiseSZX)
+
+	tcpCfg := client.Config{}
+	tcpOpts := []tcp.Option{
+		options.WithBlockwise(true, blockwise.SZXBERT, time.Second),
+		options.WithMaxBlockSZX(blockwise.SZX512),
+	}
+	for _, o := range tcpOpts {
+		o.TCPClientApply(&tcpCfg)
+	}
+	require.Equal(t, blockwise.SZX512, tcpCfg.BlockwiseSZX)
+}
+
+func TestTokenLength(t *testing.T) {
+	cfg := udpClient.Config{}
+	options.WithTokenLength(4, message.MaxTokenSize).UDPClientApply(&cfg)
+	require.Equal(t, message.TokenLength{Min: 4, Max: message.MaxTokenSize}, cfg.TokenLength)
+	options.WithReservedTokenLength(0, message.MaxEncodableTokenSize).UDPClientApply(&cfg)
+	require.Equal(t, message.TokenLength{Max: message.MaxEncodableTokenSize}, cfg.TokenLength)
+
+	require.Panics(t, func() { options.WithTokenLength(-1, message.MaxTokenSize) })
+	require.Panics(t, func() { options.WithTokenLength(6, 4) })
+	require.Panics(t, func() { options.WithTokenLength(0, message.MaxTokenSize+1) })
+	require.Panics(t, func() { options.WithReservedTokenLength(0, message.MaxEncodableTokenSize+1) })
+}
@@ -3,22 +3,51 @@ package config
 import (
 	"context"
 	"fmt"
+	"net"
 	"time"
 
 	"github.com/plgd-dev/go-coap/v3/message"
 	"github.com/plgd-dev/go-coap/v3/message/pool"
+	coapNet "github.com/plgd-dev/go-coap/v3/net"
 	"github.com/plgd-dev/go-coap/v3/net/blockwise"
 	"github.com/plgd-dev/go-coap/v3/net/client"
+	"github.com/plgd-dev/go-coap/v3/net/metrics"
 	"github.com/plgd-dev/go-coap/v3/net/responsewriter"
+	"github.com/plgd-dev/go-coap/v3/pkg/goroutine"
 	"github.com/plgd-dev/go-coap/v3/pkg/runner/periodic"
 )
 
+// Direction of the data captured by WireTapFunc.
+type Direction int
+
+const (
+	// DirectionReceived data was received from the peer.
+	DirectionReceived Direction = iota
+	// DirectionSent data was sent to the peer.
+	DirectionSent
+)
+
+func (d Direction) String() string {
+	if d == DirectionSent {
+		return "sent"
+	}
+	return "received"
+}
+
 type (
+	// WireTapFunc is called with raw bytes of every datagram/frame. The data must not be modified or retained.
+	WireTapFunc                                         = func(dir Direction, data []byte, addr net.Addr)
 	ErrorFunc                                           = func(error)
 	HandlerFunc[C responsewriter.Client]                func(w *responsewriter.ResponseWriter[C], r *pool.Message)
 	ProcessReceivedMessageFunc[C responsewriter.Client] func(req *pool.Message, cc C, handler HandlerFunc[C])
+	// TransportErrorFunc is called with the error of reading from or writing to the connection, fatal reports whether
+	// the error closed the connection.
+	TransportErrorFunc[C responsewriter.Client] func(cc C, err error, fatal bool)
 )
 
+// ParseErrorFunc is called with raw bytes of the datagram/frame which cannot be parsed. The data must not be modified or retained.
+type ParseErrorFunc = func(data []byte, addr net.Addr, err error)
+
 type Common[C responsewriter.Client] struct {
 	LimitClientParallelRequests         int64
 	LimitClientEndpointParallelRequests int64
@@ -31,8 +60,40 @@ type Common[C responsewriter.Client] struct {
 	BlockwiseTransferTimeout            time.Duration
 	BlockwiseSZX                        blockwise.SZX
 	BlockwiseEnable                     bool
+	BlockwiseBufferAllocator            blockwise.BufferAllocator
+	BlockwiseMaxRequestBodySize         uint32
+	BlockwiseMaxConcurrentTransfers     uint32
+	BlockwisePerBlockTimeout            time.Duration
 	ProcessReceivedMessage              ProcessReceivedMessageFunc[C]
 	ReceivedMessageQueueSize            int
+	WireTap                             WireTapFunc
+	OnParseError                        ParseErrorFunc
+	SerializedHandlers                  bool
+	MaxObservations                     uint32
+	TrackObservations                   bool
+	StrictParsing                       bool
+	SendQueueSize                       int
+	SendQueueOverflowPolicy             coapNet.SendQueueOverflowPolicy
+	// Metrics receives the events of the connections, nil disables the collection.
+	Metrics metrics.Collector
+	// Goroutines counts the goroutines of the connections and it limits the connections accepted by the servers,
+	// nil doesn't count them.
+	Goroutines *goroutine.Limiter
+	// OnTransportError is called with the errors of reading from and writing to the connections, nil ignores them.
+	OnTransportError TransportErrorFunc[C]
+	// TokenLength is the range of the lengths of the tokens of the sent and the received messages.
+	TokenLength message.TokenLength
+	// NotificationWorkers is the number of goroutines of the notification scheduler of the server,
+	// zero uses scheduler.DefaultWorkers.
+	NotificationWorkers int
+}
+
+// NewSendQueue creates the send queue of the connection bounded by SendQueueSize, nil when the size is not set.
+func (c *Common[C]) NewSendQueue() *coapNet.SendQueue {
+	if c.SendQueueSize <= 0 {
+		return nil
+	}
+	return coapNet.NewSendQueue(c.SendQueueSize, c.SendQueueOverflowPolicy)
 }
 
 func NewCommon[C responsewriter.Client]() Common[C] {
@@ -57,5 +118,6 @@ func NewCommon[C responsewriter.Client]() Common[C] {
 		LimitClientParallelRequests:         1,
 		LimitClientEndpointParallelRequests: 1,
 		ReceivedMessageQueueSize:            16,
+		TokenLength:                         message.DefaultTokenLength,
 	}
 }
@@ -39,6 +39,46 @@ func WithDisableTCPSignalMessageCSM() DisableTCPSignalMessageCSMOpt {
 	return DisableTCPSignalMessageCSMOpt{}
 }
 
+// OnReleaseOpt coap-tcp release signal option.
+type OnReleaseOpt struct {
+	onRelease tcpClient.OnReleaseFunc
+}
+
+func (o OnReleaseOpt) TCPServerApply(cfg *tcpServer.Config) {
+	cfg.OnRelease = o.onRelease
+}
+
+func (o OnReleaseOpt) TCPClientApply(cfg *tcpClient.Config) {
+	cfg.OnRelease = o.onRelease
+}
+
+// WithOnRelease notifies about the Release signal received from the peer, e.g. to reconnect to the alternative address.
+func WithOnRelease(onRelease tcpClient.OnReleaseFunc) OnReleaseOpt {
+	return OnReleaseOpt{
+		onRelease: onRelease,
+	}
+}
+
+// OnAbortOpt coap-tcp abort signal option.
+type OnAbortOpt struct {
+	onAbort tcpClient.OnAbortFunc
+}
+
+func (o OnAbortOpt) TCPServerApply(cfg *tcpServer.Config) {
+	cfg.OnAbort = o.onAbort
+}
+
+func (o OnAbortOpt) TCPClientApply(cfg *tcpClient.Config) {
+	cfg.OnAbort = o.onAbort
+}
+
+// WithOnAbort notifies about the Abort signal received from the peer before the connection is closed.
+func WithOnAbort(onAbort tcpClient.OnAbortFunc) OnAbortOpt {
+	return OnAbortOpt{
+		onAbort: onAbort,
+	}
+}
+
 // TLSOpt tls configuration option.
 type TLSOpt struct {
 	tlsCfg *tls.Config
@@ -1,9 +1,11 @@
 package options
 
 import (
+	"fmt"
 	"time"
 
 	dtlsServer "github.com/plgd-dev/go-coap/v3/dtls/server"
+	"github.com/plgd-dev/go-coap/v3/message"
 	udpClient "github.com/plgd-dev/go-coap/v3/udp/client"
 	udpServer "github.com/plgd-dev/go-coap/v3/udp/server"
 )
@@ -45,6 +47,147 @@ func WithTransmission(transmissionNStart uint32,
 	}
 }
 
+// AckRandomFactorOpt ACK_RANDOM_FACTOR option.
+type AckRandomFactorOpt struct {
+	factor float64
+}
+
+func (o AckRandomFactorOpt) UDPServerApply(cfg *udpServer.Config) {
+	cfg.TransmissionAckRandomFactor = o.factor
+}
+
+func (o AckRandomFactorOpt) DTLSServerApply(cfg *dtlsServer.Config) {
+	cfg.TransmissionAckRandomFactor = o.factor
+}
+
+func (o AckRandomFactorOpt) UDPClientApply(cfg *udpClient.Config) {
+	cfg.TransmissionAckRandomFactor = o.factor
+}
+
+// WithAckRandomFactor sets ACK_RANDOM_FACTOR (RFC 7252 section 4.8): the initial acknowledge timeout of a Confirmable message
+// is chosen randomly between ackTimeout and ackTimeout*factor. Default is 1.5, values less or equal to 1 disable the randomization.
+func WithAckRandomFactor(factor float64) AckRandomFactorOpt {
+	return AckRandomFactorOpt{
+		factor: factor,
+	}
+}
+
+// ExchangeLifetimeOpt EXCHANGE_LIFETIME option.
+type ExchangeLifetimeOpt struct {
+	exchangeLifetime time.Duration
+}
+
+func (o ExchangeLifetimeOpt) UDPServerApply(cfg *udpServer.Config) {
+	cfg.TransmissionExchangeLifetime = o.exchangeLifetime
+}
+
+func (o ExchangeLifetimeOpt) DTLSServerApply(cfg *dtlsServer.Config) {
+	cfg.TransmissionExchangeLifetime = o.exchangeLifetime
+}
+
+func (o ExchangeLifetimeOpt) UDPClientApply(cfg *udpClient.Config) {
+	cfg.TransmissionExchangeLifetime = o.exchangeLifetime
+}
+
+// WithExchangeLifetime overrides EXCHANGE_LIFETIME (RFC 7252 section 4.8.2), the time for which the responses are cached
+// to answer the duplicated Confirmable requests. By default it is derived from the transmission parameters
+// (see WithTransmission), e.g. 247s for the default ones. The shorter lifetime reduces the memory of the busy server,
+// but a duplicate which arrives after it is processed again.
+func WithExchangeLifetime(d time.Duration) ExchangeLifetimeOpt {
+	return ExchangeLifetimeOpt{
+		exchangeLifetime: d,
+	}
+}
+
+// NonConfirmableResponseTypeOpt type of the response to the Non-confirmable request option.
+type NonConfirmableResponseTypeOpt struct {
+	confirmable bool
+}
+
+func (o NonConfirmableResponseTypeOpt) UDPServerApply(cfg *udpServer.Config) {
+	cfg.ConfirmableResponseToNonConfirmable = o.confirmable
+}
+
+func (o NonConfirmableResponseTypeOpt) DTLSServerApply(cfg *dtlsServer.Config) {
+	cfg.ConfirmableResponseToNonConfirmable = o.confirmable
+}
+
+func (o NonConfirmableResponseTypeOpt) UDPClientApply(cfg *udpClient.Config) {
+	cfg.ConfirmableResponseToNonConfirmable = o.confirmable
+}
+
+// WithNonConfirmableResponseType sets the type (message.NonConfirmable or message.Confirmable) of the responses
+// to the Non-confirmable requests. By default the response type follows the request (RFC 7252 section 5.2):
+// the response to the Confirmable request is piggybacked in the Acknowledgement and the response to the Non-confirmable
+// request is Non-confirmable. The handler can still set the type of its response by w.Message().SetType.
+// It panics for the other types.
+func WithNonConfirmableResponseType(typ message.Type) NonConfirmableResponseTypeOpt {
+	if typ != message.NonConfirmable && typ != message.Confirmable {
+		panic(fmt.Errorf("invalid type %v of the responses to the Non-confirmable requests, expected %v or %v", typ, message.NonConfirmable, message.Confirmable))
+	}
+	return NonConfirmableResponseTypeOpt{
+		confirmable: typ == message.Confirmable,
+	}
+}
+
+// SeparateResponseThresholdOpt threshold of the separate response option.
+type SeparateResponseThresholdOpt struct {
+	threshold time.Duration
+}
+
+func (o SeparateResponseThresholdOpt) UDPServerApply(cfg *udpServer.Config) {
+	cfg.SeparateResponseThreshold = o.threshold
+}
+
+func (o SeparateResponseThresholdOpt) DTLSServerApply(cfg *dtlsServer.Config) {
+	cfg.SeparateResponseThreshold = o.threshold
+}
+
+func (o SeparateResponseThresholdOpt) UDPClientApply(cfg *udpClient.Config) {
+	cfg.SeparateResponseThreshold = o.threshold
+}
+
+// WithSeparateResponseThreshold selects between the piggybacked and the separate response (RFC 7252 section 5.2)
+// by the latency of the handler. When the handler of the Confirmable request returns within the threshold, its response
+// is piggybacked in the ACK. Otherwise the request is acknowledged by the empty ACK when the threshold elapses,
+// so the client doesn't retransmit it, and the response is sent as the separate Confirmable message after the handler
+// returns. The threshold should be shorter than the ACK_TIMEOUT of the clients. By default it is disabled,
+// the response is piggybacked unless the handler doesn't set it.
+func WithSeparateResponseThreshold(threshold time.Duration) SeparateResponseThresholdOpt {
+	return SeparateResponseThresholdOpt{
+		threshold: threshold,
+	}
+}
+
+// UnexpectedMessageHandlerOpt handler of the unexpected messages option.
+type UnexpectedMessageHandlerOpt struct {
+	h udpClient.UnexpectedMessageFunc
+}
+
+func (o UnexpectedMessageHandlerOpt) UDPServerApply(cfg *udpServer.Config) {
+	cfg.UnexpectedMessageHandler = o.h
+}
+
+func (o UnexpectedMessageHandlerOpt) DTLSServerApply(cfg *dtlsServer.Config) {
+	cfg.UnexpectedMessageHandler = o.h
+}
+
+func (o UnexpectedMessageHandlerOpt) UDPClientApply(cfg *udpClient.Config) {
+	cfg.UnexpectedMessageHandler = o.h
+}
+
+// WithUnexpectedMessageHandler calls h for every received Acknowledgement and Reset whose message ID doesn't match
+// any Confirmable message waiting for the acknowledgement, e.g. the late duplicate of the ACK after the request timed out
+// or was acknowledged already, so the timing issues of the flaky links are visible. The handler is called by the goroutine
+// reading the connection, so it must not block and it must not retain msg. It can log the message or write the Reset
+// to the connection, the message is then processed as before: the empty message is dropped and the response is passed
+// to the handlers of the tokens. By default the unexpected messages are dropped silently.
+func WithUnexpectedMessageHandler(h udpClient.UnexpectedMessageFunc) UnexpectedMessageHandlerOpt {
+	return UnexpectedMessageHandlerOpt{
+		h: h,
+	}
+}
+
 // MTUOpt transmission options.
 type MTUOpt struct {
 	mtu uint16
@@ -68,3 +211,91 @@ func WithMTU(mtu uint16) MTUOpt {
 		mtu: mtu,
 	}
 }
+
+// HandshakeTimeoutOpt DTLS handshake timeout option.
+type HandshakeTimeoutOpt struct {
+	timeout time.Duration
+}
+
+func (o HandshakeTimeoutOpt) UDPClientApply(cfg *udpClient.Config) {
+	cfg.HandshakeTimeout = o.timeout
+}
+
+func (o HandshakeTimeoutOpt) DTLSServerApply(cfg *dtlsServer.Config) {
+	cfg.HandshakeTimeout = o.timeout
+}
+
+// WithHandshakeTimeout sets the timeout of the DTLS handshake performed by dtls.Dial and by the DTLS server
+// for the accepted connections, the server closes the connection which didn't finish the handshake in time.
+// It is independent of the timeouts of requests sent over the established connection.
+// Zero value means that the handshake is performed with the first request.
+func WithHandshakeTimeout(timeout time.Duration) HandshakeTimeoutOpt {
+	return HandshakeTimeoutOpt{
+		timeout: timeout,
+	}
+}
+
+// PacketDialerOpt packet dialer option.
+type PacketDialerOpt struct {
+	dialer udpClient.PacketDialer
+}
+
+func (o PacketDialerOpt) UDPClientApply(cfg *udpClient.Config) {
+	cfg.PacketDialer = o.dialer
+}
+
+// WithPacketDialer sets the dialer of the custom datagram transport used by udp.Dial instead of the UDP socket,
+// e.g. a userspace VPN or tunnel. The multicast is not supported over the custom transport.
+func WithPacketDialer(dialer udpClient.PacketDialer) PacketDialerOpt {
+	return PacketDialerOpt{
+		dialer: dialer,
+	}
+}
+
+// DeduplicationOpt duplicate detection option.
+type DeduplicationOpt struct {
+	nonConfirmable bool
+}
+
+func (o DeduplicationOpt) UDPServerApply(cfg *udpServer.Config) {
+	cfg.DeduplicateNonConfirmable = o.nonConfirmable
+}
+
+func (o DeduplicationOpt) DTLSServerApply(cfg *dtlsServer.Config) {
+	cfg.DeduplicateNonConfirmable = o.nonConfirmable
+}
+
+func (o DeduplicationOpt) UDPClientApply(cfg *udpClient.Config) {
+	cfg.DeduplicateNonConfirmable = o.nonConfirmable
+}
+
+// WithDeduplication selects the requests whose duplicates are detected by the message ID within EXCHANGE_LIFETIME
+// (RFC 7252 section 4.5). The duplicates of the Confirmable requests are always detected and answered by the cached
+// response. When nonConfirmable is true, which is the default, the duplicates of the Non-confirmable requests are
+// detected as well: they are answered by the cached response or dropped when the handler didn't respond, e.g. to
+// the telemetry. When nonConfirmable is false, the responses to the Non-confirmable requests are not cached
+// and each duplicate is processed by the handler again.
+func WithDeduplication(nonConfirmable bool) DeduplicationOpt {
+	return DeduplicationOpt{
+		nonConfirmable: nonConfirmable,
+	}
+}
+
+// DiscoveryVerifierOpt discovery verifier option.
+type DiscoveryVerifierOpt struct {
+	verify udpServer.DiscoveryVerifyFunc
+}
+
+func (o DiscoveryVerifierOpt) UDPServerApply(cfg *udpServer.Config) {
+	cfg.VerifyDiscoveryResponse = o.verify
+}
+
+// WithDiscoveryVerifier verifies the authenticity of the responders of the discovery requests of the server
+// (Server.Discover and Server.DiscoveryRequest) before the responses are passed to the receiver, e.g. by
+// udpServer.HMACDiscoveryVerifier with the key shared with the devices, which sign the responses
+// by udpServer.SignDiscoveryResponse. By default any responder is trusted.
+func WithDiscoveryVerifier(verify udpServer.DiscoveryVerifyFunc) DiscoveryVerifierOpt {
+	return DiscoveryVerifierOpt{
+		verify: verify,
+	}
+}
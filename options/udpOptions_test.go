@@ -5,6 +5,7 @@ import (
 	"time"
 
 	dtlsServer "github.com/plgd-dev/go-coap/v3/dtls/server"
+	"github.com/plgd-dev/go-coap/v3/message"
 	"github.com/plgd-dev/go-coap/v3/options"
 	"github.com/plgd-dev/go-coap/v3/udp"
 	"github.com/plgd-dev/go-coap/v3/udp/client"
@@ -62,3 +63,17 @@ func TestUDPClientApply(t *testing.T) {
 	// WithMTU
 	require.Equal(t, uint16(1500), cfg.MTU)
 }
+
+func TestNonConfirmableResponseType(t *testing.T) {
+	cfg := udpServer.Config{}
+	options.WithNonConfirmableResponseType(message.Confirmable).UDPServerApply(&cfg)
+	require.True(t, cfg.ConfirmableResponseToNonConfirmable)
+	options.WithNonConfirmableResponseType(message.NonConfirmable).UDPServerApply(&cfg)
+	require.False(t, cfg.ConfirmableResponseToNonConfirmable)
+	require.Panics(t, func() {
+		options.WithNonConfirmableResponseType(message.Acknowledgement)
+	})
+	require.Panics(t, func() {
+		options.WithNonConfirmableResponseType(message.Unset)
+	})
+}
@@ -51,6 +51,11 @@ func (c *Connections) copyConnections() []Connection {
 	return m
 }
 
+// List returns the stored connections.
+func (c *Connections) List() []Connection {
+	return c.copyConnections()
+}
+
 func (c *Connections) CheckExpirations(now time.Time) {
 	for _, cc := range c.copyConnections() {
 		select {
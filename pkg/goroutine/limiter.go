@@ -0,0 +1,71 @@
+// Package goroutine counts and limits the goroutines started by the servers and the connections.
+package goroutine
+
+import "go.uber.org/atomic"
+
+// Limiter counts the running goroutines started by Go and TryGo and it limits the goroutines started by TryGo.
+// The nil Limiter starts the goroutines without counting them.
+type Limiter struct {
+	max     int64
+	running atomic.Int64
+}
+
+// NewLimiter creates the limiter of max goroutines, max less or equal to 0 only counts the goroutines.
+func NewLimiter(max int64) *Limiter {
+	if max < 0 {
+		max = 0
+	}
+	return &Limiter{max: max}
+}
+
+// TryGo starts f in the new goroutine when the number of the running goroutines is lower than the limit,
+// it reports whether f was started.
+func (l *Limiter) TryGo(f func()) bool {
+	if l == nil {
+		go f()
+		return true
+	}
+	if n := l.running.Inc(); l.max > 0 && n > l.max {
+		l.running.Dec()
+		return false
+	}
+	go l.run(f)
+	return true
+}
+
+// Go starts f in the new goroutine regardless of the limit, e.g. the goroutine needed to finish the work of the goroutine
+// started by TryGo. It is counted, so the number of the running goroutines can exceed the limit.
+func (l *Limiter) Go(f func()) {
+	if l == nil {
+		go f()
+		return
+	}
+	l.running.Inc()
+	go l.run(f)
+}
+
+func (l *Limiter) run(f func()) {
+	defer l.running.Dec()
+	f()
+}
+
+// Running returns the number of the running goroutines.
+func (l *Limiter) Running() int64 {
+	if l == nil {
+		return 0
+	}
+	return l.running.Load()
+}
+
+// Max returns the limit of the goroutines, 0 means unlimited.
+func (l *Limiter) Max() int64 {
+	if l == nil {
+		return 0
+	}
+	return l.max
+}
+
+// Exceeded reports whether the number of the running goroutines reached the limit.
+func (l *Limiter) Exceeded() bool {
+	return l.Max() > 0 && l.Running() >= l.max
+}
@@ -0,0 +1,41 @@
+package goroutine
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	l := NewLimiter(2)
+	var wg sync.WaitGroup
+	release := make(chan struct{})
+	wait := func() {
+		defer wg.Done()
+		<-release
+	}
+	wg.Add(2)
+	require.True(t, l.TryGo(wait))
+	require.True(t, l.TryGo(wait))
+	require.False(t, l.TryGo(wait))
+	require.True(t, l.Exceeded())
+	// Go starts the goroutine over the limit
+	wg.Add(1)
+	l.Go(wait)
+	require.Equal(t, int64(3), l.Running())
+	close(release)
+	wg.Wait()
+	require.Eventually(t, func() bool {
+		return l.Running() == 0
+	}, time.Second, time.Millisecond)
+	require.False(t, l.Exceeded())
+
+	var nilLimiter *Limiter
+	done := make(chan struct{})
+	require.True(t, nilLimiter.TryGo(func() { close(done) }))
+	<-done
+	require.Equal(t, int64(0), nilLimiter.Running())
+	require.False(t, nilLimiter.Exceeded())
+}
@@ -29,3 +29,10 @@ func (l *Rand) Uint32() uint32 {
 	l.lock.Unlock()
 	return val
 }
+
+func (l *Rand) Float64() float64 {
+	l.lock.Lock()
+	val := l.src.Float64()
+	l.lock.Unlock()
+	return val
+}
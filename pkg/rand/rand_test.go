@@ -7,10 +7,14 @@ import (
 	"github.com/plgd-dev/go-coap/v3/pkg/rand"
 )
 
-func TestRand(*testing.T) {
+func TestRand(t *testing.T) {
 	r := rand.NewRand(0)
 	_ = r.Int63()
 	_ = r.Uint32()
+	v := r.Float64()
+	if v < 0 || v >= 1 {
+		t.Fatalf("invalid Float64 value %v", v)
+	}
 }
 
 func TestMultiThreadedRand(*testing.T) {
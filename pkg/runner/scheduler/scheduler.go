@@ -0,0 +1,220 @@
+// Package scheduler serves the timed notifications of many observations by a bounded pool of goroutines.
+package scheduler
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultWorkers is the number of goroutines of the scheduler when the number is not set.
+const DefaultWorkers = 16
+
+// Scheduler services timed notifications of many observations by a bounded pool of goroutines
+// instead of one goroutine per observation.
+type Scheduler struct {
+	mutex   sync.Mutex
+	entries scheduledEntries
+	wakeup  chan struct{}
+	jobs    chan *scheduledEntry
+	done    chan struct{}
+	wg      sync.WaitGroup
+	closed  bool
+}
+
+type scheduledEntry struct {
+	ctx      context.Context
+	interval time.Duration
+	next     time.Time
+	notify   func(now time.Time) bool
+	canceled bool
+	index    int
+}
+
+type scheduledEntries []*scheduledEntry
+
+func (e scheduledEntries) Len() int           { return len(e) }
+func (e scheduledEntries) Less(i, j int) bool { return e[i].next.Before(e[j].next) }
+func (e scheduledEntries) Swap(i, j int) {
+	e[i], e[j] = e[j], e[i]
+	e[i].index = i
+	e[j].index = j
+}
+
+func (e *scheduledEntries) Push(x interface{}) {
+	entry := x.(*scheduledEntry) //nolint:forcetypeassert
+	entry.index = len(*e)
+	*e = append(*e, entry)
+}
+
+func (e *scheduledEntries) Pop() interface{} {
+	old := *e
+	n := len(old)
+	entry := old[n-1]
+	old[n-1] = nil
+	entry.index = -1
+	*e = old[:n-1]
+	return entry
+}
+
+// New creates the scheduler with the pool of workers goroutines. When workers is not positive,
+// DefaultWorkers is used. Close must be called to stop the goroutines.
+func New(workers int) *Scheduler {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	s := &Scheduler{
+		wakeup: make(chan struct{}, 1),
+		jobs:   make(chan *scheduledEntry),
+		done:   make(chan struct{}),
+	}
+	s.wg.Add(workers + 1)
+	for i := 0; i < workers; i++ {
+		go s.work()
+	}
+	go s.run()
+	return s
+}
+
+// Schedule calls notify every interval by one of the workers. The scheduling stops when notify returns false,
+// ctx is done (e.g. the context of the observer's connection), the returned cancel function is called
+// or the scheduler is closed.
+func (s *Scheduler) Schedule(ctx context.Context, interval time.Duration, notify func(now time.Time) bool) (cancel func()) {
+	entry := &scheduledEntry{
+		ctx:      ctx,
+		interval: interval,
+		next:     time.Now().Add(interval),
+		notify:   notify,
+	}
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return func() {
+			// scheduler is closed
+		}
+	}
+	heap.Push(&s.entries, entry)
+	s.mutex.Unlock()
+	s.signal()
+	return func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		entry.canceled = true
+		if entry.index >= 0 {
+			heap.Remove(&s.entries, entry.index)
+		}
+	}
+}
+
+// Len returns the number of scheduled notifications.
+func (s *Scheduler) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.entries)
+}
+
+// Close stops the scheduler and waits for the running notifications.
+func (s *Scheduler) Close() {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return
+	}
+	s.closed = true
+	s.entries = nil
+	s.mutex.Unlock()
+	close(s.done)
+	s.wg.Wait()
+}
+
+func (s *Scheduler) signal() {
+	select {
+	case s.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// popDue returns the first entry which should be notified or the duration to the next one.
+func (s *Scheduler) popDue(now time.Time) (*scheduledEntry, time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for len(s.entries) > 0 {
+		entry := s.entries[0]
+		if entry.ctx.Err() != nil {
+			heap.Pop(&s.entries)
+			continue
+		}
+		if wait := entry.next.Sub(now); wait > 0 {
+			return nil, wait
+		}
+		return heap.Pop(&s.entries).(*scheduledEntry), 0 //nolint:forcetypeassert
+	}
+	return nil, -1
+}
+
+func (s *Scheduler) run() {
+	defer s.wg.Done()
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		entry, wait := s.popDue(time.Now())
+		if entry != nil {
+			select {
+			case s.jobs <- entry:
+			case <-s.done:
+				return
+			}
+			continue
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		var timerC <-chan time.Time
+		if wait >= 0 {
+			timer.Reset(wait)
+			timerC = timer.C
+		}
+		select {
+		case <-timerC:
+		case <-s.wakeup:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *Scheduler) work() {
+	defer s.wg.Done()
+	for {
+		select {
+		case entry := <-s.jobs:
+			s.process(entry)
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *Scheduler) process(entry *scheduledEntry) {
+	now := time.Now()
+	if entry.ctx.Err() != nil || !entry.notify(now) {
+		return
+	}
+	s.mutex.Lock()
+	if s.closed || entry.canceled {
+		s.mutex.Unlock()
+		return
+	}
+	entry.next = entry.next.Add(entry.interval)
+	if entry.next.Before(now) {
+		// notifications are late, don't try to catch up
+		entry.next = now.Add(entry.interval)
+	}
+	heap.Push(&s.entries, entry)
+	s.mutex.Unlock()
+	s.signal()
+}
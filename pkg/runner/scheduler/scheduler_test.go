@@ -0,0 +1,66 @@
+package scheduler_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/plgd-dev/go-coap/v3/pkg/runner/scheduler"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestScheduler(t *testing.T) {
+	s := scheduler.New(2)
+	defer s.Close()
+
+	const numObservations = 100
+	const numNotifications = 3
+	var wg sync.WaitGroup
+	wg.Add(numObservations)
+	var running, maxRunning atomic.Int32
+	for i := 0; i < numObservations; i++ {
+		var count int
+		s.Schedule(context.Background(), time.Millisecond*10, func(time.Time) bool {
+			r := running.Inc()
+			defer running.Dec()
+			for {
+				m := maxRunning.Load()
+				if r <= m || maxRunning.CAS(m, r) {
+					break
+				}
+			}
+			count++
+			if count == numNotifications {
+				wg.Done()
+				return false
+			}
+			return true
+		})
+	}
+	wg.Wait()
+	require.LessOrEqual(t, maxRunning.Load(), int32(2))
+	require.Eventually(t, func() bool { return s.Len() == 0 }, time.Second, time.Millisecond*10)
+
+	// canceled by context and by cancel function
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls atomic.Int32
+	s.Schedule(ctx, time.Millisecond*10, func(time.Time) bool {
+		calls.Inc()
+		return true
+	})
+	stop := s.Schedule(context.Background(), time.Millisecond*10, func(time.Time) bool {
+		calls.Inc()
+		return true
+	})
+	require.Equal(t, 2, s.Len())
+	// the canceled notification is removed immediately
+	stop()
+	require.Equal(t, 1, s.Len())
+	cancel()
+	require.Eventually(t, func() bool { return s.Len() == 0 }, time.Second, time.Millisecond*10)
+	v := calls.Load()
+	time.Sleep(time.Millisecond * 50)
+	require.Equal(t, v, calls.Load())
+}
@@ -0,0 +1,244 @@
+// Package proxy implements a HTTP-CoAP cross-proxy according to RFC 8075.
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+	"github.com/plgd-dev/go-coap/v3/mux"
+)
+
+// DefaultMaxBodySize is the maximum size of a HTTP request body which is forwarded to the CoAP server.
+const DefaultMaxBodySize = 1024 * 1024
+
+// HTTPHandler is a http.Handler which forwards HTTP requests to the CoAP server over the connection.
+type HTTPHandler struct {
+	conn        mux.Conn
+	maxBodySize int64
+	errors      func(error)
+}
+
+// NewHTTPHandler creates a HTTP handler which translates HTTP requests to CoAP requests sent over conn
+// and CoAP responses back to HTTP responses. The path and the query of the HTTP request are used
+// as Uri-Path and Uri-Query of the CoAP request.
+func NewHTTPHandler(conn mux.Conn) *HTTPHandler {
+	return &HTTPHandler{
+		conn:        conn,
+		maxBodySize: DefaultMaxBodySize,
+		errors: func(err error) {
+			fmt.Println(err)
+		},
+	}
+}
+
+// SetMaxBodySize sets the maximum size of a HTTP request body. Bigger requests are rejected by 413 Request Entity Too Large.
+func (h *HTTPHandler) SetMaxBodySize(size int64) *HTTPHandler {
+	h.maxBodySize = size
+	return h
+}
+
+// SetErrors sets the function which is called when the request cannot be forwarded. The HTTP client receives
+// only the status text, the detail of the error is reported just by this function. By default the error is printed.
+func (h *HTTPHandler) SetErrors(errorsFunc func(error)) *HTTPHandler {
+	h.errors = errorsFunc
+	return h
+}
+
+// CodeFromHTTPMethod maps the HTTP method to the CoAP request code.
+func CodeFromHTTPMethod(method string) (codes.Code, bool) {
+	switch method {
+	case http.MethodGet:
+		return codes.GET, true
+	case http.MethodPost:
+		return codes.POST, true
+	case http.MethodPut:
+		return codes.PUT, true
+	case http.MethodDelete:
+		return codes.DELETE, true
+	}
+	return 0, false
+}
+
+// HTTPStatusFromCode maps the CoAP response code to the HTTP status code by RFC 8075 section 7.
+func HTTPStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.Created:
+		return http.StatusCreated
+	case codes.Deleted, codes.Changed, codes.Content:
+		return http.StatusOK
+	case codes.Valid:
+		return http.StatusNotModified
+	case codes.BadRequest, codes.BadOption, codes.RequestEntityIncomplete:
+		return http.StatusBadRequest
+	case codes.Unauthorized, codes.Forbidden:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.MethodNotAllowed:
+		return http.StatusMethodNotAllowed
+	case codes.NotAcceptable:
+		return http.StatusNotAcceptable
+	case codes.PreconditionFailed:
+		return http.StatusPreconditionFailed
+	case codes.RequestEntityTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case codes.UnsupportedMediaType:
+		return http.StatusUnsupportedMediaType
+	case codes.TooManyRequests:
+		return http.StatusTooManyRequests
+	case codes.NotImplemented:
+		return http.StatusNotImplemented
+	case codes.BadGateway, codes.ProxyingNotSupported:
+		return http.StatusBadGateway
+	case codes.ServiceUnavailable:
+		return http.StatusServiceUnavailable
+	case codes.GatewayTimeout:
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+func toMediaType(contentType string) (message.MediaType, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return 0, err
+	}
+	if mediaType == "text/plain" {
+		if charset, ok := params["charset"]; ok && !strings.EqualFold(charset, "utf-8") {
+			return 0, fmt.Errorf("unsupported charset %v", charset)
+		}
+		return message.TextPlain, nil
+	}
+	if len(params) > 0 {
+		// media types with parameters (cose-type, ...) must match exactly
+		return message.ToMediaType(contentType)
+	}
+	return message.ToMediaType(mediaType)
+}
+
+func (h *HTTPHandler) readBody(r *http.Request) (message.MediaType, io.ReadSeeker, int, error) {
+	contentFormat := message.AppOctets
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mt, err := toMediaType(contentType)
+		if err != nil {
+			return 0, nil, http.StatusUnsupportedMediaType, fmt.Errorf("unsupported content type %v: %w", contentType, err)
+		}
+		contentFormat = mt
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, h.maxBodySize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return 0, nil, http.StatusRequestEntityTooLarge, err
+		}
+		return 0, nil, http.StatusBadRequest, err
+	}
+	return contentFormat, bytes.NewReader(body), 0, nil
+}
+
+func (h *HTTPHandler) newRequest(r *http.Request) (*pool.Message, int, error) {
+	code, ok := CodeFromHTTPMethod(r.Method)
+	if !ok {
+		return nil, http.StatusNotImplemented, fmt.Errorf("unsupported method %v", r.Method)
+	}
+	opts := make(message.Options, 0, 4)
+	if r.URL.RawQuery != "" {
+		for _, q := range strings.Split(r.URL.RawQuery, "&") {
+			v, err := url.QueryUnescape(q)
+			if err != nil {
+				return nil, http.StatusBadRequest, err
+			}
+			opts = append(opts, message.Option{ID: message.URIQuery, Value: []byte(v)})
+		}
+	}
+	if accept := r.Header.Get("Accept"); accept != "" && accept != "*/*" {
+		if mt, err := toMediaType(accept); err == nil {
+			buf := make([]byte, 4)
+			opts, _, _ = opts.SetAccept(buf, mt)
+		}
+	}
+	var req *pool.Message
+	var err error
+	switch code {
+	case codes.GET:
+		req, err = h.conn.NewGetRequest(r.Context(), r.URL.Path, opts...)
+	case codes.DELETE:
+		req, err = h.conn.NewDeleteRequest(r.Context(), r.URL.Path, opts...)
+	default:
+		contentFormat, body, status, errB := h.readBody(r)
+		if errB != nil {
+			return nil, status, errB
+		}
+		if code == codes.POST {
+			req, err = h.conn.NewPostRequest(r.Context(), r.URL.Path, contentFormat, body, opts...)
+		} else {
+			req, err = h.conn.NewPutRequest(r.Context(), r.URL.Path, contentFormat, body, opts...)
+		}
+	}
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	return req, 0, nil
+}
+
+func (h *HTTPHandler) writeResponse(w http.ResponseWriter, resp *pool.Message) error {
+	body, err := resp.ReadBody()
+	if err != nil {
+		return err
+	}
+	header := w.Header()
+	if mt, errC := resp.ContentFormat(); errC == nil {
+		header.Set("Content-Type", mt.String())
+	}
+	if etag, errE := resp.ETag(); errE == nil {
+		header.Set("ETag", strconv.Quote(hex.EncodeToString(etag)))
+	}
+	if maxAge, errM := resp.GetOptionUint32(message.MaxAge); errM == nil {
+		header.Set("Cache-Control", "max-age="+strconv.FormatUint(uint64(maxAge), 10))
+	}
+	status := HTTPStatusFromCode(resp.Code())
+	w.WriteHeader(status)
+	if status == http.StatusNotModified {
+		// 304 must not contain a body
+		return nil
+	}
+	_, err = w.Write(body)
+	return err
+}
+
+// ServeHTTP forwards the HTTP request to the CoAP server and writes the CoAP response as HTTP response.
+func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req, status, err := h.newRequest(r)
+	if err != nil {
+		h.errors(fmt.Errorf("cannot create coap request: %w", err))
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	defer h.conn.ReleaseMessage(req)
+	resp, err := h.conn.Do(req)
+	if err != nil {
+		h.errors(fmt.Errorf("cannot forward request %v: %w", r.URL.Path, err))
+		status = http.StatusBadGateway
+		if errors.Is(err, context.DeadlineExceeded) {
+			status = http.StatusGatewayTimeout
+		}
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	defer h.conn.ReleaseMessage(resp)
+	if err = h.writeResponse(w, resp); err != nil {
+		h.errors(fmt.Errorf("cannot write response for %v: %w", r.URL.Path, err))
+	}
+}
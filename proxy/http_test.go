@@ -0,0 +1,129 @@
+package proxy_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/mux"
+	coapNet "github.com/plgd-dev/go-coap/v3/net"
+	"github.com/plgd-dev/go-coap/v3/options"
+	"github.com/plgd-dev/go-coap/v3/proxy"
+	"github.com/plgd-dev/go-coap/v3/udp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPHandler(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	m := mux.NewRouter()
+	err = m.Handle("/a/b", mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		queries, errQ := r.Queries()
+		assert.NoError(t, errQ)
+		assert.Equal(t, []string{"x=1", "y=a b"}, queries)
+		errS := w.SetResponse(codes.Content, message.AppJSON, bytes.NewReader([]byte(`{"a":1}`)))
+		assert.NoError(t, errS)
+	}))
+	require.NoError(t, err)
+	err = m.Handle("/echo", mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		assert.Equal(t, codes.POST, r.Code())
+		cf, errC := r.ContentFormat()
+		assert.NoError(t, errC)
+		assert.Equal(t, message.TextPlain, cf)
+		body, errB := r.ReadBody()
+		assert.NoError(t, errB)
+		errS := w.SetResponse(codes.Created, message.TextPlain, bytes.NewReader(body))
+		assert.NoError(t, errS)
+	}))
+	require.NoError(t, err)
+
+	s := udp.NewServer(options.WithMux(m))
+	defer s.Stop()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := udp.Dial(l.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	var errs []error
+	var errsLock sync.Mutex
+	srv := httptest.NewServer(proxy.NewHTTPHandler(cc).SetErrors(func(err error) {
+		errsLock.Lock()
+		defer errsLock.Unlock()
+		errs = append(errs, err)
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/a/b?x=1&y=a%20b")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	require.Equal(t, `{"a":1}`, string(body))
+
+	resp, err = http.Post(srv.URL+"/echo", "text/plain; charset=utf-8", strings.NewReader("hello"))
+	require.NoError(t, err)
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	require.Equal(t, "hello", string(body))
+
+	resp, err = http.Get(srv.URL + "/not-found")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = http.Post(srv.URL+"/echo", "unknown/type", strings.NewReader("hello"))
+	require.NoError(t, err)
+	body, err = io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+	// the detail of the error is not exposed to the HTTP client
+	require.Equal(t, http.StatusText(http.StatusUnsupportedMediaType)+"\n", string(body))
+	errsLock.Lock()
+	require.Len(t, errs, 1)
+	require.Contains(t, errs[0].Error(), "unknown/type")
+	errsLock.Unlock()
+
+	req, err := http.NewRequest(http.MethodPatch, srv.URL+"/echo", nil)
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusNotImplemented, resp.StatusCode)
+}
+
+func TestHTTPStatusFromCode(t *testing.T) {
+	require.Equal(t, http.StatusOK, proxy.HTTPStatusFromCode(codes.Content))
+	require.Equal(t, http.StatusNotModified, proxy.HTTPStatusFromCode(codes.Valid))
+	require.Equal(t, http.StatusForbidden, proxy.HTTPStatusFromCode(codes.Unauthorized))
+	require.Equal(t, http.StatusBadGateway, proxy.HTTPStatusFromCode(codes.ProxyingNotSupported))
+	require.Equal(t, http.StatusInternalServerError, proxy.HTTPStatusFromCode(codes.InternalServerError))
+}
@@ -0,0 +1,228 @@
+package coap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+)
+
+// MessagePool provides the messages for the RequestBuilder, e.g. the client connection or *pool.Pool.
+type MessagePool interface {
+	AcquireMessage(ctx context.Context) *pool.Message
+	ReleaseMessage(m *pool.Message)
+}
+
+// RequestBuilder builds the request by the chain of calls, e.g.
+//
+//	req, err := coap.NewRequest(ctx).FromPool(cc).Get("/path").Accept(message.AppCBOR).Confirmable().Build()
+//	if err != nil {
+//		return err
+//	}
+//	defer cc.ReleaseMessage(req)
+//	resp, err := cc.Do(req)
+//
+// The setters can be called in any order, they are applied by Build. The message type and the message ID are
+// not set by default, so they are set by the connection when the request is sent.
+type RequestBuilder struct {
+	ctx           context.Context
+	pool          MessagePool
+	code          codes.Code
+	path          string
+	token         message.Token
+	typ           message.Type
+	messageID     int32
+	contentFormat message.MediaType
+	payload       io.ReadSeeker
+	setters       []func(req *pool.Message) error
+	err           error
+}
+
+// NewRequest creates the builder of the request with the ctx, use ctx to set timeout.
+func NewRequest(ctx context.Context) *RequestBuilder {
+	return &RequestBuilder{
+		ctx:       ctx,
+		typ:       message.Unset,
+		messageID: -1,
+	}
+}
+
+// FromPool acquires the message from the pool, the message must be released back to the pool by the caller.
+// Without the pool the message is allocated and it is reclaimed by the garbage collector.
+func (b *RequestBuilder) FromPool(p MessagePool) *RequestBuilder {
+	b.pool = p
+	return b
+}
+
+func (b *RequestBuilder) method(code codes.Code, path string) *RequestBuilder {
+	if b.code != codes.Empty && b.code != code {
+		b.err = fmt.Errorf("method is already set to %v", b.code)
+	}
+	b.code = code
+	b.path = path
+	return b
+}
+
+// Get sets the method GET with the path.
+func (b *RequestBuilder) Get(path string) *RequestBuilder {
+	return b.method(codes.GET, path)
+}
+
+// Post sets the method POST with the path and the payload. If payload is nil then content format is not used.
+func (b *RequestBuilder) Post(path string, contentFormat message.MediaType, payload io.ReadSeeker) *RequestBuilder {
+	b.contentFormat = contentFormat
+	b.payload = payload
+	return b.method(codes.POST, path)
+}
+
+// Put sets the method PUT with the path and the payload. If payload is nil then content format is not used.
+func (b *RequestBuilder) Put(path string, contentFormat message.MediaType, payload io.ReadSeeker) *RequestBuilder {
+	b.contentFormat = contentFormat
+	b.payload = payload
+	return b.method(codes.PUT, path)
+}
+
+// Delete sets the method DELETE with the path.
+func (b *RequestBuilder) Delete(path string) *RequestBuilder {
+	return b.method(codes.DELETE, path)
+}
+
+// Token sets the token, by default the random token is generated by message.GetToken.
+func (b *RequestBuilder) Token(token message.Token) *RequestBuilder {
+	b.token = token
+	return b
+}
+
+// Confirmable sets the message type to Confirmable.
+func (b *RequestBuilder) Confirmable() *RequestBuilder {
+	b.typ = message.Confirmable
+	return b
+}
+
+// NonConfirmable sets the message type to NonConfirmable.
+func (b *RequestBuilder) NonConfirmable() *RequestBuilder {
+	b.typ = message.NonConfirmable
+	return b
+}
+
+// MessageID sets the message ID, by default it is generated by the connection.
+func (b *RequestBuilder) MessageID(mid int32) *RequestBuilder {
+	b.messageID = mid
+	return b
+}
+
+// Accept sets the Accept option.
+func (b *RequestBuilder) Accept(contentFormat message.MediaType) *RequestBuilder {
+	return b.with(func(req *pool.Message) error {
+		req.SetAccept(contentFormat)
+		return nil
+	})
+}
+
+// Query adds the Uri-Query option, e.g. "rt=temperature".
+func (b *RequestBuilder) Query(query string) *RequestBuilder {
+	return b.with(func(req *pool.Message) error {
+		req.AddQuery(query)
+		return nil
+	})
+}
+
+// Observe sets the Observe option, 0 registers the observation and 1 deregisters it.
+func (b *RequestBuilder) Observe(observe uint32) *RequestBuilder {
+	return b.with(func(req *pool.Message) error {
+		req.SetObserve(observe)
+		return nil
+	})
+}
+
+// ETag adds the ETag option.
+func (b *RequestBuilder) ETag(etag []byte) *RequestBuilder {
+	return b.with(func(req *pool.Message) error {
+		return req.AddETag(etag)
+	})
+}
+
+// Options adds the options to the request.
+func (b *RequestBuilder) Options(opts ...message.Option) *RequestBuilder {
+	return b.with(func(req *pool.Message) error {
+		for _, o := range opts {
+			req.AddOptionBytes(o.ID, o.Value)
+		}
+		return nil
+	})
+}
+
+func (b *RequestBuilder) with(set func(req *pool.Message) error) *RequestBuilder {
+	b.setters = append(b.setters, set)
+	return b
+}
+
+func (b *RequestBuilder) acquireMessage() *pool.Message {
+	if b.pool == nil {
+		return pool.NewMessage(b.ctx)
+	}
+	return b.pool.AcquireMessage(b.ctx)
+}
+
+func (b *RequestBuilder) releaseMessage(req *pool.Message) {
+	if b.pool != nil {
+		b.pool.ReleaseMessage(req)
+	}
+}
+
+// Build creates the request. When the request was acquired from the pool (see FromPool), it must be released by the caller.
+func (b *RequestBuilder) Build() (*pool.Message, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if b.code == codes.Empty {
+		return nil, errors.New("method is not set")
+	}
+	token := b.token
+	if token == nil {
+		var err error
+		token, err = message.GetToken()
+		if err != nil {
+			return nil, fmt.Errorf("cannot get token: %w", err)
+		}
+	}
+	req := b.acquireMessage()
+	if err := b.setup(req, token); err != nil {
+		b.releaseMessage(req)
+		return nil, err
+	}
+	return req, nil
+}
+
+func (b *RequestBuilder) setup(req *pool.Message, token message.Token) error {
+	var err error
+	switch b.code {
+	case codes.GET:
+		err = req.SetupGet(b.path, token)
+	case codes.POST:
+		err = req.SetupPost(b.path, token, b.contentFormat, b.payload)
+	case codes.PUT:
+		err = req.SetupPut(b.path, token, b.contentFormat, b.payload)
+	case codes.DELETE:
+		err = req.SetupDelete(b.path, token)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot setup %v request: %w", b.code, err)
+	}
+	if b.typ != message.Unset {
+		req.SetType(b.typ)
+	}
+	if b.messageID >= 0 {
+		req.SetMessageID(b.messageID)
+	}
+	for _, set := range b.setters {
+		if err = set(req); err != nil {
+			return fmt.Errorf("cannot setup %v request: %w", b.code, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package coap
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestBuilder(t *testing.T) {
+	ctx := context.Background()
+	p := pool.New(0, 0)
+
+	req, err := NewRequest(ctx).FromPool(p).Get("/a/b").Accept(message.AppCBOR).Confirmable().Query("rt=temp").Build()
+	require.NoError(t, err)
+	require.Equal(t, codes.GET, req.Code())
+	require.Equal(t, message.Confirmable, req.Type())
+	require.Equal(t, int32(-1), req.MessageID())
+	require.NotEmpty(t, req.Token())
+	path, err := req.Path()
+	require.NoError(t, err)
+	require.Equal(t, "/a/b", path)
+	accept, err := req.Accept()
+	require.NoError(t, err)
+	require.Equal(t, message.AppCBOR, accept)
+	queries, err := req.Queries()
+	require.NoError(t, err)
+	require.Equal(t, []string{"rt=temp"}, queries)
+	p.ReleaseMessage(req)
+
+	req, err = NewRequest(ctx).NonConfirmable().MessageID(7).Token(message.Token("t")).
+		Post("/a", message.TextPlain, bytes.NewReader([]byte("x"))).Observe(0).Build()
+	require.NoError(t, err)
+	require.Equal(t, codes.POST, req.Code())
+	require.Equal(t, message.NonConfirmable, req.Type())
+	require.Equal(t, int32(7), req.MessageID())
+	require.Equal(t, message.Token("t"), req.Token())
+	cf, err := req.ContentFormat()
+	require.NoError(t, err)
+	require.Equal(t, message.TextPlain, cf)
+	obs, err := req.Observe()
+	require.NoError(t, err)
+	require.Equal(t, uint32(0), obs)
+	body, err := req.ReadBody()
+	require.NoError(t, err)
+	require.Equal(t, []byte("x"), body)
+
+	_, err = NewRequest(ctx).Accept(message.AppCBOR).Build()
+	require.Error(t, err)
+	_, err = NewRequest(ctx).Get("/a").Delete("/a").Build()
+	require.Error(t, err)
+	_, err = NewRequest(ctx).FromPool(p).Put("/a", message.TextPlain, nil).ETag(make([]byte, 9)).Build()
+	require.Error(t, err)
+}
@@ -82,6 +82,11 @@ func Client(conn net.Conn, opts ...Option) *client.Conn {
 				func(token message.Token) (*pool.Message, bool) {
 					return v.GetObservationRequest(token)
 				},
+				blockwise.WithBufferAllocator(cfg.BlockwiseBufferAllocator),
+				blockwise.WithMaxRequestBodySize(cfg.BlockwiseMaxRequestBodySize),
+				blockwise.WithMaxConcurrentTransfers(cfg.BlockwiseMaxConcurrentTransfers),
+				blockwise.WithPerBlockTimeout(cfg.BlockwisePerBlockTimeout),
+				blockwise.WithMetrics(cfg.Metrics),
 			)
 		}
 	}
@@ -50,4 +50,9 @@ type Config struct {
 	DisablePeerTCPSignalMessageCSMs bool
 	CloseSocket                     bool
 	DisableTCPSignalMessageCSM      bool
+	DefaultContentFormat            *message.MediaType
+	OnRelease                       OnReleaseFunc
+	OnAbort                         OnAbortFunc
+	// ClientOptions are added to all requests sent by the client which don't contain the option.
+	ClientOptions message.Options
 }
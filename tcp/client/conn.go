@@ -14,9 +14,11 @@ import (
 	"github.com/plgd-dev/go-coap/v3/net/blockwise"
 	"github.com/plgd-dev/go-coap/v3/net/client"
 	limitparallelrequests "github.com/plgd-dev/go-coap/v3/net/client/limitParallelRequests"
+	"github.com/plgd-dev/go-coap/v3/net/metrics"
 	"github.com/plgd-dev/go-coap/v3/net/monitor/inactivity"
 	"github.com/plgd-dev/go-coap/v3/net/observation"
 	"github.com/plgd-dev/go-coap/v3/net/responsewriter"
+	"github.com/plgd-dev/go-coap/v3/options/config"
 	coapErrors "github.com/plgd-dev/go-coap/v3/pkg/errors"
 	coapSync "github.com/plgd-dev/go-coap/v3/pkg/sync"
 	"go.uber.org/atomic"
@@ -45,6 +47,7 @@ type Conn struct {
 	*client.Client[*Conn]
 	session                         *Session
 	observationHandler              *observation.Handler[*Conn]
+	peerObservations                *observation.Registrations
 	processReceivedMessage          func(req *pool.Message, cc *Conn, handler HandlerFunc)
 	tokenHandlerContainer           *coapSync.Map[uint64, HandlerFunc]
 	blockWise                       *blockwise.BlockWise[*Conn]
@@ -52,8 +55,17 @@ type Conn struct {
 	peerMaxMessageSize              atomic.Uint32
 	disablePeerTCPSignalMessageCSMs bool
 	peerBlockWiseTranferEnabled     atomic.Bool
+	defaultContentFormat            *message.MediaType
+	clientOptions                   message.Options
+	metrics                         metrics.Collector
+	onTransportError                config.TransportErrorFunc[*Conn]
+	onRelease                       OnReleaseFunc
+	onAbort                         OnAbortFunc
 
 	receivedMessageReader *client.ReceivedMessageReader[*Conn]
+
+	// closeReason is set by Close, the other reasons are derived from the cause of the canceled context
+	closeReason atomic.Uint32
 }
 
 type ConnOptions struct {
@@ -88,6 +100,45 @@ func WithRequestMonitor(requestMonitor RequestMonitorFunc) Option {
 	}
 }
 
+// serializeHandler calls the handler of the requests one at a time in the receive order, when it is configured
+// by SerializedHandlers.
+func serializeHandler(cc *Conn, cfg *Config) HandlerFunc {
+	h := cfg.Handler
+	if !cfg.SerializedHandlers || h == nil {
+		return h
+	}
+	return func(w *responsewriter.ResponseWriter[*Conn], r *pool.Message) {
+		cc.receivedMessageReader.ProcessInOrder(func() {
+			h(w, r)
+		})
+	}
+}
+
+// limitObservations tracks the observations registered by the peer, when it is enabled by MaxObservations, Metrics
+// or TrackObservations. It rejects the registrations over MaxObservations and it reports the registered observations
+// to the metrics. The returned registrations are nil when the observations are not tracked, otherwise they must be
+// released when the connection is closed.
+func limitObservations(cfg *Config, h HandlerFunc) (HandlerFunc, *observation.Registrations) {
+	if (cfg.MaxObservations == 0 && cfg.Metrics == nil && !cfg.TrackObservations) || h == nil {
+		return h, nil
+	}
+	return observation.RegistrationsHandler(cfg.MaxObservations, cfg.Metrics, h, cfg.Errors)
+}
+
+// collectMetrics reports the connection and its observations and blockwise transfers to the metrics until the connection is closed.
+func (cc *Conn) collectMetrics() {
+	cc.metrics.AddConnections(1)
+	cc.AddOnClose(func() {
+		if cc.peerObservations != nil {
+			cc.peerObservations.Release()
+		}
+		if cc.blockWise != nil {
+			cc.blockWise.Release()
+		}
+		cc.metrics.AddConnections(-1)
+	})
+}
+
 // NewConn creates connection over session and observation.
 func NewConn(
 	connection *coapNet.Conn,
@@ -118,9 +169,16 @@ func NewConnWithOpts(connection *coapNet.Conn, cfg *Config, opts ...Option) *Con
 		tokenHandlerContainer:           coapSync.NewMap[uint64, HandlerFunc](),
 		blockwiseSZX:                    cfg.BlockwiseSZX,
 		disablePeerTCPSignalMessageCSMs: cfg.DisablePeerTCPSignalMessageCSMs,
+		defaultContentFormat:            cfg.DefaultContentFormat,
+		clientOptions:                   cfg.ClientOptions,
+		onRelease:                       cfg.OnRelease,
+		onAbort:                         cfg.OnAbort,
+		metrics:                         metrics.OrNil(cfg.Metrics),
+		onTransportError:                cfg.OnTransportError,
 	}
 	limitParallelRequests := limitparallelrequests.New(cfg.LimitClientParallelRequests, cfg.LimitClientEndpointParallelRequests, cc.do, cc.doObserve)
-	cc.observationHandler = observation.NewHandler(&cc, cfg.Handler, limitParallelRequests.Do)
+	handler, peerObservations := limitObservations(cfg, serializeHandler(&cc, cfg))
+	cc.observationHandler = observation.NewHandler(&cc, handler, limitParallelRequests.Do)
 	cc.Client = client.New(&cc, cc.observationHandler, cfg.GetToken, limitParallelRequests)
 	cc.blockWise = cfgOpts.CreateBlockWise(&cc)
 	session := NewSession(cfg.Ctx,
@@ -134,11 +192,19 @@ func NewConnWithOpts(connection *coapNet.Conn, cfg *Config, opts ...Option) *Con
 		cfg.ConnectionCacheSize,
 		cfg.MessagePool,
 	)
+	session.SetWireTap(cfg.WireTap)
+	session.SetMetrics(cfg.Metrics)
+	session.SetTokenLength(cfg.TokenLength)
+	session.SetOnParseError(cfg.OnParseError)
+	session.SetStrictParsing(cfg.StrictParsing)
+	session.SetSendQueue(cfg.NewSendQueue())
 	cc.session = session
 	if cc.processReceivedMessage == nil {
 		cc.processReceivedMessage = processReceivedMessage
 	}
-	cc.receivedMessageReader = client.NewReceivedMessageReader(&cc, cfg.ReceivedMessageQueueSize)
+	cc.receivedMessageReader = client.NewReceivedMessageReaderWithLimiter(&cc, cfg.ReceivedMessageQueueSize, cfg.Goroutines)
+	cc.peerObservations = peerObservations
+	cc.collectMetrics()
 	return &cc
 }
 
@@ -154,8 +220,24 @@ func (cc *Conn) Session() *Session {
 	return cc.session
 }
 
+// Flush blocks until the outgoing messages queued by the send queue (see options.WithSendQueueSize), e.g. notifications
+// and ACKs, are written to the socket or the ctx is done. Calling it before Close ensures that the last messages,
+// e.g. the "going offline" notification, are not dropped by the close.
+func (cc *Conn) Flush(ctx context.Context) error {
+	return cc.session.Flush(ctx)
+}
+
 // Close closes connection without wait of ends Run function.
 func (cc *Conn) Close() error {
+	return cc.CloseWithReason(coapNet.CloseReasonClosed)
+}
+
+// CloseWithReason closes the connection as Close and reports the reason by CloseReason, e.g. the callback
+// of the inactivity monitor closes the connection with coapNet.CloseReasonIdleTimeout (see inactivity.CloseConn).
+func (cc *Conn) CloseWithReason(reason coapNet.CloseReason) error {
+	if cc.Context().Err() == nil {
+		cc.closeReason.CompareAndSwap(uint32(coapNet.CloseReasonNone), uint32(reason))
+	}
 	err := cc.session.Close()
 	if errors.Is(err, net.ErrClosed) {
 		return nil
@@ -197,13 +279,39 @@ func (cc *Conn) doInternal(req *pool.Message) (*pool.Message, error) {
 	}
 }
 
+// upsertDefaultContentFormat sets the default content format to requests with a body but without content format.
+func (cc *Conn) upsertDefaultContentFormat(req *pool.Message) {
+	if cc.defaultContentFormat == nil || req.Body() == nil || req.Code() < codes.GET || req.Code() > codes.DELETE {
+		return
+	}
+	req.UpsertContentFormat(*cc.defaultContentFormat)
+}
+
+// addClientOptions adds the client options to requests which don't contain them.
+func (cc *Conn) addClientOptions(req *pool.Message) {
+	if len(cc.clientOptions) == 0 || req.Code() < codes.GET || req.Code() > codes.DELETE {
+		return
+	}
+	for _, o := range cc.clientOptions {
+		if !req.HasOption(o.ID) {
+			req.AddOptionBytes(o.ID, o.Value)
+		}
+	}
+}
+
 // Do sends an coap message and returns an coap response.
 //
 // An error is returned if by failure to speak COAP (such as a network connectivity problem).
-// Any status code doesn't cause an error.
+// Any status code doesn't cause an error. The request with the Content-Format option,
+// but without the body is rejected by message.ErrContentFormatWithoutPayload.
 //
 // Caller is responsible to release request and response.
 func (cc *Conn) do(req *pool.Message) (*pool.Message, error) {
+	cc.upsertDefaultContentFormat(req)
+	cc.addClientOptions(req)
+	if err := req.ValidateContentFormat(); err != nil {
+		return nil, err
+	}
 	if !cc.peerBlockWiseTranferEnabled.Load() || cc.blockWise == nil {
 		return cc.doInternal(req)
 	}
@@ -220,6 +328,8 @@ func (cc *Conn) writeMessage(req *pool.Message) error {
 
 // WriteMessage sends an coap message.
 func (cc *Conn) WriteMessage(req *pool.Message) error {
+	cc.upsertDefaultContentFormat(req)
+	cc.addClientOptions(req)
 	if !cc.peerBlockWiseTranferEnabled.Load() || cc.blockWise == nil {
 		return cc.writeMessage(req)
 	}
@@ -264,7 +374,19 @@ func (cc *Conn) AsyncPing(receivedPong func()) (func(), error) {
 
 // Run reads and process requests from a connection, until the connection is not closed.
 func (cc *Conn) Run() (err error) {
-	return cc.session.Run(cc)
+	err = cc.session.Run(cc)
+	switch cc.CloseReason() {
+	case coapNet.CloseReasonPeerReset, coapNet.CloseReasonTransportError:
+		cc.reportTransportError(context.Cause(cc.Context()), true)
+	}
+	return err
+}
+
+// reportTransportError reports the error of reading from or writing to the connection, fatal when it closed the connection.
+func (cc *Conn) reportTransportError(err error, fatal bool) {
+	if cc.onTransportError != nil {
+		cc.onTransportError(cc, err, fatal)
+	}
 }
 
 // AddOnClose calls function on close connection event.
@@ -272,7 +394,21 @@ func (cc *Conn) AddOnClose(f EventFunc) {
 	cc.session.AddOnClose(f)
 }
 
+// CloseReason returns the reason why the connection was closed, it is valid in the functions registered by AddOnClose.
+// CloseReasonNone is returned for the open connection.
+func (cc *Conn) CloseReason() coapNet.CloseReason {
+	if reason := coapNet.CloseReason(cc.closeReason.Load()); reason != coapNet.CloseReasonNone {
+		return reason
+	}
+	return coapNet.CloseReasonFromCause(context.Cause(cc.Context()))
+}
+
 // RemoteAddr gets remote address.
+// BlockwiseSZX returns the maximal block size used by the blockwise transfers of the connection.
+func (cc *Conn) BlockwiseSZX() blockwise.SZX {
+	return cc.blockwiseSZX
+}
+
 func (cc *Conn) RemoteAddr() net.Addr {
 	return cc.session.RemoteAddr()
 }
@@ -308,6 +444,12 @@ func (cc *Conn) AcquireMessage(ctx context.Context) *pool.Message {
 	return cc.session.AcquireMessage(ctx)
 }
 
+// TryAcquireMessage acquires the message from the message pool of the connection, it returns pool.ErrPoolExhausted
+// when the message cannot be acquired by the pool.ExhaustedPolicy of the pool.
+func (cc *Conn) TryAcquireMessage(ctx context.Context) (*pool.Message, error) {
+	return cc.session.messagePool.TryAcquireMessage(ctx)
+}
+
 func (cc *Conn) ReleaseMessage(m *pool.Message) {
 	cc.session.ReleaseMessage(m)
 }
@@ -334,6 +476,7 @@ func (cc *Conn) ProcessReceivedMessageWithHandler(req *pool.Message, handler Han
 	if w.Message().IsModified() {
 		err := cc.Session().WriteMessage(w.Message())
 		if err != nil {
+			cc.reportTransportError(err, true)
 			if errC := cc.Close(); errC != nil {
 				cc.Session().errors(fmt.Errorf("cannot close connection: %w", errC))
 			}
@@ -392,14 +535,10 @@ func (cc *Conn) handleSignals(r *pool.Message) bool {
 		}
 		return true
 	case codes.Release:
-		// if r.HasOption(message.TCPAlternativeAddress) {
-		// TODO
-		// }
+		cc.handleRelease(r)
 		return true
 	case codes.Abort:
-		// if r.HasOption(message.TCPBadCSMOption) {
-		// TODO
-		// }
+		cc.handleAbort(r)
 		return true
 	case codes.Pong:
 		if h, ok := cc.tokenHandlerContainer.LoadAndDelete(r.Token().Hash()); ok {
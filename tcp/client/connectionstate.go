@@ -0,0 +1,42 @@
+package client
+
+import (
+	"crypto/tls"
+	"net"
+
+	"github.com/plgd-dev/go-coap/v3/net/blockwise"
+)
+
+// ConnectionState contains the parameters of the connection negotiated by the Capabilities and Settings Messages (CSM),
+// e.g. for audit logs.
+type ConnectionState struct {
+	LocalAddr  net.Addr
+	RemoteAddr net.Addr
+	// MaxMessageSize is the maximal size of the message which can be received over the connection.
+	MaxMessageSize uint32
+	// BlockwiseSZX is the maximal block size used by the blockwise transfers.
+	BlockwiseSZX blockwise.SZX
+	// PeerMaxMessageSize is the Max-Message-Size option of the CSM received from the peer, 0 when the peer didn't send it.
+	PeerMaxMessageSize uint32
+	// PeerBlockWiseTransfer is true when the peer announced the Block-Wise-Transfer option in the CSM.
+	PeerBlockWiseTransfer bool
+	// TLS contains the state of the TLS connection. It is nil when the connection is not over TLS.
+	TLS *tls.ConnectionState
+}
+
+// ConnectionState returns the parameters of the connection.
+func (cc *Conn) ConnectionState() ConnectionState {
+	state := ConnectionState{
+		LocalAddr:             cc.LocalAddr(),
+		RemoteAddr:            cc.RemoteAddr(),
+		MaxMessageSize:        cc.session.maxMessageSize,
+		BlockwiseSZX:          cc.blockwiseSZX,
+		PeerMaxMessageSize:    cc.peerMaxMessageSize.Load(),
+		PeerBlockWiseTransfer: cc.peerBlockWiseTranferEnabled.Load(),
+	}
+	if c, ok := cc.NetConn().(*tls.Conn); ok {
+		tlsState := c.ConnectionState()
+		state.TLS = &tlsState
+	}
+	return state
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"sync"
 	"time"
@@ -13,7 +14,9 @@ import (
 	"github.com/plgd-dev/go-coap/v3/message/codes"
 	"github.com/plgd-dev/go-coap/v3/message/pool"
 	coapNet "github.com/plgd-dev/go-coap/v3/net"
+	"github.com/plgd-dev/go-coap/v3/net/metrics"
 	"github.com/plgd-dev/go-coap/v3/net/monitor/inactivity"
+	"github.com/plgd-dev/go-coap/v3/options/config"
 	"github.com/plgd-dev/go-coap/v3/pkg/math"
 	"github.com/plgd-dev/go-coap/v3/tcp/coder"
 	"go.uber.org/atomic"
@@ -26,7 +29,7 @@ type Session struct {
 	inactivityMonitor InactivityMonitor
 	requestMonitor    RequestMonitorFunc
 	errSendCSM        error
-	cancel            context.CancelFunc
+	cancel            context.CancelCauseFunc
 	done              chan struct{}
 	errors            ErrorFunc
 	connection        *coapNet.Conn
@@ -40,6 +43,13 @@ type Session struct {
 	connectionCacheSize        uint16
 	disableTCPSignalMessageCSM bool
 	closeSocket                bool
+	wireTap                    config.WireTapFunc
+	onParseError               config.ParseErrorFunc
+	decoder                    *coder.Coder
+	encoder                    *coder.Coder
+	tokenLength                message.TokenLength
+	sendQueue                  *coapNet.SendQueue
+	metrics                    metrics.Collector
 }
 
 func NewSession(
@@ -54,7 +64,7 @@ func NewSession(
 	connectionCacheSize uint16,
 	messagePool *pool.Pool,
 ) *Session {
-	ctx, cancel := context.WithCancel(ctx)
+	ctx, cancel := context.WithCancelCause(ctx)
 	if errors == nil {
 		errors = func(error) {
 			// default no-op
@@ -81,6 +91,10 @@ func NewSession(
 		done:                       make(chan struct{}),
 		connectionCacheSize:        connectionCacheSize,
 		messagePool:                messagePool,
+		decoder:                    coder.DefaultCoder,
+		encoder:                    coder.DefaultCoder,
+		tokenLength:                message.DefaultTokenLength,
+		metrics:                    metrics.NilCollector{},
 	}
 	s.ctx.Store(&ctx)
 
@@ -127,7 +141,7 @@ func (s *Session) shutdown() {
 }
 
 func (s *Session) Close() error {
-	s.cancel()
+	s.cancel(nil)
 	if s.closeSocket {
 		return s.connection.Close()
 	}
@@ -175,14 +189,34 @@ func (s *Session) processBuffer(buffer *bytes.Buffer, cc *Conn) error {
 		if math.CastTo[uint32](buffer.Len()) < header.MessageLength {
 			return nil
 		}
-		req := s.messagePool.AcquireMessage(s.Context())
-		read, err := req.UnmarshalWithDecoder(coder.DefaultCoder, buffer.Bytes()[:header.MessageLength])
+		if s.wireTap != nil {
+			s.wireTap(config.DirectionReceived, buffer.Bytes()[:header.MessageLength], s.RemoteAddr())
+		}
+		req, err := s.messagePool.AcquireMessageNoWait(s.Context())
+		if err != nil {
+			// the frame is dropped, so the exhausted pool doesn't close the connection
+			metrics.MessageDropped(s.metrics)
+			buffer = seekBufferToNextMessage(buffer, math.CastTo[int](header.MessageLength))
+			continue
+		}
+		read, err := req.UnmarshalWithDecoder(s.decoder, buffer.Bytes()[:header.MessageLength])
 		if err != nil {
 			s.messagePool.ReleaseMessage(req)
+			if s.onParseError != nil {
+				s.onParseError(buffer.Bytes()[:header.MessageLength], s.RemoteAddr(), err)
+			}
+			return fmt.Errorf("cannot unmarshal with header: %w", err)
+		}
+		if err = s.tokenLength.Validate(req.Code(), req.Token()); err != nil {
+			s.messagePool.ReleaseMessage(req)
+			if s.onParseError != nil {
+				s.onParseError(buffer.Bytes()[:header.MessageLength], s.RemoteAddr(), err)
+			}
 			return fmt.Errorf("cannot unmarshal with header: %w", err)
 		}
 		buffer = seekBufferToNextMessage(buffer, read)
 		req.SetSequence(s.Sequence())
+		s.metrics.MessageReceived(req.Code())
 
 		drop, err := s.requestMonitor(cc, req)
 		if err != nil {
@@ -199,11 +233,99 @@ func (s *Session) processBuffer(buffer *bytes.Buffer, cc *Conn) error {
 	return nil
 }
 
+// SetWireTap sets the function which is called with every frame sent or received by the session.
+func (s *Session) SetWireTap(wireTap config.WireTapFunc) {
+	s.wireTap = wireTap
+}
+
+// SetMetrics sets the collector of the messages sent and received by the session, nil disables the collection.
+func (s *Session) SetMetrics(collector metrics.Collector) {
+	s.metrics = metrics.OrNil(collector)
+}
+
+// SetOnParseError sets the function which is called with every frame which cannot be parsed.
+func (s *Session) SetOnParseError(onParseError config.ParseErrorFunc) {
+	s.onParseError = onParseError
+}
+
+// SetStrictParsing rejects the received frames whose options are not in the canonical form as the frames which cannot be parsed.
+func (s *Session) SetStrictParsing(strict bool) {
+	s.decoder = coder.DefaultCoder
+	if strict {
+		s.decoder = coder.StrictCoder
+	}
+}
+
+// SetTokenLength sets the lengths of the tokens of the written and the received frames, the written frames with other
+// lengths are rejected and the received ones are handled as the frames which cannot be parsed.
+func (s *Session) SetTokenLength(tokenLength message.TokenLength) {
+	s.tokenLength = tokenLength
+	s.encoder = coder.DefaultCoder.WithMaxTokenSize(tokenLength.Max)
+}
+
+// streamBodyThreshold is the size of the body from which the message is written as the length-prefixed frame header
+// followed by the body streamed from the reader, so the body is not buffered in the memory.
+const streamBodyThreshold = 16 * 1024
+
+// SetSendQueue bounds the messages written by the session at once, nil removes the bound.
+func (s *Session) SetSendQueue(sendQueue *coapNet.SendQueue) {
+	s.sendQueue = sendQueue
+}
+
+// Flush blocks until the messages queued by the send queue are written to the connection or the ctx is done.
+// Without the send queue the messages are written synchronously, so there is nothing to wait for.
+func (s *Session) Flush(ctx context.Context) error {
+	if s.sendQueue == nil {
+		return nil
+	}
+	return s.sendQueue.Flush(ctx)
+}
+
+// WriteMessage writes the message to the connection. The body of at least 16KiB is streamed from the reader directly after
+// the frame header, unless the wire tap is set. The size of the frame is not checked against the Max-Message-Size announced
+// by the peer in its CSM (see Conn.ConnectionState), so the larger bodies must be sent via the blockwise transfer
+// or the peer can close the connection.
 func (s *Session) WriteMessage(req *pool.Message) error {
-	data, err := req.MarshalWithEncoder(coder.DefaultCoder)
+	if s.sendQueue == nil {
+		return s.writeAndCountMessage(req)
+	}
+	return s.sendQueue.Write(req.Context(), func() error {
+		return s.writeAndCountMessage(req)
+	})
+}
+
+func (s *Session) writeAndCountMessage(req *pool.Message) error {
+	// the message can be released by the response which is received right after the write
+	code := req.Code()
+	if err := s.writeMessage(req); err != nil {
+		return err
+	}
+	s.metrics.MessageSent(code)
+	return nil
+}
+
+func (s *Session) writeMessage(req *pool.Message) error {
+	if err := s.tokenLength.Validate(req.Code(), req.Token()); err != nil {
+		return err
+	}
+	if s.wireTap == nil && req.Body() != nil {
+		bodySize, err := req.BodySize()
+		switch {
+		case errors.Is(err, message.ErrStreamSizeUnknown):
+			// the body of unknown size is marshaled at once
+		case err != nil:
+			return fmt.Errorf("cannot get body size: %w", err)
+		case bodySize >= streamBodyThreshold:
+			return s.writeMessageStream(req, bodySize)
+		}
+	}
+	data, err := req.MarshalWithEncoder(s.encoder)
 	if err != nil {
 		return fmt.Errorf("cannot marshal: %w", err)
 	}
+	if s.wireTap != nil {
+		s.wireTap(config.DirectionSent, data, s.RemoteAddr())
+	}
 	err = s.connection.WriteWithContext(req.Context(), data)
 	if err != nil {
 		return fmt.Errorf("cannot write to connection: %w", err)
@@ -211,6 +333,25 @@ func (s *Session) WriteMessage(req *pool.Message) error {
 	return err
 }
 
+func (s *Session) writeMessageStream(req *pool.Message, bodySize int64) error {
+	payloadLen, err := math.SafeCastTo[int](bodySize)
+	if err != nil {
+		return fmt.Errorf("cannot marshal: %w", err)
+	}
+	header, err := req.MarshalHeaderWithEncoder(s.encoder, payloadLen)
+	if err != nil {
+		return fmt.Errorf("cannot marshal: %w", err)
+	}
+	if _, err = req.Body().Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("cannot seek body: %w", err)
+	}
+	err = s.connection.WriteStreamWithContext(req.Context(), header, req.Body(), bodySize)
+	if err != nil {
+		return fmt.Errorf("cannot write to connection: %w", err)
+	}
+	return nil
+}
+
 func (s *Session) sendCSM() error {
 	token, err := message.GetToken()
 	if err != nil {
@@ -233,6 +374,10 @@ func shrinkBufferIfNecessary(buffer *bytes.Buffer, maxCap uint16) *bytes.Buffer
 // Run reads and process requests from a connection, until the connection is not closed.
 func (s *Session) Run(cc *Conn) (err error) {
 	defer func() {
+		if err != nil {
+			// the error is the cause of the closed connection
+			s.cancel(err)
+		}
 		err1 := s.Close()
 		if err == nil {
 			err = err1
@@ -253,6 +398,7 @@ func (s *Session) Run(cc *Conn) (err error) {
 		readLen, err := s.connection.ReadWithContext(s.Context(), readBuf)
 		if err != nil {
 			if coapNet.IsConnectionBrokenError(err) { // other side closed the connection, ignore the error and return
+				s.cancel(err)
 				return nil
 			}
 			return fmt.Errorf("cannot read from connection: %w", err)
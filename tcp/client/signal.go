@@ -0,0 +1,164 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+	coapNet "github.com/plgd-dev/go-coap/v3/net"
+	"github.com/plgd-dev/go-coap/v3/net/observation"
+)
+
+type (
+	// OnReleaseFunc is called when the peer sends the Release signal.
+	OnReleaseFunc = func(cc *Conn, release ReleaseSignal)
+	// OnAbortFunc is called when the peer sends the Abort signal, the connection is closed after the call.
+	OnAbortFunc = func(cc *Conn, abort AbortSignal)
+)
+
+// ReleaseSignal contains the options of the Release signal (RFC 8323 section 5.5). The sender of the Release
+// announces that it is going to close the connection, so no new requests are sent over it.
+type ReleaseSignal struct {
+	// AlternativeAddresses where the sender is reachable, e.g. "[2001:db8::1]:5684".
+	AlternativeAddresses []string
+	// HoldOff is the time during which the sender doesn't accept new connections.
+	HoldOff time.Duration
+}
+
+// AbortSignal contains the options of the Abort signal (RFC 8323 section 5.6). The sender of the Abort
+// is unable to continue and it closes the connection.
+type AbortSignal struct {
+	// BadCSMOption is the option of the CSM which caused the abort, valid only when HasBadCSMOption is true.
+	BadCSMOption    message.OptionID
+	HasBadCSMOption bool
+	// Diagnostic is the human-readable description of the reason.
+	Diagnostic string
+}
+
+func parseReleaseSignal(r *pool.Message) ReleaseSignal {
+	var release ReleaseSignal
+	for _, o := range r.Options() {
+		if o.ID == message.TCPAlternativeAddress {
+			release.AlternativeAddresses = append(release.AlternativeAddresses, string(o.Value))
+		}
+	}
+	if holdOff, err := r.GetOptionUint32(message.TCPHoldOff); err == nil {
+		release.HoldOff = time.Duration(holdOff) * time.Second
+	}
+	return release
+}
+
+func parseAbortSignal(r *pool.Message) (AbortSignal, error) {
+	var abort AbortSignal
+	if opt, err := r.GetOptionUint32(message.TCPBadCSMOption); err == nil {
+		abort.BadCSMOption = message.OptionID(opt)
+		abort.HasBadCSMOption = true
+	}
+	if r.Body() == nil {
+		return abort, nil
+	}
+	diagnostic, err := r.ReadBody()
+	if err != nil {
+		return abort, fmt.Errorf("cannot read diagnostic payload: %w", err)
+	}
+	abort.Diagnostic = string(diagnostic)
+	return abort, nil
+}
+
+func (cc *Conn) handleRelease(r *pool.Message) {
+	if cc.onRelease == nil {
+		return
+	}
+	cc.onRelease(cc, parseReleaseSignal(r))
+}
+
+func (cc *Conn) handleAbort(r *pool.Message) {
+	abort, err := parseAbortSignal(r)
+	if err != nil {
+		cc.Session().errors(fmt.Errorf("cannot handle abort signal: %w", err))
+	}
+	if cc.onAbort != nil {
+		cc.onAbort(cc, abort)
+	}
+	cc.closeReason.CompareAndSwap(uint32(coapNet.CloseReasonNone), uint32(coapNet.CloseReasonPeerReset))
+	if err := cc.Close(); err != nil {
+		cc.Session().errors(fmt.Errorf("cannot close connection after abort signal: %w", err))
+	}
+}
+
+// Release sends the Release signal which announces to the peer that the connection is going to be closed,
+// e.g. to migrate the peer to one of the alternative addresses. The connection stays open, so the outstanding
+// exchanges can be finished before Close is called.
+func (cc *Conn) Release(release ReleaseSignal) error {
+	req := cc.AcquireMessage(cc.Context())
+	defer cc.ReleaseMessage(req)
+	req.SetCode(codes.Release)
+	for _, addr := range release.AlternativeAddresses {
+		req.AddOptionString(message.TCPAlternativeAddress, addr)
+	}
+	if release.HoldOff > 0 {
+		req.SetOptionUint32(message.TCPHoldOff, uint32(release.HoldOff/time.Second))
+	}
+	return cc.Session().WriteMessage(req)
+}
+
+// CloseWithRelease closes the connection gracefully (RFC 8323 section 5.5): it sends the Release signal, so the peer
+// can reconnect to one of the alternative addresses, and it waits until the peer closes the connection or the ctx is done.
+// Then the connection is closed. Unlike Abort, the peer can finish its outstanding exchanges meanwhile.
+func (cc *Conn) CloseWithRelease(ctx context.Context, release ReleaseSignal) error {
+	err := cc.Release(release)
+	if err == nil {
+		select {
+		case <-ctx.Done():
+		case <-cc.Done():
+		}
+	}
+	if errC := cc.Close(); errC != nil && err == nil {
+		err = errC
+	}
+	return err
+}
+
+// PeerObservations returns the tokens of the observations registered by the peer and accepted by the handler.
+func (cc *Conn) PeerObservations() []message.Token {
+	if cc.peerObservations == nil {
+		return nil
+	}
+	return cc.peerObservations.Tokens()
+}
+
+// MigrateObservers moves the observers to the server at the altAddr: when the peer registered any observation,
+// the connection is closed by CloseWithRelease with the altAddr as the alternative address, so the peer reconnects
+// and registers the observations again. The connection without the observations is not affected. The observations
+// must be tracked, see options.WithObserverMigration, otherwise observation.ErrNotTracked is returned.
+func (cc *Conn) MigrateObservers(ctx context.Context, altAddr string) error {
+	if cc.peerObservations == nil {
+		return observation.ErrNotTracked
+	}
+	if len(cc.PeerObservations()) == 0 {
+		return nil
+	}
+	return cc.CloseWithRelease(ctx, ReleaseSignal{AlternativeAddresses: []string{altAddr}})
+}
+
+// Abort sends the Abort signal with the diagnostic to the peer and closes the connection.
+func (cc *Conn) Abort(abort AbortSignal) error {
+	req := cc.AcquireMessage(cc.Context())
+	defer cc.ReleaseMessage(req)
+	req.SetCode(codes.Abort)
+	if abort.HasBadCSMOption {
+		req.SetOptionUint32(message.TCPBadCSMOption, uint32(abort.BadCSMOption))
+	}
+	if abort.Diagnostic != "" {
+		req.SetBody(bytes.NewReader([]byte(abort.Diagnostic)))
+	}
+	err := cc.Session().WriteMessage(req)
+	if errC := cc.Close(); errC != nil && err == nil {
+		err = errC
+	}
+	return err
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"io"
+	"net"
 	"sync"
 	"testing"
 	"time"
@@ -18,6 +19,7 @@ import (
 	"github.com/plgd-dev/go-coap/v3/options/config"
 	"github.com/plgd-dev/go-coap/v3/pkg/runner/periodic"
 	"github.com/plgd-dev/go-coap/v3/tcp/client"
+	"github.com/plgd-dev/go-coap/v3/tcp/coder"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/atomic"
@@ -96,7 +98,7 @@ func TestConnGet(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		errS := s.Serve(l)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := Dial(l.Addr().String())
@@ -226,7 +228,7 @@ func TestConnPost(t *testing.T) {
 			go func() {
 				defer wg.Done()
 				errS := s.Serve(l)
-				assert.NoError(t, errS)
+				assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 			}()
 
 			cc, err := Dial(l.Addr().String())
@@ -354,7 +356,7 @@ func TestConnPut(t *testing.T) {
 			go func() {
 				defer wg.Done()
 				errS := s.Serve(l)
-				assert.NoError(t, errS)
+				assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 			}()
 
 			cc, err := Dial(l.Addr().String())
@@ -459,7 +461,7 @@ func TestConnDelete(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		errS := s.Serve(l)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := Dial(l.Addr().String())
@@ -511,7 +513,7 @@ func TestConnPing(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		errS := s.Serve(l)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := Dial(l.Addr().String())
@@ -528,6 +530,310 @@ func TestConnPing(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestConnReleaseAbortSignals(t *testing.T) {
+	l, err := coapNet.NewTCPListener("tcp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	serverConns := make(chan *client.Conn, 1)
+	s := NewServer(options.WithOnNewConn(func(cc *client.Conn) {
+		serverConns <- cc
+	}))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	releases := make(chan client.ReleaseSignal, 1)
+	aborts := make(chan client.AbortSignal, 1)
+	cc, err := Dial(l.Addr().String(),
+		options.WithOnRelease(func(_ *client.Conn, release client.ReleaseSignal) {
+			releases <- release
+		}),
+		options.WithOnAbort(func(_ *client.Conn, abort client.AbortSignal) {
+			aborts <- abort
+		}),
+	)
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	err = cc.Ping(ctx)
+	require.NoError(t, err)
+	sc := <-serverConns
+
+	release := client.ReleaseSignal{
+		AlternativeAddresses: []string{"coap+tcp://[2001:db8::1]:5683", "coap+tcp://192.0.2.1:5683"},
+		HoldOff:              time.Minute,
+	}
+	err = sc.Release(release)
+	require.NoError(t, err)
+	select {
+	case r := <-releases:
+		require.Equal(t, release, r)
+	case <-ctx.Done():
+		require.NoError(t, ctx.Err())
+	}
+	// the connection is still usable after the release
+	err = cc.Ping(ctx)
+	require.NoError(t, err)
+
+	abort := client.AbortSignal{
+		BadCSMOption:    message.TCPMaxMessageSize,
+		HasBadCSMOption: true,
+		Diagnostic:      "unsupported max message size",
+	}
+	err = sc.Abort(abort)
+	require.NoError(t, err)
+	select {
+	case a := <-aborts:
+		require.Equal(t, abort, a)
+	case <-ctx.Done():
+		require.NoError(t, ctx.Err())
+	}
+	select {
+	case <-cc.Done():
+	case <-ctx.Done():
+		require.NoError(t, ctx.Err())
+	}
+	require.Equal(t, coapNet.CloseReasonPeerReset, cc.CloseReason())
+}
+
+func TestConnCloseWithRelease(t *testing.T) {
+	l, err := coapNet.NewTCPListener("tcp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	serverConns := make(chan *client.Conn, 1)
+	s := NewServer(options.WithOnNewConn(func(cc *client.Conn) {
+		serverConns <- cc
+	}))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	releases := make(chan client.ReleaseSignal, 1)
+	cc, err := Dial(l.Addr().String(),
+		options.WithOnRelease(func(cc *client.Conn, release client.ReleaseSignal) {
+			releases <- release
+			// the client reconnects to the alternative address, so it closes the released connection
+			errC := cc.Close()
+			assert.NoError(t, errC)
+		}),
+	)
+	require.NoError(t, err)
+	defer func() {
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	err = cc.Ping(ctx)
+	require.NoError(t, err)
+	sc := <-serverConns
+
+	release := client.ReleaseSignal{
+		AlternativeAddresses: []string{"coap+tcp://192.0.2.1:5683"},
+	}
+	err = sc.CloseWithRelease(ctx, release)
+	require.NoError(t, err)
+	require.NoError(t, ctx.Err())
+	require.Equal(t, release, <-releases)
+	<-sc.Done()
+}
+
+func TestServerMigrateObservers(t *testing.T) {
+	l, err := coapNet.NewTCPListener("tcp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	m := mux.NewRouter()
+	err = m.Handle("/tmp", mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		n := mux.NewNotifier(w, r)
+		errS := n.SetResponse(w, codes.Content, message.TextPlain, bytes.NewReader([]byte("0")))
+		assert.NoError(t, errS)
+	}))
+	require.NoError(t, err)
+
+	serverConns := make(chan *client.Conn, 1)
+	s := NewServer(options.WithMux(m), options.WithObserverMigration(), options.WithOnNewConn(func(cc *client.Conn) {
+		serverConns <- cc
+	}))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	releases := make(chan client.ReleaseSignal, 1)
+	cc, err := Dial(l.Addr().String(),
+		options.WithOnRelease(func(cc *client.Conn, release client.ReleaseSignal) {
+			releases <- release
+			errC := cc.Close()
+			assert.NoError(t, errC)
+		}),
+	)
+	require.NoError(t, err)
+	defer func() {
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	_, err = cc.Observe(ctx, "/tmp", func(*pool.Message) {})
+	require.NoError(t, err)
+	sc := <-serverConns
+	require.Len(t, sc.PeerObservations(), 1)
+
+	const altAddr = "coap+tcp://192.0.2.1:5683"
+	err = s.MigrateObservers(ctx, altAddr)
+	require.NoError(t, err)
+	require.Equal(t, client.ReleaseSignal{AlternativeAddresses: []string{altAddr}}, <-releases)
+	<-sc.Done()
+}
+
+func TestConnPostStreamedBody(t *testing.T) {
+	l, err := coapNet.NewTCPListener("tcp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	payload := make([]byte, 2*1024*1024)
+	for i := range payload {
+		payload[i] = byte(i % 251)
+	}
+	m := mux.NewRouter()
+	err = m.Handle("/a", mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		body, errR := r.ReadBody()
+		require.NoError(t, errR)
+		require.Equal(t, payload, body)
+		errS := w.SetResponse(codes.Changed, message.TextPlain, nil)
+		require.NoError(t, errS)
+	}))
+	require.NoError(t, err)
+
+	s := NewServer(options.WithMux(m), options.WithMaxMessageSize(4*1024*1024))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := Dial(l.Addr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	// the body is streamed in one frame, because the peer doesn't announce the blockwise transfer
+	resp, err := cc.Post(ctx, "/a", message.AppOctets, bytes.NewReader(payload))
+	require.NoError(t, err)
+	require.Equal(t, codes.Changed, resp.Code())
+}
+
+func TestConnConnectionState(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	// peer announces its capabilities by CSM
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c, errA := l.Accept()
+		if errA != nil {
+			return
+		}
+		defer func() {
+			_ = c.Close()
+		}()
+		csm := message.Message{
+			Code: codes.CSM,
+			Options: message.Options{
+				{ID: message.TCPMaxMessageSize, Value: []byte{0x80, 0x00}},
+				{ID: message.TCPBlockWiseTransfer},
+			},
+		}
+		buf := make([]byte, 64)
+		n, errE := coder.DefaultCoder.Encode(csm, buf)
+		assert.NoError(t, errE)
+		_, errE = c.Write(buf[:n])
+		assert.NoError(t, errE)
+		// wait for close of the client
+		_, _ = io.Copy(io.Discard, c)
+	}()
+
+	cc, err := Dial(l.Addr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	var state client.ConnectionState
+	for i := 0; i < 100; i++ {
+		state = cc.ConnectionState()
+		if state.PeerBlockWiseTransfer {
+			break
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	require.Equal(t, cc.RemoteAddr(), state.RemoteAddr)
+	require.Equal(t, cc.LocalAddr(), state.LocalAddr)
+	require.Equal(t, uint32(32*1024), state.PeerMaxMessageSize)
+	require.True(t, state.PeerBlockWiseTransfer)
+	require.Nil(t, state.TLS)
+}
+
 func TestClientInactiveMonitor(t *testing.T) {
 	var inactivityDetected atomic.Bool
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*8)
@@ -566,7 +872,7 @@ func TestClientInactiveMonitor(t *testing.T) {
 	go func() {
 		defer serverWg.Done()
 		errS := sd.Serve(ld)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := Dial(
@@ -636,7 +942,7 @@ func TestClientKeepAliveMonitor(t *testing.T) {
 	go func() {
 		defer serverWg.Done()
 		errS := sd.Serve(ld)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 	defer func() {
 		sd.Stop()
@@ -725,7 +1031,7 @@ func TestConnRequestMonitorCloseConnection(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		errS := s.Serve(l)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := Dial(l.Addr().String(),
@@ -807,7 +1113,7 @@ func TestConnRequestMonitorDropRequest(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		errS := s.Serve(l)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := Dial(l.Addr().String(),
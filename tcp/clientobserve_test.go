@@ -165,7 +165,7 @@ func TestConnObserve(t *testing.T) {
 			go func() {
 				defer wg.Done()
 				errS := s.Serve(l)
-				assert.NoError(t, errS)
+				assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 			}()
 
 			cc, err := Dial(l.Addr().String())
@@ -282,7 +282,7 @@ func TestConnObserveNotSupported(t *testing.T) {
 			go func() {
 				defer wg.Done()
 				errS := s.Serve(l)
-				assert.NoError(t, errS)
+				assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 			}()
 
 			cc, err := Dial(l.Addr().String())
@@ -313,6 +313,61 @@ func TestConnObserveNotSupported(t *testing.T) {
 	}
 }
 
+func TestConnObserveWithQueries(t *testing.T) {
+	l, err := coapNet.NewTCPListener("tcp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	queries := make(chan []string, 2)
+	s := NewServer(options.WithHandlerFunc(func(w *responsewriter.ResponseWriter[*client.Conn], r *pool.Message) {
+		obs, errO := r.Observe()
+		if errO != nil {
+			return
+		}
+		q, errQ := r.Queries()
+		assert.NoError(t, errQ)
+		queries <- q
+		opts := message.Options{}
+		if obs == 0 {
+			opts, _, _ = opts.SetObserve(make([]byte, 4), 2)
+		}
+		errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("a")), opts...)
+		assert.NoError(t, errS)
+	}))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := Dial(l.Addr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	want := []string{"pmin=10", "pmax=60"}
+	obs, err := cc.Observe(ctx, "/tmp", func(*pool.Message) {
+		// no-op
+	}, message.QueryParams{{Key: "pmin", Value: "10"}, {Key: "pmax", Value: "60"}}.Options()...)
+	require.NoError(t, err)
+	require.Equal(t, want, <-queries)
+	err = obs.Cancel(ctx)
+	require.NoError(t, err)
+	require.Equal(t, want, <-queries)
+}
+
 func TestConnObserveCancel(t *testing.T) {
 	type cancelType int
 	const (
@@ -383,7 +438,12 @@ func TestConnObserveCancel(t *testing.T) {
 			go func() {
 				defer wg.Done()
 				errS := s.Serve(l)
-				assert.NoError(t, errS)
+				if tt.args.cancel == closeListeningConnection {
+					// the listener is closed without Stop
+					assert.Error(t, errS)
+					return
+				}
+				assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 			}()
 
 			cc, err := Dial(l.Addr().String())
@@ -450,3 +510,67 @@ func (o *observer) observe(req *pool.Message) {
 		}
 	}
 }
+
+func TestConnCancelObservations(t *testing.T) {
+	l, err := coapNet.NewTCPListener("tcp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	deregistered := make(chan string, 2)
+	s := NewServer(options.WithHandlerFunc(func(w *responsewriter.ResponseWriter[*client.Conn], r *pool.Message) {
+		obs, errO := r.Observe()
+		if errO != nil {
+			return
+		}
+		opts := message.Options{}
+		if obs == 0 {
+			opts, _, _ = opts.SetObserve(make([]byte, 4), 2)
+		} else {
+			path, errP := r.Path()
+			assert.NoError(t, errP)
+			deregistered <- path
+		}
+		errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("a")), opts...)
+		assert.NoError(t, errS)
+	}))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := Dial(l.Addr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	for _, path := range []string{"/a", "/b"} {
+		_, err = cc.Observe(ctx, path, func(*pool.Message) {
+			// no-op
+		})
+		require.NoError(t, err)
+	}
+	observations := cc.Observations()
+	require.Len(t, observations, 2)
+
+	err = cc.CancelObservations(ctx)
+	require.NoError(t, err)
+	require.Empty(t, cc.Observations())
+	for _, o := range observations {
+		require.True(t, o.Canceled())
+	}
+	paths := []string{<-deregistered, <-deregistered}
+	require.ElementsMatch(t, []string{"/a", "/b"}, paths)
+}
@@ -11,6 +11,10 @@ import (
 
 var DefaultCoder = new(Coder)
 
+// StrictCoder rejects the received messages whose options are not in the canonical form, see message.Options.ValidateCanonical,
+// and the sent and received messages whose options violate their definitions, see message.Options.Validate.
+var StrictCoder = &Coder{strict: true}
+
 const (
 	MessageLength13Base = 13
 	MessageLength14Base = 269
@@ -18,7 +22,26 @@ const (
 	messageMaxLen       = 0x7fff0000 // Large number that works in 32-bit builds
 )
 
-type Coder struct{}
+type Coder struct {
+	strict       bool
+	maxTokenSize int
+}
+
+// WithMaxTokenSize returns the copy of the coder which encodes the tokens up to maxTokenSize bytes, e.g.
+// message.MaxEncodableTokenSize for the conformance tests of the peers. By default the tokens are up to
+// message.MaxTokenSize bytes.
+func (c *Coder) WithMaxTokenSize(maxTokenSize int) *Coder {
+	coder := *c
+	coder.maxTokenSize = maxTokenSize
+	return &coder
+}
+
+func (c *Coder) tokenSizeLimit() int {
+	if c.maxTokenSize <= 0 || c.maxTokenSize > message.MaxEncodableTokenSize {
+		return message.MaxTokenSize
+	}
+	return c.maxTokenSize
+}
 
 type MessageHeader struct {
 	Token         []byte
@@ -86,23 +109,53 @@ func (c *Coder) Encode(m message.Message, buf []byte) (int, error) {
 	   | 15         | 4                     | Extended Length + 65805   |
 	*/
 
-	if len(m.Token) > message.MaxTokenSize {
-		return -1, message.ErrInvalidTokenLen
+	hdrLen, bufLen, err := c.encodeHeader(m, len(m.Payload), buf)
+	if err != nil {
+		return bufLen, err
+	}
+	if len(buf) < bufLen {
+		return bufLen, message.ErrTooSmall
+	}
+	if len(m.Payload) > 0 {
+		copy(buf[hdrLen:], m.Payload)
+	}
+	return bufLen, nil
+}
+
+// EncodeHeader encodes the message without the payload: the frame header with the length of the payloadLen bytes,
+// the options and the payload marker. The payload is ignored, so it can be written directly from a reader after the header.
+// Returns the size of the encoded header.
+func (c *Coder) EncodeHeader(m message.Message, payloadLen int, buf []byte) (int, error) {
+	hdrLen, bufLen, err := c.encodeHeader(m, payloadLen, buf)
+	if errors.Is(err, message.ErrTooSmall) {
+		return bufLen - payloadLen, err
+	}
+	return hdrLen, err
+}
+
+// encodeHeader encodes the message without the payload and returns the size of the header and the size of the whole message.
+// The buf must have space only for the header.
+func (c *Coder) encodeHeader(m message.Message, payloadLen int, buf []byte) (int, int, error) {
+	if len(m.Token) > c.tokenSizeLimit() {
+		return -1, -1, message.ErrInvalidTokenLen
+	}
+	if err := c.validateOptions(m.Options, optionDefs(m.Code)); err != nil {
+		return -1, -1, err
 	}
 
-	payloadLen := len(m.Payload)
+	payloadMarkerLen := 0
 	if payloadLen > 0 {
 		// for separator 0xff
-		payloadLen++
+		payloadMarkerLen = 1
 	}
 	optionsLen, err := m.Options.Marshal(nil)
 	if !errors.Is(err, message.ErrTooSmall) {
-		return -1, err
+		return -1, -1, err
 	}
-	bufLen := payloadLen + optionsLen
+	bufLen := payloadLen + payloadMarkerLen + optionsLen
 	lenNib, extLenBytes := getHeader(bufLen)
 
-	var hdr [1 + 4 + message.MaxTokenSize + 1]byte
+	var hdr [1 + 4 + message.MaxEncodableTokenSize + 1]byte
 	hdrLen := 1 + len(extLenBytes) + len(m.Token) + 1
 	hdrOff := 0
 
@@ -129,8 +182,8 @@ func (c *Coder) Encode(m message.Message, buf []byte) (int, error) {
 	copyToHdr(hdrOff, m.Token)
 
 	bufLen += hdrLen
-	if len(buf) < bufLen {
-		return bufLen, message.ErrTooSmall
+	if len(buf) < bufLen-payloadLen {
+		return -1, bufLen, message.ErrTooSmall
 	}
 
 	copy(buf, hdr[:hdrLen])
@@ -138,16 +191,63 @@ func (c *Coder) Encode(m message.Message, buf []byte) (int, error) {
 	switch {
 	case err == nil:
 	case errors.Is(err, message.ErrTooSmall):
-		return bufLen, err
+		return -1, bufLen, err
 	default:
+		return -1, -1, err
+	}
+	hdrLen += optionsLen
+	if payloadMarkerLen > 0 {
+		buf[hdrLen] = 0xff
+		hdrLen++
+	}
+	return hdrLen, bufLen, nil
+}
+
+// EncodeTail encodes the options, the payload marker and the payload of the message, which follow the token in the frame.
+func (c *Coder) EncodeTail(m message.Message, buf []byte) (int, error) {
+	if err := c.validateOptions(m.Options, optionDefs(m.Code)); err != nil {
+		return -1, err
+	}
+	optionsLen, err := m.Options.Marshal(nil)
+	if !errors.Is(err, message.ErrTooSmall) {
 		return -1, err
 	}
+	size := optionsLen + len(m.Payload)
 	if len(m.Payload) > 0 {
-		copy(buf[hdrLen+optionsLen:], []byte{0xff})
-		copy(buf[hdrLen+optionsLen+1:], m.Payload)
+		// for separator 0xff
+		size++
 	}
+	if len(buf) < size {
+		return size, message.ErrTooSmall
+	}
+	if _, err = m.Options.Marshal(buf); err != nil {
+		return -1, err
+	}
+	if len(m.Payload) > 0 {
+		buf[optionsLen] = 0xff
+		copy(buf[optionsLen+1:], m.Payload)
+	}
+	return size, nil
+}
 
-	return bufLen, nil
+// EncodeWithTail encodes the frame header, the code and the token of the message followed by the tail encoded
+// by EncodeTail for the same code. The options and the payload of m are ignored.
+func (c *Coder) EncodeWithTail(m message.Message, tail []byte, buf []byte) (int, error) {
+	if len(m.Token) > c.tokenSizeLimit() {
+		return -1, message.ErrInvalidTokenLen
+	}
+	lenNib, extLenBytes := getHeader(len(tail))
+	size := 1 + len(extLenBytes) + 1 + len(m.Token) + len(tail)
+	if len(buf) < size {
+		return size, message.ErrTooSmall
+	}
+	buf[0] = math.CastTo[uint8](len(m.Token)) | (lenNib << 4)
+	n := 1 + copy(buf[1:], extLenBytes)
+	buf[n] = byte(m.Code)
+	n++
+	n += copy(buf[n:], m.Token)
+	copy(buf[n:], tail)
+	return size, nil
 }
 
 func (c *Coder) DecodeHeader(data []byte, h *MessageHeader) (int, error) {
@@ -211,24 +311,36 @@ func (c *Coder) DecodeHeader(data []byte, h *MessageHeader) (int, error) {
 	return int(h.Length), nil
 }
 
-func (c *Coder) DecodeWithHeader(data []byte, header MessageHeader, m *message.Message) (int, error) {
-	optionDefs := message.CoapOptionDefs
-	processed := header.Length
-	switch header.Code {
+// optionDefs returns the definitions of the options for the code, the signal messages have own option numbers.
+func optionDefs(code codes.Code) map[message.OptionID]message.OptionDef {
+	switch code {
 	case codes.CSM:
-		optionDefs = message.TCPSignalCSMOptionDefs
+		return message.TCPSignalCSMOptionDefs
 	case codes.Ping, codes.Pong:
-		optionDefs = message.TCPSignalPingPongOptionDefs
+		return message.TCPSignalPingPongOptionDefs
 	case codes.Release:
-		optionDefs = message.TCPSignalReleaseOptionDefs
+		return message.TCPSignalReleaseOptionDefs
 	case codes.Abort:
-		optionDefs = message.TCPSignalAbortOptionDefs
+		return message.TCPSignalAbortOptionDefs
 	}
+	return message.CoapOptionDefs
+}
 
-	proc, err := m.Options.Unmarshal(data, optionDefs)
+func (c *Coder) DecodeWithHeader(data []byte, header MessageHeader, m *message.Message) (int, error) {
+	processed := header.Length
+	defs := optionDefs(header.Code)
+	proc, err := m.Options.Unmarshal(data, defs)
 	if err != nil {
 		return -1, err
 	}
+	if c.strict {
+		if err = m.Options.ValidateCanonical(defs); err != nil {
+			return -1, err
+		}
+		if err = c.validateOptions(m.Options, defs); err != nil {
+			return -1, err
+		}
+	}
 	data = data[proc:]
 	processed += math.CastTo[uint32](proc)
 
@@ -253,3 +365,11 @@ func (c *Coder) Decode(data []byte, m *message.Message) (int, error) {
 	}
 	return c.DecodeWithHeader(data[header.Length:], header, m)
 }
+
+// validateOptions validates the options by their definitions only for the strict coder.
+func (c *Coder) validateOptions(options message.Options, optionDefs map[message.OptionID]message.OptionDef) error {
+	if !c.strict {
+		return nil
+	}
+	return options.Validate(optionDefs)
+}
@@ -70,3 +70,68 @@ func FuzzDecode(f *testing.F) {
 		_, _ = DefaultCoder.Decode(input_data, &msg)
 	})
 }
+
+func TestEncodeHeader(t *testing.T) {
+	for _, payloadLen := range []int{0, 1, 12, 300, 70000} {
+		msg := message.Message{
+			Code:    codes.POST,
+			Token:   []byte{0x1, 0x2, 0x3},
+			Payload: make([]byte, payloadLen),
+		}
+		for i := range msg.Payload {
+			msg.Payload[i] = byte(i)
+		}
+		expected := make([]byte, payloadLen+32)
+		n, err := DefaultCoder.Encode(msg, expected)
+		require.NoError(t, err)
+		expected = expected[:n]
+
+		hdrLen, err := DefaultCoder.EncodeHeader(msg, payloadLen, nil)
+		require.ErrorIs(t, err, message.ErrTooSmall)
+		buf := make([]byte, hdrLen)
+		n, err = DefaultCoder.EncodeHeader(msg, payloadLen, buf)
+		require.NoError(t, err)
+		require.Equal(t, hdrLen, n)
+		// the header followed by the payload is the whole message
+		require.Equal(t, expected, append(buf[:n], msg.Payload...))
+	}
+}
+
+func TestEncodeInvalidOption(t *testing.T) {
+	msg := message.Message{
+		Code:    codes.GET,
+		Options: message.Options{{ID: message.ETag, Value: make([]byte, 9)}},
+	}
+	// only the strict coder validates the options
+	_, err := DefaultCoder.Encode(msg, make([]byte, 64))
+	require.NoError(t, err)
+	_, err = StrictCoder.Encode(msg, make([]byte, 64))
+	require.ErrorIs(t, err, message.ErrInvalidValueLength)
+
+	// the signal messages are validated by own definitions
+	msg = message.Message{
+		Code:    codes.CSM,
+		Options: message.Options{{ID: message.TCPBlockWiseTransfer}},
+	}
+	_, err = StrictCoder.Encode(msg, make([]byte, 64))
+	require.NoError(t, err)
+
+	// the repeatable options
+	msg = message.Message{
+		Code:    codes.GET,
+		Options: message.Options{{ID: message.RequestTag, Value: []byte{1}}, {ID: message.RequestTag, Value: []byte{2}}},
+	}
+	_, err = StrictCoder.Encode(msg, make([]byte, 64))
+	require.NoError(t, err)
+}
+
+func TestUnmarshalMessageStrict(t *testing.T) {
+	// Observe option with the value 1 encoded by 2 bytes
+	data := []byte{0x30, byte(codes.GET), 0x62, 0x00, 0x01}
+	msg := message.Message{Options: make(message.Options, 0, 4)}
+	_, err := DefaultCoder.Decode(data, &msg)
+	require.NoError(t, err)
+	msg = message.Message{Options: make(message.Options, 0, 4)}
+	_, err = StrictCoder.Decode(data, &msg)
+	require.ErrorIs(t, err, message.ErrOptionsNotCanonical)
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/plgd-dev/go-coap/v3/message"
 	"github.com/plgd-dev/go-coap/v3/message/codes"
 	"github.com/plgd-dev/go-coap/v3/message/pool"
+	coapNet "github.com/plgd-dev/go-coap/v3/net"
 	"github.com/plgd-dev/go-coap/v3/net/monitor/inactivity"
 	"github.com/plgd-dev/go-coap/v3/net/responsewriter"
 	"github.com/plgd-dev/go-coap/v3/options/config"
@@ -24,6 +25,9 @@ type GoPoolFunc = func(func()) error
 // OnNewConnFunc is the callback for new connections.
 type OnNewConnFunc = func(*client.Conn)
 
+// OnCloseConnFunc is the callback for closed connections with the reason of the close.
+type OnCloseConnFunc = func(cc *client.Conn, reason coapNet.CloseReason)
+
 var DefaultConfig = func() Config {
 	opts := Config{
 		Common: config.NewCommon[*client.Conn](),
@@ -31,7 +35,7 @@ var DefaultConfig = func() Config {
 			maxRetries := uint32(2)
 			timeout := time.Second * 16
 			onInactive := func(cc *client.Conn) {
-				_ = cc.Close()
+				_ = cc.CloseWithReason(coapNet.CloseReasonIdleTimeout)
 			}
 			keepalive := inactivity.NewKeepAlive(maxRetries, onInactive, func(cc *client.Conn, receivePong func()) (func(), error) {
 				return cc.AsyncPing(receivePong)
@@ -59,8 +63,12 @@ type Config struct {
 	CreateInactivityMonitor         client.CreateInactivityMonitorFunc
 	Handler                         HandlerFunc
 	OnNewConn                       OnNewConnFunc
+	OnCloseConn                     OnCloseConnFunc
+	MaxConnections                  uint32
 	RequestMonitor                  client.RequestMonitorFunc
 	ConnectionCacheSize             uint16
 	DisablePeerTCPSignalMessageCSMs bool
 	DisableTCPSignalMessageCSM      bool
+	OnRelease                       client.OnReleaseFunc
+	OnAbort                         client.OnAbortFunc
 }
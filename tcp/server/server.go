@@ -14,7 +14,9 @@ import (
 	"github.com/plgd-dev/go-coap/v3/net/blockwise"
 	"github.com/plgd-dev/go-coap/v3/net/monitor/inactivity"
 	"github.com/plgd-dev/go-coap/v3/pkg/connections"
+	"github.com/plgd-dev/go-coap/v3/pkg/runner/scheduler"
 	"github.com/plgd-dev/go-coap/v3/tcp/client"
+	"go.uber.org/atomic"
 )
 
 // Listener defined used by coap
@@ -27,8 +29,14 @@ type Server struct {
 	listenMutex sync.Mutex
 	listen      Listener
 	ctx         context.Context
-	cancel      context.CancelFunc
+	cancel      context.CancelCauseFunc
 	cfg         *Config
+
+	numConnections atomic.Uint32
+	connections    *connections.Connections
+
+	notificationSchedulerOnce sync.Once
+	notificationScheduler     *scheduler.Scheduler
 }
 
 // A Option sets options such as credentials, codec and keepalive parameters, etc.
@@ -42,7 +50,7 @@ func New(opt ...Option) *Server {
 		o.TCPServerApply(&cfg)
 	}
 
-	ctx, cancel := context.WithCancel(cfg.Ctx)
+	ctx, cancel := context.WithCancelCause(cfg.Ctx)
 
 	if cfg.CreateInactivityMonitor == nil {
 		cfg.CreateInactivityMonitor = func() client.InactivityMonitor {
@@ -72,9 +80,10 @@ func New(opt ...Option) *Server {
 	}
 
 	return &Server{
-		ctx:    ctx,
-		cancel: cancel,
-		cfg:    &cfg,
+		ctx:         ctx,
+		cancel:      cancel,
+		cfg:         &cfg,
+		connections: connections.New(),
 	}
 }
 
@@ -96,24 +105,28 @@ func (s *Server) popListener() Listener {
 	return l
 }
 
-func (s *Server) checkAcceptError(err error) bool {
+// checkAcceptError returns false with the error returned by Serve when the accepting of connections must be stopped.
+func (s *Server) checkAcceptError(err error) (bool, error) {
 	if err == nil {
-		return true
+		return true, nil
 	}
 	switch {
 	case errors.Is(err, coapNet.ErrListenerIsClosed):
+		if s.ctx.Err() != nil {
+			return false, coapNet.ErrServerClosed
+		}
 		s.Stop()
-		return false
+		return false, err
 	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
 		select {
 		case <-s.ctx.Done():
 		default:
 			s.cfg.Errors(fmt.Errorf("cannot accept connection: %w", err))
-			return true
+			return true, nil
 		}
-		return false
+		return false, coapNet.ErrServerClosed
 	default:
-		return true
+		return true, nil
 	}
 }
 
@@ -124,6 +137,11 @@ func (s *Server) serveConnection(connections *connections.Connections, rw net.Co
 	if s.cfg.OnNewConn != nil {
 		s.cfg.OnNewConn(cc)
 	}
+	if s.cfg.OnCloseConn != nil {
+		cc.AddOnClose(func() {
+			s.cfg.OnCloseConn(cc, cc.CloseReason())
+		})
+	}
 	connections.Store(cc)
 	defer connections.Delete(cc)
 
@@ -132,6 +150,9 @@ func (s *Server) serveConnection(connections *connections.Connections, rw net.Co
 	}
 }
 
+// Serve accepts the connections on the listener l until Stop is called. Serve always returns a non-nil error:
+// coapNet.ErrServerClosed after Stop or the cancellation of the context of the server, otherwise the error
+// which stopped the serving, e.g. when the listener was closed without Stop.
 func (s *Server) Serve(l Listener) error {
 	if s.cfg.BlockwiseSZX > blockwise.SZXBERT {
 		return errors.New("invalid blockwiseSZX")
@@ -147,7 +168,7 @@ func (s *Server) Serve(l Listener) error {
 	var wg sync.WaitGroup
 	defer wg.Wait()
 
-	connections := connections.New()
+	connections := s.connections
 	s.cfg.PeriodicRunner(func(now time.Time) bool {
 		connections.CheckExpirations(now)
 		return s.ctx.Err() == nil
@@ -156,23 +177,89 @@ func (s *Server) Serve(l Listener) error {
 
 	for {
 		rw, err := l.AcceptWithContext(s.ctx)
-		if ok := s.checkAcceptError(err); !ok {
-			return nil
+		if ok, errA := s.checkAcceptError(err); !ok {
+			return errA
 		}
 		if err != nil || rw == nil {
 			continue
 		}
+		if !s.acquireConnection() {
+			s.refuseConnection(rw, coapNet.ErrMaxConnectionsExceeded)
+			continue
+		}
 		wg.Add(1)
-		go func() {
+		started := s.cfg.Goroutines.TryGo(func() {
 			defer wg.Done()
+			defer s.releaseConnection()
 			s.serveConnection(connections, rw)
+		})
+		if !started {
+			wg.Done()
+			s.releaseConnection()
+			s.refuseConnection(rw, coapNet.ErrMaxGoroutinesExceeded)
+		}
+	}
+}
+
+// MigrateObservers moves the observers of all connections to the server at the altAddr, see client.Conn.MigrateObservers.
+// The connections with the observations are closed, the errors of the connections are joined.
+func (s *Server) MigrateObservers(ctx context.Context, altAddr string) error {
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	var errs []error
+	for _, c := range s.connections.List() {
+		cc, ok := c.(*client.Conn)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := cc.MigrateObservers(ctx, altAddr); err != nil {
+				mutex.Lock()
+				errs = append(errs, fmt.Errorf("%v: %w", cc.RemoteAddr(), err))
+				mutex.Unlock()
+			}
 		}()
 	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// acquireConnection reserves the slot for the new connection when the number of connections is limited.
+func (s *Server) acquireConnection() bool {
+	if s.cfg.MaxConnections == 0 {
+		return true
+	}
+	if s.numConnections.Inc() > s.cfg.MaxConnections {
+		s.numConnections.Dec()
+		return false
+	}
+	return true
+}
+
+func (s *Server) releaseConnection() {
+	if s.cfg.MaxConnections > 0 {
+		s.numConnections.Dec()
+	}
+}
+
+func (s *Server) refuseConnection(rw net.Conn, reason error) {
+	s.cfg.Errors(fmt.Errorf("%v: %w", rw.RemoteAddr(), reason))
+	if err := rw.Close(); err != nil {
+		s.cfg.Errors(fmt.Errorf("cannot close refused connection: %w", err))
+	}
+}
+
+// Goroutines returns the number of the goroutines of the connections counted by options.WithMaxGoroutines,
+// 0 when the option is not set.
+func (s *Server) Goroutines() int64 {
+	return s.cfg.Goroutines.Running()
 }
 
 // Stop stops server without wait of ends Serve function.
 func (s *Server) Stop() {
-	s.cancel()
+	s.cancel(coapNet.ErrServerShutdown)
 	l := s.popListener()
 	if l == nil {
 		return
@@ -182,6 +269,19 @@ func (s *Server) Stop() {
 	}
 }
 
+// NotificationScheduler returns the scheduler of the timed notifications served by the goroutines
+// set by options.WithNotificationWorkers. It is created by the first call and closed when the server is stopped.
+func (s *Server) NotificationScheduler() *scheduler.Scheduler {
+	s.notificationSchedulerOnce.Do(func() {
+		s.notificationScheduler = scheduler.New(s.cfg.NotificationWorkers)
+		go func() {
+			<-s.ctx.Done()
+			s.notificationScheduler.Close()
+		}()
+	})
+	return s.notificationScheduler
+}
+
 func (s *Server) createConn(connection *coapNet.Conn, inactivityMonitor client.InactivityMonitor, requestMonitor client.RequestMonitorFunc) *client.Conn {
 	createBlockWise := func(*client.Conn) *blockwise.BlockWise[*client.Conn] {
 		return nil
@@ -195,6 +295,11 @@ func (s *Server) createConn(connection *coapNet.Conn, inactivityMonitor client.I
 				func(message.Token) (*pool.Message, bool) {
 					return nil, false
 				},
+				blockwise.WithBufferAllocator(s.cfg.BlockwiseBufferAllocator),
+				blockwise.WithMaxRequestBodySize(s.cfg.BlockwiseMaxRequestBodySize),
+				blockwise.WithMaxConcurrentTransfers(s.cfg.BlockwiseMaxConcurrentTransfers),
+				blockwise.WithPerBlockTimeout(s.cfg.BlockwisePerBlockTimeout),
+				blockwise.WithMetrics(s.cfg.Metrics),
 			)
 		}
 	}
@@ -206,12 +311,26 @@ func (s *Server) createConn(connection *coapNet.Conn, inactivityMonitor client.I
 	cfg.BlockwiseSZX = s.cfg.BlockwiseSZX
 	cfg.DisablePeerTCPSignalMessageCSMs = s.cfg.DisablePeerTCPSignalMessageCSMs
 	cfg.DisableTCPSignalMessageCSM = s.cfg.DisableTCPSignalMessageCSM
+	cfg.OnRelease = s.cfg.OnRelease
+	cfg.OnAbort = s.cfg.OnAbort
 	cfg.CloseSocket = true
 	cfg.ConnectionCacheSize = s.cfg.ConnectionCacheSize
 	cfg.MessagePool = s.cfg.MessagePool
 	cfg.GetToken = s.cfg.GetToken
 	cfg.ProcessReceivedMessage = s.cfg.ProcessReceivedMessage
 	cfg.ReceivedMessageQueueSize = s.cfg.ReceivedMessageQueueSize
+	cfg.WireTap = s.cfg.WireTap
+	cfg.OnParseError = s.cfg.OnParseError
+	cfg.SerializedHandlers = s.cfg.SerializedHandlers
+	cfg.MaxObservations = s.cfg.MaxObservations
+	cfg.TrackObservations = s.cfg.TrackObservations
+	cfg.StrictParsing = s.cfg.StrictParsing
+	cfg.Metrics = s.cfg.Metrics
+	cfg.Goroutines = s.cfg.Goroutines
+	cfg.TokenLength = s.cfg.TokenLength
+	cfg.OnTransportError = s.cfg.OnTransportError
+	cfg.SendQueueSize = s.cfg.SendQueueSize
+	cfg.SendQueueOverflowPolicy = s.cfg.SendQueueOverflowPolicy
 	cc := client.NewConnWithOpts(
 		connection,
 		&cfg,
@@ -6,6 +6,7 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/binary"
+	"errors"
 	"math/big"
 	"net"
 	"sync"
@@ -63,7 +64,7 @@ func TestServerCleanUpConns(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		errS := sd.Serve(ld)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := tcp.Dial(ld.Addr().String())
@@ -167,7 +168,7 @@ func TestServerSetContextValueWithPKI(t *testing.T) {
 	defer sd.Stop()
 	go func() {
 		errS := sd.Serve(ld)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := tcp.Dial(ld.Addr().String(), options.WithTLS(clientCgf))
@@ -227,7 +228,7 @@ func TestServerInactiveMonitor(t *testing.T) {
 	go func() {
 		defer serverWg.Done()
 		errS := sd.Serve(ld)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := tcp.Dial(
@@ -258,6 +259,72 @@ func TestServerInactiveMonitor(t *testing.T) {
 	require.True(t, inactivityDetected.Load())
 }
 
+func TestServerMaxConnections(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*8)
+	defer cancel()
+
+	ld, err := coapNet.NewTCPListener("tcp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := ld.Close()
+		require.NoError(t, errC)
+	}()
+
+	var refused atomic.Bool
+	sd := tcp.NewServer(
+		options.WithMaxConnections(1),
+		options.WithErrors(func(err error) {
+			if errors.Is(err, coapNet.ErrMaxConnectionsExceeded) {
+				refused.Store(true)
+			}
+		}),
+	)
+
+	var serverWg sync.WaitGroup
+	defer func() {
+		sd.Stop()
+		serverWg.Wait()
+	}()
+	serverWg.Add(1)
+	go func() {
+		defer serverWg.Done()
+		errS := sd.Serve(ld)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := tcp.Dial(ld.Addr().String())
+	require.NoError(t, err)
+	err = cc.Ping(ctx)
+	require.NoError(t, err)
+
+	// the second connection is closed immediately by the server
+	cc1, err := tcp.Dial(ld.Addr().String())
+	if err == nil {
+		err = cc1.Ping(ctx)
+		require.Error(t, err)
+		<-cc1.Done()
+	}
+	require.True(t, refused.Load())
+
+	// the slot is released by the close of the connection
+	err = cc.Close()
+	require.NoError(t, err)
+	<-cc.Done()
+	require.Eventually(t, func() bool {
+		cc2, errD := tcp.Dial(ld.Addr().String())
+		if errD != nil {
+			return false
+		}
+		defer func() {
+			_ = cc2.Close()
+			<-cc2.Done()
+		}()
+		ctxPing, cancelPing := context.WithTimeout(ctx, time.Millisecond*200)
+		defer cancelPing()
+		return cc2.Ping(ctxPing) == nil
+	}, time.Second*4, time.Millisecond*50)
+}
+
 func TestServerKeepAliveMonitor(t *testing.T) {
 	var inactivityDetected atomic.Bool
 
@@ -298,7 +365,7 @@ func TestServerKeepAliveMonitor(t *testing.T) {
 	go func() {
 		defer serverWg.Done()
 		errS := sd.Serve(ld)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := net.Dial("tcp", ld.Addr().String())
@@ -350,7 +417,7 @@ func TestCheckForLossOrder(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		errS := sd.Serve(ld)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 	defer func() {
 		sd.Stop()
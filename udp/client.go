@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"net/netip"
 	"time"
 
 	"github.com/plgd-dev/go-coap/v3/message"
@@ -27,6 +28,9 @@ func Dial(target string, opts ...Option) (*client.Conn, error) {
 	for _, o := range opts {
 		o.UDPClientApply(&cfg)
 	}
+	if cfg.PacketDialer != nil {
+		return dialPacket(cfg, target, opts...)
+	}
 	c, err := cfg.Dialer.DialContext(cfg.Ctx, cfg.Net, target)
 	if err != nil {
 		return nil, err
@@ -39,12 +43,90 @@ func Dial(target string, opts ...Option) (*client.Conn, error) {
 	return Client(conn, opts...), nil
 }
 
+func dialPacket(cfg client.Config, target string, opts ...Option) (*client.Conn, error) {
+	c, err := cfg.PacketDialer.DialPacket(cfg.Ctx, cfg.Net, target)
+	if err != nil {
+		return nil, err
+	}
+	raddr, err := packetConnRemoteAddr(cfg, c, target)
+	if err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+	opts = append(opts, options.WithCloseSocket())
+	return ClientOverPacketConn(c, raddr, opts...), nil
+}
+
+// packetConnRemoteAddr returns the peer address reported by the transport, otherwise it resolves the target.
+func packetConnRemoteAddr(cfg client.Config, c net.PacketConn, target string) (*net.UDPAddr, error) {
+	if rc, ok := c.(interface{ RemoteAddr() net.Addr }); ok {
+		if raddr, ok := rc.RemoteAddr().(*net.UDPAddr); ok && raddr != nil {
+			return raddr, nil
+		}
+	}
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return nil, err
+	}
+	resolver := net.DefaultResolver
+	if cfg.Dialer != nil && cfg.Dialer.Resolver != nil {
+		resolver = cfg.Dialer.Resolver
+	}
+	ipNetwork := "ip"
+	switch cfg.Net {
+	case "udp4":
+		ipNetwork = "ip4"
+	case "udp6":
+		ipNetwork = "ip6"
+	}
+	ips, err := resolver.LookupNetIP(cfg.Ctx, ipNetwork, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("cannot resolve %v: no address", host)
+	}
+	portNum, err := resolver.LookupPort(cfg.Ctx, cfg.Net, port)
+	if err != nil {
+		return nil, err
+	}
+	// prefer IPv4 as net.ResolveUDPAddr
+	ip := ips[0]
+	for _, v := range ips {
+		if v.Is4() || v.Is4In6() {
+			ip = v
+			break
+		}
+	}
+	return net.UDPAddrFromAddrPort(netip.AddrPortFrom(ip.Unmap(), uint16(portNum))), nil
+}
+
 // Client creates client over udp connection.
 func Client(conn *net.UDPConn, opts ...Option) *client.Conn {
 	cfg := client.DefaultConfig
 	for _, o := range opts {
 		o.UDPClientApply(&cfg)
 	}
+	addr, _ := conn.RemoteAddr().(*net.UDPAddr)
+	return newClient(cfg, addr, func(errors func(error)) *coapNet.UDPConn {
+		return coapNet.NewUDPConn(cfg.Net, conn, coapNet.WithErrors(errors))
+	})
+}
+
+// ClientOverPacketConn creates client over the custom datagram transport, e.g. a userspace VPN or tunnel.
+// The messages are sent to raddr and the transport must report the source addresses as *net.UDPAddr.
+// The transport is closed with the connection only when options.WithCloseSocket is set.
+func ClientOverPacketConn(conn net.PacketConn, raddr *net.UDPAddr, opts ...Option) *client.Conn {
+	cfg := client.DefaultConfig
+	for _, o := range opts {
+		o.UDPClientApply(&cfg)
+	}
+	return newClient(cfg, raddr, func(errors func(error)) *coapNet.UDPConn {
+		return coapNet.NewUDPConnOverPacketConn(cfg.Net, conn, coapNet.WithErrors(errors))
+	})
+}
+
+func newClient(cfg client.Config, addr *net.UDPAddr, newConn func(errors func(error)) *coapNet.UDPConn) *client.Conn {
 	if cfg.Errors == nil {
 		cfg.Errors = func(error) {
 			// default no-op
@@ -65,9 +147,8 @@ func Client(conn *net.UDPConn, opts ...Option) *client.Conn {
 			// this error was produced by cancellation context or closing connection.
 			return
 		}
-		errorsFunc(fmt.Errorf("udp: %v: %w", conn.RemoteAddr(), err))
+		errorsFunc(fmt.Errorf("udp: %v: %w", addr, err))
 	}
-	addr, _ := conn.RemoteAddr().(*net.UDPAddr)
 	createBlockWise := func(*client.Conn) *blockwise.BlockWise[*client.Conn] {
 		return nil
 	}
@@ -81,12 +162,17 @@ func Client(conn *net.UDPConn, opts ...Option) *client.Conn {
 				func(token message.Token) (*pool.Message, bool) {
 					return v.GetObservationRequest(token)
 				},
+				blockwise.WithBufferAllocator(cfg.BlockwiseBufferAllocator),
+				blockwise.WithMaxRequestBodySize(cfg.BlockwiseMaxRequestBodySize),
+				blockwise.WithMaxConcurrentTransfers(cfg.BlockwiseMaxConcurrentTransfers),
+				blockwise.WithPerBlockTimeout(cfg.BlockwisePerBlockTimeout),
+				blockwise.WithMetrics(cfg.Metrics),
 			)
 		}
 	}
 
 	monitor := cfg.CreateInactivityMonitor()
-	l := coapNet.NewUDPConn(cfg.Net, conn, coapNet.WithErrors(cfg.Errors))
+	l := newConn(cfg.Errors)
 	session := server.NewSession(cfg.Ctx,
 		context.Background(),
 		l,
@@ -95,6 +181,10 @@ func Client(conn *net.UDPConn, opts ...Option) *client.Conn {
 		cfg.MTU,
 		cfg.CloseSocket,
 	)
+	session.SetWireTap(cfg.WireTap)
+	session.SetMetrics(cfg.Metrics)
+	session.SetTokenLength(cfg.TokenLength)
+	session.SetSendQueue(cfg.NewSendQueue())
 	cc := client.NewConnWithOpts(session, &cfg,
 		client.WithBlockWise(createBlockWise),
 		client.WithInactivityMonitor(monitor),
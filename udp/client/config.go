@@ -1,6 +1,7 @@
 package client
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"time"
@@ -29,7 +30,9 @@ var DefaultConfig = func() Config {
 		TransmissionNStart:             1,
 		TransmissionAcknowledgeTimeout: time.Second * 2,
 		TransmissionMaxRetransmit:      4,
+		TransmissionAckRandomFactor:    1.5,
 		GetMID:                         message.GetMID,
+		DeduplicateNonConfirmable:      true,
 		MTU:                            DefaultMTU,
 	}
 	opts.Handler = func(w *responsewriter.ResponseWriter[*Conn], r *pool.Message) {
@@ -43,6 +46,14 @@ var DefaultConfig = func() Config {
 	return opts
 }()
 
+// PacketDialer creates the datagram transport used by udp.Dial instead of the UDP socket, e.g. a userspace VPN or tunnel.
+type PacketDialer interface {
+	// DialPacket returns the transport for the communication with the address. The transport is closed with the connection.
+	// When the transport implements RemoteAddr() net.Addr returning *net.UDPAddr, the messages are sent to it and the address
+	// is not resolved by udp.Dial, so the dialer can resolve it in its own network, e.g. without leaking the DNS lookup.
+	DialPacket(ctx context.Context, network, address string) (net.PacketConn, error)
+}
+
 type Config struct {
 	config.Common[*Conn]
 	CreateInactivityMonitor        CreateInactivityMonitorFunc
@@ -51,9 +62,26 @@ type Config struct {
 	GetMID                         GetMIDFunc
 	Handler                        HandlerFunc
 	Dialer                         *net.Dialer
+	PacketDialer                   PacketDialer
 	TransmissionNStart             uint32
 	TransmissionAcknowledgeTimeout time.Duration
 	TransmissionMaxRetransmit      uint32
-	CloseSocket                    bool
-	MTU                            uint16
+	TransmissionAckRandomFactor    float64
+	TransmissionExchangeLifetime   time.Duration
+	// ConfirmableResponseToNonConfirmable sends the responses to the Non-confirmable requests as Confirmable messages.
+	ConfirmableResponseToNonConfirmable bool
+	// DeduplicateNonConfirmable detects also the duplicates of the Non-confirmable requests by the message ID.
+	DeduplicateNonConfirmable bool
+	// SeparateResponseThreshold acknowledges the Confirmable request by the empty ACK when its handler doesn't return
+	// within the threshold and the response is sent separately, zero piggybacks the response in the ACK.
+	SeparateResponseThreshold time.Duration
+	// UnexpectedMessageHandler is called for the received ACK and RST which don't match any sent Confirmable message,
+	// e.g. the late duplicate of the ACK after the request timed out. Nil drops them silently.
+	UnexpectedMessageHandler UnexpectedMessageFunc
+	CloseSocket              bool
+	MTU                      uint16
+	HandshakeTimeout         time.Duration
+	DefaultContentFormat     *message.MediaType
+	// ClientOptions are added to all requests sent by the client which don't contain the option.
+	ClientOptions message.Options
 }
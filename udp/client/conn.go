@@ -17,6 +17,7 @@ import (
 	"github.com/plgd-dev/go-coap/v3/net/blockwise"
 	"github.com/plgd-dev/go-coap/v3/net/client"
 	limitparallelrequests "github.com/plgd-dev/go-coap/v3/net/client/limitParallelRequests"
+	"github.com/plgd-dev/go-coap/v3/net/metrics"
 	"github.com/plgd-dev/go-coap/v3/net/monitor/inactivity"
 	"github.com/plgd-dev/go-coap/v3/net/observation"
 	"github.com/plgd-dev/go-coap/v3/net/responsewriter"
@@ -24,16 +25,24 @@ import (
 	"github.com/plgd-dev/go-coap/v3/pkg/cache"
 	coapErrors "github.com/plgd-dev/go-coap/v3/pkg/errors"
 	"github.com/plgd-dev/go-coap/v3/pkg/fn"
+	"github.com/plgd-dev/go-coap/v3/pkg/goroutine"
 	pkgMath "github.com/plgd-dev/go-coap/v3/pkg/math"
+	pkgRand "github.com/plgd-dev/go-coap/v3/pkg/rand"
 	coapSync "github.com/plgd-dev/go-coap/v3/pkg/sync"
 	"github.com/plgd-dev/go-coap/v3/udp/coder"
 	"go.uber.org/atomic"
 	"golang.org/x/sync/semaphore"
 )
 
+// ExchangeLifetime is EXCHANGE_LIFETIME for the default transmission parameters, see Transmission.ExchangeLifetime.
 // https://datatracker.ietf.org/doc/html/rfc7252#section-4.8.2
 const ExchangeLifetime = 247 * time.Second
 
+// MaxLatency is MAX_LATENCY, the maximum time a datagram is expected to take from the start of its transmission
+// to the completion of its reception.
+// https://datatracker.ietf.org/doc/html/rfc7252#section-4.8.2
+const MaxLatency = 100 * time.Second
+
 type (
 	HandlerFunc                 = func(*responsewriter.ResponseWriter[*Conn], *pool.Message)
 	ErrorFunc                   = func(error)
@@ -41,6 +50,7 @@ type (
 	GetMIDFunc                  = func() int32
 	CreateInactivityMonitorFunc = func() InactivityMonitor
 	RequestMonitorFunc          = func(cc *Conn, req *pool.Message) (drop bool, err error)
+	UnexpectedMessageFunc       = func(cc *Conn, msg *pool.Message)
 )
 
 type InactivityMonitor interface {
@@ -74,11 +84,16 @@ const (
 	errFmtWriteResponse = "cannot write response: %w"
 )
 
+var jitterRng = pkgRand.NewRand(time.Now().UnixNano())
+
 type midElement struct {
 	handler    HandlerFunc
 	start      time.Time
 	deadline   time.Time
 	retransmit atomic.Uint32
+	// transmission parameters valid when the message was sent
+	acknowledgeTimeout time.Duration
+	maxRetransmit      uint32
 
 	private struct {
 		sync.Mutex
@@ -95,17 +110,17 @@ func (m *midElement) ReleaseMessage(cc *Conn) {
 	}
 }
 
-func (m *midElement) IsExpired(now time.Time, maxRetransmit uint32) bool {
+func (m *midElement) IsExpired(now time.Time) bool {
 	if !m.deadline.IsZero() && now.After(m.deadline) {
 		// remove element if deadline is exceeded
 		return true
 	}
 	retransmit := m.retransmit.Load()
-	return retransmit >= maxRetransmit
+	return retransmit >= m.maxRetransmit
 }
 
-func (m *midElement) Retransmit(now time.Time, acknowledgeTimeout time.Duration) bool {
-	if now.After(m.start.Add(acknowledgeTimeout * time.Duration(m.retransmit.Load()+1))) {
+func (m *midElement) Retransmit(now time.Time) bool {
+	if now.After(m.start.Add(m.acknowledgeTimeout * time.Duration(m.retransmit.Load()+1))) {
 		m.retransmit.Inc()
 		// retransmit
 		return true
@@ -137,16 +152,21 @@ type MessageCache interface {
 
 // messageCache is a CoAP message cache backed by an in-memory cache.
 type messageCache struct {
-	c *cache.Cache[string, []byte]
+	c                *cache.Cache[string, []byte]
+	exchangeLifetime func() time.Duration
 }
 
-// newMessageCache constructs a new CoAP message cache.
-func newMessageCache() *messageCache {
+// newMessageCache constructs a new CoAP message cache which stores the messages for the exchange lifetime.
+func newMessageCache(exchangeLifetime func() time.Duration) *messageCache {
 	return &messageCache{
-		c: cache.NewCache[string, []byte](),
+		c:                cache.NewCache[string, []byte](),
+		exchangeLifetime: exchangeLifetime,
 	}
 }
 
+// cacheCoder encodes the cached responses, whose tokens were validated by the connection.
+var cacheCoder = coder.DefaultCoder.WithMaxTokenSize(message.MaxEncodableTokenSize)
+
 // Load loads a message from the cache if one exists with key.
 func (m *messageCache) Load(key string, msg *pool.Message) (bool, error) {
 	cachedResp := m.c.Load(key)
@@ -154,7 +174,7 @@ func (m *messageCache) Load(key string, msg *pool.Message) (bool, error) {
 		return false, nil
 	}
 	if rawMsg := cachedResp.Data(); len(rawMsg) > 0 {
-		_, err := msg.UnmarshalWithDecoder(coder.DefaultCoder, rawMsg)
+		_, err := msg.UnmarshalWithDecoder(cacheCoder, rawMsg)
 		if err != nil {
 			return false, err
 		}
@@ -165,13 +185,13 @@ func (m *messageCache) Load(key string, msg *pool.Message) (bool, error) {
 
 // Store stores a message in the cache.
 func (m *messageCache) Store(key string, msg *pool.Message) error {
-	marshaledResp, err := msg.MarshalWithEncoder(coder.DefaultCoder)
+	marshaledResp, err := msg.MarshalWithEncoder(cacheCoder)
 	if err != nil {
 		return err
 	}
 	cacheMsg := make([]byte, len(marshaledResp))
 	copy(cacheMsg, marshaledResp)
-	m.c.LoadOrStore(key, cache.NewElement(cacheMsg, time.Now().Add(ExchangeLifetime), nil))
+	m.c.LoadOrStore(key, cache.NewElement(cacheMsg, time.Now().Add(m.exchangeLifetime()), nil))
 	return nil
 }
 
@@ -193,11 +213,14 @@ type Conn struct {
 
 	blockWise          *blockwise.BlockWise[*Conn]
 	observationHandler *observation.Handler[*Conn]
+	peerObservations   *observation.Registrations
 	transmission       *Transmission
 	messagePool        *pool.Pool
 
 	processReceivedMessage config.ProcessReceivedMessageFunc[*Conn]
 	errors                 ErrorFunc
+	onParseError           config.ParseErrorFunc
+	decoder                *coder.Coder
 	responseMsgCache       MessageCache
 	msgIDMutex             *MutexMap
 
@@ -215,6 +238,26 @@ type Conn struct {
 	*/
 	numOutstandingInteraction *semaphore.Weighted
 	receivedMessageReader     *client.ReceivedMessageReader[*Conn]
+	defaultContentFormat      *message.MediaType
+	clientOptions             message.Options
+	metrics                   metrics.Collector
+	// nonConfirmableResponseType is the default type of the response to the Non-confirmable request
+	nonConfirmableResponseType message.Type
+	// deduplicateNonConfirmable enables the response cache also for the Non-confirmable requests
+	deduplicateNonConfirmable bool
+	// separateResponseThreshold is the latency of the handler after which the request is acknowledged by the empty ACK
+	separateResponseThreshold time.Duration
+	// onTransportError is called with the errors of reading from and writing to the connection
+	onTransportError config.TransportErrorFunc[*Conn]
+	// tokenLength is the range of the lengths of the tokens of the received messages
+	tokenLength message.TokenLength
+	// unexpectedMessageHandler is called for the ACK and the RST which don't match any sent Confirmable message
+	unexpectedMessageHandler UnexpectedMessageFunc
+	// goroutines counts the goroutines of the connection set by options.WithMaxGoroutines
+	goroutines *goroutine.Limiter
+
+	// closeReason is set by Close, the other reasons are derived from the cause of the canceled context
+	closeReason atomic.Uint32
 }
 
 // Transmission is a threadsafe container for transmission related parameters
@@ -222,6 +265,8 @@ type Transmission struct {
 	nStart             *atomic.Uint32
 	acknowledgeTimeout *atomic.Duration
 	maxRetransmit      *atomic.Uint32
+	ackRandomFactor    *atomic.Float64
+	exchangeLifetime   *atomic.Duration // 0 means derived from the other parameters
 }
 
 // SetTransmissionNStart changing the nStart value will only effect requests queued after the change. The requests waiting here already before the change will get unblocked when enough weight has been released.
@@ -229,18 +274,93 @@ func (t *Transmission) SetTransmissionNStart(d uint32) {
 	t.nStart.Store(d)
 }
 
+// SetTransmissionAcknowledgeTimeout changing the acknowledge timeout will only effect confirmable messages sent after the change.
 func (t *Transmission) SetTransmissionAcknowledgeTimeout(d time.Duration) {
 	t.acknowledgeTimeout.Store(d)
 }
 
+// SetTransmissionMaxRetransmit changing the max retransmit will only effect confirmable messages sent after the change.
 func (t *Transmission) SetTransmissionMaxRetransmit(d uint32) {
 	t.maxRetransmit.Store(d)
 }
 
+// SetTransmissionAckRandomFactor changing the ACK_RANDOM_FACTOR will only effect confirmable messages sent after the change.
+// Values less or equal to 1 disable the randomization of the acknowledge timeout.
+func (t *Transmission) SetTransmissionAckRandomFactor(f float64) {
+	t.ackRandomFactor.Store(f)
+}
+
+// SetExchangeLifetime overrides EXCHANGE_LIFETIME derived from the transmission parameters, 0 restores the derived value.
+// It will only effect the responses cached after the change.
+func (t *Transmission) SetExchangeLifetime(d time.Duration) {
+	t.exchangeLifetime.Store(d)
+}
+
+// NStart returns the current number of simultaneous outstanding interactions.
+func (t *Transmission) NStart() uint32 {
+	return t.nStart.Load()
+}
+
+// AcknowledgeTimeout returns the current acknowledge timeout.
+func (t *Transmission) AcknowledgeTimeout() time.Duration {
+	return t.acknowledgeTimeout.Load()
+}
+
+// MaxRetransmit returns the current max retransmit.
+func (t *Transmission) MaxRetransmit() uint32 {
+	return t.maxRetransmit.Load()
+}
+
+// AckRandomFactor returns the current ACK_RANDOM_FACTOR.
+func (t *Transmission) AckRandomFactor() float64 {
+	return t.ackRandomFactor.Load()
+}
+
+// ExchangeLifetime returns the current EXCHANGE_LIFETIME: the time for which the response is cached to be resent
+// to the duplicate of the Confirmable request. Unless it is overridden by SetExchangeLifetime, it is derived
+// from the transmission parameters as MAX_TRANSMIT_SPAN + 2 * MAX_LATENCY + PROCESSING_DELAY, which is 247 seconds
+// for the default parameters.
+// https://datatracker.ietf.org/doc/html/rfc7252#section-4.8.2
+func (t *Transmission) ExchangeLifetime() time.Duration {
+	if d := t.exchangeLifetime.Load(); d > 0 {
+		return d
+	}
+	ackTimeout := t.AcknowledgeTimeout()
+	factor := t.AckRandomFactor()
+	if factor < 1 {
+		factor = 1
+	}
+	// MAX_TRANSMIT_SPAN = ACK_TIMEOUT * ((2 ** MAX_RETRANSMIT) - 1) * ACK_RANDOM_FACTOR
+	maxTransmitSpan := float64(ackTimeout) * (math.Pow(2, float64(t.MaxRetransmit())) - 1) * factor
+	lifetime := maxTransmitSpan + float64(2*MaxLatency+ackTimeout)
+	if lifetime >= math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(lifetime)
+}
+
+// initialTimeout returns the acknowledge timeout multiplied by a random factor between 1 and ACK_RANDOM_FACTOR (RFC 7252 section 4.2).
+func (t *Transmission) initialTimeout() time.Duration {
+	timeout := t.AcknowledgeTimeout()
+	factor := t.AckRandomFactor()
+	if factor <= 1 {
+		return timeout
+	}
+	return time.Duration(float64(timeout) * (1 + jitterRng.Float64()*(factor-1)))
+}
+
 func (cc *Conn) Transmission() *Transmission {
 	return cc.transmission
 }
 
+// SetTransmissionParameters changes the (re)transmission parameters of the live connection, e.g. according to the measured RTT.
+// Requests sent before the change keep the parameters valid at the time they were sent.
+func (cc *Conn) SetTransmissionParameters(nStart uint32, acknowledgeTimeout time.Duration, maxRetransmit uint32) {
+	cc.transmission.SetTransmissionNStart(nStart)
+	cc.transmission.SetTransmissionAcknowledgeTimeout(acknowledgeTimeout)
+	cc.transmission.SetTransmissionMaxRetransmit(maxRetransmit)
+}
+
 type ConnOptions struct {
 	createBlockWise   func(cc *Conn) *blockwise.BlockWise[*Conn]
 	inactivityMonitor InactivityMonitor
@@ -275,15 +395,16 @@ func WithRequestMonitor(requestMonitor RequestMonitorFunc) Option {
 }
 
 // WithResponseMessageCache sets the cache used for response messages. All
-// response messages are submitted to the cache, but it is up to the cache
-// implementation to determine which messages are stored and for how long.
+// responses to the Confirmable requests (and to the Non-confirmable requests
+// when Config.DeduplicateNonConfirmable is set) are submitted to the cache, but it
+// is up to the cache implementation to determine which messages are stored and for how long.
 // Caching responses enables sending the same Acknowledgment for retransmitted
 // confirmable messages within an EXCHANGE_LIFETIME. It may be desirable to
 // relax this behavior in some scenarios.
 // https://datatracker.ietf.org/doc/html/rfc7252#section-4.5
 // The default response message cache stores all responses with an expiration of
-// 247 seconds, which is EXCHANGE_LIFETIME when using default CoAP transmission
-// parameters.
+// EXCHANGE_LIFETIME (see Transmission.ExchangeLifetime), which is 247 seconds when
+// using default CoAP transmission parameters.
 // https://datatracker.ietf.org/doc/html/rfc7252#section-4.8.2
 func WithResponseMessageCache(cache MessageCache) Option {
 	return func(opts *ConnOptions) {
@@ -319,42 +440,109 @@ func NewConnWithOpts(session Session, cfg *Config, opts ...Option) *Conn {
 	for _, o := range opts {
 		o(&cfgOpts)
 	}
+	transmission := &Transmission{
+		atomic.NewUint32(cfg.TransmissionNStart),
+		atomic.NewDuration(cfg.TransmissionAcknowledgeTimeout),
+		atomic.NewUint32(cfg.TransmissionMaxRetransmit),
+		atomic.NewFloat64(cfg.TransmissionAckRandomFactor),
+		atomic.NewDuration(cfg.TransmissionExchangeLifetime),
+	}
 	// Only construct cache if one was not set via options.
 	if cfgOpts.responseMsgCache == nil {
-		cfgOpts.responseMsgCache = newMessageCache()
+		cfgOpts.responseMsgCache = newMessageCache(transmission.ExchangeLifetime)
 	}
 	cc := Conn{
-		session: session,
-		transmission: &Transmission{
-			atomic.NewUint32(cfg.TransmissionNStart),
-			atomic.NewDuration(cfg.TransmissionAcknowledgeTimeout),
-			atomic.NewUint32(cfg.TransmissionMaxRetransmit),
-		},
-		blockwiseSZX: cfg.BlockwiseSZX,
+		session:              session,
+		transmission:         transmission,
+		blockwiseSZX:         cfg.BlockwiseSZX,
+		defaultContentFormat: cfg.DefaultContentFormat,
+		clientOptions:        cfg.ClientOptions,
+
+		nonConfirmableResponseType: message.NonConfirmable,
+		deduplicateNonConfirmable:  cfg.DeduplicateNonConfirmable,
+		separateResponseThreshold:  cfg.SeparateResponseThreshold,
+		unexpectedMessageHandler:   cfg.UnexpectedMessageHandler,
+		goroutines:                 cfg.Goroutines,
+		tokenLength:                cfg.TokenLength,
+		onTransportError:           cfg.OnTransportError,
 
 		tokenHandlerContainer:     coapSync.NewMap[uint64, HandlerFunc](),
 		midHandlerContainer:       coapSync.NewMap[int32, *midElement](),
 		processReceivedMessage:    cfg.ProcessReceivedMessage,
 		errors:                    cfg.Errors,
+		onParseError:              cfg.OnParseError,
+		decoder:                   coder.DefaultCoder,
 		msgIDMutex:                NewMutexMap(),
 		responseMsgCache:          cfgOpts.responseMsgCache,
 		inactivityMonitor:         cfgOpts.inactivityMonitor,
 		requestMonitor:            cfgOpts.requestMonitor,
 		messagePool:               cfg.MessagePool,
 		numOutstandingInteraction: semaphore.NewWeighted(math.MaxInt64),
+		metrics:                   metrics.OrNil(cfg.Metrics),
 	}
 	cc.msgID.Store(pkgMath.CastTo[uint32](cfg.GetMID() - 0xffff/2))
+	if cfg.StrictParsing {
+		cc.decoder = coder.StrictCoder
+	}
+	if cfg.TokenLength.Max > message.MaxTokenSize {
+		cc.decoder = cc.decoder.WithMaxTokenSize(cfg.TokenLength.Max)
+	}
+	if cfg.ConfirmableResponseToNonConfirmable {
+		cc.nonConfirmableResponseType = message.Confirmable
+	}
 	cc.blockWise = cfgOpts.createBlockWise(&cc)
 	limitParallelRequests := limitparallelrequests.New(cfg.LimitClientParallelRequests, cfg.LimitClientEndpointParallelRequests, cc.do, cc.doObserve)
-	cc.observationHandler = observation.NewHandler(&cc, cfg.Handler, limitParallelRequests.Do)
+	handler, peerObservations := limitObservations(cfg, serializeHandler(&cc, cfg))
+	cc.observationHandler = observation.NewHandler(&cc, handler, limitParallelRequests.Do)
 	cc.Client = client.New(&cc, cc.observationHandler, cfg.GetToken, limitParallelRequests)
 	if cc.processReceivedMessage == nil {
 		cc.processReceivedMessage = processReceivedMessage
 	}
-	cc.receivedMessageReader = client.NewReceivedMessageReader(&cc, cfg.ReceivedMessageQueueSize)
+	cc.receivedMessageReader = client.NewReceivedMessageReaderWithLimiter(&cc, cfg.ReceivedMessageQueueSize, cfg.Goroutines)
+	cc.peerObservations = peerObservations
+	cc.collectMetrics()
 	return &cc
 }
 
+// serializeHandler calls the handler of the requests one at a time in the receive order, when it is configured
+// by SerializedHandlers.
+func serializeHandler(cc *Conn, cfg *Config) HandlerFunc {
+	h := cfg.Handler
+	if !cfg.SerializedHandlers || h == nil {
+		return h
+	}
+	return func(w *responsewriter.ResponseWriter[*Conn], r *pool.Message) {
+		cc.receivedMessageReader.ProcessInOrder(func() {
+			h(w, r)
+		})
+	}
+}
+
+// limitObservations tracks the observations registered by the peer, when it is enabled by MaxObservations, Metrics
+// or TrackObservations. It rejects the registrations over MaxObservations and it reports the registered observations
+// to the metrics. The returned registrations are nil when the observations are not tracked, otherwise they must be
+// released when the connection is closed.
+func limitObservations(cfg *Config, h HandlerFunc) (HandlerFunc, *observation.Registrations) {
+	if (cfg.MaxObservations == 0 && cfg.Metrics == nil && !cfg.TrackObservations) || h == nil {
+		return h, nil
+	}
+	return observation.RegistrationsHandler(cfg.MaxObservations, cfg.Metrics, h, cfg.Errors)
+}
+
+// collectMetrics reports the connection and its observations and blockwise transfers to the metrics until the connection is closed.
+func (cc *Conn) collectMetrics() {
+	cc.metrics.AddConnections(1)
+	cc.AddOnClose(func() {
+		if cc.peerObservations != nil {
+			cc.peerObservations.Release()
+		}
+		if cc.blockWise != nil {
+			cc.blockWise.Release()
+		}
+		cc.metrics.AddConnections(-1)
+	})
+}
+
 // NewConn creates connection over session and observation.
 func NewConn(
 	session Session,
@@ -387,8 +575,32 @@ func (cc *Conn) GetMessageID() int32 {
 	return int32(pkgMath.CastTo[uint16](cc.msgID.Inc()))
 }
 
+type flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// Flush blocks until the outgoing messages queued by the send queue (see options.WithSendQueueSize), e.g. notifications
+// and ACKs, are written to the socket or the ctx is done. Calling it before Close ensures that the last messages,
+// e.g. the "going offline" notification, are not dropped by the close. The sessions without the send queue
+// write the messages synchronously, so there is nothing to wait for.
+func (cc *Conn) Flush(ctx context.Context) error {
+	if f, ok := cc.session.(flusher); ok {
+		return f.Flush(ctx)
+	}
+	return nil
+}
+
 // Close closes connection without waiting for the end of the Run function.
 func (cc *Conn) Close() error {
+	return cc.CloseWithReason(coapNet.CloseReasonClosed)
+}
+
+// CloseWithReason closes the connection as Close and reports the reason by CloseReason, e.g. the callback
+// of the inactivity monitor closes the connection with coapNet.CloseReasonIdleTimeout (see inactivity.CloseConn).
+func (cc *Conn) CloseWithReason(reason coapNet.CloseReason) error {
+	if cc.Context().Err() == nil {
+		cc.closeReason.CompareAndSwap(uint32(coapNet.CloseReasonNone), uint32(reason))
+	}
 	err := cc.session.Close()
 	if errors.Is(err, net.ErrClosed) {
 		return nil
@@ -424,19 +636,45 @@ func (cc *Conn) doInternal(req *pool.Message) (*pool.Message, error) {
 	case <-req.Context().Done():
 		return nil, req.Context().Err()
 	case <-cc.Context().Done():
-		return nil, fmt.Errorf("connection was closed: %w", cc.session.Context().Err())
+		return nil, fmt.Errorf("connection was closed: %w", context.Cause(cc.session.Context()))
 	case resp := <-respChan:
 		return resp, nil
 	}
 }
 
+// upsertDefaultContentFormat sets the default content format to requests with a body but without content format.
+func (cc *Conn) upsertDefaultContentFormat(req *pool.Message) {
+	if cc.defaultContentFormat == nil || req.Body() == nil || req.Code() < codes.GET || req.Code() > codes.DELETE {
+		return
+	}
+	req.UpsertContentFormat(*cc.defaultContentFormat)
+}
+
+// addClientOptions adds the client options to requests which don't contain them.
+func (cc *Conn) addClientOptions(req *pool.Message) {
+	if len(cc.clientOptions) == 0 || req.Code() < codes.GET || req.Code() > codes.DELETE {
+		return
+	}
+	for _, o := range cc.clientOptions {
+		if !req.HasOption(o.ID) {
+			req.AddOptionBytes(o.ID, o.Value)
+		}
+	}
+}
+
 // Do sends an coap message and returns an coap response.
 //
 // An error is returned if by failure to speak COAP (such as a network connectivity problem).
-// Any status code doesn't cause an error.
+// Any status code doesn't cause an error. The request with the Content-Format option,
+// but without the body is rejected by message.ErrContentFormatWithoutPayload.
 //
 // Caller is responsible to release request and response.
 func (cc *Conn) do(req *pool.Message) (*pool.Message, error) {
+	cc.upsertDefaultContentFormat(req)
+	cc.addClientOptions(req)
+	if err := req.ValidateContentFormat(); err != nil {
+		return nil, err
+	}
 	if cc.blockWise == nil {
 		return cc.doInternal(req)
 	}
@@ -462,11 +700,11 @@ func (cc *Conn) releaseOutstandingInteraction() {
 }
 
 func (cc *Conn) acquireOutstandingInteraction(ctx context.Context) error {
-	nStart := cc.Transmission().nStart.Load()
+	nStart := cc.Transmission().NStart()
 	if nStart == 0 {
 		return fmt.Errorf("invalid NStart value %v", nStart)
 	}
-	n := math.MaxInt64 - int64(cc.Transmission().nStart.Load()) + 1
+	n := math.MaxInt64 - int64(cc.Transmission().NStart()) + 1
 	err := cc.numOutstandingInteraction.Acquire(ctx, n)
 	if err != nil {
 		return err
@@ -483,7 +721,7 @@ func (cc *Conn) waitForAcknowledge(req *pool.Message, waitForResponseChan chan s
 	case <-req.Context().Done():
 		return req.Context().Err()
 	case <-cc.Context().Done():
-		return fmt.Errorf("connection was closed: %w", cc.Context().Err())
+		return fmt.Errorf("connection was closed: %w", context.Cause(cc.Context()))
 	}
 }
 
@@ -508,9 +746,11 @@ func (cc *Conn) prepareWriteMessage(req *pool.Message, handler HandlerFunc) (fun
 		}
 		deadline, _ := req.Context().Deadline()
 		if _, loaded := cc.midHandlerContainer.LoadOrStore(req.MessageID(), &midElement{
-			handler:  handler,
-			start:    time.Now(),
-			deadline: deadline,
+			handler:            handler,
+			start:              time.Now(),
+			deadline:           deadline,
+			acknowledgeTimeout: cc.transmission.initialTimeout(),
+			maxRetransmit:      cc.transmission.MaxRetransmit(),
 			private: struct {
 				sync.Mutex
 				msg *pool.Message
@@ -534,6 +774,11 @@ func (cc *Conn) prepareWriteMessage(req *pool.Message, handler HandlerFunc) (fun
 func (cc *Conn) writeMessageAsync(req *pool.Message) error {
 	req.UpsertType(message.Confirmable)
 	req.UpsertMessageID(cc.GetMessageID())
+	if req.Type() == message.Confirmable {
+		// the message ID handler is kept until the acknowledgement or the timeout, so the acknowledgement
+		// isn't reported as unexpected
+		return cc.writeConfirmableResponse(req)
+	}
 	closeFn, err := cc.prepareWriteMessage(req, func(*responsewriter.ResponseWriter[*Conn], *pool.Message) {
 		// do nothing
 	})
@@ -541,6 +786,7 @@ func (cc *Conn) writeMessageAsync(req *pool.Message) error {
 		return err
 	}
 	defer closeFn()
+	cc.notified(req)
 	if err := cc.session.WriteMessage(req); err != nil {
 		return fmt.Errorf(errFmtWriteRequest, err)
 	}
@@ -561,17 +807,40 @@ func (cc *Conn) writeMessage(req *pool.Message) error {
 		return err
 	}
 	defer closeFn()
+	cc.notified(req)
 	if err := cc.session.WriteMessage(req); err != nil {
+		cc.notificationFailed(req.MessageID())
 		return fmt.Errorf(errFmtWriteRequest, err)
 	}
 	if err := cc.waitForAcknowledge(req, respChan); err != nil {
+		cc.notificationFailed(req.MessageID())
 		return fmt.Errorf(errFmtWriteRequest, err)
 	}
 	return nil
 }
 
+// notified records the message ID of the notification of the observation registered by the peer, so the observation
+// is released when the peer rejects the notification by the Reset message or the notification is not acknowledged.
+func (cc *Conn) notified(req *pool.Message) {
+	if cc.peerObservations == nil || !req.HasOption(message.Observe) {
+		return
+	}
+	if typ := req.Type(); typ == message.Confirmable || typ == message.NonConfirmable {
+		cc.peerObservations.Notified(req.Token(), req.MessageID())
+	}
+}
+
+// notificationFailed releases the observation registered by the peer whose last notification has the message ID.
+func (cc *Conn) notificationFailed(mid int32) {
+	if cc.peerObservations != nil {
+		cc.peerObservations.RemoveByMessageID(mid)
+	}
+}
+
 // WriteMessage sends an coap message.
 func (cc *Conn) WriteMessage(req *pool.Message) error {
+	cc.upsertDefaultContentFormat(req)
+	cc.addClientOptions(req)
 	if cc.blockWise == nil {
 		return cc.writeMessage(req)
 	}
@@ -583,6 +852,77 @@ func (cc *Conn) WriteMessage(req *pool.Message) error {
 	})
 }
 
+type batchWriter interface {
+	WriteMessages(reqs []*pool.Message) error
+}
+
+// WriteMessages sends messages, e.g. notifications for observers. The non-confirmable messages are sent
+// without waiting for acknowledgements and when the session supports it (UDP), they are sent by a single
+// batch write to reduce number of syscalls, otherwise they are written one by one. The confirmable messages
+// are sent one by one as by WriteMessage, so they are retransmitted until they are acknowledged.
+func (cc *Conn) WriteMessages(reqs []*pool.Message) error {
+	msgs := make([]*pool.Message, 0, len(reqs))
+	for _, req := range reqs {
+		cc.upsertDefaultContentFormat(req)
+		cc.addClientOptions(req)
+		if cc.blockWise == nil {
+			msgs = append(msgs, req)
+			continue
+		}
+		err := cc.blockWise.WriteMessage(req, cc.blockwiseSZX, cc.session.MaxMessageSize(), func(bwReq *pool.Message) error {
+			if bwReq.Options().HasOption(message.Block1) || bwReq.Options().HasOption(message.Block2) {
+				bwReq.SetMessageID(cc.GetMessageID())
+			}
+			msgs = append(msgs, bwReq)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	nonConfirmable := make([]*pool.Message, 0, len(msgs))
+	confirmable := make([]*pool.Message, 0, len(msgs))
+	for _, req := range msgs {
+		req.UpsertType(message.Confirmable)
+		req.UpsertMessageID(cc.GetMessageID())
+		if req.Type() == message.Confirmable {
+			confirmable = append(confirmable, req)
+			continue
+		}
+		nonConfirmable = append(nonConfirmable, req)
+	}
+	if err := cc.writeNonConfirmableMessages(nonConfirmable); err != nil {
+		return err
+	}
+	for _, req := range confirmable {
+		if err := cc.writeMessage(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cc *Conn) writeNonConfirmableMessages(reqs []*pool.Message) error {
+	if len(reqs) == 0 {
+		return nil
+	}
+	for _, req := range reqs {
+		cc.notified(req)
+	}
+	if bw, ok := cc.session.(batchWriter); ok {
+		if err := bw.WriteMessages(reqs); err != nil {
+			return fmt.Errorf(errFmtWriteRequest, err)
+		}
+		return nil
+	}
+	for _, req := range reqs {
+		if err := cc.session.WriteMessage(req); err != nil {
+			return fmt.Errorf(errFmtWriteRequest, err)
+		}
+	}
+	return nil
+}
+
 // Context returns the client's context.
 //
 // If connections was closed context is cancelled.
@@ -603,8 +943,10 @@ func (cc *Conn) AsyncPing(receivedPong func()) (func(), error) {
 				receivedPong()
 			}
 		},
-		start:    time.Now(),
-		deadline: time.Time{}, // no deadline
+		start:              time.Now(),
+		deadline:           time.Time{}, // no deadline
+		acknowledgeTimeout: cc.transmission.initialTimeout(),
+		maxRetransmit:      cc.transmission.MaxRetransmit(),
 		private: struct {
 			sync.Mutex
 			msg *pool.Message
@@ -626,7 +968,19 @@ func (cc *Conn) AsyncPing(receivedPong func()) (func(), error) {
 
 // Run reads and process requests from a connection, until the connection is closed.
 func (cc *Conn) Run() error {
-	return cc.session.Run(cc)
+	err := cc.session.Run(cc)
+	switch cc.CloseReason() {
+	case coapNet.CloseReasonPeerReset, coapNet.CloseReasonTransportError:
+		cc.reportTransportError(context.Cause(cc.Context()), true)
+	}
+	return err
+}
+
+// reportTransportError reports the error of reading from or writing to the connection, fatal when it closed the connection.
+func (cc *Conn) reportTransportError(err error, fatal bool) {
+	if cc.onTransportError != nil {
+		cc.onTransportError(cc, err, fatal)
+	}
 }
 
 // AddOnClose calls function on close connection event.
@@ -634,6 +988,20 @@ func (cc *Conn) AddOnClose(f EventFunc) {
 	cc.session.AddOnClose(f)
 }
 
+// CloseReason returns the reason why the connection was closed, it is valid in the functions registered by AddOnClose.
+// CloseReasonNone is returned for the open connection.
+func (cc *Conn) CloseReason() coapNet.CloseReason {
+	if reason := coapNet.CloseReason(cc.closeReason.Load()); reason != coapNet.CloseReasonNone {
+		return reason
+	}
+	return coapNet.CloseReasonFromCause(context.Cause(cc.Context()))
+}
+
+// BlockwiseSZX returns the maximal block size used by the blockwise transfers of the connection.
+func (cc *Conn) BlockwiseSZX() blockwise.SZX {
+	return cc.blockwiseSZX
+}
+
 func (cc *Conn) RemoteAddr() net.Addr {
 	return cc.session.RemoteAddr()
 }
@@ -689,9 +1057,14 @@ func (cc *Conn) getResponseFromCache(mid int32, resp *pool.Message) (bool, error
 	return cc.responseMsgCache.Load(strconv.Itoa(int(mid)), resp)
 }
 
-// addResponseToCache adds a message to the response message cache.
-func (cc *Conn) addResponseToCache(resp *pool.Message) error {
-	return cc.responseMsgCache.Store(strconv.Itoa(int(resp.MessageID())), resp)
+// addResponseToCache adds a response to the request with the message ID to the response message cache.
+func (cc *Conn) addResponseToCache(mid int32, resp *pool.Message) error {
+	return cc.responseMsgCache.Store(strconv.Itoa(int(mid)), resp)
+}
+
+// isDeduplicated reports whether the duplicates of the requests of the type are detected by the response cache.
+func (cc *Conn) isDeduplicated(reqType message.Type) bool {
+	return reqType == message.Confirmable || (reqType == message.NonConfirmable && cc.deduplicateNonConfirmable)
 }
 
 // checkMyMessageID compare client msgID against peer messageID and if it is near < 0xffff/4 then increase msgID.
@@ -712,20 +1085,32 @@ func (cc *Conn) checkMyMessageID(req *pool.Message) {
 }
 
 func (cc *Conn) checkResponseCache(req *pool.Message, w *responsewriter.ResponseWriter[*Conn]) (bool, error) {
-	if req.Type() == message.Confirmable || req.Type() == message.NonConfirmable {
-		if ok, err := cc.getResponseFromCache(req.MessageID(), w.Message()); ok {
-			w.Message().SetMessageID(req.MessageID())
-			w.Message().SetType(message.NonConfirmable)
-			if req.Type() == message.Confirmable {
-				// req could be changed from NonConfirmation to confirmation message.
-				w.Message().SetType(message.Acknowledgement)
-			}
-			return true, nil
-		} else if err != nil {
-			return false, fmt.Errorf("cannot unmarshal response from cache: %w", err)
-		}
+	if !cc.isDeduplicated(req.Type()) {
+		return false, nil
 	}
-	return false, nil
+	ok, err := cc.getResponseFromCache(req.MessageID(), w.Message())
+	if err != nil {
+		return false, fmt.Errorf("cannot unmarshal response from cache: %w", err)
+	}
+	if !ok {
+		return false, nil
+	}
+	if req.Type() == message.Confirmable {
+		// req could be changed from NonConfirmation to confirmation message.
+		w.Message().SetType(message.Acknowledgement)
+		w.Message().SetMessageID(req.MessageID())
+		return true, nil
+	}
+	if w.Message().Code() == codes.Empty {
+		// the duplicate of the Non-confirmable request without the response is dropped
+		w.Message().SetModified(false)
+		return true, nil
+	}
+	if typ := w.Message().Type(); typ != message.Confirmable && typ != message.NonConfirmable {
+		w.Message().SetType(message.NonConfirmable)
+	}
+	w.Message().SetMessageID(cc.GetMessageID())
+	return true, nil
 }
 
 func isPongOrResetResponse(w *responsewriter.ResponseWriter[*Conn]) bool {
@@ -755,25 +1140,47 @@ func (cc *Conn) processResponse(reqType message.Type, reqMessageID int32, w *res
 		w.Message().SetType(message.Acknowledgement)
 		w.Message().SetMessageID(reqMessageID)
 		w.Message().SetToken(nil)
-		err := cc.addResponseToCache(w.Message())
+		err := cc.addResponseToCache(reqMessageID, w.Message())
 		if err != nil {
 			return fmt.Errorf("cannot cache response: %w", err)
 		}
 		return nil
 	case !w.Message().IsModified():
 		// don't send response
+		if reqType == message.NonConfirmable && cc.deduplicateNonConfirmable {
+			// the empty message marks the request as received, so its duplicates are dropped
+			w.Message().SetCode(codes.Empty)
+			w.Message().SetType(message.NonConfirmable)
+			w.Message().SetMessageID(reqMessageID)
+			w.Message().SetToken(nil)
+			err := cc.addResponseToCache(reqMessageID, w.Message())
+			w.Message().SetModified(false)
+			if err != nil {
+				return fmt.Errorf("cannot cache response: %w", err)
+			}
+		}
 		return nil
 	}
 
-	// send piggybacked response
-	w.Message().SetType(message.Confirmable)
-	w.Message().SetMessageID(cc.GetMessageID())
-	if reqType == message.Confirmable {
+	// The response to the Confirmable request is piggybacked in the Acknowledgement and the response
+	// to the Non-confirmable request is Non-confirmable (RFC 7252 section 5.2), unless the handler has set
+	// the type or the default type was changed by ConfirmableResponseToNonConfirmable.
+	switch reqType {
+	case message.Confirmable:
 		w.Message().SetType(message.Acknowledgement)
 		w.Message().SetMessageID(reqMessageID)
+	case message.NonConfirmable:
+		if typ := w.Message().Type(); typ != message.Confirmable && typ != message.NonConfirmable {
+			w.Message().SetType(cc.nonConfirmableResponseType)
+		}
+		w.Message().SetMessageID(cc.GetMessageID())
+	default:
+		// the next request of the exchange, e.g. the request of the next block sent by the blockwise transfer
+		w.Message().SetType(message.Confirmable)
+		w.Message().SetMessageID(cc.GetMessageID())
 	}
-	if reqType == message.Confirmable || reqType == message.NonConfirmable {
-		err := cc.addResponseToCache(w.Message())
+	if cc.isDeduplicated(reqType) {
+		err := cc.addResponseToCache(reqMessageID, w.Message())
 		if err != nil {
 			return fmt.Errorf("cannot cache response: %w", err)
 		}
@@ -801,7 +1208,14 @@ func (cc *Conn) handleReq(w *responsewriter.ResponseWriter[*Conn], req *pool.Mes
 	w.Message().SetModified(false)
 	reqType := req.Type()
 	reqMessageID := req.MessageID()
-	cc.handle(w, req)
+	if reqType == message.Confirmable && cc.separateResponseThreshold > 0 {
+		if cc.handleWithSeparateResponseThreshold(w, req) {
+			cc.processSeparateResponse(w)
+			return
+		}
+	} else {
+		cc.handle(w, req)
+	}
 
 	err := cc.processResponse(reqType, reqMessageID, w)
 	if err != nil {
@@ -810,6 +1224,55 @@ func (cc *Conn) handleReq(w *responsewriter.ResponseWriter[*Conn], req *pool.Mes
 	}
 }
 
+// handleWithSeparateResponseThreshold handles the Confirmable request and it sends the empty ACK when the handler
+// doesn't return within the separate response threshold. It reports whether the request was acknowledged.
+func (cc *Conn) handleWithSeparateResponseThreshold(w *responsewriter.ResponseWriter[*Conn], req *pool.Message) bool {
+	reqMessageID := req.MessageID()
+	reqCM := req.ControlMessage()
+	var mutex sync.Mutex
+	var done, acknowledged bool
+	timer := time.AfterFunc(cc.separateResponseThreshold, func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if done {
+			return
+		}
+		acknowledged = true
+		ack := cc.AcquireMessage(cc.Context())
+		defer cc.ReleaseMessage(ack)
+		ack.SetCode(codes.Empty)
+		ack.SetType(message.Acknowledgement)
+		ack.SetMessageID(reqMessageID)
+		upsertInterfaceToMessage(ack, reqCM)
+		// the duplicates of the request are acknowledged again
+		if err := cc.addResponseToCache(reqMessageID, ack); err != nil {
+			cc.errors(fmt.Errorf("cannot cache acknowledgement: %w", err))
+		}
+		if err := cc.session.WriteMessage(ack); err != nil {
+			// the client retransmits the request, so the request is acknowledged again from the cache
+			cc.reportTransportError(err, false)
+			cc.errors(fmt.Errorf("cannot send acknowledgement: %w", err))
+		}
+	})
+	cc.handle(w, req)
+	timer.Stop()
+	mutex.Lock()
+	defer mutex.Unlock()
+	done = true
+	return acknowledged
+}
+
+// processSeparateResponse sets the response of the request acknowledged by the empty ACK as the separate message.
+func (cc *Conn) processSeparateResponse(w *responsewriter.ResponseWriter[*Conn]) {
+	if !w.Message().IsModified() {
+		return
+	}
+	if typ := w.Message().Type(); typ != message.Confirmable && typ != message.NonConfirmable {
+		w.Message().SetType(message.Confirmable)
+	}
+	w.Message().SetMessageID(cc.GetMessageID())
+}
+
 func (cc *Conn) closeConnection() {
 	if errC := cc.Close(); errC != nil {
 		cc.errors(fmt.Errorf("cannot close connection: %w", errC))
@@ -824,7 +1287,7 @@ func (cc *Conn) ProcessReceivedMessageWithHandler(req *pool.Message, handler con
 	}()
 	resp := cc.AcquireMessage(cc.Context())
 	resp.SetToken(req.Token())
-	ifIndex := req.ControlMessage().GetIfIndex()
+	reqCM := req.ControlMessage()
 	w := responsewriter.New(resp, cc, req.Options()...)
 	defer func() {
 		cc.ReleaseMessage(w.Message())
@@ -839,28 +1302,64 @@ func (cc *Conn) ProcessReceivedMessageWithHandler(req *pool.Message, handler con
 		// nothing to send
 		return
 	}
-	upsertInterfaceToMessage(w.Message(), ifIndex)
+	upsertInterfaceToMessage(w.Message(), reqCM)
 	errW := cc.writeMessageAsync(w.Message())
 	if errW != nil {
+		cc.reportTransportError(errW, true)
 		cc.closeConnection()
 		cc.errors(fmt.Errorf(errFmtWriteResponse, errW))
 	}
 }
 
+// writeConfirmableResponse sends the copy of the Confirmable response by writeMessage from a goroutine,
+// so the response is retransmitted until it is acknowledged without blocking the processing of the received messages.
+// The waiting for the acknowledgement is bounded by EXCHANGE_LIFETIME.
+func (cc *Conn) writeConfirmableResponse(resp *pool.Message) error {
+	msg := cc.AcquireMessage(cc.Context())
+	if err := resp.Clone(msg); err != nil {
+		cc.ReleaseMessage(msg)
+		return fmt.Errorf("cannot clone message: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(cc.Context(), cc.transmission.ExchangeLifetime())
+	msg.SetContext(ctx)
+	// the response is already created, so the goroutine is started regardless of the limit
+	cc.goroutines.Go(func() {
+		defer cancel()
+		defer cc.ReleaseMessage(msg)
+		if err := cc.writeMessage(msg); err != nil {
+			cc.errors(fmt.Errorf(errFmtWriteResponse, err))
+		}
+	})
+	return nil
+}
+
 func (cc *Conn) handlePong(w *responsewriter.ResponseWriter[*Conn], r *pool.Message) {
 	cc.sendPong(w, r)
 }
 
-func upsertInterfaceToMessage(m *pool.Message, ifIndex int) {
-	if ifIndex >= 1 {
-		cm := coapNet.ControlMessage{
-			IfIndex: ifIndex,
-		}
-		m.UpsertControlMessage(&cm)
+// upsertInterfaceToMessage binds the response to the interface on which the request arrived. When the request was
+// sent to an unicast address, the response is also sent from this address, so it passes the source address check of
+// the client and the reverse path filtering on multi-homed hosts. For multicast requests the source address is
+// selected by the system from the addresses of the interface.
+func upsertInterfaceToMessage(m *pool.Message, reqCM *coapNet.ControlMessage) {
+	ifIndex := reqCM.GetIfIndex()
+	if ifIndex < 1 {
+		return
+	}
+	cm := coapNet.ControlMessage{
+		IfIndex: ifIndex,
+	}
+	if dst := reqCM.Dst; dst != nil && !dst.IsMulticast() && !dst.IsUnspecified() && !dst.Equal(net.IPv4bcast) {
+		cm.Src = dst
 	}
+	m.UpsertControlMessage(&cm)
 }
 
 func (cc *Conn) handleSpecialMessages(r *pool.Message) bool {
+	if r.Type() == message.Reset {
+		// the peer rejected the notification, so it is not interested in the observation (RFC 7641 section 3.6)
+		cc.notificationFailed(r.MessageID())
+	}
 	// ping request
 	if r.IsPing(false) {
 		cc.ProcessReceivedMessageWithHandler(r, cc.handlePong)
@@ -872,7 +1371,7 @@ func (cc *Conn) handleSpecialMessages(r *pool.Message) bool {
 		elem.ReleaseMessage(cc)
 		resp := cc.AcquireMessage(cc.Context())
 		resp.SetToken(r.Token())
-		upsertInterfaceToMessage(resp, r.ControlMessage().GetIfIndex())
+		upsertInterfaceToMessage(resp, r.ControlMessage())
 		w := responsewriter.New(resp, cc, r.Options()...)
 		defer func() {
 			cc.ReleaseMessage(w.Message())
@@ -882,6 +1381,9 @@ func (cc *Conn) handleSpecialMessages(r *pool.Message) bool {
 		// the body of the message is need to be processed by the loopOverReceivedMessageQueue goroutine
 		return false
 	}
+	if cc.unexpectedMessageHandler != nil && (r.Type() == message.Acknowledgement || r.Type() == message.Reset) {
+		cc.unexpectedMessageHandler(cc, r)
+	}
 	// separate message
 	if r.IsSeparateMessage() {
 		// msg was processed by token handler - just drop it.
@@ -890,18 +1392,32 @@ func (cc *Conn) handleSpecialMessages(r *pool.Message) bool {
 	return false
 }
 
+// Process processes the received datagram. The datagram which cannot be decoded is dropped and reported
+// as the recoverable transport error, the returned error wraps coapNet.ErrMalformedDatagram.
 func (cc *Conn) Process(cm *coapNet.ControlMessage, datagram []byte) error {
 	if pkgMath.CastTo[uint32](len(datagram)) > cc.session.MaxMessageSize() {
-		return fmt.Errorf("max message size(%v) was exceeded %v", cc.session.MaxMessageSize(), len(datagram))
+		return cc.malformedDatagram(fmt.Errorf("max message size(%v) was exceeded %v", cc.session.MaxMessageSize(), len(datagram)))
+	}
+	req, err := cc.messagePool.AcquireMessageNoWait(cc.Context())
+	if err != nil {
+		// the datagram is dropped, so the exhausted pool doesn't close the connection
+		metrics.MessageDropped(cc.metrics)
+		return nil
+	}
+	_, err = req.UnmarshalWithDecoder(cc.decoder, datagram)
+	if err == nil {
+		err = cc.tokenLength.Validate(req.Code(), req.Token())
 	}
-	req := cc.AcquireMessage(cc.Context())
-	_, err := req.UnmarshalWithDecoder(coder.DefaultCoder, datagram)
 	if err != nil {
 		cc.ReleaseMessage(req)
-		return err
+		if cc.onParseError != nil {
+			cc.onParseError(datagram, cc.RemoteAddr(), err)
+		}
+		return cc.malformedDatagram(err)
 	}
 	req.SetControlMessage(cm)
 	req.SetSequence(cc.Sequence())
+	cc.metrics.MessageReceived(req.Code())
 	cc.checkMyMessageID(req)
 	drop, err := cc.requestMonitor(cc, req)
 	if err != nil {
@@ -923,6 +1439,12 @@ func (cc *Conn) Process(cm *coapNet.ControlMessage, datagram []byte) error {
 	return nil
 }
 
+func (cc *Conn) malformedDatagram(err error) error {
+	err = fmt.Errorf("%w: %w", coapNet.ErrMalformedDatagram, err)
+	cc.reportTransportError(err, false)
+	return err
+}
+
 // SetContextValue stores the value associated with key to context of connection.
 func (cc *Conn) SetContextValue(key interface{}, val interface{}) {
 	cc.session.SetContextValue(key, val)
@@ -933,14 +1455,16 @@ func (cc *Conn) Done() <-chan struct{} {
 	return cc.session.Done()
 }
 
-func (cc *Conn) checkMidHandlerContainer(now time.Time, maxRetransmit uint32, acknowledgeTimeout time.Duration, key int32, value *midElement) {
-	if value.IsExpired(now, maxRetransmit) {
+func (cc *Conn) checkMidHandlerContainer(now time.Time, key int32, value *midElement) {
+	if value.IsExpired(now) {
 		cc.midHandlerContainer.Delete(key)
 		value.ReleaseMessage(cc)
+		// the notification which is not acknowledged ends the observation (RFC 7641 section 4.5)
+		cc.notificationFailed(key)
 		cc.errors(fmt.Errorf(errFmtWriteRequest, context.DeadlineExceeded))
 		return
 	}
-	if !value.Retransmit(now, acknowledgeTimeout) {
+	if !value.Retransmit(now) {
 		return
 	}
 	msg, ok, err := value.GetMessage(cc)
@@ -954,8 +1478,12 @@ func (cc *Conn) checkMidHandlerContainer(now time.Time, maxRetransmit uint32, ac
 		defer cc.ReleaseMessage(msg)
 		err := cc.session.WriteMessage(msg)
 		if err != nil {
+			// the message is retransmitted again after the next timeout
+			cc.reportTransportError(err, false)
 			cc.errors(fmt.Errorf(errFmtWriteRequest, err))
+			return
 		}
+		cc.metrics.Retransmitted()
 	}
 }
 
@@ -966,21 +1494,15 @@ func (cc *Conn) CheckExpirations(now time.Time) {
 	if cc.blockWise != nil {
 		cc.blockWise.CheckExpirations(now)
 	}
-	maxRetransmit := cc.transmission.maxRetransmit.Load()
-	acknowledgeTimeout := cc.transmission.acknowledgeTimeout.Load()
 	x := struct {
-		now                time.Time
-		maxRetransmit      uint32
-		acknowledgeTimeout time.Duration
-		cc                 *Conn
+		now time.Time
+		cc  *Conn
 	}{
-		now:                now,
-		maxRetransmit:      maxRetransmit,
-		acknowledgeTimeout: acknowledgeTimeout,
-		cc:                 cc,
+		now: now,
+		cc:  cc,
 	}
 	cc.midHandlerContainer.Range(func(key int32, value *midElement) bool {
-		x.cc.checkMidHandlerContainer(x.now, x.maxRetransmit, x.acknowledgeTimeout, key, value)
+		x.cc.checkMidHandlerContainer(x.now, key, value)
 		return true
 	})
 }
@@ -989,6 +1511,12 @@ func (cc *Conn) AcquireMessage(ctx context.Context) *pool.Message {
 	return cc.messagePool.AcquireMessage(ctx)
 }
 
+// TryAcquireMessage acquires the message from the message pool of the connection, it returns pool.ErrPoolExhausted
+// when the message cannot be acquired by the pool.ExhaustedPolicy of the pool.
+func (cc *Conn) TryAcquireMessage(ctx context.Context) (*pool.Message, error) {
+	return cc.messagePool.TryAcquireMessage(ctx)
+}
+
 func (cc *Conn) ReleaseMessage(m *pool.Message) {
 	cc.messagePool.ReleaseMessage(m)
 }
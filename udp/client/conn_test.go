@@ -67,7 +67,7 @@ func TestConnDeduplication(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		errS := s.Serve(l)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := udp.Dial(l.LocalAddr().String(),
@@ -152,7 +152,7 @@ func TestConnDeduplicationRetransmission(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		errS := s.Serve(l)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := udp.Dial(l.LocalAddr().String(),
@@ -268,7 +268,7 @@ func testParallelConnGet(t *testing.T, numParallel int) {
 	go func() {
 		defer wg.Done()
 		errS := s.Serve(l)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := udp.Dial(l.LocalAddr().String())
@@ -369,7 +369,7 @@ func TestConnGetSeparateMessage(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		errS := s.Serve(l)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := udp.Dial(l.LocalAddr().String(), options.WithHandlerFunc(func(_ *responsewriter.ResponseWriter[*client.Conn], r *pool.Message) {
@@ -488,7 +488,7 @@ func testConnPost(t *testing.T, numParallel int) {
 			go func() {
 				defer wg.Done()
 				errS := s.Serve(l)
-				assert.NoError(t, errS)
+				assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 			}()
 
 			cc, err := udp.Dial(l.LocalAddr().String())
@@ -631,7 +631,7 @@ func testConnPut(t *testing.T, numParallel int) {
 			go func() {
 				defer wg.Done()
 				errS := s.Serve(l)
-				assert.NoError(t, errS)
+				assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 			}()
 
 			cc, err := udp.Dial(l.LocalAddr().String())
@@ -750,7 +750,7 @@ func testConnDelete(t *testing.T, numParallel int) {
 	go func() {
 		defer wg.Done()
 		errS := s.Serve(l)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := udp.Dial(l.LocalAddr().String())
@@ -817,7 +817,7 @@ func TestConnPing(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		errS := s.Serve(l)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := udp.Dial(l.LocalAddr().String())
@@ -890,7 +890,7 @@ func TestConnRequestMonitorCloseConnection(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		errS := s.Serve(l)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := udp.Dial(l.LocalAddr().String(),
@@ -972,7 +972,7 @@ func TestConnRequestMonitorDropRequest(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		errS := s.Serve(l)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := udp.Dial(l.LocalAddr().String(),
@@ -0,0 +1,31 @@
+package client
+
+import (
+	"net"
+
+	"github.com/plgd-dev/go-coap/v3/net/blockwise"
+)
+
+// ConnectionState contains the parameters of the connection, e.g. for audit logs. The state of the DTLS connection
+// is returned by dtls.ConnectionState.
+type ConnectionState struct {
+	LocalAddr  net.Addr
+	RemoteAddr net.Addr
+	// MaxMessageSize is the maximal size of the message which can be received or sent over the connection.
+	MaxMessageSize uint32
+	// BlockwiseSZX is the maximal block size used by the blockwise transfers.
+	BlockwiseSZX blockwise.SZX
+	// BlockwiseEnabled is true when the blockwise transfers are enabled.
+	BlockwiseEnabled bool
+}
+
+// ConnectionState returns the parameters of the connection.
+func (cc *Conn) ConnectionState() ConnectionState {
+	return ConnectionState{
+		LocalAddr:        cc.LocalAddr(),
+		RemoteAddr:       cc.RemoteAddr(),
+		MaxMessageSize:   cc.session.MaxMessageSize(),
+		BlockwiseSZX:     cc.blockwiseSZX,
+		BlockwiseEnabled: cc.blockWise != nil,
+	}
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/net/observation"
+)
+
+// AlternativeAddress is the option of the final notification sent by Conn.MigrateObservers with the address of the server
+// to which the observer should register again, as the Alternative-Address option of the Release signal of CoAP over TCP
+// (RFC 8323 section 5.5). The number is from the experimental range (RFC 7252 section 12.2) and the option is elective,
+// so the observers which don't support the migration only see the end of the observation.
+const AlternativeAddress message.OptionID = 65004
+
+// PeerObservations returns the tokens of the observations registered by the peer and accepted by the handler.
+func (cc *Conn) PeerObservations() []message.Token {
+	if cc.peerObservations == nil {
+		return nil
+	}
+	return cc.peerObservations.Tokens()
+}
+
+// MigrateObservers ends the observations registered by the peer by the final Confirmable notification 5.03 (Service Unavailable)
+// with the AlternativeAddress option set to the altAddr, so the peer can register them to the server at the altAddr,
+// and it closes the connection. Each notification waits for the acknowledgement until the ctx is done. The connection
+// without the observations is not affected. The observations must be tracked, see options.WithObserverMigration,
+// otherwise observation.ErrNotTracked is returned.
+func (cc *Conn) MigrateObservers(ctx context.Context, altAddr string) error {
+	if cc.peerObservations == nil {
+		return observation.ErrNotTracked
+	}
+	tokens := cc.PeerObservations()
+	if len(tokens) == 0 {
+		return nil
+	}
+	var errs []error
+	for _, token := range tokens {
+		if err := cc.sendMigration(ctx, token, altAddr); err != nil {
+			errs = append(errs, fmt.Errorf("cannot migrate observation %v: %w", token, err))
+		}
+		cc.peerObservations.Remove(token)
+	}
+	if err := cc.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func (cc *Conn) sendMigration(ctx context.Context, token message.Token, altAddr string) error {
+	req := cc.AcquireMessage(ctx)
+	defer cc.ReleaseMessage(req)
+	req.SetCode(codes.ServiceUnavailable)
+	req.SetToken(token)
+	req.SetType(message.Confirmable)
+	req.SetOptionString(AlternativeAddress, altAddr)
+	return cc.WriteMessage(req)
+}
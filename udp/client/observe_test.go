@@ -3,6 +3,7 @@ package client_test
 import (
 	"bytes"
 	"context"
+	"net"
 	"sync"
 	"testing"
 	"time"
@@ -10,11 +11,16 @@ import (
 	"github.com/plgd-dev/go-coap/v3/message"
 	"github.com/plgd-dev/go-coap/v3/message/codes"
 	"github.com/plgd-dev/go-coap/v3/message/pool"
+	"github.com/plgd-dev/go-coap/v3/mux"
 	coapNet "github.com/plgd-dev/go-coap/v3/net"
+	"github.com/plgd-dev/go-coap/v3/net/blockwise"
+	"github.com/plgd-dev/go-coap/v3/net/observation"
 	"github.com/plgd-dev/go-coap/v3/net/responsewriter"
 	"github.com/plgd-dev/go-coap/v3/options"
+	"github.com/plgd-dev/go-coap/v3/pkg/runner/periodic"
 	"github.com/plgd-dev/go-coap/v3/udp"
 	"github.com/plgd-dev/go-coap/v3/udp/client"
+	"github.com/plgd-dev/go-coap/v3/udp/coder"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -158,7 +164,7 @@ func TestConnObserve(t *testing.T) {
 			go func() {
 				defer wg.Done()
 				errS := s.Serve(l)
-				assert.NoError(t, errS)
+				assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 			}()
 
 			cc, err := udp.Dial(l.LocalAddr().String())
@@ -281,7 +287,7 @@ func TestConnObserveNotSupported(t *testing.T) {
 			go func() {
 				defer wg.Done()
 				errS := s.Serve(l)
-				assert.NoError(t, errS)
+				assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 			}()
 
 			cc, err := udp.Dial(l.LocalAddr().String())
@@ -377,7 +383,7 @@ func TestConnObserveCancel(t *testing.T) {
 			go func() {
 				defer wg.Done()
 				errS := s.Serve(l)
-				assert.NoError(t, errS)
+				assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 			}()
 
 			cc, err := udp.Dial(l.LocalAddr().String())
@@ -444,3 +450,649 @@ func (o *observer) observe(req *pool.Message) {
 		}
 	}
 }
+
+func TestConnObserveNotifier(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	const numNotifications = 3
+	m := mux.NewRouter()
+	err = m.Handle("/tmp", mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		obs, errO := r.Observe()
+		if errO != nil || obs != 0 {
+			errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("0")))
+			assert.NoError(t, errS)
+			return
+		}
+		n := mux.NewNotifier(w, r)
+		errS := n.SetResponse(w, codes.Content, message.TextPlain, bytes.NewReader([]byte("0")))
+		assert.NoError(t, errS)
+		go func() {
+			for i := 1; i <= numNotifications; i++ {
+				errN := n.Notify(codes.Content, message.TextPlain, bytes.NewReader([]byte{byte('0' + i)}))
+				assert.NoError(t, errN)
+			}
+		}()
+	}))
+	require.NoError(t, err)
+
+	s := udp.NewServer(options.WithMux(m))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := udp.Dial(l.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	received := make(chan uint32, numNotifications+1)
+	obs, err := cc.Observe(ctx, "/tmp", func(n *pool.Message) {
+		v, errO := n.Observe()
+		assert.NoError(t, errO)
+		received <- v
+	})
+	require.NoError(t, err)
+	for i := 1; i <= numNotifications+1; i++ {
+		select {
+		case v := <-received:
+			require.Equal(t, uint32(i), v)
+		case <-ctx.Done():
+			require.NoError(t, ctx.Err())
+		}
+	}
+	err = obs.Cancel(ctx)
+	require.NoError(t, err)
+}
+
+func TestConnObserveInto(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	m := mux.NewRouter()
+	err = m.Handle("/tmp", mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		obs, errO := r.Observe()
+		if errO != nil || obs != 0 {
+			errS := w.SetResponse(codes.Content, message.AppJSON, bytes.NewReader([]byte(`{"value":0}`)))
+			assert.NoError(t, errS)
+			return
+		}
+		n := mux.NewNotifier(w, r)
+		errS := n.SetResponse(w, codes.Content, message.AppJSON, bytes.NewReader([]byte(`{"value":1}`)))
+		assert.NoError(t, errS)
+		go func() {
+			errN := n.Notify(codes.Content, message.AppLinkFormat, bytes.NewReader([]byte("</a>")))
+			assert.NoError(t, errN)
+			errN = n.Notify(codes.Content, message.AppJSON, bytes.NewReader([]byte(`{"value":2}`)))
+			assert.NoError(t, errN)
+		}()
+	}))
+	require.NoError(t, err)
+
+	s := udp.NewServer(options.WithMux(m))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := udp.Dial(l.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	var dest struct {
+		Value int `json:"value"`
+	}
+	values := make(chan int, 2)
+	errs := make(chan error, 1)
+	obs, err := cc.ObserveInto(ctx, "/tmp", &dest, func() {
+		values <- dest.Value
+	}, func(err error) {
+		errs <- err
+	})
+	require.NoError(t, err)
+	for i := 1; i <= 2; i++ {
+		select {
+		case v := <-values:
+			require.Equal(t, i, v)
+		case <-ctx.Done():
+			require.NoError(t, ctx.Err())
+		}
+	}
+	require.ErrorIs(t, <-errs, message.ErrUnsupportedContentFormat)
+	err = obs.Cancel(ctx)
+	require.NoError(t, err)
+}
+
+func TestConnMaxObservationsPerConn(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	m := mux.NewRouter()
+	err = m.Handle("/tmp", mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		obs, errO := r.Observe()
+		if errO != nil || obs != 0 {
+			errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("0")))
+			assert.NoError(t, errS)
+			return
+		}
+		n := mux.NewNotifier(w, r)
+		errS := n.SetResponse(w, codes.Content, message.TextPlain, bytes.NewReader([]byte("0")))
+		assert.NoError(t, errS)
+	}))
+	require.NoError(t, err)
+
+	s := udp.NewServer(options.WithMux(m), options.WithMaxObservationsPerConn(1))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := udp.Dial(l.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	obs, err := cc.Observe(ctx, "/tmp", func(*pool.Message) {})
+	require.NoError(t, err)
+	_, err = cc.Observe(ctx, "/tmp", func(*pool.Message) {})
+	require.ErrorContains(t, err, codes.ServiceUnavailable.String())
+
+	// the deregistration releases the observation
+	err = obs.Cancel(ctx)
+	require.NoError(t, err)
+	obs, err = cc.Observe(ctx, "/tmp", func(*pool.Message) {})
+	require.NoError(t, err)
+	err = obs.Cancel(ctx)
+	require.NoError(t, err)
+}
+
+func TestConnMigrateObservers(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	m := mux.NewRouter()
+	err = m.Handle("/tmp", mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		n := mux.NewNotifier(w, r)
+		errS := n.SetResponse(w, codes.Content, message.TextPlain, bytes.NewReader([]byte("0")))
+		assert.NoError(t, errS)
+	}))
+	require.NoError(t, err)
+
+	serverConns := make(chan *client.Conn, 1)
+	s := udp.NewServer(options.WithMux(m), options.WithObserverMigration(), options.WithOnNewConn(func(cc *client.Conn) {
+		serverConns <- cc
+	}))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := udp.Dial(l.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	const altAddr = "coap://[2001:db8::1]:5683"
+	migrated := make(chan string, 1)
+	_, err = cc.Observe(ctx, "/tmp", func(n *pool.Message) {
+		if n.Code() != codes.ServiceUnavailable {
+			return
+		}
+		addr, errG := n.GetOptionBytes(client.AlternativeAddress)
+		assert.NoError(t, errG)
+		migrated <- string(addr)
+	})
+	require.NoError(t, err)
+	serverConn := <-serverConns
+	require.Len(t, serverConn.PeerObservations(), 1)
+
+	err = s.MigrateObservers(ctx, altAddr)
+	require.NoError(t, err)
+	select {
+	case addr := <-migrated:
+		require.Equal(t, altAddr, addr)
+	case <-ctx.Done():
+		require.NoError(t, ctx.Err())
+	}
+	// the migrated connection is closed
+	select {
+	case <-serverConn.Context().Done():
+	case <-ctx.Done():
+		require.NoError(t, ctx.Err())
+	}
+	require.Empty(t, serverConn.PeerObservations())
+}
+
+func TestConnPeerObservationsReleasedByFailedNotification(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	notifiers := make(chan *mux.Notifier, 2)
+	m := mux.NewRouter()
+	err = m.Handle("/tmp", mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		n := mux.NewNotifier(w, r)
+		errS := n.SetResponse(w, codes.Content, message.TextPlain, bytes.NewReader([]byte("0")))
+		assert.NoError(t, errS)
+		notifiers <- n
+	}))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	serverConns := make(chan *client.Conn, 1)
+	s := udp.NewServer(options.WithMux(m), options.WithObserverMigration(),
+		options.WithTransmission(1, time.Millisecond*50, 1), options.WithAckRandomFactor(1),
+		options.WithPeriodicRunner(periodic.New(ctx.Done(), time.Millisecond*10)),
+		options.WithOnNewConn(func(cc *client.Conn) {
+			serverConns <- cc
+		}))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	raddr, ok := l.LocalAddr().(*net.UDPAddr)
+	require.True(t, ok)
+	c, err := net.DialUDP("udp", nil, raddr)
+	require.NoError(t, err)
+	defer func() {
+		errC := c.Close()
+		require.NoError(t, errC)
+	}()
+	buf := make([]byte, 1024)
+	write := func(msg message.Message) {
+		n, errE := coder.DefaultCoder.Encode(msg, buf)
+		require.NoError(t, errE)
+		_, errE = c.Write(buf[:n])
+		require.NoError(t, errE)
+	}
+	// read returns the next message with the token, the other messages are skipped, e.g. the response of the mux
+	// to the Reset message
+	read := func(token message.Token) message.Message {
+		for {
+			errD := c.SetReadDeadline(time.Now().Add(time.Second * 5))
+			require.NoError(t, errD)
+			n, errR := c.Read(buf)
+			require.NoError(t, errR)
+			msg := message.Message{Options: make(message.Options, 0, 8)}
+			_, errR = coder.DefaultCoder.Decode(buf[:n], &msg)
+			require.NoError(t, errR)
+			if bytes.Equal(msg.Token, token) {
+				return msg
+			}
+		}
+	}
+	register := func(token message.Token, mid int32) *mux.Notifier {
+		write(message.Message{
+			Token:     token,
+			Code:      codes.GET,
+			Type:      message.Confirmable,
+			MessageID: mid,
+			Options: message.Options{
+				{ID: message.Observe, Value: []byte{}},
+				{ID: message.URIPath, Value: []byte("tmp")},
+			},
+		})
+		resp := read(token)
+		require.Equal(t, codes.Content, resp.Code)
+		return <-notifiers
+	}
+	notify := func(n *mux.Notifier) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// the notification which is not acknowledged fails when the connection is closed
+			_ = n.Notify(codes.Content, message.TextPlain, bytes.NewReader([]byte("1")))
+		}()
+	}
+
+	// the peer rejects the notification by the Reset message
+	n := register(message.Token{1}, 1)
+	serverConn := <-serverConns
+	require.Len(t, serverConn.PeerObservations(), 1)
+	notify(n)
+	notification := read(message.Token{1})
+	write(message.Message{Code: codes.Empty, Type: message.Reset, MessageID: notification.MessageID})
+	require.Eventually(t, func() bool {
+		return len(serverConn.PeerObservations()) == 0
+	}, time.Second*5, time.Millisecond*10)
+
+	// the notification is not acknowledged
+	n = register(message.Token{2}, 2)
+	require.Len(t, serverConn.PeerObservations(), 1)
+	notify(n)
+	read(message.Token{2})
+	require.Eventually(t, func() bool {
+		return len(serverConn.PeerObservations()) == 0
+	}, time.Second*5, time.Millisecond*10)
+}
+
+func TestConnObserveNext(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	notify := make(chan int)
+	m := mux.NewRouter()
+	err = m.Handle("/tmp", mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		obs, errO := r.Observe()
+		if errO != nil || obs != 0 {
+			errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("0")))
+			assert.NoError(t, errS)
+			return
+		}
+		n := mux.NewNotifier(w, r)
+		errS := n.SetResponse(w, codes.Content, message.TextPlain, bytes.NewReader([]byte("0")))
+		assert.NoError(t, errS)
+		go func() {
+			for i := range notify {
+				errN := n.Notify(codes.Content, message.TextPlain, bytes.NewReader([]byte{byte('0' + i)}))
+				assert.NoError(t, errN)
+			}
+		}()
+	}))
+	require.NoError(t, err)
+
+	s := udp.NewServer(options.WithMux(m))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := udp.Dial(l.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	o, err := cc.Observe(ctx, "/tmp", nil)
+	require.NoError(t, err)
+	defer close(notify)
+	obs, ok := o.(mux.NextObservation)
+	require.True(t, ok)
+
+	next := func() string {
+		n, errN := obs.Next(ctx)
+		require.NoError(t, errN)
+		defer cc.ReleaseMessage(n)
+		body, errN := n.ReadBody()
+		require.NoError(t, errN)
+		return string(body)
+	}
+	// the response to the registration is the first notification
+	require.Equal(t, "0", next())
+	notify <- 1
+	require.Equal(t, "1", next())
+
+	// the unread notification is replaced by the newer one
+	notify <- 2
+	notify <- 3
+	time.Sleep(time.Millisecond * 200)
+	require.Equal(t, "3", next())
+
+	// the unread notification is released by the cancel
+	notify <- 4
+	time.Sleep(time.Millisecond * 200)
+	err = obs.Cancel(ctx)
+	require.NoError(t, err)
+	_, err = obs.Next(ctx)
+	require.ErrorIs(t, err, observation.ErrObservationCanceled)
+
+	obsFunc, err := cc.Observe(ctx, "/tmp", func(*pool.Message) {})
+	require.NoError(t, err)
+	_, err = obsFunc.(mux.NextObservation).Next(ctx)
+	require.ErrorIs(t, err, observation.ErrObserveFuncIsSet)
+	err = obsFunc.Cancel(ctx)
+	require.NoError(t, err)
+}
+
+func TestConnObserveWithMethod(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	deregistered := make(chan codes.Code, 1)
+	m := mux.NewRouter()
+	err = m.Handle("/action", mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		obs, errO := r.Observe()
+		if errO != nil || obs != 0 {
+			deregistered <- r.Code()
+			errS := w.SetResponse(codes.Changed, message.TextPlain, nil)
+			assert.NoError(t, errS)
+			return
+		}
+		assert.Equal(t, codes.POST, r.Code())
+		body, errR := r.ReadBody()
+		assert.NoError(t, errR)
+		assert.Equal(t, []byte("start"), body)
+		n := mux.NewNotifier(w, r)
+		errS := n.SetResponse(w, codes.Changed, message.TextPlain, bytes.NewReader([]byte("0")))
+		assert.NoError(t, errS)
+		go func() {
+			errN := n.Notify(codes.Content, message.TextPlain, bytes.NewReader([]byte("50")))
+			assert.NoError(t, errN)
+			errN = n.Notify(codes.Content, message.TextPlain, bytes.NewReader([]byte("100")))
+			assert.NoError(t, errN)
+		}()
+	}))
+	require.NoError(t, err)
+
+	s := udp.NewServer(options.WithMux(m))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := udp.Dial(l.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	_, err = cc.ObserveWithMethod(ctx, codes.DELETE, "/action", message.TextPlain, nil, nil)
+	require.Error(t, err)
+
+	progress := make(chan string, 3)
+	obs, err := cc.ObserveWithMethod(ctx, codes.POST, "/action", message.TextPlain, bytes.NewReader([]byte("start")), func(n *pool.Message) {
+		body, errR := n.ReadBody()
+		assert.NoError(t, errR)
+		progress <- string(body)
+	})
+	require.NoError(t, err)
+	for _, want := range []string{"0", "50", "100"} {
+		select {
+		case p := <-progress:
+			require.Equal(t, want, p)
+		case <-ctx.Done():
+			require.NoError(t, ctx.Err())
+		}
+	}
+	err = obs.Cancel(ctx)
+	require.NoError(t, err)
+	require.Equal(t, codes.POST, <-deregistered)
+}
+
+func TestConnObserveBlockwise(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	representation := func(c byte) []byte {
+		return bytes.Repeat([]byte{c}, 3000)
+	}
+	var stateLock sync.Mutex
+	state := byte('a')
+	getState := func() byte {
+		stateLock.Lock()
+		defer stateLock.Unlock()
+		return state
+	}
+	m := mux.NewRouter()
+	err = m.Handle("/big", mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		obs, errO := r.Observe()
+		if errO != nil || obs != 0 {
+			// the next blocks of the notification are fetched by GET
+			errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader(representation(getState())))
+			assert.NoError(t, errS)
+			return
+		}
+		n := mux.NewNotifier(w, r)
+		errS := n.SetResponse(w, codes.Content, message.TextPlain, bytes.NewReader(representation(getState())))
+		assert.NoError(t, errS)
+		go func() {
+			time.Sleep(time.Millisecond * 100)
+			stateLock.Lock()
+			// the resource is changed again before the rest of the notification is fetched
+			state = 'c'
+			stateLock.Unlock()
+			errN := n.Notify(codes.Content, message.TextPlain, bytes.NewReader(representation('b')))
+			assert.NoError(t, errN)
+		}()
+	}))
+	require.NoError(t, err)
+
+	s := udp.NewServer(options.WithMux(m), options.WithBlockwise(true, blockwise.SZX1024, time.Second*5))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := udp.Dial(l.LocalAddr().String(), options.WithBlockwise(true, blockwise.SZX1024, time.Second*5))
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	notifications := make(chan []byte, 2)
+	obs, err := cc.Observe(ctx, "/big", func(n *pool.Message) {
+		body, errR := n.ReadBody()
+		assert.NoError(t, errR)
+		_, errE := n.ETag()
+		assert.NoError(t, errE)
+		notifications <- body
+	})
+	require.NoError(t, err)
+	// the callback gets the whole representations, the blocks of the different representations are not mixed
+	for _, want := range []byte{'a', 'c'} {
+		select {
+		case body := <-notifications:
+			require.Equal(t, representation(want), body)
+		case <-ctx.Done():
+			require.NoError(t, ctx.Err())
+		}
+	}
+	err = obs.Cancel(ctx)
+	require.NoError(t, err)
+}
@@ -0,0 +1,60 @@
+package client
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestTransmissionInitialTimeout(t *testing.T) {
+	ackTimeout := time.Second * 2
+	tr := &Transmission{
+		atomic.NewUint32(1),
+		atomic.NewDuration(ackTimeout),
+		atomic.NewUint32(4),
+		atomic.NewFloat64(1.5),
+		atomic.NewDuration(0),
+	}
+	timeouts := make(map[time.Duration]struct{})
+	for i := 0; i < 100; i++ {
+		timeout := tr.initialTimeout()
+		require.GreaterOrEqual(t, timeout, ackTimeout)
+		require.LessOrEqual(t, timeout, ackTimeout*3/2)
+		timeouts[timeout] = struct{}{}
+	}
+	// timeouts are randomized
+	require.Greater(t, len(timeouts), 1)
+
+	tr.SetTransmissionAckRandomFactor(1)
+	require.Equal(t, ackTimeout, tr.initialTimeout())
+	tr.SetTransmissionAckRandomFactor(0)
+	require.Equal(t, ackTimeout, tr.initialTimeout())
+}
+
+func TestTransmissionExchangeLifetime(t *testing.T) {
+	tr := &Transmission{
+		atomic.NewUint32(1),
+		atomic.NewDuration(time.Second * 2),
+		atomic.NewUint32(4),
+		atomic.NewFloat64(1.5),
+		atomic.NewDuration(0),
+	}
+	require.Equal(t, ExchangeLifetime, tr.ExchangeLifetime())
+
+	// the lifetime follows the transmission parameters: 1s * (2^2 - 1) * 1 + 2 * 100s + 1s
+	tr.SetTransmissionAcknowledgeTimeout(time.Second)
+	tr.SetTransmissionMaxRetransmit(2)
+	tr.SetTransmissionAckRandomFactor(1)
+	require.Equal(t, time.Second*204, tr.ExchangeLifetime())
+
+	tr.SetExchangeLifetime(time.Second * 30)
+	require.Equal(t, time.Second*30, tr.ExchangeLifetime())
+	tr.SetExchangeLifetime(0)
+	require.Equal(t, time.Second*204, tr.ExchangeLifetime())
+
+	tr.SetTransmissionMaxRetransmit(200)
+	require.Equal(t, time.Duration(math.MaxInt64), tr.ExchangeLifetime())
+}
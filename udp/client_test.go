@@ -4,23 +4,33 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net"
+	"runtime"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/plgd-dev/go-coap/v3/message"
 	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/message/linkformat"
 	"github.com/plgd-dev/go-coap/v3/message/pool"
 	"github.com/plgd-dev/go-coap/v3/mux"
 	coapNet "github.com/plgd-dev/go-coap/v3/net"
+	"github.com/plgd-dev/go-coap/v3/net/blockwise"
+	netClient "github.com/plgd-dev/go-coap/v3/net/client"
+	"github.com/plgd-dev/go-coap/v3/net/metrics"
 	"github.com/plgd-dev/go-coap/v3/net/responsewriter"
 	"github.com/plgd-dev/go-coap/v3/options"
 	"github.com/plgd-dev/go-coap/v3/options/config"
 	"github.com/plgd-dev/go-coap/v3/pkg/runner/periodic"
 	"github.com/plgd-dev/go-coap/v3/udp/client"
+	"github.com/plgd-dev/go-coap/v3/udp/coder"
+	"github.com/plgd-dev/go-coap/v3/udp/server"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/atomic"
@@ -141,7 +151,7 @@ func TestConnGet(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		errS := s.Serve(l)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := Dial(l.LocalAddr().String())
@@ -241,7 +251,7 @@ func TestConnGetSeparateMessage(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		errS := s.Serve(l)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := Dial(l.LocalAddr().String(), options.WithHandlerFunc(func(_ *responsewriter.ResponseWriter[*client.Conn], r *pool.Message) {
@@ -363,7 +373,7 @@ func TestConnPost(t *testing.T) {
 			go func() {
 				defer wg.Done()
 				errS := s.Serve(l)
-				assert.NoError(t, errS)
+				assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 			}()
 
 			cc, err := Dial(l.LocalAddr().String())
@@ -493,7 +503,7 @@ func TestConnPut(t *testing.T) {
 			go func() {
 				defer wg.Done()
 				errS := s.Serve(l)
-				assert.NoError(t, errS)
+				assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 			}()
 
 			cc, err := Dial(l.LocalAddr().String())
@@ -600,7 +610,7 @@ func TestConnDelete(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		errS := s.Serve(l)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := Dial(l.LocalAddr().String())
@@ -652,7 +662,7 @@ func TestConnPing(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		errS := s.Serve(l)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := Dial(l.LocalAddr().String())
@@ -669,129 +679,3402 @@ func TestConnPing(t *testing.T) {
 	require.NoError(t, err)
 }
 
-func TestClientInactiveMonitor(t *testing.T) {
-	var inactivityDetected atomic.Bool
+func TestConnGetWithContentFormat(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	m := mux.NewRouter()
+	err = m.Handle("/a", mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		errH := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("a")))
+		require.NoError(t, errH)
+	}))
+	require.NoError(t, err)
+
+	s := NewServer(options.WithMux(m))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := Dial(l.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
 
 	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
 	defer cancel()
+	// Content-Format describes the payload, so the GET must use the Accept option instead
+	_, err = cc.Get(ctx, "/a", message.Option{ID: message.ContentFormat, Value: []byte{byte(message.AppJSON)}})
+	require.ErrorIs(t, err, message.ErrContentFormatWithoutPayload)
 
-	ld, err := coapNet.NewListenUDP("udp4", "")
+	resp, err := cc.Get(ctx, "/a", message.Option{ID: message.Accept, Value: []byte{byte(message.TextPlain)}})
+	require.NoError(t, err)
+	require.Equal(t, codes.Content, resp.Code())
+
+	// the empty payload has the content format
+	resp, err = cc.Post(ctx, "/a", message.TextPlain, bytes.NewReader(nil))
+	require.NoError(t, err)
+	require.Equal(t, codes.Content, resp.Code())
+}
+
+func TestConnRefused(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("ICMP port unreachable is reported to connected UDP sockets only on linux")
+	}
+	l, err := coapNet.NewListenUDP("udp", "127.0.0.1:")
+	require.NoError(t, err)
+	addr := l.LocalAddr().String()
+	err = l.Close()
+	require.NoError(t, err)
+
+	cc, err := Dial(addr)
 	require.NoError(t, err)
 	defer func() {
-		errC := ld.Close()
+		errC := cc.Close()
 		require.NoError(t, errC)
+		<-cc.Done()
 	}()
 
-	checkClose := semaphore.NewWeighted(1)
-	err = checkClose.Acquire(ctx, 1)
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	_, err = cc.Get(ctx, "/a")
+	require.Error(t, err)
+	require.ErrorIs(t, err, coapNet.ErrConnectionRefused)
+	require.NoError(t, ctx.Err())
+}
+
+func TestConnResponseFromRequestDestination(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the whole 127.0.0.0/8 is assigned to the loopback interface only on linux")
+	}
+	l, err := coapNet.NewListenUDP("udp4", "0.0.0.0:")
 	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
 
-	sd := NewServer(
-		options.WithPeriodicRunner(periodic.New(ctx.Done(), time.Millisecond*10)),
-	)
+	s := NewServer()
+	defer s.Stop()
 
-	var serverWg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	addr, ok := l.LocalAddr().(*net.UDPAddr)
+	require.True(t, ok)
+	// the connected client socket accepts only responses sent from 127.0.0.2
+	cc, err := Dial(fmt.Sprintf("127.0.0.2:%v", addr.Port))
+	require.NoError(t, err)
 	defer func() {
-		sd.Stop()
-		serverWg.Wait()
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
 	}()
-	serverWg.Add(1)
-	go func() {
-		defer serverWg.Done()
-		errS := sd.Serve(ld)
-		assert.NoError(t, errS)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	resp, err := cc.Get(ctx, "/a")
+	require.NoError(t, err)
+	require.Equal(t, codes.NotFound, resp.Code())
+}
+
+func TestConnSetTransmissionParameters(t *testing.T) {
+	// peer doesn't respond, so confirmable requests are retransmitted
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
 	}()
 
-	cc, err := Dial(
-		ld.LocalAddr().String(),
-		options.WithInactivityMonitor(100*time.Millisecond, func(cc *client.Conn) {
-			require.False(t, inactivityDetected.Load())
-			inactivityDetected.Store(true)
-			errC := cc.Close()
-			require.NoError(t, errC)
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	var mutex sync.Mutex
+	sent := make(map[int32]int)
+	var onFirstSent func()
+	cc, err := Dial(l.LocalAddr().String(),
+		options.WithTransmission(1, time.Millisecond*20, 1),
+		options.WithPeriodicRunner(periodic.New(ctx.Done(), time.Millisecond*5)),
+		options.WithErrors(func(error) {
+			// no-op
 		}),
-		options.WithPeriodicRunner(periodic.New(ctx.Done(), time.Millisecond*100)),
-		options.WithReceivedMessageQueueSize(32),
-		options.WithProcessReceivedMessageFunc(func(req *pool.Message, cc *client.Conn, handler config.HandlerFunc[*client.Conn]) {
-			cc.ProcessReceivedMessageWithHandler(req, handler)
+		options.WithWireTap(func(dir config.Direction, data []byte, _ net.Addr) {
+			if dir != config.DirectionSent {
+				return
+			}
+			msg, errP := ParseMessage(context.Background(), data)
+			assert.NoError(t, errP)
+			mutex.Lock()
+			defer mutex.Unlock()
+			sent[msg.MessageID()]++
+			if onFirstSent != nil {
+				onFirstSent()
+				onFirstSent = nil
+			}
 		}),
 	)
 	require.NoError(t, err)
-	cc.AddOnClose(func() {
-		checkClose.Release(1)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	numSent := func(mid int32) int {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return sent[mid]
+	}
+	get := func(changeParameters func()) int32 {
+		req, errR := cc.NewGetRequest(ctx, "/a")
+		require.NoError(t, errR)
+		defer cc.ReleaseMessage(req)
+		reqCtx, reqCancel := context.WithTimeout(ctx, time.Millisecond*300)
+		defer reqCancel()
+		req.SetContext(reqCtx)
+		mutex.Lock()
+		onFirstSent = changeParameters
+		mutex.Unlock()
+		_, errR = cc.Do(req)
+		require.ErrorIs(t, errR, context.DeadlineExceeded)
+		return req.MessageID()
+	}
+
+	// parameters changed during the request are not applied to it
+	mid := get(func() {
+		cc.SetTransmissionParameters(1, time.Millisecond*20, 4)
 	})
+	require.Equal(t, 2, numSent(mid))
+	require.Equal(t, uint32(4), cc.Transmission().MaxRetransmit())
 
-	// send ping to create serverside connection
-	ctxPing, cancel := context.WithTimeout(ctx, time.Second)
-	defer cancel()
-	err = cc.Ping(ctxPing)
+	mid = get(func() {
+		// no-op
+	})
+	require.Equal(t, 5, numSent(mid))
+}
+
+func TestConnIsAlive(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
 	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
 
-	err = cc.Ping(ctx)
+	s := NewServer()
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := Dial(l.LocalAddr().String())
 	require.NoError(t, err)
 
-	// wait for fire inactivity
-	time.Sleep(time.Second * 2)
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	require.True(t, cc.IsAlive(ctx))
 
-	err = checkClose.Acquire(ctx, 1)
+	errC := cc.Close()
+	require.NoError(t, errC)
+	<-cc.Done()
+	require.False(t, cc.IsAlive(ctx))
+	err = cc.Ping(ctx)
+	require.Error(t, err)
+
+	// peer doesn't respond
+	silent, err := coapNet.NewListenUDP("udp", "")
 	require.NoError(t, err)
-	require.True(t, inactivityDetected.Load())
+	defer func() {
+		errC := silent.Close()
+		require.NoError(t, errC)
+	}()
+	cc, err = Dial(silent.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+	ctxSilent, cancelSilent := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancelSilent()
+	require.False(t, cc.IsAlive(ctxSilent))
 }
 
-func TestClientKeepAliveMonitor(t *testing.T) {
-	var inactivityDetected atomic.Bool
+func TestConnSerializedHandlers(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
 
 	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
 	defer cancel()
 
-	ld, err := coapNet.NewListenUDP("udp4", "")
+	var mutex sync.Mutex
+	var handled []string
+	m := mux.NewRouter()
+	err = m.Handle("/a", mux.HandlerFunc(func(w mux.ResponseWriter, _ *mux.Message) {
+		// the request of the handler is served by the peer after the request /b was received
+		resp, errG := w.Conn().Get(ctx, "/dependency")
+		if assert.NoError(t, errG) {
+			assert.Equal(t, codes.Content, resp.Code())
+		}
+		mutex.Lock()
+		handled = append(handled, "a")
+		mutex.Unlock()
+		errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("a")))
+		assert.NoError(t, errS)
+	}))
+	require.NoError(t, err)
+	err = m.Handle("/b", mux.HandlerFunc(func(w mux.ResponseWriter, _ *mux.Message) {
+		mutex.Lock()
+		handled = append(handled, "b")
+		mutex.Unlock()
+		errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("b")))
+		assert.NoError(t, errS)
+	}))
 	require.NoError(t, err)
 
-	var serverWg sync.WaitGroup
-	serverWg.Add(1)
-	defer serverWg.Wait()
+	s := NewServer(options.WithMux(m), options.WithSerializedHandlers())
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	clientMux := mux.NewRouter()
+	err = clientMux.Handle("/dependency", mux.HandlerFunc(func(w mux.ResponseWriter, _ *mux.Message) {
+		time.Sleep(time.Millisecond * 200)
+		errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("dependency")))
+		assert.NoError(t, errS)
+	}))
+	require.NoError(t, err)
+	cc, err := Dial(l.LocalAddr().String(), options.WithMux(clientMux), options.WithLimitClientParallelRequest(2), options.WithTransmission(2, time.Second*2, 4))
+	require.NoError(t, err)
 	defer func() {
-		errC := ld.Close()
+		errC := cc.Close()
 		require.NoError(t, errC)
+		<-cc.Done()
 	}()
+
+	var reqWg sync.WaitGroup
+	reqWg.Add(1)
 	go func() {
-		defer serverWg.Done()
-		for {
-			_, _, errR := ld.ReadWithContext(ctx, make([]byte, 1024))
-			if errR != nil {
-				if errors.Is(errR, net.ErrClosed) {
-					return
-				}
-			}
-			assert.NoError(t, errR)
-		}
+		defer reqWg.Done()
+		_, errG := cc.Get(ctx, "/a")
+		assert.NoError(t, errG)
 	}()
-
-	checkClose := semaphore.NewWeighted(1)
-	err = checkClose.Acquire(ctx, 1)
-	require.NoError(t, err)
-	cc, err := Dial(
-		ld.LocalAddr().String(),
-		options.WithKeepAlive(3, 100*time.Millisecond, func(cc *client.Conn) {
-			require.False(t, inactivityDetected.Load())
-			inactivityDetected.Store(true)
-			errC := cc.Close()
-			require.NoError(t, errC)
-		}),
-		options.WithPeriodicRunner(periodic.New(ctx.Done(), time.Millisecond*10)),
-	)
+	time.Sleep(time.Millisecond * 50)
+	_, err = cc.Get(ctx, "/b")
 	require.NoError(t, err)
-	cc.AddOnClose(func() {
-		checkClose.Release(1)
-	})
+	reqWg.Wait()
 
-	// send ping to create server side connection
-	ctxPing, cancel := context.WithTimeout(ctx, time.Second)
-	defer cancel()
-	_, err = cc.Get(ctxPing, "/tmp")
-	require.Error(t, err)
+	mutex.Lock()
+	defer mutex.Unlock()
+	require.Equal(t, []string{"a", "b"}, handled)
+}
 
-	err = checkClose.Acquire(ctx, 1)
+func TestConnWireTap(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
 	require.NoError(t, err)
-	require.True(t, inactivityDetected.Load())
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	type tapped struct {
+		dir  config.Direction
+		data []byte
+	}
+	newTap := func(mutex *sync.Mutex, data *[]tapped) config.WireTapFunc {
+		return func(dir config.Direction, d []byte, addr net.Addr) {
+			assert.NotNil(t, addr)
+			mutex.Lock()
+			defer mutex.Unlock()
+			*data = append(*data, tapped{dir: dir, data: append([]byte(nil), d...)})
+		}
+	}
+	var serverMutex, clientMutex sync.Mutex
+	var serverTapped, clientTapped []tapped
+
+	s := NewServer(options.WithWireTap(newTap(&serverMutex, &serverTapped)))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := Dial(l.LocalAddr().String(), options.WithWireTap(newTap(&clientMutex, &clientTapped)))
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	resp, err := cc.Get(ctx, "/a")
+	require.NoError(t, err)
+	require.Equal(t, codes.NotFound, resp.Code())
+
+	clientMutex.Lock()
+	require.Len(t, clientTapped, 2)
+	require.Equal(t, config.DirectionSent, clientTapped[0].dir)
+	require.Equal(t, config.DirectionReceived, clientTapped[1].dir)
+	clientMutex.Unlock()
+
+	serverMutex.Lock()
+	defer serverMutex.Unlock()
+	require.Len(t, serverTapped, 2)
+	require.Equal(t, tapped{dir: config.DirectionReceived, data: clientTapped[0].data}, serverTapped[0])
+	require.Equal(t, tapped{dir: config.DirectionSent, data: clientTapped[1].data}, serverTapped[1])
+}
+
+func TestConnOnParseError(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	type parseError struct {
+		data []byte
+		err  error
+	}
+	parseErrors := make(chan parseError, 1)
+	s := NewServer(options.WithOnParseError(func(data []byte, addr net.Addr, err error) {
+		assert.NotNil(t, addr)
+		parseErrors <- parseError{data: append([]byte(nil), data...), err: err}
+	}))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	raddr, ok := l.LocalAddr().(*net.UDPAddr)
+	require.True(t, ok)
+	c, err := net.DialUDP("udp", nil, raddr)
+	require.NoError(t, err)
+	defer func() {
+		errC := c.Close()
+		require.NoError(t, errC)
+	}()
+	// option delta 15 is reserved for the payload marker
+	malformed := []byte{0x40, byte(codes.GET), 0, 1, 0xf1, 0x00}
+	_, err = c.Write(malformed)
+	require.NoError(t, err)
+
+	select {
+	case p := <-parseErrors:
+		require.Equal(t, malformed, p.data)
+		require.Error(t, p.err)
+	case <-time.After(Timeout):
+		require.Fail(t, "parse error was not reported")
+	}
+}
+
+func TestConnSeparateResponseRetransmitted(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	m := mux.NewRouter()
+	m.HandleFunc("/slow", func(w mux.ResponseWriter, _ *mux.Message) {
+		time.Sleep(time.Millisecond * 100)
+		errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("slow")))
+		assert.NoError(t, errS)
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := NewServer(options.WithMux(m), options.WithSeparateResponseThreshold(time.Millisecond*10),
+		options.WithTransmission(1, time.Millisecond*100, 4), options.WithAckRandomFactor(1),
+		options.WithPeriodicRunner(periodic.New(ctx.Done(), time.Millisecond*10)))
+	defer s.Stop()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	raddr, ok := l.LocalAddr().(*net.UDPAddr)
+	require.True(t, ok)
+	c, err := net.DialUDP("udp", nil, raddr)
+	require.NoError(t, err)
+	defer func() {
+		errC := c.Close()
+		require.NoError(t, errC)
+	}()
+	buf := make([]byte, 1024)
+	n, err := coder.DefaultCoder.Encode(message.Message{
+		Token:     []byte{1},
+		Code:      codes.GET,
+		Type:      message.Confirmable,
+		MessageID: 1,
+		Options:   message.Options{{ID: message.URIPath, Value: []byte("slow")}},
+	}, buf)
+	require.NoError(t, err)
+	_, err = c.Write(buf[:n])
+	require.NoError(t, err)
+
+	read := func() message.Message {
+		errD := c.SetReadDeadline(time.Now().Add(Timeout))
+		require.NoError(t, errD)
+		n, errR := c.Read(buf)
+		require.NoError(t, errR)
+		msg := message.Message{Options: make(message.Options, 0, 8)}
+		_, errR = coder.DefaultCoder.Decode(buf[:n], &msg)
+		require.NoError(t, errR)
+		return msg
+	}
+	ack := read()
+	require.Equal(t, message.Acknowledgement, ack.Type)
+	require.Equal(t, codes.Empty, ack.Code)
+
+	// the separate response is not acknowledged, so it is retransmitted
+	resp := read()
+	require.Equal(t, message.Confirmable, resp.Type)
+	require.Equal(t, codes.Content, resp.Code)
+	respMID := resp.MessageID
+	resp = read()
+	require.Equal(t, message.Confirmable, resp.Type)
+	require.Equal(t, respMID, resp.MessageID)
+	require.Equal(t, []byte("slow"), resp.Payload)
+
+	n, err = coder.DefaultCoder.Encode(message.Message{
+		Code:      codes.Empty,
+		Type:      message.Acknowledgement,
+		MessageID: respMID,
+	}, buf)
+	require.NoError(t, err)
+	_, err = c.Write(buf[:n])
+	require.NoError(t, err)
+	// no more retransmissions after the acknowledgement, except the one which could be sent meanwhile
+	retransmissions := 0
+	for {
+		err = c.SetReadDeadline(time.Now().Add(time.Millisecond * 500))
+		require.NoError(t, err)
+		if _, err = c.Read(buf); err != nil {
+			break
+		}
+		retransmissions++
+	}
+	require.LessOrEqual(t, retransmissions, 1)
+	var netErr net.Error
+	require.ErrorAs(t, err, &netErr)
+	require.True(t, netErr.Timeout())
+}
+
+func TestConnStrictParsing(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	parseErrors := make(chan error, 1)
+	s := NewServer(options.WithStrictParsing(), options.WithOnParseError(func(_ []byte, _ net.Addr, err error) {
+		parseErrors <- err
+	}))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	raddr, ok := l.LocalAddr().(*net.UDPAddr)
+	require.True(t, ok)
+	c, err := net.DialUDP("udp", nil, raddr)
+	require.NoError(t, err)
+	defer func() {
+		errC := c.Close()
+		require.NoError(t, errC)
+	}()
+	// Observe option with the value 1 encoded by 2 bytes
+	_, err = c.Write([]byte{0x40, byte(codes.GET), 0, 1, 0x62, 0x00, 0x01})
+	require.NoError(t, err)
+
+	select {
+	case err := <-parseErrors:
+		require.ErrorIs(t, err, message.ErrOptionsNotCanonical)
+	case <-time.After(Timeout):
+		require.Fail(t, "parse error was not reported")
+	}
+}
+
+func TestConnDefaultContentFormat(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	m := mux.NewRouter()
+	err = m.Handle("/a", mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		cf, errC := r.ContentFormat()
+		if errC != nil {
+			errS := w.SetResponse(codes.BadRequest, message.TextPlain, nil)
+			assert.NoError(t, errS)
+			return
+		}
+		errS := w.SetResponse(codes.Changed, cf, bytes.NewReader([]byte{1}))
+		assert.NoError(t, errS)
+	}))
+	require.NoError(t, err)
+
+	s := NewServer(options.WithMux(m))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := Dial(l.LocalAddr().String(), options.WithDefaultContentFormat(message.AppCBOR))
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	// request without content format gets the default one
+	req, err := cc.NewGetRequest(ctx, "/a")
+	require.NoError(t, err)
+	req.SetCode(codes.POST)
+	req.SetBody(bytes.NewReader([]byte{0xa0}))
+	resp, err := cc.Do(req)
+	require.NoError(t, err)
+	require.Equal(t, codes.Changed, resp.Code())
+	cf, err := resp.ContentFormat()
+	require.NoError(t, err)
+	require.Equal(t, message.AppCBOR, cf)
+
+	// the helpers without the content format send the default one
+	resp, err = cc.PostWithoutContentFormat(ctx, "/a", bytes.NewReader([]byte{0xa0}))
+	require.NoError(t, err)
+	require.Equal(t, codes.Changed, resp.Code())
+	cf, err = resp.ContentFormat()
+	require.NoError(t, err)
+	require.Equal(t, message.AppCBOR, cf)
+	resp, err = cc.PutWithoutContentFormat(ctx, "/a", bytes.NewReader([]byte{0xa0}))
+	require.NoError(t, err)
+	require.Equal(t, codes.Changed, resp.Code())
+	cf, err = resp.ContentFormat()
+	require.NoError(t, err)
+	require.Equal(t, message.AppCBOR, cf)
+
+	// explicit content format is kept
+	resp, err = cc.Post(ctx, "/a", message.AppJSON, bytes.NewReader([]byte("{}")))
+	require.NoError(t, err)
+	cf, err = resp.ContentFormat()
+	require.NoError(t, err)
+	require.Equal(t, message.AppJSON, cf)
+}
+
+func TestConnClientOption(t *testing.T) {
+	const clientOptionID = message.OptionID(65000)
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	m := mux.NewRouter()
+	err = m.Handle("/a", mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		values := make([][]byte, 2)
+		n, errC := r.Options().GetBytess(clientOptionID, values)
+		if errC != nil || n != 1 {
+			errS := w.SetResponse(codes.BadRequest, message.TextPlain, nil)
+			require.NoError(t, errS)
+			return
+		}
+		errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader(values[0]))
+		require.NoError(t, errS)
+	}))
+	require.NoError(t, err)
+
+	s := NewServer(options.WithMux(m))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := Dial(l.LocalAddr().String(), options.WithClientOption(clientOptionID, []byte("myapp/1.2")))
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	// request without the option gets the client option
+	resp, err := cc.Get(ctx, "/a")
+	require.NoError(t, err)
+	require.Equal(t, codes.Content, resp.Code())
+	body, err := resp.ReadBody()
+	require.NoError(t, err)
+	require.Equal(t, "myapp/1.2", string(body))
+
+	// explicit option is kept
+	resp, err = cc.Get(ctx, "/a", message.Option{ID: clientOptionID, Value: []byte("other/1.0")})
+	require.NoError(t, err)
+	require.Equal(t, codes.Content, resp.Code())
+	body, err = resp.ReadBody()
+	require.NoError(t, err)
+	require.Equal(t, "other/1.0", string(body))
+}
+
+func TestConnBlockwiseSZXDownNegotiation(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	payload := make([]byte, 2048)
+	for i := range payload {
+		payload[i] = byte(i % 251)
+	}
+
+	m := mux.NewRouter()
+	err = m.Handle("/a", mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		if r.Code() == codes.GET {
+			errS := w.SetResponse(codes.Content, message.AppOctets, bytes.NewReader(payload))
+			assert.NoError(t, errS)
+			return
+		}
+		body, errB := r.ReadBody()
+		assert.NoError(t, errB)
+		assert.Equal(t, payload, body)
+		errS := w.SetResponse(codes.Changed, message.TextPlain, nil)
+		assert.NoError(t, errS)
+	}))
+	require.NoError(t, err)
+
+	s := NewServer(options.WithMux(m), options.WithMaxBlockSZX(blockwise.SZX32))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	var sent atomic.Int32
+	cc, err := Dial(l.LocalAddr().String(), options.WithWireTap(func(dir config.Direction, _ []byte, _ net.Addr) {
+		if dir == config.DirectionSent {
+			sent.Inc()
+		}
+	}))
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	// the first block is sent with SZX1024 and the rest continues from the offset 1024 with SZX32
+	resp, err := cc.Post(ctx, "/a", message.AppOctets, bytes.NewReader(payload))
+	require.NoError(t, err)
+	require.Equal(t, codes.Changed, resp.Code())
+	require.Equal(t, int32(1+1024/32), sent.Load())
+
+	sent.Store(0)
+	resp, err = cc.Get(ctx, "/a")
+	require.NoError(t, err)
+	require.Equal(t, codes.Content, resp.Code())
+	body, err := resp.ReadBody()
+	require.NoError(t, err)
+	require.Equal(t, payload, body)
+	require.Equal(t, int32(len(payload)/32), sent.Load())
+}
+
+type testBufferAllocator struct {
+	mutex      sync.Mutex
+	acquired   map[*byte]int
+	numAcquire int
+}
+
+func (a *testBufferAllocator) Acquire(size int) []byte {
+	buf := make([]byte, 0, size)
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.acquired[&buf[:1][0]] = size
+	a.numAcquire++
+	return buf
+}
+
+func (a *testBufferAllocator) Release(buf []byte) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	delete(a.acquired, &buf[:1][0])
+}
+
+func (a *testBufferAllocator) stats() (numAcquire, outstanding int) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.numAcquire, len(a.acquired)
+}
+
+func TestConnBlockwiseBufferAllocator(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	payload := make([]byte, 8000)
+	for i := range payload {
+		payload[i] = byte(i % 251)
+	}
+
+	m := mux.NewRouter()
+	err = m.Handle("/a", mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		body, errB := r.ReadBody()
+		require.NoError(t, errB)
+		require.Equal(t, payload, body)
+		errS := w.SetResponse(codes.Changed, message.TextPlain, nil)
+		require.NoError(t, errS)
+	}))
+	require.NoError(t, err)
+
+	allocator := &testBufferAllocator{acquired: make(map[*byte]int)}
+	s := NewServer(options.WithMux(m), options.WithBlockwiseBufferAllocator(allocator))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := Dial(l.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	for i := 0; i < 2; i++ {
+		resp, errP := cc.Post(ctx, "/a", message.AppOctets, bytes.NewReader(payload))
+		require.NoError(t, errP)
+		require.Equal(t, codes.Changed, resp.Code())
+	}
+	numAcquire, outstanding := allocator.stats()
+	// buffer grows 1024 -> 2048 -> 4096 -> 8192 for each transfer
+	require.Equal(t, 8, numAcquire)
+	require.Equal(t, 0, outstanding)
+}
+
+func TestConnBlockwiseStreamResponse(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	payload := make([]byte, 8192)
+	for i := range payload {
+		payload[i] = byte(i % 251)
+	}
+
+	m := mux.NewRouter()
+	err = m.Handle("/a", mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		size, errQ := r.Queries()
+		require.NoError(t, errQ)
+		n, errA := strconv.Atoi(size[0])
+		require.NoError(t, errA)
+		// hide the bytes.Reader behind io.Reader, so the size is unknown
+		errS := w.SetResponse(codes.Content, message.AppOctets, message.NewStreamBody(io.MultiReader(bytes.NewReader(payload[:n]))))
+		require.NoError(t, errS)
+	}))
+	require.NoError(t, err)
+
+	s := NewServer(options.WithMux(m))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := Dial(l.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	// the last block is short, full (the stream ends at the block boundary) and the stream fits into the single message
+	for _, n := range []int{8000, 8192, 100} {
+		resp, errG := cc.Get(ctx, "/a", message.Option{ID: message.URIQuery, Value: []byte(strconv.Itoa(n))})
+		require.NoError(t, errG)
+		require.Equal(t, codes.Content, resp.Code())
+		body, errB := resp.ReadBody()
+		require.NoError(t, errB)
+		require.Equal(t, payload[:n], body)
+	}
+
+	// the previous block is requested again, e.g. when its response was lost
+	ccBlocks, err := Dial(l.LocalAddr().String(), options.WithBlockwise(false, blockwise.SZX1024, Timeout))
+	require.NoError(t, err)
+	defer func() {
+		errC := ccBlocks.Close()
+		require.NoError(t, errC)
+		<-ccBlocks.Done()
+	}()
+	token, err := message.GetToken()
+	require.NoError(t, err)
+	for _, num := range []int64{0, 1, 2, 1, 3} {
+		req, errR := ccBlocks.NewGetRequest(ctx, "/a", message.Option{ID: message.URIQuery, Value: []byte("4096")})
+		require.NoError(t, errR)
+		req.SetToken(token)
+		block, errE := blockwise.EncodeBlockOption(blockwise.SZX1024, num, false)
+		require.NoError(t, errE)
+		req.SetOptionUint32(message.Block2, block)
+		resp, errD := ccBlocks.Do(req)
+		ccBlocks.ReleaseMessage(req)
+		require.NoError(t, errD)
+		require.Equal(t, codes.Content, resp.Code())
+		body, errB := resp.ReadBody()
+		require.NoError(t, errB)
+		require.Equal(t, payload[num*1024:(num+1)*1024], body)
+	}
+}
+
+func TestConnBlockwiseRequestEntityTooLarge(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	var handled atomic.Int32
+	m := mux.NewRouter()
+	err = m.Handle("/a", mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		handled.Inc()
+		_, errB := r.ReadBody()
+		require.NoError(t, errB)
+		errS := w.SetResponse(codes.Changed, message.TextPlain, nil)
+		require.NoError(t, errS)
+	}))
+	require.NoError(t, err)
+
+	s := NewServer(options.WithMux(m), options.WithBlockwiseMaxRequestBodySize(4096))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	var sent atomic.Int32
+	cc, err := Dial(l.LocalAddr().String(), options.WithWireTap(func(dir config.Direction, _ []byte, _ net.Addr) {
+		if dir == config.DirectionSent {
+			sent.Inc()
+		}
+	}))
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	err = cc.ProbeUpload(ctx, codes.POST, "/a", 100000)
+	require.ErrorIs(t, err, netClient.ErrRequestEntityTooLarge)
+	require.ErrorContains(t, err, "4096")
+	err = cc.ProbeUpload(ctx, codes.PUT, "/a", 4096)
+	require.NoError(t, err)
+	require.Equal(t, int32(0), handled.Load())
+
+	// upload is rejected after the first block
+	sent.Store(0)
+	resp, err := cc.Post(ctx, "/a", message.AppOctets, bytes.NewReader(make([]byte, 100000)))
+	require.NoError(t, err)
+	require.Equal(t, codes.RequestEntityTooLarge, resp.Code())
+	maxSize, err := resp.GetOptionUint32(message.Size1)
+	require.NoError(t, err)
+	require.Equal(t, uint32(4096), maxSize)
+	require.Equal(t, int32(1), sent.Load())
+	require.Equal(t, int32(0), handled.Load())
+
+	resp, err = cc.Post(ctx, "/a", message.AppOctets, bytes.NewReader(make([]byte, 4096)))
+	require.NoError(t, err)
+	require.Equal(t, codes.Changed, resp.Code())
+	require.Equal(t, int32(1), handled.Load())
+}
+
+func TestConnProbeUploadBlockSZX(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	probeSZX := make(chan blockwise.SZX, 1)
+	s := NewServer(options.WithBlockwiseMaxRequestBodySize(4096), options.WithWireTap(func(dir config.Direction, data []byte, _ net.Addr) {
+		if dir != config.DirectionReceived {
+			return
+		}
+		msg := pool.NewMessage(context.Background())
+		if _, errU := msg.UnmarshalWithDecoder(coder.DefaultCoder, data); errU != nil {
+			return
+		}
+		block, errB := msg.GetOptionUint32(message.Block1)
+		if errB != nil {
+			return
+		}
+		szx, _, _, errD := blockwise.DecodeBlockOption(block)
+		assert.NoError(t, errD)
+		select {
+		case probeSZX <- szx:
+		default:
+		}
+	}))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := Dial(l.LocalAddr().String(), options.WithBlockwise(true, blockwise.SZX64, time.Second*3))
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	// the probe announces the block size configured for the connection
+	err = cc.ProbeUpload(ctx, codes.PUT, "/a", 1000)
+	require.NoError(t, err)
+	select {
+	case szx := <-probeSZX:
+		require.Equal(t, blockwise.SZX64, szx)
+	case <-ctx.Done():
+		require.NoError(t, ctx.Err())
+	}
+}
+
+func TestConnWriteMessages(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	received := make(chan string, 3)
+	s := NewServer(options.WithHandlerFunc(func(w *responsewriter.ResponseWriter[*client.Conn], r *pool.Message) {
+		body, errB := r.ReadBody()
+		assert.NoError(t, errB)
+		received <- string(body)
+		errS := w.SetResponse(codes.Changed, message.TextPlain, nil)
+		assert.NoError(t, errS)
+	}))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := Dial(l.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	want := []string{"a", "b", "c"}
+	reqs := make([]*pool.Message, 0, len(want))
+	for _, v := range want {
+		req, errR := cc.NewPostRequest(ctx, "/a", message.TextPlain, bytes.NewReader([]byte(v)))
+		require.NoError(t, errR)
+		req.SetType(message.NonConfirmable)
+		reqs = append(reqs, req)
+	}
+	err = cc.WriteMessages(reqs)
+	require.NoError(t, err)
+	got := make([]string, 0, len(want))
+	for range want {
+		select {
+		case v := <-received:
+			got = append(got, v)
+		case <-ctx.Done():
+			require.NoError(t, ctx.Err())
+		}
+	}
+	require.ElementsMatch(t, want, got)
+}
+
+func TestConnWriteMessagesConfirmable(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	received := make(chan string, 4)
+	s := NewServer(options.WithHandlerFunc(func(w *responsewriter.ResponseWriter[*client.Conn], r *pool.Message) {
+		body, errB := r.ReadBody()
+		assert.NoError(t, errB)
+		received <- string(body)
+		errS := w.SetResponse(codes.Changed, message.TextPlain, nil)
+		assert.NoError(t, errS)
+	}))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	// with NSTART=1 the confirmable requests must not wait for each other's outstanding interaction
+	cc, err := Dial(l.LocalAddr().String(), options.WithTransmission(1, time.Second, 4))
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	types := map[string]message.Type{
+		"a": message.Confirmable,
+		"b": message.Confirmable,
+		"c": message.Confirmable,
+		"d": message.NonConfirmable,
+	}
+	reqs := make([]*pool.Message, 0, len(types))
+	for _, v := range []string{"a", "b", "c", "d"} {
+		req, errR := cc.NewPostRequest(ctx, "/a", message.TextPlain, bytes.NewReader([]byte(v)))
+		require.NoError(t, errR)
+		req.SetType(types[v])
+		reqs = append(reqs, req)
+	}
+	err = cc.WriteMessages(reqs)
+	require.NoError(t, err)
+	// the confirmable messages were acknowledged, so they were handled by the server before WriteMessages returned
+	require.GreaterOrEqual(t, len(received), 3)
+	got := make([]string, 0, len(types))
+	for len(got) < len(types) {
+		select {
+		case v := <-received:
+			got = append(got, v)
+		case <-ctx.Done():
+			require.NoError(t, ctx.Err())
+		}
+	}
+	require.ElementsMatch(t, []string{"a", "b", "c", "d"}, got)
+}
+
+func TestClientInactiveMonitor(t *testing.T) {
+	var inactivityDetected atomic.Bool
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+
+	ld, err := coapNet.NewListenUDP("udp4", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := ld.Close()
+		require.NoError(t, errC)
+	}()
+
+	checkClose := semaphore.NewWeighted(1)
+	err = checkClose.Acquire(ctx, 1)
+	require.NoError(t, err)
+
+	sd := NewServer(
+		options.WithPeriodicRunner(periodic.New(ctx.Done(), time.Millisecond*10)),
+	)
+
+	var serverWg sync.WaitGroup
+	defer func() {
+		sd.Stop()
+		serverWg.Wait()
+	}()
+	serverWg.Add(1)
+	go func() {
+		defer serverWg.Done()
+		errS := sd.Serve(ld)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := Dial(
+		ld.LocalAddr().String(),
+		options.WithInactivityMonitor(100*time.Millisecond, func(cc *client.Conn) {
+			require.False(t, inactivityDetected.Load())
+			inactivityDetected.Store(true)
+			errC := cc.Close()
+			require.NoError(t, errC)
+		}),
+		options.WithPeriodicRunner(periodic.New(ctx.Done(), time.Millisecond*100)),
+		options.WithReceivedMessageQueueSize(32),
+		options.WithProcessReceivedMessageFunc(func(req *pool.Message, cc *client.Conn, handler config.HandlerFunc[*client.Conn]) {
+			cc.ProcessReceivedMessageWithHandler(req, handler)
+		}),
+	)
+	require.NoError(t, err)
+	cc.AddOnClose(func() {
+		checkClose.Release(1)
+	})
+
+	// send ping to create serverside connection
+	ctxPing, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	err = cc.Ping(ctxPing)
+	require.NoError(t, err)
+
+	err = cc.Ping(ctx)
+	require.NoError(t, err)
+
+	// wait for fire inactivity
+	time.Sleep(time.Second * 2)
+
+	err = checkClose.Acquire(ctx, 1)
+	require.NoError(t, err)
+	require.True(t, inactivityDetected.Load())
+}
+
+func TestClientKeepAliveMonitor(t *testing.T) {
+	var inactivityDetected atomic.Bool
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+
+	ld, err := coapNet.NewListenUDP("udp4", "")
+	require.NoError(t, err)
+
+	var serverWg sync.WaitGroup
+	serverWg.Add(1)
+	defer serverWg.Wait()
+	defer func() {
+		errC := ld.Close()
+		require.NoError(t, errC)
+	}()
+	go func() {
+		defer serverWg.Done()
+		for {
+			_, _, errR := ld.ReadWithContext(ctx, make([]byte, 1024))
+			if errR != nil {
+				if errors.Is(errR, net.ErrClosed) {
+					return
+				}
+			}
+			assert.NoError(t, errR)
+		}
+	}()
+
+	checkClose := semaphore.NewWeighted(1)
+	err = checkClose.Acquire(ctx, 1)
+	require.NoError(t, err)
+	cc, err := Dial(
+		ld.LocalAddr().String(),
+		options.WithKeepAlive(3, 100*time.Millisecond, func(cc *client.Conn) {
+			require.False(t, inactivityDetected.Load())
+			inactivityDetected.Store(true)
+			errC := cc.Close()
+			require.NoError(t, errC)
+		}),
+		options.WithPeriodicRunner(periodic.New(ctx.Done(), time.Millisecond*10)),
+	)
+	require.NoError(t, err)
+	cc.AddOnClose(func() {
+		checkClose.Release(1)
+	})
+
+	// send ping to create server side connection
+	ctxPing, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	_, err = cc.Get(ctxPing, "/tmp")
+	require.Error(t, err)
+
+	err = checkClose.Acquire(ctx, 1)
+	require.NoError(t, err)
+	require.True(t, inactivityDetected.Load())
+}
+
+func TestConnWellKnownCore(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	h := mux.HandlerFunc(func(w mux.ResponseWriter, _ *mux.Message) {
+		errH := w.SetResponse(codes.Content, message.TextPlain, nil)
+		require.NoError(t, errH)
+	})
+	m := mux.NewRouter()
+	err = m.HandleWithAttributes("/sensors/temp", h,
+		linkformat.Param{Key: "rt", Value: "temperature-c", HasValue: true},
+		linkformat.Param{Key: "if", Value: "sensor", HasValue: true},
+		linkformat.Param{Key: "ct", Value: "0", HasValue: true},
+	)
+	require.NoError(t, err)
+	err = m.HandleWithAttributes("/sensors/light", h, linkformat.Param{Key: "rt", Value: "light-lux core.s", HasValue: true})
+	require.NoError(t, err)
+	err = m.Handle("/lights/{id}", h)
+	require.NoError(t, err)
+	err = m.EnableWellKnownCore()
+	require.NoError(t, err)
+
+	s := NewServer(options.WithMux(m))
+	defer s.Stop()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := Dial(l.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	discover := func(query ...string) string {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		var opts message.Options
+		for _, q := range query {
+			buf := make([]byte, len(q))
+			opts, _, err = opts.AddString(buf, message.URIQuery, q)
+			require.NoError(t, err)
+		}
+		resp, errG := cc.Get(ctx, mux.WellKnownCorePath, opts...)
+		require.NoError(t, errG)
+		require.Equal(t, codes.Content, resp.Code())
+		ct, errG := resp.ContentFormat()
+		require.NoError(t, errG)
+		require.Equal(t, message.AppLinkFormat, ct)
+		body, errG := resp.ReadBody()
+		require.NoError(t, errG)
+		return string(body)
+	}
+	require.Equal(t, `</sensors/light>;rt="light-lux core.s",</sensors/temp>;rt="temperature-c";if="sensor";ct=0`, discover())
+	require.Equal(t, `</sensors/temp>;rt="temperature-c";if="sensor";ct=0`, discover("rt=temperature*"))
+	require.Equal(t, `</sensors/light>;rt="light-lux core.s"`, discover("href=/sensors/l*"))
+	require.Equal(t, "", discover("if=actuator"))
+	// the resource types are matched by the tokens of the space-separated list
+	require.Equal(t, `</sensors/light>;rt="light-lux core.s"`, discover("rt=core.s"))
+	require.Equal(t, `</sensors/light>;rt="light-lux core.s"`, discover("rt=core*"))
+
+	err = m.HandleRemove("/sensors/light")
+	require.NoError(t, err)
+	require.Equal(t, `</sensors/temp>;rt="temperature-c";if="sensor";ct=0`, discover())
+}
+
+func TestConnBlockwiseUploadCanceled(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	m := mux.NewRouter()
+	err = m.Handle("/a", mux.HandlerFunc(func(w mux.ResponseWriter, _ *mux.Message) {
+		errS := w.SetResponse(codes.Changed, message.TextPlain, nil)
+		require.NoError(t, errS)
+	}))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	uploadCtx, cancelUpload := context.WithCancel(ctx)
+	defer cancelUpload()
+	s := NewServer(options.WithMux(m), options.WithRequestMonitor(func(_ *client.Conn, req *pool.Message) (bool, error) {
+		block, errG := req.GetOptionUint32(message.Block1)
+		if errG != nil || uploadCtx.Err() != nil {
+			return false, nil
+		}
+		_, num, _, errD := blockwise.DecodeBlockOption(block)
+		require.NoError(t, errD)
+		if num == 2 {
+			// cancel the upload in the middle of the transfer
+			cancelUpload()
+			return true, nil
+		}
+		return false, nil
+	}))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := Dial(l.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+	err = cc.Ping(ctx)
+	require.NoError(t, err)
+
+	numGoroutines := runtime.NumGoroutine()
+	_, err = cc.Post(uploadCtx, "/a", message.AppOctets, bytes.NewReader(make([]byte, 8000)))
+	require.ErrorIs(t, err, context.Canceled)
+	// all goroutines of the transfer are finished, require.Eventually is not used because it runs the condition in the goroutine
+	for i := 0; i < 100 && runtime.NumGoroutine() > numGoroutines; i++ {
+		time.Sleep(time.Millisecond * 10)
+	}
+	require.LessOrEqual(t, runtime.NumGoroutine(), numGoroutines)
+
+	// the connection can be used for the next transfer
+	resp, err := cc.Post(ctx, "/a", message.AppOctets, bytes.NewReader(make([]byte, 8000)))
+	require.NoError(t, err)
+	require.Equal(t, codes.Changed, resp.Code())
+}
+
+func TestConnPathRewriter(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	m := mux.NewRouter()
+	err = m.Handle("/lights/{id}", mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		errH := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte(r.RouteParams.Vars["id"])))
+		require.NoError(t, errH)
+	}))
+	require.NoError(t, err)
+	m.SetPathRewriter(func(path string) string {
+		return strings.TrimPrefix(path, "/v1")
+	})
+
+	s := NewServer(options.WithMux(m))
+	defer s.Stop()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := Dial(l.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	get := func(path string) (codes.Code, string) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		resp, errG := cc.Get(ctx, path)
+		require.NoError(t, errG)
+		if resp.Body() == nil {
+			return resp.Code(), ""
+		}
+		body, errG := resp.ReadBody()
+		require.NoError(t, errG)
+		return resp.Code(), string(body)
+	}
+
+	code, body := get("/v1/lights/1")
+	require.Equal(t, codes.Content, code)
+	require.Equal(t, "1", body)
+	code, body = get("/lights/2")
+	require.Equal(t, codes.Content, code)
+	require.Equal(t, "2", body)
+
+	m.SetPathRewriter(nil)
+	code, _ = get("/v1/lights/1")
+	require.Equal(t, codes.NotFound, code)
+}
+
+func TestConnHandleFormat(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	m := mux.NewRouter()
+	err = m.HandleFormat("/a", message.TextPlain, mux.HandlerFunc(func(w mux.ResponseWriter, _ *mux.Message) {
+		errH := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("text")))
+		require.NoError(t, errH)
+	}))
+	require.NoError(t, err)
+	err = m.HandleFormat("/a", message.AppJSON, mux.HandlerFunc(func(w mux.ResponseWriter, _ *mux.Message) {
+		errH := w.SetResponse(codes.Content, message.AppJSON, bytes.NewReader([]byte(`"json"`)))
+		require.NoError(t, errH)
+	}))
+	require.NoError(t, err)
+
+	s := NewServer(options.WithMux(m))
+	defer s.Stop()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := Dial(l.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	get := func(opts ...message.Option) (codes.Code, string) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		resp, errG := cc.Get(ctx, "/a", opts...)
+		require.NoError(t, errG)
+		if resp.Body() == nil {
+			return resp.Code(), ""
+		}
+		body, errG := resp.ReadBody()
+		require.NoError(t, errG)
+		return resp.Code(), string(body)
+	}
+	acceptCBOR := message.Option{ID: message.Accept, Value: []byte{byte(message.AppCBOR)}}
+
+	code, body := get()
+	require.Equal(t, codes.Content, code)
+	require.Equal(t, "text", body)
+	code, body = get(message.Option{ID: message.Accept, Value: []byte{byte(message.AppJSON)}})
+	require.Equal(t, codes.Content, code)
+	require.Equal(t, `"json"`, body)
+	code, _ = get(acceptCBOR)
+	require.Equal(t, codes.NotAcceptable, code)
+
+	err = m.HandleFormatDefault("/a", mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		accept, errA := r.Accept()
+		require.NoError(t, errA)
+		errH := w.SetResponse(codes.Content, accept, bytes.NewReader([]byte("transcoded")))
+		require.NoError(t, errH)
+	}))
+	require.NoError(t, err)
+	code, body = get(acceptCBOR)
+	require.Equal(t, codes.Content, code)
+	require.Equal(t, "transcoded", body)
+	// the registered formats are kept
+	code, body = get()
+	require.Equal(t, codes.Content, code)
+	require.Equal(t, "text", body)
+}
+
+func TestConnHandleHost(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	newRouter := func(name string) *mux.Router {
+		r := mux.NewRouter()
+		r.HandleFunc("/a", func(w mux.ResponseWriter, _ *mux.Message) {
+			errH := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte(name)))
+			require.NoError(t, errH)
+		})
+		return r
+	}
+	m := newRouter("default")
+	err = m.HandleHost("sensor.local", newRouter("sensor"))
+	require.NoError(t, err)
+	err = m.HandleHost("sensor.local:5684", newRouter("sensor-5684"))
+	require.NoError(t, err)
+
+	s := NewServer(options.WithMux(m))
+	defer s.Stop()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := Dial(l.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	get := func(opts ...message.Option) string {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		defer cancel()
+		resp, errG := cc.Get(ctx, "/a", opts...)
+		require.NoError(t, errG)
+		require.Equal(t, codes.Content, resp.Code())
+		body, errG := resp.ReadBody()
+		require.NoError(t, errG)
+		return string(body)
+	}
+	host := func(h string) message.Option {
+		return message.Option{ID: message.URIHost, Value: []byte(h)}
+	}
+	port := func(p uint16) message.Option {
+		return message.Option{ID: message.URIPort, Value: []byte{byte(p >> 8), byte(p)}}
+	}
+
+	require.Equal(t, "default", get())
+	require.Equal(t, "default", get(host("unknown.local")))
+	require.Equal(t, "sensor", get(host("Sensor.Local")))
+	require.Equal(t, "sensor", get(host("sensor.local"), port(5683)))
+	require.Equal(t, "sensor-5684", get(host("sensor.local"), port(5684)))
+
+	err = m.HandleHostRemove("sensor.local")
+	require.NoError(t, err)
+	require.Equal(t, "default", get(host("sensor.local")))
+}
+
+func TestConnBadOption(t *testing.T) {
+	const criticalOptionID = message.OptionID(65001)
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	m := mux.NewRouter()
+	m.HandleFunc("/a", func(w mux.ResponseWriter, _ *mux.Message) {
+		errH := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("a")))
+		require.NoError(t, errH)
+	})
+	err = m.HandleCriticalOption(message.OptionID(65002))
+	require.Error(t, err)
+
+	s := NewServer(options.WithMux(m))
+	defer s.Stop()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := Dial(l.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	get := func(opts ...message.Option) (codes.Code, string) {
+		ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+		defer cancel()
+		resp, errG := cc.Get(ctx, "/a", opts...)
+		require.NoError(t, errG)
+		body, errG := resp.ReadBody()
+		require.NoError(t, errG)
+		return resp.Code(), string(body)
+	}
+
+	// unknown elective option is ignored
+	code, _ := get(message.Option{ID: criticalOptionID + 1, Value: []byte{1}})
+	require.Equal(t, codes.Content, code)
+
+	// the check is opt-in
+	code, _ = get(message.Option{ID: criticalOptionID, Value: []byte{1}})
+	require.Equal(t, codes.Content, code)
+
+	m.EnableCriticalOptionCheck()
+	// the standard critical option is understood
+	code, _ = get(message.Option{ID: message.IfNoneMatch})
+	require.Equal(t, codes.Content, code)
+	code, body := get(message.Option{ID: criticalOptionID, Value: []byte{1}})
+	require.Equal(t, codes.BadOption, code)
+	require.Equal(t, "unrecognized critical option Option(65001)", body)
+
+	err = m.HandleCriticalOption(criticalOptionID)
+	require.NoError(t, err)
+	code, _ = get(message.Option{ID: criticalOptionID, Value: []byte{1}})
+	require.Equal(t, codes.Content, code)
+
+	err = m.HandleCriticalOptionRemove(criticalOptionID)
+	require.NoError(t, err)
+	code, _ = get(message.Option{ID: criticalOptionID, Value: []byte{1}})
+	require.Equal(t, codes.BadOption, code)
+	err = m.HandleCriticalOptionRemove(criticalOptionID)
+	require.Error(t, err)
+}
+
+func TestConnRequestInterface(t *testing.T) {
+	ifaces, err := net.Interfaces()
+	require.NoError(t, err)
+	loopbackIndex := 0
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			loopbackIndex = iface.Index
+			break
+		}
+	}
+	if loopbackIndex == 0 {
+		t.Skip("loopback interface not found")
+	}
+
+	l, err := coapNet.NewListenUDP("udp4", "127.0.0.1:")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	s := NewServer(options.WithHandlerFunc(func(w *responsewriter.ResponseWriter[*client.Conn], r *pool.Message) {
+		m := mux.Message{Message: r}
+		errH := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte(strconv.Itoa(m.Interface()))))
+		assert.NoError(t, errH)
+	}))
+	defer s.Stop()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := Dial(l.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	resp, err := cc.Get(ctx, "/a")
+	require.NoError(t, err)
+	body, err := resp.ReadBody()
+	require.NoError(t, err)
+	require.Equal(t, strconv.Itoa(loopbackIndex), string(body))
+}
+
+type testContextKey struct{}
+
+func TestConnMiddlewareContext(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	m := mux.NewRouter()
+	m.Use(mux.ContextMiddleware(func(ctx context.Context, r *mux.Message) context.Context {
+		return context.WithValue(ctx, testContextKey{}, r.RouteParams.Path)
+	}))
+	var connCtxValue atomic.Bool
+	m.Use(func(next mux.Handler) mux.Handler {
+		return mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+			// the context of the connection is the parent of the request context
+			connCtxValue.Store(r.Context().Value(testContextKey{}) != nil && w.Conn().Context().Value(testContextKey{}) == nil)
+			next.ServeCOAP(w, r)
+		})
+	})
+	err = m.Handle("/a", mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		v, _ := r.Context().Value(testContextKey{}).(string)
+		// outgoing requests use the request context
+		errP := w.Conn().Ping(r.Context())
+		assert.NoError(t, errP)
+		errH := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte(v)))
+		assert.NoError(t, errH)
+	}))
+	require.NoError(t, err)
+
+	s := NewServer(options.WithMux(m))
+	defer s.Stop()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := Dial(l.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	resp, err := cc.Get(ctx, "/a")
+	require.NoError(t, err)
+	require.Equal(t, codes.Content, resp.Code())
+	body, err := resp.ReadBody()
+	require.NoError(t, err)
+	require.Equal(t, "/a", string(body))
+	require.True(t, connCtxValue.Load())
+}
+
+// countingPacketConn hides the *net.UDPConn behind net.PacketConn, as a custom transport would.
+type countingPacketConn struct {
+	net.PacketConn
+	written    atomic.Int32
+	failWrites atomic.Bool
+}
+
+func (c *countingPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	c.written.Inc()
+	if c.failWrites.Load() {
+		return 0, errors.New("write failed")
+	}
+	return c.PacketConn.WriteTo(b, addr)
+}
+
+type testPacketDialer struct {
+	conn *countingPacketConn
+}
+
+func (d *testPacketDialer) DialPacket(_ context.Context, network, _ string) (net.PacketConn, error) {
+	c, err := net.ListenPacket(network, "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	d.conn = &countingPacketConn{PacketConn: c}
+	return d.conn, nil
+}
+
+func TestConnPacketDialer(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp4", "127.0.0.1:")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	m := mux.NewRouter()
+	err = m.Handle("/a", mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("hello")))
+		require.NoError(t, errS)
+	}))
+	require.NoError(t, err)
+
+	s := NewServer(options.WithMux(m))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	dialer := &testPacketDialer{}
+	cc, err := Dial(l.LocalAddr().String(), options.WithPacketDialer(dialer), options.WithNetwork("udp4"))
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+	require.Nil(t, cc.NetConn())
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	resp, err := cc.Get(ctx, "/a")
+	require.NoError(t, err)
+	require.Equal(t, codes.Content, resp.Code())
+	body, err := resp.ReadBody()
+	require.NoError(t, err)
+	require.Equal(t, []byte("hello"), body)
+	require.Greater(t, dialer.conn.written.Load(), int32(0))
+}
+
+// remotePacketConn reports the peer address, so the target is not resolved by Dial.
+type remotePacketConn struct {
+	net.PacketConn
+	raddr net.Addr
+}
+
+func (c *remotePacketConn) RemoteAddr() net.Addr {
+	return c.raddr
+}
+
+type remotePacketDialer struct {
+	raddr net.Addr
+}
+
+func (d *remotePacketDialer) DialPacket(_ context.Context, network, _ string) (net.PacketConn, error) {
+	c, err := net.ListenPacket(network, "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	return &remotePacketConn{PacketConn: c, raddr: d.raddr}, nil
+}
+
+func TestConnPacketDialerRemoteAddr(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp4", "127.0.0.1:")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	s := NewServer(options.WithHandlerFunc(func(w *responsewriter.ResponseWriter[*client.Conn], _ *pool.Message) {
+		errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("hello")))
+		assert.NoError(t, errS)
+	}))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	// the target is resolved only by the dialer, e.g. in the tunnel
+	dialer := &remotePacketDialer{raddr: l.LocalAddr()}
+	cc, err := Dial("coap-peer.invalid:5683", options.WithPacketDialer(dialer), options.WithNetwork("udp4"))
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+	require.Equal(t, l.LocalAddr().String(), cc.RemoteAddr().String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	resp, err := cc.Get(ctx, "/a")
+	require.NoError(t, err)
+	require.Equal(t, codes.Content, resp.Code())
+}
+
+func TestConnExchangeLifetime(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+
+	cc, err := Dial(l.LocalAddr().String(), options.WithTransmission(1, time.Second, 2), options.WithAckRandomFactor(1))
+	require.NoError(t, err)
+	require.Equal(t, time.Second*204, cc.Transmission().ExchangeLifetime())
+	err = cc.Close()
+	require.NoError(t, err)
+	<-cc.Done()
+
+	cc, err = Dial(l.LocalAddr().String(), options.WithExchangeLifetime(time.Second*10))
+	require.NoError(t, err)
+	require.Equal(t, time.Second*10, cc.Transmission().ExchangeLifetime())
+	err = cc.Close()
+	require.NoError(t, err)
+	<-cc.Done()
+}
+
+func TestConnMessagePoolExhausted(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+
+	mp := pool.New(1, 1024, pool.WithExhaustedPolicy(pool.ExhaustedError))
+	cc, err := Dial(l.LocalAddr().String(), options.WithMessagePool(mp))
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	req, err := cc.NewGetRequest(context.Background(), "/a")
+	require.NoError(t, err)
+	_, err = cc.NewGetRequest(context.Background(), "/b")
+	require.ErrorIs(t, err, pool.ErrPoolExhausted)
+	cc.ReleaseMessage(req)
+	req, err = cc.NewGetRequest(context.Background(), "/b")
+	require.NoError(t, err)
+	cc.ReleaseMessage(req)
+}
+
+type dropCollector struct {
+	metrics.NilCollector
+	dropped atomic.Int32
+}
+
+func (c *dropCollector) MessageDropped() {
+	c.dropped.Inc()
+}
+
+func TestConnMessagePoolExhaustedDropsReceived(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	s := NewServer(options.WithHandlerFunc(func(w *responsewriter.ResponseWriter[*client.Conn], _ *pool.Message) {
+		errS := w.SetResponse(codes.Content, message.TextPlain, nil)
+		assert.NoError(t, errS)
+	}))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	// the response is dropped instead of blocking the reading of the connection
+	collector := &dropCollector{}
+	mp := pool.New(3, 1024, pool.WithExhaustedPolicy(pool.ExhaustedBlock))
+	cc, err := Dial(l.LocalAddr().String(), options.WithMessagePool(mp), options.WithMetrics(collector),
+		options.WithBlockwise(false, blockwise.SZX1024, time.Second))
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*300)
+	defer cancel()
+	var held []*pool.Message
+	for i := 0; i < 2; i++ {
+		m, errA := cc.TryAcquireMessage(ctx)
+		require.NoError(t, errA)
+		held = append(held, m)
+	}
+	req, err := cc.NewGetRequest(ctx, "/a")
+	require.NoError(t, err)
+	req.SetType(message.NonConfirmable)
+	_, err = cc.Do(req)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	cc.ReleaseMessage(req)
+	for _, m := range held {
+		cc.ReleaseMessage(m)
+	}
+	require.Positive(t, collector.dropped.Load())
+	require.Positive(t, mp.NumExhausted())
+	require.NoError(t, cc.Context().Err())
+
+	ctx, cancel = context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	resp, err := cc.Get(ctx, "/a")
+	require.NoError(t, err)
+	require.Equal(t, codes.Content, resp.Code())
+}
+
+func TestConnNonConfirmableResponseType(t *testing.T) {
+	m := mux.NewRouter()
+	err := m.Handle("/a", mux.HandlerFunc(func(w mux.ResponseWriter, _ *mux.Message) {
+		errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("a")))
+		require.NoError(t, errS)
+	}))
+	require.NoError(t, err)
+	err = m.Handle("/con", mux.HandlerFunc(func(w mux.ResponseWriter, _ *mux.Message) {
+		errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("con")))
+		require.NoError(t, errS)
+		w.Message().SetType(message.Confirmable)
+	}))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		opts     []server.Option
+		path     string
+		reqType  message.Type
+		wantType message.Type
+	}{
+		{
+			name:     "confirmable",
+			path:     "/a",
+			reqType:  message.Confirmable,
+			wantType: message.Acknowledgement,
+		},
+		{
+			name:     "non-confirmable",
+			path:     "/a",
+			reqType:  message.NonConfirmable,
+			wantType: message.NonConfirmable,
+		},
+		{
+			name:     "non-confirmable set by handler",
+			path:     "/con",
+			reqType:  message.NonConfirmable,
+			wantType: message.Confirmable,
+		},
+		{
+			name:     "non-confirmable set by option",
+			opts:     []server.Option{options.WithNonConfirmableResponseType(message.Confirmable)},
+			path:     "/a",
+			reqType:  message.NonConfirmable,
+			wantType: message.Confirmable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, errL := coapNet.NewListenUDP("udp", "")
+			require.NoError(t, errL)
+			defer func() {
+				errC := l.Close()
+				require.NoError(t, errC)
+			}()
+			var wg sync.WaitGroup
+			defer wg.Wait()
+
+			s := NewServer(append([]server.Option{options.WithMux(m)}, tt.opts...)...)
+			defer s.Stop()
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errS := s.Serve(l)
+				assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+			}()
+
+			cc, errD := Dial(l.LocalAddr().String())
+			require.NoError(t, errD)
+			defer func() {
+				errC := cc.Close()
+				require.NoError(t, errC)
+				<-cc.Done()
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+			defer cancel()
+			req, errR := cc.NewGetRequest(ctx, tt.path)
+			require.NoError(t, errR)
+			defer cc.ReleaseMessage(req)
+			req.SetType(tt.reqType)
+			resp, errD := cc.Do(req)
+			require.NoError(t, errD)
+			require.Equal(t, codes.Content, resp.Code())
+			require.Equal(t, tt.wantType, resp.Type())
+		})
+	}
+}
+
+type recordingReaderAt struct {
+	data    []byte
+	mutex   sync.Mutex
+	offsets []int64
+	maxRead int
+}
+
+func (r *recordingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	r.mutex.Lock()
+	r.offsets = append(r.offsets, off)
+	if len(p) > r.maxRead {
+		r.maxRead = len(p)
+	}
+	r.mutex.Unlock()
+	return bytes.NewReader(r.data).ReadAt(p, off)
+}
+
+func TestConnBlockwiseReaderAt(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	payload := make([]byte, 5000)
+	for i := range payload {
+		payload[i] = byte(i % 251)
+	}
+	file := &recordingReaderAt{data: payload}
+
+	m := mux.NewRouter()
+	err = m.Handle("/file", mux.HandlerFunc(func(w mux.ResponseWriter, _ *mux.Message) {
+		errS := w.SetResponse(codes.Content, message.AppOctets, nil)
+		require.NoError(t, errS)
+		w.Message().SetContentFormat(message.AppOctets)
+		w.Message().SetBodyReaderAt(file, int64(len(payload)))
+	}))
+	require.NoError(t, err)
+
+	s := NewServer(options.WithMux(m))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := Dial(l.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	resp, err := cc.Get(ctx, "/file")
+	require.NoError(t, err)
+	require.Equal(t, codes.Content, resp.Code())
+	body, err := resp.ReadBody()
+	require.NoError(t, err)
+	require.Equal(t, payload, body)
+
+	file.mutex.Lock()
+	defer file.mutex.Unlock()
+	// each block is read at its offset, the body is not read at once
+	require.LessOrEqual(t, file.maxRead, 1024)
+	for _, off := range file.offsets {
+		require.Zero(t, off%1024, "offset %v", off)
+	}
+}
+
+func TestConnSchedulePeriodic(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	var inProgress, maxInProgress, served atomic.Int32
+	m := mux.NewRouter()
+	m.HandleFunc("/a", func(w mux.ResponseWriter, _ *mux.Message) {
+		n := inProgress.Inc()
+		defer inProgress.Dec()
+		if n > maxInProgress.Load() {
+			maxInProgress.Store(n)
+		}
+		// the response is slower than the interval
+		time.Sleep(time.Millisecond * 30)
+		errH := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte{byte(served.Inc())}))
+		require.NoError(t, errH)
+	})
+
+	s := NewServer(options.WithMux(m))
+	defer s.Stop()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := Dial(l.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	req, err := cc.NewGetRequest(ctx, "/a")
+	require.NoError(t, err)
+	defer cc.ReleaseMessage(req)
+
+	for _, policy := range []netClient.OverlapPolicy{netClient.OverlapSkip, netClient.OverlapQueue} {
+		t.Run(policy.String(), func(t *testing.T) {
+			responses := make(chan byte, 16)
+			stop, errS := cc.SchedulePeriodic(time.Millisecond*10, Timeout, policy, req, func(resp *pool.Message, err error) {
+				if !assert.NoError(t, err) {
+					return
+				}
+				body, errR := resp.ReadBody()
+				assert.NoError(t, errR)
+				responses <- body[0]
+			})
+			require.NoError(t, errS)
+			var last byte
+			for i := 0; i < 3; i++ {
+				select {
+				case v := <-responses:
+					require.Greater(t, v, last)
+					last = v
+				case <-ctx.Done():
+					require.NoError(t, ctx.Err())
+				}
+			}
+			stop()
+			time.Sleep(time.Millisecond * 50)
+			n := served.Load()
+			time.Sleep(time.Millisecond * 50)
+			require.Equal(t, n, served.Load())
+			require.Equal(t, int32(1), maxInProgress.Load())
+		})
+	}
+
+	// the request slower than the interval is canceled by the default timeout
+	errs := make(chan error, 16)
+	stop, err := cc.SchedulePeriodic(time.Millisecond*10, 0, netClient.OverlapSkip, req, func(resp *pool.Message, err error) {
+		assert.Nil(t, resp)
+		errs <- err
+	})
+	require.NoError(t, err)
+	select {
+	case errH := <-errs:
+		require.ErrorIs(t, errH, context.DeadlineExceeded)
+	case <-ctx.Done():
+		require.NoError(t, ctx.Err())
+	}
+	stop()
+
+	_, err = cc.SchedulePeriodic(0, 0, netClient.OverlapSkip, req, func(*pool.Message, error) {})
+	require.Error(t, err)
+}
+
+func TestConnDeduplication(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          []server.Option
+		wantA         int32
+		wantTelemetry int32
+	}{
+		{
+			name:          "default",
+			wantA:         1,
+			wantTelemetry: 1,
+		},
+		{
+			name:          "confirmable only",
+			opts:          []server.Option{options.WithDeduplication(false)},
+			wantA:         2,
+			wantTelemetry: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var a, telemetry atomic.Int32
+			m := mux.NewRouter()
+			err := m.Handle("/a", mux.HandlerFunc(func(w mux.ResponseWriter, _ *mux.Message) {
+				a.Inc()
+				errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("a")))
+				require.NoError(t, errS)
+			}))
+			require.NoError(t, err)
+			err = m.Handle("/telemetry", mux.HandlerFunc(func(mux.ResponseWriter, *mux.Message) {
+				telemetry.Inc()
+			}))
+			require.NoError(t, err)
+
+			l, errL := coapNet.NewListenUDP("udp", "")
+			require.NoError(t, errL)
+			defer func() {
+				errC := l.Close()
+				require.NoError(t, errC)
+			}()
+			var wg sync.WaitGroup
+			defer wg.Wait()
+
+			s := NewServer(append([]server.Option{options.WithMux(m)}, tt.opts...)...)
+			defer s.Stop()
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errS := s.Serve(l)
+				assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+			}()
+
+			// the message ID of the request with the payload is not overwritten without the blockwise transfer
+			cc, errD := Dial(l.LocalAddr().String(), options.WithBlockwise(false, blockwise.SZX1024, Timeout))
+			require.NoError(t, errD)
+			defer func() {
+				errC := cc.Close()
+				require.NoError(t, errC)
+				<-cc.Done()
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+			defer cancel()
+			token, errT := message.GetToken()
+			require.NoError(t, errT)
+			for i := 0; i < 2; i++ {
+				req, errR := cc.NewGetRequest(ctx, "/a")
+				require.NoError(t, errR)
+				req.SetType(message.NonConfirmable)
+				req.SetMessageID(1000)
+				req.SetToken(token)
+				resp, errDo := cc.Do(req)
+				cc.ReleaseMessage(req)
+				require.NoError(t, errDo)
+				require.Equal(t, codes.Content, resp.Code())
+				require.Equal(t, message.NonConfirmable, resp.Type())
+			}
+			require.Equal(t, tt.wantA, a.Load())
+
+			for i := 0; i < 2; i++ {
+				req, errR := cc.NewPostRequest(ctx, "/telemetry", message.TextPlain, bytes.NewReader([]byte("21.5")))
+				require.NoError(t, errR)
+				req.SetType(message.NonConfirmable)
+				req.SetMessageID(1001)
+				errW := cc.WriteMessage(req)
+				cc.ReleaseMessage(req)
+				require.NoError(t, errW)
+			}
+			require.Eventually(t, func() bool {
+				return telemetry.Load() == tt.wantTelemetry
+			}, time.Second, time.Millisecond*10)
+			time.Sleep(time.Millisecond * 100)
+			require.Equal(t, tt.wantTelemetry, telemetry.Load())
+		})
+	}
+}
+
+func TestConnGetOrError(t *testing.T) {
+	m := mux.NewRouter()
+	err := m.Handle("/a", mux.HandlerFunc(func(w mux.ResponseWriter, _ *mux.Message) {
+		errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("a")))
+		require.NoError(t, errS)
+	}))
+	require.NoError(t, err)
+	err = m.Handle("/fail", mux.HandlerFunc(func(w mux.ResponseWriter, _ *mux.Message) {
+		errS := w.SetResponse(codes.ServiceUnavailable, message.TextPlain, bytes.NewReader([]byte("overloaded")))
+		require.NoError(t, errS)
+	}))
+	require.NoError(t, err)
+
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	s := NewServer(options.WithMux(m))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := Dial(l.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	resp, err := cc.GetOrError(ctx, "/a")
+	require.NoError(t, err)
+	require.Equal(t, codes.Content, resp.Code())
+
+	_, err = cc.GetOrError(ctx, "/notfound")
+	var respErr *netClient.ResponseError
+	require.ErrorAs(t, err, &respErr)
+	require.Equal(t, codes.NotFound, respErr.Code())
+	require.Equal(t, "response 4.04 (NotFound)", err.Error())
+
+	_, err = cc.DeleteOrError(ctx, "/fail")
+	require.ErrorAs(t, err, &respErr)
+	require.Equal(t, codes.ServiceUnavailable, respErr.Code())
+	require.Equal(t, "overloaded", respErr.Diagnostic())
+	require.Equal(t, "response 5.03 (ServiceUnavailable): overloaded", err.Error())
+	require.NotNil(t, respErr.Response)
+	require.Equal(t, codes.ServiceUnavailable, respErr.Response.Code())
+}
+
+type testMetrics struct {
+	mutex              sync.Mutex
+	received           map[codes.Code]int
+	sent               map[codes.Code]int
+	connections        int
+	observations       int
+	blockwiseTransfers int
+}
+
+var _ metrics.Collector = (*testMetrics)(nil)
+
+func newTestMetrics() *testMetrics {
+	return &testMetrics{
+		received: make(map[codes.Code]int),
+		sent:     make(map[codes.Code]int),
+	}
+}
+
+func (m *testMetrics) update(f func()) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	f()
+}
+
+func (m *testMetrics) MessageReceived(code codes.Code) { m.update(func() { m.received[code]++ }) }
+
+func (m *testMetrics) MessageSent(code codes.Code) { m.update(func() { m.sent[code]++ }) }
+
+func (m *testMetrics) Retransmitted() {}
+
+func (m *testMetrics) AddConnections(delta int) { m.update(func() { m.connections += delta }) }
+
+func (m *testMetrics) AddObservations(delta int) { m.update(func() { m.observations += delta }) }
+
+func (m *testMetrics) AddBlockwiseTransfers(delta int) {
+	m.update(func() { m.blockwiseTransfers += delta })
+}
+
+func (m *testMetrics) get(f func() int) int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return f()
+}
+
+func TestConnMetrics(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	m := mux.NewRouter()
+	m.HandleFunc("/a", func(w mux.ResponseWriter, r *mux.Message) {
+		errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("a")))
+		require.NoError(t, errS)
+		if r.ObserveAction() == message.ObserveRegister {
+			w.Message().SetObserve(1)
+		}
+	})
+
+	serverMetrics := newTestMetrics()
+	s := NewServer(options.WithMux(m), options.WithMetrics(serverMetrics))
+	defer s.Stop()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	clientMetrics := newTestMetrics()
+	cc, err := Dial(l.LocalAddr().String(), options.WithMetrics(clientMetrics))
+	require.NoError(t, err)
+	require.Equal(t, 1, clientMetrics.get(func() int { return clientMetrics.connections }))
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	_, err = cc.Get(ctx, "/a")
+	require.NoError(t, err)
+	require.Equal(t, 1, clientMetrics.get(func() int { return clientMetrics.sent[codes.GET] }))
+	require.Equal(t, 1, clientMetrics.get(func() int { return clientMetrics.received[codes.Content] }))
+	require.Equal(t, 1, serverMetrics.get(func() int { return serverMetrics.received[codes.GET] }))
+	require.Equal(t, 1, serverMetrics.get(func() int { return serverMetrics.sent[codes.Content] }))
+	require.Equal(t, 1, serverMetrics.get(func() int { return serverMetrics.connections }))
+
+	obs, err := cc.Observe(ctx, "/a", func(*pool.Message) {})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return serverMetrics.get(func() int { return serverMetrics.observations }) == 1
+	}, time.Second, time.Millisecond*10)
+	err = obs.Cancel(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, serverMetrics.get(func() int { return serverMetrics.observations }))
+
+	// the observation of the closed connection is released
+	_, err = cc.Observe(ctx, "/a", func(*pool.Message) {})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return serverMetrics.get(func() int { return serverMetrics.observations }) == 1
+	}, time.Second, time.Millisecond*10)
+	errC := cc.Close()
+	require.NoError(t, errC)
+	<-cc.Done()
+	require.Equal(t, 0, clientMetrics.get(func() int { return clientMetrics.connections }))
+	s.Stop()
+	require.Eventually(t, func() bool {
+		return serverMetrics.get(func() int { return serverMetrics.connections + serverMetrics.observations }) == 0
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestConnPublisher(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	publisher := mux.NewPublisher()
+	m := mux.NewRouter()
+	m.HandleFunc("/telemetry", func(w mux.ResponseWriter, r *mux.Message) {
+		switch r.ObserveAction() {
+		case message.ObserveRegister:
+			errS := publisher.SetResponse(w, r, codes.Content, message.TextPlain, bytes.NewReader([]byte("init")))
+			require.NoError(t, errS)
+		case message.ObserveDeregister:
+			publisher.Remove(w.Conn(), r.Token())
+			errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("init")))
+			require.NoError(t, errS)
+		}
+	})
+
+	s := NewServer(options.WithMux(m))
+	defer s.Stop()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+
+	const observers = 3
+	type notification struct {
+		observe uint32
+		body    string
+	}
+	notifications := make(chan notification, observers*4)
+	obss := make([]mux.Observation, 0, observers)
+	for i := 0; i < observers; i++ {
+		cc, errD := Dial(l.LocalAddr().String())
+		require.NoError(t, errD)
+		defer func() {
+			errC := cc.Close()
+			require.NoError(t, errC)
+			<-cc.Done()
+		}()
+		obs, errO := cc.Observe(ctx, "/telemetry", func(n *pool.Message) {
+			body, errR := n.ReadBody()
+			assert.NoError(t, errR)
+			if string(body) == "init" {
+				return
+			}
+			obs, errR := n.Observe()
+			assert.NoError(t, errR)
+			cf, errR := n.ContentFormat()
+			assert.NoError(t, errR)
+			assert.Equal(t, message.AppJSON, cf)
+			notifications <- notification{observe: obs, body: string(body)}
+		})
+		require.NoError(t, errO)
+		obss = append(obss, obs)
+	}
+	require.Equal(t, observers, publisher.Len())
+
+	err = publisher.Publish(codes.Content, message.AppJSON, []byte(`{"temp":21}`))
+	require.NoError(t, err)
+	var observe uint32
+	for i := 0; i < observers; i++ {
+		select {
+		case n := <-notifications:
+			require.Equal(t, `{"temp":21}`, n.body)
+			if i > 0 {
+				// all observers get the same notification
+				require.Equal(t, observe, n.observe)
+			}
+			observe = n.observe
+		case <-ctx.Done():
+			require.NoError(t, ctx.Err())
+		}
+	}
+
+	// the deregistered observer is removed
+	err = obss[0].Cancel(ctx)
+	require.NoError(t, err)
+	require.Equal(t, observers-1, publisher.Len())
+}
+
+func TestConnSeparateResponseThreshold(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	var served atomic.Int32
+	m := mux.NewRouter()
+	m.HandleFunc("/fast", func(w mux.ResponseWriter, _ *mux.Message) {
+		errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("fast")))
+		require.NoError(t, errS)
+	})
+	m.HandleFunc("/slow", func(w mux.ResponseWriter, _ *mux.Message) {
+		served.Inc()
+		// longer than the ack timeout of the client, the request is not retransmitted thanks to the empty ACK
+		time.Sleep(time.Millisecond * 300)
+		errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("slow")))
+		require.NoError(t, errS)
+	})
+
+	s := NewServer(options.WithMux(m), options.WithSeparateResponseThreshold(time.Millisecond*50))
+	defer s.Stop()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := Dial(l.LocalAddr().String(), options.WithTransmission(1, time.Millisecond*100, 4), options.WithAckRandomFactor(1))
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	resp, err := cc.Get(ctx, "/fast")
+	require.NoError(t, err)
+	// piggybacked
+	require.Equal(t, message.Acknowledgement, resp.Type())
+	body, err := resp.ReadBody()
+	require.NoError(t, err)
+	require.Equal(t, "fast", string(body))
+
+	resp, err = cc.Get(ctx, "/slow")
+	require.NoError(t, err)
+	require.Equal(t, message.Confirmable, resp.Type())
+	body, err = resp.ReadBody()
+	require.NoError(t, err)
+	require.Equal(t, "slow", string(body))
+	require.Equal(t, int32(1), served.Load())
+}
+
+func TestConnGetBlock(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	payload := make([]byte, 3000)
+	for i := range payload {
+		payload[i] = byte(i % 251)
+	}
+
+	m := mux.NewRouter()
+	err = m.Handle("/a", mux.HandlerFunc(func(w mux.ResponseWriter, _ *mux.Message) {
+		errS := w.SetResponse(codes.Content, message.AppOctets, bytes.NewReader(payload))
+		require.NoError(t, errS)
+	}))
+	require.NoError(t, err)
+	err = m.Handle("/small", mux.HandlerFunc(func(w mux.ResponseWriter, _ *mux.Message) {
+		errS := w.SetResponse(codes.Content, message.AppOctets, bytes.NewReader(payload[:100]))
+		require.NoError(t, errS)
+	}))
+	require.NoError(t, err)
+
+	s := NewServer(options.WithMux(m))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := Dial(l.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+
+	data, more, err := cc.GetBlock(ctx, "/a", 1, blockwise.SZX1024)
+	require.NoError(t, err)
+	require.True(t, more)
+	require.Equal(t, payload[1024:2048], data)
+
+	data, more, err = cc.GetBlock(ctx, "/a", 5, blockwise.SZX512)
+	require.NoError(t, err)
+	require.False(t, more)
+	require.Equal(t, payload[2560:], data)
+
+	data, more, err = cc.GetBlock(ctx, "/small", 0, blockwise.SZX1024)
+	require.NoError(t, err)
+	require.False(t, more)
+	require.Equal(t, payload[:100], data)
+
+	_, _, err = cc.GetBlock(ctx, "/b", 0, blockwise.SZX1024)
+	require.Error(t, err)
+
+	// the whole body is still reassembled by Get
+	resp, err := cc.Get(ctx, "/a")
+	require.NoError(t, err)
+	body, err := resp.ReadBody()
+	require.NoError(t, err)
+	require.Equal(t, payload, body)
+}
+
+func TestConnUnexpectedMessageHandler(t *testing.T) {
+	peer, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer func() {
+		errC := peer.Close()
+		require.NoError(t, errC)
+	}()
+
+	unexpected := make(chan int32, 4)
+	cc, err := Dial(peer.LocalAddr().String(), options.WithUnexpectedMessageHandler(func(_ *client.Conn, msg *pool.Message) {
+		require.Equal(t, message.Acknowledgement, msg.Type())
+		unexpected <- msg.MessageID()
+	}))
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	pingErr := make(chan error, 1)
+	go func() {
+		pingErr <- cc.Ping(ctx)
+	}()
+
+	buf := make([]byte, 1500)
+	n, addr, err := peer.ReadFrom(buf)
+	require.NoError(t, err)
+	var ping message.Message
+	_, err = coder.DefaultCoder.Decode(buf[:n], &ping)
+	require.NoError(t, err)
+	require.Equal(t, message.Confirmable, ping.Type)
+
+	// the unknown message ID, the acknowledgement of the ping and its late duplicate
+	for _, mid := range []int32{ping.MessageID ^ 0x100, ping.MessageID, ping.MessageID} {
+		ack := message.Message{Code: codes.Empty, Type: message.Acknowledgement, MessageID: mid}
+		size, errE := coder.DefaultCoder.Encode(ack, buf)
+		require.NoError(t, errE)
+		_, errW := peer.WriteTo(buf[:size], addr)
+		require.NoError(t, errW)
+	}
+	require.NoError(t, <-pingErr)
+	require.Equal(t, ping.MessageID^0x100, <-unexpected)
+	require.Equal(t, ping.MessageID, <-unexpected)
+	select {
+	case mid := <-unexpected:
+		require.Failf(t, "unexpected call of the handler", "message ID %v", mid)
+	case <-time.After(time.Millisecond * 100):
+	}
+}
+
+func TestConnDeferResponse(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	respondErrs := make(chan error, 2)
+	m := mux.NewRouter()
+	err = m.Handle("/a", mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		rc := mux.DeferResponse(w, r, Timeout)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// the backend responds after the client would retransmit the request without the empty ACK
+			time.Sleep(time.Millisecond * 200)
+			respondErrs <- rc.Respond(codes.Content, message.TextPlain, bytes.NewReader([]byte("done")))
+			respondErrs <- rc.Respond(codes.Content, message.TextPlain, bytes.NewReader([]byte("again")))
+		}()
+	}))
+	require.NoError(t, err)
+	err = m.Handle("/expired", mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		rc := mux.DeferResponse(w, r, time.Millisecond)
+		time.Sleep(time.Millisecond * 10)
+		errR := rc.Respond(codes.Content, message.TextPlain, nil)
+		assert.ErrorIs(t, errR, mux.ErrResponseExpired)
+		errS := w.SetResponse(codes.ServiceUnavailable, message.TextPlain, nil)
+		require.NoError(t, errS)
+	}))
+	require.NoError(t, err)
+
+	s := NewServer(options.WithMux(m), options.WithTransmission(1, time.Millisecond*100, 2))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := Dial(l.LocalAddr().String(), options.WithTransmission(1, time.Millisecond*100, 2))
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	resp, err := cc.Get(ctx, "/a")
+	require.NoError(t, err)
+	require.Equal(t, codes.Content, resp.Code())
+	require.Equal(t, message.Confirmable, resp.Type())
+	body, err := resp.ReadBody()
+	require.NoError(t, err)
+	require.Equal(t, []byte("done"), body)
+	require.NoError(t, <-respondErrs)
+	require.ErrorIs(t, <-respondErrs, mux.ErrResponseSent)
+
+	resp, err = cc.Get(ctx, "/expired")
+	require.NoError(t, err)
+	require.Equal(t, codes.ServiceUnavailable, resp.Code())
+}
+
+func TestConnTokenLength(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	m := mux.NewRouter()
+	err = m.Handle("/a", mux.HandlerFunc(func(w mux.ResponseWriter, _ *mux.Message) {
+		errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("a")))
+		require.NoError(t, errS)
+	}))
+	require.NoError(t, err)
+
+	parseErrors := make(chan error, 1)
+	s := NewServer(options.WithMux(m), options.WithReservedTokenLength(4, message.MaxEncodableTokenSize), options.WithOnParseError(func(_ []byte, _ net.Addr, err error) {
+		select {
+		case parseErrors <- err:
+		default:
+		}
+	}))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	getToken := func(n int) netClient.GetTokenFunc {
+		return func() (message.Token, error) {
+			return bytes.Repeat([]byte{0xab}, n), nil
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+
+	// the token of 12 bytes is allowed by both sides
+	cc, err := Dial(l.LocalAddr().String(), options.WithReservedTokenLength(0, message.MaxEncodableTokenSize), options.WithGetToken(getToken(12)))
+	require.NoError(t, err)
+	resp, err := cc.Get(ctx, "/a")
+	require.NoError(t, err)
+	require.Equal(t, codes.Content, resp.Code())
+	require.Equal(t, message.Token(bytes.Repeat([]byte{0xab}, 12)), resp.Token())
+	require.NoError(t, cc.Close())
+	<-cc.Done()
+
+	// the client rejects the token which violates RFC 7252 by default
+	cc, err = Dial(l.LocalAddr().String(), options.WithGetToken(getToken(12)))
+	require.NoError(t, err)
+	_, err = cc.Get(ctx, "/a")
+	require.ErrorIs(t, err, message.ErrInvalidTokenLen)
+	require.NoError(t, cc.Close())
+	<-cc.Done()
+
+	// the server rejects the short token
+	cc, err = Dial(l.LocalAddr().String(), options.WithGetToken(getToken(2)))
+	require.NoError(t, err)
+	reqCtx, reqCancel := context.WithTimeout(ctx, time.Millisecond*500)
+	defer reqCancel()
+	_, err = cc.Get(reqCtx, "/a")
+	require.Error(t, err)
+	require.ErrorIs(t, <-parseErrors, message.ErrInvalidTokenLen)
+	require.NoError(t, cc.Close())
+	<-cc.Done()
+}
+
+func TestConnOnTransportError(t *testing.T) {
+	peer, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer func() {
+		errC := peer.Close()
+		require.NoError(t, errC)
+	}()
+
+	type transportError struct {
+		err   error
+		fatal bool
+	}
+	transportErrors := make(chan transportError, 16)
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	dialer := &testPacketDialer{}
+	cc, err := Dial(peer.LocalAddr().String(), options.WithPacketDialer(dialer), options.WithNetwork("udp4"),
+		options.WithTransmission(1, time.Millisecond*50, 4),
+		options.WithPeriodicRunner(periodic.New(ctx.Done(), time.Millisecond*10)),
+		options.WithOnTransportError(func(_ *client.Conn, err error, fatal bool) {
+			select {
+			case transportErrors <- transportError{err: err, fatal: fatal}:
+			default:
+			}
+		}))
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, errG := cc.Get(ctx, "/a")
+		assert.Error(t, errG)
+	}()
+	buf := make([]byte, 1500)
+	_, addr, err := peer.ReadFrom(buf)
+	require.NoError(t, err)
+
+	// the retransmission which cannot be written doesn't close the connection
+	dialer.conn.failWrites.Store(true)
+	e := <-transportErrors
+	require.False(t, e.fatal)
+	require.Error(t, e.err)
+	require.NoError(t, cc.Context().Err())
+
+	// the malformed datagram is dropped, option delta 15 is reserved for the payload marker
+	_, err = peer.WriteTo([]byte{0x40, byte(codes.GET), 0, 1, 0xf1, 0x00}, addr)
+	require.NoError(t, err)
+	for e = range transportErrors {
+		if errors.Is(e.err, coapNet.ErrMalformedDatagram) {
+			break
+		}
+	}
+	require.False(t, e.fatal)
+	require.NoError(t, cc.Context().Err())
+
+	// the response which cannot be written closes the connection
+	_, err = peer.WriteTo([]byte{0x40, byte(codes.GET), 0, 2}, addr)
+	require.NoError(t, err)
+	for e = range transportErrors {
+		if e.fatal {
+			break
+		}
+	}
+	require.Error(t, e.err)
+	<-cc.Done()
+}
+
+func TestConnMaxConcurrentBlockwise(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	m := mux.NewRouter()
+	err = m.Handle("/a", mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		body, errB := r.ReadBody()
+		assert.NoError(t, errB)
+		assert.Len(t, body, 32)
+		errS := w.SetResponse(codes.Changed, message.TextPlain, nil)
+		assert.NoError(t, errS)
+	}))
+	require.NoError(t, err)
+
+	s := NewServer(options.WithMux(m), options.WithMaxConcurrentBlockwise(1))
+	defer s.Stop()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	peer, err := net.Dial("udp", l.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := peer.Close()
+		require.NoError(t, errC)
+	}()
+
+	var mid int32
+	sendBlock := func(token string, num int64, more bool) codes.Code {
+		block, errE := blockwise.EncodeBlockOption(blockwise.SZX16, num, more)
+		require.NoError(t, errE)
+		mid++
+		req := message.Message{
+			Code:      codes.POST,
+			Type:      message.Confirmable,
+			MessageID: mid,
+			Token:     message.Token(token),
+			Options:   make(message.Options, 0, 4),
+			Payload:   make([]byte, 16),
+		}
+		buf := make([]byte, 256)
+		req.Options, _, errE = req.Options.SetPath(buf, "/a")
+		require.NoError(t, errE)
+		req.Options, _, errE = req.Options.SetUint32(buf[64:], message.Block1, block)
+		require.NoError(t, errE)
+		data := make([]byte, 256)
+		size, errE := coder.DefaultCoder.Encode(req, data)
+		require.NoError(t, errE)
+		_, errE = peer.Write(data[:size])
+		require.NoError(t, errE)
+
+		require.NoError(t, peer.SetReadDeadline(time.Now().Add(Timeout)))
+		n, errE := peer.Read(data)
+		require.NoError(t, errE)
+		resp := message.Message{Options: make(message.Options, 0, 8)}
+		_, errE = coder.DefaultCoder.Decode(data[:n], &resp)
+		require.NoError(t, errE)
+		require.Equal(t, mid, resp.MessageID)
+		return resp.Code
+	}
+
+	require.Equal(t, codes.Continue, sendBlock("first", 0, true))
+	// the second transfer exceeds the limit while the first one is half-finished
+	require.Equal(t, codes.ServiceUnavailable, sendBlock("second", 0, true))
+	require.Equal(t, codes.Changed, sendBlock("first", 1, false))
+	require.Equal(t, codes.Continue, sendBlock("second", 0, true))
+	require.Equal(t, codes.Changed, sendBlock("second", 1, false))
+}
+
+func TestConnPerBlockTimeout(t *testing.T) {
+	peer, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() {
+		errC := peer.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	// the peer acknowledges the first block late, but within the per block timeout, and then it stalls
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		data := make([]byte, 256)
+		n, addr, errR := peer.ReadFrom(data)
+		if !assert.NoError(t, errR) {
+			return
+		}
+		req := message.Message{Options: make(message.Options, 0, 8)}
+		_, errR = coder.DefaultCoder.Decode(data[:n], &req)
+		if !assert.NoError(t, errR) {
+			return
+		}
+		block, errR := req.Options.GetUint32(message.Block1)
+		if !assert.NoError(t, errR) {
+			return
+		}
+		resp := message.Message{
+			Code:      codes.Continue,
+			Type:      message.Acknowledgement,
+			MessageID: req.MessageID,
+			Token:     req.Token,
+			Options:   make(message.Options, 0, 4),
+		}
+		buf := make([]byte, 16)
+		resp.Options, _, errR = resp.Options.SetUint32(buf, message.Block1, block)
+		if !assert.NoError(t, errR) {
+			return
+		}
+		n, errR = coder.DefaultCoder.Encode(resp, data)
+		if !assert.NoError(t, errR) {
+			return
+		}
+		time.Sleep(time.Millisecond * 150)
+		_, errR = peer.WriteTo(data[:n], addr)
+		assert.NoError(t, errR)
+	}()
+
+	cc, err := Dial(peer.LocalAddr().String(),
+		options.WithBlockwise(true, blockwise.SZX16, time.Minute),
+		options.WithPerBlockTimeout(time.Millisecond*200),
+	)
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	start := time.Now()
+	_, err = cc.Post(ctx, "/a", message.TextPlain, bytes.NewReader(make([]byte, 64)))
+	require.ErrorIs(t, err, blockwise.ErrBlockTimeout)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	// the acknowledged block restarts the timeout
+	require.Greater(t, time.Since(start), time.Millisecond*300)
+	require.Less(t, time.Since(start), time.Second*2)
+	require.NoError(t, ctx.Err())
+}
+
+func TestConnUnexpectedMessageHandlerConfirmableResponse(t *testing.T) {
+	peer, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer func() {
+		errC := peer.Close()
+		require.NoError(t, errC)
+	}()
+
+	m := mux.NewRouter()
+	err = m.Handle("/a", mux.HandlerFunc(func(w mux.ResponseWriter, _ *mux.Message) {
+		errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("a")))
+		assert.NoError(t, errS)
+	}))
+	require.NoError(t, err)
+
+	unexpected := make(chan int32, 4)
+	cc, err := Dial(peer.LocalAddr().String(), options.WithMux(m),
+		options.WithNonConfirmableResponseType(message.Confirmable),
+		options.WithUnexpectedMessageHandler(func(_ *client.Conn, msg *pool.Message) {
+			unexpected <- msg.MessageID()
+		}))
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	buf := make([]byte, 1500)
+	req := message.Message{Code: codes.GET, Type: message.NonConfirmable, MessageID: 1, Token: message.Token{1}, Options: make(message.Options, 0, 4)}
+	req.Options, _, err = req.Options.SetPath(make([]byte, 16), "/a")
+	require.NoError(t, err)
+	size, err := coder.DefaultCoder.Encode(req, buf)
+	require.NoError(t, err)
+	_, err = peer.WriteTo(buf[:size], cc.LocalAddr())
+	require.NoError(t, err)
+
+	n, addr, err := peer.ReadFrom(buf)
+	require.NoError(t, err)
+	resp := message.Message{Options: make(message.Options, 0, 8)}
+	_, err = coder.DefaultCoder.Decode(buf[:n], &resp)
+	require.NoError(t, err)
+	require.Equal(t, message.Confirmable, resp.Type)
+	require.Equal(t, codes.Content, resp.Code)
+
+	// the acknowledgement of the Confirmable response matches the sent message
+	ack := message.Message{Code: codes.Empty, Type: message.Acknowledgement, MessageID: resp.MessageID}
+	size, err = coder.DefaultCoder.Encode(ack, buf)
+	require.NoError(t, err)
+	_, err = peer.WriteTo(buf[:size], addr)
+	require.NoError(t, err)
+	select {
+	case mid := <-unexpected:
+		require.Failf(t, "unexpected call of the handler", "message ID %v", mid)
+	case <-time.After(time.Millisecond * 200):
+	}
+}
+
+func TestMarshalMessage(t *testing.T) {
+	msg := pool.NewMessage(context.Background())
+	err := msg.SetupGet("/a", message.Token{1, 2})
+	require.NoError(t, err)
+	msg.SetType(message.NonConfirmable)
+	msg.SetMessageID(7)
+	data, err := MarshalMessage(msg)
+	require.NoError(t, err)
+
+	parsed, err := ParseMessage(context.Background(), data)
+	require.NoError(t, err)
+	require.Equal(t, msg.String(), parsed.String())
+	_, err = ParseMessage(context.Background(), data[:2])
+	require.Error(t, err)
 }
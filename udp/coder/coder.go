@@ -12,10 +12,39 @@ import (
 
 var DefaultCoder = new(Coder)
 
-type Coder struct{}
+// StrictCoder rejects the received messages whose options are not in the canonical form, see message.Options.ValidateCanonical,
+// and the sent and received messages whose options violate their definitions, see message.Options.Validate.
+var StrictCoder = &Coder{strict: true}
+
+const (
+	defaultVersion = 1
+	// the version is encoded by 2 bits
+	maxVersion = 3
+)
+
+type Coder struct {
+	strict       bool
+	maxTokenSize int
+}
+
+// WithMaxTokenSize returns the copy of the coder which encodes and decodes the tokens up to maxTokenSize bytes,
+// e.g. message.MaxEncodableTokenSize for the conformance tests of the peers. By default the tokens are up to
+// message.MaxTokenSize bytes.
+func (c *Coder) WithMaxTokenSize(maxTokenSize int) *Coder {
+	coder := *c
+	coder.maxTokenSize = maxTokenSize
+	return &coder
+}
+
+func (c *Coder) tokenSizeLimit() int {
+	if c.maxTokenSize <= 0 || c.maxTokenSize > message.MaxEncodableTokenSize {
+		return message.MaxTokenSize
+	}
+	return c.maxTokenSize
+}
 
 func (c *Coder) Size(m message.Message) (int, error) {
-	if len(m.Token) > message.MaxTokenSize {
+	if len(m.Token) > c.tokenSizeLimit() {
 		return -1, message.ErrInvalidTokenLen
 	}
 	size := 4 + len(m.Token)
@@ -46,11 +75,11 @@ func (c *Coder) Encode(m message.Message, buf []byte) (int, error) {
 	   |1 1 1 1 1 1 1 1|    Payload (if any) ...
 	   +-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+-+
 	*/
-	if !message.ValidateMID(m.MessageID) {
-		return -1, fmt.Errorf("invalid MessageID(%v)", m.MessageID)
+	if err := c.validateHeader(m); err != nil {
+		return -1, err
 	}
-	if !message.ValidateType(m.Type) {
-		return -1, fmt.Errorf("invalid Type(%v)", m.Type)
+	if err := c.validateOptions(m.Options, message.CoapOptionDefs); err != nil {
+		return -1, err
 	}
 	size, err := c.Size(m)
 	if err != nil {
@@ -59,22 +88,7 @@ func (c *Coder) Encode(m message.Message, buf []byte) (int, error) {
 	if len(buf) < size {
 		return size, message.ErrTooSmall
 	}
-
-	tmpbuf := []byte{0, 0}
-	// safe: checked by message.ValidateMID above
-	binary.BigEndian.PutUint16(tmpbuf, math.CastTo[uint16](m.MessageID))
-
-	buf[0] = (1 << 6) | byte(m.Type)<<4 | byte(0xf&len(m.Token))
-	buf[1] = byte(m.Code)
-	buf[2] = tmpbuf[0]
-	buf[3] = tmpbuf[1]
-	buf = buf[4:]
-
-	if len(m.Token) > message.MaxTokenSize {
-		return -1, message.ErrInvalidTokenLen
-	}
-	copy(buf, m.Token)
-	buf = buf[len(m.Token):]
+	buf = buf[encodeHeader(m, buf):]
 
 	optionsLen, err := m.Options.Marshal(buf)
 	switch {
@@ -94,19 +108,92 @@ func (c *Coder) Encode(m message.Message, buf []byte) (int, error) {
 	return size, nil
 }
 
+// EncodeTail encodes the options and the payload of the message, which follow the token in the encoded message.
+func (c *Coder) EncodeTail(m message.Message, buf []byte) (int, error) {
+	if err := c.validateOptions(m.Options, message.CoapOptionDefs); err != nil {
+		return -1, err
+	}
+	optionsLen, err := m.Options.Marshal(nil)
+	if !errors.Is(err, message.ErrTooSmall) {
+		return -1, err
+	}
+	size := optionsLen + len(m.Payload)
+	if len(m.Payload) > 0 {
+		// for separator 0xff
+		size++
+	}
+	if len(buf) < size {
+		return size, message.ErrTooSmall
+	}
+	if _, err = m.Options.Marshal(buf); err != nil {
+		return -1, err
+	}
+	if len(m.Payload) > 0 {
+		buf[optionsLen] = 0xff
+		copy(buf[optionsLen+1:], m.Payload)
+	}
+	return size, nil
+}
+
+// EncodeWithTail encodes the header and the token of the message followed by the tail encoded by EncodeTail.
+// The options and the payload of m are ignored.
+func (c *Coder) EncodeWithTail(m message.Message, tail []byte, buf []byte) (int, error) {
+	if err := c.validateHeader(m); err != nil {
+		return -1, err
+	}
+	size := 4 + len(m.Token) + len(tail)
+	if len(buf) < size {
+		return size, message.ErrTooSmall
+	}
+	n := encodeHeader(m, buf)
+	copy(buf[n:], tail)
+	return size, nil
+}
+
+func (c *Coder) validateHeader(m message.Message) error {
+	if !message.ValidateMID(m.MessageID) {
+		return fmt.Errorf("invalid MessageID(%v)", m.MessageID)
+	}
+	if !message.ValidateType(m.Type) {
+		return fmt.Errorf("invalid Type(%v)", m.Type)
+	}
+	if m.Version != nil && *m.Version > maxVersion {
+		return fmt.Errorf("invalid Version(%v)", *m.Version)
+	}
+	if len(m.Token) > c.tokenSizeLimit() {
+		return message.ErrInvalidTokenLen
+	}
+	return nil
+}
+
+// encodeHeader encodes the fixed header and the token of the message validated by validateHeader to buf,
+// it returns the number of the written bytes.
+func encodeHeader(m message.Message, buf []byte) int {
+	version := uint8(defaultVersion)
+	if m.Version != nil {
+		version = *m.Version
+	}
+	buf[0] = version<<6 | byte(m.Type)<<4 | byte(0xf&len(m.Token))
+	buf[1] = byte(m.Code)
+	// safe: checked by message.ValidateMID in validateHeader
+	binary.BigEndian.PutUint16(buf[2:4], math.CastTo[uint16](m.MessageID))
+	copy(buf[4:], m.Token)
+	return 4 + len(m.Token)
+}
+
 func (c *Coder) Decode(data []byte, m *message.Message) (int, error) {
 	size := len(data)
 	if size < 4 {
 		return -1, ErrMessageTruncated
 	}
 
-	if data[0]>>6 != 1 {
+	if data[0]>>6 != defaultVersion {
 		return -1, ErrMessageInvalidVersion
 	}
 
 	typ := message.Type((data[0] >> 4) & 0x3)
 	tokenLen := int(data[0] & 0xf)
-	if tokenLen > 8 {
+	if tokenLen > c.tokenSizeLimit() {
 		return -1, message.ErrInvalidTokenLen
 	}
 
@@ -127,6 +214,14 @@ func (c *Coder) Decode(data []byte, m *message.Message) (int, error) {
 	if err != nil {
 		return -1, err
 	}
+	if c.strict {
+		if err = m.Options.ValidateCanonical(optionDefs); err != nil {
+			return -1, err
+		}
+		if err = c.validateOptions(m.Options, optionDefs); err != nil {
+			return -1, err
+		}
+	}
 	data = data[proc:]
 	if len(data) == 0 {
 		data = nil
@@ -140,3 +235,11 @@ func (c *Coder) Decode(data []byte, m *message.Message) (int, error) {
 
 	return size, nil
 }
+
+// validateOptions validates the options by their definitions only for the strict coder.
+func (c *Coder) validateOptions(options message.Options, optionDefs map[message.OptionID]message.OptionDef) error {
+	if !c.strict {
+		return nil
+	}
+	return options.Validate(optionDefs)
+}
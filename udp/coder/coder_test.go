@@ -205,3 +205,76 @@ func FuzzDecode(f *testing.F) {
 		_, _ = DefaultCoder.Decode(input_data, &msg)
 	})
 }
+
+func TestMarshalMessageInvalidOption(t *testing.T) {
+	msg := message.Message{
+		Code:      codes.GET,
+		Type:      message.Confirmable,
+		MessageID: 1,
+		Options:   message.Options{{ID: message.ContentFormat, Value: []byte{0, 0, 0}}},
+	}
+	// only the strict coder validates the options
+	_, err := DefaultCoder.Encode(msg, make([]byte, 64))
+	require.NoError(t, err)
+	_, err = StrictCoder.Encode(msg, make([]byte, 64))
+	require.ErrorIs(t, err, message.ErrInvalidValueLength)
+
+	msg.Options = message.Options{{ID: message.ContentFormat}, {ID: message.ContentFormat}}
+	_, err = StrictCoder.Encode(msg, make([]byte, 64))
+	require.ErrorIs(t, err, message.ErrOptionDuplicate)
+
+	// the repeatable options
+	msg.Options = message.Options{{ID: message.RequestTag, Value: []byte{1}}, {ID: message.RequestTag, Value: []byte{2}}}
+	n, err := StrictCoder.Encode(msg, make([]byte, 64))
+	require.NoError(t, err)
+	buf := make([]byte, n)
+	_, err = StrictCoder.Encode(msg, buf)
+	require.NoError(t, err)
+	decoded := message.Message{Options: make(message.Options, 0, 4)}
+	_, err = StrictCoder.Decode(buf, &decoded)
+	require.NoError(t, err)
+	require.Equal(t, msg.Options, decoded.Options)
+}
+
+func TestMarshalMessageVersion(t *testing.T) {
+	buf := make([]byte, 64)
+	version := uint8(2)
+	msg := message.Message{
+		Code:      codes.GET,
+		Type:      message.Confirmable,
+		MessageID: 1,
+		Version:   &version,
+	}
+	testMarshalMessage(t, msg, buf, []byte{0x80, byte(codes.GET), 0, 1})
+
+	// the version 0 is encoded as well
+	version = 0
+	testMarshalMessage(t, msg, buf, []byte{0x00, byte(codes.GET), 0, 1})
+
+	msg.Version = nil
+	testMarshalMessage(t, msg, buf, []byte{0x40, byte(codes.GET), 0, 1})
+
+	_, err := DefaultCoder.Decode([]byte{0x80, byte(codes.GET), 0, 1}, &message.Message{})
+	require.ErrorIs(t, err, ErrMessageInvalidVersion)
+
+	version = 4
+	msg.Version = &version
+	_, err = DefaultCoder.Encode(msg, buf)
+	require.Error(t, err)
+}
+
+func TestUnmarshalMessageStrict(t *testing.T) {
+	// Observe option with the value 1 encoded by 2 bytes
+	data := []byte{0x40, byte(codes.GET), 0, 1, 0x62, 0x00, 0x01}
+	msg := message.Message{Options: make(message.Options, 0, 4)}
+	_, err := DefaultCoder.Decode(data, &msg)
+	require.NoError(t, err)
+	msg = message.Message{Options: make(message.Options, 0, 4)}
+	_, err = StrictCoder.Decode(data, &msg)
+	require.ErrorIs(t, err, message.ErrOptionsNotCanonical)
+
+	// minimal encoding is accepted
+	msg = message.Message{Options: make(message.Options, 0, 4)}
+	_, err = StrictCoder.Decode([]byte{0x40, byte(codes.GET), 0, 1, 0x61, 0x01}, &msg)
+	require.NoError(t, err)
+}
@@ -0,0 +1,20 @@
+package udp
+
+import (
+	"context"
+
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+	"github.com/plgd-dev/go-coap/v3/udp/coder"
+)
+
+// MarshalMessage encodes the message to the CoAP over UDP wire format (RFC 7252 section 3), which is also used by DTLS,
+// e.g. to store the message in a queue. The returned slice is a copy, so it can be stored after the message is released.
+func MarshalMessage(msg *pool.Message) ([]byte, error) {
+	return msg.MarshalBinaryWithEncoder(coder.DefaultCoder)
+}
+
+// ParseMessage creates the message from data in the CoAP over UDP wire format. The data must contain exactly one message
+// and it can be reused after the call.
+func ParseMessage(ctx context.Context, data []byte) (*pool.Message, error) {
+	return pool.ParseMessage(ctx, data, coder.DefaultCoder)
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/plgd-dev/go-coap/v3/message"
 	"github.com/plgd-dev/go-coap/v3/message/codes"
 	"github.com/plgd-dev/go-coap/v3/message/pool"
+	coapNet "github.com/plgd-dev/go-coap/v3/net"
 	"github.com/plgd-dev/go-coap/v3/net/monitor/inactivity"
 	"github.com/plgd-dev/go-coap/v3/net/responsewriter"
 	"github.com/plgd-dev/go-coap/v3/options/config"
@@ -22,6 +23,9 @@ type ErrorFunc = func(error)
 // OnNewConnFunc is the callback for new connections.
 type OnNewConnFunc = func(cc *udpClient.Conn)
 
+// OnCloseConnFunc is the callback for closed connections with the reason of the close.
+type OnCloseConnFunc = func(cc *udpClient.Conn, reason coapNet.CloseReason)
+
 type GetMIDFunc = func() int32
 
 var DefaultConfig = func() Config {
@@ -30,7 +34,7 @@ var DefaultConfig = func() Config {
 		CreateInactivityMonitor: func() udpClient.InactivityMonitor {
 			timeout := time.Second * 16
 			onInactive := func(cc *udpClient.Conn) {
-				_ = cc.Close()
+				_ = cc.CloseWithReason(coapNet.CloseReasonIdleTimeout)
 			}
 			return inactivity.New(timeout, onInactive)
 		},
@@ -43,7 +47,9 @@ var DefaultConfig = func() Config {
 		TransmissionNStart:             1,
 		TransmissionAcknowledgeTimeout: time.Second * 2,
 		TransmissionMaxRetransmit:      4,
+		TransmissionAckRandomFactor:    1.5,
 		GetMID:                         message.GetMID,
+		DeduplicateNonConfirmable:      true,
 		MTU:                            udpClient.DefaultMTU,
 	}
 	opts.Handler = func(w *responsewriter.ResponseWriter[*udpClient.Conn], _ *pool.Message) {
@@ -60,9 +66,25 @@ type Config struct {
 	GetMID                         GetMIDFunc
 	Handler                        HandlerFunc
 	OnNewConn                      OnNewConnFunc
+	OnCloseConn                    OnCloseConnFunc
+	MaxConnections                 uint32
 	RequestMonitor                 udpClient.RequestMonitorFunc
 	TransmissionNStart             uint32
 	TransmissionAcknowledgeTimeout time.Duration
 	TransmissionMaxRetransmit      uint32
-	MTU                            uint16
+	TransmissionAckRandomFactor    float64
+	TransmissionExchangeLifetime   time.Duration
+	// ConfirmableResponseToNonConfirmable sends the responses to the Non-confirmable requests as Confirmable messages.
+	ConfirmableResponseToNonConfirmable bool
+	// DeduplicateNonConfirmable detects also the duplicates of the Non-confirmable requests by the message ID.
+	DeduplicateNonConfirmable bool
+	// SeparateResponseThreshold acknowledges the Confirmable request by the empty ACK when its handler doesn't return
+	// within the threshold and the response is sent separately, zero piggybacks the response in the ACK.
+	SeparateResponseThreshold time.Duration
+	// UnexpectedMessageHandler is called for the received ACK and RST which don't match any sent Confirmable message,
+	// e.g. the late duplicate of the ACK after the request timed out. Nil drops them silently.
+	UnexpectedMessageHandler udpClient.UnexpectedMessageFunc
+	// VerifyDiscoveryResponse verifies the responders of the discovery requests, nil trusts any responder.
+	VerifyDiscoveryResponse DiscoveryVerifyFunc
+	MTU                     uint16
 }
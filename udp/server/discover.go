@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sync"
+	"time"
 
 	"github.com/plgd-dev/go-coap/v3/message"
 	"github.com/plgd-dev/go-coap/v3/message/pool"
@@ -15,12 +17,62 @@ import (
 	"github.com/plgd-dev/go-coap/v3/udp/coder"
 )
 
+// DiscoveryReceiverFunc receives the responses of the discovery. When the responder replied with the message which cannot be processed,
+// the resp is nil and the err describes the failure.
+//
+// The cc is the connection of the server to the address of the responder, the same as returned by Server.NewConn, so it can be
+// used for the follow-up unicast requests to the responder, e.g. cc.Get(ctx, "/light"), also after the discovery ends.
+// The observation established by cc.Observe uses the same socket and the address (including the port) from which the responder
+// replied, so the responder doesn't need to listen on another well-known port.
+// The connection stays open until it is closed by the inactivity monitor of the server (see options.WithInactivityMonitor),
+// by cc.Close or by the Stop of the server.
+type DiscoveryReceiverFunc = func(cc *client.Conn, resp *pool.Message, err error)
+
+func ignoreDiscoveryErrors(receiverFunc func(cc *client.Conn, resp *pool.Message)) DiscoveryReceiverFunc {
+	return func(cc *client.Conn, resp *pool.Message, err error) {
+		if err != nil {
+			return
+		}
+		receiverFunc(cc, resp)
+	}
+}
+
+// datagramToken returns the token from the header of the datagram or nil when the header is corrupted.
+func datagramToken(datagram []byte) message.Token {
+	const headerLen = 4
+	if len(datagram) < headerLen {
+		return nil
+	}
+	tkl := int(datagram[0] & 0xf)
+	if tkl == 0 || tkl > message.MaxTokenSize || len(datagram) < headerLen+tkl {
+		return nil
+	}
+	return message.Token(datagram[headerLen : headerLen+tkl])
+}
+
+// handleDiscoveryError passes the error of the processing of the datagram to the discovery which sent the request with the same token.
+func (s *Server) handleDiscoveryError(cc *client.Conn, datagram []byte, err error) {
+	token := datagramToken(datagram)
+	if token == nil {
+		return
+	}
+	if h, ok := s.multicastErrorHandler.Load(token.Hash()); ok {
+		h(cc, err)
+	}
+}
+
 // Discover sends GET to multicast or unicast address and waits for responses until context timeouts or server shutdown.
 // For unicast there is a difference against the Dial. The Dial is connection-oriented and it means that, if you send a request to an address, the peer must send the response from the same
 // address where was request sent. For Discover it allows the client to send a response from another address where was request send.
 // By default it is sent over all network interfaces and all compatible source IP addresses with hop limit 1.
 // Via opts you can specify the network interface, source IP address, and hop limit.
 func (s *Server) Discover(ctx context.Context, address, path string, receiverFunc func(cc *client.Conn, resp *pool.Message), opts ...coapNet.MulticastOption) error {
+	return s.DiscoverWithErrors(ctx, address, path, ignoreDiscoveryErrors(receiverFunc), opts...)
+}
+
+// DiscoverWithErrors is same as Discover, but the receiverFunc is also called for the responders which replied with the message
+// which cannot be processed.
+func (s *Server) DiscoverWithErrors(ctx context.Context, address, path string, receiverFunc DiscoveryReceiverFunc, opts ...coapNet.MulticastOption) error {
 	token, err := s.cfg.GetToken()
 	if err != nil {
 		return fmt.Errorf("cannot get token: %w", err)
@@ -33,7 +85,7 @@ func (s *Server) Discover(ctx context.Context, address, path string, receiverFun
 	}
 	req.SetMessageID(s.cfg.GetMID())
 	req.SetType(message.NonConfirmable)
-	return s.DiscoveryRequest(req, address, receiverFunc, opts...)
+	return s.DiscoveryRequestWithErrors(req, address, receiverFunc, opts...)
 }
 
 // DiscoveryRequest sends request to multicast/unicast address and wait for responses until request timeouts or server shutdown.
@@ -42,6 +94,94 @@ func (s *Server) Discover(ctx context.Context, address, path string, receiverFun
 // By default it is sent over all network interfaces and all compatible source IP addresses with hop limit 1.
 // Via opts you can specify the network interface, source IP address, and hop limit.
 func (s *Server) DiscoveryRequest(req *pool.Message, address string, receiverFunc func(cc *client.Conn, resp *pool.Message), opts ...coapNet.MulticastOption) error {
+	return s.DiscoveryRequestWithErrors(req, address, ignoreDiscoveryErrors(receiverFunc), opts...)
+}
+
+// DiscoveryRequestWithErrors is same as DiscoveryRequest, but the receiverFunc is also called with the error for the responders
+// which replied with the message which cannot be processed (e.g. malformed message). The errors are matched to the request
+// by the token, so the datagrams with the corrupted header are reported only via the Errors callback of the server.
+//
+// With the coapNet.WithMulticastDedicatedListener option, the request is sent from the temporary socket created for the request,
+// so the responses are received only by this socket. The socket is closed when the discovery ends, so the receiverFunc gets
+// the connection of the server s to the responder instead of the connection of the temporary socket.
+// With the coapNet.WithMulticastRepeat option, the request is sent multiple times and the receiverFunc is called
+// only for the first response of each responder.
+func (s *Server) DiscoveryRequestWithErrors(req *pool.Message, address string, receiverFunc DiscoveryReceiverFunc, opts ...coapNet.MulticastOption) error {
+	mcastOpts := coapNet.DefaultMulticastOptions()
+	for _, o := range opts {
+		mcastOpts.Apply(o)
+	}
+	if mcastOpts.DedicatedListener {
+		return s.discoveryRequestWithDedicatedListener(req, address, receiverFunc, mcastOpts, opts...)
+	}
+	return s.discoveryRequest(req, address, receiverFunc, mcastOpts, opts...)
+}
+
+// discoveryRequestWithDedicatedListener serves the temporary socket by the server with the same configuration, which is stopped
+// together with the server s.
+func (s *Server) discoveryRequestWithDedicatedListener(req *pool.Message, address string, receiverFunc DiscoveryReceiverFunc, mcastOpts coapNet.MulticastOptions, opts ...coapNet.MulticastOption) error {
+	c := s.conn()
+	if c == nil {
+		return errors.New("server doesn't serve connection")
+	}
+	l, err := coapNet.NewListenUDP(c.Network(), "")
+	if err != nil {
+		return fmt.Errorf("cannot create dedicated listener: %w", err)
+	}
+	cfg := *s.cfg
+	cfg.Ctx = s.ctx
+	ds := newServer(cfg)
+	var wg sync.WaitGroup
+	defer func() {
+		ds.Stop()
+		wg.Wait()
+		// the listener is not closed by Stop when the server was stopped before Serve started
+		_ = l.Close()
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if errS := ds.Serve(l); !errors.Is(errS, coapNet.ErrServerClosed) {
+			s.cfg.Errors(fmt.Errorf("cannot serve dedicated listener: %w", errS))
+		}
+	}()
+	return ds.discoveryRequest(req, address, s.rebindResponders(receiverFunc), mcastOpts, opts...)
+}
+
+// rebindResponders replaces the connection of the temporary socket by the connection of the server s to the same responder,
+// so the connection passed to the receiverFunc outlives the discovery. When the connection cannot be created,
+// e.g. due to the limit of the connections, the connection of the temporary socket is passed.
+func (s *Server) rebindResponders(receiverFunc DiscoveryReceiverFunc) DiscoveryReceiverFunc {
+	return func(cc *client.Conn, resp *pool.Message, err error) {
+		if raddr, ok := cc.RemoteAddr().(*net.UDPAddr); ok {
+			if sc, errC := s.NewConn(raddr); errC == nil {
+				cc = sc
+			}
+		}
+		receiverFunc(cc, resp, err)
+	}
+}
+
+// dedupResponders passes only the first response of each responder to the receiverFunc.
+func dedupResponders(receiverFunc DiscoveryReceiverFunc) DiscoveryReceiverFunc {
+	var mutex sync.Mutex
+	responders := make(map[string]struct{})
+	return func(cc *client.Conn, resp *pool.Message, err error) {
+		if err == nil {
+			key := cc.RemoteAddr().String()
+			mutex.Lock()
+			_, ok := responders[key]
+			responders[key] = struct{}{}
+			mutex.Unlock()
+			if ok {
+				return
+			}
+		}
+		receiverFunc(cc, resp, err)
+	}
+}
+
+func (s *Server) discoveryRequest(req *pool.Message, address string, receiverFunc DiscoveryReceiverFunc, mcastOpts coapNet.MulticastOptions, opts ...coapNet.MulticastOption) error {
 	token := req.Token()
 	if len(token) == 0 {
 		return errors.New("invalid token")
@@ -59,25 +199,51 @@ func (s *Server) DiscoveryRequest(req *pool.Message, address string, receiverFun
 	if err != nil {
 		return fmt.Errorf("cannot marshal req: %w", err)
 	}
+	if mcastOpts.RepeatCount > 1 {
+		receiverFunc = dedupResponders(receiverFunc)
+	}
+	if s.cfg.VerifyDiscoveryResponse != nil {
+		// the spoofed responses are rejected before they are deduplicated
+		receiverFunc = verifyResponders(s.cfg.VerifyDiscoveryResponse, req, receiverFunc)
+	}
 	s.multicastRequests.Store(token.Hash(), req)
 	defer s.multicastRequests.Delete(token.Hash())
 	if _, loaded := s.multicastHandler.LoadOrStore(token.Hash(), func(w *responsewriter.ResponseWriter[*client.Conn], r *pool.Message) {
-		receiverFunc(w.Conn(), r)
+		receiverFunc(w.Conn(), r, nil)
 	}); loaded {
 		return pkgErrors.ErrKeyAlreadyExists
 	}
 	defer func() {
 		_, _ = s.multicastHandler.LoadAndDelete(token.Hash())
 	}()
+	s.multicastErrorHandler.Store(token.Hash(), func(cc *client.Conn, err error) {
+		receiverFunc(cc, nil, err)
+	})
+	defer func() {
+		_, _ = s.multicastErrorHandler.LoadAndDelete(token.Hash())
+	}()
 
-	if addr.IP.IsMulticast() {
-		err = c.WriteMulticast(req.Context(), addr, data, opts...)
-		if err != nil {
-			return err
+	send := func() error {
+		if addr.IP.IsMulticast() {
+			return c.WriteMulticast(req.Context(), addr, data, opts...)
 		}
-	} else {
-		err = c.WriteWithContext(req.Context(), addr, data)
-		if err != nil {
+		return c.WriteWithContext(req.Context(), addr, data)
+	}
+	if err = send(); err != nil {
+		return err
+	}
+	for i := 1; i < mcastOpts.RepeatCount; i++ {
+		t := time.NewTimer(mcastOpts.RepeatInterval)
+		select {
+		case <-t.C:
+		case <-req.Context().Done():
+			t.Stop()
+			return nil
+		case <-s.ctx.Done():
+			t.Stop()
+			return fmt.Errorf("server was closed: %w", s.ctx.Err())
+		}
+		if err = send(); err != nil {
 			return err
 		}
 	}
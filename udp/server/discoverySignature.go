@@ -0,0 +1,146 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+	pkgMath "github.com/plgd-dev/go-coap/v3/pkg/math"
+	"github.com/plgd-dev/go-coap/v3/udp/client"
+)
+
+// DiscoverySignature is the option of the signature of the discovery response set by SignDiscoveryResponse.
+// The number is from the experimental range (RFC 7252 section 12.2) and the option is elective, so the clients
+// which don't verify the signature ignore it.
+const DiscoverySignature message.OptionID = 65000
+
+// ErrInvalidDiscoverySignature is returned by the verifier of HMACDiscoveryVerifier when the response is not signed
+// or its signature doesn't match.
+var ErrInvalidDiscoverySignature = errors.New("invalid discovery signature")
+
+// DiscoveryVerifyFunc verifies the authenticity of the responder of the discovery request req before the response resp
+// is passed to the receiverFunc of the discovery, e.g. by the signature set by SignDiscoveryResponse or by the certificate
+// of the device. The response which is not verified is passed to the receiverFunc of DiscoveryRequestWithErrors
+// as the error, the receiverFunc of DiscoveryRequest doesn't get it. The function is called concurrently for the
+// responses of the different responders.
+type DiscoveryVerifyFunc = func(cc *client.Conn, req, resp *pool.Message) error
+
+// writeDiscoverySignatureField writes the field prefixed by its length, so the fields cannot be confused
+// by moving the bytes between them.
+func writeDiscoverySignatureField(mac hash.Hash, field []byte) {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], pkgMath.CastTo[uint32](len(field)))
+	mac.Write(length[:])
+	mac.Write(field)
+}
+
+// discoveryResponderIP returns the IP address of the responder without the port and the IPv6 zone, because the zone
+// is the interface of the local host and the port can be changed by NAT.
+func discoveryResponderIP(responder net.Addr) net.IP {
+	switch a := responder.(type) {
+	case *net.UDPAddr:
+		return a.IP.To16()
+	case *net.IPAddr:
+		return a.IP.To16()
+	}
+	host, _, err := net.SplitHostPort(responder.String())
+	if err != nil {
+		host = responder.String()
+	}
+	if i := strings.IndexByte(host, '%'); i >= 0 {
+		host = host[:i]
+	}
+	return net.ParseIP(host).To16()
+}
+
+// discoverySignature computes HMAC-SHA256 by the key over the token of the request, the IP address of the responder,
+// the code, all options except DiscoverySignature and the payload of the response.
+func discoverySignature(key []byte, token message.Token, responder net.Addr, resp *pool.Message) ([]byte, error) {
+	payload, err := resp.ReadBody()
+	if err != nil {
+		return nil, fmt.Errorf("cannot read payload: %w", err)
+	}
+	if body := resp.Body(); body != nil {
+		if _, err = body.Seek(0, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("cannot seek payload: %w", err)
+		}
+	}
+	opts := make(message.Options, 0, len(resp.Options()))
+	for _, o := range resp.Options() {
+		if o.ID != DiscoverySignature {
+			opts = append(opts, o)
+		}
+	}
+	mac := hmac.New(sha256.New, key)
+	writeDiscoverySignatureField(mac, token)
+	writeDiscoverySignatureField(mac, discoveryResponderIP(responder))
+	writeDiscoverySignatureField(mac, []byte{byte(resp.Code())})
+	writeDiscoverySignatureField(mac, binary.BigEndian.AppendUint32(nil, pkgMath.CastTo[uint32](len(opts))))
+	for _, o := range opts {
+		writeDiscoverySignatureField(mac, binary.BigEndian.AppendUint16(nil, uint16(o.ID)))
+		writeDiscoverySignatureField(mac, o.Value)
+	}
+	writeDiscoverySignatureField(mac, payload)
+	return mac.Sum(nil), nil
+}
+
+// SignDiscoveryResponse sets the DiscoverySignature option of the response resp to the discovery request with the token,
+// signed by the shared key. The responder is the address from which the response is received by the discovering
+// client, e.g. the unicast address of the device, only its IP address without the port and the zone is signed.
+// The responder calls it after the response is set, e.g.:
+//
+//	err := w.SetResponse(codes.Content, message.AppLinkFormat, bytes.NewReader(links))
+//	...
+//	err = server.SignDiscoveryResponse(key, r.Token(), unicastAddr, w.Message())
+//
+// The signature binds the response to the token of the request and to the responder, so it cannot be replayed
+// to the other discovery or from the other IP address. The options and the payload of the response must not be modified
+// after the signing.
+func SignDiscoveryResponse(key []byte, token message.Token, responder net.Addr, resp *pool.Message) error {
+	signature, err := discoverySignature(key, token, responder, resp)
+	if err != nil {
+		return fmt.Errorf("cannot sign discovery response: %w", err)
+	}
+	resp.SetOptionBytes(DiscoverySignature, signature)
+	return nil
+}
+
+// HMACDiscoveryVerifier returns the verifier of the signatures set by SignDiscoveryResponse with the shared key,
+// the responder is the remote address of the connection which received the response, see options.WithDiscoveryVerifier.
+func HMACDiscoveryVerifier(key []byte) DiscoveryVerifyFunc {
+	return func(cc *client.Conn, req, resp *pool.Message) error {
+		signature, err := resp.GetOptionBytes(DiscoverySignature)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidDiscoverySignature, err)
+		}
+		expected, err := discoverySignature(key, req.Token(), cc.RemoteAddr(), resp)
+		if err != nil {
+			return err
+		}
+		if !hmac.Equal(signature, expected) {
+			return ErrInvalidDiscoverySignature
+		}
+		return nil
+	}
+}
+
+// verifyResponders passes only the verified responses to the receiverFunc, the other ones are passed as the errors.
+func verifyResponders(verify DiscoveryVerifyFunc, req *pool.Message, receiverFunc DiscoveryReceiverFunc) DiscoveryReceiverFunc {
+	return func(cc *client.Conn, resp *pool.Message, err error) {
+		if err == nil {
+			if errV := verify(cc, req, resp); errV != nil {
+				receiverFunc(cc, nil, fmt.Errorf("cannot verify discovery response from %v: %w", cc.RemoteAddr(), errV))
+				return
+			}
+		}
+		receiverFunc(cc, resp, err)
+	}
+}
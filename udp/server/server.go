@@ -12,8 +12,11 @@ import (
 	"github.com/plgd-dev/go-coap/v3/message/pool"
 	coapNet "github.com/plgd-dev/go-coap/v3/net"
 	"github.com/plgd-dev/go-coap/v3/net/blockwise"
+	"github.com/plgd-dev/go-coap/v3/net/metrics"
 	"github.com/plgd-dev/go-coap/v3/net/monitor/inactivity"
 	"github.com/plgd-dev/go-coap/v3/net/responsewriter"
+	"github.com/plgd-dev/go-coap/v3/options/config"
+	"github.com/plgd-dev/go-coap/v3/pkg/runner/scheduler"
 	coapSync "github.com/plgd-dev/go-coap/v3/pkg/sync"
 	"github.com/plgd-dev/go-coap/v3/udp/client"
 )
@@ -23,9 +26,11 @@ type Server struct {
 	ctx               context.Context
 	multicastRequests *client.RequestsMap
 	multicastHandler  *coapSync.Map[uint64, HandlerFunc]
-	serverStartedChan chan struct{}
-	doneCancel        context.CancelFunc
-	cancel            context.CancelFunc
+	// multicastErrorHandler receives errors of the responses to the discovery requests
+	multicastErrorHandler *coapSync.Map[uint64, func(cc *client.Conn, err error)]
+	serverStartedChan     chan struct{}
+	doneCancel            context.CancelFunc
+	cancel                context.CancelCauseFunc
 
 	connsMutex sync.Mutex
 	conns      map[string]*client.Conn
@@ -33,6 +38,13 @@ type Server struct {
 	listenMutex sync.Mutex
 	listen      *coapNet.UDPConn
 
+	// refusedReported and refusedDatagrams are used only by the goroutine of Serve
+	refusedReported  time.Time
+	refusedDatagrams int
+
+	notificationSchedulerOnce sync.Once
+	notificationScheduler     *scheduler.Scheduler
+
 	cfg *Config
 }
 
@@ -70,10 +82,6 @@ func New(opt ...Option) *Server {
 		cfg.MessagePool = pool.New(0, 0)
 	}
 
-	ctx, cancel := context.WithCancel(cfg.Ctx)
-	serverStartedChan := make(chan struct{})
-
-	doneCtx, doneCancel := context.WithCancel(context.Background())
 	errorsFunc := cfg.Errors
 	cfg.Errors = func(err error) {
 		if coapNet.IsCancelOrCloseError(err) {
@@ -82,15 +90,25 @@ func New(opt ...Option) *Server {
 		}
 		errorsFunc(fmt.Errorf("udp: %w", err))
 	}
+	return newServer(cfg)
+}
+
+// newServer creates the server from the completed configuration.
+func newServer(cfg Config) *Server {
+	ctx, cancel := context.WithCancelCause(cfg.Ctx)
+	serverStartedChan := make(chan struct{})
+
+	doneCtx, doneCancel := context.WithCancel(context.Background())
 	return &Server{
-		ctx:               ctx,
-		cancel:            cancel,
-		multicastHandler:  coapSync.NewMap[uint64, HandlerFunc](),
-		multicastRequests: coapSync.NewMap[uint64, *pool.Message](),
-		serverStartedChan: serverStartedChan,
-		doneCtx:           doneCtx,
-		doneCancel:        doneCancel,
-		conns:             make(map[string]*client.Conn),
+		ctx:                   ctx,
+		cancel:                cancel,
+		multicastHandler:      coapSync.NewMap[uint64, HandlerFunc](),
+		multicastErrorHandler: coapSync.NewMap[uint64, func(cc *client.Conn, err error)](),
+		multicastRequests:     coapSync.NewMap[uint64, *pool.Message](),
+		serverStartedChan:     serverStartedChan,
+		doneCtx:               doneCtx,
+		doneCancel:            doneCancel,
+		conns:                 make(map[string]*client.Conn),
 
 		cfg: &cfg,
 	}
@@ -113,6 +131,9 @@ func (s *Server) closeConnection(cc *client.Conn) {
 	}
 }
 
+// Serve accepts the connections on the listener l until Stop is called. Serve always returns a non-nil error:
+// coapNet.ErrServerClosed after Stop or the cancellation of the context of the server, otherwise the error
+// which stopped the serving, e.g. when the listener was closed without Stop.
 func (s *Server) Serve(l *coapNet.UDPConn) error {
 	if s.cfg.BlockwiseSZX > blockwise.SZX1024 {
 		return errors.New("invalid blockwiseSZX")
@@ -150,37 +171,74 @@ func (s *Server) Serve(l *coapNet.UDPConn) error {
 
 			select {
 			case <-s.ctx.Done():
-				return nil
+				return coapNet.ErrServerClosed
 			default:
-				if coapNet.IsCancelOrCloseError(err) {
-					return nil
-				}
 				return err
 			}
 		}
 		buf = buf[:n]
+		if s.cfg.WireTap != nil {
+			s.cfg.WireTap(config.DirectionReceived, buf, raddr)
+		}
 		cc, err := s.getConn(l, raddr, true)
 		if err != nil {
+			if errors.Is(err, coapNet.ErrMaxConnectionsExceeded) || errors.Is(err, coapNet.ErrMaxGoroutinesExceeded) {
+				s.refuseDatagram(raddr, err)
+				continue
+			}
 			s.cfg.Errors(fmt.Errorf("%v: cannot get client connection: %w", raddr, err))
 			continue
 		}
 		err = cc.Process(cm, buf)
 		if err != nil {
+			s.handleDiscoveryError(cc, buf, err)
+			if errors.Is(err, coapNet.ErrMalformedDatagram) {
+				// the datagram is dropped and the connection stays open
+				s.cfg.Errors(fmt.Errorf("%v: cannot process packet: %w", cc.RemoteAddr(), err))
+				continue
+			}
+			if s.cfg.OnTransportError != nil {
+				s.cfg.OnTransportError(cc, err, true)
+			}
 			s.closeConnection(cc)
 			s.cfg.Errors(fmt.Errorf("%v: cannot process packet: %w", cc.RemoteAddr(), err))
 		}
 	}
 }
 
+// refusedReportInterval is the minimal interval between the errors which report the refused datagrams.
+const refusedReportInterval = time.Second
+
+// refuseDatagram drops the datagram of the source which got no connection because of the limits of the server.
+// Every dropped datagram is counted by the metrics, but the error is reported at most once per refusedReportInterval,
+// so a flood of the datagrams from distinct sources doesn't flood the errors.
+func (s *Server) refuseDatagram(raddr *net.UDPAddr, err error) {
+	metrics.MessageDropped(s.cfg.Metrics)
+	s.refusedDatagrams++
+	now := time.Now()
+	if now.Sub(s.refusedReported) < refusedReportInterval {
+		return
+	}
+	s.cfg.Errors(fmt.Errorf("%v: cannot get client connection: %w (%v datagrams dropped)", raddr, err, s.refusedDatagrams))
+	s.refusedReported = now
+	s.refusedDatagrams = 0
+}
+
 func (s *Server) getListener() *coapNet.UDPConn {
 	s.listenMutex.Lock()
 	defer s.listenMutex.Unlock()
 	return s.listen
 }
 
+// Goroutines returns the number of the goroutines of the connections counted by options.WithMaxGoroutines,
+// 0 when the option is not set.
+func (s *Server) Goroutines() int64 {
+	return s.cfg.Goroutines.Running()
+}
+
 // Stop stops server without wait of ends Serve function.
 func (s *Server) Stop() {
-	s.cancel()
+	s.cancel(coapNet.ErrServerShutdown)
 	l := s.getListener()
 	if l != nil {
 		if errC := l.Close(); errC != nil {
@@ -190,6 +248,19 @@ func (s *Server) Stop() {
 	s.closeSessions()
 }
 
+// NotificationScheduler returns the scheduler of the timed notifications served by the goroutines
+// set by options.WithNotificationWorkers. It is created by the first call and closed when the server is stopped.
+func (s *Server) NotificationScheduler() *scheduler.Scheduler {
+	s.notificationSchedulerOnce.Do(func() {
+		s.notificationScheduler = scheduler.New(s.cfg.NotificationWorkers)
+		go func() {
+			<-s.ctx.Done()
+			s.notificationScheduler.Close()
+		}()
+	})
+	return s.notificationScheduler
+}
+
 func (s *Server) closeSessions() {
 	s.connsMutex.Lock()
 	conns := s.conns
@@ -228,6 +299,28 @@ func (s *Server) getConns() []*client.Conn {
 	return conns
 }
 
+// MigrateObservers moves the observers of all connections to the server at the altAddr, see client.Conn.MigrateObservers.
+// The connections with the observations are closed, the errors of the connections are joined.
+func (s *Server) MigrateObservers(ctx context.Context, altAddr string) error {
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	var errs []error
+	for _, cc := range s.getConns() {
+		cc := cc
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := cc.MigrateObservers(ctx, altAddr); err != nil {
+				mutex.Lock()
+				errs = append(errs, fmt.Errorf("%v: %w", cc.RemoteAddr(), err))
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
 func (s *Server) handleInactivityMonitors(now time.Time) {
 	for _, cc := range s.getConns() {
 		select {
@@ -254,14 +347,20 @@ func getClose(cc *client.Conn) func() {
 	return closeFn
 }
 
-func (s *Server) getOrCreateConn(udpConn *coapNet.UDPConn, raddr *net.UDPAddr) (cc *client.Conn, created bool) {
+func (s *Server) getOrCreateConn(udpConn *coapNet.UDPConn, raddr *net.UDPAddr) (cc *client.Conn, created bool, err error) {
 	s.connsMutex.Lock()
 	defer s.connsMutex.Unlock()
 	key := raddr.String()
 	cc = s.conns[key]
 
 	if cc != nil {
-		return cc, false
+		return cc, false, nil
+	}
+	if s.cfg.MaxConnections > 0 && len(s.conns) >= int(s.cfg.MaxConnections) {
+		return nil, false, coapNet.ErrMaxConnectionsExceeded
+	}
+	if s.cfg.Goroutines.Exceeded() {
+		return nil, false, coapNet.ErrMaxGoroutinesExceeded
 	}
 
 	createBlockWise := func(*client.Conn) *blockwise.BlockWise[*client.Conn] {
@@ -287,7 +386,13 @@ func (s *Server) getOrCreateConn(udpConn *coapNet.UDPConn, raddr *net.UDPAddr) (
 						msg.SetMessageID(m.MessageID())
 						return msg
 					})
-				})
+				},
+				blockwise.WithBufferAllocator(s.cfg.BlockwiseBufferAllocator),
+				blockwise.WithMaxRequestBodySize(s.cfg.BlockwiseMaxRequestBodySize),
+				blockwise.WithMaxConcurrentTransfers(s.cfg.BlockwiseMaxConcurrentTransfers),
+				blockwise.WithPerBlockTimeout(s.cfg.BlockwisePerBlockTimeout),
+				blockwise.WithMetrics(s.cfg.Metrics),
+			)
 		}
 	}
 	session := NewSession(
@@ -299,11 +404,21 @@ func (s *Server) getOrCreateConn(udpConn *coapNet.UDPConn, raddr *net.UDPAddr) (
 		s.cfg.MTU,
 		false,
 	)
+	session.SetWireTap(s.cfg.WireTap)
+	session.SetMetrics(s.cfg.Metrics)
+	session.SetTokenLength(s.cfg.TokenLength)
+	session.SetSendQueue(s.cfg.NewSendQueue())
 	monitor := s.cfg.CreateInactivityMonitor()
 	cfg := client.DefaultConfig
 	cfg.TransmissionNStart = s.cfg.TransmissionNStart
 	cfg.TransmissionAcknowledgeTimeout = s.cfg.TransmissionAcknowledgeTimeout
 	cfg.TransmissionMaxRetransmit = s.cfg.TransmissionMaxRetransmit
+	cfg.TransmissionAckRandomFactor = s.cfg.TransmissionAckRandomFactor
+	cfg.TransmissionExchangeLifetime = s.cfg.TransmissionExchangeLifetime
+	cfg.ConfirmableResponseToNonConfirmable = s.cfg.ConfirmableResponseToNonConfirmable
+	cfg.SeparateResponseThreshold = s.cfg.SeparateResponseThreshold
+	cfg.UnexpectedMessageHandler = s.cfg.UnexpectedMessageHandler
+	cfg.DeduplicateNonConfirmable = s.cfg.DeduplicateNonConfirmable
 	cfg.Handler = func(w *responsewriter.ResponseWriter[*client.Conn], r *pool.Message) {
 		h, ok := s.multicastHandler.Load(r.Token().Hash())
 		if ok {
@@ -319,6 +434,15 @@ func (s *Server) getOrCreateConn(udpConn *coapNet.UDPConn, raddr *net.UDPAddr) (
 	cfg.MessagePool = s.cfg.MessagePool
 	cfg.ProcessReceivedMessage = s.cfg.ProcessReceivedMessage
 	cfg.ReceivedMessageQueueSize = s.cfg.ReceivedMessageQueueSize
+	cfg.OnParseError = s.cfg.OnParseError
+	cfg.SerializedHandlers = s.cfg.SerializedHandlers
+	cfg.MaxObservations = s.cfg.MaxObservations
+	cfg.TrackObservations = s.cfg.TrackObservations
+	cfg.StrictParsing = s.cfg.StrictParsing
+	cfg.Metrics = s.cfg.Metrics
+	cfg.Goroutines = s.cfg.Goroutines
+	cfg.TokenLength = s.cfg.TokenLength
+	cfg.OnTransportError = s.cfg.OnTransportError
 
 	requestMonitor := s.cfg.RequestMonitor
 	cc = client.NewConnWithOpts(
@@ -342,15 +466,23 @@ func (s *Server) getOrCreateConn(udpConn *coapNet.UDPConn, raddr *net.UDPAddr) (
 		}
 	})
 	s.conns[key] = cc
-	return cc, true
+	return cc, true, nil
 }
 
 func (s *Server) getConn(l *coapNet.UDPConn, raddr *net.UDPAddr, firstTime bool) (*client.Conn, error) {
-	cc, created := s.getOrCreateConn(l, raddr)
+	cc, created, err := s.getOrCreateConn(l, raddr)
+	if err != nil {
+		return nil, err
+	}
 	if created {
 		if s.cfg.OnNewConn != nil {
 			s.cfg.OnNewConn(cc)
 		}
+		if s.cfg.OnCloseConn != nil {
+			cc.AddOnClose(func() {
+				s.cfg.OnCloseConn(cc, cc.CloseReason())
+			})
+		}
 	} else {
 		// check if client is not expired now + 10ms  - if so, close it
 		// 10ms - The expected maximum time taken by cc.CheckExpirations and cc.InactivityMonitor().Notify()
@@ -2,13 +2,18 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"sync"
 	"sync/atomic"
 
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
 	"github.com/plgd-dev/go-coap/v3/message/pool"
 	coapNet "github.com/plgd-dev/go-coap/v3/net"
+	"github.com/plgd-dev/go-coap/v3/net/metrics"
+	"github.com/plgd-dev/go-coap/v3/options/config"
 	"github.com/plgd-dev/go-coap/v3/udp/client"
 	"github.com/plgd-dev/go-coap/v3/udp/coder"
 )
@@ -24,7 +29,7 @@ type Session struct {
 	connection *coapNet.UDPConn
 	doneCancel context.CancelFunc
 
-	cancel context.CancelFunc
+	cancel context.CancelCauseFunc
 	raddr  *net.UDPAddr
 
 	mutex          sync.Mutex
@@ -32,6 +37,12 @@ type Session struct {
 	mtu            uint16
 
 	closeSocket bool
+
+	wireTap     config.WireTapFunc
+	sendQueue   *coapNet.SendQueue
+	metrics     metrics.Collector
+	tokenLength message.TokenLength
+	encoder     *coder.Coder
 }
 
 func NewSession(
@@ -43,7 +54,7 @@ func NewSession(
 	mtu uint16,
 	closeSocket bool,
 ) *Session {
-	ctx, cancel := context.WithCancel(ctx)
+	ctx, cancel := context.WithCancelCause(ctx)
 
 	doneCtx, doneCancel := context.WithCancel(doneCtx)
 	s := &Session{
@@ -55,6 +66,9 @@ func NewSession(
 		closeSocket:    closeSocket,
 		doneCtx:        doneCtx,
 		doneCancel:     doneCancel,
+		metrics:        metrics.NilCollector{},
+		tokenLength:    message.DefaultTokenLength,
+		encoder:        coder.DefaultCoder,
 	}
 	s.ctx.Store(&ctx)
 	return s
@@ -93,7 +107,7 @@ func (s *Session) shutdown() {
 }
 
 func (s *Session) Close() error {
-	s.cancel()
+	s.cancel(nil)
 	if s.closeSocket {
 		return s.connection.Close()
 	}
@@ -104,28 +118,133 @@ func (s *Session) Context() context.Context {
 	return *s.ctx.Load()
 }
 
+// SetWireTap sets the function which is called with every datagram sent or received by the session.
+func (s *Session) SetWireTap(wireTap config.WireTapFunc) {
+	s.wireTap = wireTap
+}
+
+// SetMetrics sets the collector of the messages sent by the session, nil disables the collection.
+func (s *Session) SetMetrics(collector metrics.Collector) {
+	s.metrics = metrics.OrNil(collector)
+}
+
+// SetTokenLength sets the lengths of the tokens of the messages written by the session, the other messages are rejected.
+func (s *Session) SetTokenLength(tokenLength message.TokenLength) {
+	s.tokenLength = tokenLength
+	s.encoder = coder.DefaultCoder.WithMaxTokenSize(tokenLength.Max)
+}
+
+func (s *Session) marshal(req *pool.Message) ([]byte, error) {
+	if err := s.tokenLength.Validate(req.Code(), req.Token()); err != nil {
+		return nil, err
+	}
+	data, err := req.MarshalWithEncoder(s.encoder)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal: %w", err)
+	}
+	return data, nil
+}
+
+// SetSendQueue bounds the messages written by the session at once, nil removes the bound.
+func (s *Session) SetSendQueue(sendQueue *coapNet.SendQueue) {
+	s.sendQueue = sendQueue
+}
+
+// Flush blocks until the messages queued by the send queue are written to the connection or the ctx is done.
+// Without the send queue the messages are written synchronously, so there is nothing to wait for.
+func (s *Session) Flush(ctx context.Context) error {
+	if s.sendQueue == nil {
+		return nil
+	}
+	return s.sendQueue.Flush(ctx)
+}
+
+func (s *Session) write(req *pool.Message, write func() error) error {
+	if s.sendQueue == nil {
+		return write()
+	}
+	return s.sendQueue.Write(req.Context(), write)
+}
+
 func (s *Session) WriteMessage(req *pool.Message) error {
-	data, err := req.MarshalWithEncoder(coder.DefaultCoder)
+	data, err := s.marshal(req)
 	if err != nil {
-		return fmt.Errorf("cannot marshal: %w", err)
+		return err
 	}
-	return s.connection.WriteWithOptions(data, coapNet.WithContext(req.Context()), coapNet.WithRemoteAddr(s.raddr), coapNet.WithControlMessage(req.ControlMessage()))
+	code := req.Code()
+	return s.write(req, func() error {
+		if s.wireTap != nil {
+			s.wireTap(config.DirectionSent, data, s.raddr)
+		}
+		if errW := s.connection.WriteWithOptions(data, coapNet.WithContext(req.Context()), coapNet.WithRemoteAddr(s.raddr), coapNet.WithControlMessage(req.ControlMessage())); errW != nil {
+			return errW
+		}
+		s.metrics.MessageSent(code)
+		return nil
+	})
+}
+
+// WriteMessages sends messages to the remote address by a single batch write.
+func (s *Session) WriteMessages(reqs []*pool.Message) error {
+	if len(reqs) == 0 {
+		return nil
+	}
+	datagrams := make([]coapNet.UDPDatagram, 0, len(reqs))
+	sentCodes := make([]codes.Code, 0, len(reqs))
+	for _, req := range reqs {
+		data, err := s.marshal(req)
+		if err != nil {
+			return err
+		}
+		datagrams = append(datagrams, coapNet.UDPDatagram{
+			Data:           data,
+			RemoteAddr:     s.raddr,
+			ControlMessage: req.ControlMessage(),
+		})
+		sentCodes = append(sentCodes, req.Code())
+	}
+	return s.write(reqs[0], func() error {
+		if s.wireTap != nil {
+			for _, d := range datagrams {
+				s.wireTap(config.DirectionSent, d.Data, s.raddr)
+			}
+		}
+		if _, err := s.connection.WriteBatch(reqs[0].Context(), datagrams); err != nil {
+			return err
+		}
+		for _, code := range sentCodes {
+			s.metrics.MessageSent(code)
+		}
+		return nil
+	})
 }
 
 // WriteMulticastMessage sends multicast to the remote multicast address.
 // By default it is sent over all network interfaces and all compatible source IP addresses with hop limit 1.
 // Via opts you can specify the network interface, source IP address, and hop limit.
 func (s *Session) WriteMulticastMessage(req *pool.Message, address *net.UDPAddr, opts ...coapNet.MulticastOption) error {
-	data, err := req.MarshalWithEncoder(coder.DefaultCoder)
+	data, err := s.marshal(req)
 	if err != nil {
-		return fmt.Errorf("cannot marshal: %w", err)
+		return err
+	}
+	if s.wireTap != nil {
+		s.wireTap(config.DirectionSent, data, address)
 	}
 
-	return s.connection.WriteMulticast(req.Context(), address, data, opts...)
+	code := req.Code()
+	if err = s.connection.WriteMulticast(req.Context(), address, data, opts...); err != nil {
+		return err
+	}
+	s.metrics.MessageSent(code)
+	return nil
 }
 
 func (s *Session) Run(cc *client.Conn) (err error) {
 	defer func() {
+		if err != nil {
+			// pending requests get the error (e.g. coapNet.ErrConnectionRefused) as the cause of the closed connection
+			s.cancel(err)
+		}
 		err1 := s.Close()
 		if err == nil {
 			err = err1
@@ -141,8 +260,11 @@ func (s *Session) Run(cc *client.Conn) (err error) {
 			return err
 		}
 		buf = buf[:n]
+		if s.wireTap != nil {
+			s.wireTap(config.DirectionReceived, buf, s.raddr)
+		}
 		err = cc.Process(cm, buf)
-		if err != nil {
+		if err != nil && !errors.Is(err, coapNet.ErrMalformedDatagram) {
 			return err
 		}
 	}
@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/binary"
+	"errors"
 	"log"
 	"net"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
@@ -15,6 +17,7 @@ import (
 	"github.com/plgd-dev/go-coap/v3/message/pool"
 	"github.com/plgd-dev/go-coap/v3/mux"
 	coapNet "github.com/plgd-dev/go-coap/v3/net"
+	"github.com/plgd-dev/go-coap/v3/net/metrics"
 	"github.com/plgd-dev/go-coap/v3/net/responsewriter"
 	"github.com/plgd-dev/go-coap/v3/options"
 	"github.com/plgd-dev/go-coap/v3/options/config"
@@ -71,7 +74,7 @@ func TestServerDiscoverIotivity(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		errS := sd.Serve(ld)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
@@ -170,7 +173,7 @@ func TestServerDiscover(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		errS := s.Serve(l)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	ld, err := coapNet.NewListenUDP("udp4", "")
@@ -187,7 +190,7 @@ func TestServerDiscover(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		errS := sd.Serve(ld)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	for _, tt := range tests {
@@ -204,6 +207,166 @@ func TestServerDiscover(t *testing.T) {
 	}
 }
 
+func TestServerDiscoverWithErrors(t *testing.T) {
+	// responder replies to the request by the message with the valid header and the corrupted options
+	responder, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer func() {
+		errC := responder.Close()
+		require.NoError(t, errC)
+	}()
+	go func() {
+		buf := make([]byte, 1500)
+		_, raddr, errR := responder.ReadFromUDP(buf)
+		if errR != nil {
+			return
+		}
+		tkl := int(buf[0] & 0xf)
+		resp := []byte{0x50 | byte(tkl), byte(codes.Content), buf[2], buf[3]}
+		resp = append(resp, buf[4:4+tkl]...)
+		// option delta 15 is reserved for the payload marker
+		resp = append(resp, 0xf1, 0x00)
+		_, _ = responder.WriteToUDP(resp, raddr)
+	}()
+
+	ld, err := coapNet.NewListenUDP("udp4", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := ld.Close()
+		require.NoError(t, errC)
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	sd := udp.NewServer()
+	defer sd.Stop()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := sd.Serve(ld)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*500)
+	defer cancel()
+	var mutex sync.Mutex
+	var errs []error
+	err = sd.DiscoverWithErrors(ctx, responder.LocalAddr().String(), "/oic/res", func(cc *client.Conn, resp *pool.Message, err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		require.NotNil(t, cc)
+		require.Nil(t, resp)
+		errs = append(errs, err)
+	})
+	require.NoError(t, err)
+	mutex.Lock()
+	defer mutex.Unlock()
+	require.Len(t, errs, 1)
+	require.Error(t, errs[0])
+}
+
+func TestServerDiscoverDedicatedListener(t *testing.T) {
+	responder, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer func() {
+		errC := responder.Close()
+		require.NoError(t, errC)
+	}()
+	requestAddr := make(chan *net.UDPAddr, 1)
+	go func() {
+		buf := make([]byte, 1500)
+		_, raddr, errR := responder.ReadFromUDP(buf)
+		if errR != nil {
+			return
+		}
+		requestAddr <- raddr
+		tkl := int(buf[0] & 0xf)
+		resp := []byte{0x50 | byte(tkl), byte(codes.Content), buf[2], buf[3]}
+		resp = append(resp, buf[4:4+tkl]...)
+		_, _ = responder.WriteToUDP(resp, raddr)
+	}()
+
+	ld, err := coapNet.NewListenUDP("udp4", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := ld.Close()
+		require.NoError(t, errC)
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	sd := udp.NewServer()
+	defer sd.Stop()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := sd.Serve(ld)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*500)
+	defer cancel()
+	recv := &mcastreceiver{}
+	err = sd.Discover(ctx, responder.LocalAddr().String(), "/oic/res", recv.process, coapNet.WithMulticastDedicatedListener())
+	require.NoError(t, err)
+	require.Len(t, recv.pop(), 1)
+
+	raddr := <-requestAddr
+	serverAddr, ok := ld.LocalAddr().(*net.UDPAddr)
+	require.True(t, ok)
+	require.NotEqual(t, serverAddr.Port, raddr.Port)
+}
+
+func TestServerDiscoverRepeat(t *testing.T) {
+	responder, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer func() {
+		errC := responder.Close()
+		require.NoError(t, errC)
+	}()
+	var numRequests atomic.Int32
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			_, raddr, errR := responder.ReadFromUDP(buf)
+			if errR != nil {
+				return
+			}
+			numRequests.Inc()
+			tkl := int(buf[0] & 0xf)
+			resp := []byte{0x50 | byte(tkl), byte(codes.Content), buf[2], buf[3]}
+			resp = append(resp, buf[4:4+tkl]...)
+			_, _ = responder.WriteToUDP(resp, raddr)
+		}
+	}()
+
+	ld, err := coapNet.NewListenUDP("udp4", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := ld.Close()
+		require.NoError(t, errC)
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	sd := udp.NewServer()
+	defer sd.Stop()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := sd.Serve(ld)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*500)
+	defer cancel()
+	recv := &mcastreceiver{}
+	err = sd.Discover(ctx, responder.LocalAddr().String(), "/oic/res", recv.process, coapNet.WithMulticastRepeat(3, time.Millisecond*50))
+	require.NoError(t, err)
+	require.Equal(t, int32(3), numRequests.Load())
+	require.Len(t, recv.pop(), 1)
+}
+
 func TestServerCleanUpConns(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
@@ -234,7 +397,7 @@ func TestServerCleanUpConns(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		errS := sd.Serve(ld)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := udp.Dial(ld.LocalAddr().String())
@@ -295,7 +458,7 @@ func TestServerInactiveMonitor(t *testing.T) {
 	go func() {
 		defer serverWg.Done()
 		errS := sd.Serve(ld)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := udp.Dial(
@@ -367,7 +530,7 @@ func TestServerKeepAliveMonitor(t *testing.T) {
 	go func() {
 		defer serverWg.Done()
 		errS := sd.Serve(ld)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 
 	cc, err := udp.Dial(
@@ -395,6 +558,270 @@ func TestServerKeepAliveMonitor(t *testing.T) {
 	require.True(t, inactivityDetected.Load())
 }
 
+func TestServerOnCloseConn(t *testing.T) {
+	ld, err := coapNet.NewListenUDP("udp4", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := ld.Close()
+		require.NoError(t, errC)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*8)
+	defer cancel()
+
+	reasons := make(chan coapNet.CloseReason, 2)
+	sd := udp.NewServer(
+		options.WithOnCloseConn(func(cc *client.Conn, reason coapNet.CloseReason) {
+			reasons <- reason
+		}),
+		options.WithInactivityMonitor(100*time.Millisecond, func(cc *client.Conn) {
+			errC := cc.CloseWithReason(coapNet.CloseReasonIdleTimeout)
+			assert.NoError(t, errC)
+		}),
+		options.WithPeriodicRunner(periodic.New(ctx.Done(), time.Millisecond*10)),
+	)
+
+	var serverWg sync.WaitGroup
+	serverWg.Add(1)
+	go func() {
+		defer serverWg.Done()
+		errS := sd.Serve(ld)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := udp.Dial(ld.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc.Close()
+		require.NoError(t, errC)
+		<-cc.Done()
+	}()
+
+	// send ping to create serverside connection
+	err = cc.Ping(ctx)
+	require.NoError(t, err)
+	select {
+	case reason := <-reasons:
+		require.Equal(t, coapNet.CloseReasonIdleTimeout, reason)
+	case <-ctx.Done():
+		require.NoError(t, ctx.Err())
+	}
+
+	// create serverside connection again and stop the server
+	err = cc.Ping(ctx)
+	require.NoError(t, err)
+	sd.Stop()
+	serverWg.Wait()
+	select {
+	case reason := <-reasons:
+		require.Equal(t, coapNet.CloseReasonServerShutdown, reason)
+		require.True(t, reason.IsGraceful())
+	case <-ctx.Done():
+		require.NoError(t, ctx.Err())
+	}
+}
+
+type refusedCollector struct {
+	metrics.NilCollector
+	dropped atomic.Int32
+}
+
+func (c *refusedCollector) MessageDropped() {
+	c.dropped.Inc()
+}
+
+func TestServerMaxConnections(t *testing.T) {
+	ld, err := coapNet.NewListenUDP("udp4", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := ld.Close()
+		require.NoError(t, errC)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*8)
+	defer cancel()
+
+	var refused atomic.Int32
+	collector := &refusedCollector{}
+	serverConns := make(chan *client.Conn, 2)
+	sd := udp.NewServer(
+		options.WithMaxConnections(1),
+		options.WithMetrics(collector),
+		options.WithOnNewConn(func(cc *client.Conn) {
+			serverConns <- cc
+		}),
+		options.WithErrors(func(err error) {
+			if errors.Is(err, coapNet.ErrMaxConnectionsExceeded) {
+				refused.Inc()
+			}
+		}),
+	)
+
+	var serverWg sync.WaitGroup
+	defer func() {
+		sd.Stop()
+		serverWg.Wait()
+	}()
+	serverWg.Add(1)
+	go func() {
+		defer serverWg.Done()
+		errS := sd.Serve(ld)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := udp.Dial(ld.LocalAddr().String())
+	require.NoError(t, err)
+	err = cc.Ping(ctx)
+	require.NoError(t, err)
+
+	// the datagrams from the second source are dropped
+	cc1, err := udp.Dial(ld.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc1.Close()
+		require.NoError(t, errC)
+		<-cc1.Done()
+	}()
+	for i := 0; i < 3; i++ {
+		ctxPing, cancelPing := context.WithTimeout(ctx, time.Millisecond*100)
+		err = cc1.Ping(ctxPing)
+		cancelPing()
+		require.Error(t, err)
+	}
+	// every refused datagram is counted, but the error is reported once per interval
+	require.Equal(t, int32(1), refused.Load())
+	require.GreaterOrEqual(t, collector.dropped.Load(), int32(3))
+
+	// the slot is released by the close of the serverside connection
+	err = cc.Close()
+	require.NoError(t, err)
+	<-cc.Done()
+	sc := <-serverConns
+	err = sc.Close()
+	require.NoError(t, err)
+	<-sc.Done()
+	err = cc1.Ping(ctx)
+	require.NoError(t, err)
+}
+
+func TestServerMaxGoroutines(t *testing.T) {
+	ld, err := coapNet.NewListenUDP("udp4", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := ld.Close()
+		require.NoError(t, errC)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*8)
+	defer cancel()
+
+	var refused atomic.Bool
+	serverConns := make(chan *client.Conn, 2)
+	sd := udp.NewServer(
+		// the goroutine which processes the received messages of one connection
+		options.WithMaxGoroutines(1),
+		options.WithOnNewConn(func(cc *client.Conn) {
+			serverConns <- cc
+		}),
+		options.WithErrors(func(err error) {
+			if errors.Is(err, coapNet.ErrMaxGoroutinesExceeded) {
+				refused.Store(true)
+			}
+		}),
+	)
+
+	var serverWg sync.WaitGroup
+	defer func() {
+		sd.Stop()
+		serverWg.Wait()
+	}()
+	serverWg.Add(1)
+	go func() {
+		defer serverWg.Done()
+		errS := sd.Serve(ld)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	cc, err := udp.Dial(ld.LocalAddr().String())
+	require.NoError(t, err)
+	err = cc.Ping(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), sd.Goroutines())
+
+	cc1, err := udp.Dial(ld.LocalAddr().String())
+	require.NoError(t, err)
+	defer func() {
+		errC := cc1.Close()
+		require.NoError(t, errC)
+		<-cc1.Done()
+	}()
+	ctxPing, cancelPing := context.WithTimeout(ctx, time.Millisecond*500)
+	defer cancelPing()
+	err = cc1.Ping(ctxPing)
+	require.Error(t, err)
+	require.True(t, refused.Load())
+
+	// the goroutine ends with the serverside connection
+	err = cc.Close()
+	require.NoError(t, err)
+	<-cc.Done()
+	sc := <-serverConns
+	err = sc.Close()
+	require.NoError(t, err)
+	<-sc.Done()
+	require.Eventually(t, func() bool {
+		return sd.Goroutines() == 0
+	}, time.Second, time.Millisecond*10)
+	err = cc1.Ping(ctx)
+	require.NoError(t, err)
+}
+
+func TestServerDualStack(t *testing.T) {
+	ld, err := coapNet.NewListenUDPDualStack(":0")
+	if err != nil {
+		t.Skipf("dual-stack listener is not supported: %v", err)
+	}
+	defer func() {
+		errC := ld.Close()
+		require.NoError(t, errC)
+	}()
+
+	remoteAddrs := make(chan net.Addr, 2)
+	sd := udp.NewServer(options.WithOnNewConn(func(cc *client.Conn) {
+		remoteAddrs <- cc.RemoteAddr()
+	}))
+	var serverWg sync.WaitGroup
+	defer func() {
+		sd.Stop()
+		serverWg.Wait()
+	}()
+	serverWg.Add(1)
+	go func() {
+		defer serverWg.Done()
+		errS := sd.Serve(ld)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	port := strconv.Itoa(ld.LocalAddr().(*net.UDPAddr).Port)
+	for _, host := range []string{"127.0.0.1", "::1"} {
+		cc, errD := udp.Dial(net.JoinHostPort(host, port))
+		require.NoError(t, errD)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*4)
+		errD = cc.Ping(ctx)
+		cancel()
+		require.NoError(t, errD)
+		// IPv4 peers are reported by IPv4 addresses
+		raddr := <-remoteAddrs
+		require.Equal(t, host, raddr.(*net.UDPAddr).IP.String())
+		errD = cc.Close()
+		require.NoError(t, errD)
+		<-cc.Done()
+	}
+
+	_, err = coapNet.NewListenUDPDualStack("127.0.0.1:0")
+	require.Error(t, err)
+}
+
 func TestServerNewClient(t *testing.T) {
 	newServer := func(l *coapNet.UDPConn) (*server.Server, func()) {
 		var wg sync.WaitGroup
@@ -403,7 +830,7 @@ func TestServerNewClient(t *testing.T) {
 		go func() {
 			defer wg.Done()
 			errS := s.Serve(l)
-			assert.NoError(t, errS)
+			assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 		}()
 		return s, func() {
 			s.Stop()
@@ -480,7 +907,7 @@ func TestCheckForLossOrder(t *testing.T) {
 	go func() {
 		defer wg.Done()
 		errS := sd.Serve(ld)
-		assert.NoError(t, errS)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 	}()
 	defer func() {
 		sd.Stop()
@@ -535,7 +962,7 @@ func TestServerReconnectNewClient(t *testing.T) {
 		go func() {
 			defer wg.Done()
 			errS := s.Serve(l)
-			assert.NoError(t, errS)
+			assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
 		}()
 		return s, func() {
 			s.Stop()
@@ -620,9 +1047,10 @@ func TestMuxPath(t *testing.T) {
 		require.NoError(t, errC)
 	}()
 	s := udp.NewServer(options.WithMux(r))
+	defer s.Stop()
 	go func() {
 		errL := s.Serve(l)
-		assert.NoError(t, errL)
+		assert.ErrorIs(t, errL, coapNet.ErrServerClosed)
 	}()
 
 	type args struct {
@@ -679,3 +1107,384 @@ func handleA(w mux.ResponseWriter, _ *mux.Message) {
 		log.Printf("cannot set response: %v", err)
 	}
 }
+
+func TestServerDiscoverFollowUpRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []coapNet.MulticastOption
+	}{
+		{
+			name: "shared listener",
+		},
+		{
+			name: "dedicated listener",
+			opts: []coapNet.MulticastOption{coapNet.WithMulticastDedicatedListener()},
+		},
+	}
+
+	m := mux.NewRouter()
+	err := m.Handle("/oic/res", mux.HandlerFunc(func(w mux.ResponseWriter, _ *mux.Message) {
+		errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("res")))
+		require.NoError(t, errS)
+	}))
+	require.NoError(t, err)
+	err = m.Handle("/light", mux.HandlerFunc(func(w mux.ResponseWriter, _ *mux.Message) {
+		errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("on")))
+		require.NoError(t, errS)
+	}))
+	require.NoError(t, err)
+
+	l, err := coapNet.NewListenUDP("udp4", "127.0.0.1:")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	ld, err := coapNet.NewListenUDP("udp4", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := ld.Close()
+		require.NoError(t, errC)
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	s := udp.NewServer(options.WithMux(m))
+	defer s.Stop()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+	sd := udp.NewServer()
+	defer sd.Stop()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := sd.Serve(ld)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*500)
+			defer cancel()
+			var responders []*client.Conn
+			var mutex sync.Mutex
+			err := sd.Discover(ctx, l.LocalAddr().String(), "/oic/res", func(cc *client.Conn, _ *pool.Message) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				responders = append(responders, cc)
+			}, tt.opts...)
+			require.NoError(t, err)
+			require.Len(t, responders, 1)
+
+			cc := responders[0]
+			require.Equal(t, l.LocalAddr().String(), cc.RemoteAddr().String())
+			reqCtx, reqCancel := context.WithTimeout(context.Background(), time.Second*4)
+			defer reqCancel()
+			resp, err := cc.Get(reqCtx, "/light")
+			require.NoError(t, err)
+			require.Equal(t, codes.Content, resp.Code())
+			body, err := resp.ReadBody()
+			require.NoError(t, err)
+			require.Equal(t, []byte("on"), body)
+		})
+	}
+}
+
+func TestServerDiscoverObserve(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []coapNet.MulticastOption
+	}{
+		{
+			name: "shared listener",
+		},
+		{
+			name: "dedicated listener",
+			opts: []coapNet.MulticastOption{coapNet.WithMulticastDedicatedListener()},
+		},
+	}
+
+	observers := make(chan string, 2)
+	m := mux.NewRouter()
+	err := m.Handle("/oic/res", mux.HandlerFunc(func(w mux.ResponseWriter, _ *mux.Message) {
+		errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("res")))
+		require.NoError(t, errS)
+	}))
+	require.NoError(t, err)
+	err = m.Handle("/obs", mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		if obs, errO := r.Observe(); errO != nil || obs != 0 {
+			errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("0")))
+			require.NoError(t, errS)
+			return
+		}
+		observers <- w.Conn().RemoteAddr().String()
+		errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("1")))
+		require.NoError(t, errS)
+		w.Message().SetObserve(1)
+		cc := w.Conn()
+		token := r.Token()
+		go func() {
+			time.Sleep(time.Millisecond * 10)
+			n := cc.AcquireMessage(cc.Context())
+			defer cc.ReleaseMessage(n)
+			n.SetCode(codes.Content)
+			n.SetToken(token)
+			n.SetObserve(2)
+			n.SetContentFormat(message.TextPlain)
+			n.SetBody(bytes.NewReader([]byte("2")))
+			errW := cc.WriteMessage(n)
+			assert.NoError(t, errW)
+		}()
+	}))
+	require.NoError(t, err)
+
+	l, err := coapNet.NewListenUDP("udp4", "127.0.0.1:")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	ld, err := coapNet.NewListenUDP("udp4", "127.0.0.1:")
+	require.NoError(t, err)
+	defer func() {
+		errC := ld.Close()
+		require.NoError(t, errC)
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	s := udp.NewServer(options.WithMux(m))
+	defer s.Stop()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+	sd := udp.NewServer()
+	defer sd.Stop()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := sd.Serve(ld)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*500)
+			defer cancel()
+			responders := make(chan *client.Conn, 1)
+			err := sd.Discover(ctx, l.LocalAddr().String(), "/oic/res", func(cc *client.Conn, _ *pool.Message) {
+				responders <- cc
+			}, tt.opts...)
+			require.NoError(t, err)
+			cc := <-responders
+
+			// the observation uses the socket of the discovery and the address of the responder
+			notifications := make(chan string, 2)
+			obsCtx, obsCancel := context.WithTimeout(context.Background(), time.Second*4)
+			defer obsCancel()
+			obs, err := cc.Observe(obsCtx, "/obs", func(n *pool.Message) {
+				body, errR := n.ReadBody()
+				assert.NoError(t, errR)
+				notifications <- string(body)
+			})
+			require.NoError(t, err)
+			require.Equal(t, ld.LocalAddr().String(), <-observers)
+			for _, exp := range []string{"1", "2"} {
+				select {
+				case n := <-notifications:
+					require.Equal(t, exp, n)
+				case <-obsCtx.Done():
+					require.NoError(t, obsCtx.Err())
+				}
+			}
+			err = obs.Cancel(obsCtx)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestServerDiscoverVerifier(t *testing.T) {
+	key := []byte("shared secret of the deployment")
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	var closeResponders []func()
+	defer func() {
+		for _, c := range closeResponders {
+			c()
+		}
+	}()
+	// newResponder signs the responses as sent from the signedAddr, by default from its own address
+	newResponder := func(signKey []byte, signedAddr net.Addr) string {
+		l, err := coapNet.NewListenUDP("udp4", "127.0.0.1:")
+		require.NoError(t, err)
+		if signedAddr == nil {
+			signedAddr = l.LocalAddr()
+		}
+		m := mux.NewRouter()
+		m.HandleFunc("/oic/res", func(w mux.ResponseWriter, r *mux.Message) {
+			errS := w.SetResponse(codes.Content, message.TextPlain, bytes.NewReader([]byte("device")), message.Option{ID: message.MaxAge, Value: []byte{60}})
+			assert.NoError(t, errS)
+			if signKey != nil {
+				errS = server.SignDiscoveryResponse(signKey, r.Token(), signedAddr, w.Message())
+				assert.NoError(t, errS)
+			}
+		})
+		s := udp.NewServer(options.WithMux(m))
+		closeResponders = append(closeResponders, func() {
+			s.Stop()
+			errC := l.Close()
+			require.NoError(t, errC)
+		})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errS := s.Serve(l)
+			assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+		}()
+		return l.LocalAddr().String()
+	}
+
+	ld, err := coapNet.NewListenUDP("udp4", "")
+	require.NoError(t, err)
+	defer func() {
+		errC := ld.Close()
+		require.NoError(t, errC)
+	}()
+	sd := udp.NewServer(options.WithDiscoveryVerifier(server.HMACDiscoveryVerifier(key)))
+	defer sd.Stop()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := sd.Serve(ld)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	discover := func(address string) ([]string, []error) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*500)
+		defer cancel()
+		var mutex sync.Mutex
+		var bodies []string
+		var errs []error
+		errD := sd.DiscoverWithErrors(ctx, address, "/oic/res", func(_ *client.Conn, resp *pool.Message, err error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			body, errB := resp.ReadBody()
+			assert.NoError(t, errB)
+			bodies = append(bodies, string(body))
+		})
+		require.NoError(t, errD)
+		mutex.Lock()
+		defer mutex.Unlock()
+		return bodies, errs
+	}
+
+	bodies, errs := discover(newResponder(key, nil))
+	require.Empty(t, errs)
+	require.Equal(t, []string{"device"}, bodies)
+
+	// only the IP address is signed, e.g. the port is changed by NAT and the zone is the interface of the host
+	bodies, errs = discover(newResponder(key, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5683, Zone: "lo"}))
+	require.Empty(t, errs)
+	require.Equal(t, []string{"device"}, bodies)
+
+	// the response signed by the other device is replayed from the address of the impostor
+	deviceAddr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 2), Port: 5683}
+	for _, responder := range []string{newResponder(nil, nil), newResponder([]byte("key of the impostor"), nil), newResponder(key, deviceAddr)} {
+		bodies, errs = discover(responder)
+		require.Empty(t, bodies)
+		require.Len(t, errs, 1)
+		require.ErrorIs(t, errs[0], server.ErrInvalidDiscoverySignature)
+	}
+}
+
+func TestServerNotificationScheduler(t *testing.T) {
+	s := udp.NewServer(options.WithNotificationWorkers(1))
+	scheduler := s.NotificationScheduler()
+	require.Same(t, scheduler, s.NotificationScheduler())
+
+	notified := make(chan struct{})
+	scheduler.Schedule(context.Background(), time.Millisecond*10, func(time.Time) bool {
+		close(notified)
+		return false
+	})
+	select {
+	case <-notified:
+	case <-time.After(time.Second):
+		require.Fail(t, "notification was not served")
+	}
+
+	// the scheduler is closed by the stop of the server
+	s.Stop()
+	require.Eventually(t, func() bool {
+		scheduler.Schedule(context.Background(), time.Millisecond*10, func(time.Time) bool {
+			return true
+		})
+		return scheduler.Len() == 0
+	}, time.Second, time.Millisecond*10)
+}
+
+func TestServerMalformedDatagram(t *testing.T) {
+	l, err := coapNet.NewListenUDP("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() {
+		errC := l.Close()
+		require.NoError(t, errC)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	var conns atomic.Int32
+	transportErrors := make(chan bool, 4)
+	s := udp.NewServer(
+		options.WithOnNewConn(func(*client.Conn) {
+			conns.Inc()
+		}),
+		options.WithOnTransportError(func(_ *client.Conn, err error, fatal bool) {
+			if errors.Is(err, coapNet.ErrMalformedDatagram) {
+				transportErrors <- fatal
+			}
+		}),
+		options.WithErrors(func(error) {}),
+	)
+	defer s.Stop()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errS := s.Serve(l)
+		assert.ErrorIs(t, errS, coapNet.ErrServerClosed)
+	}()
+
+	peer, err := net.DialUDP("udp4", nil, l.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	defer func() {
+		errC := peer.Close()
+		require.NoError(t, errC)
+	}()
+	// option delta 15 is reserved for the payload marker
+	_, err = peer.Write([]byte{0x40, byte(codes.GET), 0, 1, 0xf1, 0x00})
+	require.NoError(t, err)
+	require.False(t, <-transportErrors)
+
+	// the connection stays open and answers the next request
+	_, err = peer.Write([]byte{0x40, byte(codes.GET), 0, 2})
+	require.NoError(t, err)
+	require.NoError(t, peer.SetReadDeadline(time.Now().Add(time.Second*5)))
+	buf := make([]byte, 1500)
+	n, err := peer.Read(buf)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, n, 4)
+	require.Equal(t, byte(codes.NotFound), buf[1])
+	require.Equal(t, []byte{0, 2}, buf[2:4])
+	require.Equal(t, int32(1), conns.Load())
+}